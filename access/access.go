@@ -0,0 +1,147 @@
+package access
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+// Reasons of decisions
+const (
+	ReasonAllowlisted = "allowlisted"
+	ReasonDenylisted  = "denylisted"
+	ReasonExpired     = "expired"
+	ReasonUnknown     = "not listed"
+	ReasonStoreError  = "store error"
+)
+
+// Decision is the outcome for a presented card
+type Decision struct {
+	Time    time.Time `json:"time"`
+	UID     string    `json:"uid"`
+	Name    string    `json:"name,omitempty"`
+	Reader  string    `json:"reader,omitempty"`
+	Allowed bool      `json:"allowed"`
+	Reason  string    `json:"reason"`
+}
+
+// Action reacts to a decision, e.g. opens a door
+type Action interface {
+	Execute(d Decision) error
+}
+
+// ActionFunc adapts a function to an Action
+type ActionFunc func(d Decision) error
+
+func (f ActionFunc) Execute(d Decision) error {
+	return f(d)
+}
+
+// Controller decides on cards with the lists of a store. Unknown cards are
+// denied. Store errors deny as well, so a broken store fails closed. Allow,
+// Decide, Handle and SetAuditLog are safe for concurrent use; add the actions
+// before handling cards.
+type Controller struct {
+	store   Store
+	onAllow []Action
+	onDeny  []Action
+
+	mu    sync.Mutex
+	audit io.Writer
+}
+
+// NewController creates a controller on the store
+func NewController(store Store) *Controller {
+	return &Controller{store: store}
+}
+
+// OnAllow adds actions run for allowed cards
+func (c *Controller) OnAllow(actions ...Action) {
+	c.onAllow = append(c.onAllow, actions...)
+}
+
+// OnDeny adds actions run for denied cards
+func (c *Controller) OnDeny(actions ...Action) {
+	c.onDeny = append(c.onDeny, actions...)
+}
+
+// SetAuditLog writes every handled decision as a JSON line to w, e.g. a file
+// opened with O_APPEND
+func (c *Controller) SetAuditLog(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.audit = w
+}
+
+// Allow reports whether the card is allowed and why
+func (c *Controller) Allow(id []byte) (bool, string) {
+	d := c.Decide(id)
+	return d.Allowed, d.Reason
+}
+
+// Decide looks the card up without running actions or auditing
+func (c *Controller) Decide(id []byte) Decision {
+	d := Decision{Time: time.Now(), UID: uid.Hex(id)}
+	entry, err := c.store.Get(d.UID)
+	switch {
+	case err != nil:
+		d.Reason = ReasonStoreError
+	case entry == nil:
+		d.Reason = ReasonUnknown
+	case entry.Deny:
+		d.Name, d.Reason = entry.Name, ReasonDenylisted
+	case !entry.Expires.IsZero() && d.Time.After(entry.Expires):
+		d.Name, d.Reason = entry.Name, ReasonExpired
+	default:
+		d.Name, d.Reason, d.Allowed = entry.Name, ReasonAllowlisted, true
+	}
+	return d
+}
+
+// Handle decides on the connected card, runs the actions and writes the audit
+// log. All actions run; their errors are joined.
+func (c *Controller) Handle(reader *hardware.Reader) (Decision, error) {
+	d := c.Decide(reader.CardInfo().UID)
+	d.Reader = reader.Reader()
+	return d, c.Apply(d)
+}
+
+// Apply runs the actions of a decision and audits it
+func (c *Controller) Apply(d Decision) error {
+	actions := c.onDeny
+	if d.Allowed {
+		actions = c.onAllow
+	}
+	var errs []error
+	for _, a := range actions {
+		if err := a.Execute(d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := c.writeAudit(d); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Controller) writeAudit(d Decision) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.audit == nil {
+		return nil
+	}
+	line, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %v", err)
+	}
+	if _, err := c.audit.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package access
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stores opens each kind of store with the same entries
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	dir := t.TempDir()
+	fs, err := OpenFileStore(filepath.Join(dir, "access.json"))
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	ss, err := OpenSQLiteStore(filepath.Join(dir, "access.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { ss.Close() })
+	m := map[string]Store{"file": fs, "sqlite": ss}
+	for _, s := range m {
+		fill(t, s)
+	}
+	return m
+}
+
+func fill(t *testing.T, s Store) {
+	t.Helper()
+	for _, e := range []Entry{
+		{UID: "04:A1:B2", Name: "alice"},
+		{UID: "0102", Deny: true},
+		{UID: "0103", Expires: time.Now().Add(-time.Hour)},
+	} {
+		if err := s.Put(e); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			testDecide(t, store)
+		})
+	}
+}
+
+func testDecide(t *testing.T, store Store) {
+	c := NewController(store)
+	for _, tc := range []struct {
+		id      []byte
+		allowed bool
+		reason  string
+	}{
+		{[]byte{0x04, 0xA1, 0xB2}, true, ReasonAllowlisted},
+		{[]byte{0x01, 0x02}, false, ReasonDenylisted},
+		{[]byte{0x01, 0x03}, false, ReasonExpired},
+		{[]byte{0x09}, false, ReasonUnknown},
+	} {
+		allowed, reason := c.Allow(tc.id)
+		if allowed != tc.allowed || reason != tc.reason {
+			t.Errorf("Allow(%X) = %t, %q, want %t, %q", tc.id, allowed, reason, tc.allowed, tc.reason)
+		}
+	}
+}
+
+func TestControllerConcurrent(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			testControllerConcurrent(t, store)
+		})
+	}
+}
+
+func testControllerConcurrent(t *testing.T, store Store) {
+	c := NewController(store)
+	var mu sync.Mutex
+	counts := map[bool]int{}
+	count := ActionFunc(func(d Decision) error {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[d.Allowed]++
+		return nil
+	})
+	c.OnAllow(count)
+	c.OnDeny(count)
+	var audit bytes.Buffer
+	c.SetAuditLog(&audit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		id := []byte{0x04, 0xA1, 0xB2}
+		if i%2 == 1 {
+			id = []byte{0x01, 0x02}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if err := c.Apply(c.Decide(id)); err != nil {
+					t.Errorf("Apply: %v", err)
+				}
+				if err := store.Put(Entry{UID: "0A0B", Name: "visitor"}); err != nil {
+					t.Errorf("Put: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if counts[true] != 200 || counts[false] != 200 {
+		t.Fatalf("actions ran %d times for allowed and %d for denied cards, want 200 each", counts[true], counts[false])
+	}
+	lines := 0
+	scanner := bufio.NewScanner(&audit)
+	for scanner.Scan() {
+		var d Decision
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("audit line %d: %v", lines+1, err)
+		}
+		lines++
+	}
+	if lines != 400 {
+		t.Fatalf("%d audit lines, want 400", lines)
+	}
+}
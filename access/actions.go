@@ -0,0 +1,120 @@
+package access
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/webhook"
+)
+
+// LEDColor selects the LEDs of the ACR122U
+type LEDColor byte
+
+const (
+	LEDRed LEDColor = iota
+	LEDGreen
+	LEDOrange // red and green
+)
+
+// LEDAction blinks the reader LED and sounds the buzzer. It talks through the
+// card connection, so it runs while the card is still on the reader.
+type LEDAction struct {
+	reader  *hardware.Reader
+	color   LEDColor
+	blinks  byte
+	beep    bool
+	onTime  byte // in 100 ms
+	offTime byte
+}
+
+// NewLEDAction blinks the color the given number of times, beeping with each
+// blink if beep is set
+func NewLEDAction(reader *hardware.Reader, color LEDColor, blinks byte, beep bool) *LEDAction {
+	return &LEDAction{reader: reader, color: color, blinks: blinks, beep: beep, onTime: 2, offTime: 2}
+}
+
+// command builds the "LED and buzzer control" pseudo-APDU FF 00 40
+func (a *LEDAction) command() []byte {
+	// P2: blinking mask and initial blinking state per LED, final states off
+	var p2 byte
+	switch a.color {
+	case LEDRed:
+		p2 = 0x54
+	case LEDGreen:
+		p2 = 0xA8
+	case LEDOrange:
+		p2 = 0xFC
+	}
+	var buzzer byte
+	if a.beep {
+		buzzer = 0x01 // during T1
+	}
+	return []byte{0xFF, 0x00, 0x40, p2, 0x04, a.onTime, a.offTime, a.blinks, buzzer}
+}
+
+func (a *LEDAction) Execute(Decision) error {
+	rsp, err := a.reader.Transport().Transmit(a.command())
+	if err != nil {
+		return fmt.Errorf("failed to set LED: %v", err)
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 {
+		return fmt.Errorf("failed to set LED: response % X", rsp)
+	}
+	return nil
+}
+
+// WebhookAction posts the decision as JSON
+type WebhookAction struct {
+	notifier *webhook.Notifier
+}
+
+// NewWebhookAction posts decisions through the notifier
+func NewWebhookAction(notifier *webhook.Notifier) *WebhookAction {
+	return &WebhookAction{notifier: notifier}
+}
+
+func (a *WebhookAction) Execute(d Decision) error {
+	return a.notifier.Send(context.Background(), d)
+}
+
+// CommandAction runs a program, e.g. to switch a door relay. The decision is
+// passed in the environment variables ACCESS_UID, ACCESS_NAME, ACCESS_ALLOWED,
+// ACCESS_REASON and ACCESS_READER.
+type CommandAction struct {
+	name    string
+	args    []string
+	timeout time.Duration
+}
+
+// NewCommandAction runs the program with the arguments, killing it after
+// timeout if not zero
+func NewCommandAction(timeout time.Duration, name string, args ...string) *CommandAction {
+	return &CommandAction{name: name, args: args, timeout: timeout}
+}
+
+func (a *CommandAction) Execute(d Decision) error {
+	ctx := context.Background()
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, a.name, a.args...)
+	cmd.Env = append(os.Environ(),
+		"ACCESS_UID="+d.UID,
+		"ACCESS_NAME="+d.Name,
+		"ACCESS_ALLOWED="+strconv.FormatBool(d.Allowed),
+		"ACCESS_REASON="+d.Reason,
+		"ACCESS_READER="+d.Reader,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command %s failed: %v: %s", a.name, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
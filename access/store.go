@@ -0,0 +1,278 @@
+package access
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+// Entry is a listed card
+type Entry struct {
+	UID     string    `json:"uid"` // upper case hex
+	Name    string    `json:"name,omitempty"`
+	Deny    bool      `json:"deny,omitempty"`   // denylisted instead of allowlisted
+	Expires time.Time `json:"expires,omitzero"` // allowed until, forever if zero
+}
+
+// Store persists the allow and deny lists. Get returns nil for unknown UIDs.
+// Both implementations are safe for concurrent use.
+type Store interface {
+	Get(id string) (*Entry, error)
+	Put(entry Entry) error
+	Delete(id string) error
+	Entries() ([]Entry, error)
+	Close() error
+}
+
+// normalize returns the UID in the form stored in entries
+func normalize(id string) (string, error) {
+	b, err := uid.Parse(id)
+	if err != nil {
+		return "", err
+	}
+	return uid.Hex(b), nil
+}
+
+// FileStore keeps the entries in a JSON file, rewritten on every change
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]Entry
+}
+
+// OpenFileStore loads the file, which is created on the first change if it
+// does not exist
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, entries: make(map[string]Entry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access list: %v", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse access list: %v", err)
+	}
+	for _, e := range entries {
+		id, err := normalize(e.UID)
+		if err != nil {
+			return nil, fmt.Errorf("access list: %v", err)
+		}
+		e.UID = id
+		s.entries[id] = e
+	}
+	return s, nil
+}
+
+func (s *FileStore) Get(id string) (*Entry, error) {
+	id, err := normalize(id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (s *FileStore) Put(entry Entry) error {
+	id, err := normalize(entry.UID)
+	if err != nil {
+		return err
+	}
+	entry.UID = id
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.entries[id]
+	s.entries[id] = entry
+	if err := s.save(); err != nil {
+		if existed {
+			s.entries[id] = old
+		} else {
+			delete(s.entries, id)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(id string) error {
+	id, err := normalize(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.entries[id]
+	if !ok {
+		return nil
+	}
+	delete(s.entries, id)
+	if err := s.save(); err != nil {
+		s.entries[id] = old
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Entries() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted(), nil
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+func (s *FileStore) sorted() []Entry {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UID < entries[j].UID })
+	return entries
+}
+
+// save writes a temporary file and renames it, so a crash never leaves a
+// truncated list
+func (s *FileStore) save() error {
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode access list: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write access list: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write access list: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write access list: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write access list: %v", err)
+	}
+	return nil
+}
+
+// SQLiteStore keeps the entries in an SQLite database, for large lists or
+// lists shared with other programs
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens or creates the database
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access database: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS access_entries (
+		uid     TEXT PRIMARY KEY,
+		name    TEXT NOT NULL DEFAULT '',
+		deny    INTEGER NOT NULL DEFAULT 0,
+		expires INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create access table: %v", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// scanEntry reads a row; expires is stored as Unix seconds, 0 for never
+func scanEntry(row interface{ Scan(...any) error }) (Entry, error) {
+	var e Entry
+	var expires int64
+	if err := row.Scan(&e.UID, &e.Name, &e.Deny, &expires); err != nil {
+		return e, err
+	}
+	if expires != 0 {
+		e.Expires = time.Unix(expires, 0)
+	}
+	return e, nil
+}
+
+func (s *SQLiteStore) Get(id string) (*Entry, error) {
+	id, err := normalize(id)
+	if err != nil {
+		return nil, err
+	}
+	row := s.db.QueryRow(`SELECT uid, name, deny, expires FROM access_entries WHERE uid = ?`, id)
+	e, err := scanEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access entry: %v", err)
+	}
+	return &e, nil
+}
+
+func (s *SQLiteStore) Put(entry Entry) error {
+	id, err := normalize(entry.UID)
+	if err != nil {
+		return err
+	}
+	var expires int64
+	if !entry.Expires.IsZero() {
+		expires = entry.Expires.Unix()
+	}
+	_, err = s.db.Exec(`INSERT INTO access_entries (uid, name, deny, expires) VALUES (?, ?, ?, ?)
+		ON CONFLICT(uid) DO UPDATE SET name = excluded.name, deny = excluded.deny, expires = excluded.expires`,
+		id, entry.Name, entry.Deny, expires)
+	if err != nil {
+		return fmt.Errorf("failed to store access entry: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Delete(id string) error {
+	id, err := normalize(id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM access_entries WHERE uid = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete access entry: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Entries() ([]Entry, error) {
+	rows, err := s.db.Query(`SELECT uid, name, deny, expires FROM access_entries ORDER BY uid`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access entries: %v", err)
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read access entry: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
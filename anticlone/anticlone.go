@@ -0,0 +1,324 @@
+package anticlone
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/oo-developer/acr122u/access"
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/ndef"
+	"github.com/oo-developer/acr122u/ntag"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+// Status is the outcome of one check
+type Status string
+
+const (
+	StatusPass    Status = "pass"
+	StatusFail    Status = "fail"
+	StatusSkipped Status = "skipped" // does not apply to the card, or could not run
+)
+
+// Names of the checks
+const (
+	CheckAllowlist = "allowlist"
+	CheckUID       = "uid"       // UID matches the manufacturer data and its BCC
+	CheckSignature = "signature" // NXP originality signature
+	CheckGen2      = "gen2"      // manufacturer block refuses writes
+	CheckChallenge = "challenge" // card proves its key
+	CheckGen1A     = "gen1a"     // no backdoor
+)
+
+// Check is the result of one check with the reason
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Verdict is the report on a card
+type Verdict struct {
+	UID  string `json:"uid"`
+	Type string `json:"type"`
+	// Genuine is set when no check failed and at least one passed
+	Genuine bool    `json:"genuine"`
+	Checks  []Check `json:"checks"`
+}
+
+// Failed returns the failed checks
+func (v Verdict) Failed() []Check {
+	var failed []Check
+	for _, c := range v.Checks {
+		if c.Status == StatusFail {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// KeyFunc returns the key of a card, e.g. diversified from a master key
+type KeyFunc func(id []byte) ([]byte, error)
+
+// StaticKey returns the same key for every card
+func StaticKey(key []byte) KeyFunc {
+	return func([]byte) ([]byte, error) { return key, nil }
+}
+
+// DiversifiedAES derives the AES key of each card from a master key with
+// desfire.DiversifyAES, over the UID, the AID and the system identifier
+func DiversifiedAES(masterKey []byte, aid []byte, systemID []byte) KeyFunc {
+	return func(id []byte) ([]byte, error) {
+		input := append(append(append([]byte{}, id...), aid...), systemID...)
+		return desfire.DiversifyAES(masterKey, input)
+	}
+}
+
+// DESFireChallenge authenticates with an AES key of an application
+type DESFireChallenge struct {
+	AID   []byte
+	KeyNo byte
+	Key   KeyFunc
+}
+
+// Config selects the checks. Checks that are not configured, or do not apply
+// to the card family, are reported as skipped.
+type Config struct {
+	// Allowlist holds the cards that are allowed, see access.Controller
+	Allowlist access.Store
+	// Signature checks the originality signature of NTAG21x, Ultralight EV1
+	// and DESFire EV2 or later against OriginalityKeys. Cards with random
+	// UIDs sign their real UID, so their signature does not verify.
+	Signature bool
+	// Magic checks the UID against the manufacturer data of Classic and Type
+	// 2 cards and probes Classic cards for the gen1a backdoor. The probe runs
+	// last since it halts the card.
+	Magic bool
+	// WriteProbe writes the manufacturer block of Classic cards back
+	// unchanged with the factory key A, which only gen2 magic cards take
+	WriteProbe bool
+	// DESFire authenticates DESFire cards, if set
+	DESFire *DESFireChallenge
+	// ULCKey authenticates Ultralight C cards, if set
+	ULCKey KeyFunc
+}
+
+// Verifier combines the checks against cloned cards
+type Verifier struct {
+	config     Config
+	controller *access.Controller
+}
+
+// NewVerifier creates a verifier running the configured checks
+func NewVerifier(config Config) *Verifier {
+	v := &Verifier{config: config}
+	if config.Allowlist != nil {
+		v.controller = access.NewController(config.Allowlist)
+	}
+	return v
+}
+
+// family of a card as far as the checks are concerned
+const (
+	familyClassic = "classic"
+	familyType2   = "type2"
+	familyDESFire = "desfire"
+)
+
+func familyOf(info *hardware.CardInfo) string {
+	switch {
+	case classic.Supported(info):
+		return familyClassic
+	case strings.Contains(info.Type, "DESFire"):
+		return familyDESFire
+	case ndef.DetectTagType(info) == ndef.TagType2:
+		return familyType2
+	}
+	return ""
+}
+
+// Verify runs the checks on the connected card. Failed checks are part of
+// the verdict; the error is reserved for a missing card.
+func (v *Verifier) Verify(reader *hardware.Reader) (Verdict, error) {
+	info := reader.CardInfo()
+	if info == nil {
+		return Verdict{}, fmt.Errorf("no card connected")
+	}
+	verdict := Verdict{UID: uid.Hex(info.UID), Type: info.Type}
+	family := familyOf(info)
+	add := func(name string) func(Status, string) {
+		return func(status Status, detail string) {
+			verdict.Checks = append(verdict.Checks, Check{Name: name, Status: status, Detail: detail})
+		}
+	}
+
+	if v.controller != nil {
+		d := v.controller.Decide(info.UID)
+		if d.Allowed {
+			add(CheckAllowlist)(StatusPass, d.Reason)
+		} else {
+			add(CheckAllowlist)(StatusFail, d.Reason)
+		}
+	}
+	if v.config.Magic {
+		add(CheckUID)(v.checkUID(reader, family, info.UID))
+	}
+	if v.config.WriteProbe {
+		add(CheckGen2)(v.checkGen2(reader, family))
+	}
+	if v.config.Signature {
+		add(CheckSignature)(v.checkSignature(reader, family, info.UID))
+	}
+	if v.config.DESFire != nil || v.config.ULCKey != nil {
+		add(CheckChallenge)(v.checkChallenge(reader, family, info.UID))
+	}
+	if v.config.Magic {
+		add(CheckGen1A)(v.checkGen1A(reader, family))
+	}
+
+	passed := false
+	for _, c := range verdict.Checks {
+		if c.Status == StatusFail {
+			return verdict, nil
+		}
+		passed = passed || c.Status == StatusPass
+	}
+	verdict.Genuine = passed
+	return verdict, nil
+}
+
+// checkUID compares the UID of the anticollision with the manufacturer data
+// and its check bytes
+func (v *Verifier) checkUID(reader *hardware.Reader, family string, id []byte) (Status, string) {
+	switch family {
+	case familyClassic:
+		if len(id) != 4 {
+			return StatusSkipped, "7 byte UIDs carry no check byte"
+		}
+		c := classic.NewClassic(reader)
+		if c.TryStandardKeys(0, classic.KeyTypeA) == "" {
+			return StatusSkipped, "sector 0 does not open with a default key"
+		}
+		block0, err := c.ReadBlock(0)
+		if err != nil {
+			return StatusSkipped, err.Error()
+		}
+		if !bytes.Equal(block0[0:4], id) {
+			return StatusFail, fmt.Sprintf("manufacturer block holds UID %X", block0[0:4])
+		}
+		if bcc := id[0] ^ id[1] ^ id[2] ^ id[3]; block0[4] != bcc {
+			return StatusFail, fmt.Sprintf("BCC %02X, expected %02X", block0[4], bcc)
+		}
+		return StatusPass, ""
+	case familyType2:
+		if len(id) != 7 {
+			return StatusSkipped, fmt.Sprintf("unexpected UID length %d", len(id))
+		}
+		pages, err := ntag.NewNTAG(reader).ReadPages(0)
+		if err != nil {
+			return StatusSkipped, err.Error()
+		}
+		if !bytes.Equal(pages[0:3], id[0:3]) || !bytes.Equal(pages[4:8], id[3:7]) {
+			return StatusFail, fmt.Sprintf("pages 0 and 1 hold UID %X", append(pages[0:3:3], pages[4:8]...))
+		}
+		if bcc0 := 0x88 ^ id[0] ^ id[1] ^ id[2]; pages[3] != bcc0 {
+			return StatusFail, fmt.Sprintf("BCC0 %02X, expected %02X", pages[3], bcc0)
+		}
+		if bcc1 := id[3] ^ id[4] ^ id[5] ^ id[6]; pages[8] != bcc1 {
+			return StatusFail, fmt.Sprintf("BCC1 %02X, expected %02X", pages[8], bcc1)
+		}
+		return StatusPass, ""
+	}
+	return StatusSkipped, "not a Classic or Type 2 card"
+}
+
+func (v *Verifier) checkGen2(reader *hardware.Reader, family string) (Status, string) {
+	if family != familyClassic {
+		return StatusSkipped, "not a Classic card"
+	}
+	writable, err := classic.NewClassic(reader).BlockZeroWritable([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	switch {
+	case err != nil:
+		return StatusSkipped, err.Error()
+	case writable:
+		return StatusFail, "manufacturer block is writable"
+	}
+	return StatusPass, ""
+}
+
+func (v *Verifier) checkSignature(reader *hardware.Reader, family string, id []byte) (Status, string) {
+	var signature []byte
+	switch family {
+	case familyType2:
+		n := ntag.NewNTAG(reader)
+		if _, err := n.GetVersion(); err != nil {
+			return StatusSkipped, "card has no signature"
+		}
+		// Tags that answer GET_VERSION are signed
+		var err error
+		if signature, err = n.ReadSignature(); err != nil {
+			return StatusFail, err.Error()
+		}
+	case familyDESFire:
+		var err error
+		if signature, err = desfire.NewDESFire(reader).ReadSignature(); err != nil {
+			return StatusSkipped, fmt.Sprintf("card has no signature: %v", err)
+		}
+	default:
+		return StatusSkipped, "card family has no signature"
+	}
+	if name := VerifySignature(id, signature); name != "" {
+		return StatusPass, name
+	}
+	return StatusFail, fmt.Sprintf("signature %X does not verify", signature)
+}
+
+func (v *Verifier) checkChallenge(reader *hardware.Reader, family string, id []byte) (Status, string) {
+	switch {
+	case family == familyDESFire && v.config.DESFire != nil:
+		challenge := v.config.DESFire
+		key, err := challenge.Key(id)
+		if err != nil {
+			return StatusSkipped, fmt.Sprintf("no key: %v", err)
+		}
+		df := desfire.NewDESFire(reader)
+		if err := df.SelectApplication(challenge.AID); err != nil {
+			return StatusFail, err.Error()
+		}
+		if err := df.AuthenticateAES(challenge.KeyNo, key); err != nil {
+			return StatusFail, err.Error()
+		}
+		return StatusPass, fmt.Sprintf("application %X key %d", challenge.AID, challenge.KeyNo)
+	case family == familyType2 && v.config.ULCKey != nil:
+		n := ntag.NewNTAG(reader)
+		if _, err := n.GetVersion(); err == nil {
+			return StatusSkipped, "not an Ultralight C card"
+		}
+		key, err := v.config.ULCKey(id)
+		if err != nil {
+			return StatusSkipped, fmt.Sprintf("no key: %v", err)
+		}
+		if err := n.AuthenticateULC(key); err != nil {
+			return StatusFail, err.Error()
+		}
+		return StatusPass, "Ultralight C key"
+	}
+	return StatusSkipped, "no challenge for the card family"
+}
+
+func (v *Verifier) checkGen1A(reader *hardware.Reader, family string) (Status, string) {
+	if family != familyClassic {
+		return StatusSkipped, "not a Classic card"
+	}
+	gen1a, err := classic.NewClassic(reader).IsGen1A()
+	switch {
+	case err != nil:
+		return StatusSkipped, err.Error()
+	case gen1a:
+		return StatusFail, "card answers the gen1a backdoor"
+	}
+	return StatusPass, ""
+}
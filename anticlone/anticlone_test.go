@@ -0,0 +1,275 @@
+package anticlone
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/oo-developer/acr122u/access"
+	"github.com/oo-developer/acr122u/batch"
+	"github.com/oo-developer/acr122u/classic/simulator"
+	"github.com/oo-developer/acr122u/desfire/provision"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ntag"
+	ntagsim "github.com/oo-developer/acr122u/ntag/simulator"
+)
+
+// present connects a mock reader to a card
+func present(t *testing.T, card hardware.Transport, atr []byte) *hardware.Reader {
+	t.Helper()
+	reader := mock.NewReader(card, atr)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return reader
+}
+
+// verify runs the checks and compares the statuses of the verdict
+func verify(t *testing.T, reader *hardware.Reader, config Config, genuine bool, statuses map[string]Status) {
+	t.Helper()
+	verdict, err := NewVerifier(config).Verify(reader)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if verdict.Genuine != genuine {
+		t.Errorf("verdict %+v, want genuine %t", verdict, genuine)
+	}
+	for _, c := range verdict.Checks {
+		if want, ok := statuses[c.Name]; ok && c.Status != want {
+			t.Errorf("check %s: %s (%s), want %s", c.Name, c.Status, c.Detail, want)
+		}
+	}
+}
+
+func TestCurve(t *testing.T) {
+	if !secp128r1.IsOnCurve(secp128r1.Gx, secp128r1.Gy) {
+		t.Fatalf("generator not on secp128r1")
+	}
+	for _, key := range OriginalityKeys {
+		size := (key.Curve.Params().BitSize + 7) / 8
+		x := new(big.Int).SetBytes(key.Point[1 : 1+size])
+		y := new(big.Int).SetBytes(key.Point[1+size:])
+		if len(key.Point) != 1+2*size || !key.Curve.IsOnCurve(x, y) {
+			t.Errorf("%s key not on its curve", key.Name)
+		}
+	}
+}
+
+func TestClassic(t *testing.T) {
+	config := Config{Magic: true, WriteProbe: true}
+	for _, tc := range []struct {
+		name    string
+		magic   simulator.Magic
+		genuine bool
+		failed  string
+	}{
+		{"genuine", simulator.MagicNone, true, ""},
+		{"gen1a", simulator.MagicGen1A, false, CheckGen1A},
+		{"gen2", simulator.MagicGen2, false, CheckGen2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			card, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+			if err != nil {
+				t.Fatalf("NewClassic1K: %v", err)
+			}
+			card.SetMagic(tc.magic)
+			statuses := map[string]Status{CheckUID: StatusPass, CheckGen1A: StatusPass, CheckGen2: StatusPass}
+			if tc.failed != "" {
+				statuses[tc.failed] = StatusFail
+			}
+			verify(t, present(t, card, mock.Classic1KATR), config, tc.genuine, statuses)
+		})
+	}
+
+	blank, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	memory := blank.Dump()
+	memory[4] ^= 0xFF // BCC
+	card, err := simulator.NewSimulator(memory)
+	if err != nil {
+		t.Fatalf("NewSimulator: %v", err)
+	}
+	verify(t, present(t, card, mock.Classic1KATR), config, false, map[string]Status{CheckUID: StatusFail})
+}
+
+func TestAllowlist(t *testing.T) {
+	store, err := access.OpenFileStore(filepath.Join(t.TempDir(), "access.json"))
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	if err := store.Put(access.Entry{UID: "01020304", Name: "alice"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	for _, tc := range []struct {
+		uid     []byte
+		genuine bool
+	}{
+		{[]byte{0x01, 0x02, 0x03, 0x04}, true},
+		{[]byte{0x01, 0x02, 0x03, 0x05}, false},
+	} {
+		card, err := mock.NewClassic1K(tc.uid)
+		if err != nil {
+			t.Fatalf("NewClassic1K: %v", err)
+		}
+		verify(t, present(t, card, mock.Classic1KATR), Config{Allowlist: store}, tc.genuine, nil)
+	}
+}
+
+func TestNTAGSignature(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(secp128r1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := append([]byte{0x04}, append(priv.X.FillBytes(make([]byte, 16)), priv.Y.FillBytes(make([]byte, 16))...)...)
+	saved := OriginalityKeys
+	OriginalityKeys = append(OriginalityKeys, OriginalityKey{"test", secp128r1, point})
+	t.Cleanup(func() { OriginalityKeys = saved })
+
+	id := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	signed, err := mock.NewNTAG215(id)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, priv, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed.SetSignature(append(r.FillBytes(make([]byte, 16)), s.FillBytes(make([]byte, 16))...))
+	verify(t, present(t, signed, mock.NTAGATR), Config{Signature: true, Magic: true}, true,
+		map[string]Status{CheckSignature: StatusPass, CheckUID: StatusPass, CheckGen1A: StatusSkipped})
+
+	unsigned, err := mock.NewNTAG215(id)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	verify(t, present(t, unsigned, mock.NTAGATR), Config{Signature: true}, false,
+		map[string]Status{CheckSignature: StatusFail})
+}
+
+func TestUltralightC(t *testing.T) {
+	key := []byte("0123456789ABCDEF")
+	newCard := func() *ntagsim.Simulator {
+		memory := make([]byte, 192)
+		copy(memory, []byte{0x04, 0x01, 0x02, 0x88 ^ 0x04 ^ 0x01 ^ 0x02, 0x03, 0x04, 0x05, 0x06, 0x03 ^ 0x04 ^ 0x05 ^ 0x06, 0x48, 0x00, 0x00, 0xE1, 0x10, 0x06, 0x00})
+		for i, page := range ntag.ULCKeyPages(key) {
+			copy(memory[(ntag.ULCKeyPage+i)*4:], page)
+		}
+		card, err := ntagsim.NewSimulator(memory)
+		if err != nil {
+			t.Fatalf("NewSimulator: %v", err)
+		}
+		return card
+	}
+	verify(t, present(t, newCard(), mock.NTAGATR), Config{ULCKey: StaticKey(key), Magic: true}, true,
+		map[string]Status{CheckChallenge: StatusPass, CheckUID: StatusPass})
+	other := []byte("FEDCBA9876543210")
+	verify(t, present(t, newCard(), mock.NTAGATR), Config{ULCKey: StaticKey(other)}, false,
+		map[string]Status{CheckChallenge: StatusFail})
+
+	// A key written through the card module authenticates afterwards
+	tag := ntag.NewNTAG(present(t, newCard(), mock.NTAGATR))
+	if err := tag.AuthenticateULC(key); err != nil {
+		t.Fatalf("AuthenticateULC: %v", err)
+	}
+	if err := tag.WriteULCKey(other); err != nil {
+		t.Fatalf("WriteULCKey: %v", err)
+	}
+	if err := tag.AuthenticateULC(other); err != nil {
+		t.Fatalf("AuthenticateULC with the new key: %v", err)
+	}
+}
+
+func TestDESFire(t *testing.T) {
+	id := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	aid := []byte{0x01, 0x02, 0x03}
+	keyOf := DiversifiedAES(make([]byte, 16), aid, []byte("sys"))
+	key, err := keyOf(id)
+	if err != nil {
+		t.Fatalf("DiversifiedAES: %v", err)
+	}
+	profile, err := provision.ParseProfile([]byte(`
+picc_key_type: des
+picc_key: "0000000000000000"
+applications:
+  - aid: "010203"
+    key_settings: 0x0F
+    key_type: aes
+    keys:
+      - value: "` + hex.EncodeToString(key) + `"
+`))
+	if err != nil {
+		t.Fatalf("ParseProfile: %v", err)
+	}
+	card, err := mock.NewDESFire(id)
+	if err != nil {
+		t.Fatalf("NewDESFire: %v", err)
+	}
+	reader := present(t, card, mock.DESFireATR)
+	station, err := batch.NewStation(batch.Config{Profile: &batch.DESFireProfile{Profile: profile}})
+	if err != nil {
+		t.Fatalf("NewStation: %v", err)
+	}
+	if err := station.Handle(reader); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	config := Config{Signature: true, DESFire: &DESFireChallenge{AID: aid, Key: keyOf}}
+	verify(t, reader, config, true, map[string]Status{CheckChallenge: StatusPass})
+	config.DESFire.Key = StaticKey(make([]byte, 16))
+	verify(t, reader, config, false, map[string]Status{CheckChallenge: StatusFail})
+	card.SetSignature(make([]byte, 56))
+	verify(t, reader, Config{Signature: true}, false, map[string]Status{CheckSignature: StatusFail})
+}
+
+// newNTAG215 returns a blank NTAG215 with the UID, without a signature
+func newNTAG215(t *testing.T, id []byte) *ntagsim.Simulator {
+	t.Helper()
+	card, err := mock.NewNTAG215(id)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	return card
+}
+
+func TestVerifySignature(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(secp128r1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := append([]byte{0x04}, append(priv.X.FillBytes(make([]byte, 16)), priv.Y.FillBytes(make([]byte, 16))...)...)
+	saved := OriginalityKeys
+	OriginalityKeys = append(OriginalityKeys, OriginalityKey{"test", secp128r1, point})
+	t.Cleanup(func() { OriginalityKeys = saved })
+
+	id := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	signed := newNTAG215(t, id)
+	r, s, err := ecdsa.Sign(rand.Reader, priv, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed.SetSignature(append(r.FillBytes(make([]byte, 16)), s.FillBytes(make([]byte, 16))...))
+	signature, err := ntag.NewNTAGWithTransport(signed).ReadSignature()
+	if err != nil {
+		t.Fatalf("ReadSignature: %v", err)
+	}
+	if name := VerifySignature(id, signature); name != "test" {
+		t.Fatalf("signature verified by %q", name)
+	}
+	if name := VerifySignature([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x07}, signature); name != "" {
+		t.Fatalf("signature of another UID verified by %q", name)
+	}
+
+	signature, err = ntag.NewNTAGWithTransport(newNTAG215(t, id)).ReadSignature()
+	if err != nil {
+		t.Fatalf("ReadSignature: %v", err)
+	}
+	if name := VerifySignature(id, signature); name != "" {
+		t.Fatalf("unsigned tag verified by %q", name)
+	}
+}
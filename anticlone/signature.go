@@ -0,0 +1,71 @@
+package anticlone
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+)
+
+// secp128r1 is the curve of the NTAG and Ultralight EV1 signatures, which
+// the standard library does not provide
+var secp128r1 = &elliptic.CurveParams{
+	Name:    "secp128r1",
+	BitSize: 128,
+	P:       bigHex("FFFFFFFDFFFFFFFFFFFFFFFFFFFFFFFF"),
+	N:       bigHex("FFFFFFFE0000000075A30D1B9038A115"),
+	B:       bigHex("E87579C11079F43DD824993C2CEE5ED3"),
+	Gx:      bigHex("161FF7528B899B2D0C28607CA52C5B86"),
+	Gy:      bigHex("CF5AC8395BAFEB13C02DA292DDED7A83"),
+}
+
+// OriginalityKey is a public key NXP signs the UIDs of a card family with
+type OriginalityKey struct {
+	Name  string
+	Curve elliptic.Curve
+	Point []byte // uncompressed: 04, X, Y
+}
+
+// OriginalityKeys are the published keys the signatures are checked
+// against. Add keys of further families before verifying.
+var OriginalityKeys = []OriginalityKey{
+	{"NTAG21x", secp128r1, mustHex("04494E1A386D3D3CFE3DC10E5DE68A499B1C202DB5B132393E89ED19FE5BE8BC61")},
+	{"MIFARE Ultralight EV1", secp128r1, mustHex("0490933BDCD6E99B4E255E3DA55389A827564E11718E017292FAF23226A96614B8")},
+	{"DESFire EV2", elliptic.P224(), mustHex("04B304DC4C615F5326FE9383DDEC9AA892DF3A57FA7FFB3276192BC0EAA252ED45A865E3B093A3D0DCE5BE29E92F1392CE7DE321E3E5C52B3A")},
+}
+
+// VerifySignature checks an originality signature, r and s concatenated,
+// of a UID. The UID itself is signed, without hashing. It returns the name
+// of the key that verifies, empty if none does.
+func VerifySignature(id []byte, signature []byte) string {
+	for _, key := range OriginalityKeys {
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size || len(key.Point) != 1+2*size || key.Point[0] != 0x04 {
+			continue
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: key.Curve,
+			X:     new(big.Int).SetBytes(key.Point[1 : 1+size]),
+			Y:     new(big.Int).SetBytes(key.Point[1+size:]),
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if ecdsa.Verify(pub, id, r, s) {
+			return key.Name
+		}
+	}
+	return ""
+}
+
+func bigHex(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 16)
+	return n
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
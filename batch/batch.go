@@ -0,0 +1,265 @@
+package batch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oo-developer/acr122u/access"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/scanlog"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+// Card is the card being personalized
+type Card struct {
+	Info *hardware.CardInfo
+	// Serial is the number the station assigns to the card if it succeeds
+	Serial int
+}
+
+// Profile personalizes the cards of one family. Apply must be idempotent, so
+// a card that failed or was pulled early can be presented again.
+type Profile interface {
+	Name() string
+	Supports(info *hardware.CardInfo) bool
+	Apply(ctx context.Context, reader *hardware.Reader, card *Card) error
+	// Verify checks a card after Apply, reading it back
+	Verify(ctx context.Context, reader *hardware.Reader, card *Card) error
+}
+
+// Result is the outcome of a presented card
+type Result string
+
+const (
+	ResultDone        Result = "done"
+	ResultFailed      Result = "failed"
+	ResultQuarantined Result = "quarantined" // failed too often, now refused
+	ResultRejected    Result = "rejected"    // presented while quarantined
+	ResultUnsupported Result = "unsupported"
+	ResultDuplicate   Result = "duplicate" // already done in this run
+)
+
+// ErrQuarantined is returned for cards that are in quarantine
+var ErrQuarantined = errors.New("card is quarantined")
+
+// Config configures a station
+type Config struct {
+	Profile Profile
+	// FirstSerial is the serial of the first card done
+	FirstSerial int
+	// MaxAttempts is the number of failed runs after which a card is
+	// quarantined, 3 if zero
+	MaxAttempts int
+	// QuarantinePath is a file of the UIDs of quarantined cards in hex, one
+	// per line. It is read when the station is created and appended to; if
+	// empty, quarantine lasts for the run only.
+	QuarantinePath string
+	// Log records every presented card with the profile, result, serial and
+	// error as metadata, if set
+	Log *scanlog.Logger
+	// Feedback blinks the LED and beeps: green once when done, red twice on
+	// failure and orange for duplicates
+	Feedback bool
+}
+
+// Counters count the results of a run
+type Counters struct {
+	Done        int `json:"done"`
+	Failed      int `json:"failed"`
+	Quarantined int `json:"quarantined"`
+	Rejected    int `json:"rejected"`
+	Unsupported int `json:"unsupported"`
+	Duplicate   int `json:"duplicate"`
+}
+
+// Station personalizes the cards presented one after another. Its Handle
+// method serves as daemon.Handler, so the daemon waits for each card and
+// survives reader restarts. Counters may be called from any goroutine.
+type Station struct {
+	config Config
+
+	mu         sync.Mutex
+	counters   Counters
+	attempts   map[string]int
+	quarantine map[string]bool
+	done       map[string]bool
+}
+
+// NewStation creates a station, loading the quarantine file if configured
+func NewStation(config Config) (*Station, error) {
+	if config.Profile == nil {
+		return nil, fmt.Errorf("no profile configured")
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 3
+	}
+	s := &Station{
+		config:     config,
+		attempts:   make(map[string]int),
+		quarantine: make(map[string]bool),
+		done:       make(map[string]bool),
+	}
+	if config.QuarantinePath != "" {
+		if err := s.loadQuarantine(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Station) loadQuarantine() error {
+	f, err := os.Open(s.config.QuarantinePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open quarantine file: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			s.quarantine[strings.ToUpper(line)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read quarantine file: %v", err)
+	}
+	return nil
+}
+
+// Counters returns a snapshot of the counters
+func (s *Station) Counters() Counters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters
+}
+
+// Handle personalizes the connected card
+func (s *Station) Handle(reader *hardware.Reader) error {
+	return s.HandleContext(context.Background(), reader)
+}
+
+// HandleContext personalizes the connected card: it is checked against the
+// quarantine and the cards done, the profile is applied and verified, and
+// the result is counted, logged and signalled. The error tells why a card
+// was not done.
+func (s *Station) HandleContext(ctx context.Context, reader *hardware.Reader) error {
+	info := reader.CardInfo()
+	id := uid.Hex(info.UID)
+
+	s.mu.Lock()
+	card := &Card{Info: info, Serial: s.config.FirstSerial + s.counters.Done}
+	quarantined, done := s.quarantine[id], s.done[id]
+	s.mu.Unlock()
+
+	var err error
+	result := ResultDone
+	switch {
+	case quarantined:
+		result, err = ResultRejected, ErrQuarantined
+	case done:
+		result = ResultDuplicate
+	case !s.config.Profile.Supports(info):
+		result, err = ResultUnsupported, fmt.Errorf("profile %s does not support %s", s.config.Profile.Name(), info.Type)
+	default:
+		if err = s.config.Profile.Apply(ctx, reader, card); err != nil {
+			err = fmt.Errorf("failed to apply profile: %w", err)
+		} else if err = s.config.Profile.Verify(ctx, reader, card); err != nil {
+			err = fmt.Errorf("verification failed: %w", err)
+		}
+		if err != nil {
+			result = s.fail(id)
+		}
+	}
+	s.count(id, result)
+	if result == ResultQuarantined && s.config.QuarantinePath != "" {
+		if qErr := s.appendQuarantine(id); qErr != nil {
+			err = errors.Join(err, qErr)
+		}
+	}
+
+	if s.config.Log != nil {
+		metadata := map[string]string{"profile": s.config.Profile.Name(), "result": string(result)}
+		if result == ResultDone {
+			metadata["serial"] = strconv.Itoa(card.Serial)
+		}
+		if err != nil {
+			metadata["error"] = err.Error()
+		}
+		if logErr := s.config.Log.Log(reader, metadata); logErr != nil {
+			err = errors.Join(err, logErr)
+		}
+	}
+	if s.config.Feedback {
+		s.signal(reader, result)
+	}
+	return err
+}
+
+// fail counts a failed attempt and tells whether the card goes to quarantine
+func (s *Station) fail(id string) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[id]++
+	if s.attempts[id] >= s.config.MaxAttempts {
+		s.quarantine[id] = true
+		return ResultQuarantined
+	}
+	return ResultFailed
+}
+
+func (s *Station) count(id string, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch result {
+	case ResultDone:
+		s.counters.Done++
+		s.done[id] = true
+		delete(s.attempts, id)
+	case ResultFailed:
+		s.counters.Failed++
+	case ResultQuarantined:
+		s.counters.Quarantined++
+	case ResultRejected:
+		s.counters.Rejected++
+	case ResultUnsupported:
+		s.counters.Unsupported++
+	case ResultDuplicate:
+		s.counters.Duplicate++
+	}
+}
+
+func (s *Station) appendQuarantine(id string) error {
+	f, err := os.OpenFile(s.config.QuarantinePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open quarantine file: %v", err)
+	}
+	if _, err := fmt.Fprintf(f, "%s\n", id); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write quarantine file: %v", err)
+	}
+	return f.Close()
+}
+
+// signal shows the result on the reader; failures to do so are logged only
+func (s *Station) signal(reader *hardware.Reader, result Result) {
+	action := access.NewLEDAction(reader, access.LEDRed, 2, true)
+	switch result {
+	case ResultDone:
+		action = access.NewLEDAction(reader, access.LEDGreen, 1, true)
+	case ResultDuplicate:
+		action = access.NewLEDAction(reader, access.LEDOrange, 1, true)
+	}
+	if err := action.Execute(access.Decision{Time: time.Now()}); err != nil {
+		hardware.Logger().Warn("failed to signal result", "result", result, "error", err)
+	}
+}
@@ -0,0 +1,202 @@
+package batch_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/batch"
+	"github.com/oo-developer/acr122u/classic/simulator"
+	"github.com/oo-developer/acr122u/desfire/provision"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ndef"
+	"github.com/oo-developer/acr122u/scanlog"
+)
+
+// present connects a mock reader to a card
+func present(t *testing.T, card hardware.Transport, atr []byte) *hardware.Reader {
+	t.Helper()
+	reader := mock.NewReader(card, atr)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return reader
+}
+
+// classicCard returns a blank Classic 1K, with the keys of sector 1 changed
+// to an unknown key if locked
+func classicCard(t *testing.T, first byte, locked bool) *simulator.Simulator {
+	t.Helper()
+	blank, err := mock.NewClassic1K([]byte{first, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	memory := blank.Dump()
+	if locked {
+		copy(memory[1*64+48:], []byte{0x77, 0x77, 0x77, 0x77, 0x77, 0x77})
+		copy(memory[1*64+58:], []byte{0x77, 0x77, 0x77, 0x77, 0x77, 0x77})
+	}
+	sim, err := simulator.NewSimulator(memory)
+	if err != nil {
+		t.Fatalf("NewSimulator: %v", err)
+	}
+	return sim
+}
+
+func TestClassicStation(t *testing.T) {
+	dir := t.TempDir()
+	log, err := scanlog.Open(scanlog.Config{Path: filepath.Join(dir, "scans.jsonl"), Format: scanlog.FormatJSONL})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	keyA := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	keyB := []byte{0x09, 0x09, 0x09, 0x09, 0x09, 0x09}
+	profile := &batch.ClassicProfile{
+		Blocks:  map[int][]byte{4: bytes.Repeat([]byte{0xAB}, 16), 5: bytes.Repeat([]byte{0xCD}, 16)},
+		Sectors: map[int]batch.ClassicSector{1: {KeyA: keyA, KeyB: keyB}},
+	}
+	quarantine := filepath.Join(dir, "quarantine.txt")
+	station, err := batch.NewStation(batch.Config{
+		Profile:        profile,
+		FirstSerial:    100,
+		MaxAttempts:    2,
+		QuarantinePath: quarantine,
+		Log:            log,
+		Feedback:       true,
+	})
+	if err != nil {
+		t.Fatalf("NewStation: %v", err)
+	}
+
+	good := classicCard(t, 0x01, false)
+	reader := present(t, good, mock.Classic1KATR)
+	if err := station.Handle(reader); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	memory := good.Dump()
+	if !bytes.Equal(memory[4*16:5*16], profile.Blocks[4]) || !bytes.Equal(memory[5*16:6*16], profile.Blocks[5]) {
+		t.Fatalf("blocks not written: % X", memory[4*16:6*16])
+	}
+	if trailer := memory[7*16 : 8*16]; !bytes.Equal(trailer[:6], keyA) || !bytes.Equal(trailer[10:], keyB) {
+		t.Fatalf("sector 1 trailer % X", trailer)
+	}
+	// Presenting it again is a duplicate, not a second run
+	if err := station.Handle(reader); err != nil {
+		t.Fatalf("Handle of a card done: %v", err)
+	}
+
+	bad := present(t, classicCard(t, 0x07, true), mock.Classic1KATR)
+	for i := 0; i < 2; i++ {
+		if err := station.Handle(bad); err == nil {
+			t.Fatalf("attempt %d: Handle of a locked card", i+1)
+		}
+	}
+	if err := station.Handle(bad); !errors.Is(err, batch.ErrQuarantined) {
+		t.Fatalf("Handle of a quarantined card: %v", err)
+	}
+	want := batch.Counters{Done: 1, Duplicate: 1, Failed: 1, Quarantined: 1, Rejected: 1}
+	if c := station.Counters(); c != want {
+		t.Fatalf("counters %+v, want %+v", c, want)
+	}
+
+	// The quarantine outlives the station
+	restarted, err := batch.NewStation(batch.Config{Profile: profile, QuarantinePath: quarantine})
+	if err != nil {
+		t.Fatalf("NewStation: %v", err)
+	}
+	if err := restarted.Handle(bad); !errors.Is(err, batch.ErrQuarantined) {
+		t.Fatalf("Handle after restart: %v", err)
+	}
+
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	scans, err := os.ReadFile(filepath.Join(dir, "scans.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(scans), "\n"); lines != 5 || !strings.Contains(string(scans), `"serial":"100"`) {
+		t.Fatalf("scan log of %d lines:\n%s", lines, scans)
+	}
+}
+
+func TestNTAGStation(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	reader := present(t, card, mock.NTAGATR)
+	text, err := ndef.NewTextRecord("hello", "en")
+	if err != nil {
+		t.Fatalf("NewTextRecord: %v", err)
+	}
+	profile := &batch.NTAGProfile{
+		Message:  &ndef.Message{Records: []ndef.Record{text}},
+		Password: []byte{0x01, 0x02, 0x03, 0x04},
+		PACK:     []byte{0xAA, 0xBB},
+		Auth0:    4,
+	}
+	station, err := batch.NewStation(batch.Config{Profile: profile})
+	if err != nil {
+		t.Fatalf("NewStation: %v", err)
+	}
+	if err := station.Handle(reader); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	config := card.Dump()[0x83*4:]
+	if config[3] != 4 || !bytes.Equal(config[8:12], profile.Password) || !bytes.Equal(config[12:14], profile.PACK) {
+		t.Fatalf("configuration pages % X", config[:16])
+	}
+
+	// A new run opens the protected card with the password
+	station, err = batch.NewStation(batch.Config{Profile: profile})
+	if err != nil {
+		t.Fatalf("NewStation: %v", err)
+	}
+	if err := station.Handle(reader); err != nil {
+		t.Fatalf("Handle of a protected card: %v", err)
+	}
+
+	// Classic cards are not for this profile
+	if err := station.Handle(present(t, classicCard(t, 0x01, false), mock.Classic1KATR)); err == nil {
+		t.Fatalf("Handle of a Classic card")
+	}
+	if c := station.Counters(); c.Done != 1 || c.Unsupported != 1 {
+		t.Fatalf("counters %+v", c)
+	}
+}
+
+func TestDESFireStation(t *testing.T) {
+	card, err := mock.NewDESFire([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	if err != nil {
+		t.Fatalf("NewDESFire: %v", err)
+	}
+	profile, err := provision.ParseProfile([]byte(`
+picc_key_type: des
+picc_key: "0000000000000000"
+applications:
+  - aid: "010203"
+    key_settings: 0x0F
+    key_type: aes
+    keys:
+      - value: "00000000000000000000000000000011"
+`))
+	if err != nil {
+		t.Fatalf("ParseProfile: %v", err)
+	}
+	station, err := batch.NewStation(batch.Config{Profile: &batch.DESFireProfile{Profile: profile}})
+	if err != nil {
+		t.Fatalf("NewStation: %v", err)
+	}
+	reader := present(t, card, mock.DESFireATR)
+	if err := station.Handle(reader); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if c := station.Counters(); c.Done != 1 {
+		t.Fatalf("counters %+v", c)
+	}
+}
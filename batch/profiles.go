@@ -0,0 +1,287 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/desfire/provision"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
+	"github.com/oo-developer/acr122u/ndef"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// transportKey is key A of blank MIFARE Classic cards
+var transportKey = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// ClassicSector is the target trailer of a sector; nil keys are kept
+type ClassicSector struct {
+	KeyA   []byte
+	KeyB   []byte
+	Access []byte // access bits and general purpose byte, FF078069 if nil
+}
+
+// ClassicProfile writes data blocks and sector keys of MIFARE Classic cards
+// with 4-block sectors. Each sector is opened with key A, the target key
+// first and then TransportKey, so re-runs work on cards already keyed; the
+// target access conditions must let key A read and write the data blocks and
+// the trailer.
+type ClassicProfile struct {
+	Label        string
+	TransportKey []byte // key A of blank cards, FFFFFFFFFFFF if nil
+	Blocks       map[int][]byte
+	Sectors      map[int]ClassicSector
+}
+
+func (p *ClassicProfile) Name() string {
+	if p.Label == "" {
+		return "classic"
+	}
+	return p.Label
+}
+
+func (p *ClassicProfile) Supports(info *hardware.CardInfo) bool {
+	return classic.Supported(info)
+}
+
+func (p *ClassicProfile) validate() error {
+	for block, data := range p.Blocks {
+		if block <= 0 || block >= 128 || block%4 == 3 {
+			return fmt.Errorf("block %d is not a data block", block)
+		}
+		if len(data) != 16 {
+			return fmt.Errorf("block %d must be 16 bytes", block)
+		}
+	}
+	for sector := range p.Sectors {
+		if sector < 0 || sector >= 32 {
+			return fmt.Errorf("sector %d out of range", sector)
+		}
+	}
+	return nil
+}
+
+// open authenticates the sector with key A and returns the key that worked
+func (p *ClassicProfile) open(card *classic.Classic, sector int) ([]byte, error) {
+	candidates := [][]byte{p.Sectors[sector].KeyA, p.TransportKey}
+	if p.TransportKey == nil {
+		candidates[1] = transportKey
+	}
+	trailer := classic.GetSectorTrailerBlock(byte(sector))
+	for _, key := range candidates {
+		if key == nil {
+			continue
+		}
+		if err := card.LoadKey(0x00, key); err != nil {
+			return nil, err
+		}
+		if card.Authenticate(trailer, classic.KeyTypeA, 0x00) == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("sector %d: neither the target nor the transport key authenticates", sector)
+}
+
+func (p *ClassicProfile) Apply(ctx context.Context, reader *hardware.Reader, card *Card) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	c := classic.NewClassic(reader)
+	opened := -1
+	for _, block := range slices.Sorted(maps.Keys(p.Blocks)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if sector := block / 4; sector != opened {
+			if _, err := p.open(c, sector); err != nil {
+				return err
+			}
+			opened = sector
+		}
+		if err := c.WriteBlock(byte(block), p.Blocks[block]); err != nil {
+			return fmt.Errorf("block %d: %v", block, err)
+		}
+	}
+	for _, sector := range slices.Sorted(maps.Keys(p.Sectors)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key, err := p.open(c, sector)
+		if err != nil {
+			return err
+		}
+		target := p.Sectors[sector]
+		if err := c.ChangeKeys(byte(sector), target.KeyA, target.KeyB, target.Access, classic.KeyTypeA, key); err != nil {
+			return fmt.Errorf("sector %d: %v", sector, err)
+		}
+	}
+	return nil
+}
+
+func (p *ClassicProfile) Verify(ctx context.Context, reader *hardware.Reader, card *Card) error {
+	c := classic.NewClassic(reader)
+	for sector, target := range p.Sectors {
+		if target.KeyA == nil {
+			continue
+		}
+		if err := c.LoadKey(0x00, target.KeyA); err != nil {
+			return err
+		}
+		if err := c.Authenticate(classic.GetSectorTrailerBlock(byte(sector)), classic.KeyTypeA, 0x00); err != nil {
+			return fmt.Errorf("sector %d does not open with the target key A", sector)
+		}
+	}
+	opened := -1
+	for _, block := range slices.Sorted(maps.Keys(p.Blocks)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if sector := block / 4; sector != opened {
+			if _, err := p.open(c, sector); err != nil {
+				return err
+			}
+			opened = sector
+		}
+		data, err := c.ReadBlock(byte(block))
+		if err != nil {
+			return fmt.Errorf("block %d: %v", block, err)
+		}
+		if !bytes.Equal(data, p.Blocks[block]) {
+			return fmt.Errorf("block %d differs", block)
+		}
+	}
+	return nil
+}
+
+// NTAGProfile writes an NDEF message and sets the password of NTAG21x
+// cards; Ultralight cards take the message only. A card that is already
+// protected is opened with Password first.
+type NTAGProfile struct {
+	Label    string
+	Message  *ndef.Message // written if set
+	Password []byte        // set if not nil, with PACK, Auth0 and AuthLimit
+	PACK     []byte
+	Auth0    byte // first protected page
+	// AuthLimit is the number of failed password attempts before the card
+	// locks, 0 for no limit
+	AuthLimit byte
+}
+
+func (p *NTAGProfile) Name() string {
+	if p.Label == "" {
+		return "ntag"
+	}
+	return p.Label
+}
+
+func (p *NTAGProfile) Supports(info *hardware.CardInfo) bool {
+	return ndef.DetectTagType(info) == ndef.TagType2
+}
+
+func (p *NTAGProfile) Apply(ctx context.Context, reader *hardware.Reader, card *Card) error {
+	n := ntag.NewNTAG(reader)
+	if p.Password != nil {
+		// A failed PWD_AUTH halts the tag, so blank cards are not tried
+		protected, err := n.PasswordProtected()
+		if err != nil {
+			return err
+		}
+		if protected {
+			if _, err := n.Authenticate(p.Password); err != nil {
+				return fmt.Errorf("password not accepted: %v", err)
+			}
+		}
+	}
+	if p.Message != nil {
+		if err := ndef.WriteToTagContext(ctx, reader, p.Message, false, nil); err != nil {
+			return fmt.Errorf("failed to write NDEF: %w", err)
+		}
+	}
+	if p.Password != nil {
+		if err := n.SetPassword(p.Password, p.PACK, p.Auth0, p.AuthLimit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *NTAGProfile) Verify(ctx context.Context, reader *hardware.Reader, card *Card) error {
+	if p.Password != nil {
+		pack, err := ntag.NewNTAG(reader).Authenticate(p.Password)
+		if err != nil {
+			return fmt.Errorf("password not accepted: %v", err)
+		}
+		if !bytes.Equal(pack, p.PACK) {
+			return fmt.Errorf("PACK %X differs", pack)
+		}
+	}
+	if p.Message != nil {
+		want, err := p.Message.Encode()
+		if err != nil {
+			return err
+		}
+		msg, err := ndef.ReadFromTag(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read NDEF: %w", err)
+		}
+		got, err := msg.Encode()
+		if err != nil || !bytes.Equal(got, want) {
+			return ndef.ErrVerifyFailed
+		}
+	}
+	return nil
+}
+
+// DESFireProfile lays out the applications of DESFire cards with the
+// provision package
+type DESFireProfile struct {
+	Label   string
+	Profile *provision.Profile
+	// PICCCipher authenticates at PICC level instead of Profile.PICCKey, see
+	// provision.Provisioner.UsePICCCipher
+	PICCCipher keys.Cipher
+}
+
+func (p *DESFireProfile) Name() string {
+	if p.Label == "" {
+		return "desfire"
+	}
+	return p.Label
+}
+
+func (p *DESFireProfile) Supports(info *hardware.CardInfo) bool {
+	return strings.Contains(info.Type, "DESFire")
+}
+
+func (p *DESFireProfile) provisioner(reader *hardware.Reader) *provision.Provisioner {
+	provisioner := provision.NewProvisioner(desfire.NewDESFire(reader))
+	if p.PICCCipher != nil {
+		provisioner.UsePICCCipher(p.PICCCipher)
+	}
+	return provisioner
+}
+
+func (p *DESFireProfile) Apply(ctx context.Context, reader *hardware.Reader, card *Card) error {
+	_, err := p.provisioner(reader).ApplyContext(ctx, p.Profile, nil)
+	return err
+}
+
+// Verify applies the profile once more, which must find everything in place
+func (p *DESFireProfile) Verify(ctx context.Context, reader *hardware.Reader, card *Card) error {
+	report, err := p.provisioner(reader).ApplyContext(ctx, p.Profile, nil)
+	if err != nil {
+		return err
+	}
+	for _, step := range report.Steps {
+		if step.Action != provision.ActionUnchanged {
+			return fmt.Errorf("%s %s on second run", step.Target, step.Action)
+		}
+	}
+	return nil
+}
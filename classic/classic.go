@@ -1,10 +1,16 @@
 package classic
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
-	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/config"
 	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
 )
 
 const (
@@ -55,20 +61,69 @@ var DefaultKeys = map[string]struct {
 }
 
 type Classic struct {
-	ctx    *scard.Context
-	card   *scard.Card
+	card   hardware.Transport
 	reader string
+	loaded map[byte][]byte // keys loaded into the reader's key slots
 }
 
 // NewClassic initializes a new hardware
 func NewClassic(reader *hardware.Reader) *Classic {
 	return &Classic{
-		ctx:    reader.Ctx(),
-		card:   reader.Card(),
+		card:   reader.Transport(),
 		reader: reader.Reader(),
 	}
 }
 
+// NewClassicWithTransport creates a Classic handler on top of any transport,
+// e.g. a transcript replayer
+func NewClassicWithTransport(transport hardware.Transport) *Classic {
+	return &Classic{
+		card: transport,
+	}
+}
+
+// UseConfig adds the configured keys to DefaultKeys, so TryStandardKeys
+// probes them as well. Key references are resolved with the keys package.
+func UseConfig(cfg *config.Config) error {
+	resolver := keys.NewResolver(cfg)
+	for _, k := range cfg.Classic.Keys {
+		var keyA, keyB []byte
+		var err error
+		if k.KeyA != "" {
+			if keyA, err = resolver.Resolve(k.KeyA, 6); err != nil {
+				return fmt.Errorf("classic key %s: %v", k.Name, err)
+			}
+		}
+		if k.KeyB != "" {
+			if keyB, err = resolver.Resolve(k.KeyB, 6); err != nil {
+				return fmt.Errorf("classic key %s: %v", k.Name, err)
+			}
+		}
+		DefaultKeys[k.Name] = struct {
+			KeyA  []byte
+			KeyB  []byte
+			Usage string
+		}{KeyA: keyA, KeyB: keyB, Usage: "Configured"}
+	}
+	return nil
+}
+
+// Supported reports whether the card speaks CRYPTO1: MIFARE Classic and Mini
+// cards, and MIFARE Plus cards at security level 1, which the reader handles
+// exactly like MIFARE Classic
+func Supported(info *hardware.CardInfo) bool {
+	if info.SecurityLevel != "" {
+		return info.SecurityLevel == "SL1"
+	}
+	for _, name := range []string{hardware.MIFARE_CLASSIK_1K, hardware.MIFARE_CLASSIK_4K, hardware.MIFARE_MINI,
+		hardware.MIFARE_PLUS_SE_2K, hardware.MIFARE_PLUS_SE_4K} {
+		if strings.HasPrefix(info.Type, name) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Classic) getVersion() []byte {
 	// GET_VERSION command for NTAG/Ultralight EV1
 	cmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x02, 0x60, 0x00}
@@ -89,23 +144,34 @@ func (m *Classic) getVersion() []byte {
 	return nil
 }
 
+// LoadKey loads a key into one of the reader's volatile key slots. Loading
+// the key the slot already holds through this handler sends nothing, so
+// sector loops authenticating with the same key cost one APDU per sector.
 func (m *Classic) LoadKey(keyNumber byte, key []byte) error {
 	if len(key) != 6 {
 		return fmt.Errorf("key must be 6 bytes")
 	}
+	if loaded, ok := m.loaded[keyNumber]; ok && bytes.Equal(loaded, key) {
+		return nil
+	}
+	delete(m.loaded, keyNumber)
 
 	cmd := []byte{0xFF, 0x82, 0x00, keyNumber, 0x06}
 	cmd = append(cmd, key...)
 
 	rsp, err := m.card.Transmit(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to load key: %v", err)
+		return fmt.Errorf("failed to load key: %w", err)
 	}
 
 	if len(rsp) != 2 || rsp[0] != 0x90 || rsp[1] != 0x00 {
-		return fmt.Errorf("key load failed: %v", rsp)
+		return fmt.Errorf("key load failed: %w", hardware.NewStatusError(cmd, rsp))
 	}
 
+	if m.loaded == nil {
+		m.loaded = make(map[byte][]byte)
+	}
+	m.loaded[keyNumber] = append([]byte{}, key...)
 	return nil
 }
 
@@ -114,11 +180,11 @@ func (m *Classic) Authenticate(block byte, keyType byte, keyNumber byte) error {
 
 	rsp, err := m.card.Transmit(cmd)
 	if err != nil {
-		return fmt.Errorf("authentication failed: %v", err)
+		return fmt.Errorf("authentication failed: %w", err)
 	}
 
 	if len(rsp) != 2 || rsp[0] != 0x90 || rsp[1] != 0x00 {
-		return fmt.Errorf("authentication error: %v", rsp)
+		return fmt.Errorf("authentication error: %w", hardware.NewStatusError(cmd, rsp))
 	}
 
 	return nil
@@ -130,15 +196,15 @@ func (m *Classic) ReadBlock(block byte) ([]byte, error) {
 
 	rsp, err := m.card.Transmit(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("read failed: %v", err)
+		return nil, fmt.Errorf("read failed: %w", err)
 	}
 
 	if len(rsp) < 2 {
-		return nil, fmt.Errorf("invalid response length")
+		return nil, fmt.Errorf("invalid response length: %w", hardware.ErrWrongLength)
 	}
 
 	if rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
-		return nil, fmt.Errorf("read error: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+		return nil, fmt.Errorf("read error: %w", hardware.NewStatusError(cmd, rsp))
 	}
 
 	return rsp[:len(rsp)-2], nil
@@ -155,11 +221,11 @@ func (m *Classic) WriteBlock(block byte, data []byte) error {
 
 	rsp, err := m.card.Transmit(cmd)
 	if err != nil {
-		return fmt.Errorf("write failed: %v", err)
+		return fmt.Errorf("write failed: %w", err)
 	}
 
 	if len(rsp) != 2 || rsp[0] != 0x90 || rsp[1] != 0x00 {
-		return fmt.Errorf("write error: %v", rsp)
+		return fmt.Errorf("write error: %w", hardware.NewStatusError(cmd, rsp))
 	}
 
 	return nil
@@ -189,18 +255,18 @@ func (m *Classic) ChangeKeys(sector byte, newKeyA []byte, newKeyB []byte, access
 
 	// Load the current key
 	if err := m.LoadKey(0x00, currentKey); err != nil {
-		return fmt.Errorf("failed to load current key: %v", err)
+		return fmt.Errorf("failed to load current key: %w", err)
 	}
 
 	// Authenticate with current key
 	if err := m.Authenticate(trailerBlock, currentKeyType, 0x00); err != nil {
-		return fmt.Errorf("authentication failed: %v", err)
+		return fmt.Errorf("authentication failed: %w", err)
 	}
 
 	// Read current sector trailer to preserve values we're not changing
 	currentTrailer, err := m.ReadBlock(trailerBlock)
 	if err != nil {
-		return fmt.Errorf("failed to read sector trailer: %v", err)
+		return fmt.Errorf("failed to read sector trailer: %w", err)
 	}
 
 	// Build new sector trailer
@@ -234,7 +300,7 @@ func (m *Classic) ChangeKeys(sector byte, newKeyA []byte, newKeyB []byte, access
 
 	// Write the new sector trailer
 	if err := m.WriteBlock(trailerBlock, newTrailer); err != nil {
-		return fmt.Errorf("failed to write new keys: %v", err)
+		return fmt.Errorf("failed to write new keys: %w", err)
 	}
 
 	return nil
@@ -245,22 +311,42 @@ func GetSectorTrailerBlock(sector byte) byte {
 	return sector*4 + 3
 }
 
+// TryStandardKeys returns the name of the first default key, in name order,
+// that authenticates the block
 func (m *Classic) TryStandardKeys(blockNum byte, keyType int) string {
-	for name, keys := range DefaultKeys {
-		fmt.Sprintf("     Probing %s\n", name)
+	name, _ := m.TryStandardKeysContext(context.Background(), blockNum, keyType, nil)
+	return name
+}
+
+// TryStandardKeysContext probes the keys like TryStandardKeys, reporting the
+// keys tried to progress (which may be nil). It returns an empty name if no
+// key matched, and an error only if the probing could not be completed.
+func (m *Classic) TryStandardKeysContext(ctx context.Context, blockNum byte, keyType int, progress hardware.Progress) (string, error) {
+	t := hardware.NewTracker(ctx, progress)
+	t.Begin(len(DefaultKeys))
+	for _, name := range slices.Sorted(maps.Keys(DefaultKeys)) {
+		if err := t.Next(); err != nil {
+			return "", err
+		}
+		keys := DefaultKeys[name]
 		key := keys.KeyA
 		if KeyTypeB == keyType {
 			key = keys.KeyB
 		}
+		if key == nil {
+			// Configured keys may have only one of A and B
+			t.Advance(1)
+			continue
+		}
 		err := m.LoadKey(0x00, key)
 		if err != nil {
-			return ""
+			return "", err
 		}
-		err = m.Authenticate(blockNum, KeyTypeA, 0x00)
+		err = m.Authenticate(blockNum, byte(keyType), 0x00)
 		if err == nil {
-			return name
+			return name, nil
 		}
-
+		t.Advance(1)
 	}
-	return ""
+	return "", nil
 }
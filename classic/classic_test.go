@@ -0,0 +1,187 @@
+package classic_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/classic/simulator"
+	"github.com/oo-developer/acr122u/config"
+	"github.com/oo-developer/acr122u/dump"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
+	"github.com/zalando/go-keyring"
+)
+
+// counter counts the APDUs sent to a transport
+type counter struct {
+	transport hardware.Transport
+	apdus     int
+}
+
+func (c *counter) Transmit(cmd []byte) ([]byte, error) {
+	c.apdus++
+	return c.transport.Transmit(cmd)
+}
+
+var transportKey = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// newClassic1K returns a blank MIFARE Classic 1K with all sectors in
+// transport configuration
+func newClassic1K(uid []byte) (*simulator.Simulator, error) {
+	memory := make([]byte, 1024)
+	copy(memory, uid)
+	memory[4] = uid[0] ^ uid[1] ^ uid[2] ^ uid[3]
+	copy(memory[5:8], []byte{0x08, 0x04, 0x00})
+	for sector := 0; sector < 16; sector++ {
+		copy(memory[sector*64+48:], []byte{
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFF, 0x07, 0x80, 0x69,
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		})
+	}
+	return simulator.NewSimulator(memory)
+}
+
+// newCard returns a client on a blank MIFARE Classic 1K and its APDU counter
+func newCard(b *testing.B) (*classic.Classic, *counter) {
+	b.Helper()
+	sim, err := newClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		b.Fatalf("NewClassic1K: %v", err)
+	}
+	card := &counter{transport: sim}
+	return classic.NewClassicWithTransport(card), card
+}
+
+func BenchmarkReadBlock(b *testing.B) {
+	c, card := newCard(b)
+	if err := c.LoadKey(0, transportKey); err != nil {
+		b.Fatalf("LoadKey: %v", err)
+	}
+	if err := c.Authenticate(4, 0x60, 0); err != nil {
+		b.Fatalf("Authenticate: %v", err)
+	}
+	card.apdus = 0
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := c.ReadBlock(4); err != nil {
+			b.Fatalf("ReadBlock: %v", err)
+		}
+	}
+	b.ReportMetric(float64(card.apdus)/float64(b.N), "apdus/op")
+}
+
+func BenchmarkLoadKey(b *testing.B) {
+	keys := [][]byte{transportKey, {0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}}
+	for _, bc := range []struct {
+		name string
+		keys [][]byte
+	}{
+		{"same", keys[:1]},       // the slot holds the key already
+		{"alternating", keys[:]}, // every load reaches the reader
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c, card := newCard(b)
+			b.ReportAllocs()
+			i := 0
+			for b.Loop() {
+				if err := c.LoadKey(0, bc.keys[i%len(bc.keys)]); err != nil {
+					b.Fatalf("LoadKey: %v", err)
+				}
+				i++
+			}
+			b.ReportMetric(float64(card.apdus)/float64(b.N), "apdus/op")
+		})
+	}
+}
+
+func TestDumpImage(t *testing.T) {
+	blank, err := newClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	memory := blank.Dump()
+	copy(memory[16:], "hello world 1234")
+	// Sector 1 opens with no default key, sector 2 with the HID key A
+	copy(memory[1*64+48:], []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0xFF, 0x07, 0x80, 0x69, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	copy(memory[2*64+48:], []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5})
+	sim, err := simulator.NewSimulator(memory)
+	if err != nil {
+		t.Fatalf("NewSimulator: %v", err)
+	}
+
+	img, err := classic.NewClassicWithTransport(sim).DumpImage(16)
+	if err != nil {
+		t.Fatalf("DumpImage: %v", err)
+	}
+	if img.Type != hardware.MIFARE_CLASSIK_1K || !bytes.Equal(img.UID, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Fatalf("dumped %s with UID % X", img.Type, img.UID)
+	}
+	want := append([]byte{}, memory...)
+	clear(want[1*64 : 2*64])
+	if !bytes.Equal(img.Data, want) {
+		t.Fatalf("dumped\n% X\nwant\n% X", img.Data, want)
+	}
+	if len(img.Regions) != 16 || img.Complete() {
+		t.Fatalf("regions %v", img.Regions)
+	}
+	for i, r := range img.Regions {
+		status := dump.StatusOK
+		if i == 1 {
+			status = dump.StatusUnreadable
+		}
+		if r.Status != status || r.Offset != i*64 || r.Length != 64 {
+			t.Errorf("region %d: %+v", i, r)
+		}
+	}
+	if len(img.Keys) != 15 || !bytes.Equal(img.Keys["sector 2 key A"], []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}) {
+		t.Fatalf("keys %X", img.Keys)
+	}
+	if _, ok := img.Keys["sector 1 key A"]; ok {
+		t.Fatalf("key recorded for the unreadable sector")
+	}
+
+	var buf bytes.Buffer
+	if err := dump.Encode(&buf, img, dump.Container); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	back, err := dump.Decode(&buf, dump.Container)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(back.Data, img.Data) || len(back.Regions) != 16 || back.Regions[1] != img.Regions[1] ||
+		len(back.Keys) != 15 || !back.Captured.Equal(img.Captured.Truncate(0)) {
+		t.Fatalf("decoded %+v", back)
+	}
+}
+
+func TestUseConfig(t *testing.T) {
+	keyring.MockInit()
+	if err := keys.NewKeyring(keys.DefaultKeyringService).Store("office-b", []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	cfg, err := config.ParseYAML([]byte(`
+classic:
+  keys:
+    - name: office
+      key_a: A0A1A2A3A4A5
+      key_b: keyring:office-b
+`))
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	t.Cleanup(func() { delete(classic.DefaultKeys, "office") })
+	if err := classic.UseConfig(cfg); err != nil {
+		t.Fatalf("UseConfig: %v", err)
+	}
+	if k := classic.DefaultKeys["office"]; !bytes.Equal(k.KeyA, []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}) ||
+		!bytes.Equal(k.KeyB, []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5}) {
+		t.Fatalf("office keys % X, % X", k.KeyA, k.KeyB)
+	}
+
+	cfg.Classic.Keys[0].KeyB = "keyring:office-c"
+	if err := classic.UseConfig(cfg); err == nil {
+		t.Fatalf("UseConfig resolved a missing keyring secret")
+	}
+}
@@ -0,0 +1,132 @@
+package classic
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oo-developer/acr122u/dump"
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// cardTypes names the Classic cards by sector count
+var cardTypes = map[int]string{
+	5:  hardware.MIFARE_MINI,
+	16: hardware.MIFARE_CLASSIK_1K,
+	32: "MIFARE Classic 2K",
+	40: hardware.MIFARE_CLASSIK_4K,
+}
+
+// sectorBlocks returns the first block and block count of a sector; 4K cards
+// have 32 sectors of 4 blocks and 8 sectors of 16 blocks
+func sectorBlocks(sector int) (int, int) {
+	if sector < 32 {
+		return sector * 4, 4
+	}
+	return 128 + (sector-32)*16, 16
+}
+
+// DumpImage reads a card of the given number of sectors (5 for Mini, 16 for
+// 1K, 32 for 2K, 40 for 4K) into an image of the dump package, with the keys
+// of DefaultKeys
+func (m *Classic) DumpImage(sectors int) (*dump.Image, error) {
+	return m.DumpImageContext(context.Background(), sectors, nil)
+}
+
+// DumpImageContext reads the image like DumpImage, reporting the bytes done
+// to progress (which may be nil). Each sector is a region of the image:
+// sectors no default key opens, or with blocks the key may not read, are
+// marked unreadable with zeros for the missing blocks. The keys found are
+// recorded in the image and filled into the trailers, where the card reads
+// them as zeros.
+func (m *Classic) DumpImageContext(ctx context.Context, sectors int, progress hardware.Progress) (*dump.Image, error) {
+	cardType, ok := cardTypes[sectors]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sector count %d", sectors)
+	}
+	first, count := sectorBlocks(sectors - 1)
+	size := (first + count) * 16
+	img := &dump.Image{
+		Type:     cardType,
+		Data:     make([]byte, size),
+		Captured: time.Now(),
+		Keys:     make(map[string][]byte),
+	}
+	t := hardware.NewTracker(ctx, progress)
+	t.Begin(size)
+	for sector := 0; sector < sectors; sector++ {
+		if err := t.Next(); err != nil {
+			return nil, err
+		}
+		first, count := sectorBlocks(sector)
+		status, err := m.dumpSector(ctx, img, sector, first, count)
+		if err != nil {
+			return nil, err
+		}
+		img.Regions = append(img.Regions, dump.Region{
+			Name:   fmt.Sprintf("sector %d", sector),
+			Offset: first * 16,
+			Length: count * 16,
+			Status: status,
+		})
+		t.Advance(count * 16)
+	}
+	if img.Regions[0].Status == dump.StatusUnreadable {
+		return nil, fmt.Errorf("failed to read the manufacturer block")
+	}
+	img.UID = append([]byte{}, img.Data[0:4]...)
+	return img, nil
+}
+
+// dumpSector reads the blocks of a sector into the image
+func (m *Classic) dumpSector(ctx context.Context, img *dump.Image, sector int, first int, count int) (dump.Status, error) {
+	trailer := first + count - 1
+	keyType, key, err := m.findKey(ctx, byte(trailer))
+	if err != nil || key == nil {
+		return dump.StatusUnreadable, err
+	}
+	keyName := fmt.Sprintf("sector %d key A", sector)
+	if keyType == KeyTypeB {
+		keyName = fmt.Sprintf("sector %d key B", sector)
+	}
+	img.Keys[keyName] = key
+
+	status := dump.StatusOK
+	for block := first; block < first+count; block++ {
+		data, err := m.ReadBlock(byte(block))
+		if err != nil || len(data) != 16 {
+			// A refused read ends the authentication
+			status = dump.StatusUnreadable
+			if m.Authenticate(byte(trailer), keyType, 0x00) != nil {
+				break
+			}
+			continue
+		}
+		copy(img.Data[block*16:], data)
+	}
+	if keyType == KeyTypeA {
+		copy(img.Data[trailer*16:], key)
+	} else {
+		copy(img.Data[trailer*16+10:], key)
+	}
+	return status, nil
+}
+
+// findKey returns the first default key, A before B, that authenticates the
+// block, nil if none does
+func (m *Classic) findKey(ctx context.Context, block byte) (byte, []byte, error) {
+	for _, keyType := range []byte{KeyTypeA, KeyTypeB} {
+		name, err := m.TryStandardKeysContext(ctx, block, int(keyType), nil)
+		if err != nil {
+			return 0, nil, err
+		}
+		if name == "" {
+			continue
+		}
+		if keyType == KeyTypeA {
+			return keyType, DefaultKeys[name].KeyA, nil
+		}
+		return keyType, DefaultKeys[name].KeyB, nil
+	}
+	return 0, nil, nil
+}
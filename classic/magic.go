@@ -0,0 +1,111 @@
+package classic
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// PN532 registers the gen1a probe changes
+const (
+	regTxMode     = 0x6302
+	regRxMode     = 0x6303
+	regBitFraming = 0x633D
+)
+
+// IsGen1A reports whether the card is a "gen1a" magic card, which answers
+// the backdoor commands 40 (7 bits) and 43 with an ACK and then accepts
+// reads and writes of any block without authentication. Genuine cards stay
+// silent. The probe halts the card, so it must be selected again, e.g. by
+// reconnecting, before further commands.
+func (m *Classic) IsGen1A() (bool, error) {
+	// Raw frames: no CRC, so HLTA carries its own
+	if err := m.writeRegisters(regTxMode, 0x00, regRxMode, 0x00); err != nil {
+		return false, err
+	}
+	defer m.writeRegisters(regTxMode, 0x80, regRxMode, 0x80, regBitFraming, 0x00)
+
+	m.communicateThru([]byte{0x50, 0x00, 0x57, 0xCD})
+
+	if err := m.writeRegisters(regBitFraming, 0x07); err != nil {
+		return false, err
+	}
+	rsp, err := m.communicateThru([]byte{0x40})
+	if err != nil || !bytes.Equal(rsp, []byte{0x0A}) {
+		return false, nil
+	}
+	if err := m.writeRegisters(regBitFraming, 0x00); err != nil {
+		return false, err
+	}
+	rsp, err = m.communicateThru([]byte{0x43})
+	return err == nil && bytes.Equal(rsp, []byte{0x0A}), nil
+}
+
+// BlockZeroWritable reports whether the manufacturer block takes a regular
+// write, as on "gen2" (CUID) magic cards. The block is written back
+// unchanged after authenticating sector 0 with key A; genuine cards refuse
+// the write.
+func (m *Classic) BlockZeroWritable(keyA []byte) (bool, error) {
+	if err := m.LoadKey(0x00, keyA); err != nil {
+		return false, err
+	}
+	if err := m.Authenticate(0, KeyTypeA, 0x00); err != nil {
+		return false, err
+	}
+	block0, err := m.ReadBlock(0)
+	if err != nil {
+		return false, err
+	}
+	if m.WriteBlock(0, block0) != nil {
+		// The refused write ended the authentication
+		m.Authenticate(0, KeyTypeA, 0x00)
+		return false, nil
+	}
+	return true, nil
+}
+
+// writeRegisters writes PN532 registers, given as address and value pairs
+func (m *Classic) writeRegisters(pairs ...int) error {
+	payload := []byte{0xD4, 0x08}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		payload = append(payload, byte(pairs[i]>>8), byte(pairs[i]), byte(pairs[i+1]))
+	}
+	rsp, err := m.pn532(payload)
+	if err != nil {
+		return fmt.Errorf("failed to write PN532 registers: %w", err)
+	}
+	if len(rsp) < 2 || rsp[0] != 0xD5 || rsp[1] != 0x09 {
+		return fmt.Errorf("failed to write PN532 registers: % X", rsp)
+	}
+	return nil
+}
+
+// communicateThru sends raw bytes to the card with PN532 InCommunicateThru
+// and returns its answer
+func (m *Classic) communicateThru(data []byte) ([]byte, error) {
+	rsp, err := m.pn532(append([]byte{0xD4, 0x42}, data...))
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 3 || rsp[0] != 0xD5 || rsp[1] != 0x43 {
+		return nil, fmt.Errorf("invalid PN532 response % X", rsp)
+	}
+	if rsp[2] != 0x00 {
+		return nil, hardware.PN532Error(rsp[2])
+	}
+	return rsp[3:], nil
+}
+
+// pn532 sends a PN532 command with the reader's direct transmit
+func (m *Classic) pn532(payload []byte) ([]byte, error) {
+	cmd := append([]byte{0xFF, 0x00, 0x00, 0x00, byte(len(payload))}, payload...)
+	rsp, err := m.card.Transmit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+		return nil, fmt.Errorf("direct transmit failed: %w", hardware.NewStatusError(cmd, rsp))
+	}
+	return rsp[:len(rsp)-2], nil
+}
@@ -0,0 +1,332 @@
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/dump"
+)
+
+var (
+	swSuccess = []byte{0x90, 0x00}
+	swFailed  = []byte{0x63, 0x00}
+)
+
+// Simulator is a MIFARE Classic card loaded from a dump. It answers the
+// ACR122U pseudo-APDUs for loading keys, authentication, and reading and
+// writing blocks, and can be used as transport for
+// classic.NewClassicWithTransport.
+//
+// Access conditions of the sector trailers are enforced as on the card: key A
+// never reads back, key B only where the trailer allows it, and a key B that
+// is readable cannot be used for data access. Dumps read from cards usually
+// hold zeros for unreadable keys, so fill in the keys before simulating. The
+// manufacturer block is read-only, and trailer writes whose access bits are
+// inconsistent are refused, where a real card would lock the sector forever.
+// SetMagic turns the card into a magic card for clone detection.
+type Simulator struct {
+	blocks [][]byte
+	keys   [2][]byte // volatile key slots of the reader
+
+	// Authentication state, authSector is -1 when not authenticated
+	authSector int
+	authKeyB   bool
+
+	magic      Magic
+	bitFraming byte // PN532 bit framing register, for the gen1a backdoor
+	backdoor   bool // 40 was acknowledged
+}
+
+// Magic is the kind of magic card a simulator plays
+type Magic int
+
+const (
+	MagicNone Magic = iota
+	// MagicGen1A acknowledges the backdoor commands 40 (7 bits) and 43;
+	// the unlocked reads and writes are not simulated
+	MagicGen1A
+	// MagicGen2 takes regular writes of the manufacturer block
+	MagicGen2
+)
+
+// SetMagic makes the card a magic card of the kind
+func (s *Simulator) SetMagic(magic Magic) {
+	s.magic = magic
+}
+
+// NewSimulator creates a card from a dump of all blocks: 320 bytes for a
+// MIFARE Mini, 1024 for 1K, 2048 for 2K and 4096 for 4K
+func NewSimulator(dump []byte) (*Simulator, error) {
+	switch len(dump) {
+	case 320, 1024, 2048, 4096:
+	default:
+		return nil, fmt.Errorf("unsupported dump size %d", len(dump))
+	}
+	s := &Simulator{authSector: -1}
+	for i := 0; i < len(dump); i += 16 {
+		s.blocks = append(s.blocks, append([]byte{}, dump[i:i+16]...))
+	}
+	return s, nil
+}
+
+// LoadSimulator creates a card from a dump file in any format of the dump
+// package, chosen by extension
+func LoadSimulator(path string) (*Simulator, error) {
+	img, err := dump.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSimulator(img.Data)
+}
+
+// UID returns the 4-byte UID of the manufacturer block
+func (s *Simulator) UID() []byte {
+	return append([]byte{}, s.blocks[0][0:4]...)
+}
+
+// Dump returns the current memory, including writes
+func (s *Simulator) Dump() []byte {
+	var dump []byte
+	for _, b := range s.blocks {
+		dump = append(dump, b...)
+	}
+	return dump
+}
+
+// Remove simulates taking the card off the reader, which ends the
+// authentication
+func (s *Simulator) Remove() {
+	s.authSector = -1
+}
+
+func (s *Simulator) Transmit(apdu []byte) ([]byte, error) {
+	if len(apdu) < 5 || apdu[0] != 0xFF {
+		return []byte{0x6E, 0x00}, nil
+	}
+	switch apdu[1] {
+	case 0xCA:
+		return append(s.UID(), swSuccess...), nil
+	case 0x82:
+		// Load Keys: FF 82 00 slot 06 key
+		if len(apdu) != 11 || apdu[4] != 6 || apdu[3] > 1 {
+			return swFailed, nil
+		}
+		s.keys[apdu[3]] = append([]byte{}, apdu[5:11]...)
+		return swSuccess, nil
+	case 0x86:
+		// General Authenticate: FF 86 00 00 05 01 00 block type slot
+		if len(apdu) != 10 || apdu[4] != 5 || apdu[5] != 0x01 {
+			return swFailed, nil
+		}
+		return s.authenticate(int(apdu[7]), apdu[8], apdu[9]), nil
+	case 0xB0:
+		return s.read(int(apdu[3]), int(apdu[4])), nil
+	case 0xD6:
+		if len(apdu) != 21 || apdu[4] != 16 {
+			return swFailed, nil
+		}
+		return s.write(int(apdu[3]), apdu[5:21]), nil
+	case 0x00:
+		// Direct transmit: PN532 commands, or e.g. GET_VERSION, which Classic
+		// cards do not know
+		if len(apdu) > 6 && apdu[5] == 0xD4 {
+			return s.pn532(apdu[5:]), nil
+		}
+		return swFailed, nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func (s *Simulator) authenticate(block int, keyType byte, slot byte) []byte {
+	s.authSector = -1
+	if block >= len(s.blocks) || slot > 1 || s.keys[slot] == nil {
+		return swFailed
+	}
+	trailer := s.blocks[trailerBlock(sector(block))]
+	var key []byte
+	switch keyType {
+	case classic.KeyTypeA:
+		key = trailer[0:6]
+	case classic.KeyTypeB:
+		key = trailer[10:16]
+	default:
+		return swFailed
+	}
+	if !bytes.Equal(key, s.keys[slot]) {
+		return swFailed
+	}
+	s.authSector = sector(block)
+	s.authKeyB = keyType == classic.KeyTypeB
+	return swSuccess
+}
+
+func (s *Simulator) read(block int, length int) []byte {
+	if length != 16 || !s.authenticatedFor(block) {
+		return swFailed
+	}
+	sec := sector(block)
+	c := s.conditions(sec, group(block))
+	if block != trailerBlock(sec) {
+		if !s.allowed(sec, dataRead[c]) {
+			return s.fail()
+		}
+		return append(append([]byte{}, s.blocks[block]...), swSuccess...)
+	}
+	// Key A never reads back, access bits and key B depending on the
+	// trailer's own conditions
+	trailer := s.blocks[block]
+	out := make([]byte, 16)
+	if s.allowed(sec, trailerAccessRead[c]) {
+		copy(out[6:10], trailer[6:10])
+	}
+	if s.allowed(sec, trailerKeyBRead[c]) {
+		copy(out[10:16], trailer[10:16])
+	}
+	return append(out, swSuccess...)
+}
+
+func (s *Simulator) write(block int, data []byte) []byte {
+	if block == 0 && s.magic != MagicGen2 || !s.authenticatedFor(block) {
+		return s.fail()
+	}
+	sec := sector(block)
+	c := s.conditions(sec, group(block))
+	if block != trailerBlock(sec) {
+		if !s.allowed(sec, dataWrite[c]) {
+			return s.fail()
+		}
+		copy(s.blocks[block], data)
+		return swSuccess
+	}
+	trailer := s.blocks[block]
+	keyA := s.allowed(sec, trailerKeyAWrite[c])
+	access := s.allowed(sec, trailerAccessWrite[c])
+	keyB := s.allowed(sec, trailerKeyBWrite[c])
+	if !keyA && !access && !keyB || access && !validAccessBits(data[6:9]) {
+		return s.fail()
+	}
+	if keyA {
+		copy(trailer[0:6], data[0:6])
+	}
+	if access {
+		copy(trailer[6:10], data[6:10])
+	}
+	if keyB {
+		copy(trailer[10:16], data[10:16])
+	}
+	return swSuccess
+}
+
+// pn532 answers WriteRegister and the raw frames of InCommunicateThru that
+// probe for the gen1a backdoor; other raw frames time out
+func (s *Simulator) pn532(cmd []byte) []byte {
+	timeout := []byte{0xD5, 0x43, 0x01, 0x90, 0x00}
+	switch {
+	case cmd[1] == 0x08:
+		for i := 2; i+2 < len(cmd); i += 3 {
+			if cmd[i] == 0x63 && cmd[i+1] == 0x3D {
+				s.bitFraming = cmd[i+2] & 0x07
+			}
+		}
+		return []byte{0xD5, 0x09, 0x90, 0x00}
+	case cmd[1] == 0x42 && len(cmd) == 3 && s.magic == MagicGen1A:
+		switch {
+		case cmd[2] == 0x40 && s.bitFraming == 7:
+			s.backdoor = true
+			return []byte{0xD5, 0x43, 0x00, 0x0A, 0x90, 0x00}
+		case cmd[2] == 0x43 && s.backdoor && s.bitFraming == 0:
+			return []byte{0xD5, 0x43, 0x00, 0x0A, 0x90, 0x00}
+		}
+	case cmd[1] == 0x42 && len(cmd) > 2 && cmd[2] == 0x50:
+		// HLTA: the card halts silently
+		s.authSector = -1
+		s.backdoor = false
+	}
+	return timeout
+}
+
+// fail ends the authentication like the card does after a refused command
+func (s *Simulator) fail() []byte {
+	s.authSector = -1
+	return swFailed
+}
+
+func (s *Simulator) authenticatedFor(block int) bool {
+	return block < len(s.blocks) && s.authSector == sector(block)
+}
+
+// Permissions by access condition (C1 C2 C3 as a number), as keys allowed
+const (
+	never = 0
+	keyA  = 1
+	keyB  = 2
+	keyAB = keyA | keyB
+)
+
+var (
+	dataRead  = [8]int{keyAB, keyAB, keyAB, keyB, keyAB, keyB, keyAB, never}
+	dataWrite = [8]int{keyAB, never, never, keyB, keyB, never, keyB, never}
+
+	trailerKeyAWrite   = [8]int{keyA, keyA, never, keyB, keyB, never, never, never}
+	trailerAccessRead  = [8]int{keyA, keyA, keyA, keyAB, keyAB, keyAB, keyAB, keyAB}
+	trailerAccessWrite = [8]int{never, keyA, never, keyB, never, keyB, never, never}
+	trailerKeyBRead    = [8]int{keyA, keyA, keyA, never, never, never, never, never}
+	trailerKeyBWrite   = [8]int{keyA, keyA, never, keyB, keyB, never, never, never}
+)
+
+// allowed reports whether the key of the authentication has the permission.
+// Where key B is readable it is data and grants nothing.
+func (s *Simulator) allowed(sec int, permission int) bool {
+	if s.authKeyB {
+		if trailerKeyBRead[s.conditions(sec, 3)] != never {
+			return false
+		}
+		return permission&keyB != 0
+	}
+	return permission&keyA != 0
+}
+
+// conditions returns C1 C2 C3 of a block group (0 to 2 for data, 3 for the
+// trailer) as a number
+func (s *Simulator) conditions(sec int, g int) int {
+	bits := s.blocks[trailerBlock(sec)][6:9]
+	c1 := int(bits[1]>>(4+g)) & 1
+	c2 := int(bits[2]>>g) & 1
+	c3 := int(bits[2]>>(4+g)) & 1
+	return c1<<2 | c2<<1 | c3
+}
+
+// validAccessBits checks the inverted copies of the access bits
+func validAccessBits(bits []byte) bool {
+	return bits[0]&0x0F == ^bits[1]>>4&0x0F && bits[0]>>4 == ^bits[2]&0x0F && bits[1]&0x0F == ^bits[2]>>4&0x0F
+}
+
+// sector returns the sector of a block; 4K cards have 32 sectors of 4 blocks
+// and 8 sectors of 16 blocks
+func sector(block int) int {
+	if block < 128 {
+		return block / 4
+	}
+	return 32 + (block-128)/16
+}
+
+func trailerBlock(sector int) int {
+	if sector < 32 {
+		return sector*4 + 3
+	}
+	return 128 + (sector-32)*16 + 15
+}
+
+// group returns the access condition group of a block; the data blocks of
+// large sectors share conditions in groups of five
+func group(block int) int {
+	sec := sector(block)
+	if block == trailerBlock(sec) {
+		return 3
+	}
+	if sec < 32 {
+		return block % 4
+	}
+	return (block - 128 - (sec-32)*16) / 5
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/oo-developer/acr122u/batch"
+	"github.com/oo-developer/acr122u/config"
+	"github.com/oo-developer/acr122u/daemon"
+	"github.com/oo-developer/acr122u/desfire/provision"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
+	"github.com/oo-developer/acr122u/personalize"
+	"github.com/oo-developer/acr122u/scanlog"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+func main() {
+	configPath := flag.String("config", "", "YAML or TOML config file")
+	reader := flag.String("reader", "", "use the first reader whose name matches this regular expression")
+	profilePath := flag.String("desfire-profile", "", "DESFire provisioning profile (YAML)")
+	templatePath := flag.String("template", "", "personalization template (YAML)")
+	quarantine := flag.String("quarantine", "quarantine.txt", "file of the UIDs of quarantined cards")
+	firstSerial := flag.Int("first-serial", 1, "serial of the first card")
+	attempts := flag.Int("attempts", 3, "failed runs before a card is quarantined")
+	flag.Parse()
+
+	cfg := &config.Config{}
+	if *configPath != "" {
+		var err error
+		if cfg, err = config.Load(*configPath); err != nil {
+			slog.Error("failed to load config", "error", err)
+			os.Exit(1)
+		}
+	}
+	if *reader != "" || cfg.Reader.Name == "" {
+		cfg.Reader.Name = *reader
+	}
+	var profile batch.Profile
+	switch {
+	case (*profilePath == "") == (*templatePath == ""):
+		slog.Error("give either -template or -desfire-profile")
+		os.Exit(2)
+	case *templatePath != "":
+		template, err := personalize.Load(*templatePath)
+		if err != nil {
+			slog.Error("failed to load template", "error", err)
+			os.Exit(1)
+		}
+		profile = personalize.NewEngine(template, keys.NewResolver(cfg))
+	default:
+		p, err := provision.LoadProfile(*profilePath)
+		if err != nil {
+			slog.Error("failed to load profile", "error", err)
+			os.Exit(1)
+		}
+		profile = &batch.DESFireProfile{Label: *profilePath, Profile: p}
+	}
+
+	stationConfig := batch.Config{
+		Profile:        profile,
+		FirstSerial:    *firstSerial,
+		MaxAttempts:    *attempts,
+		QuarantinePath: *quarantine,
+		Feedback:       true,
+	}
+	if cfg.Output.LogPath != "" {
+		logFormat, err := scanlog.ParseFormat(cfg.Output.LogFormat)
+		if err != nil {
+			slog.Error("invalid config", "error", err)
+			os.Exit(1)
+		}
+		scans, err := scanlog.Open(scanlog.Config{Path: cfg.Output.LogPath, Format: logFormat})
+		if err != nil {
+			slog.Error("failed to open scan log", "error", err)
+			os.Exit(1)
+		}
+		defer scans.Close()
+		stationConfig.Log = scans
+	}
+	station, err := batch.NewStation(stationConfig)
+	if err != nil {
+		slog.Error("failed to create station", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	d := daemon.New(daemon.Config{Reader: cfg.Reader.Name}, func(r *hardware.Reader) error {
+		err := station.HandleContext(ctx, r)
+		counters := station.Counters()
+		if err != nil {
+			slog.Warn("card not done", "uid", uid.Hex(r.CardInfo().UID), "error", err, "done", counters.Done)
+		} else {
+			slog.Info("card done", "uid", uid.Hex(r.CardInfo().UID), "done", counters.Done)
+		}
+		return err
+	})
+	slog.Info("present cards", "profile", profile.Name())
+	if err := d.Run(ctx); err != nil {
+		slog.Error("station failed", "error", err)
+		os.Exit(1)
+	}
+	c := station.Counters()
+	slog.Info("run finished", "done", c.Done, "failed", c.Failed, "quarantined", c.Quarantined,
+		"rejected", c.Rejected, "unsupported", c.Unsupported, "duplicate", c.Duplicate)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/config"
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/emv"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/transcript"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// operations run against the card of a session, on the reader when
+// recording and on the transcript when replaying
+var operations = map[string]func(t hardware.Transport) error{
+	"uid": func(t hardware.Transport) error {
+		rsp, err := t.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("% X\n", rsp)
+		return nil
+	},
+	"ntag-dump": func(t hardware.Transport) error {
+		data, err := ntag.NewNTAGWithTransport(t).DumpMemory()
+		printBlocks(data, 4)
+		return err
+	},
+	"classic1k-dump": func(t hardware.Transport) error {
+		c := classic.NewClassicWithTransport(t)
+		var data []byte
+		for sector := byte(0); sector < 16; sector++ {
+			if c.TryStandardKeys(classic.GetSectorTrailerBlock(sector), classic.KeyTypeA) == "" {
+				return fmt.Errorf("no standard key for sector %d", sector)
+			}
+			for block := sector * 4; block < sector*4+4; block++ {
+				b, err := c.ReadBlock(block)
+				if err != nil {
+					return err
+				}
+				data = append(data, b...)
+			}
+		}
+		printBlocks(data, 16)
+		return nil
+	},
+	"desfire-info": func(t hardware.Transport) error {
+		df := desfire.NewDESFireWithTransport(t)
+		version, err := df.GetVersion()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version % X\n", version)
+		aids, err := df.GetApplicationIDs()
+		if err != nil {
+			return err
+		}
+		for _, aid := range aids {
+			fmt.Printf("application % X\n", aid)
+		}
+		return nil
+	},
+	"emv": func(t hardware.Transport) error {
+		apps, err := emv.NewEMVWithTransport(t).Applications()
+		if err != nil {
+			return err
+		}
+		for _, app := range apps {
+			fmt.Printf("%X %s\n", app.AID, app.Label)
+		}
+		return nil
+	},
+}
+
+func printBlocks(data []byte, size int) {
+	for i := 0; i+size <= len(data); i += size {
+		fmt.Printf("%3d: % X\n", i/size, data[i:i+size])
+	}
+}
+
+func usage() {
+	names := slices.Sorted(maps.Keys(operations))
+	fmt.Fprintf(os.Stderr, `Usage:
+  acr122u-transcript record [-dir DIR] [-reader PATTERN] OPERATION
+      run the operation on the presented card and write a transcript to DIR
+  acr122u-transcript replay FILE OPERATION
+      run the operation against a transcript instead of a card
+  acr122u-transcript show FILE
+      print a transcript
+
+Operations: %s
+`, strings.Join(names, ", "))
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "record":
+		err = record(os.Args[2:])
+	case "replay":
+		err = replay(os.Args[2:])
+	case "show":
+		err = show(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		slog.Error(os.Args[1]+" failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func operation(name string) func(hardware.Transport) error {
+	op, ok := operations[name]
+	if !ok {
+		usage()
+	}
+	return op
+}
+
+func record(args []string) error {
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory for the transcript")
+	pattern := flags.String("reader", "", "use the first reader whose name matches this regular expression")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	op := operation(flags.Arg(0))
+
+	cfg := &config.Config{}
+	cfg.Reader.Name = *pattern
+	reader, err := hardware.NewReaderFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	slog.Info("waiting for card", "reader", reader.Reader())
+	if err := reader.WaitForCard(); err != nil {
+		return fmt.Errorf("failed to wait for card: %v", err)
+	}
+	if err := reader.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer reader.Disconnect()
+	info := reader.CardInfo()
+	slog.Info("card connected", "uid", fmt.Sprintf("%X", info.UID), "type", info.Type)
+
+	rec, err := transcript.NewFileRecorder(reader.Transport(), *dir)
+	if err != nil {
+		return err
+	}
+	opErr := op(rec)
+	if err := rec.Close(); err != nil {
+		return err
+	}
+	slog.Info("transcript written", "path", rec.Path(), "exchanges", len(rec.Exchanges()))
+	return opErr
+}
+
+func replay(args []string) error {
+	if len(args) != 2 {
+		usage()
+	}
+	op := operation(args[1])
+	replayer, err := transcript.LoadReplayer(args[0])
+	if err != nil {
+		return err
+	}
+	if err := op(replayer); err != nil {
+		return err
+	}
+	return replayer.Done()
+}
+
+func show(args []string) error {
+	if len(args) != 1 {
+		usage()
+	}
+	exchanges, err := transcript.Load(args[0])
+	if err != nil {
+		return err
+	}
+	for i, e := range exchanges {
+		fmt.Printf("%4d %s %8s > % X\n", i+1, e.Time.Format("15:04:05.000"), e.Duration, e.Command)
+		if e.Error != "" {
+			fmt.Printf("%4d %21s < error: %s\n", i+1, "", e.Error)
+		} else {
+			fmt.Printf("%4d %21s < % X\n", i+1, "", e.Response)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/oo-developer/acr122u/access"
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/config"
+	"github.com/oo-developer/acr122u/daemon"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
+	"github.com/oo-developer/acr122u/metrics"
+	"github.com/oo-developer/acr122u/ntag"
+	"github.com/oo-developer/acr122u/personalize"
+	"github.com/oo-developer/acr122u/scanlog"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+func main() {
+	configPath := flag.String("config", "", "YAML or TOML config file")
+	reader := flag.String("reader", "", "use the first reader whose name matches this regular expression")
+	socket := flag.String("socket", "/run/acr122u/control.sock", "control socket path, empty to disable")
+	metricsAddr := flag.String("metrics", "", "serve Prometheus metrics on this address, e.g. :9122")
+	debug := flag.Bool("debug", false, "log every APDU")
+	templatePath := flag.String("template", "", "personalization template (YAML) applied to every card")
+	flag.Parse()
+
+	cfg := &config.Config{}
+	if *configPath != "" {
+		var err error
+		if cfg, err = config.Load(*configPath); err != nil {
+			slog.Error("failed to load config", "error", err)
+			os.Exit(1)
+		}
+	}
+	// Flags given on the command line override the config file
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["reader"] || cfg.Reader.Name == "" {
+		cfg.Reader.Name = *reader
+	}
+	if set["socket"] || cfg.Daemon.ControlSocket == "" {
+		cfg.Daemon.ControlSocket = *socket
+	}
+	if set["metrics"] || cfg.Daemon.Metrics == "" {
+		cfg.Daemon.Metrics = *metricsAddr
+	}
+	if set["debug"] {
+		cfg.Daemon.Debug = *debug
+	}
+	if set["template"] {
+		cfg.Daemon.Template = *templatePath
+	}
+
+	level := slog.LevelInfo
+	if cfg.Daemon.Debug {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	if err := classic.UseConfig(cfg); err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+	if err := ntag.UseConfig(cfg); err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+
+	format, err := uid.Formatter(cfg.Output.UIDFormat)
+	if err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+	var scans *scanlog.Logger
+	if cfg.Output.LogPath != "" {
+		logFormat, err := scanlog.ParseFormat(cfg.Output.LogFormat)
+		if err != nil {
+			slog.Error("invalid config", "error", err)
+			os.Exit(1)
+		}
+		scans, err = scanlog.Open(scanlog.Config{Path: cfg.Output.LogPath, Format: logFormat})
+		if err != nil {
+			slog.Error("failed to open scan log", "error", err)
+			os.Exit(1)
+		}
+		defer scans.Close()
+	}
+	var engine *personalize.Engine
+	if cfg.Daemon.Template != "" {
+		template, err := personalize.Load(cfg.Daemon.Template)
+		if err != nil {
+			slog.Error("failed to load template", "error", err)
+			os.Exit(1)
+		}
+		engine = personalize.NewEngine(template, keys.NewResolver(cfg))
+	}
+	serial := 1
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var middlewares []hardware.Middleware
+	if cfg.Daemon.Metrics != "" {
+		middlewares = append(middlewares, metrics.Middleware)
+	}
+	d := daemon.New(daemon.Config{
+		Reader:        cfg.Reader.Name,
+		ControlSocket: cfg.Daemon.ControlSocket,
+		PollInterval:  cfg.Daemon.PollInterval,
+		Middlewares:   middlewares,
+	}, func(r *hardware.Reader) error {
+		info := r.CardInfo()
+		slog.Info("card scanned", "uid", format(info.UID), "type", info.Type, "reader", r.Reader())
+		metrics.ObserveScan(info)
+		if engine != nil {
+			if err := engine.Personalize(ctx, r, serial); err != nil {
+				slog.Warn("failed to personalize card", "uid", format(info.UID), "error", err)
+			} else {
+				slog.Info("card personalized", "uid", format(info.UID), "serial", serial)
+				serial++
+			}
+		}
+		if scans != nil {
+			if err := scans.Log(r, nil); err != nil {
+				slog.Warn("failed to log scan", "error", err)
+			}
+		}
+		if cfg.Feedback.LED || cfg.Feedback.Beep {
+			if err := access.NewLEDAction(r, access.LEDGreen, 1, cfg.Feedback.Beep).Execute(access.Decision{}); err != nil {
+				slog.Warn("failed to signal scan", "error", err)
+			}
+		}
+		return nil
+	})
+	if cfg.Daemon.Metrics != "" {
+		if err := metrics.RegisterDaemon(d); err != nil {
+			slog.Error("failed to register metrics", "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			if err := metrics.ListenAndServe(cfg.Daemon.Metrics); err != nil {
+				slog.Error("metrics endpoint failed", "error", err)
+				stop()
+			}
+		}()
+	}
+	if err := d.Run(ctx); err != nil {
+		slog.Error("daemon failed", "error", err)
+		os.Exit(1)
+	}
+}
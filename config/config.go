@@ -0,0 +1,352 @@
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/oo-developer/acr122u/uid"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration shared by the library and the commands. It is
+// read from YAML (also JSON) or TOML:
+//
+//	reader:
+//	  name: "ACR122U.*00"
+//	  exchange_timeout: 2s
+//	classic:
+//	  keys:
+//	    - name: office
+//	      key_a: A0A1A2A3A4A5
+//	      key_b: keyring:office-b
+//	ntag:
+//	  passwords:
+//	    - name: badges
+//	      pwd: 12345678
+//	      pack: AABB
+//	desfire:
+//	  keys:
+//	    - name: master
+//	      type: aes
+//	      ref: env:DESFIRE_MASTER_KEY
+//	keys:
+//	  age_file: keys.age
+//	  age_identity: /etc/acr122u/identity.txt
+//	feedback:
+//	  led: true
+//	  beep: false
+//	output:
+//	  uid_format: decimal10
+type Config struct {
+	Reader   Reader   `yaml:"reader" toml:"reader"`
+	Classic  Classic  `yaml:"classic" toml:"classic"`
+	NTAG     NTAG     `yaml:"ntag" toml:"ntag"`
+	DESFire  DESFire  `yaml:"desfire" toml:"desfire"`
+	Feedback Feedback `yaml:"feedback" toml:"feedback"`
+	Output   Output   `yaml:"output" toml:"output"`
+	Daemon   Daemon   `yaml:"daemon" toml:"daemon"`
+	Keys     Keys     `yaml:"keys" toml:"keys"`
+}
+
+// Reader selects the PC/SC reader
+type Reader struct {
+	// Name is a regular expression matched against the reader names; the
+	// first matching reader is used, the first reader if empty
+	Name string `yaml:"name" toml:"name"`
+	// Timeouts of waiting for cards, connecting and each card exchange,
+	// none if zero; see hardware.Timeouts
+	WaitTimeout     time.Duration `yaml:"wait_timeout" toml:"wait_timeout"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+	ExchangeTimeout time.Duration `yaml:"exchange_timeout" toml:"exchange_timeout"`
+}
+
+// Classic lists MIFARE Classic keys tried in addition to the built-in ones
+type Classic struct {
+	Keys []ClassicKey `yaml:"keys" toml:"keys"`
+}
+
+// ClassicKey holds hex keys or references to them, see DESFireKey
+type ClassicKey struct {
+	Name string `yaml:"name" toml:"name"`
+	KeyA string `yaml:"key_a" toml:"key_a"` // hex
+	KeyB string `yaml:"key_b" toml:"key_b"`
+}
+
+// NTAG lists passwords tried in addition to the built-in ones
+type NTAG struct {
+	Passwords []NTAGPassword `yaml:"passwords" toml:"passwords"`
+}
+
+// NTAGPassword holds a hex password and PACK or references to them, see
+// DESFireKey
+type NTAGPassword struct {
+	Name string `yaml:"name" toml:"name"`
+	PWD  string `yaml:"pwd" toml:"pwd"` // hex
+	PACK string `yaml:"pack" toml:"pack"`
+}
+
+// DESFire names keys stored elsewhere, so the file holds no key material
+type DESFire struct {
+	Keys []DESFireKey `yaml:"keys" toml:"keys"`
+}
+
+// DESFireKey refers to a key: "env:NAME" reads hex from an environment
+// variable, "file:PATH" hex from a file and "hex:..." is the key itself.
+// "keyring:NAME" and "age:NAME" look the key up in the stores configured in
+// Keys, and are resolved by the keys package.
+type DESFireKey struct {
+	Name string `yaml:"name" toml:"name"`
+	Type string `yaml:"type" toml:"type"` // des, 2k3des, 3k3des or aes
+	Ref  string `yaml:"ref" toml:"ref"`
+}
+
+// Feedback controls the reader's LED and buzzer on scans
+type Feedback struct {
+	LED  bool `yaml:"led" toml:"led"`
+	Beep bool `yaml:"beep" toml:"beep"`
+}
+
+// Output configures how scans are reported
+type Output struct {
+	UIDFormat string `yaml:"uid_format" toml:"uid_format"` // see uid.Formatter
+	LogFormat string `yaml:"log_format" toml:"log_format"` // csv, jsonl or sqlite
+	LogPath   string `yaml:"log_path" toml:"log_path"`
+}
+
+// Daemon configures acr122ud
+type Daemon struct {
+	ControlSocket string        `yaml:"control_socket" toml:"control_socket"`
+	Metrics       string        `yaml:"metrics" toml:"metrics"`
+	PollInterval  time.Duration `yaml:"poll_interval" toml:"poll_interval"`
+	Debug         bool          `yaml:"debug" toml:"debug"`
+	// Template is a personalization template applied to every card, see the
+	// personalize package
+	Template string `yaml:"template" toml:"template"`
+}
+
+// Keys configures the key stores of "keyring:" and "age:" references
+type Keys struct {
+	// KeyringService is the service of the keys in the OS keyring, "acr122u"
+	// if empty
+	KeyringService string `yaml:"keyring_service" toml:"keyring_service"`
+	// AgeFile is an age encrypted file of keys, opened with the identities
+	// in AgeIdentity
+	AgeFile     string `yaml:"age_file" toml:"age_file"`
+	AgeIdentity string `yaml:"age_identity" toml:"age_identity"`
+}
+
+// referenceSchemes are the schemes of key references
+var referenceSchemes = []string{"env", "file", "hex", "keyring", "age"}
+
+// IsReference reports whether a key value refers to a key instead of being
+// hex
+func IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, ":")
+	return ok && slices.Contains(referenceSchemes, scheme)
+}
+
+// Load reads a config file; .toml files are TOML, all others YAML
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return ParseTOML(data)
+	}
+	return ParseYAML(data)
+}
+
+// ParseYAML parses a YAML or JSON config
+func ParseYAML(data []byte) (*Config, error) {
+	var c Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ParseTOML parses a TOML config
+func ParseTOML(data []byte) (*Config, error) {
+	var c Config
+	meta, err := toml.Decode(string(data), &c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("failed to parse config: unknown key %s", undecoded[0])
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks keys and passwords; references are resolved when used
+func (c *Config) Validate() error {
+	if c.Output.UIDFormat != "" {
+		if _, err := uid.Formatter(c.Output.UIDFormat); err != nil {
+			return err
+		}
+	}
+	for _, k := range c.Classic.Keys {
+		if k.Name == "" {
+			return fmt.Errorf("classic key without name")
+		}
+		for _, key := range []string{k.KeyA, k.KeyB} {
+			if key == "" {
+				continue
+			}
+			if err := c.checkKey(key, 6); err != nil {
+				return fmt.Errorf("classic key %s: %v", k.Name, err)
+			}
+		}
+		if k.KeyA == "" && k.KeyB == "" {
+			return fmt.Errorf("classic key %s: no key", k.Name)
+		}
+	}
+	for _, p := range c.NTAG.Passwords {
+		if p.Name == "" {
+			return fmt.Errorf("ntag password without name")
+		}
+		if err := c.checkKey(p.PWD, 4); err != nil {
+			return fmt.Errorf("ntag password %s: %v", p.Name, err)
+		}
+		if p.PACK != "" {
+			if err := c.checkKey(p.PACK, 2); err != nil {
+				return fmt.Errorf("ntag password %s: %v", p.Name, err)
+			}
+		}
+	}
+	for _, k := range c.DESFire.Keys {
+		if _, err := keySize(k.Type); err != nil {
+			return fmt.Errorf("desfire key %s: %v", k.Name, err)
+		}
+		if !IsReference(k.Ref) {
+			return fmt.Errorf("desfire key %s: invalid reference %q", k.Name, k.Ref)
+		}
+		if err := c.checkKey(k.Ref, 0); err != nil {
+			return fmt.Errorf("desfire key %s: %v", k.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkKey checks a hex key of the size, or a reference
+func (c *Config) checkKey(value string, size int) error {
+	if strings.HasPrefix(value, "age:") && c.Keys.AgeFile == "" {
+		return fmt.Errorf("%q needs keys.age_file", value)
+	}
+	if IsReference(value) {
+		return nil
+	}
+	_, err := parseHex(value, size)
+	return err
+}
+
+// Bytes returns key A and key B, nil if not set or references
+func (k ClassicKey) Bytes() (keyA []byte, keyB []byte) {
+	keyA, _ = parseHex(k.KeyA, 6)
+	keyB, _ = parseHex(k.KeyB, 6)
+	return keyA, keyB
+}
+
+// Bytes returns the password and PACK, nil if not set or references
+func (p NTAGPassword) Bytes() (pwd []byte, pack []byte) {
+	pwd, _ = parseHex(p.PWD, 4)
+	pack, _ = parseHex(p.PACK, 2)
+	return pwd, pack
+}
+
+// DESFireKey returns the key of the name
+func (c *Config) DESFireKey(name string) (*DESFireKey, error) {
+	for i := range c.DESFire.Keys {
+		if c.DESFire.Keys[i].Name == name {
+			return &c.DESFire.Keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no desfire key %q configured", name)
+}
+
+// Resolve reads the key the reference points to
+func (k DESFireKey) Resolve() ([]byte, error) {
+	size, err := keySize(k.Type)
+	if err != nil {
+		return nil, err
+	}
+	var text string
+	scheme, value, _ := strings.Cut(k.Ref, ":")
+	switch scheme {
+	case "env":
+		v, ok := os.LookupEnv(value)
+		if !ok {
+			return nil, fmt.Errorf("desfire key %s: environment variable %s not set", k.Name, value)
+		}
+		text = v
+	case "file":
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("desfire key %s: %v", k.Name, err)
+		}
+		text = string(data)
+	case "hex":
+		text = value
+	case "keyring", "age":
+		return nil, fmt.Errorf("desfire key %s: %s references are resolved by the keys package", k.Name, scheme)
+	default:
+		return nil, fmt.Errorf("desfire key %s: invalid reference %q", k.Name, k.Ref)
+	}
+	key, err := parseHex(strings.TrimSpace(text), size)
+	if err != nil {
+		return nil, fmt.Errorf("desfire key %s: %v", k.Name, err)
+	}
+	return key, nil
+}
+
+// Size returns the key length of the key type
+func (k DESFireKey) Size() (int, error) {
+	return keySize(k.Type)
+}
+
+func keySize(keyType string) (int, error) {
+	switch strings.ToLower(keyType) {
+	case "des":
+		return 8, nil
+	case "2k3des", "3des":
+		return 16, nil
+	case "3k3des":
+		return 24, nil
+	case "aes":
+		return 16, nil
+	}
+	return 0, fmt.Errorf("unknown key type %q", keyType)
+}
+
+// parseHex decodes hex with optional spaces or colons; empty gives nil
+func parseHex(s string, size int) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(strings.NewReplacer(" ", "", ":", "").Replace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex %q", s)
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("%q must be %d bytes", s, size)
+	}
+	return b, nil
+}
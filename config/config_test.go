@@ -0,0 +1,219 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oo-developer/acr122u/config"
+)
+
+const yamlConfig = `
+reader:
+  name: "ACR122U.*00"
+  exchange_timeout: 2s
+classic:
+  keys:
+    - name: office
+      key_a: A0A1A2A3A4A5
+      key_b: keyring:office-b
+ntag:
+  passwords:
+    - name: badges
+      pwd: 12345678
+      pack: AABB
+desfire:
+  keys:
+    - name: master
+      type: aes
+      ref: env:DESFIRE_MASTER_KEY
+keys:
+  age_file: keys.age
+feedback:
+  led: true
+output:
+  uid_format: decimal10
+`
+
+const tomlConfig = `
+[reader]
+name = "ACR122U.*00"
+exchange_timeout = "2s"
+
+[[classic.keys]]
+name = "office"
+key_a = "A0A1A2A3A4A5"
+key_b = "keyring:office-b"
+
+[[ntag.passwords]]
+name = "badges"
+pwd = "12345678"
+pack = "AABB"
+
+[[desfire.keys]]
+name = "master"
+type = "aes"
+ref = "env:DESFIRE_MASTER_KEY"
+
+[keys]
+age_file = "keys.age"
+
+[feedback]
+led = true
+
+[output]
+uid_format = "decimal10"
+`
+
+// checkExample checks the values of the example configs
+func checkExample(t *testing.T, c *config.Config) {
+	t.Helper()
+	if c.Reader.Name != "ACR122U.*00" || c.Reader.ExchangeTimeout != 2*time.Second {
+		t.Errorf("reader %+v", c.Reader)
+	}
+	keyA, keyB := c.Classic.Keys[0].Bytes()
+	if !bytes.Equal(keyA, []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}) || keyB != nil {
+		t.Errorf("classic keys % X, % X", keyA, keyB)
+	}
+	pwd, pack := c.NTAG.Passwords[0].Bytes()
+	if !bytes.Equal(pwd, []byte{0x12, 0x34, 0x56, 0x78}) || !bytes.Equal(pack, []byte{0xAA, 0xBB}) {
+		t.Errorf("ntag password % X, PACK % X", pwd, pack)
+	}
+	if k, err := c.DESFireKey("master"); err != nil || k.Ref != "env:DESFIRE_MASTER_KEY" {
+		t.Errorf("DESFireKey: %+v, %v", k, err)
+	}
+	if !c.Feedback.LED || c.Feedback.Beep || c.Output.UIDFormat != "decimal10" || c.Keys.AgeFile != "keys.age" {
+		t.Errorf("config %+v", c)
+	}
+}
+
+func TestParse(t *testing.T) {
+	c, err := config.ParseYAML([]byte(yamlConfig))
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	checkExample(t, c)
+	c, err = config.ParseTOML([]byte(tomlConfig))
+	if err != nil {
+		t.Fatalf("ParseTOML: %v", err)
+	}
+	checkExample(t, c)
+
+	// Empty files are valid
+	if _, err := config.ParseYAML(nil); err != nil {
+		t.Errorf("ParseYAML of an empty file: %v", err)
+	}
+	if _, err := config.ParseYAML([]byte(`{"output": {"uid_format": "hex"}}`)); err != nil {
+		t.Errorf("ParseYAML of JSON: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		yaml string
+		toml string
+	}{
+		{"unknown section", "readers:\n  name: x\n", "[readers]\nname = \"x\"\n"},
+		{"unknown key", "reader:\n  timeout: 2s\n", "[reader]\ntimeout = \"2s\"\n"},
+		{"invalid duration", "reader:\n  wait_timeout: soon\n", "[reader]\nwait_timeout = \"soon\"\n"},
+	} {
+		if _, err := config.ParseYAML([]byte(tc.yaml)); err == nil {
+			t.Errorf("%s: ParseYAML succeeded", tc.name)
+		}
+		if _, err := config.ParseTOML([]byte(tc.toml)); err == nil {
+			t.Errorf("%s: ParseTOML succeeded", tc.name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		yaml string
+		err  string // empty if valid
+	}{
+		{"classic key with colons", "classic:\n  keys: [{name: a, key_a: 'A0:A1:A2:A3:A4:A5'}]", ""},
+		{"classic key B only", "classic:\n  keys: [{name: a, key_b: 'env:KEY_B'}]", ""},
+		{"classic key without name", "classic:\n  keys: [{key_a: A0A1A2A3A4A5}]", "without name"},
+		{"classic key missing", "classic:\n  keys: [{name: a}]", "classic key a: no key"},
+		{"classic key bad hex", "classic:\n  keys: [{name: a, key_a: A0A1A2A3A4AG}]", "invalid hex"},
+		{"classic key too short", "classic:\n  keys: [{name: a, key_a: A0A1A2A3A4}]", "must be 6 bytes"},
+		{"ntag password too long", "ntag:\n  passwords: [{name: p, pwd: '1234567890'}]", "must be 4 bytes"},
+		{"ntag PACK bad hex", "ntag:\n  passwords: [{name: p, pwd: '12345678', pack: XYZW}]", "invalid hex"},
+		{"ntag password without name", "ntag:\n  passwords: [{pwd: '12345678'}]", "without name"},
+		{"desfire key from a file", "desfire:\n  keys: [{name: k, type: 3k3des, ref: 'file:/etc/key'}]", ""},
+		{"desfire key type", "desfire:\n  keys: [{name: k, type: rsa, ref: 'env:K'}]", `unknown key type "rsa"`},
+		{"desfire key as hex", "desfire:\n  keys: [{name: k, type: aes, ref: 00112233445566778899AABBCCDDEEFF}]", "invalid reference"},
+		{"desfire key unknown scheme", "desfire:\n  keys: [{name: k, type: aes, ref: 'vault:k'}]", "invalid reference"},
+		{"age reference without file", "desfire:\n  keys: [{name: k, type: aes, ref: 'age:k'}]", "needs keys.age_file"},
+		{"age reference", "keys: {age_file: keys.age}\ndesfire:\n  keys: [{name: k, type: aes, ref: 'age:k'}]", ""},
+		{"uid format", "output: {uid_format: base64}", "unknown UID format"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := config.ParseYAML([]byte(tc.yaml))
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("ParseYAML: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("ParseYAML: %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("DESFIRE_TEST_KEY", "00112233445566778899AABBCCDDEEFF")
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("0011223344556677\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	for _, tc := range []struct {
+		key  config.DESFireKey
+		want []byte // nil if the key does not resolve
+	}{
+		{config.DESFireKey{Name: "env", Type: "aes", Ref: "env:DESFIRE_TEST_KEY"},
+			[]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}},
+		{config.DESFireKey{Name: "file", Type: "des", Ref: "file:" + path},
+			[]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}},
+		{config.DESFireKey{Name: "hex", Type: "DES", Ref: "hex:01:02:03:04:05:06:07:08"},
+			[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+		{config.DESFireKey{Name: "wrong size", Type: "3k3des", Ref: "env:DESFIRE_TEST_KEY"}, nil},
+		{config.DESFireKey{Name: "unset", Type: "aes", Ref: "env:DESFIRE_UNSET_KEY"}, nil},
+		{config.DESFireKey{Name: "missing file", Type: "aes", Ref: "file:" + path + ".missing"}, nil},
+		{config.DESFireKey{Name: "keyring", Type: "aes", Ref: "keyring:k"}, nil},
+	} {
+		key, err := tc.key.Resolve()
+		if tc.want == nil {
+			if err == nil {
+				t.Errorf("%s: Resolve = % X", tc.key.Name, key)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(key, tc.want) {
+			t.Errorf("%s: Resolve = % X, %v", tc.key.Name, key, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"acr122u.yaml": yamlConfig, "acr122u.TOML": tomlConfig} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		c, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", name, err)
+		}
+		checkExample(t, c)
+	}
+	if _, err := config.Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("Load of a missing file succeeded")
+	}
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+)
+
+// Control socket commands, one per line. Each gets a one-line JSON reply.
+const (
+	CommandHealth    = "health"    // the Status
+	CommandReconnect = "reconnect" // reconnect to pcscd
+	CommandShutdown  = "shutdown"  // stop the daemon
+)
+
+type reply struct {
+	OK     bool    `json:"ok"`
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+func (d *Daemon) serve(ctx context.Context, listener net.Listener) {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.serveConn(conn)
+	}
+}
+
+func (d *Daemon) serveConn(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+		if !scanner.Scan() {
+			return
+		}
+		if err := encoder.Encode(d.command(strings.TrimSpace(scanner.Text()))); err != nil {
+			return
+		}
+	}
+}
+
+func (d *Daemon) command(cmd string) reply {
+	switch cmd {
+	case CommandHealth:
+		status := d.Status()
+		return reply{OK: status.State == "running", Status: &status}
+	case CommandReconnect:
+		d.Reconnect()
+		return reply{OK: true}
+	case CommandShutdown:
+		d.Shutdown()
+		return reply{OK: true}
+	}
+	return reply{Error: "unknown command " + cmd}
+}
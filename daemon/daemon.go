@@ -0,0 +1,272 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// Handler processes a card while it is connected
+type Handler func(reader *hardware.Reader) error
+
+// Config configures the daemon
+type Config struct {
+	// Reader is a regular expression selecting the first matching reader,
+	// the first reader if empty
+	Reader string
+	// ControlSocket is the path of the unix control socket, none if empty
+	ControlSocket string
+
+	PollInterval time.Duration // card state polling, 500ms if zero
+	RetryMin     time.Duration // first delay before reconnecting to pcscd, 1s if zero
+	RetryMax     time.Duration // delay limit, 30s if zero
+
+	// Middlewares are installed on every reader the daemon opens
+	Middlewares []hardware.Middleware
+}
+
+// Status is the health of the daemon as reported on the control socket
+type Status struct {
+	State      string    `json:"state"` // starting, waiting, running or stopping
+	Reader     string    `json:"reader,omitempty"`
+	Started    time.Time `json:"started"`
+	Scans      int       `json:"scans"`
+	Errors     int       `json:"errors"`
+	Reconnects int       `json:"reconnects"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Daemon watches a reader and hands each presented card to the handler. It
+// survives pcscd restarts and unplugged readers by reconnecting with backoff.
+// The handler runs on the goroutine of Run and owns the reader until it
+// returns; Status, Reconnect and Shutdown may be called from any goroutine.
+type Daemon struct {
+	config    Config
+	handler   Handler
+	reconnect chan struct{}
+	stop      context.CancelFunc
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New creates a daemon
+func New(config Config, handler Handler) *Daemon {
+	if config.PollInterval == 0 {
+		config.PollInterval = 500 * time.Millisecond
+	}
+	if config.RetryMin == 0 {
+		config.RetryMin = time.Second
+	}
+	if config.RetryMax == 0 {
+		config.RetryMax = 30 * time.Second
+	}
+	return &Daemon{
+		config:    config,
+		handler:   handler,
+		reconnect: make(chan struct{}, 1),
+		status:    Status{State: "starting"},
+	}
+}
+
+// Status returns a snapshot of the health
+func (d *Daemon) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+func (d *Daemon) update(f func(s *Status)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f(&d.status)
+	_ = Notify(strings.TrimSpace("STATUS=" + d.status.State + " " + d.status.Reader))
+}
+
+// Reconnect drops the PC/SC context and connects again
+func (d *Daemon) Reconnect() {
+	select {
+	case d.reconnect <- struct{}{}:
+	default:
+	}
+}
+
+// Run serves until the context is done or Shutdown is called, then returns
+// nil. Failures of pcscd or the reader are retried; only a failing control
+// socket ends the daemon with an error.
+func (d *Daemon) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.mu.Lock()
+	d.stop = cancel
+	d.status.Started = time.Now()
+	d.mu.Unlock()
+
+	if d.config.ControlSocket != "" {
+		listener, err := listen(d.config.ControlSocket)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(d.config.ControlSocket)
+		defer listener.Close()
+		go d.serve(ctx, listener)
+	}
+	if interval := WatchdogInterval(); interval > 0 {
+		go watchdog(ctx, interval)
+	}
+	_ = Notify(NotifyReady)
+
+	// Readers plugged in end the backoff
+	readers := hardware.WatchReaders(ctx)
+	delay := d.config.RetryMin
+	for {
+		established, err := d.session(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if established {
+			delay = d.config.RetryMin
+		}
+		d.update(func(s *Status) {
+			s.State = "waiting"
+			s.Reconnects++
+			if err != nil {
+				s.LastError = err.Error()
+			}
+		})
+		if err == nil {
+			// Reconnect requested
+			continue
+		}
+		select {
+		case <-time.After(delay):
+		case <-d.reconnect:
+		case _, ok := <-readers:
+			if !ok {
+				readers = nil
+			}
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		delay = min(delay*2, d.config.RetryMax)
+	}
+	d.update(func(s *Status) { s.State = "stopping" })
+	_ = Notify(NotifyStopping)
+	return nil
+}
+
+// Shutdown makes Run return
+func (d *Daemon) Shutdown() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		d.stop()
+	}
+}
+
+func watchdog(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = Notify(NotifyWatchdog)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// session connects to pcscd and handles cards until an error, a reconnect
+// request or the end of ctx. established reports whether a reader was found.
+func (d *Daemon) session(ctx context.Context) (established bool, err error) {
+	reader, err := hardware.NewReader()
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+	name, err := d.selectReader(reader)
+	if err != nil {
+		return false, err
+	}
+	reader.UseReader(name)
+	reader.Use(d.config.Middlewares...)
+	d.update(func(s *Status) { s.State, s.Reader = "running", name })
+
+	state := scard.StateUnaware
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case <-d.reconnect:
+			return true, nil
+		default:
+		}
+		states := []scard.ReaderState{{Reader: name, CurrentState: state}}
+		if err := reader.Ctx().GetStatusChange(states, d.config.PollInterval); err != nil {
+			if errors.Is(err, scard.ErrTimeout) {
+				continue
+			}
+			return true, fmt.Errorf("failed to watch reader: %v", err)
+		}
+		newState := states[0].EventState &^ scard.StateChanged
+		arrived := newState&scard.StatePresent != 0 && state&scard.StatePresent == 0
+		state = newState
+		if arrived {
+			d.handle(reader)
+		}
+	}
+}
+
+func (d *Daemon) selectReader(reader *hardware.Reader) (string, error) {
+	readers, err := reader.ListReaders()
+	if err != nil {
+		return "", err
+	}
+	return hardware.SelectReader(readers, d.config.Reader)
+}
+
+// handle connects to an arrived card and runs the handler. Card errors are
+// counted, they do not end the session.
+func (d *Daemon) handle(reader *hardware.Reader) {
+	err := reader.Connect()
+	if err == nil {
+		err = d.handler(reader)
+		reader.Disconnect()
+	}
+	d.update(func(s *Status) {
+		s.Scans++
+		if err != nil {
+			s.Errors++
+			s.LastError = err.Error()
+		}
+	})
+}
+
+// listen creates the control socket, replacing a stale one
+func listen(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("control socket %s is in use", path)
+	}
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create control socket: %v", err)
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to create control socket: %v", err)
+	}
+	return listener, nil
+}
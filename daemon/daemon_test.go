@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dial connects to the control socket once the daemon listens on it
+func dial(t *testing.T, path string) net.Conn {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			return conn
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Dial: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	d := New(Config{ControlSocket: socket, RetryMin: time.Millisecond, RetryMax: 10 * time.Millisecond}, nil)
+	done := make(chan error, 1)
+	go func() { done <- d.Run(t.Context()) }()
+	dial(t, socket).Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				d.Status()
+				d.Reconnect()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			conn := dial(t, socket)
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for _, cmd := range []string{CommandHealth, CommandReconnect, CommandHealth} {
+				if _, err := conn.Write([]byte(cmd + "\n")); err != nil {
+					t.Errorf("Write: %v", err)
+					return
+				}
+				line, err := r.ReadBytes('\n')
+				if err != nil {
+					t.Errorf("%s: %v", cmd, err)
+					return
+				}
+				var rep reply
+				if err := json.Unmarshal(line, &rep); err != nil {
+					t.Errorf("%s: %v", cmd, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	d.Shutdown()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run did not return after Shutdown")
+	}
+	if state := d.Status().State; state != "stopping" {
+		t.Fatalf("state %q after Shutdown, want stopping", state)
+	}
+}
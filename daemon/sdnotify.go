@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sd_notify states
+const (
+	NotifyReady    = "READY=1"
+	NotifyStopping = "STOPPING=1"
+	NotifyWatchdog = "WATCHDOG=1"
+)
+
+// Notify sends a state to systemd, e.g. NotifyReady or "STATUS=waiting". It
+// does nothing when not started by systemd with Type=notify.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Abstract sockets are given with a leading @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to notify systemd: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %v", err)
+	}
+	return nil
+}
+
+// WatchdogInterval returns half the systemd watchdog timeout, the interval
+// to send NotifyWatchdog at, or zero if the watchdog is off
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
@@ -0,0 +1,106 @@
+package database
+
+import "fmt"
+
+// Manufacturer is an IC manufacturer registered in ISO/IEC 7816-6. The code
+// is the first byte of 7- and 10-byte UIDs and the vendor ID reported by
+// GET_VERSION.
+type Manufacturer struct {
+	Code     byte
+	Name     string
+	Families []string        // Likely chip families
+	Products map[byte]string // GET_VERSION product types
+}
+
+// NXP is the manufacturer of most cards handled by this module
+const NXP = 0x04
+
+var manufacturers = map[byte]Manufacturer{
+	0x01: {Code: 0x01, Name: "Motorola"},
+	0x02: {Code: 0x02, Name: "STMicroelectronics",
+		Families: []string{"ST25TA", "ST25TB/SRI", "ST25TV"}},
+	0x03: {Code: 0x03, Name: "Hitachi"},
+	NXP: {Code: NXP, Name: "NXP Semiconductors",
+		Families: []string{"MIFARE Classic", "MIFARE Ultralight", "NTAG", "MIFARE DESFire", "MIFARE Plus", "ICODE"},
+		Products: map[byte]string{
+			0x01: "MIFARE DESFire",
+			0x02: "MIFARE Plus",
+			0x03: "MIFARE Ultralight",
+			0x04: "NTAG",
+		}},
+	0x05: {Code: 0x05, Name: "Infineon Technologies",
+		Families: []string{"my-d", "SLE 66 (MIFARE Classic compatible)"}},
+	0x06: {Code: 0x06, Name: "Cylink"},
+	0x07: {Code: 0x07, Name: "Texas Instruments",
+		Families: []string{"Tag-it HF-I"}},
+	0x08: {Code: 0x08, Name: "Fujitsu"},
+	0x09: {Code: 0x09, Name: "Matsushita"},
+	0x0A: {Code: 0x0A, Name: "NEC"},
+	0x0B: {Code: 0x0B, Name: "Oki Electric"},
+	0x0C: {Code: 0x0C, Name: "Toshiba"},
+	0x0D: {Code: 0x0D, Name: "Mitsubishi Electric"},
+	0x0E: {Code: 0x0E, Name: "Samsung Electronics"},
+	0x0F: {Code: 0x0F, Name: "Hynix"},
+	0x10: {Code: 0x10, Name: "LG Semiconductors"},
+	0x11: {Code: 0x11, Name: "Emosyn-EM Microelectronics"},
+	0x12: {Code: 0x12, Name: "INSIDE Technology",
+		Families: []string{"PicoPass"}},
+	0x13: {Code: 0x13, Name: "ORGA Kartensysteme"},
+	0x14: {Code: 0x14, Name: "Sharp"},
+	0x15: {Code: 0x15, Name: "Atmel",
+		Families: []string{"CryptoRF"}},
+	0x16: {Code: 0x16, Name: "EM Microelectronic-Marin",
+		Families: []string{"EM4x"}},
+	0x17: {Code: 0x17, Name: "KSW Microtec"},
+	0x18: {Code: 0x18, Name: "ZMD"},
+	0x19: {Code: 0x19, Name: "XICOR"},
+	0x1A: {Code: 0x1A, Name: "Sony",
+		Families: []string{"FeliCa"}},
+	0x1B: {Code: 0x1B, Name: "Malaysia Microelectronic Solutions"},
+	0x1C: {Code: 0x1C, Name: "Emosyn"},
+	0x1D: {Code: 0x1D, Name: "Shanghai Fudan Microelectronics",
+		Families: []string{"FM11RF08 (MIFARE Classic compatible)"}},
+	0x1E: {Code: 0x1E, Name: "Magellan Technology"},
+	0x1F: {Code: 0x1F, Name: "Melexis"},
+	0x20: {Code: 0x20, Name: "Renesas Technology"},
+	0x21: {Code: 0x21, Name: "TAGSYS"},
+	0x22: {Code: 0x22, Name: "Transcore"},
+	0x23: {Code: 0x23, Name: "Shanghai Belling"},
+	0x24: {Code: 0x24, Name: "Masktech"},
+	0x25: {Code: 0x25, Name: "Innovision Research and Technology",
+		Families: []string{"Topaz/Jewel"}},
+	0x26: {Code: 0x26, Name: "Hitachi ULSI Systems"},
+	0x27: {Code: 0x27, Name: "Cypak"},
+	0x28: {Code: 0x28, Name: "Ricoh"},
+	0x29: {Code: 0x29, Name: "ASK"},
+	0x2A: {Code: 0x2A, Name: "Unicore Microsystems"},
+	0x2B: {Code: 0x2B, Name: "Dallas Semiconductor/Maxim"},
+	0x2C: {Code: 0x2C, Name: "Impinj"},
+}
+
+// LookupManufacturer returns the manufacturer encoded in the first byte of a
+// 7- or 10-byte UID. Single size UIDs (4 bytes) do not identify the
+// manufacturer and report false, as do unregistered codes.
+func LookupManufacturer(uid []byte) (Manufacturer, bool) {
+	if len(uid) != 7 && len(uid) != 10 {
+		return Manufacturer{}, false
+	}
+	return LookupVendor(uid[0])
+}
+
+// LookupVendor returns the manufacturer of a vendor ID reported by
+// GET_VERSION
+func LookupVendor(vendorID byte) (Manufacturer, bool) {
+	m, ok := manufacturers[vendorID]
+	return m, ok
+}
+
+// Product returns the chip family of a GET_VERSION product type, or an empty
+// string if it is not known for the manufacturer
+func (m Manufacturer) Product(productType byte) string {
+	return m.Products[productType]
+}
+
+func (m Manufacturer) String() string {
+	return fmt.Sprintf("%s (%02X)", m.Name, m.Code)
+}
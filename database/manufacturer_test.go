@@ -0,0 +1,52 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/oo-developer/acr122u/database"
+)
+
+func TestLookupManufacturer(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		uid  []byte
+		want string // empty if not identified
+	}{
+		{"NTAG215", []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, "NXP Semiconductors (04)"},
+		{"ST25TV", []byte{0x02, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}, "STMicroelectronics (02)"},
+		{"10-byte UID", []byte{0x05, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}, "Infineon Technologies (05)"},
+		{"FeliCa", []byte{0x1A, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, "Sony (1A)"},
+		{"Fudan", []byte{0x1D, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, "Shanghai Fudan Microelectronics (1D)"},
+		{"unregistered code", []byte{0x7F, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, ""},
+		{"code 00", []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, ""},
+		{"single size UID", []byte{0x04, 0x01, 0x02, 0x03}, ""},
+		{"no UID", nil, ""},
+	} {
+		m, ok := database.LookupManufacturer(tc.uid)
+		if ok != (tc.want != "") || ok && m.String() != tc.want {
+			t.Errorf("%s: LookupManufacturer = %v, %t, want %q", tc.name, m, ok, tc.want)
+		}
+	}
+}
+
+func TestProduct(t *testing.T) {
+	nxp, ok := database.LookupVendor(database.NXP)
+	if !ok {
+		t.Fatalf("NXP not registered")
+	}
+	for _, tc := range []struct {
+		productType byte
+		want        string
+	}{
+		{0x01, "MIFARE DESFire"},
+		{0x04, "NTAG"},
+		{0x7F, ""},
+	} {
+		if got := nxp.Product(tc.productType); got != tc.want {
+			t.Errorf("Product(%02X) = %q, want %q", tc.productType, got, tc.want)
+		}
+	}
+	if sony, _ := database.LookupVendor(0x1A); sony.Product(0x01) != "" {
+		t.Errorf("Sony product 01 is %q", sony.Product(0x01))
+	}
+}
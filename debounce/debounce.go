@@ -0,0 +1,75 @@
+package debounce
+
+import (
+	"sync"
+	"time"
+
+	"github.com/oo-developer/acr122u/uid"
+)
+
+// Debouncer suppresses repeated sightings of the same card. A card counts as
+// new once it has not been seen for the window, so a card held on the reader
+// yields one event however often it is polled, and a re-tap within the
+// window is dropped. It is safe for concurrent use.
+type Debouncer struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	now    func() time.Time
+}
+
+// NewDebouncer creates a debouncer with the cooldown window
+func NewDebouncer(window time.Duration) *Debouncer {
+	return &Debouncer{window: window, seen: make(map[string]time.Time), now: time.Now}
+}
+
+// Accept records a sighting and reports whether it is a new logical event
+func (d *Debouncer) Accept(id []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	key := uid.Hex(id)
+	last, ok := d.seen[key]
+	d.seen[key] = now
+	d.expire(now)
+	return !ok || now.Sub(last) >= d.window
+}
+
+// expire forgets cards not seen for the window; the caller holds the lock
+func (d *Debouncer) expire(now time.Time) {
+	for key, last := range d.seen {
+		if now.Sub(last) >= d.window {
+			delete(d.seen, key)
+		}
+	}
+}
+
+// Removed forgets a card, so presenting it again is a new event even within
+// the window. Use it when removals are detected reliably.
+func (d *Debouncer) Removed(id []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, uid.Hex(id))
+}
+
+// Reset forgets all cards
+func (d *Debouncer) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	clear(d.seen)
+}
+
+// Filter forwards the events of in whose card is accepted by the debouncer.
+// The returned channel is closed when in is closed.
+func Filter[E any](in <-chan E, d *Debouncer, id func(E) []byte) <-chan E {
+	out := make(chan E)
+	go func() {
+		defer close(out)
+		for e := range in {
+			if d.Accept(id(e)) {
+				out <- e
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,58 @@
+package debounce
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAccept(t *testing.T) {
+	d := NewDebouncer(2 * time.Second)
+	now := time.Unix(0, 0)
+	d.now = func() time.Time { return now }
+	id := []byte{0x04, 0xA1}
+
+	for i, step := range []struct {
+		after time.Duration
+		want  bool
+	}{
+		{0, true},
+		{time.Second, false},
+		{time.Second, false}, // held on the reader, the window restarts
+		{2 * time.Second, true},
+	} {
+		now = now.Add(step.after)
+		if got := d.Accept(id); got != step.want {
+			t.Fatalf("sighting %d: Accept = %t, want %t", i, got, step.want)
+		}
+	}
+	d.Removed(id)
+	if !d.Accept(id) {
+		t.Fatalf("Accept after Removed = false")
+	}
+}
+
+func TestAcceptConcurrent(t *testing.T) {
+	d := NewDebouncer(time.Hour)
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if d.Accept([]byte{0x04, byte(j)}) {
+					accepted.Add(1)
+				}
+				if j%10 == 0 {
+					d.Removed([]byte{0x05})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := accepted.Load(); n != 100 {
+		t.Fatalf("accepted %d sightings, want one per card", n)
+	}
+}
@@ -0,0 +1,76 @@
+package desfire
+
+import "fmt"
+
+// Access right values besides key numbers 0x0-0xD
+const (
+	AccessFree  = 0x0E // access without authentication
+	AccessNever = 0x0F // access denied
+)
+
+// AccessRights holds the keys granting access to a file. Each right is a key
+// number of the application, AccessFree or AccessNever.
+type AccessRights struct {
+	Read         byte
+	Write        byte
+	ReadWrite    byte
+	ChangeAccess byte
+}
+
+// FreeAccess grants every operation on a file without authentication
+var FreeAccess = AccessRights{
+	Read:         AccessFree,
+	Write:        AccessFree,
+	ReadWrite:    AccessFree,
+	ChangeAccess: AccessFree,
+}
+
+// Encode returns the 2-byte wire format of the access rights
+func (ar AccessRights) Encode() []byte {
+	return []byte{
+		(ar.ReadWrite&0x0F)<<4 | ar.ChangeAccess&0x0F,
+		(ar.Read&0x0F)<<4 | ar.Write&0x0F,
+	}
+}
+
+// DecodeAccessRights parses the 2-byte wire format of access rights
+func DecodeAccessRights(b []byte) (AccessRights, error) {
+	if len(b) != 2 {
+		return AccessRights{}, fmt.Errorf("access rights must be 2 bytes")
+	}
+	return AccessRights{
+		Read:         b[1] >> 4,
+		Write:        b[1] & 0x0F,
+		ReadWrite:    b[0] >> 4,
+		ChangeAccess: b[0] & 0x0F,
+	}, nil
+}
+
+// IsFree reports whether reading (or writing) is possible without
+// authentication. Such access is always in plain communication mode.
+func (ar AccessRights) IsFree(write bool) bool {
+	if ar.ReadWrite == AccessFree {
+		return true
+	}
+	if write {
+		return ar.Write == AccessFree
+	}
+	return ar.Read == AccessFree
+}
+
+func (ar AccessRights) String() string {
+	return fmt.Sprintf("R:%s W:%s RW:%s C:%s",
+		accessString(ar.Read), accessString(ar.Write),
+		accessString(ar.ReadWrite), accessString(ar.ChangeAccess))
+}
+
+func accessString(right byte) string {
+	switch right {
+	case AccessFree:
+		return "free"
+	case AccessNever:
+		return "never"
+	default:
+		return fmt.Sprintf("key%d", right)
+	}
+}
@@ -0,0 +1,185 @@
+package desfire
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/oo-developer/acr122u/internal/cmac"
+)
+
+// Helper functions for cryptography
+
+// normalizeKey returns a copy of key in the form the cipher for keyType
+// expects: single DES keys are doubled to 16 bytes, all others are checked
+// for their expected length
+func normalizeKey(keyType byte, key []byte) ([]byte, error) {
+	switch keyType {
+	case KeyTypeDES:
+		if len(key) == 8 {
+			return append(append([]byte{}, key...), key...), nil
+		}
+		if len(key) != 16 {
+			return nil, fmt.Errorf("DES key must be 8 or 16 bytes")
+		}
+	case KeyType3DES:
+		if len(key) != 16 {
+			return nil, fmt.Errorf("3DES key must be 16 bytes")
+		}
+	case KeyType3K3DES:
+		if len(key) != 24 {
+			return nil, fmt.Errorf("3K3DES key must be 24 bytes")
+		}
+	case KeyTypeAES:
+		if len(key) != 16 {
+			return nil, fmt.Errorf("AES key must be 16 bytes")
+		}
+	default:
+		return nil, fmt.Errorf("unknown key type: 0x%02X", keyType)
+	}
+	return append([]byte{}, key...), nil
+}
+
+// isSingleDES reports whether a 16-byte key has identical halves, ignoring
+// the parity bits that carry the key version
+func isSingleDES(key []byte) bool {
+	for i := 0; i < 8; i++ {
+		if key[i]&0xFE != key[i+8]&0xFE {
+			return false
+		}
+	}
+	return true
+}
+
+// newCipher creates the block cipher for a key of the given type
+func newCipher(keyType byte, key []byte) (cipher.Block, error) {
+	key, err := normalizeKey(keyType, key)
+	if err != nil {
+		return nil, err
+	}
+	if keyType == KeyTypeAES {
+		return aes.NewCipher(key)
+	}
+	if len(key) == 16 {
+		// 2-key 3DES is 3-key 3DES with K3 = K1
+		key = append(key, key[:8]...)
+	}
+	return des.NewTripleDESCipher(key)
+}
+
+// deriveSessionKey builds the session key from both random numbers of a
+// successful authentication
+func deriveSessionKey(keyType byte, rndA []byte, rndB []byte) []byte {
+	var sk []byte
+	switch keyType {
+	case KeyTypeDES:
+		sk = append(sk, rndA[0:4]...)
+		sk = append(sk, rndB[0:4]...)
+		sk = append(sk, sk...)
+	case KeyType3DES:
+		sk = append(sk, rndA[0:4]...)
+		sk = append(sk, rndB[0:4]...)
+		sk = append(sk, rndA[4:8]...)
+		sk = append(sk, rndB[4:8]...)
+	case KeyType3K3DES:
+		sk = append(sk, rndA[0:4]...)
+		sk = append(sk, rndB[0:4]...)
+		sk = append(sk, rndA[6:10]...)
+		sk = append(sk, rndB[6:10]...)
+		sk = append(sk, rndA[12:16]...)
+		sk = append(sk, rndB[12:16]...)
+	case KeyTypeAES:
+		sk = append(sk, rndA[0:4]...)
+		sk = append(sk, rndB[0:4]...)
+		sk = append(sk, rndA[12:16]...)
+		sk = append(sk, rndB[12:16]...)
+	}
+	return sk
+}
+
+// setKeyVersion stores the key version in the parity bits of a DES/3DES key.
+// For 3DES keys the second half gets the inverted bits so the key can't
+// collapse into single DES.
+func setKeyVersion(keyType byte, key []byte, version byte) {
+	if keyType == KeyTypeAES {
+		return
+	}
+	for n := 0; n < 8; n++ {
+		bit := (version >> (7 - n)) & 0x01
+		key[n] = (key[n] & 0xFE) | bit
+		if keyType == KeyTypeDES {
+			key[n+8] = key[n]
+		} else {
+			key[n+8] = (key[n+8] & 0xFE) | (^bit & 0x01)
+		}
+	}
+}
+
+// crc32DESFire computes the CRC32 used by DESFire EV1 secure messaging
+// (IEEE polynomial, no final inversion)
+func crc32DESFire(data []byte) uint32 {
+	return ^crc32.ChecksumIEEE(data)
+}
+
+// appendCRC32 appends the little-endian CRC32 of data to dst
+func appendCRC32(dst []byte, data []byte) []byte {
+	return binary.LittleEndian.AppendUint32(dst, crc32DESFire(data))
+}
+
+// DiversifyAES derives the AES key of one card from a master key as in NXP
+// AN10922. The input is up to 31 bytes, usually the UID, the AID and a
+// system identifier.
+func DiversifyAES(masterKey []byte, input []byte) ([]byte, error) {
+	if len(input) == 0 || len(input) > 31 {
+		return nil, fmt.Errorf("diversification input must be 1 to 31 bytes")
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %v", err)
+	}
+	k1, k2 := cmac.Subkeys(block)
+	data := append([]byte{0x01}, input...)
+	subkey := k1
+	if len(data) < 32 {
+		data = append(data, 0x80)
+		data = append(data, make([]byte, 32-len(data))...)
+		subkey = k2
+	}
+	for i := range subkey {
+		data[16+i] ^= subkey[i]
+	}
+	return cbcEncrypt(block, make([]byte, 16), data)[16:], nil
+}
+
+func cbcEncrypt(block cipher.Block, iv []byte, data []byte) []byte {
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return out
+}
+
+func cbcDecrypt(block cipher.Block, iv []byte, data []byte) []byte {
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	return out
+}
+
+// padZero pads data with zero bytes to a multiple of blockSize
+func padZero(data []byte, blockSize int) []byte {
+	if rem := len(data) % blockSize; rem != 0 {
+		data = append(data, make([]byte, blockSize-rem)...)
+	}
+	return data
+}
+
+func rotateLeft(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	rotated := make([]byte, len(data))
+	copy(rotated, data[1:])
+	rotated[len(data)-1] = data[0]
+	return rotated
+}
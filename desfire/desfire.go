@@ -2,15 +2,14 @@ package desfire
 
 import (
 	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/des"
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"strings"
 
-	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/config"
 	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
 )
 
 // DESFire card command codes
@@ -40,6 +39,7 @@ const (
 	CmdDeleteFile             = 0xDF
 	CmdGetFileIDs             = 0x6F
 	CmdGetFileSettings        = 0xF5
+	CmdChangeFileSettings     = 0x5F
 
 	// Data manipulation
 	CmdReadData          = 0xBD
@@ -59,6 +59,9 @@ const (
 	CmdGetKeySettings    = 0x45
 	CmdSetConfiguration  = 0x5C
 
+	// Originality
+	CmdReadSignature = 0x3C
+
 	// Additional frame
 	CmdAdditionalFrame = 0xAF
 )
@@ -91,6 +94,32 @@ const (
 	KeyTypeAES    = 0x03
 )
 
+// ConfigKey resolves a configured key reference with the keys package and
+// returns the key and its key type, ready for Authenticate
+func ConfigKey(cfg *config.Config, name string) ([]byte, byte, error) {
+	k, err := cfg.DESFireKey(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := k.Size()
+	if err != nil {
+		return nil, 0, err
+	}
+	key, err := keys.NewResolver(cfg).Resolve(k.Ref, size)
+	if err != nil {
+		return nil, 0, fmt.Errorf("desfire key %s: %v", name, err)
+	}
+	switch strings.ToLower(k.Type) {
+	case "des":
+		return key, KeyTypeDES, nil
+	case "2k3des", "3des":
+		return key, KeyType3DES, nil
+	case "3k3des":
+		return key, KeyType3K3DES, nil
+	}
+	return key, KeyTypeAES, nil
+}
+
 // Communication modes
 const (
 	CommModePlain = 0x00
@@ -98,17 +127,28 @@ const (
 	CommModeFull  = 0x03
 )
 
+// File types
+const (
+	FileTypeStandardData = 0x00
+	FileTypeBackupData   = 0x01
+	FileTypeValue        = 0x02
+	FileTypeLinearRecord = 0x03
+	FileTypeCyclicRecord = 0x04
+)
+
 // DESFire card structure
 type DESFire struct {
-	card    *scard.Card
-	ctx     *scard.Context
+	card    hardware.Transport
 	reader  string
+	aid     []byte
+	files   map[byte]*FileSettings
 	session *SessionKey
 }
 
 // SessionKey holds the session encryption keys
 type SessionKey struct {
 	keyType       byte
+	keyNo         byte
 	key           []byte
 	sessionKey    []byte
 	sessionKeyMAC []byte
@@ -116,17 +156,110 @@ type SessionKey struct {
 	cmdCounter    uint16
 }
 
+// FileSettings describes a file as reported by GetFileSettings. Only the
+// fields matching FileType are set.
+type FileSettings struct {
+	FileType     byte
+	CommMode     byte
+	AccessRights AccessRights
+
+	// Data files
+	FileSize int
+
+	// Value files
+	LowerLimit           int32
+	UpperLimit           int32
+	LimitedCreditValue   int32
+	LimitedCreditEnabled bool
+
+	// Record files
+	RecordSize     int
+	MaxRecords     int
+	CurrentRecords int
+}
+
 // NewDESFire creates a new DESFire card instance
 func NewDESFire(reader *hardware.Reader) *DESFire {
 	return &DESFire{
-		card:   reader.Card(),
-		ctx:    reader.Ctx(),
+		card:   reader.Transport(),
 		reader: reader.Reader(),
 	}
 }
 
-// Transceive sends a command and receives response
+// NewDESFireWithTransport creates a DESFire card instance on top of any
+// transport, e.g. a simulator
+func NewDESFireWithTransport(transport hardware.Transport) *DESFire {
+	return &DESFire{
+		card: transport,
+	}
+}
+
+// Transceive sends a command and receives the complete response. Additional
+// frames are requested until the card has sent everything. Once a session is
+// authenticated, the command and response are protected with the
+// communication mode the command (or the file it accesses) requires.
 func (df *DESFire) Transceive(cmd []byte) ([]byte, error) {
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	switch cmd[0] {
+	case CmdAuthenticateLegacy, CmdAuthenticateISO, CmdAuthenticateAES, CmdAuthenticateEV2First, CmdAdditionalFrame:
+		// Authentication frames are exchanged one at a time by the caller
+		resp, _, err := df.exchange(cmd)
+		return resp, err
+	}
+
+	settings, err := df.commSettingsFor(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return df.transceive(cmd, settings)
+}
+
+// transceive protects cmd according to settings, sends it (split into
+// additional frames if necessary), collects all response frames and verifies
+// or deciphers the response
+func (df *DESFire) transceive(cmd []byte, settings commSettings) ([]byte, error) {
+	frame, err := df.protectCommand(cmd, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the command, continuing long commands in additional frames
+	var resp []byte
+	var status byte
+	for {
+		n := min(len(frame)-1, maxFrameData)
+		resp, status, err = df.exchange(frame[:n+1])
+		if err != nil {
+			return nil, err
+		}
+		if n == len(frame)-1 {
+			break
+		}
+		if status != StatusAdditionalFrame {
+			return nil, fmt.Errorf("card did not accept additional frame: 0x%02X", status)
+		}
+		frame = append([]byte{CmdAdditionalFrame}, frame[n+1:]...)
+	}
+
+	// Collect the remaining response frames
+	for status == StatusAdditionalFrame {
+		var more []byte
+		more, status, err = df.exchange([]byte{CmdAdditionalFrame})
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, more...)
+	}
+
+	return df.verifyResponse(resp, status, settings)
+}
+
+// exchange wraps a single DESFire frame in an ISO 7816-4 APDU, sends it and
+// returns the response data and the DESFire status
+func (df *DESFire) exchange(cmd []byte) ([]byte, byte, error) {
 	// Wrap command in ISO 7816-4 APDU format
 	apdu := make([]byte, 0, len(cmd)+5)
 	apdu = append(apdu, 0x90)   // CLA
@@ -145,11 +278,13 @@ func (df *DESFire) Transceive(cmd []byte) ([]byte, error) {
 
 	response, err := df.card.Transmit(apdu)
 	if err != nil {
-		return nil, fmt.Errorf("transmit error: %w", err)
+		// The card may have executed the command and advanced its IV
+		df.session = nil
+		return nil, 0, fmt.Errorf("transmit error: %w", err)
 	}
 
 	if len(response) < 2 {
-		return nil, fmt.Errorf("response too short: %d bytes", len(response))
+		return nil, 0, fmt.Errorf("response too short: %d bytes: %w", len(response), hardware.ErrWrongLength)
 	}
 
 	// Check status bytes (last 2 bytes)
@@ -159,46 +294,26 @@ func (df *DESFire) Transceive(cmd []byte) ([]byte, error) {
 	// Handle DESFire status codes wrapped in ISO 7816 format
 	if sw1 == 0x91 {
 		if sw2 != StatusSuccess && sw2 != StatusAdditionalFrame {
-			return nil, fmt.Errorf("DESFire error: 0x%02X", sw2)
+			// Any error ends the authenticated state on the card
+			df.session = nil
+			return nil, 0, fmt.Errorf("DESFire error: %w", hardware.NewStatusError(apdu, response))
 		}
-		return response[:len(response)-2], nil
+		return response[:len(response)-2], sw2, nil
 	}
 
 	if sw1 == 0x90 && sw2 == 0x00 {
 		// ISO success
-		return response[:len(response)-2], nil
+		return response[:len(response)-2], StatusSuccess, nil
 	}
 
-	return nil, fmt.Errorf("card error: SW1=0x%02X SW2=0x%02X", sw1, sw2)
+	df.session = nil
+	return nil, 0, fmt.Errorf("card error: %w", hardware.NewStatusError(apdu, response))
 }
 
 // GetVersion retrieves the card version information
 func (df *DESFire) GetVersion() ([]byte, error) {
-	// GetVersion requires 3 sequential commands
-	var fullVersion []byte
-
-	// First call
-	resp, err := df.Transceive([]byte{CmdGetVersion})
-	if err != nil {
-		return nil, err
-	}
-	fullVersion = append(fullVersion, resp...)
-
-	// Second call
-	resp, err = df.Transceive([]byte{CmdAdditionalFrame})
-	if err != nil {
-		return nil, err
-	}
-	fullVersion = append(fullVersion, resp...)
-
-	// Third call
-	resp, err = df.Transceive([]byte{CmdAdditionalFrame})
-	if err != nil {
-		return nil, err
-	}
-	fullVersion = append(fullVersion, resp...)
-
-	return fullVersion, nil
+	// The version is sent in three frames which Transceive collects
+	return df.Transceive([]byte{CmdGetVersion})
 }
 
 // GetUID retrieves the card UID from version info
@@ -216,6 +331,19 @@ func (df *DESFire) GetUID() ([]byte, error) {
 	return nil, fmt.Errorf("version response too short")
 }
 
+// ReadSignature reads the 56 byte originality signature of DESFire EV2 and
+// later, the ECDSA signature of the UID by NXP. EV1 cards do not support it.
+func (df *DESFire) ReadSignature() ([]byte, error) {
+	signature, err := df.Transceive([]byte{CmdReadSignature, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	if len(signature) != 56 {
+		return nil, fmt.Errorf("signature has %d bytes, expected 56", len(signature))
+	}
+	return signature, nil
+}
+
 // SelectApplication selects an application by AID
 func (df *DESFire) SelectApplication(aid []byte) error {
 	if len(aid) != 3 {
@@ -224,7 +352,37 @@ func (df *DESFire) SelectApplication(aid []byte) error {
 
 	cmd := append([]byte{CmdSelectApplication}, aid...)
 	_, err := df.Transceive(cmd)
-	return err
+
+	// Selecting, even the same application or a failed selection, ends the
+	// authentication on the card
+	df.session = nil
+	if err != nil {
+		return err
+	}
+	df.aid = append([]byte{}, aid...)
+	df.files = nil
+	return nil
+}
+
+// AuthState describes the authentication of the current session
+type AuthState struct {
+	Authenticated bool
+	AID           []byte // selected application, nil or 000000 for the PICC level
+	KeyNo         byte
+	KeyType       byte
+}
+
+// AuthState reports whether the session is authenticated and with which key.
+// The state is cleared by SelectApplication, a new authentication, changing
+// the session key and any error returned by the card.
+func (df *DESFire) AuthState() AuthState {
+	state := AuthState{AID: append([]byte(nil), df.aid...)}
+	if df.session != nil {
+		state.Authenticated = true
+		state.KeyNo = df.session.keyNo
+		state.KeyType = df.session.keyType
+	}
+	return state
 }
 
 // GetApplicationIDs retrieves all application IDs
@@ -244,159 +402,243 @@ func (df *DESFire) GetApplicationIDs() ([][]byte, error) {
 	return aids, nil
 }
 
+// Authenticate authenticates with a key of the given type, dispatching to
+// AuthenticateAES or Authenticate3DES. Single DES keys may be given as 8 bytes.
+func (df *DESFire) Authenticate(keyNo byte, keyType byte, key []byte) error {
+	key, err := normalizeKey(keyType, key)
+	if err != nil {
+		return err
+	}
+	if keyType == KeyTypeAES {
+		return df.AuthenticateAES(keyNo, key)
+	}
+	return df.Authenticate3DES(keyNo, key)
+}
+
 // AuthenticateAES performs AES authentication with the card
 func (df *DESFire) AuthenticateAES(keyNo byte, key []byte) error {
 	if len(key) != 16 {
 		return fmt.Errorf("AES key must be 16 bytes")
 	}
+	return df.authenticate(CmdAuthenticateAES, KeyTypeAES, keyNo, key)
+}
+
+// Authenticate3DES performs ISO 3DES authentication. A 16-byte key is used as
+// 2-key 3DES, a 24-byte key as 3-key 3DES (3K3DES).
+func (df *DESFire) Authenticate3DES(keyNo byte, key []byte) error {
+	switch len(key) {
+	case 16:
+		// Identical halves (ignoring the parity bits) make a single DES key
+		if isSingleDES(key) {
+			return df.authenticate(CmdAuthenticateISO, KeyTypeDES, keyNo, key)
+		}
+		return df.authenticate(CmdAuthenticateISO, KeyType3DES, keyNo, key)
+	case 24:
+		return df.authenticate(CmdAuthenticateISO, KeyType3K3DES, keyNo, key)
+	default:
+		return fmt.Errorf("3DES key must be 16 or 24 bytes")
+	}
+}
+
+// AuthenticateCipher authenticates with a key that is used through a cipher
+// instead of being at hand, such as a key kept in an HSM. keyType selects the
+// authentication: KeyTypeAES for AES, and ISO authentication for the others.
+// The key itself never passes this package; the session key derived from
+// the authentication is held in memory like for other keys.
+func (df *DESFire) AuthenticateCipher(keyNo byte, keyType byte, c keys.Cipher) error {
+	switch keyType {
+	case KeyTypeAES:
+		return df.authenticateCipher(CmdAuthenticateAES, keyType, keyNo, c)
+	case KeyTypeDES, KeyType3DES, KeyType3K3DES:
+		return df.authenticateCipher(CmdAuthenticateISO, keyType, keyNo, c)
+	}
+	return fmt.Errorf("unknown key type %d", keyType)
+}
+
+// authenticate runs the authentication with a key in memory
+func (df *DESFire) authenticate(authCmd byte, keyType byte, keyNo byte, key []byte) error {
+	block, err := newCipher(keyType, key)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	if err := df.authenticateCipher(authCmd, keyType, keyNo, keys.BlockCipher(block)); err != nil {
+		return err
+	}
+	df.session.key = key
+	return nil
+}
+
+// authenticateCipher runs the three-pass mutual authentication shared by the
+// ISO and AES commands and derives the session key on success
+func (df *DESFire) authenticateCipher(authCmd byte, keyType byte, keyNo byte, block keys.Cipher) error {
+	blockSize := block.BlockSize()
+	wantBlockSize := 8
+	if keyType == KeyTypeAES {
+		wantBlockSize = 16
+	}
+	if blockSize != wantBlockSize {
+		return fmt.Errorf("cipher block size %d does not fit key type %d", blockSize, keyType)
+	}
+
+	// RndA and RndB are 8 bytes for 2-key 3DES, 16 bytes for 3K3DES and AES
+	rndSize := 16
+	if keyType == KeyTypeDES || keyType == KeyType3DES {
+		rndSize = 8
+	}
+
+	// A new authentication always ends the current session
+	df.session = nil
 
 	// Step 1: Send authenticate command with key number
-	cmd := []byte{CmdAuthenticateAES, keyNo}
-	resp, err := df.Transceive(cmd)
+	resp, err := df.Transceive([]byte{authCmd, keyNo})
 	if err != nil {
 		return fmt.Errorf("authenticate step 1 failed: %w", err)
 	}
 
-	if len(resp) < 16 {
+	if len(resp) < rndSize {
 		return fmt.Errorf("encrypted RndB too short: %d bytes", len(resp))
 	}
 
-	encRndB := resp[:16]
+	encRndB := resp[:rndSize]
 
 	// Step 2: Decrypt RndB
-	rndB, err := decryptAES(encRndB, key)
+	rndB, err := block.DecryptCBC(make([]byte, blockSize), encRndB)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt RndB: %w", err)
 	}
 
 	// Step 3: Generate RndA
-	rndA := make([]byte, 16)
+	rndA := make([]byte, rndSize)
 	if _, err := rand.Read(rndA); err != nil {
 		return fmt.Errorf("failed to generate RndA: %w", err)
 	}
 
-	// Step 4: Rotate RndB left by 1 byte
-	rndBRotated := rotateLeft(rndB)
-
-	// Step 5: Concatenate RndA + RndB' and encrypt
-	data := append(rndA, rndBRotated...)
-	encData, err := encryptAES(data, key)
+	// Step 4: Concatenate RndA + RndB' (RndB rotated left by 1 byte) and
+	// encrypt, chaining from the last block received from the card
+	data := append(append([]byte{}, rndA...), rotateLeft(rndB)...)
+	encData, err := block.EncryptCBC(encRndB[rndSize-blockSize:], data)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt data: %w", err)
+		return fmt.Errorf("failed to encrypt RndA: %w", err)
 	}
 
-	// Step 6: Send encrypted data
-	cmd = append([]byte{CmdAdditionalFrame}, encData...)
+	// Step 5: Send encrypted data
+	cmd := append([]byte{CmdAdditionalFrame}, encData...)
 	resp, err = df.Transceive(cmd)
 	if err != nil {
 		return fmt.Errorf("authenticate step 2 failed: %w", err)
 	}
 
-	if len(resp) < 16 {
+	if len(resp) < rndSize {
 		return fmt.Errorf("encrypted RndA' too short: %d bytes", len(resp))
 	}
 
-	// Step 7: Decrypt and verify RndA'
-	encRndARotated := resp[:16]
-	rndARotatedDecrypted, err := decryptAES(encRndARotated, key)
+	// Step 6: Decrypt and verify RndA'
+	rndARotated, err := block.DecryptCBC(encData[len(encData)-blockSize:], resp[:rndSize])
 	if err != nil {
 		return fmt.Errorf("failed to decrypt RndA': %w", err)
 	}
-
-	// Rotate RndA left to compare
-	rndARotated := rotateLeft(rndA)
-	if !bytes.Equal(rndARotated, rndARotatedDecrypted) {
+	if !bytes.Equal(rotateLeft(rndA), rndARotated) {
 		return fmt.Errorf("authentication failed: RndA mismatch")
 	}
 
-	// Generate session keys
 	df.session = &SessionKey{
-		keyType:    KeyTypeAES,
-		key:        key,
-		iv:         make([]byte, 16),
+		keyType:    keyType,
+		keyNo:      keyNo,
+		sessionKey: deriveSessionKey(keyType, rndA, rndB),
+		iv:         make([]byte, blockSize),
 		cmdCounter: 0,
 	}
 
-	// Session key derivation for AES (simplified)
-	df.session.sessionKey = make([]byte, 16)
-	copy(df.session.sessionKey, key) // In production, derive properly from RndA and RndB
-
 	return nil
 }
 
-// Authenticate3DES performs 3DES authentication (legacy)
-func (df *DESFire) Authenticate3DES(keyNo byte, key []byte) error {
-	if len(key) != 16 && len(key) != 24 {
-		return fmt.Errorf("3DES key must be 16 or 24 bytes")
+// ChangeKey changes key keyNo of the selected application, or the PICC master
+// key when the PICC level is selected. The current session must be
+// authenticated; oldKey is only needed when keyNo is not the key the session
+// was authenticated with. keyType selects how newKey is interpreted:
+// KeyTypeDES (8 bytes), KeyType3DES (16 bytes), KeyType3K3DES (24 bytes) or
+// KeyTypeAES (16 bytes).
+func (df *DESFire) ChangeKey(keyNo byte, keyType byte, newKey []byte, keyVersion byte, oldKey []byte) error {
+	if df.session == nil {
+		return fmt.Errorf("not authenticated")
 	}
 
-	// Similar to AES but using 3DES and 8-byte blocks
-	cmd := []byte{CmdAuthenticateISO, keyNo}
-	resp, err := df.Transceive(cmd)
+	keyData, err := normalizeKey(keyType, newKey)
 	if err != nil {
-		return fmt.Errorf("authenticate step 1 failed: %w", err)
+		return fmt.Errorf("invalid new key: %w", err)
 	}
-
-	if len(resp) < 8 {
-		return fmt.Errorf("encrypted RndB too short: %d bytes", len(resp))
-	}
-
-	encRndB := resp[:8]
-
-	// Decrypt RndB
-	rndB, err := decrypt3DES(encRndB, key)
-	if err != nil {
-		return fmt.Errorf("failed to decrypt RndB: %w", err)
+	if keyType == KeyTypeAES {
+		keyData = append(keyData, keyVersion)
+	} else {
+		setKeyVersion(keyType, keyData, keyVersion)
+	}
+	// The CRC over the new key never covers the AES version byte
+	newKeyLen := len(keyData)
+	if keyType == KeyTypeAES {
+		newKeyLen--
+	}
+
+	// Changing the PICC master key also selects its key type
+	cmdKeyNo := keyNo
+	if df.isPICCSelected() && keyNo == 0x00 {
+		switch keyType {
+		case KeyType3K3DES:
+			cmdKeyNo |= 0x40
+		case KeyTypeAES:
+			cmdKeyNo |= 0x80
+		}
 	}
 
-	// Generate RndA (8 bytes for DES/3DES)
-	rndA := make([]byte, 8)
-	if _, err := rand.Read(rndA); err != nil {
-		return fmt.Errorf("failed to generate RndA: %w", err)
+	plain := append([]byte{}, keyData...)
+	sameKey := keyNo&0x0F == df.session.keyNo&0x0F
+	if !sameKey {
+		if oldKey == nil {
+			return fmt.Errorf("old key required to change key %d", keyNo)
+		}
+		oldData, err := normalizeKey(keyType, oldKey)
+		if err != nil {
+			return fmt.Errorf("invalid old key: %w", err)
+		}
+		for i := range oldData {
+			plain[i] ^= oldData[i]
+		}
 	}
-
-	// Rotate RndB left by 1 byte
-	rndBRotated := rotateLeft(rndB)
-
-	// Concatenate and encrypt
-	data := append(rndA, rndBRotated...)
-	encData, err := encrypt3DES(data, key)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt data: %w", err)
+	plain = appendCRC32(plain, append([]byte{CmdChangeKey, cmdKeyNo}, plain...))
+	if !sameKey {
+		plain = appendCRC32(plain, keyData[:newKeyLen])
 	}
 
-	// Send encrypted data
-	cmd = append([]byte{CmdAdditionalFrame}, encData...)
-	resp, err = df.Transceive(cmd)
+	block, err := newCipher(df.session.keyType, df.session.sessionKey)
 	if err != nil {
-		return fmt.Errorf("authenticate step 2 failed: %w", err)
+		return fmt.Errorf("invalid session key: %w", err)
 	}
+	plain = padZero(plain, block.BlockSize())
+	cryptogram := cbcEncrypt(block, df.session.iv, plain)
+	copy(df.session.iv, cryptogram[len(cryptogram)-block.BlockSize():])
 
-	// Verify response
-	if len(resp) < 8 {
-		return fmt.Errorf("encrypted RndA' too short: %d bytes", len(resp))
+	// The cryptogram already carries the protection; when the session key
+	// itself is changed the card answers without a MAC
+	settings := commSettings{cmd: commModeNone, resp: CommModePlain}
+	if sameKey {
+		settings.resp = commModeNone
 	}
-
-	rndARotatedDecrypted, err := decrypt3DES(resp[:8], key)
-	if err != nil {
-		return fmt.Errorf("failed to decrypt RndA': %w", err)
+	cmd := append([]byte{CmdChangeKey, cmdKeyNo}, cryptogram...)
+	if _, err := df.transceive(cmd, settings); err != nil {
+		return fmt.Errorf("change key failed: %w", err)
 	}
 
-	rndARotated := rotateLeft(rndA)
-	if !bytes.Equal(rndARotated, rndARotatedDecrypted) {
-		return fmt.Errorf("authentication failed: RndA mismatch")
+	// Changing the key of the current session ends the authentication
+	if sameKey {
+		df.session = nil
 	}
-
-	df.session = &SessionKey{
-		keyType:    KeyType3DES,
-		key:        key,
-		iv:         make([]byte, 8),
-		cmdCounter: 0,
-	}
-
 	return nil
 }
 
+// isPICCSelected reports whether the PICC level (AID 000000) is selected
+func (df *DESFire) isPICCSelected() bool {
+	return df.aid == nil || bytes.Equal(df.aid, []byte{0x00, 0x00, 0x00})
+}
+
 // CreateApplication creates a new application
 func (df *DESFire) CreateApplication(aid []byte, keySetting byte, numKeys byte) error {
 	if len(aid) != 3 {
@@ -412,6 +654,42 @@ func (df *DESFire) CreateApplication(aid []byte, keySetting byte, numKeys byte)
 	return err
 }
 
+// CreateISOApplication creates an application that can also be selected
+// with ISO SELECT by its file ID and DF name
+func (df *DESFire) CreateISOApplication(aid []byte, keySetting byte, numKeys byte, isoFileID uint16, dfName []byte) error {
+	if len(aid) != 3 {
+		return fmt.Errorf("AID must be 3 bytes")
+	}
+	if len(dfName) > 16 {
+		return fmt.Errorf("DF name must be at most 16 bytes")
+	}
+
+	// Bit 5 of the key count announces the ISO file ID
+	cmd := []byte{CmdCreateApplication}
+	cmd = append(cmd, aid...)
+	cmd = append(cmd, keySetting, numKeys|0x20)
+	cmd = binary.LittleEndian.AppendUint16(cmd, isoFileID)
+	cmd = append(cmd, dfName...)
+
+	_, err := df.Transceive(cmd)
+	return err
+}
+
+// GetKeyVersion retrieves the version of a key of the selected application
+// (or the PICC master key at PICC level)
+func (df *DESFire) GetKeyVersion(keyNo byte) (byte, error) {
+	resp, err := df.Transceive([]byte{CmdGetKeyVersion, keyNo})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(resp) < 1 {
+		return 0, fmt.Errorf("key version response empty")
+	}
+
+	return resp[0], nil
+}
+
 // DeleteApplication deletes an application
 func (df *DESFire) DeleteApplication(aid []byte) error {
 	if len(aid) != 3 {
@@ -423,152 +701,263 @@ func (df *DESFire) DeleteApplication(aid []byte) error {
 	return err
 }
 
-// ReadData reads data from a standard data file
-func (df *DESFire) ReadData(fileNo byte, offset int, length int) ([]byte, error) {
-	cmd := []byte{CmdReadData, fileNo}
+// CreateStdDataFile creates a standard data file of size bytes
+func (df *DESFire) CreateStdDataFile(fileNo byte, commMode byte, access AccessRights, size int) error {
+	return df.createDataFile(CmdCreateStdDataFile, fileNo, commMode, access, size)
+}
 
-	// Add offset (3 bytes, little-endian)
-	offsetBytes := make([]byte, 3)
-	binary.LittleEndian.PutUint32(append(offsetBytes, 0), uint32(offset))
-	cmd = append(cmd, offsetBytes[:3]...)
+// CreateBackupDataFile creates a backup data file of size bytes. Writes take
+// effect with CommitTransaction.
+func (df *DESFire) CreateBackupDataFile(fileNo byte, commMode byte, access AccessRights, size int) error {
+	return df.createDataFile(CmdCreateBackupDataFile, fileNo, commMode, access, size)
+}
 
-	// Add length (3 bytes, little-endian)
-	lengthBytes := make([]byte, 3)
-	binary.LittleEndian.PutUint32(append(lengthBytes, 0), uint32(length))
-	cmd = append(cmd, lengthBytes[:3]...)
+// CreateISOStdDataFile creates a standard data file that can also be
+// selected with ISO SELECT by its file ID. The application must have been
+// created with ISO file IDs.
+func (df *DESFire) CreateISOStdDataFile(fileNo byte, isoFileID uint16, commMode byte, access AccessRights, size int) error {
+	cmd := []byte{CmdCreateStdDataFile, fileNo}
+	cmd = binary.LittleEndian.AppendUint16(cmd, isoFileID)
+	cmd = append(cmd, commMode)
+	cmd = append(cmd, access.Encode()...)
+	cmd = appendUint24(cmd, size)
 
-	return df.Transceive(cmd)
+	_, err := df.Transceive(cmd)
+	return err
 }
 
-// WriteData writes data to a standard data file
-func (df *DESFire) WriteData(fileNo byte, offset int, data []byte) error {
-	cmd := []byte{CmdWriteData, fileNo}
+func (df *DESFire) createDataFile(cmdCode byte, fileNo byte, commMode byte, access AccessRights, size int) error {
+	cmd := []byte{cmdCode, fileNo, commMode}
+	cmd = append(cmd, access.Encode()...)
+	cmd = appendUint24(cmd, size)
 
-	// Add offset (3 bytes, little-endian)
-	offsetBytes := make([]byte, 3)
-	binary.LittleEndian.PutUint32(append(offsetBytes, 0), uint32(offset))
-	cmd = append(cmd, offsetBytes[:3]...)
+	_, err := df.Transceive(cmd)
+	return err
+}
 
-	// Add length (3 bytes, little-endian)
-	lengthBytes := make([]byte, 3)
-	binary.LittleEndian.PutUint32(append(lengthBytes, 0), uint32(len(data)))
-	cmd = append(cmd, lengthBytes[:3]...)
+// CreateValueFile creates a value file holding value within the lower and
+// upper limit
+func (df *DESFire) CreateValueFile(fileNo byte, commMode byte, access AccessRights, lower int32, upper int32, value int32, limitedCredit bool) error {
+	cmd := []byte{CmdCreateValueFile, fileNo, commMode}
+	cmd = append(cmd, access.Encode()...)
+	cmd = binary.LittleEndian.AppendUint32(cmd, uint32(lower))
+	cmd = binary.LittleEndian.AppendUint32(cmd, uint32(upper))
+	cmd = binary.LittleEndian.AppendUint32(cmd, uint32(value))
+	if limitedCredit {
+		cmd = append(cmd, 0x01)
+	} else {
+		cmd = append(cmd, 0x00)
+	}
 
-	// Add data
-	cmd = append(cmd, data...)
+	_, err := df.Transceive(cmd)
+	return err
+}
+
+// CreateLinearRecordFile creates a record file that holds up to maxRecords
+// records of recordSize bytes
+func (df *DESFire) CreateLinearRecordFile(fileNo byte, commMode byte, access AccessRights, recordSize int, maxRecords int) error {
+	return df.createRecordFile(CmdCreateLinearRecordFile, fileNo, commMode, access, recordSize, maxRecords)
+}
+
+// CreateCyclicRecordFile creates a record file that overwrites the oldest of
+// its maxRecords records once full
+func (df *DESFire) CreateCyclicRecordFile(fileNo byte, commMode byte, access AccessRights, recordSize int, maxRecords int) error {
+	return df.createRecordFile(CmdCreateCyclicRecordFile, fileNo, commMode, access, recordSize, maxRecords)
+}
+
+func (df *DESFire) createRecordFile(cmdCode byte, fileNo byte, commMode byte, access AccessRights, recordSize int, maxRecords int) error {
+	cmd := []byte{cmdCode, fileNo, commMode}
+	cmd = append(cmd, access.Encode()...)
+	cmd = appendUint24(cmd, recordSize)
+	cmd = appendUint24(cmd, maxRecords)
 
 	_, err := df.Transceive(cmd)
 	return err
 }
 
-// Helper functions for cryptography
+// GetFileIDs retrieves the file numbers of the selected application
+func (df *DESFire) GetFileIDs() ([]byte, error) {
+	return df.Transceive([]byte{CmdGetFileIDs})
+}
 
-func encryptAES(data []byte, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+// GetFileSettings retrieves the settings of a file in the selected application
+func (df *DESFire) GetFileSettings(fileNo byte) (*FileSettings, error) {
+	resp, err := df.Transceive([]byte{CmdGetFileSettings, fileNo})
 	if err != nil {
 		return nil, err
 	}
 
-	// Pad data to block size
-	data = padData(data, aes.BlockSize)
+	if len(resp) < 4 {
+		return nil, fmt.Errorf("file settings too short: %d bytes", len(resp))
+	}
 
-	ciphertext := make([]byte, len(data))
-	iv := make([]byte, aes.BlockSize)
+	access, err := DecodeAccessRights(resp[2:4])
+	if err != nil {
+		return nil, err
+	}
+	fs := &FileSettings{
+		FileType:     resp[0],
+		CommMode:     resp[1] & 0x03,
+		AccessRights: access,
+	}
+	data := resp[4:]
 
-	mode := cipher.NewCBCEncrypter(block, iv)
-	mode.CryptBlocks(ciphertext, data)
+	switch fs.FileType {
+	case FileTypeStandardData, FileTypeBackupData:
+		if len(data) < 3 {
+			return nil, fmt.Errorf("data file settings too short")
+		}
+		fs.FileSize = int(uint24(data[0:3]))
+	case FileTypeValue:
+		if len(data) < 13 {
+			return nil, fmt.Errorf("value file settings too short")
+		}
+		fs.LowerLimit = int32(binary.LittleEndian.Uint32(data[0:4]))
+		fs.UpperLimit = int32(binary.LittleEndian.Uint32(data[4:8]))
+		fs.LimitedCreditValue = int32(binary.LittleEndian.Uint32(data[8:12]))
+		fs.LimitedCreditEnabled = data[12]&0x01 != 0
+	case FileTypeLinearRecord, FileTypeCyclicRecord:
+		if len(data) < 9 {
+			return nil, fmt.Errorf("record file settings too short")
+		}
+		fs.RecordSize = int(uint24(data[0:3]))
+		fs.MaxRecords = int(uint24(data[3:6]))
+		fs.CurrentRecords = int(uint24(data[6:9]))
+	default:
+		return nil, fmt.Errorf("unknown file type: 0x%02X", fs.FileType)
+	}
 
-	return ciphertext, nil
+	if df.files == nil {
+		df.files = make(map[byte]*FileSettings)
+	}
+	df.files[fileNo] = fs
+	return fs, nil
 }
 
-func decryptAES(data []byte, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+// ChangeFileSettings changes the communication mode and access rights of a
+// file. Unless changing the access rights is free, the session must be
+// authenticated with the change key and the new settings are sent enciphered.
+func (df *DESFire) ChangeFileSettings(fileNo byte, commMode byte, access AccessRights) error {
+	fs, err := df.fileSettings(fileNo)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	if len(data)%aes.BlockSize != 0 {
-		return nil, fmt.Errorf("ciphertext is not a multiple of block size")
+	if fs.AccessRights.ChangeAccess != AccessFree && df.session == nil {
+		return fmt.Errorf("authentication with key %d required", fs.AccessRights.ChangeAccess)
 	}
 
-	plaintext := make([]byte, len(data))
-	iv := make([]byte, aes.BlockSize)
+	cmd := []byte{CmdChangeFileSettings, fileNo, commMode}
+	cmd = append(cmd, access.Encode()...)
 
-	mode := cipher.NewCBCDecrypter(block, iv)
-	mode.CryptBlocks(plaintext, data)
+	_, err = df.Transceive(cmd)
+	// The cached settings are outdated either way
+	delete(df.files, fileNo)
+	return err
+}
 
-	return plaintext, nil
+// uint24 decodes a 3-byte little-endian value
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
 }
 
-func encrypt3DES(data []byte, key []byte) ([]byte, error) {
-	var block cipher.Block
-	var err error
+// appendUint24 appends v as a 3-byte little-endian value
+func appendUint24(b []byte, v int) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16))
+}
 
-	if len(key) == 16 {
-		// 2-key 3DES
-		block, err = des.NewTripleDESCipher(key)
-	} else if len(key) == 24 {
-		// 3-key 3DES
-		block, err = des.NewTripleDESCipher(key)
-	} else {
-		return nil, fmt.Errorf("invalid key length for 3DES")
-	}
+// ReadData reads data from a standard data file
+func (df *DESFire) ReadData(fileNo byte, offset int, length int) ([]byte, error) {
+	cmd := []byte{CmdReadData, fileNo}
 
-	if err != nil {
-		return nil, err
-	}
+	// Add offset and length (3 bytes each, little-endian)
+	cmd = appendUint24(cmd, offset)
+	cmd = appendUint24(cmd, length)
 
-	data = padData(data, des.BlockSize)
-	ciphertext := make([]byte, len(data))
-	iv := make([]byte, des.BlockSize)
+	return df.Transceive(cmd)
+}
 
-	mode := cipher.NewCBCEncrypter(block, iv)
-	mode.CryptBlocks(ciphertext, data)
+// WriteData writes data to a standard data file
+func (df *DESFire) WriteData(fileNo byte, offset int, data []byte) error {
+	cmd := []byte{CmdWriteData, fileNo}
 
-	return ciphertext, nil
-}
+	// Add offset and length (3 bytes each, little-endian)
+	cmd = appendUint24(cmd, offset)
+	cmd = appendUint24(cmd, len(data))
 
-func decrypt3DES(data []byte, key []byte) ([]byte, error) {
-	var block cipher.Block
-	var err error
+	// Add data
+	cmd = append(cmd, data...)
 
-	if len(key) == 16 {
-		block, err = des.NewTripleDESCipher(key)
-	} else if len(key) == 24 {
-		block, err = des.NewTripleDESCipher(key)
-	} else {
-		return nil, fmt.Errorf("invalid key length for 3DES")
-	}
+	_, err := df.Transceive(cmd)
+	return err
+}
 
+// GetValue reads the current value of a value file
+func (df *DESFire) GetValue(fileNo byte) (int32, error) {
+	resp, err := df.Transceive([]byte{CmdGetValue, fileNo})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	plaintext := make([]byte, len(data))
-	iv := make([]byte, des.BlockSize)
+	if len(resp) < 4 {
+		return 0, fmt.Errorf("value response too short: %d bytes", len(resp))
+	}
 
-	mode := cipher.NewCBCDecrypter(block, iv)
-	mode.CryptBlocks(plaintext, data)
+	return int32(binary.LittleEndian.Uint32(resp[:4])), nil
+}
 
-	return plaintext, nil
+// Credit increases the value of a value file. The change takes effect with
+// CommitTransaction.
+func (df *DESFire) Credit(fileNo byte, amount int32) error {
+	return df.changeValue(CmdCredit, fileNo, amount)
 }
 
-func padData(data []byte, blockSize int) []byte {
-	padding := blockSize - (len(data) % blockSize)
-	if padding == 0 {
-		padding = blockSize
-	}
+// Debit decreases the value of a value file. The change takes effect with
+// CommitTransaction.
+func (df *DESFire) Debit(fileNo byte, amount int32) error {
+	return df.changeValue(CmdDebit, fileNo, amount)
+}
 
-	padText := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(data, padText...)
+// LimitedCredit increases the value of a value file by at most the amount
+// debited in the last transaction. The change takes effect with
+// CommitTransaction.
+func (df *DESFire) LimitedCredit(fileNo byte, amount int32) error {
+	return df.changeValue(CmdLimitedCredit, fileNo, amount)
 }
 
-func rotateLeft(data []byte) []byte {
-	if len(data) == 0 {
-		return data
+func (df *DESFire) changeValue(cmdCode byte, fileNo byte, amount int32) error {
+	if amount < 0 {
+		return fmt.Errorf("amount must not be negative")
 	}
-	rotated := make([]byte, len(data))
-	copy(rotated, data[1:])
-	rotated[len(data)-1] = data[0]
-	return rotated
+
+	cmd := []byte{cmdCode, fileNo}
+	cmd = binary.LittleEndian.AppendUint32(cmd, uint32(amount))
+
+	_, err := df.Transceive(cmd)
+	return err
+}
+
+// CommitTransaction validates all pending changes to backup, value and record
+// files of the selected application
+func (df *DESFire) CommitTransaction() error {
+	_, err := df.Transceive([]byte{CmdCommitTransaction})
+	return err
+}
+
+// AbortTransaction discards all pending changes to backup, value and record
+// files of the selected application
+func (df *DESFire) AbortTransaction() error {
+	_, err := df.Transceive([]byte{CmdAbortTransaction})
+	return err
+}
+
+// WriteRecord appends data at offset to the current record of a record file.
+// The record is added with CommitTransaction.
+func (df *DESFire) WriteRecord(fileNo byte, offset int, data []byte) error {
+	cmd := []byte{CmdWriteRecord, fileNo}
+	cmd = appendUint24(cmd, offset)
+	cmd = appendUint24(cmd, len(data))
+	cmd = append(cmd, data...)
+
+	_, err := df.Transceive(cmd)
+	return err
 }
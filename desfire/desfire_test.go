@@ -0,0 +1,149 @@
+package desfire_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/hex"
+	"testing"
+
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/desfire/simulator"
+	"github.com/oo-developer/acr122u/keys"
+)
+
+// newApplication returns a client on a fresh card with an AES application
+// selected and authenticated with key 0
+func newApplication(t *testing.T) *desfire.DESFire {
+	t.Helper()
+	df := desfire.NewDESFireWithTransport(simulator.NewSimulator([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+	aid := []byte{0x0A, 0x0B, 0x0C}
+	if err := df.CreateApplication(aid, 0x0F, 0x82); err != nil {
+		t.Fatalf("CreateApplication: %v", err)
+	}
+	if err := df.SelectApplication(aid); err != nil {
+		t.Fatalf("SelectApplication: %v", err)
+	}
+	if err := df.AuthenticateAES(0, make([]byte, 16)); err != nil {
+		t.Fatalf("AuthenticateAES: %v", err)
+	}
+	return df
+}
+
+func TestChangeFileSettings(t *testing.T) {
+	keyOne := desfire.AccessRights{Read: 1, Write: 1, ReadWrite: 1, ChangeAccess: 1}
+	for _, tt := range []struct {
+		name   string
+		before desfire.AccessRights
+	}{
+		{"free", desfire.FreeAccess},
+		{"enciphered", desfire.AccessRights{Read: 0, Write: 0, ReadWrite: 0, ChangeAccess: 0}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			df := newApplication(t)
+			if err := df.CreateStdDataFile(1, desfire.CommModeFull, tt.before, 16); err != nil {
+				t.Fatalf("CreateStdDataFile: %v", err)
+			}
+			if err := df.ChangeFileSettings(1, desfire.CommModeMAC, keyOne); err != nil {
+				t.Fatalf("ChangeFileSettings: %v", err)
+			}
+			fs, err := df.GetFileSettings(1)
+			if err != nil {
+				t.Fatalf("GetFileSettings: %v", err)
+			}
+			if fs.CommMode != desfire.CommModeMAC || fs.AccessRights != keyOne {
+				t.Fatalf("settings %+v", fs)
+			}
+		})
+	}
+}
+
+func TestChangeFileSettingsNeedsAuthentication(t *testing.T) {
+	df := newApplication(t)
+	access := desfire.AccessRights{Read: 0, Write: 0, ReadWrite: 0, ChangeAccess: 0}
+	if err := df.CreateStdDataFile(1, desfire.CommModePlain, access, 16); err != nil {
+		t.Fatalf("CreateStdDataFile: %v", err)
+	}
+	// Selecting the application again ends the session
+	if err := df.SelectApplication([]byte{0x0A, 0x0B, 0x0C}); err != nil {
+		t.Fatalf("SelectApplication: %v", err)
+	}
+	if err := df.ChangeFileSettings(1, desfire.CommModePlain, desfire.FreeAccess); err == nil {
+		t.Fatal("ChangeFileSettings succeeded without authentication")
+	}
+}
+
+func TestAuthenticateCipher(t *testing.T) {
+	aesKey := bytes.Repeat([]byte{0x42}, 16)
+	desKey := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18}
+	des3Key := append(append([]byte{}, desKey...), 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28)
+	newBlock := func(keyType byte, key []byte) cipher.Block {
+		var block cipher.Block
+		var err error
+		switch keyType {
+		case desfire.KeyTypeAES:
+			block, err = aes.NewCipher(key)
+		case desfire.KeyType3K3DES:
+			block, err = des.NewTripleDESCipher(key)
+		default:
+			block, err = des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
+	for _, tt := range []struct {
+		name    string
+		keyType byte
+		key     []byte
+	}{
+		{"AES", desfire.KeyTypeAES, aesKey},
+		{"2-key 3DES", desfire.KeyType3DES, desKey},
+		{"3K3DES", desfire.KeyType3K3DES, des3Key},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			df := desfire.NewDESFireWithTransport(simulator.NewSimulator([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+			if err := df.Authenticate(0, desfire.KeyTypeDES, make([]byte, 8)); err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if err := df.ChangeKey(0, tt.keyType, tt.key, 1, nil); err != nil {
+				t.Fatalf("ChangeKey: %v", err)
+			}
+
+			wrong := make([]byte, len(tt.key))
+			if err := df.AuthenticateCipher(0, tt.keyType, keys.BlockCipher(newBlock(tt.keyType, wrong))); err == nil {
+				t.Fatalf("AuthenticateCipher with a wrong key")
+			}
+			if err := df.AuthenticateCipher(0, tt.keyType, keys.BlockCipher(newBlock(tt.keyType, tt.key))); err != nil {
+				t.Fatalf("AuthenticateCipher: %v", err)
+			}
+			if state := df.AuthState(); !state.Authenticated || state.KeyType != tt.keyType {
+				t.Fatalf("AuthState %+v", state)
+			}
+			// The session works for commands under the master key
+			if err := df.CreateApplication([]byte{0x0A, 0x0B, 0x0C}, 0x0F, 0x82); err != nil {
+				t.Fatalf("CreateApplication: %v", err)
+			}
+		})
+	}
+
+	df := desfire.NewDESFireWithTransport(simulator.NewSimulator([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+	if err := df.AuthenticateCipher(0, desfire.KeyType3DES, keys.BlockCipher(newBlock(desfire.KeyTypeAES, aesKey))); err == nil {
+		t.Fatalf("AuthenticateCipher accepted an AES cipher for a 3DES key")
+	}
+}
+
+// TestDiversifyAES checks the AES-128 example of AN10922
+func TestDiversifyAES(t *testing.T) {
+	master, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	input, _ := hex.DecodeString("04782E21801D803042F54E585020416275")
+	key, err := desfire.DiversifyAES(master, input)
+	if err != nil {
+		t.Fatalf("DiversifyAES: %v", err)
+	}
+	if got := hex.EncodeToString(key); got != "a8dd63a3b89d54b37ca802473fda9175" {
+		t.Fatalf("diversified key %s", got)
+	}
+}
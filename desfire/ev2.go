@@ -0,0 +1,78 @@
+package desfire
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"fmt"
+)
+
+// NTAG424DFName is the DF name of the NDEF application of NTAG 424 DNA,
+// which holds its keys
+var NTAG424DFName = []byte{0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01}
+
+// SelectDFName selects an application with ISO SELECT by its DF name, as
+// created by CreateISOApplication or preset like NTAG424DFName
+func (df *DESFire) SelectDFName(name []byte) error {
+	if len(name) == 0 || len(name) > 16 {
+		return fmt.Errorf("DF name must be 1 to 16 bytes")
+	}
+	apdu := append([]byte{0x00, 0xA4, 0x04, 0x0C, byte(len(name))}, name...)
+	rsp, err := df.card.Transmit(append(apdu, 0x00))
+
+	// Like SelectApplication, the selection ends the authentication
+	df.session = nil
+	if err != nil {
+		return fmt.Errorf("transmit error: %w", err)
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+		return fmt.Errorf("failed to select %X: % X", name, rsp)
+	}
+	// The AID is not known, but it is not the PICC level
+	df.aid = []byte{}
+	df.files = nil
+	return nil
+}
+
+// AuthenticateEV2First runs the EV2 first authentication with an AES key,
+// as DESFire EV2 and later and NTAG 424 DNA take it. Both sides prove the
+// key. The EV2 secure messaging is not implemented, so the commands that
+// follow are sent without a session.
+func (df *DESFire) AuthenticateEV2First(keyNo byte, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	df.session = nil
+
+	// No capabilities are requested
+	resp, err := df.Transceive([]byte{CmdAuthenticateEV2First, keyNo, 0x00})
+	if err != nil {
+		return fmt.Errorf("authenticate step 1 failed: %w", err)
+	}
+	if len(resp) != 16 {
+		return fmt.Errorf("encrypted RndB has %d bytes", len(resp))
+	}
+	rndB := cbcDecrypt(block, make([]byte, 16), resp)
+
+	rndA := make([]byte, 16)
+	if _, err := rand.Read(rndA); err != nil {
+		return fmt.Errorf("failed to generate RndA: %w", err)
+	}
+	// Unlike the EV1 authentication, every message starts from a zero IV
+	data := cbcEncrypt(block, make([]byte, 16), append(append([]byte{}, rndA...), rotateLeft(rndB)...))
+	resp, err = df.Transceive(append([]byte{CmdAdditionalFrame}, data...))
+	if err != nil {
+		return fmt.Errorf("authenticate step 2 failed: %w", err)
+	}
+	if len(resp) != 32 {
+		return fmt.Errorf("authentication response has %d bytes", len(resp))
+	}
+
+	// TI (4), RndA' (16) and the capabilities (12)
+	plain := cbcDecrypt(block, make([]byte, 16), resp)
+	if !bytes.Equal(plain[4:20], rotateLeft(rndA)) {
+		return fmt.Errorf("authentication failed: RndA mismatch")
+	}
+	return nil
+}
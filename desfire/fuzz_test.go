@@ -0,0 +1,49 @@
+package desfire_test
+
+import (
+	"testing"
+
+	"github.com/oo-developer/acr122u/desfire"
+)
+
+// fuzzTransport answers each command with the next length-prefixed chunk of
+// the fuzz input, and 91 00 once the input is used up
+type fuzzTransport struct {
+	data []byte
+}
+
+func (f *fuzzTransport) Transmit(cmd []byte) ([]byte, error) {
+	if len(f.data) == 0 {
+		return []byte{0x91, 0x00}, nil
+	}
+	n := min(int(f.data[0]), len(f.data)-1)
+	rsp := f.data[1 : 1+n]
+	f.data = f.data[1+n:]
+	return rsp, nil
+}
+
+func FuzzDecodeAccessRights(f *testing.F) {
+	f.Add([]byte{0xEE, 0xEE})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		desfire.DecodeAccessRights(b)
+	})
+}
+
+func FuzzResponses(f *testing.F) {
+	// GetVersion of a DESFire EV1
+	f.Add([]byte{
+		9, 0x04, 0x01, 0x01, 0x01, 0x00, 0x1A, 0x05, 0x91, 0xAF,
+		9, 0x04, 0x01, 0x01, 0x01, 0x00, 0x1A, 0x05, 0x91, 0xAF,
+		16, 0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x91, 0x00,
+	})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		df := desfire.NewDESFireWithTransport(&fuzzTransport{data: b})
+		df.GetVersion()
+		df.GetUID()
+		df.GetApplicationIDs()
+		df.GetFileIDs()
+		df.GetFileSettings(1)
+		df.GetKeyVersion(0)
+		df.GetValue(1)
+	})
+}
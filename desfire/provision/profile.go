@@ -0,0 +1,337 @@
+package provision
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/oo-developer/acr122u/desfire"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile describes the target layout of a card: the applications with their
+// keys and files. It can be built in Go or loaded from YAML.
+type Profile struct {
+	// PICCKeyType and PICCKey authenticate at PICC level to create
+	// applications
+	PICCKeyType  KeyType       `yaml:"picc_key_type"`
+	PICCKey      HexBytes      `yaml:"picc_key"`
+	Applications []Application `yaml:"applications"`
+}
+
+// Application describes one application. Keys[0] is the application master
+// key; the key settings must allow it to change the other keys.
+type Application struct {
+	AID         HexBytes `yaml:"aid"`
+	KeySettings byte     `yaml:"key_settings"`
+	KeyType     KeyType  `yaml:"key_type"`
+	Keys        []Key    `yaml:"keys"`
+	Files       []File   `yaml:"files"`
+}
+
+// Key is the target value of an application key. Keys whose version on the
+// card already matches a non-zero Version are left alone on re-runs.
+type Key struct {
+	Value   HexBytes `yaml:"value"`
+	Version byte     `yaml:"version"`
+}
+
+// File describes one file of an application. Only the fields of its type
+// apply; Data is the initial content of data files.
+type File struct {
+	No       byte     `yaml:"no"`
+	Type     FileType `yaml:"type"`
+	CommMode CommMode `yaml:"comm_mode"`
+	Access   Access   `yaml:"access"`
+
+	// Data files
+	Size int      `yaml:"size"`
+	Data HexBytes `yaml:"data"`
+
+	// Value files
+	LowerLimit    int32 `yaml:"lower_limit"`
+	UpperLimit    int32 `yaml:"upper_limit"`
+	Value         int32 `yaml:"value"`
+	LimitedCredit bool  `yaml:"limited_credit"`
+
+	// Record files
+	RecordSize int `yaml:"record_size"`
+	MaxRecords int `yaml:"max_records"`
+}
+
+// Access holds the access rights of a file
+type Access struct {
+	Read         Right `yaml:"read"`
+	Write        Right `yaml:"write"`
+	ReadWrite    Right `yaml:"read_write"`
+	ChangeAccess Right `yaml:"change_access"`
+}
+
+// AccessRights converts the access to the desfire representation
+func (a Access) AccessRights() desfire.AccessRights {
+	return desfire.AccessRights{
+		Read:         byte(a.Read),
+		Write:        byte(a.Write),
+		ReadWrite:    byte(a.ReadWrite),
+		ChangeAccess: byte(a.ChangeAccess),
+	}
+}
+
+// LoadProfile reads a profile from a YAML file
+func LoadProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %v", err)
+	}
+	return ParseProfile(data)
+}
+
+// ParseProfile parses and validates a YAML profile
+func ParseProfile(data []byte) (*Profile, error) {
+	var profile Profile
+	if err := yaml.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse profile: %v", err)
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Validate checks the profile for inconsistencies before touching a card
+func (p *Profile) Validate() error {
+	return p.validate(true)
+}
+
+// validate checks the profile; the PICC key may be missing when it is used
+// through a cipher
+func (p *Profile) validate(piccKey bool) error {
+	if piccKey || len(p.PICCKey) > 0 {
+		if err := checkKey(byte(p.PICCKeyType), p.PICCKey); err != nil {
+			return fmt.Errorf("PICC key: %v", err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, app := range p.Applications {
+		if len(app.AID) != 3 {
+			return fmt.Errorf("application %X: AID must be 3 bytes", []byte(app.AID))
+		}
+		if seen[string(app.AID)] {
+			return fmt.Errorf("application %X: defined twice", []byte(app.AID))
+		}
+		seen[string(app.AID)] = true
+
+		if len(app.Keys) < 1 || len(app.Keys) > 14 {
+			return fmt.Errorf("application %X: needs 1 to 14 keys", []byte(app.AID))
+		}
+		for i, key := range app.Keys {
+			if err := checkKey(byte(app.KeyType), key.Value); err != nil {
+				return fmt.Errorf("application %X key %d: %v", []byte(app.AID), i, err)
+			}
+		}
+
+		files := make(map[byte]bool)
+		for _, file := range app.Files {
+			if files[file.No] {
+				return fmt.Errorf("application %X file %d: defined twice", []byte(app.AID), file.No)
+			}
+			files[file.No] = true
+			if err := file.validate(len(app.Keys)); err != nil {
+				return fmt.Errorf("application %X file %d: %v", []byte(app.AID), file.No, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (f File) validate(numKeys int) error {
+	for _, right := range []Right{f.Access.Read, f.Access.Write, f.Access.ReadWrite, f.Access.ChangeAccess} {
+		if right < desfire.AccessFree && int(right) >= numKeys {
+			return fmt.Errorf("access right refers to missing key %d", right)
+		}
+	}
+
+	switch f.Type {
+	case desfire.FileTypeStandardData, desfire.FileTypeBackupData:
+		if f.Size <= 0 {
+			return fmt.Errorf("size must be positive")
+		}
+		if len(f.Data) > f.Size {
+			return fmt.Errorf("data exceeds file size")
+		}
+	case desfire.FileTypeValue:
+		if f.LowerLimit > f.UpperLimit || f.Value < f.LowerLimit || f.Value > f.UpperLimit {
+			return fmt.Errorf("value must be within lower and upper limit")
+		}
+	case desfire.FileTypeLinearRecord, desfire.FileTypeCyclicRecord:
+		if f.RecordSize <= 0 || f.MaxRecords <= 0 {
+			return fmt.Errorf("record size and count must be positive")
+		}
+	}
+	if len(f.Data) > 0 && f.Type != desfire.FileTypeStandardData && f.Type != desfire.FileTypeBackupData {
+		return fmt.Errorf("initial data is only supported for data files")
+	}
+	return nil
+}
+
+func checkKey(keyType byte, key []byte) error {
+	want := map[byte][]int{
+		desfire.KeyTypeDES:    {8, 16},
+		desfire.KeyType3DES:   {16},
+		desfire.KeyType3K3DES: {24},
+		desfire.KeyTypeAES:    {16},
+	}[keyType]
+	for _, n := range want {
+		if len(key) == n {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid length %d for key type %s", len(key), KeyType(keyType))
+}
+
+// HexBytes is a byte slice written as hex string in YAML, e.g. "F0 01 02"
+type HexBytes []byte
+
+func (h *HexBytes) UnmarshalYAML(value *yaml.Node) error {
+	s := strings.NewReplacer(" ", "", ":", "").Replace(value.Value)
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid hex %q", value.Line, value.Value)
+	}
+	*h = b
+	return nil
+}
+
+func (h HexBytes) MarshalYAML() (interface{}, error) {
+	return strings.ToUpper(hex.EncodeToString(h)), nil
+}
+
+// KeyType is a desfire key type written as des, 3des, 3k3des or aes in YAML
+type KeyType byte
+
+var keyTypeNames = map[KeyType]string{
+	desfire.KeyTypeDES:    "des",
+	desfire.KeyType3DES:   "3des",
+	desfire.KeyType3K3DES: "3k3des",
+	desfire.KeyTypeAES:    "aes",
+}
+
+func (k KeyType) String() string {
+	if name, ok := keyTypeNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", byte(k))
+}
+
+func (k *KeyType) UnmarshalYAML(value *yaml.Node) error {
+	v, err := lookupName(keyTypeNames, value)
+	*k = v
+	return err
+}
+
+func (k KeyType) MarshalYAML() (interface{}, error) {
+	return k.String(), nil
+}
+
+// FileType is a desfire file type written as standard, backup, value,
+// linear_record or cyclic_record in YAML
+type FileType byte
+
+var fileTypeNames = map[FileType]string{
+	desfire.FileTypeStandardData: "standard",
+	desfire.FileTypeBackupData:   "backup",
+	desfire.FileTypeValue:        "value",
+	desfire.FileTypeLinearRecord: "linear_record",
+	desfire.FileTypeCyclicRecord: "cyclic_record",
+}
+
+func (f FileType) String() string {
+	if name, ok := fileTypeNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", byte(f))
+}
+
+func (f *FileType) UnmarshalYAML(value *yaml.Node) error {
+	v, err := lookupName(fileTypeNames, value)
+	*f = v
+	return err
+}
+
+func (f FileType) MarshalYAML() (interface{}, error) {
+	return f.String(), nil
+}
+
+// CommMode is a desfire communication mode written as plain, mac or full in
+// YAML
+type CommMode byte
+
+var commModeNames = map[CommMode]string{
+	desfire.CommModePlain: "plain",
+	desfire.CommModeMAC:   "mac",
+	desfire.CommModeFull:  "full",
+}
+
+func (c CommMode) String() string {
+	if name, ok := commModeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", byte(c))
+}
+
+func (c *CommMode) UnmarshalYAML(value *yaml.Node) error {
+	v, err := lookupName(commModeNames, value)
+	*c = v
+	return err
+}
+
+func (c CommMode) MarshalYAML() (interface{}, error) {
+	return c.String(), nil
+}
+
+// Right is an access right: a key number, or free or never in YAML
+type Right byte
+
+func (r Right) String() string {
+	switch r {
+	case desfire.AccessFree:
+		return "free"
+	case desfire.AccessNever:
+		return "never"
+	default:
+		return strconv.Itoa(int(r))
+	}
+}
+
+func (r *Right) UnmarshalYAML(value *yaml.Node) error {
+	switch strings.ToLower(value.Value) {
+	case "free":
+		*r = desfire.AccessFree
+		return nil
+	case "never":
+		*r = desfire.AccessNever
+		return nil
+	}
+	n, err := strconv.Atoi(value.Value)
+	if err != nil || n < 0 || n > 13 {
+		return fmt.Errorf("line %d: invalid access right %q", value.Line, value.Value)
+	}
+	*r = Right(n)
+	return nil
+}
+
+func (r Right) MarshalYAML() (interface{}, error) {
+	return r.String(), nil
+}
+
+func lookupName[T ~byte](names map[T]string, value *yaml.Node) (T, error) {
+	for v, name := range names {
+		if strings.EqualFold(name, value.Value) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("line %d: unknown value %q", value.Line, value.Value)
+}
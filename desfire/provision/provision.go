@@ -0,0 +1,350 @@
+package provision
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
+)
+
+// Action is what the provisioner did with one part of the profile
+type Action string
+
+const (
+	ActionCreated   Action = "created"
+	ActionChanged   Action = "changed"
+	ActionWritten   Action = "written"
+	ActionUnchanged Action = "unchanged"
+	ActionMismatch  Action = "mismatch" // exists with different settings, left alone
+)
+
+// Step is one entry of a personalization report
+type Step struct {
+	Target string
+	Action Action
+	Detail string
+}
+
+// Report lists what a personalization run did, in order
+type Report struct {
+	Steps []Step
+}
+
+func (r *Report) add(target string, action Action, detail string) {
+	r.Steps = append(r.Steps, Step{Target: target, Action: action, Detail: detail})
+}
+
+// Changed reports whether the run modified the card
+func (r *Report) Changed() bool {
+	for _, step := range r.Steps {
+		if step.Action != ActionUnchanged && step.Action != ActionMismatch {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	for _, step := range r.Steps {
+		fmt.Fprintf(&sb, "%-32s %s", step.Target, step.Action)
+		if step.Detail != "" {
+			fmt.Fprintf(&sb, " (%s)", step.Detail)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Provisioner personalizes DESFire cards according to a profile. Runs are
+// idempotent: applications, keys and files already in place are skipped, so a
+// run interrupted by a card removal can simply be repeated.
+type Provisioner struct {
+	df         *desfire.DESFire
+	piccCipher keys.Cipher
+}
+
+// NewProvisioner creates a provisioner for a connected DESFire card
+func NewProvisioner(df *desfire.DESFire) *Provisioner {
+	return &Provisioner{df: df}
+}
+
+// UsePICCCipher makes the provisioner authenticate at PICC level through a
+// cipher, such as one of an HSM key, instead of with Profile.PICCKey, which
+// may then be empty. The cipher must fit Profile.PICCKeyType.
+func (p *Provisioner) UsePICCCipher(c keys.Cipher) {
+	p.piccCipher = c
+}
+
+// Apply personalizes the card with profile. The report covers all steps
+// completed so far, also when an error is returned.
+func (p *Provisioner) Apply(profile *Profile) (*Report, error) {
+	return p.ApplyContext(context.Background(), profile, nil)
+}
+
+// ApplyContext personalizes the card like Apply, reporting progress in
+// applications and files done (progress may be nil). Cancelling the context
+// stops the run before the next application or file; as runs are
+// idempotent, it can be resumed by applying the profile again.
+func (p *Provisioner) ApplyContext(ctx context.Context, profile *Profile, progress hardware.Progress) (*Report, error) {
+	report := &Report{}
+	if err := profile.validate(p.piccCipher == nil); err != nil {
+		return report, err
+	}
+
+	t := hardware.NewTracker(ctx, progress)
+	total := 0
+	for _, app := range profile.Applications {
+		total += 1 + len(app.Files)
+	}
+	t.Begin(total)
+	for _, app := range profile.Applications {
+		if err := t.Next(); err != nil {
+			return report, err
+		}
+		if err := p.applyApplication(profile, app, report, t); err != nil {
+			return report, fmt.Errorf("application %X: %w", []byte(app.AID), err)
+		}
+	}
+	return report, nil
+}
+
+func (p *Provisioner) applyApplication(profile *Profile, app Application, report *Report, t *hardware.Tracker) error {
+	target := fmt.Sprintf("application %X", []byte(app.AID))
+
+	// Create the application at PICC level if it doesn't exist yet
+	if err := p.df.SelectApplication([]byte{0x00, 0x00, 0x00}); err != nil {
+		return fmt.Errorf("failed to select PICC: %w", err)
+	}
+	if err := p.authenticatePICC(profile); err != nil {
+		return fmt.Errorf("failed to authenticate with PICC master key: %w", err)
+	}
+	aids, err := p.df.GetApplicationIDs()
+	if err != nil {
+		return fmt.Errorf("failed to list applications: %w", err)
+	}
+	exists := false
+	for _, aid := range aids {
+		if bytes.Equal(aid, app.AID) {
+			exists = true
+			break
+		}
+	}
+	if exists {
+		report.add(target, ActionUnchanged, "")
+	} else {
+		if err := p.df.CreateApplication(app.AID, app.KeySettings, numKeysWithType(app)); err != nil {
+			return fmt.Errorf("failed to create application: %w", err)
+		}
+		report.add(target, ActionCreated, fmt.Sprintf("%d %s keys", len(app.Keys), app.KeyType))
+	}
+
+	if err := p.df.SelectApplication(app.AID); err != nil {
+		return fmt.Errorf("failed to select application: %w", err)
+	}
+	if err := p.applyKeys(app, target, report); err != nil {
+		return err
+	}
+	t.Advance(1)
+	for _, file := range app.Files {
+		if err := t.Next(); err != nil {
+			return err
+		}
+		if err := p.applyFile(app, file, target, report); err != nil {
+			return fmt.Errorf("file %d: %w", file.No, err)
+		}
+		t.Advance(1)
+	}
+	return nil
+}
+
+func (p *Provisioner) authenticatePICC(profile *Profile) error {
+	if p.piccCipher != nil {
+		return p.df.AuthenticateCipher(0x00, byte(profile.PICCKeyType), p.piccCipher)
+	}
+	return p.df.Authenticate(0x00, byte(profile.PICCKeyType), profile.PICCKey)
+}
+
+// applyKeys sets the application keys. The master key is changed last as
+// changing it ends the session that the other key changes rely on.
+func (p *Provisioner) applyKeys(app Application, target string, report *Report) error {
+	keyType := byte(app.KeyType)
+	master := app.Keys[0]
+	defaultKey := defaultKeyFor(keyType)
+
+	masterSet := p.df.Authenticate(0x00, keyType, master.Value) == nil
+	if !masterSet {
+		if err := p.df.Authenticate(0x00, keyType, defaultKey); err != nil {
+			return fmt.Errorf("neither the target nor the default master key is accepted: %w", err)
+		}
+	}
+
+	for i := 1; i < len(app.Keys); i++ {
+		key := app.Keys[i]
+		keyTarget := fmt.Sprintf("%s key %d", target, i)
+		version, err := p.df.GetKeyVersion(byte(i))
+		if err != nil {
+			return fmt.Errorf("failed to get version of key %d: %w", i, err)
+		}
+		if key.Version != 0 && version == key.Version {
+			report.add(keyTarget, ActionUnchanged, fmt.Sprintf("version %d", version))
+			continue
+		}
+		if err := p.df.ChangeKey(byte(i), keyType, key.Value, key.Version, defaultKey); err != nil {
+			return fmt.Errorf("failed to change key %d: %w", i, err)
+		}
+		report.add(keyTarget, ActionChanged, fmt.Sprintf("version %d", key.Version))
+	}
+
+	masterTarget := target + " key 0"
+	if masterSet {
+		report.add(masterTarget, ActionUnchanged, "")
+		return nil
+	}
+	if err := p.df.ChangeKey(0x00, keyType, master.Value, master.Version, nil); err != nil {
+		return fmt.Errorf("failed to change master key: %w", err)
+	}
+	report.add(masterTarget, ActionChanged, fmt.Sprintf("version %d", master.Version))
+	return nil
+}
+
+func (p *Provisioner) applyFile(app Application, file File, target string, report *Report) error {
+	target = fmt.Sprintf("%s file %d", target, file.No)
+
+	if err := p.authenticate(app, 0x00); err != nil {
+		return err
+	}
+	ids, err := p.df.GetFileIDs()
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	if bytes.IndexByte(ids, file.No) >= 0 {
+		fs, err := p.df.GetFileSettings(file.No)
+		if err != nil {
+			return fmt.Errorf("failed to get file settings: %w", err)
+		}
+		if diff := file.diff(fs); diff != "" {
+			report.add(target, ActionMismatch, diff)
+		} else {
+			report.add(target, ActionUnchanged, "")
+		}
+		return nil
+	}
+
+	if err := p.createFile(file); err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	report.add(target, ActionCreated, file.Type.String())
+
+	if len(file.Data) == 0 {
+		return nil
+	}
+
+	// Write the initial data with a key granting write access
+	access := file.Access.AccessRights()
+	writeKey := access.Write
+	if writeKey == desfire.AccessNever {
+		writeKey = access.ReadWrite
+	}
+	if writeKey == desfire.AccessNever {
+		return fmt.Errorf("initial data can't be written, write access is never granted")
+	}
+	if writeKey != desfire.AccessFree {
+		if err := p.authenticate(app, writeKey); err != nil {
+			return err
+		}
+	}
+	if err := p.df.WriteData(file.No, 0, file.Data); err != nil {
+		return fmt.Errorf("failed to write initial data: %w", err)
+	}
+	if file.Type == desfire.FileTypeBackupData {
+		if err := p.df.CommitTransaction(); err != nil {
+			return fmt.Errorf("failed to commit initial data: %w", err)
+		}
+	}
+	report.add(target, ActionWritten, fmt.Sprintf("%d bytes", len(file.Data)))
+	return nil
+}
+
+func (p *Provisioner) createFile(file File) error {
+	access := file.Access.AccessRights()
+	commMode := byte(file.CommMode)
+
+	switch file.Type {
+	case desfire.FileTypeStandardData:
+		return p.df.CreateStdDataFile(file.No, commMode, access, file.Size)
+	case desfire.FileTypeBackupData:
+		return p.df.CreateBackupDataFile(file.No, commMode, access, file.Size)
+	case desfire.FileTypeValue:
+		return p.df.CreateValueFile(file.No, commMode, access, file.LowerLimit, file.UpperLimit, file.Value, file.LimitedCredit)
+	case desfire.FileTypeLinearRecord:
+		return p.df.CreateLinearRecordFile(file.No, commMode, access, file.RecordSize, file.MaxRecords)
+	case desfire.FileTypeCyclicRecord:
+		return p.df.CreateCyclicRecordFile(file.No, commMode, access, file.RecordSize, file.MaxRecords)
+	default:
+		return fmt.Errorf("unknown file type %s", file.Type)
+	}
+}
+
+// authenticate makes sure the session is authenticated with the target value
+// of an application key
+func (p *Provisioner) authenticate(app Application, keyNo byte) error {
+	state := p.df.AuthState()
+	if state.Authenticated && state.KeyNo == keyNo {
+		return nil
+	}
+	if err := p.df.Authenticate(keyNo, byte(app.KeyType), app.Keys[keyNo].Value); err != nil {
+		return fmt.Errorf("failed to authenticate with key %d: %w", keyNo, err)
+	}
+	return nil
+}
+
+// diff describes how existing file settings differ from the profile
+func (f File) diff(fs *desfire.FileSettings) string {
+	var diffs []string
+	if fs.FileType != byte(f.Type) {
+		diffs = append(diffs, fmt.Sprintf("type %s", FileType(fs.FileType)))
+	}
+	if fs.CommMode != byte(f.CommMode) {
+		diffs = append(diffs, fmt.Sprintf("comm mode %s", CommMode(fs.CommMode)))
+	}
+	if fs.AccessRights != f.Access.AccessRights() {
+		diffs = append(diffs, fmt.Sprintf("access %s", fs.AccessRights))
+	}
+	switch f.Type {
+	case desfire.FileTypeStandardData, desfire.FileTypeBackupData:
+		if fs.FileSize != f.Size {
+			diffs = append(diffs, fmt.Sprintf("size %d", fs.FileSize))
+		}
+	case desfire.FileTypeLinearRecord, desfire.FileTypeCyclicRecord:
+		if fs.RecordSize != f.RecordSize || fs.MaxRecords != f.MaxRecords {
+			diffs = append(diffs, fmt.Sprintf("records %dx%d", fs.MaxRecords, fs.RecordSize))
+		}
+	}
+	return strings.Join(diffs, ", ")
+}
+
+// numKeysWithType encodes the key count and key type for CreateApplication
+func numKeysWithType(app Application) byte {
+	numKeys := byte(len(app.Keys))
+	switch byte(app.KeyType) {
+	case desfire.KeyType3K3DES:
+		numKeys |= 0x40
+	case desfire.KeyTypeAES:
+		numKeys |= 0x80
+	}
+	return numKeys
+}
+
+// defaultKeyFor returns the all-zero key a new application starts with
+func defaultKeyFor(keyType byte) []byte {
+	if keyType == desfire.KeyType3K3DES {
+		return make([]byte, 24)
+	}
+	return make([]byte, 16)
+}
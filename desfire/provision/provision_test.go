@@ -0,0 +1,69 @@
+package provision_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"slices"
+	"testing"
+
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/desfire/provision"
+	"github.com/oo-developer/acr122u/desfire/simulator"
+	"github.com/oo-developer/acr122u/keys"
+)
+
+func TestUsePICCCipher(t *testing.T) {
+	df := desfire.NewDESFireWithTransport(simulator.NewSimulator([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+	if err := df.Authenticate(0, desfire.KeyTypeDES, make([]byte, 8)); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	master := bytes.Repeat([]byte{0x42}, 16)
+	if err := df.ChangeKey(0, desfire.KeyTypeAES, master, 1, nil); err != nil {
+		t.Fatalf("ChangeKey: %v", err)
+	}
+	block, err := aes.NewCipher(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := []byte(`
+picc_key_type: aes
+applications:
+  - aid: "010203"
+    key_settings: 0x0F
+    key_type: aes
+    keys:
+      - value: "00000000000000000000000000000011"
+`)
+	if _, err := provision.ParseProfile(text); err == nil {
+		t.Fatalf("ParseProfile accepted a profile without PICC key")
+	}
+	profile := &provision.Profile{
+		PICCKeyType: provision.KeyType(desfire.KeyTypeAES),
+		Applications: []provision.Application{{
+			AID:         provision.HexBytes{0x01, 0x02, 0x03},
+			KeySettings: 0x0F,
+			KeyType:     provision.KeyType(desfire.KeyTypeAES),
+			Keys:        []provision.Key{{Value: bytes.Repeat([]byte{0x11}, 16)}},
+		}},
+	}
+	if _, err := provision.NewProvisioner(df).Apply(profile); err == nil {
+		t.Fatalf("Apply without PICC key or cipher")
+	}
+
+	p := provision.NewProvisioner(df)
+	p.UsePICCCipher(keys.BlockCipher(block))
+	if _, err := p.Apply(profile); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if err := df.SelectApplication([]byte{0x00, 0x00, 0x00}); err != nil {
+		t.Fatalf("SelectApplication: %v", err)
+	}
+	aids, err := df.GetApplicationIDs()
+	if err != nil {
+		t.Fatalf("GetApplicationIDs: %v", err)
+	}
+	if !slices.ContainsFunc(aids, func(aid []byte) bool { return bytes.Equal(aid, []byte{0x01, 0x02, 0x03}) }) {
+		t.Fatalf("applications %X", aids)
+	}
+}
@@ -0,0 +1,209 @@
+package desfire
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/internal/cmac"
+)
+
+// maxFrameData is the number of command bytes sent per frame; longer commands
+// are continued with additional frames
+const maxFrameData = 52
+
+// commModeNone bypasses secure messaging for a command or response, either
+// because the caller already protected it or because the card sends it in
+// plain even within a session
+const commModeNone = 0xFF
+
+// commSettings describes how a command and its response are protected in an
+// authenticated session
+type commSettings struct {
+	cmd    byte // communication mode of the command data
+	resp   byte // communication mode of the response data
+	header int  // command bytes after the command code that are never enciphered
+}
+
+// commSettingsFor determines the communication modes for cmd. Data commands
+// use the mode configured for the file they access.
+func (df *DESFire) commSettingsFor(cmd []byte) (commSettings, error) {
+	settings := commSettings{cmd: CommModePlain, resp: CommModePlain, header: len(cmd) - 1}
+	if df.session == nil {
+		return settings, nil
+	}
+
+	switch cmd[0] {
+	case CmdSelectApplication:
+		// The card ends the authentication, the response carries no MAC
+		settings.cmd = commModeNone
+		settings.resp = commModeNone
+	case CmdReadData, CmdReadRecords, CmdGetValue:
+		mode, err := df.fileCommMode(cmd[1], false)
+		if err != nil {
+			return settings, err
+		}
+		settings.resp = mode
+	case CmdWriteData, CmdWriteRecord:
+		mode, err := df.fileCommMode(cmd[1], true)
+		if err != nil {
+			return settings, err
+		}
+		settings.cmd = mode
+		settings.header = 7 // file number, offset and length
+	case CmdCredit, CmdDebit, CmdLimitedCredit:
+		mode, err := df.fileCommMode(cmd[1], true)
+		if err != nil {
+			return settings, err
+		}
+		settings.cmd = mode
+		settings.header = 1 // file number
+	case CmdChangeFileSettings:
+		// Enciphered unless changing the access rights is free
+		fs, err := df.fileSettings(cmd[1])
+		if err != nil {
+			return settings, err
+		}
+		if fs.AccessRights.ChangeAccess != AccessFree {
+			settings.cmd = CommModeFull
+		}
+		settings.header = 1 // file number
+	case CmdChangeKeySettings:
+		settings.cmd = CommModeFull
+		settings.header = 0
+	case CmdSetConfiguration:
+		settings.cmd = CommModeFull
+		settings.header = 1 // option
+	case CmdReadSignature:
+		settings.resp = CommModeFull
+	}
+	return settings, nil
+}
+
+// fileCommMode returns the communication mode used for reading or writing a
+// file of the selected application. Access granted as free is always plain.
+func (df *DESFire) fileCommMode(fileNo byte, write bool) (byte, error) {
+	fs, err := df.fileSettings(fileNo)
+	if err != nil {
+		return 0, err
+	}
+
+	if fs.AccessRights.IsFree(write) {
+		return CommModePlain, nil
+	}
+	return fs.CommMode, nil
+}
+
+// fileSettings returns the cached settings of a file of the selected
+// application, retrieving them from the card on first use
+func (df *DESFire) fileSettings(fileNo byte) (*FileSettings, error) {
+	if fs, ok := df.files[fileNo]; ok {
+		return fs, nil
+	}
+	fs, err := df.GetFileSettings(fileNo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings of file %d: %w", fileNo, err)
+	}
+	return fs, nil
+}
+
+// protectCommand applies the command communication mode and returns the
+// frame to send. The session IV advances with every command.
+func (df *DESFire) protectCommand(cmd []byte, settings commSettings) ([]byte, error) {
+	if df.session == nil || settings.cmd == commModeNone {
+		return cmd, nil
+	}
+
+	block, err := newCipher(df.session.keyType, df.session.sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session key: %w", err)
+	}
+
+	switch settings.cmd {
+	case CommModeFull:
+		split := 1 + settings.header
+		plain := appendCRC32(append([]byte{}, cmd[split:]...), cmd)
+		plain = padZero(plain, block.BlockSize())
+		enc := cbcEncrypt(block, df.session.iv, plain)
+		copy(df.session.iv, enc[len(enc)-block.BlockSize():])
+		return append(append([]byte{}, cmd[:split]...), enc...), nil
+	case CommModeMAC:
+		mac := df.session.cmac(block, cmd)
+		return append(append([]byte{}, cmd...), mac[:8]...), nil
+	default:
+		df.session.cmac(block, cmd)
+		return cmd, nil
+	}
+}
+
+// verifyResponse checks the MAC of, or deciphers, a complete response
+// according to the response communication mode
+func (df *DESFire) verifyResponse(resp []byte, status byte, settings commSettings) ([]byte, error) {
+	if df.session == nil || settings.resp == commModeNone {
+		return resp, nil
+	}
+
+	block, err := newCipher(df.session.keyType, df.session.sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session key: %w", err)
+	}
+
+	if settings.resp == CommModeFull {
+		if len(resp) == 0 || len(resp)%block.BlockSize() != 0 {
+			return nil, fmt.Errorf("enciphered response has invalid length: %d bytes", len(resp))
+		}
+		plain := cbcDecrypt(block, df.session.iv, resp)
+		copy(df.session.iv, resp[len(resp)-block.BlockSize():])
+		data, ok := stripCRC32(plain, status)
+		if !ok {
+			df.session = nil
+			return nil, fmt.Errorf("response CRC mismatch")
+		}
+		return data, nil
+	}
+
+	if len(resp) < 8 {
+		df.session = nil
+		return nil, fmt.Errorf("response MAC missing")
+	}
+	data := resp[:len(resp)-8]
+	mac := df.session.cmac(block, append(append([]byte{}, data...), status))
+	if subtle.ConstantTimeCompare(mac[:8], resp[len(resp)-8:]) != 1 {
+		df.session = nil
+		return nil, fmt.Errorf("response MAC mismatch")
+	}
+	return data, nil
+}
+
+// stripCRC32 locates the CRC32 over data and status in a deciphered
+// response, followed only by padding, and returns the data in front of it
+func stripCRC32(plain []byte, status byte) ([]byte, bool) {
+	for pos := len(plain) - 4; pos >= 0; pos-- {
+		if !isPadding(plain[pos+4:]) {
+			continue
+		}
+		crc := crc32DESFire(append(append([]byte{}, plain[:pos]...), status))
+		if binary.LittleEndian.Uint32(plain[pos:pos+4]) == crc {
+			return plain[:pos], true
+		}
+	}
+	return nil, false
+}
+
+// isPadding reports whether b is zero padding, optionally introduced by 0x80
+func isPadding(b []byte) bool {
+	if len(b) > 0 && b[0] == 0x80 {
+		b = b[1:]
+	}
+	return len(bytes.Trim(b, "\x00")) == 0
+}
+
+// cmac computes the CMAC of data chained from the session IV and advances
+// the IV to the result
+func (s *SessionKey) cmac(block cipher.Block, data []byte) []byte {
+	mac := cmac.Chained(block, s.iv, data)
+	copy(s.iv, mac)
+	return mac
+}
@@ -0,0 +1,1265 @@
+package simulator
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/internal/cmac"
+)
+
+// maxResponseFrame is the number of data bytes the card sends per frame
+const maxResponseFrame = 59
+
+// respModeNone marks responses sent without MAC even within a session
+const respModeNone = 0xFF
+
+// file is a file of a simulated application. Backup, value and record files
+// keep uncommitted changes in the pending fields.
+type file struct {
+	fileType byte
+	commMode byte
+	access   desfire.AccessRights
+
+	// Data files
+	data        []byte
+	pendingData []byte
+
+	// Value files
+	value              int32
+	pendingValue       *int32
+	lower, upper       int32
+	limitedCredit      bool
+	limitedCreditValue int32
+	debited            int32
+
+	// Record files
+	recordSize     int
+	maxRecords     int
+	records        [][]byte
+	pendingRecords [][]byte
+}
+
+// application is a simulated application; the PICC level is modelled as an
+// application holding the PICC master key
+type application struct {
+	keySettings byte
+	keys        []*key
+	files       map[byte]*file
+	dfName      []byte // ISO applications only
+}
+
+// pendingAuth is the state between the two authentication frames
+type pendingAuth struct {
+	keyNo   int
+	keyType byte
+	block   cipher.Block
+	rndB    []byte
+	iv      []byte
+	ev2     bool
+}
+
+// Simulator is an in-memory DESFire EV1 card. It implements the card side of
+// the native command set wrapped in ISO 7816 APDUs, including ISO and AES
+// authentication and the plain, MACed and enciphered communication modes, and
+// can be used as transport for desfire.NewDESFireWithTransport. Given an
+// originality signature with SetSignature, it answers Read_Sig like EV2. ISO
+// applications can be selected by DF name, and take the EV2 first
+// authentication; the EV2 secure messaging is not simulated, so that
+// authentication does not start a session.
+type Simulator struct {
+	uid       []byte
+	signature []byte
+	picc      *application
+	apps      map[[3]byte]*application
+
+	// Selected application, nil at PICC level
+	aid *[3]byte
+	app *application
+
+	// Authentication state, authKeyNo is -1 when not authenticated
+	authKeyNo int
+	authType  byte
+	block     cipher.Block
+	iv        []byte
+	auth      *pendingAuth
+
+	// Frame chaining
+	cmdBuf      []byte
+	cmdExpected int
+	respFrames  [][]byte
+}
+
+// NewSimulator creates a blank card with the given 7-byte UID and the factory
+// default DES PICC master key (all zeros)
+func NewSimulator(uid []byte) *Simulator {
+	s := &Simulator{
+		uid: append([]byte{}, uid...),
+		picc: &application{
+			keySettings: 0x0F,
+			keys:        []*key{newDefaultKey(desfire.KeyTypeDES)},
+		},
+		apps:      make(map[[3]byte]*application),
+		authKeyNo: -1,
+	}
+	s.app = s.picc
+	return s
+}
+
+// Transmit processes one ISO 7816 wrapped DESFire frame (90 INS 00 00 Lc
+// data 00) and returns the response data followed by 91 status
+func (s *Simulator) Transmit(apdu []byte) ([]byte, error) {
+	if len(apdu) >= 5 && apdu[0] == 0x00 && apdu[1] == 0xA4 && apdu[2] == 0x04 {
+		return s.selectDFName(apdu), nil
+	}
+	if len(apdu) < 5 || apdu[0] != 0x90 {
+		return []byte{0x6E, 0x00}, nil
+	}
+	lc := int(apdu[4])
+	if len(apdu) < 5+lc {
+		return []byte{0x67, 0x00}, nil
+	}
+	frame := append([]byte{apdu[1]}, apdu[5:5+lc]...)
+
+	resp, status := s.process(frame)
+	if status != desfire.StatusSuccess && status != desfire.StatusAdditionalFrame {
+		s.resetAuth()
+		s.cmdBuf = nil
+		s.respFrames = nil
+		resp = nil
+	}
+	return append(append([]byte{}, resp...), 0x91, status), nil
+}
+
+func (s *Simulator) process(frame []byte) ([]byte, byte) {
+	if frame[0] == desfire.CmdAdditionalFrame {
+		switch {
+		case s.auth != nil && s.auth.ev2:
+			return s.authenticateEV2Step2(frame[1:])
+		case s.auth != nil:
+			return s.authenticateStep2(frame[1:])
+		case s.cmdBuf != nil:
+			s.cmdBuf = append(s.cmdBuf, frame[1:]...)
+			if len(s.cmdBuf) < s.cmdExpected {
+				return nil, desfire.StatusAdditionalFrame
+			}
+			cmd := s.cmdBuf
+			s.cmdBuf = nil
+			return s.execute(cmd)
+		case len(s.respFrames) > 0:
+			return s.nextFrame()
+		}
+		return nil, desfire.StatusIllegalCommand
+	}
+
+	// Any new command ends pending chains
+	s.auth = nil
+	s.cmdBuf = nil
+	s.respFrames = nil
+
+	switch frame[0] {
+	case desfire.CmdAuthenticateISO, desfire.CmdAuthenticateAES:
+		return s.authenticateStep1(frame)
+	case desfire.CmdAuthenticateEV2First:
+		return s.authenticateEV2Step1(frame)
+	}
+
+	if expected := s.expectedLength(frame); expected > len(frame) {
+		s.cmdBuf = frame
+		s.cmdExpected = expected
+		return nil, desfire.StatusAdditionalFrame
+	}
+	return s.execute(frame)
+}
+
+// expectedLength returns the full length of a command whose data may be
+// continued in additional frames
+func (s *Simulator) expectedLength(frame []byte) int {
+	if (frame[0] != desfire.CmdWriteData && frame[0] != desfire.CmdWriteRecord) || len(frame) < 8 {
+		return len(frame)
+	}
+	n := int(uint24(frame[5:8]))
+	f := s.app.files[frame[1]]
+	if f == nil || s.authKeyNo < 0 {
+		return 8 + n
+	}
+	switch s.writeMode(f) {
+	case desfire.CommModeMAC:
+		return 8 + n + 8
+	case desfire.CommModeFull:
+		return 8 + paddedLen(n+4, s.block.BlockSize())
+	default:
+		return 8 + n
+	}
+}
+
+// execute unwraps a complete command, runs it and protects the response
+func (s *Simulator) execute(cmd []byte) ([]byte, byte) {
+	cmd, status := s.unwrap(cmd)
+	if status != desfire.StatusSuccess {
+		return nil, status
+	}
+
+	data, respMode, status := s.dispatch(cmd)
+	if status != desfire.StatusSuccess {
+		return nil, status
+	}
+
+	if s.authKeyNo >= 0 && respMode != respModeNone {
+		data = s.protect(data, respMode)
+	}
+
+	// GetVersion is sent in its three parts, everything else in full frames
+	frameSize := maxResponseFrame
+	if cmd[0] == desfire.CmdGetVersion {
+		frameSize = 7
+	}
+	s.respFrames = nil
+	for len(data) > frameSize {
+		s.respFrames = append(s.respFrames, data[:frameSize])
+		data = data[frameSize:]
+		if cmd[0] == desfire.CmdGetVersion && len(s.respFrames) == 2 {
+			frameSize = maxResponseFrame
+		}
+	}
+	s.respFrames = append(s.respFrames, data)
+	return s.nextFrame()
+}
+
+func (s *Simulator) nextFrame() ([]byte, byte) {
+	frame := s.respFrames[0]
+	s.respFrames = s.respFrames[1:]
+	if len(s.respFrames) > 0 {
+		return frame, desfire.StatusAdditionalFrame
+	}
+	return frame, desfire.StatusSuccess
+}
+
+// unwrap removes the secure messaging of a command within a session: the
+// CMAC of plain commands only advances the IV, MACed commands are verified
+// and enciphered commands are deciphered
+func (s *Simulator) unwrap(cmd []byte) ([]byte, byte) {
+	if s.authKeyNo < 0 {
+		return cmd, desfire.StatusSuccess
+	}
+
+	mode := byte(desfire.CommModePlain)
+	header := len(cmd) - 1
+	length := -1 // plaintext length of enciphered commands, -1 if unknown
+	switch cmd[0] {
+	case desfire.CmdSelectApplication, desfire.CmdChangeKey:
+		// Select ends the session, ChangeKey deciphers its cryptogram itself
+		return cmd, desfire.StatusSuccess
+	case desfire.CmdWriteData, desfire.CmdWriteRecord, desfire.CmdCredit, desfire.CmdDebit, desfire.CmdLimitedCredit:
+		if len(cmd) < 2 {
+			return nil, desfire.StatusLengthError
+		}
+		f := s.app.files[cmd[1]]
+		if f == nil {
+			return nil, desfire.StatusFileNotFound
+		}
+		mode = s.writeMode(f)
+		header = 1
+		length = 4
+		if cmd[0] == desfire.CmdWriteData || cmd[0] == desfire.CmdWriteRecord {
+			if len(cmd) < 8 {
+				return nil, desfire.StatusLengthError
+			}
+			header = 7
+			length = int(uint24(cmd[5:8]))
+		}
+	case desfire.CmdChangeFileSettings:
+		if len(cmd) < 2 {
+			return nil, desfire.StatusLengthError
+		}
+		f := s.app.files[cmd[1]]
+		if f == nil {
+			return nil, desfire.StatusFileNotFound
+		}
+		if f.access.ChangeAccess != desfire.AccessFree {
+			mode = desfire.CommModeFull
+		}
+		header = 1
+		length = 3
+	case desfire.CmdChangeKeySettings:
+		mode = desfire.CommModeFull
+		header = 0
+		length = 1
+	}
+
+	bs := s.block.BlockSize()
+	switch mode {
+	case desfire.CommModeFull:
+		body := cmd[1+header:]
+		if len(body) == 0 || len(body)%bs != 0 {
+			return nil, desfire.StatusLengthError
+		}
+		plain := decryptCBC(s.block, s.iv, body)
+		s.iv = append([]byte{}, body[len(body)-bs:]...)
+		prefix := cmd[:1+header]
+		// The CRC over command and data is followed by zero padding. Search
+		// from the shortest data on: the CRC of data followed by its own CRC
+		// is zero, so zeros in the padding would match as well.
+		first, last := 0, len(plain)-4
+		if length >= 0 {
+			first, last = length, length
+		}
+		for pos := first; pos <= last && pos <= len(plain)-4; pos++ {
+			if len(bytes.Trim(plain[pos+4:], "\x00")) != 0 {
+				continue
+			}
+			data := append(append([]byte{}, prefix...), plain[:pos]...)
+			if bytes.Equal(crc(data), plain[pos:pos+4]) {
+				return data, desfire.StatusSuccess
+			}
+		}
+		return nil, desfire.StatusIntegrityError
+	case desfire.CommModeMAC:
+		if len(cmd) < 9 {
+			return nil, desfire.StatusLengthError
+		}
+		data := cmd[:len(cmd)-8]
+		mac := cmac.Chained(s.block, s.iv, data)
+		s.iv = mac
+		if subtle.ConstantTimeCompare(mac[:8], cmd[len(cmd)-8:]) != 1 {
+			return nil, desfire.StatusIntegrityError
+		}
+		return data, desfire.StatusSuccess
+	default:
+		s.iv = cmac.Chained(s.block, s.iv, cmd)
+		return cmd, desfire.StatusSuccess
+	}
+}
+
+// protect adds the CMAC to, or enciphers, a response within a session
+func (s *Simulator) protect(data []byte, mode byte) []byte {
+	withStatus := append(append([]byte{}, data...), desfire.StatusSuccess)
+	if mode == desfire.CommModeFull {
+		plain := append(append([]byte{}, data...), crc(withStatus)...)
+		enc := encryptCBC(s.block, s.iv, pad(plain, s.block.BlockSize()))
+		s.iv = enc[len(enc)-s.block.BlockSize():]
+		return enc
+	}
+	mac := cmac.Chained(s.block, s.iv, withStatus)
+	s.iv = mac
+	return append(append([]byte{}, data...), mac[:8]...)
+}
+
+func (s *Simulator) resetAuth() {
+	s.authKeyNo = -1
+	s.block = nil
+	s.iv = nil
+	s.auth = nil
+}
+
+func (s *Simulator) authenticateStep1(frame []byte) ([]byte, byte) {
+	s.resetAuth()
+	if len(frame) != 2 {
+		return nil, desfire.StatusLengthError
+	}
+	keyNo := int(frame[1])
+	if keyNo >= len(s.app.keys) {
+		return nil, desfire.StatusNoSuchKey
+	}
+	k := s.app.keys[keyNo]
+	keyType := k.effectiveType()
+	if (frame[0] == desfire.CmdAuthenticateAES) != (keyType == desfire.KeyTypeAES) {
+		return nil, desfire.StatusAuthenticationError
+	}
+
+	block := blockCipher(keyType, k.value)
+	rndB := make([]byte, rndSize(keyType))
+	rand.Read(rndB)
+	encRndB := encryptCBC(block, make([]byte, block.BlockSize()), rndB)
+	s.auth = &pendingAuth{
+		keyNo:   keyNo,
+		keyType: keyType,
+		block:   block,
+		rndB:    rndB,
+		iv:      encRndB[len(encRndB)-block.BlockSize():],
+	}
+	return encRndB, desfire.StatusAdditionalFrame
+}
+
+func (s *Simulator) authenticateStep2(data []byte) ([]byte, byte) {
+	auth := s.auth
+	s.auth = nil
+	size := len(auth.rndB)
+	bs := auth.block.BlockSize()
+	if len(data) != 2*size {
+		return nil, desfire.StatusLengthError
+	}
+
+	plain := decryptCBC(auth.block, auth.iv, data)
+	rndA := plain[:size]
+	if !bytes.Equal(plain[size:], rotl(auth.rndB)) {
+		return nil, desfire.StatusAuthenticationError
+	}
+	resp := encryptCBC(auth.block, data[len(data)-bs:], rotl(rndA))
+
+	s.authKeyNo = auth.keyNo
+	s.authType = auth.keyType
+	s.block = blockCipher(auth.keyType, sessionKey(auth.keyType, rndA, auth.rndB))
+	s.iv = make([]byte, bs)
+	return resp, desfire.StatusSuccess
+}
+
+// authenticateEV2Step1 starts the EV2 first authentication, which takes AES
+// keys only
+func (s *Simulator) authenticateEV2Step1(frame []byte) ([]byte, byte) {
+	s.resetAuth()
+	if len(frame) < 3 || len(frame) != 3+int(frame[2]) {
+		return nil, desfire.StatusLengthError
+	}
+	keyNo := int(frame[1])
+	if keyNo >= len(s.app.keys) {
+		return nil, desfire.StatusNoSuchKey
+	}
+	k := s.app.keys[keyNo]
+	if k.keyType != desfire.KeyTypeAES {
+		return nil, desfire.StatusAuthenticationError
+	}
+	block := blockCipher(desfire.KeyTypeAES, k.value)
+	rndB := make([]byte, 16)
+	rand.Read(rndB)
+	s.auth = &pendingAuth{keyNo: keyNo, keyType: desfire.KeyTypeAES, block: block, rndB: rndB, ev2: true}
+	return encryptCBC(block, make([]byte, 16), rndB), desfire.StatusAdditionalFrame
+}
+
+// authenticateEV2Step2 checks RndB' and answers TI, RndA' and empty
+// capabilities. No session follows, see Simulator.
+func (s *Simulator) authenticateEV2Step2(data []byte) ([]byte, byte) {
+	auth := s.auth
+	s.auth = nil
+	if len(data) != 32 {
+		return nil, desfire.StatusLengthError
+	}
+	plain := decryptCBC(auth.block, make([]byte, 16), data)
+	if !bytes.Equal(plain[16:], rotl(auth.rndB)) {
+		return nil, desfire.StatusAuthenticationError
+	}
+	ti := make([]byte, 4)
+	rand.Read(ti)
+	resp := append(append(ti, rotl(plain[:16])...), make([]byte, 12)...)
+	return encryptCBC(auth.block, make([]byte, 16), resp), desfire.StatusSuccess
+}
+
+func (s *Simulator) dispatch(cmd []byte) ([]byte, byte, byte) {
+	plain := byte(desfire.CommModePlain)
+	switch cmd[0] {
+	case desfire.CmdGetVersion:
+		return s.getVersion(), plain, desfire.StatusSuccess
+	case desfire.CmdSelectApplication:
+		return nil, respModeNone, s.selectApplication(cmd)
+	case desfire.CmdGetApplicationIDs:
+		return s.getApplicationIDs()
+	case desfire.CmdCreateApplication:
+		return nil, plain, s.createApplication(cmd)
+	case desfire.CmdDeleteApplication:
+		return nil, plain, s.deleteApplication(cmd)
+	case desfire.CmdFormatPICC:
+		return nil, plain, s.formatPICC()
+	case desfire.CmdChangeKey:
+		return s.changeKey(cmd)
+	case desfire.CmdGetKeySettings:
+		return s.getKeySettings()
+	case desfire.CmdChangeKeySettings:
+		return nil, plain, s.changeKeySettings(cmd)
+	case desfire.CmdGetKeyVersion:
+		return s.getKeyVersion(cmd)
+	case desfire.CmdGetFileIDs:
+		return s.getFileIDs()
+	case desfire.CmdGetFileSettings:
+		return s.getFileSettings(cmd)
+	case desfire.CmdChangeFileSettings:
+		return nil, plain, s.changeFileSettings(cmd)
+	case desfire.CmdCreateStdDataFile, desfire.CmdCreateBackupDataFile, desfire.CmdCreateValueFile,
+		desfire.CmdCreateLinearRecordFile, desfire.CmdCreateCyclicRecordFile:
+		return nil, plain, s.createFile(cmd)
+	case desfire.CmdDeleteFile:
+		return nil, plain, s.deleteFile(cmd)
+	case desfire.CmdReadData:
+		return s.readData(cmd)
+	case desfire.CmdWriteData:
+		return nil, plain, s.writeData(cmd)
+	case desfire.CmdGetValue:
+		return s.getValue(cmd)
+	case desfire.CmdCredit, desfire.CmdDebit, desfire.CmdLimitedCredit:
+		return nil, plain, s.changeValue(cmd)
+	case desfire.CmdWriteRecord:
+		return nil, plain, s.writeRecord(cmd)
+	case desfire.CmdReadRecords:
+		return s.readRecords(cmd)
+	case desfire.CmdCommitTransaction:
+		s.commit()
+		return nil, plain, desfire.StatusSuccess
+	case desfire.CmdAbortTransaction:
+		s.abort()
+		return nil, plain, desfire.StatusSuccess
+	case desfire.CmdReadSignature:
+		if s.signature == nil || len(cmd) != 2 {
+			return nil, plain, desfire.StatusIllegalCommand
+		}
+		return append([]byte{}, s.signature...), desfire.CommModeFull, desfire.StatusSuccess
+	}
+	return nil, plain, desfire.StatusIllegalCommand
+}
+
+// UID returns the 7-byte UID
+func (s *Simulator) UID() []byte {
+	return append([]byte{}, s.uid...)
+}
+
+// Remove simulates taking the card off the reader, which discards pending
+// changes and ends the authentication and the application selection
+func (s *Simulator) Remove() {
+	s.abort()
+	s.resetAuth()
+	s.aid = nil
+	s.app = s.picc
+	s.cmdBuf = nil
+	s.cmdExpected = 0
+	s.respFrames = nil
+}
+
+// SetSignature sets the 56 byte originality signature Read_Sig returns
+func (s *Simulator) SetSignature(signature []byte) {
+	s.signature = append([]byte{}, signature...)
+}
+
+func (s *Simulator) getVersion() []byte {
+	version := []byte{
+		0x04, 0x01, 0x01, 0x01, 0x00, 0x1A, 0x05, // hardware: NXP, DESFire, EV1, 8K, ISO 14443-2/3
+		0x04, 0x01, 0x01, 0x01, 0x04, 0x1A, 0x05, // software
+	}
+	version = append(version, s.uid...)
+	version = append(version, 0xBA, 0x44, 0xAF, 0x52, 0x40) // batch number
+	version = append(version, 0x11, 0x25)                   // week and year of production
+	return version
+}
+
+func (s *Simulator) selectApplication(cmd []byte) byte {
+	s.resetAuth()
+	if len(cmd) != 4 {
+		return desfire.StatusLengthError
+	}
+	s.abort()
+	var aid [3]byte
+	copy(aid[:], cmd[1:4])
+	if aid == [3]byte{} {
+		s.aid = nil
+		s.app = s.picc
+		return desfire.StatusSuccess
+	}
+	app, ok := s.apps[aid]
+	if !ok {
+		return desfire.StatusApplicationNotFound
+	}
+	s.aid = &aid
+	s.app = app
+	return desfire.StatusSuccess
+}
+
+// selectDFName answers ISO SELECT by DF name with the ISO status words
+func (s *Simulator) selectDFName(apdu []byte) []byte {
+	lc := int(apdu[4])
+	if len(apdu) < 5+lc {
+		return []byte{0x67, 0x00}
+	}
+	name := apdu[5 : 5+lc]
+	for aid, app := range s.apps {
+		if app.dfName != nil && bytes.Equal(app.dfName, name) {
+			s.resetAuth()
+			s.abort()
+			s.aid = &aid
+			s.app = app
+			return []byte{0x90, 0x00}
+		}
+	}
+	return []byte{0x6A, 0x82}
+}
+
+// masterAuthenticated reports whether the session uses the master key of the
+// selected application
+func (s *Simulator) masterAuthenticated() bool {
+	return s.authKeyNo == 0
+}
+
+// directoryAllowed checks listing rights: free directory access (key setting
+// bit 1) or master key authentication
+func (s *Simulator) directoryAllowed() byte {
+	if s.app.keySettings&0x02 != 0 || s.masterAuthenticated() {
+		return desfire.StatusSuccess
+	}
+	return desfire.StatusAuthenticationError
+}
+
+// createDeleteAllowed checks rights to create or delete: free create/delete
+// (key setting bit 2) or master key authentication
+func (s *Simulator) createDeleteAllowed() byte {
+	if s.app.keySettings&0x04 != 0 || s.masterAuthenticated() {
+		return desfire.StatusSuccess
+	}
+	return desfire.StatusAuthenticationError
+}
+
+func (s *Simulator) getApplicationIDs() ([]byte, byte, byte) {
+	if s.aid != nil {
+		return nil, 0, desfire.StatusIllegalCommand
+	}
+	if status := s.directoryAllowed(); status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	var resp []byte
+	for aid := range s.apps {
+		resp = append(resp, aid[:]...)
+	}
+	return resp, desfire.CommModePlain, desfire.StatusSuccess
+}
+
+func (s *Simulator) createApplication(cmd []byte) byte {
+	if s.aid != nil {
+		return desfire.StatusIllegalCommand
+	}
+	// ISO applications carry the file ID and a DF name
+	iso := len(cmd) >= 6 && cmd[5]&0x20 != 0
+	if (!iso && len(cmd) != 6) || (iso && (len(cmd) < 9 || len(cmd) > 24)) {
+		return desfire.StatusLengthError
+	}
+	if status := s.createDeleteAllowed(); status != desfire.StatusSuccess {
+		return status
+	}
+	var aid [3]byte
+	copy(aid[:], cmd[1:4])
+	if aid == [3]byte{} {
+		return desfire.StatusParameterError
+	}
+	if _, ok := s.apps[aid]; ok {
+		return desfire.StatusDuplicateError
+	}
+	numKeys := int(cmd[5] & 0x0F)
+	if numKeys < 1 || numKeys > 14 {
+		return desfire.StatusParameterError
+	}
+	keyType := byte(desfire.KeyTypeDES)
+	switch cmd[5] & 0xC0 {
+	case 0x40:
+		keyType = desfire.KeyType3K3DES
+	case 0x80:
+		keyType = desfire.KeyTypeAES
+	}
+
+	app := &application{
+		keySettings: cmd[4],
+		files:       make(map[byte]*file),
+	}
+	if iso {
+		app.dfName = append([]byte{}, cmd[8:]...)
+	}
+	for i := 0; i < numKeys; i++ {
+		app.keys = append(app.keys, newDefaultKey(keyType))
+	}
+	s.apps[aid] = app
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) deleteApplication(cmd []byte) byte {
+	if s.aid != nil {
+		return desfire.StatusIllegalCommand
+	}
+	if len(cmd) != 4 {
+		return desfire.StatusLengthError
+	}
+	if status := s.createDeleteAllowed(); status != desfire.StatusSuccess {
+		return status
+	}
+	var aid [3]byte
+	copy(aid[:], cmd[1:4])
+	if _, ok := s.apps[aid]; !ok {
+		return desfire.StatusApplicationNotFound
+	}
+	delete(s.apps, aid)
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) formatPICC() byte {
+	if s.aid != nil {
+		return desfire.StatusIllegalCommand
+	}
+	if !s.masterAuthenticated() {
+		return desfire.StatusAuthenticationError
+	}
+	s.apps = make(map[[3]byte]*application)
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) changeKey(cmd []byte) ([]byte, byte, byte) {
+	if s.authKeyNo < 0 {
+		return nil, 0, desfire.StatusAuthenticationError
+	}
+	if len(cmd) < 3 {
+		return nil, 0, desfire.StatusLengthError
+	}
+	keyNo := int(cmd[1] & 0x0F)
+	if keyNo >= len(s.app.keys) {
+		return nil, 0, desfire.StatusNoSuchKey
+	}
+
+	// Check the right to change the key
+	changeRight := int(s.app.keySettings >> 4)
+	switch {
+	case keyNo == 0:
+		if s.authKeyNo != 0 || s.app.keySettings&0x01 == 0 {
+			return nil, 0, desfire.StatusPermissionDenied
+		}
+	case changeRight == 0x0F:
+		return nil, 0, desfire.StatusPermissionDenied
+	case changeRight == 0x0E:
+		if s.authKeyNo != keyNo {
+			return nil, 0, desfire.StatusAuthenticationError
+		}
+	default:
+		if s.authKeyNo != changeRight {
+			return nil, 0, desfire.StatusAuthenticationError
+		}
+	}
+
+	// Determine the type of the new key; the PICC master key type is
+	// selected by the flags of the key number
+	old := s.app.keys[keyNo]
+	keyType := old.keyType
+	if s.aid == nil {
+		switch cmd[1] & 0xC0 {
+		case 0x40:
+			keyType = desfire.KeyType3K3DES
+		case 0x80:
+			keyType = desfire.KeyTypeAES
+		default:
+			keyType = desfire.KeyTypeDES
+		}
+	}
+	keyLen := 16
+	if keyType == desfire.KeyType3K3DES {
+		keyLen = 24
+	}
+	dataLen := keyLen
+	if keyType == desfire.KeyTypeAES {
+		dataLen++
+	}
+
+	bs := s.block.BlockSize()
+	body := cmd[2:]
+	if len(body)%bs != 0 {
+		return nil, 0, desfire.StatusLengthError
+	}
+	plain := decryptCBC(s.block, s.iv, body)
+	s.iv = append([]byte{}, body[len(body)-bs:]...)
+
+	sameKey := keyNo == s.authKeyNo
+	need := dataLen + 4
+	if !sameKey {
+		need += 4
+	}
+	if len(plain) < need {
+		return nil, 0, desfire.StatusLengthError
+	}
+	data := plain[:dataLen]
+	if !bytes.Equal(crc(append([]byte{cmd[0], cmd[1]}, data...)), plain[dataLen:dataLen+4]) {
+		return nil, 0, desfire.StatusIntegrityError
+	}
+	newValue := append([]byte{}, data[:keyLen]...)
+	if !sameKey {
+		if len(old.value) != keyLen {
+			return nil, 0, desfire.StatusParameterError
+		}
+		for i := range newValue {
+			newValue[i] ^= old.value[i]
+		}
+		if !bytes.Equal(crc(newValue), plain[dataLen+4:dataLen+8]) {
+			return nil, 0, desfire.StatusIntegrityError
+		}
+	}
+
+	newKey := &key{keyType: keyType, value: newValue}
+	if keyType == desfire.KeyTypeAES {
+		newKey.version = data[keyLen]
+	}
+	s.app.keys[keyNo] = newKey
+
+	if sameKey {
+		// The session key is gone, so is the session
+		s.resetAuth()
+		return nil, respModeNone, desfire.StatusSuccess
+	}
+	return nil, desfire.CommModePlain, desfire.StatusSuccess
+}
+
+func (s *Simulator) getKeySettings() ([]byte, byte, byte) {
+	if status := s.directoryAllowed(); status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	numKeys := byte(len(s.app.keys))
+	switch s.app.keys[0].keyType {
+	case desfire.KeyType3K3DES:
+		numKeys |= 0x40
+	case desfire.KeyTypeAES:
+		numKeys |= 0x80
+	}
+	return []byte{s.app.keySettings, numKeys}, desfire.CommModePlain, desfire.StatusSuccess
+}
+
+func (s *Simulator) changeKeySettings(cmd []byte) byte {
+	if !s.masterAuthenticated() {
+		return desfire.StatusAuthenticationError
+	}
+	if len(cmd) != 2 {
+		return desfire.StatusLengthError
+	}
+	if s.app.keySettings&0x08 == 0 {
+		return desfire.StatusPermissionDenied
+	}
+	s.app.keySettings = cmd[1]
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) getKeyVersion(cmd []byte) ([]byte, byte, byte) {
+	if len(cmd) != 2 {
+		return nil, 0, desfire.StatusLengthError
+	}
+	keyNo := int(cmd[1] & 0x0F)
+	if keyNo >= len(s.app.keys) {
+		return nil, 0, desfire.StatusNoSuchKey
+	}
+	return []byte{s.app.keys[keyNo].keyVersion()}, desfire.CommModePlain, desfire.StatusSuccess
+}
+
+func (s *Simulator) getFileIDs() ([]byte, byte, byte) {
+	if s.aid == nil {
+		return nil, 0, desfire.StatusIllegalCommand
+	}
+	if status := s.directoryAllowed(); status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	var ids []byte
+	for no := 0; no < 32; no++ {
+		if _, ok := s.app.files[byte(no)]; ok {
+			ids = append(ids, byte(no))
+		}
+	}
+	return ids, desfire.CommModePlain, desfire.StatusSuccess
+}
+
+func (s *Simulator) getFileSettings(cmd []byte) ([]byte, byte, byte) {
+	if len(cmd) != 2 {
+		return nil, 0, desfire.StatusLengthError
+	}
+	if status := s.directoryAllowed(); status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	f := s.app.files[cmd[1]]
+	if f == nil {
+		return nil, 0, desfire.StatusFileNotFound
+	}
+
+	resp := []byte{f.fileType, f.commMode}
+	resp = append(resp, f.access.Encode()...)
+	switch f.fileType {
+	case desfire.FileTypeStandardData, desfire.FileTypeBackupData:
+		resp = appendUint24(resp, len(f.data))
+	case desfire.FileTypeValue:
+		resp = binary.LittleEndian.AppendUint32(resp, uint32(f.lower))
+		resp = binary.LittleEndian.AppendUint32(resp, uint32(f.upper))
+		resp = binary.LittleEndian.AppendUint32(resp, uint32(f.limitedCreditValue))
+		if f.limitedCredit {
+			resp = append(resp, 0x01)
+		} else {
+			resp = append(resp, 0x00)
+		}
+	default:
+		resp = appendUint24(resp, f.recordSize)
+		resp = appendUint24(resp, f.maxRecords)
+		resp = appendUint24(resp, len(f.records))
+	}
+	return resp, desfire.CommModePlain, desfire.StatusSuccess
+}
+
+func (s *Simulator) changeFileSettings(cmd []byte) byte {
+	if len(cmd) != 5 {
+		return desfire.StatusLengthError
+	}
+	f := s.app.files[cmd[1]]
+	if f == nil {
+		return desfire.StatusFileNotFound
+	}
+	switch f.access.ChangeAccess {
+	case desfire.AccessFree:
+	case desfire.AccessNever:
+		return desfire.StatusPermissionDenied
+	default:
+		if s.authKeyNo != int(f.access.ChangeAccess) {
+			return desfire.StatusAuthenticationError
+		}
+	}
+	access, _ := desfire.DecodeAccessRights(cmd[3:5])
+	f.commMode = cmd[2] & 0x03
+	f.access = access
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) createFile(cmd []byte) byte {
+	if s.aid == nil {
+		return desfire.StatusIllegalCommand
+	}
+	if status := s.createDeleteAllowed(); status != desfire.StatusSuccess {
+		return status
+	}
+	if len(cmd) < 5 {
+		return desfire.StatusLengthError
+	}
+	fileNo := cmd[1]
+	if fileNo >= 32 {
+		return desfire.StatusParameterError
+	}
+	if _, ok := s.app.files[fileNo]; ok {
+		return desfire.StatusDuplicateError
+	}
+	access, _ := desfire.DecodeAccessRights(cmd[3:5])
+	f := &file{commMode: cmd[2] & 0x03, access: access}
+	params := cmd[5:]
+
+	switch cmd[0] {
+	case desfire.CmdCreateStdDataFile, desfire.CmdCreateBackupDataFile:
+		if len(params) != 3 {
+			return desfire.StatusLengthError
+		}
+		f.fileType = desfire.FileTypeStandardData
+		if cmd[0] == desfire.CmdCreateBackupDataFile {
+			f.fileType = desfire.FileTypeBackupData
+		}
+		f.data = make([]byte, uint24(params))
+	case desfire.CmdCreateValueFile:
+		if len(params) != 13 {
+			return desfire.StatusLengthError
+		}
+		f.fileType = desfire.FileTypeValue
+		f.lower = int32(binary.LittleEndian.Uint32(params[0:4]))
+		f.upper = int32(binary.LittleEndian.Uint32(params[4:8]))
+		f.value = int32(binary.LittleEndian.Uint32(params[8:12]))
+		f.limitedCredit = params[12]&0x01 != 0
+		if f.lower > f.upper || f.value < f.lower || f.value > f.upper {
+			return desfire.StatusBoundaryError
+		}
+	default:
+		if len(params) != 6 {
+			return desfire.StatusLengthError
+		}
+		f.fileType = desfire.FileTypeLinearRecord
+		if cmd[0] == desfire.CmdCreateCyclicRecordFile {
+			f.fileType = desfire.FileTypeCyclicRecord
+		}
+		f.recordSize = int(uint24(params[0:3]))
+		f.maxRecords = int(uint24(params[3:6]))
+		if f.recordSize == 0 || f.maxRecords == 0 {
+			return desfire.StatusParameterError
+		}
+	}
+	s.app.files[fileNo] = f
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) deleteFile(cmd []byte) byte {
+	if s.aid == nil {
+		return desfire.StatusIllegalCommand
+	}
+	if status := s.createDeleteAllowed(); status != desfire.StatusSuccess {
+		return status
+	}
+	if len(cmd) != 2 {
+		return desfire.StatusLengthError
+	}
+	if _, ok := s.app.files[cmd[1]]; !ok {
+		return desfire.StatusFileNotFound
+	}
+	delete(s.app.files, cmd[1])
+	return desfire.StatusSuccess
+}
+
+// access checks whether one of rights grants access to the session and
+// returns the communication mode to apply: plain for free access, the file
+// mode otherwise
+func (s *Simulator) access(f *file, rights ...byte) (byte, byte) {
+	for _, right := range rights {
+		if right == desfire.AccessFree {
+			return desfire.CommModePlain, desfire.StatusSuccess
+		}
+	}
+	for _, right := range rights {
+		if right != desfire.AccessNever && s.authKeyNo == int(right) {
+			return f.commMode, desfire.StatusSuccess
+		}
+	}
+	for _, right := range rights {
+		if right != desfire.AccessNever {
+			return 0, desfire.StatusAuthenticationError
+		}
+	}
+	return 0, desfire.StatusPermissionDenied
+}
+
+// writeMode returns the communication mode of write commands on f
+func (s *Simulator) writeMode(f *file) byte {
+	rights := []byte{f.access.Write, f.access.ReadWrite}
+	if f.fileType == desfire.FileTypeValue {
+		rights = append(rights, f.access.Read)
+	}
+	mode, _ := s.access(f, rights...)
+	return mode
+}
+
+func (s *Simulator) dataFile(cmd []byte, minLen int) (*file, byte) {
+	if s.aid == nil {
+		return nil, desfire.StatusIllegalCommand
+	}
+	if len(cmd) < minLen {
+		return nil, desfire.StatusLengthError
+	}
+	f := s.app.files[cmd[1]]
+	if f == nil {
+		return nil, desfire.StatusFileNotFound
+	}
+	return f, desfire.StatusSuccess
+}
+
+func (s *Simulator) readData(cmd []byte) ([]byte, byte, byte) {
+	f, status := s.dataFile(cmd, 8)
+	if status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	if f.fileType != desfire.FileTypeStandardData && f.fileType != desfire.FileTypeBackupData {
+		return nil, 0, desfire.StatusParameterError
+	}
+	mode, status := s.access(f, f.access.Read, f.access.ReadWrite)
+	if status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	offset := int(uint24(cmd[2:5]))
+	length := int(uint24(cmd[5:8]))
+	if length == 0 {
+		length = len(f.data) - offset
+	}
+	if offset > len(f.data) || length < 0 || offset+length > len(f.data) {
+		return nil, 0, desfire.StatusBoundaryError
+	}
+	return append([]byte{}, f.data[offset:offset+length]...), mode, desfire.StatusSuccess
+}
+
+func (s *Simulator) writeData(cmd []byte) byte {
+	f, status := s.dataFile(cmd, 8)
+	if status != desfire.StatusSuccess {
+		return status
+	}
+	if f.fileType != desfire.FileTypeStandardData && f.fileType != desfire.FileTypeBackupData {
+		return desfire.StatusParameterError
+	}
+	if _, status := s.access(f, f.access.Write, f.access.ReadWrite); status != desfire.StatusSuccess {
+		return status
+	}
+	offset := int(uint24(cmd[2:5]))
+	length := int(uint24(cmd[5:8]))
+	data := cmd[8:]
+	if len(data) != length {
+		return desfire.StatusLengthError
+	}
+	if offset+length > len(f.data) {
+		return desfire.StatusBoundaryError
+	}
+	if f.fileType == desfire.FileTypeStandardData {
+		copy(f.data[offset:], data)
+		return desfire.StatusSuccess
+	}
+	if f.pendingData == nil {
+		f.pendingData = append([]byte{}, f.data...)
+	}
+	copy(f.pendingData[offset:], data)
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) getValue(cmd []byte) ([]byte, byte, byte) {
+	f, status := s.dataFile(cmd, 2)
+	if status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	if f.fileType != desfire.FileTypeValue {
+		return nil, 0, desfire.StatusParameterError
+	}
+	mode, status := s.access(f, f.access.Read, f.access.Write, f.access.ReadWrite)
+	if status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	return binary.LittleEndian.AppendUint32(nil, uint32(f.value)), mode, desfire.StatusSuccess
+}
+
+func (s *Simulator) changeValue(cmd []byte) byte {
+	f, status := s.dataFile(cmd, 6)
+	if status != desfire.StatusSuccess {
+		return status
+	}
+	if f.fileType != desfire.FileTypeValue {
+		return desfire.StatusParameterError
+	}
+	if len(cmd) != 6 {
+		return desfire.StatusLengthError
+	}
+	amount := int32(binary.LittleEndian.Uint32(cmd[2:6]))
+	if amount < 0 {
+		return desfire.StatusParameterError
+	}
+
+	var rights []byte
+	switch cmd[0] {
+	case desfire.CmdCredit:
+		rights = []byte{f.access.ReadWrite}
+	case desfire.CmdDebit:
+		rights = []byte{f.access.Read, f.access.Write, f.access.ReadWrite}
+	case desfire.CmdLimitedCredit:
+		rights = []byte{f.access.Write, f.access.ReadWrite}
+	}
+	if _, status := s.access(f, rights...); status != desfire.StatusSuccess {
+		return status
+	}
+
+	value := f.value
+	if f.pendingValue != nil {
+		value = *f.pendingValue
+	}
+	switch cmd[0] {
+	case desfire.CmdCredit:
+		value += amount
+	case desfire.CmdDebit:
+		value -= amount
+		f.debited += amount
+	case desfire.CmdLimitedCredit:
+		if !f.limitedCredit || amount > f.limitedCreditValue {
+			return desfire.StatusBoundaryError
+		}
+		value += amount
+		f.limitedCreditValue = 0
+	}
+	if value < f.lower || value > f.upper {
+		return desfire.StatusBoundaryError
+	}
+	f.pendingValue = &value
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) writeRecord(cmd []byte) byte {
+	f, status := s.dataFile(cmd, 8)
+	if status != desfire.StatusSuccess {
+		return status
+	}
+	if f.fileType != desfire.FileTypeLinearRecord && f.fileType != desfire.FileTypeCyclicRecord {
+		return desfire.StatusParameterError
+	}
+	if _, status := s.access(f, f.access.Write, f.access.ReadWrite); status != desfire.StatusSuccess {
+		return status
+	}
+	offset := int(uint24(cmd[2:5]))
+	length := int(uint24(cmd[5:8]))
+	data := cmd[8:]
+	if len(data) != length {
+		return desfire.StatusLengthError
+	}
+	if offset+length > f.recordSize {
+		return desfire.StatusBoundaryError
+	}
+	if f.fileType == desfire.FileTypeLinearRecord && len(f.records)+len(f.pendingRecords) >= f.maxRecords {
+		return desfire.StatusBoundaryError
+	}
+	record := make([]byte, f.recordSize)
+	copy(record[offset:], data)
+	f.pendingRecords = append(f.pendingRecords, record)
+	return desfire.StatusSuccess
+}
+
+func (s *Simulator) readRecords(cmd []byte) ([]byte, byte, byte) {
+	f, status := s.dataFile(cmd, 8)
+	if status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+	if f.fileType != desfire.FileTypeLinearRecord && f.fileType != desfire.FileTypeCyclicRecord {
+		return nil, 0, desfire.StatusParameterError
+	}
+	mode, status := s.access(f, f.access.Read, f.access.ReadWrite)
+	if status != desfire.StatusSuccess {
+		return nil, 0, status
+	}
+
+	// The offset counts back from the newest record, records are returned
+	// oldest first
+	offset := int(uint24(cmd[2:5]))
+	count := int(uint24(cmd[5:8]))
+	if offset >= len(f.records) {
+		return nil, 0, desfire.StatusBoundaryError
+	}
+	end := len(f.records) - offset
+	if count == 0 {
+		count = end
+	}
+	if count > end {
+		return nil, 0, desfire.StatusBoundaryError
+	}
+	var resp []byte
+	for _, record := range f.records[end-count : end] {
+		resp = append(resp, record...)
+	}
+	return resp, mode, desfire.StatusSuccess
+}
+
+// commit applies the pending changes of all files in the selected application
+func (s *Simulator) commit() {
+	for _, f := range s.app.files {
+		if f.pendingData != nil {
+			f.data = f.pendingData
+		}
+		if f.pendingValue != nil {
+			f.value = *f.pendingValue
+			if f.debited > 0 {
+				f.limitedCreditValue = f.debited
+			}
+		}
+		for _, record := range f.pendingRecords {
+			if f.fileType == desfire.FileTypeCyclicRecord && len(f.records) >= f.maxRecords {
+				f.records = f.records[1:]
+			}
+			f.records = append(f.records, record)
+		}
+	}
+	s.abort()
+}
+
+// abort discards the pending changes of all files in the selected application
+func (s *Simulator) abort() {
+	for _, f := range s.app.files {
+		f.pendingData = nil
+		f.pendingValue = nil
+		f.pendingRecords = nil
+		f.debited = 0
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func appendUint24(b []byte, v int) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16))
+}
+
+// String summarizes the card contents for debugging
+func (s *Simulator) String() string {
+	return fmt.Sprintf("DESFire simulator UID %X, %d applications", s.uid, len(s.apps))
+}
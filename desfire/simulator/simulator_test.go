@@ -0,0 +1,115 @@
+package simulator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/desfire"
+)
+
+var (
+	testAID = []byte{0x01, 0x02, 0x03}
+	aesKey  = make([]byte, 16)
+)
+
+// newSession returns a client on a fresh card, authenticated with AES key 0
+// of an application holding file 1 enciphered with the given access rights
+func newSession(t *testing.T, access desfire.AccessRights) *desfire.DESFire {
+	t.Helper()
+	df := desfire.NewDESFireWithTransport(NewSimulator([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}))
+	if err := df.CreateApplication(testAID, 0x0F, 0x82); err != nil {
+		t.Fatalf("CreateApplication: %v", err)
+	}
+	if err := df.SelectApplication(testAID); err != nil {
+		t.Fatalf("SelectApplication: %v", err)
+	}
+	if err := df.AuthenticateAES(0, aesKey); err != nil {
+		t.Fatalf("AuthenticateAES: %v", err)
+	}
+	if err := df.CreateStdDataFile(1, desfire.CommModeFull, access, 32); err != nil {
+		t.Fatalf("CreateStdDataFile: %v", err)
+	}
+	return df
+}
+
+func TestEncipheredWriteData(t *testing.T) {
+	access := desfire.AccessRights{Read: 0, Write: 0, ReadWrite: 0, ChangeAccess: 0}
+	// Lengths whose padding holds 4 or more zeros, and some that do not
+	for _, n := range []int{1, 4, 7, 8, 12, 16, 23, 28, 32} {
+		df := newSession(t, access)
+		data := bytes.Repeat([]byte{0xA5}, n)
+		if err := df.WriteData(1, 0, data); err != nil {
+			t.Fatalf("WriteData of %d bytes: %v", n, err)
+		}
+		got, err := df.ReadData(1, 0, n)
+		if err != nil {
+			t.Fatalf("ReadData of %d bytes: %v", n, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("read % X after writing % X", got, data)
+		}
+	}
+}
+
+func TestEncipheredWriteDataOfZeros(t *testing.T) {
+	df := newSession(t, desfire.AccessRights{Read: 0, Write: 0, ReadWrite: 0, ChangeAccess: 0})
+	if err := df.WriteData(1, 0, []byte{0xFF, 0xFF}); err != nil {
+		t.Fatalf("WriteData: %v", err)
+	}
+	if err := df.WriteData(1, 0, make([]byte, 8)); err != nil {
+		t.Fatalf("WriteData: %v", err)
+	}
+	got, err := df.ReadData(1, 0, 8)
+	if err != nil {
+		t.Fatalf("ReadData: %v", err)
+	}
+	if !bytes.Equal(got, make([]byte, 8)) {
+		t.Fatalf("read % X", got)
+	}
+}
+
+func TestEncipheredChangeFileSettings(t *testing.T) {
+	df := newSession(t, desfire.AccessRights{Read: 0, Write: 0, ReadWrite: 0, ChangeAccess: 0})
+	if err := df.ChangeFileSettings(1, desfire.CommModePlain, desfire.FreeAccess); err != nil {
+		t.Fatalf("ChangeFileSettings: %v", err)
+	}
+	fs, err := df.GetFileSettings(1)
+	if err != nil {
+		t.Fatalf("GetFileSettings: %v", err)
+	}
+	if fs.CommMode != desfire.CommModePlain || fs.AccessRights != desfire.FreeAccess {
+		t.Fatalf("settings %+v", fs)
+	}
+}
+
+func TestEncipheredChangeKeySettings(t *testing.T) {
+	df := newSession(t, desfire.FreeAccess)
+	if _, err := df.Transceive([]byte{desfire.CmdChangeKeySettings, 0x0B}); err != nil {
+		t.Fatalf("ChangeKeySettings: %v", err)
+	}
+	settings, err := df.Transceive([]byte{desfire.CmdGetKeySettings})
+	if err != nil {
+		t.Fatalf("GetKeySettings: %v", err)
+	}
+	if settings[0] != 0x0B {
+		t.Fatalf("key settings %02X", settings[0])
+	}
+}
+
+func TestEncipheredCredit(t *testing.T) {
+	df := newSession(t, desfire.FreeAccess)
+	access := desfire.AccessRights{Read: 0, Write: 0, ReadWrite: 0, ChangeAccess: 0}
+	if err := df.CreateValueFile(2, desfire.CommModeFull, access, 0, 1000, 10, false); err != nil {
+		t.Fatalf("CreateValueFile: %v", err)
+	}
+	if err := df.Credit(2, 5); err != nil {
+		t.Fatalf("Credit: %v", err)
+	}
+	if err := df.CommitTransaction(); err != nil {
+		t.Fatalf("CommitTransaction: %v", err)
+	}
+	value, err := df.GetValue(2)
+	if err != nil || value != 15 {
+		t.Fatalf("GetValue = %d, %v", value, err)
+	}
+}
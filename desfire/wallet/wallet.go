@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/desfire"
+)
+
+// ErrInsufficientFunds is returned by Pay when the balance doesn't cover the
+// amount
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// Key references an application key used to access the wallet
+type Key struct {
+	No   byte
+	Type byte // desfire.KeyTypeAES, desfire.KeyType3DES or desfire.KeyType3K3DES
+	Key  []byte
+}
+
+// Config describes where the wallet value file lives and which keys grant
+// access to it
+type Config struct {
+	AID    []byte
+	FileNo byte
+
+	// DebitKey grants reading the balance and paying (read or write access
+	// of the value file)
+	DebitKey Key
+
+	// CreditKey grants topping up (read&write access of the value file)
+	CreditKey Key
+}
+
+// Wallet is a stored-value purse on a DESFire value file
+type Wallet struct {
+	df     *desfire.DESFire
+	config Config
+}
+
+// NewWallet creates a wallet on top of a connected DESFire card
+func NewWallet(df *desfire.DESFire, config Config) *Wallet {
+	return &Wallet{
+		df:     df,
+		config: config,
+	}
+}
+
+// Balance returns the current balance
+func (w *Wallet) Balance() (int32, error) {
+	if err := w.open(w.config.DebitKey); err != nil {
+		return 0, err
+	}
+
+	balance, err := w.df.GetValue(w.config.FileNo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read balance: %w", err)
+	}
+	return balance, nil
+}
+
+// Pay debits amount from the wallet and returns the new balance
+func (w *Wallet) Pay(amount int32) (int32, error) {
+	if amount <= 0 {
+		return 0, fmt.Errorf("amount must be positive")
+	}
+
+	if err := w.open(w.config.DebitKey); err != nil {
+		return 0, err
+	}
+
+	balance, err := w.df.GetValue(w.config.FileNo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read balance: %w", err)
+	}
+	if balance < amount {
+		return balance, ErrInsufficientFunds
+	}
+
+	if err := w.df.Debit(w.config.FileNo, amount); err != nil {
+		return 0, w.abort(fmt.Errorf("debit failed: %w", err))
+	}
+	if err := w.df.CommitTransaction(); err != nil {
+		return 0, w.abort(fmt.Errorf("commit failed: %w", err))
+	}
+
+	return balance - amount, nil
+}
+
+// TopUp credits amount to the wallet and returns the new balance
+func (w *Wallet) TopUp(amount int32) (int32, error) {
+	if amount <= 0 {
+		return 0, fmt.Errorf("amount must be positive")
+	}
+
+	if err := w.open(w.config.CreditKey); err != nil {
+		return 0, err
+	}
+
+	balance, err := w.df.GetValue(w.config.FileNo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read balance: %w", err)
+	}
+
+	if err := w.df.Credit(w.config.FileNo, amount); err != nil {
+		return 0, w.abort(fmt.Errorf("credit failed: %w", err))
+	}
+	if err := w.df.CommitTransaction(); err != nil {
+		return 0, w.abort(fmt.Errorf("commit failed: %w", err))
+	}
+
+	return balance + amount, nil
+}
+
+// open selects the wallet application and authenticates with key
+func (w *Wallet) open(key Key) error {
+	if err := w.df.SelectApplication(w.config.AID); err != nil {
+		return fmt.Errorf("failed to select wallet application: %w", err)
+	}
+
+	var err error
+	switch key.Type {
+	case desfire.KeyTypeAES:
+		err = w.df.AuthenticateAES(key.No, key.Key)
+	case desfire.KeyType3DES, desfire.KeyType3K3DES:
+		err = w.df.Authenticate3DES(key.No, key.Key)
+	default:
+		return fmt.Errorf("unsupported key type: 0x%02X", key.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with key %d: %w", key.No, err)
+	}
+	return nil
+}
+
+// abort discards the pending transaction after err; a failure to abort is
+// not reported as the card drops uncommitted changes on deselection anyway
+func (w *Wallet) abort(err error) error {
+	w.df.AbortTransaction()
+	return err
+}
@@ -0,0 +1,137 @@
+package wallet_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/desfire/simulator"
+	"github.com/oo-developer/acr122u/desfire/wallet"
+)
+
+var (
+	aid       = []byte{0x57, 0x4C, 0x54}
+	debitKey  = wallet.Key{No: 1, Type: desfire.KeyTypeAES, Key: bytes.Repeat([]byte{0x11}, 16)}
+	creditKey = wallet.Key{No: 2, Type: desfire.KeyTypeAES, Key: bytes.Repeat([]byte{0x22}, 16)}
+)
+
+// newCard returns a client on a card with a wallet application: key 1 may
+// read and debit, key 2 also credit, the value file holds 500 of at most
+// 10000 and is enciphered
+func newCard(t *testing.T) *desfire.DESFire {
+	t.Helper()
+	df := desfire.NewDESFireWithTransport(simulator.NewSimulator([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}))
+	if err := df.CreateApplication(aid, 0x0F, 0x83); err != nil {
+		t.Fatalf("CreateApplication: %v", err)
+	}
+	if err := df.SelectApplication(aid); err != nil {
+		t.Fatalf("SelectApplication: %v", err)
+	}
+	if err := df.AuthenticateAES(0, make([]byte, 16)); err != nil {
+		t.Fatalf("AuthenticateAES: %v", err)
+	}
+	for _, k := range []wallet.Key{debitKey, creditKey} {
+		if err := df.ChangeKey(k.No, k.Type, k.Key, 1, make([]byte, 16)); err != nil {
+			t.Fatalf("ChangeKey: %v", err)
+		}
+	}
+	access := desfire.AccessRights{Read: 1, Write: 1, ReadWrite: 2, ChangeAccess: 0}
+	if err := df.CreateValueFile(1, desfire.CommModeFull, access, 0, 10000, 500, false); err != nil {
+		t.Fatalf("CreateValueFile: %v", err)
+	}
+	return df
+}
+
+// newWallet returns a wallet on a new card
+func newWallet(t *testing.T) *wallet.Wallet {
+	t.Helper()
+	return wallet.NewWallet(newCard(t), wallet.Config{AID: aid, FileNo: 1, DebitKey: debitKey, CreditKey: creditKey})
+}
+
+func TestWallet(t *testing.T) {
+	w := newWallet(t)
+	if balance, err := w.Balance(); err != nil || balance != 500 {
+		t.Fatalf("Balance: %d, %v", balance, err)
+	}
+	if balance, err := w.Pay(200); err != nil || balance != 300 {
+		t.Fatalf("Pay: %d, %v", balance, err)
+	}
+	if balance, err := w.Pay(400); !errors.Is(err, wallet.ErrInsufficientFunds) || balance != 300 {
+		t.Fatalf("Pay beyond the balance: %d, %v", balance, err)
+	}
+	if balance, err := w.TopUp(1000); err != nil || balance != 1300 {
+		t.Fatalf("TopUp: %d, %v", balance, err)
+	}
+	// Beyond the upper limit the card rejects the credit
+	if _, err := w.TopUp(9000); err == nil {
+		t.Fatalf("TopUp beyond the upper limit succeeded")
+	}
+	if balance, err := w.Balance(); err != nil || balance != 1300 {
+		t.Fatalf("Balance after a failed top-up: %d, %v", balance, err)
+	}
+	for _, amount := range []int32{0, -1} {
+		if _, err := w.Pay(amount); err == nil {
+			t.Errorf("Pay(%d) succeeded", amount)
+		}
+		if _, err := w.TopUp(amount); err == nil {
+			t.Errorf("TopUp(%d) succeeded", amount)
+		}
+	}
+}
+
+func TestWalletKeys(t *testing.T) {
+	wrong := wallet.Key{No: 1, Type: desfire.KeyTypeAES, Key: bytes.Repeat([]byte{0x33}, 16)}
+	for _, tc := range []struct {
+		name       string
+		config     wallet.Config
+		topUp, pay bool // whether topping up and paying work
+	}{
+		{
+			name:   "debit key only",
+			config: wallet.Config{AID: aid, FileNo: 1, DebitKey: debitKey, CreditKey: debitKey},
+			pay:    true,
+		},
+		{
+			name:   "credit key for both",
+			config: wallet.Config{AID: aid, FileNo: 1, DebitKey: creditKey, CreditKey: creditKey},
+			topUp:  true, pay: true,
+		},
+		{
+			name:   "wrong key",
+			config: wallet.Config{AID: aid, FileNo: 1, DebitKey: wrong, CreditKey: wrong},
+		},
+		{
+			name: "unsupported key type",
+			config: wallet.Config{AID: aid, FileNo: 1, CreditKey: creditKey,
+				DebitKey: wallet.Key{No: 1, Type: desfire.KeyTypeDES, Key: make([]byte, 8)}},
+			topUp: true,
+		},
+		{
+			name:   "missing application",
+			config: wallet.Config{AID: []byte{0x01, 0x02, 0x03}, FileNo: 1, DebitKey: debitKey, CreditKey: creditKey},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := wallet.NewWallet(newCard(t), tc.config)
+			want := int32(500)
+			balance, err := w.TopUp(100)
+			if tc.topUp {
+				want += 100
+				if err != nil || balance != want {
+					t.Fatalf("TopUp: %d, %v", balance, err)
+				}
+			} else if err == nil {
+				t.Fatalf("TopUp succeeded")
+			}
+			balance, err = w.Pay(100)
+			if tc.pay {
+				if err != nil || balance != want-100 {
+					t.Fatalf("Pay: %d, %v", balance, err)
+				}
+			} else if err == nil {
+				t.Fatalf("Pay succeeded")
+			}
+		})
+	}
+}
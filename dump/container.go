@@ -0,0 +1,160 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// containerMagic starts every container file
+var containerMagic = []byte("ACRDUMP\x00")
+
+// ContainerVersion is the container format version written. Readers accept
+// older versions and refuse newer ones.
+const ContainerVersion = 1
+
+// containerHeader is the JSON header of a container. The file is the magic,
+// the version and the header length as big-endian uint16 and uint32, the
+// header, and the image data of header.Size bytes.
+type containerHeader struct {
+	Type     string            `json:"type"`
+	UID      string            `json:"uid"`
+	ATQA     string            `json:"atqa,omitempty"`
+	SAK      string            `json:"sak,omitempty"`
+	ATR      string            `json:"atr,omitempty"`
+	Captured *time.Time        `json:"captured,omitempty"`
+	Size     int               `json:"size"`
+	Regions  []containerRegion `json:"regions,omitempty"`
+	Keys     map[string]string `json:"keys,omitempty"`
+}
+
+type containerRegion struct {
+	Name   string `json:"name"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Status Status `json:"status"`
+}
+
+func encodeContainer(w io.Writer, img *Image) error {
+	if img.Type == "" || len(img.UID) == 0 {
+		return fmt.Errorf("container images need a type and UID")
+	}
+	if err := img.checkRegions(); err != nil {
+		return err
+	}
+	header := containerHeader{
+		Type: img.Type,
+		UID:  fmt.Sprintf("%X", img.UID),
+		ATQA: fmt.Sprintf("%X", img.ATQA),
+		ATR:  fmt.Sprintf("%X", img.ATR),
+		Size: len(img.Data),
+	}
+	if img.ATQA != nil {
+		header.SAK = fmt.Sprintf("%02X", img.SAK)
+	}
+	if !img.Captured.IsZero() {
+		t := img.Captured.UTC()
+		header.Captured = &t
+	}
+	for _, r := range img.Regions {
+		header.Regions = append(header.Regions, containerRegion(r))
+	}
+	if len(img.Keys) > 0 {
+		header.Keys = make(map[string]string)
+		for name, key := range img.Keys {
+			header.Keys[name] = fmt.Sprintf("%X", key)
+		}
+	}
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("failed to encode dump: %v", err)
+	}
+	var buf bytes.Buffer
+	buf.Write(containerMagic)
+	buf.Write(binary.BigEndian.AppendUint16(nil, ContainerVersion))
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(raw))))
+	buf.Write(raw)
+	buf.Write(img.Data)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func decodeContainer(data []byte, img *Image) error {
+	if !bytes.HasPrefix(data, containerMagic) {
+		return fmt.Errorf("not a dump container")
+	}
+	data = data[len(containerMagic):]
+	if len(data) < 6 {
+		return fmt.Errorf("container header truncated")
+	}
+	version := binary.BigEndian.Uint16(data)
+	if version == 0 || version > ContainerVersion {
+		return fmt.Errorf("unsupported container version %d", version)
+	}
+	n := int(binary.BigEndian.Uint32(data[2:]))
+	data = data[6:]
+	if n > len(data) {
+		return fmt.Errorf("container header truncated")
+	}
+	var header containerHeader
+	if err := json.Unmarshal(data[:n], &header); err != nil {
+		return fmt.Errorf("invalid container header: %v", err)
+	}
+	data = data[n:]
+	if header.Size != len(data) {
+		return fmt.Errorf("container holds %d bytes of data, header says %d", len(data), header.Size)
+	}
+
+	var err error
+	img.Type = header.Type
+	if img.UID, err = hex.DecodeString(header.UID); err != nil || len(img.UID) == 0 || img.Type == "" {
+		return fmt.Errorf("container header lacks type or UID")
+	}
+	if header.ATQA != "" {
+		if img.ATQA, err = hex.DecodeString(header.ATQA); err != nil || len(img.ATQA) != 2 {
+			return fmt.Errorf("invalid ATQA %q", header.ATQA)
+		}
+		sak, err := hex.DecodeString(header.SAK)
+		if err != nil || len(sak) != 1 {
+			return fmt.Errorf("invalid SAK %q", header.SAK)
+		}
+		img.SAK = sak[0]
+	}
+	if header.ATR != "" {
+		if img.ATR, err = hex.DecodeString(header.ATR); err != nil {
+			return fmt.Errorf("invalid ATR: %v", err)
+		}
+	}
+	if header.Captured != nil {
+		img.Captured = *header.Captured
+	}
+	for _, r := range header.Regions {
+		img.Regions = append(img.Regions, Region(r))
+	}
+	for name, text := range header.Keys {
+		key, err := hex.DecodeString(text)
+		if err != nil {
+			return fmt.Errorf("invalid key %q: %v", name, err)
+		}
+		if img.Keys == nil {
+			img.Keys = make(map[string][]byte)
+		}
+		img.Keys[name] = key
+	}
+	img.Data = append([]byte{}, data...)
+	return img.checkRegions()
+}
+
+// checkRegions verifies that the regions lie within the data
+func (img *Image) checkRegions() error {
+	for _, r := range img.Regions {
+		if r.Offset < 0 || r.Length < 0 || r.Offset+r.Length > len(img.Data) {
+			return fmt.Errorf("region %q exceeds the data", r.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,500 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Image is the memory of a card. The file formats of other tools hold
+// MIFARE Classic and NTAG/Ultralight memory only; the container format holds
+// any card and everything below.
+type Image struct {
+	Type string // e.g. "MIFARE Classic 1K" or "NTAG215"
+	UID  []byte
+	ATQA []byte // most significant byte first, as readers display it
+	SAK  byte
+	Data []byte // all blocks or pages from block or page 0
+
+	ATR      []byte
+	Captured time.Time
+	// Regions describe how parts of Data were read; Data is complete and
+	// read successfully if there are none
+	Regions []Region
+	// Keys maps names like "sector 3 key A" to the keys used for reading
+	Keys map[string][]byte
+}
+
+// Status tells how a region of an image was read
+type Status string
+
+const (
+	StatusOK         Status = "ok"
+	StatusUnreadable Status = "unreadable" // not read, the data is zeros
+	StatusProtected  Status = "protected"  // read as zeros by the card, e.g. keys
+)
+
+// Region is a named part of the image data, e.g. a sector
+type Region struct {
+	Name   string
+	Offset int
+	Length int
+	Status Status
+}
+
+// Complete reports whether all regions were read
+func (img *Image) Complete() bool {
+	for _, r := range img.Regions {
+		if r.Status == StatusUnreadable {
+			return false
+		}
+	}
+	return true
+}
+
+// layout describes the cards an image can hold, identified by memory size
+type layout struct {
+	name        string
+	size        int
+	blockSize   int // 16 for Classic blocks, 4 for pages
+	atqa        []byte
+	sak         byte
+	flipperType string
+	version     []byte // GET_VERSION response, nil if not supported
+}
+
+var layouts = []layout{
+	{name: "MIFARE Mini", size: 320, blockSize: 16, atqa: []byte{0x00, 0x04}, sak: 0x09, flipperType: "MINI"},
+	{name: "MIFARE Classic 1K", size: 1024, blockSize: 16, atqa: []byte{0x00, 0x04}, sak: 0x08, flipperType: "1K"},
+	{name: "MIFARE Classic 2K", size: 2048, blockSize: 16, atqa: []byte{0x00, 0x04}, sak: 0x19, flipperType: "2K"},
+	{name: "MIFARE Classic 4K", size: 4096, blockSize: 16, atqa: []byte{0x00, 0x02}, sak: 0x18, flipperType: "4K"},
+	{name: "MIFARE Ultralight", size: 64, blockSize: 4, atqa: []byte{0x00, 0x44}, flipperType: "Mifare Ultralight"},
+	{name: "MIFARE Ultralight EV1 (MF0UL11)", size: 80, blockSize: 4, atqa: []byte{0x00, 0x44}, flipperType: "Mifare Ultralight 11",
+		version: []byte{0x00, 0x04, 0x03, 0x01, 0x01, 0x00, 0x0B, 0x03}},
+	{name: "MIFARE Ultralight EV1 (MF0UL21)", size: 164, blockSize: 4, atqa: []byte{0x00, 0x44}, flipperType: "Mifare Ultralight 21",
+		version: []byte{0x00, 0x04, 0x03, 0x01, 0x01, 0x00, 0x0E, 0x03}},
+	{name: "NTAG213", size: 180, blockSize: 4, atqa: []byte{0x00, 0x44}, flipperType: "NTAG213",
+		version: []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x0F, 0x03}},
+	{name: "NTAG215", size: 540, blockSize: 4, atqa: []byte{0x00, 0x44}, flipperType: "NTAG215",
+		version: []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x11, 0x03}},
+	{name: "NTAG216", size: 924, blockSize: 4, atqa: []byte{0x00, 0x44}, flipperType: "NTAG216",
+		version: []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x13, 0x03}},
+}
+
+func layoutFor(size int) (layout, error) {
+	for _, l := range layouts {
+		if l.size == size {
+			return l, nil
+		}
+	}
+	return layout{}, fmt.Errorf("unsupported dump size %d", size)
+}
+
+// Classic reports whether the image holds 16-byte Classic blocks rather
+// than 4-byte pages
+func (img *Image) Classic() bool {
+	l, err := layoutFor(len(img.Data))
+	return err == nil && l.blockSize == 16
+}
+
+// complete fills in what a format does not store: the type and UID from the
+// memory, ATQA and SAK from the type's defaults
+func (img *Image) complete() error {
+	l, err := layoutFor(len(img.Data))
+	if err != nil {
+		if img.Type != "" && img.UID != nil {
+			// Container images of other cards hold all there is
+			return nil
+		}
+		return err
+	}
+	if img.Type == "" {
+		img.Type = l.name
+	}
+	if img.UID == nil {
+		if l.blockSize == 16 {
+			img.UID = append([]byte{}, img.Data[0:4]...)
+		} else {
+			img.UID = append(append([]byte{}, img.Data[0:3]...), img.Data[4:8]...)
+		}
+	}
+	if img.ATQA == nil {
+		img.ATQA = append([]byte{}, l.atqa...)
+		img.SAK = l.sak
+	}
+	return nil
+}
+
+// Format is a file format for images
+type Format int
+
+const (
+	Binary    Format = iota // raw memory (.bin, .mfd, .dump)
+	EML                     // hex, one block or page per line (Proxmark .eml)
+	JSON                    // JSON with type, UID, ATQA, SAK and hex blocks (.json)
+	Flipper                 // Flipper Zero NFC device file (.nfc)
+	Container               // this package's container keeping all image fields (.acrdump)
+)
+
+var formatNames = map[Format]string{
+	Binary:    "binary",
+	EML:       "eml",
+	JSON:      "json",
+	Flipper:   "flipper",
+	Container: "container",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// FormatFor returns the format of a file by its extension
+func FormatFor(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".bin", ".mfd", ".dump":
+		return Binary, nil
+	case ".eml":
+		return EML, nil
+	case ".json":
+		return JSON, nil
+	case ".nfc":
+		return Flipper, nil
+	case ".acrdump":
+		return Container, nil
+	}
+	return 0, fmt.Errorf("unknown dump format of %s", path)
+}
+
+// Encode writes an image in the format
+func Encode(w io.Writer, img *Image, format Format) error {
+	if _, err := layoutFor(len(img.Data)); err != nil && format != Container {
+		return err
+	}
+	full := *img
+	if err := full.complete(); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	switch format {
+	case Binary:
+		buf.Write(full.Data)
+	case EML:
+		for _, block := range full.blocks() {
+			fmt.Fprintf(&buf, "%X\n", block)
+		}
+	case JSON:
+		if err := encodeJSON(&buf, &full); err != nil {
+			return err
+		}
+	case Flipper:
+		encodeFlipper(&buf, &full)
+	case Container:
+		if err := encodeContainer(&buf, &full); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown dump format %v", format)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write dump: %v", err)
+	}
+	return nil
+}
+
+// Decode reads an image in the format. Formats that do not store the type,
+// UID, ATQA or SAK get them from the memory size and the manufacturer block.
+func Decode(r io.Reader, format Format) (*Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump: %v", err)
+	}
+	if bytes.HasPrefix(data, encryptedMagic) {
+		return nil, ErrEncrypted
+	}
+	img := &Image{}
+	switch format {
+	case Binary:
+		img.Data = data
+	case EML:
+		img.Data, err = decodeEML(data)
+	case JSON:
+		err = decodeJSON(data, img)
+	case Flipper:
+		err = decodeFlipper(data, img)
+	case Container:
+		err = decodeContainer(data, img)
+	default:
+		err = fmt.Errorf("unknown dump format %v", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := img.complete(); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// Load reads an image file, choosing the format by extension
+func Load(path string) (*Image, error) {
+	format, err := FormatFor(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dump: %v", err)
+	}
+	defer f.Close()
+	img, err := Decode(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// Save writes an image file, choosing the format by extension
+func Save(path string, img *Image) error {
+	format, err := FormatFor(path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, format); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to save dump: %v", err)
+	}
+	return nil
+}
+
+// blocks splits the memory into Classic blocks or pages
+func (img *Image) blocks() [][]byte {
+	size := 4
+	if img.Classic() {
+		size = 16
+	}
+	var blocks [][]byte
+	for i := 0; i < len(img.Data); i += size {
+		blocks = append(blocks, img.Data[i:i+size])
+	}
+	return blocks
+}
+
+func decodeEML(data []byte) ([]byte, error) {
+	var dump []byte
+	size := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		block, err := hex.DecodeString(text)
+		if err != nil || len(block) != 4 && len(block) != 16 || size != 0 && len(block) != size {
+			return nil, fmt.Errorf("line %d: invalid block", line)
+		}
+		size = len(block)
+		dump = append(dump, block...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dump: %v", err)
+	}
+	return dump, nil
+}
+
+// imageJSON is the JSON representation of an image:
+//
+//	{"type":"NTAG215","uid":"04A1B2C3D4E5F6","atqa":"0044","sak":"00","blocks":["04A1B2..."]}
+type imageJSON struct {
+	Type   string   `json:"type"`
+	UID    string   `json:"uid"`
+	ATQA   string   `json:"atqa"`
+	SAK    string   `json:"sak"`
+	Blocks []string `json:"blocks"`
+}
+
+func encodeJSON(w io.Writer, img *Image) error {
+	record := imageJSON{
+		Type: img.Type,
+		UID:  fmt.Sprintf("%X", img.UID),
+		ATQA: fmt.Sprintf("%X", img.ATQA),
+		SAK:  fmt.Sprintf("%02X", img.SAK),
+	}
+	for _, block := range img.blocks() {
+		record.Blocks = append(record.Blocks, fmt.Sprintf("%X", block))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to encode dump: %v", err)
+	}
+	return nil
+}
+
+func decodeJSON(data []byte, img *Image) error {
+	var record imageJSON
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("failed to parse dump: %v", err)
+	}
+	var err error
+	if img.UID, err = hex.DecodeString(record.UID); err != nil {
+		return fmt.Errorf("invalid UID: %v", err)
+	}
+	if img.ATQA, err = hex.DecodeString(record.ATQA); err != nil || len(img.ATQA) != 2 {
+		return fmt.Errorf("invalid ATQA %q", record.ATQA)
+	}
+	sak, err := hex.DecodeString(record.SAK)
+	if err != nil || len(sak) != 1 {
+		return fmt.Errorf("invalid SAK %q", record.SAK)
+	}
+	img.Type, img.SAK = record.Type, sak[0]
+	for i, text := range record.Blocks {
+		block, err := hex.DecodeString(text)
+		if err != nil {
+			return fmt.Errorf("block %d: %v", i, err)
+		}
+		img.Data = append(img.Data, block...)
+	}
+	return nil
+}
+
+// encodeFlipper writes the Flipper NFC device format version 4. Fields the
+// image does not hold, like the originality signature and the counters, are
+// written as zeros.
+func encodeFlipper(w io.Writer, img *Image) {
+	l, _ := layoutFor(len(img.Data))
+	fmt.Fprintf(w, "Filetype: Flipper NFC device\nVersion: 4\n")
+	if l.blockSize == 16 {
+		fmt.Fprintf(w, "Device type: Mifare Classic\n")
+	} else {
+		fmt.Fprintf(w, "Device type: NTAG/Ultralight\n")
+	}
+	fmt.Fprintf(w, "UID: %s\n", flipperHex(img.UID))
+	fmt.Fprintf(w, "ATQA: %s\nSAK: %02X\n", flipperHex(img.ATQA), img.SAK)
+	if l.blockSize == 16 {
+		fmt.Fprintf(w, "Mifare Classic type: %s\nData format version: 2\n", l.flipperType)
+		for i, block := range img.blocks() {
+			fmt.Fprintf(w, "Block %d: %s\n", i, flipperHex(block))
+		}
+		return
+	}
+	fmt.Fprintf(w, "Data format version: 2\nNTAG/Ultralight type: %s\n", l.flipperType)
+	fmt.Fprintf(w, "Signature: %s\n", flipperHex(make([]byte, 32)))
+	version := l.version
+	if version == nil {
+		version = make([]byte, 8)
+	}
+	fmt.Fprintf(w, "Mifare version: %s\n", flipperHex(version))
+	for i := range 3 {
+		fmt.Fprintf(w, "Counter %d: 0\nTearing %d: 00\n", i, i)
+	}
+	pages := img.blocks()
+	fmt.Fprintf(w, "Pages total: %d\nPages read: %d\n", len(pages), len(pages))
+	for i, page := range pages {
+		fmt.Fprintf(w, "Page %d: %s\n", i, flipperHex(page))
+	}
+	fmt.Fprintf(w, "Failed authentication attempts: 0\n")
+}
+
+func flipperHex(data []byte) string {
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, " ")
+}
+
+// decodeFlipper reads the blocks or pages of a Flipper NFC device file.
+// Bytes Flipper could not read (??) are taken as zeros.
+func decodeFlipper(data []byte, img *Image) error {
+	var blocks [][]byte
+	typeName := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return fmt.Errorf("line %d: expected key: value", line)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case key == "Filetype" && value != "Flipper NFC device":
+			return fmt.Errorf("not a Flipper NFC device file")
+		case key == "UID" || key == "ATQA" || key == "SAK":
+			b, err := parseFlipperHex(value)
+			if err != nil {
+				return fmt.Errorf("line %d: %v", line, err)
+			}
+			switch {
+			case key == "UID":
+				img.UID = b
+			case key == "ATQA" && len(b) == 2:
+				img.ATQA = b
+			case key == "SAK" && len(b) == 1:
+				img.SAK = b[0]
+			default:
+				return fmt.Errorf("line %d: invalid %s", line, key)
+			}
+		case key == "Mifare Classic type" || key == "NTAG/Ultralight type":
+			typeName = value
+		case strings.HasPrefix(key, "Block ") || strings.HasPrefix(key, "Page "):
+			_, number, _ := strings.Cut(key, " ")
+			n, err := strconv.Atoi(number)
+			if err != nil || n != len(blocks) {
+				return fmt.Errorf("line %d: unexpected %s", line, key)
+			}
+			b, err := parseFlipperHex(value)
+			if err != nil {
+				return fmt.Errorf("line %d: %v", line, err)
+			}
+			blocks = append(blocks, b)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read dump: %v", err)
+	}
+	for _, b := range blocks {
+		img.Data = append(img.Data, b...)
+	}
+	l, err := layoutFor(len(img.Data))
+	if err != nil {
+		return err
+	}
+	if typeName != "" && typeName != l.flipperType {
+		return fmt.Errorf("%d bytes do not match type %s", len(img.Data), typeName)
+	}
+	img.Type = l.name
+	return nil
+}
+
+func parseFlipperHex(value string) ([]byte, error) {
+	var out []byte
+	for _, field := range strings.Fields(value) {
+		if field == "??" {
+			out = append(out, 0)
+			continue
+		}
+		b, err := hex.DecodeString(field)
+		if err != nil || len(b) != 1 {
+			return nil, fmt.Errorf("invalid byte %q", field)
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
@@ -0,0 +1,180 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var extensions = map[Format]string{
+	Binary:    ".bin",
+	EML:       ".eml",
+	JSON:      ".json",
+	Flipper:   ".nfc",
+	Container: ".acrdump",
+}
+
+// TestGolden decodes each fixture of testdata and encodes it again, which
+// must give the file byte for byte
+func TestGolden(t *testing.T) {
+	for _, name := range []string{"classic1k", "ntag215"} {
+		binary, err := os.ReadFile(filepath.Join("testdata", name+".bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for format, ext := range extensions {
+			path := filepath.Join("testdata", name+ext)
+			t.Run(filepath.Base(path), func(t *testing.T) {
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				img, err := Decode(bytes.NewReader(want), format)
+				if err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				if !bytes.Equal(img.Data, binary) {
+					t.Fatalf("data differs from %s.bin", name)
+				}
+				var got bytes.Buffer
+				if err := Encode(&got, img, format); err != nil {
+					t.Fatalf("Encode: %v", err)
+				}
+				if !bytes.Equal(got.Bytes(), want) {
+					t.Fatalf("encoding differs from the file:\n%s", got.Bytes())
+				}
+			})
+		}
+	}
+}
+
+// TestConvert converts each fixture to every format and back
+func TestConvert(t *testing.T) {
+	for _, name := range []string{"classic1k", "ntag215"} {
+		img, err := Load(filepath.Join("testdata", name+".acrdump"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for format, ext := range extensions {
+			var buf bytes.Buffer
+			if err := Encode(&buf, img, format); err != nil {
+				t.Fatalf("%s to %s: %v", name, ext, err)
+			}
+			back, err := Decode(&buf, format)
+			if err != nil {
+				t.Fatalf("%s from %s: %v", name, ext, err)
+			}
+			if !bytes.Equal(back.Data, img.Data) {
+				t.Fatalf("%s via %s: data differs", name, ext)
+			}
+		}
+	}
+}
+
+// TestContainerVersion reads the golden container as each version
+func TestContainerVersion(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "ntag215.acrdump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		version uint16
+		ok      bool
+	}{
+		{0, false},
+		{ContainerVersion, true},
+		{ContainerVersion + 1, false},
+	} {
+		data := append([]byte{}, golden...)
+		binary.BigEndian.PutUint16(data[len(containerMagic):], tc.version)
+		_, err := Decode(bytes.NewReader(data), Container)
+		if (err == nil) != tc.ok {
+			t.Errorf("version %d: %v", tc.version, err)
+		}
+	}
+}
+
+// TestContainerImages encodes images beyond the known layouts
+func TestContainerImages(t *testing.T) {
+	captured := time.Date(2026, 10, 16, 8, 58, 2, 0, time.UTC)
+	desfire := &Image{
+		Type:     "MIFARE DESFire EV1",
+		UID:      []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		ATQA:     []byte{0x03, 0x44},
+		SAK:      0x20,
+		ATR:      []byte{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80},
+		Data:     []byte("application 000001 file 01"),
+		Captured: captured,
+		Regions: []Region{
+			{Name: "file 01", Length: 26, Status: StatusOK},
+		},
+		Keys: map[string][]byte{"PICC master key": make([]byte, 8)},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, desfire, Container); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	back, err := Decode(&buf, Container)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !reflect.DeepEqual(back, desfire) {
+		t.Fatalf("decoded %+v, want %+v", back, desfire)
+	}
+
+	for _, tc := range []struct {
+		name string
+		img  Image
+	}{
+		{"no type", Image{UID: desfire.UID, Data: desfire.Data}},
+		{"no UID", Image{Type: desfire.Type, Data: desfire.Data}},
+		{"region beyond the data", Image{Type: desfire.Type, UID: desfire.UID, Data: desfire.Data,
+			Regions: []Region{{Name: "file 02", Offset: 20, Length: 10, Status: StatusOK}}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Encode(io.Discard, &tc.img, Container); err == nil {
+				t.Fatalf("Encode accepted the image")
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	img := &Image{}
+	if !img.Complete() {
+		t.Fatalf("image without regions is incomplete")
+	}
+	img.Regions = []Region{{Status: StatusOK}, {Status: StatusProtected}}
+	if !img.Complete() {
+		t.Fatalf("protected region makes the image incomplete")
+	}
+	img.Regions = append(img.Regions, Region{Status: StatusUnreadable})
+	if img.Complete() {
+		t.Fatalf("image with an unreadable region is complete")
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(uint8(EML), []byte("00112233445566778899AABBCCDDEEFF\n"))
+	f.Add(uint8(Flipper), []byte("Filetype: Flipper NFC device\nPage 0: 00 ?? 11 22\n"))
+	f.Fuzz(func(t *testing.T, format uint8, b []byte) {
+		img, err := Decode(bytes.NewReader(b), Format(format%5))
+		if err != nil {
+			return
+		}
+		for f := range extensions {
+			var buf bytes.Buffer
+			if err := Encode(&buf, img, f); err != nil {
+				t.Fatalf("Encode %v: %v", f, err)
+			}
+			if _, err := Decode(&buf, f); err != nil {
+				t.Fatalf("Decode %v: %v", f, err)
+			}
+		}
+	})
+}
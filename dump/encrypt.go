@@ -0,0 +1,220 @@
+package dump
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// encryptedMagic starts every encrypted dump file
+var encryptedMagic = []byte("ACRSEAL\x00")
+
+// ErrEncrypted is returned by Load and Decode for encrypted dumps, which are
+// read with LoadEncrypted or Decrypt
+var ErrEncrypted = errors.New("dump is encrypted")
+
+// ErrWrongSecret is returned when an encrypted dump does not open with the
+// secret, or was modified
+var ErrWrongSecret = errors.New("wrong secret or corrupted dump")
+
+const (
+	kdfNone   = 0 // the secret is the AES key
+	kdfPBKDF2 = 1 // PBKDF2-HMAC-SHA256 of a passphrase
+
+	// pbkdf2Iterations is written to new files, older files keep theirs
+	pbkdf2Iterations = 600000
+	// maxPBKDF2Iterations bounds the work a crafted file can demand
+	maxPBKDF2Iterations = 10000000
+
+	saltSize = 16
+	keySize  = 32
+)
+
+// Secret opens encrypted dumps: a passphrase or a 256 bit key
+type Secret struct {
+	passphrase string
+	key        []byte
+}
+
+// Passphrase returns a secret deriving the key from a passphrase
+func Passphrase(passphrase string) Secret {
+	return Secret{passphrase: passphrase}
+}
+
+// Key returns a secret of a 32 byte AES-256 key
+func Key(key []byte) (Secret, error) {
+	if len(key) != keySize {
+		return Secret{}, fmt.Errorf("key must be %d bytes, not %d", keySize, len(key))
+	}
+	return Secret{key: append([]byte{}, key...)}, nil
+}
+
+// KeyFile returns the secret of a key file holding 32 bytes, raw or as hex
+func KeyFile(path string) (Secret, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Secret{}, fmt.Errorf("failed to read key file: %v", err)
+	}
+	if len(data) != keySize {
+		key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+		if err != nil {
+			return Secret{}, fmt.Errorf("key file %s holds neither %d bytes nor hex", path, keySize)
+		}
+		data = key
+	}
+	return Key(data)
+}
+
+// GenerateKeyFile writes a new random key file as hex, readable only by the
+// owner. An existing file is not overwritten.
+func GenerateKeyFile(path string) error {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate key: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %v", err)
+	}
+	if _, err := fmt.Fprintf(f, "%x\n", key); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write key file: %v", err)
+	}
+	return f.Close()
+}
+
+// deriveKey returns the AES key for the KDF parameters of a file
+func (s Secret) deriveKey(kdf byte, iterations int, salt []byte) ([]byte, error) {
+	switch {
+	case kdf == kdfNone && s.key != nil:
+		return s.key, nil
+	case kdf == kdfPBKDF2 && s.key == nil:
+		if iterations < 1 || iterations > maxPBKDF2Iterations {
+			return nil, fmt.Errorf("invalid PBKDF2 iteration count %d", iterations)
+		}
+		return pbkdf2.Key(sha256.New, s.passphrase, salt, iterations, keySize)
+	case kdf == kdfNone:
+		return nil, fmt.Errorf("dump is encrypted with a key, not a passphrase")
+	case kdf == kdfPBKDF2:
+		return nil, fmt.Errorf("dump is encrypted with a passphrase, not a key")
+	}
+	return nil, fmt.Errorf("unknown key derivation %d", kdf)
+}
+
+// Encrypt writes an image in the container format, encrypted with
+// AES-256-GCM. The file is the magic "ACRSEAL\0", the key derivation (0 for
+// a key, 1 for PBKDF2-HMAC-SHA256 of a passphrase), the iteration count as
+// big-endian uint32, a 16 byte salt, the 12 byte nonce and the sealed
+// container. Everything before the sealed data is authenticated with it.
+func Encrypt(w io.Writer, img *Image, secret Secret) error {
+	var plain bytes.Buffer
+	if err := Encode(&plain, img, Container); err != nil {
+		return err
+	}
+	header := append([]byte{}, encryptedMagic...)
+	kdf, iterations := byte(kdfNone), 0
+	if secret.key == nil {
+		kdf, iterations = kdfPBKDF2, pbkdf2Iterations
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %v", err)
+	}
+	header = append(header, kdf)
+	header = binary.BigEndian.AppendUint32(header, uint32(iterations))
+	header = append(header, salt...)
+
+	key, err := secret.deriveKey(kdf, iterations, salt)
+	if err != nil {
+		return err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	header = append(header, nonce...)
+	_, err = w.Write(aead.Seal(header, nonce, plain.Bytes(), header))
+	return err
+}
+
+// Decrypt reads an image written by Encrypt
+func Decrypt(r io.Reader, secret Secret) (*Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump: %v", err)
+	}
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return nil, fmt.Errorf("dump is not encrypted")
+	}
+	headerSize := len(encryptedMagic) + 1 + 4 + saltSize
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("encrypted dump truncated")
+	}
+	kdf := data[len(encryptedMagic)]
+	iterations := int(binary.BigEndian.Uint32(data[len(encryptedMagic)+1:]))
+	salt := data[headerSize-saltSize : headerSize]
+	key, err := secret.deriveKey(kdf, iterations, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	headerSize += aead.NonceSize()
+	if len(data) < headerSize+aead.Overhead() {
+		return nil, fmt.Errorf("encrypted dump truncated")
+	}
+	plain, err := aead.Open(nil, data[headerSize-aead.NonceSize():headerSize], data[headerSize:], data[:headerSize])
+	if err != nil {
+		return nil, ErrWrongSecret
+	}
+	return Decode(bytes.NewReader(plain), Container)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// LoadEncrypted reads an encrypted image file of any name
+func LoadEncrypted(path string, secret Secret) (*Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dump: %v", err)
+	}
+	defer f.Close()
+	img, err := Decrypt(f, secret)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// SaveEncrypted writes an encrypted image file, readable only by the owner
+func SaveEncrypted(path string, img *Image, secret Secret) error {
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, img, secret); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to save dump: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package dump
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sealed returns the classic fixture with a key map, the part of a dump
+// most worth protecting
+func sealed(t testing.TB) *Image {
+	t.Helper()
+	img, err := Load(filepath.Join("testdata", "classic1k.acrdump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.Keys = map[string][]byte{"sector 0 key A": {0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}}
+	return img
+}
+
+func TestEncryptPassphrase(t *testing.T) {
+	img := sealed(t)
+	path := filepath.Join(t.TempDir(), "badge.acrdump")
+	if err := SaveEncrypted(path, img, Passphrase("correct horse")); err != nil {
+		t.Fatalf("SaveEncrypted: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("sector 0")) || bytes.Contains(raw, img.Data[:16]) {
+		t.Fatalf("encrypted dump holds plaintext")
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("Stat: %v, %v", fi.Mode(), err)
+	}
+
+	if _, err := Load(path); !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := LoadEncrypted(path, Passphrase("wrong horse")); !errors.Is(err, ErrWrongSecret) {
+		t.Fatalf("LoadEncrypted with a wrong passphrase: %v", err)
+	}
+	back, err := LoadEncrypted(path, Passphrase("correct horse"))
+	if err != nil {
+		t.Fatalf("LoadEncrypted: %v", err)
+	}
+	if !bytes.Equal(back.Data, img.Data) || !bytes.Equal(back.Keys["sector 0 key A"], img.Keys["sector 0 key A"]) {
+		t.Fatalf("decrypted %+v", back)
+	}
+}
+
+func TestEncryptKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dump.key")
+	if err := GenerateKeyFile(path); err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("Stat: %v, %v", fi.Mode(), err)
+	}
+	if err := GenerateKeyFile(path); err == nil {
+		t.Fatalf("GenerateKeyFile overwrote the key file")
+	}
+	secret, err := KeyFile(path)
+	if err != nil {
+		t.Fatalf("KeyFile: %v", err)
+	}
+
+	// Raw keys work as well as hex
+	raw := filepath.Join(dir, "raw.key")
+	if err := os.WriteFile(raw, secret.key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if rawSecret, err := KeyFile(raw); err != nil || !bytes.Equal(rawSecret.key, secret.key) {
+		t.Fatalf("KeyFile of a raw key: %v", err)
+	}
+	if err := os.WriteFile(raw, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := KeyFile(raw); err == nil {
+		t.Fatalf("KeyFile accepted a short key")
+	}
+
+	var buf bytes.Buffer
+	img := sealed(t)
+	if err := Encrypt(&buf, img, secret); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	enc := buf.Bytes()
+	if _, err := Decode(bytes.NewReader(enc), Container); !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("Decode: %v", err)
+	}
+	if _, err := Decrypt(bytes.NewReader(enc), Passphrase("correct horse")); err == nil {
+		t.Fatalf("Decrypt with a passphrase opened a dump sealed with a key")
+	}
+	if back, err := Decrypt(bytes.NewReader(enc), secret); err != nil || !bytes.Equal(back.Data, img.Data) {
+		t.Fatalf("Decrypt: %v", err)
+	}
+}
+
+// TestTamper flips each bit of an encrypted dump in turn, header included
+func TestTamper(t *testing.T) {
+	secret, err := Key(bytes.Repeat([]byte{0x42}, 32))
+	if err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, sealed(t), secret); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	enc := buf.Bytes()
+	for i := range enc {
+		for bit := 0; bit < 8; bit++ {
+			modified := append([]byte{}, enc...)
+			modified[i] ^= 1 << bit
+			if _, err := Decrypt(bytes.NewReader(modified), secret); err == nil {
+				t.Fatalf("Decrypt accepted a flipped bit %d of byte %d", bit, i)
+			}
+		}
+	}
+	if _, err := Decrypt(bytes.NewReader(enc[:len(enc)-1]), secret); !errors.Is(err, ErrWrongSecret) {
+		t.Fatalf("Decrypt of a truncated dump: %v", err)
+	}
+}
+
+func FuzzDecrypt(f *testing.F) {
+	secret, err := Key(make([]byte, 32))
+	if err != nil {
+		f.Fatalf("Key: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, sealed(f), secret); err != nil {
+		f.Fatalf("Encrypt: %v", err)
+	}
+	f.Add(buf.Bytes())
+	f.Fuzz(func(t *testing.T, b []byte) {
+		// Only a key secret, the passphrase derivation is too slow to fuzz
+		Decrypt(bytes.NewReader(b), secret)
+	})
+}
@@ -0,0 +1,296 @@
+package emv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// Payment system environment names
+var (
+	PSE  = []byte("1PAY.SYS.DDF01") // contact
+	PPSE = []byte("2PAY.SYS.DDF01") // contactless
+)
+
+// EMV tags used for application selection
+const (
+	TagFCI                  = 0x6F
+	TagDFName               = 0x84
+	TagFCIProprietary       = 0xA5
+	TagSFI                  = 0x88
+	TagFCIIssuer            = 0xBF0C
+	TagApplicationTemplate  = 0x61
+	TagAID                  = 0x4F
+	TagApplicationLabel     = 0x50
+	TagPriority             = 0x87
+	TagPreferredName        = 0x9F12
+	TagLanguagePreference   = 0x5F2D
+	TagIssuerCodeTableIndex = 0x9F11
+	TagPDOL                 = 0x9F38
+	TagRecordTemplate       = 0x70
+)
+
+// schemes maps registered application provider IDs to payment schemes
+var schemes = map[string]string{
+	"A000000003": "Visa",
+	"A000000004": "Mastercard",
+	"A000000025": "American Express",
+	"A000000065": "JCB",
+	"A000000152": "Discover",
+	"A000000324": "Discover",
+	"A000000333": "UnionPay",
+	"A000000277": "Interac",
+	"A000000524": "RuPay",
+	"A000000042": "CB",
+	"A000000359": "girocard",
+	"A000000141": "Dankort",
+	"A000000098": "Visa (US debit)",
+	"A000000172": "Mir",
+	"A000000658": "Mir",
+}
+
+// knownAIDs are selected directly on cards without PSE/PPSE
+var knownAIDs = []string{
+	"A0000000031010",       // Visa credit/debit
+	"A0000000032010",       // Visa Electron
+	"A0000000041010",       // Mastercard
+	"A0000000043060",       // Maestro
+	"A00000002501",         // American Express
+	"A0000000651010",       // JCB
+	"A0000001523010",       // Discover
+	"A000000333010101",     // UnionPay
+	"A0000002771010",       // Interac
+	"A0000003591010028001", // girocard
+	"A0000000421010",       // CB
+	"A0000006581010",       // Mir
+}
+
+// Application describes a payment application without any cardholder data
+type Application struct {
+	AID           []byte
+	Label         string
+	PreferredName string
+	Priority      int // 1 is highest, 0 means none given
+	Language      string
+	Scheme        string
+}
+
+func (a Application) String() string {
+	name := a.PreferredName
+	if name == "" {
+		name = a.Label
+	}
+	return fmt.Sprintf("%s %s (%s)", strings.ToUpper(hex.EncodeToString(a.AID)), name, a.Scheme)
+}
+
+// Scheme returns the payment scheme of an AID, or "Unknown"
+func Scheme(aid []byte) string {
+	if len(aid) < 5 {
+		return "Unknown"
+	}
+	if name, ok := schemes[strings.ToUpper(hex.EncodeToString(aid[:5]))]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// EMV reads the selection data of payment cards
+type EMV struct {
+	card hardware.Transport
+}
+
+// NewEMV creates an EMV handler on the reader's connection
+func NewEMV(reader *hardware.Reader) *EMV {
+	return NewEMVWithTransport(reader.Transport())
+}
+
+// NewEMVWithTransport creates an EMV handler on top of any transport
+func NewEMVWithTransport(transport hardware.Transport) *EMV {
+	return &EMV{card: hardware.NewChainingTransport(transport)}
+}
+
+// transmit sends an APDU, fetching data announced with 61 XX and repeating
+// the command with the Le from 6C XX through the chaining transport. It
+// returns the data and status word.
+func (e *EMV) transmit(apdu []byte) ([]byte, uint16, error) {
+	rsp, err := e.card.Transmit(apdu)
+	if err != nil {
+		return nil, 0, fmt.Errorf("transmit failed: %v", err)
+	}
+	if len(rsp) < 2 {
+		return nil, 0, fmt.Errorf("invalid response length")
+	}
+	sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]
+	return rsp[:len(rsp)-2], uint16(sw1)<<8 | uint16(sw2), nil
+}
+
+// Select sends SELECT by DF name and returns the parsed FCI
+func (e *EMV) Select(name []byte) ([]TLV, error) {
+	apdu := append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(name))}, name...)
+	data, sw, err := e.transmit(append(apdu, 0x00))
+	if err != nil {
+		return nil, err
+	}
+	if sw != 0x9000 {
+		return nil, fmt.Errorf("select %X failed: %04X", name, sw)
+	}
+	return ParseTLV(data)
+}
+
+// ReadRecord reads a record of a short file ID and returns it parsed
+func (e *EMV) ReadRecord(sfi byte, record byte) ([]TLV, error) {
+	data, sw, err := e.transmit([]byte{0x00, 0xB2, record, sfi<<3 | 0x04, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	if sw != 0x9000 {
+		return nil, fmt.Errorf("read record %d of SFI %d failed: %04X", record, sfi, sw)
+	}
+	return ParseTLV(data)
+}
+
+// Applications lists the payment applications of the card: from the PPSE,
+// else from the PSE directory, else by selecting well-known AIDs. The list is
+// sorted by priority.
+func (e *EMV) Applications() ([]Application, error) {
+	apps, err := e.fromPPSE()
+	if err != nil || len(apps) == 0 {
+		apps, err = e.fromPSE()
+	}
+	if err != nil || len(apps) == 0 {
+		apps = e.probeKnownAIDs()
+	}
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no payment application found")
+	}
+	sort.SliceStable(apps, func(i, j int) bool {
+		pi, pj := apps[i].Priority, apps[j].Priority
+		return pi != 0 && (pj == 0 || pi < pj)
+	})
+	return apps, nil
+}
+
+// fromPPSE reads the application templates from the PPSE FCI
+func (e *EMV) fromPPSE() ([]Application, error) {
+	fci, err := e.Select(PPSE)
+	if err != nil {
+		return nil, err
+	}
+	return applications(FindAll(fci, TagApplicationTemplate)), nil
+}
+
+// fromPSE reads the directory records of the PSE
+func (e *EMV) fromPSE() ([]Application, error) {
+	fci, err := e.Select(PSE)
+	if err != nil {
+		return nil, err
+	}
+	sfi, ok := Find(fci, TagSFI)
+	if !ok || len(sfi.Value) != 1 {
+		return nil, fmt.Errorf("PSE without directory SFI")
+	}
+	var templates []TLV
+	for record := byte(1); record <= 16; record++ {
+		tlvs, err := e.ReadRecord(sfi.Value[0], record)
+		if err != nil {
+			break
+		}
+		templates = append(templates, FindAll(tlvs, TagApplicationTemplate)...)
+	}
+	return applications(templates), nil
+}
+
+// probeKnownAIDs selects well-known AIDs one by one
+func (e *EMV) probeKnownAIDs() []Application {
+	var apps []Application
+	for _, s := range knownAIDs {
+		aid, _ := hex.DecodeString(s)
+		fci, err := e.Select(aid)
+		if err != nil {
+			continue
+		}
+		app := applicationFromFCI(fci)
+		if len(app.AID) == 0 {
+			app.AID = aid
+			app.Scheme = Scheme(aid)
+		}
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+func applications(templates []TLV) []Application {
+	var apps []Application
+	for _, t := range templates {
+		app := Application{}
+		for _, c := range t.Children {
+			setField(&app, c)
+		}
+		if len(app.AID) > 0 {
+			app.Scheme = Scheme(app.AID)
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// ApplicationInfo selects an application and returns the public data of its
+// FCI
+func (e *EMV) ApplicationInfo(aid []byte) (Application, error) {
+	fci, err := e.Select(aid)
+	if err != nil {
+		return Application{}, err
+	}
+	app := applicationFromFCI(fci)
+	if len(app.AID) == 0 {
+		app.AID = append([]byte{}, aid...)
+		app.Scheme = Scheme(aid)
+	}
+	return app, nil
+}
+
+func applicationFromFCI(fci []TLV) Application {
+	app := Application{}
+	if name, ok := Find(fci, TagDFName); ok {
+		app.AID = append([]byte{}, name.Value...)
+		app.Scheme = Scheme(app.AID)
+	}
+	if prop, ok := Find(fci, TagFCIProprietary); ok {
+		for _, c := range prop.Children {
+			setField(&app, c)
+		}
+	}
+	return app
+}
+
+// setField copies a selection data object into the application
+func setField(app *Application, t TLV) {
+	switch t.Tag {
+	case TagAID:
+		app.AID = append([]byte{}, t.Value...)
+	case TagApplicationLabel:
+		app.Label = printable(t.Value)
+	case TagPreferredName:
+		app.PreferredName = printable(t.Value)
+	case TagPriority:
+		if len(t.Value) == 1 {
+			app.Priority = int(t.Value[0] & 0x0F)
+		}
+	case TagLanguagePreference:
+		app.Language = printable(t.Value)
+	}
+}
+
+// printable keeps the printable ASCII characters of a label
+func printable(b []byte) string {
+	return string(bytes.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E {
+			return -1
+		}
+		return r
+	}, b))
+}
@@ -0,0 +1,149 @@
+package emv_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/emv"
+)
+
+// card answers APDUs from a table keyed by the command in hex, everything
+// else with 6A 82 (file not found)
+type card map[string][]byte
+
+func (c card) Transmit(cmd []byte) ([]byte, error) {
+	if rsp, ok := c[strings.ToUpper(hex.EncodeToString(cmd))]; ok {
+		return rsp, nil
+	}
+	return []byte{0x6A, 0x82}, nil
+}
+
+// selectCmd returns SELECT by name in hex
+func selectCmd(name []byte) string {
+	apdu := append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(name))}, name...)
+	return strings.ToUpper(hex.EncodeToString(append(apdu, 0x00)))
+}
+
+// ok appends status 90 00
+func ok(data []byte) []byte {
+	return append(data, 0x90, 0x00)
+}
+
+// template returns an application template with AID, label and priority
+func template(aid string, label string, priority byte) []byte {
+	id, _ := hex.DecodeString(aid)
+	return tlv([]byte{0x61},
+		tlv([]byte{0x4F}, id),
+		tlv([]byte{0x50}, []byte(label)),
+		tlv([]byte{0x87}, []byte{priority}))
+}
+
+func TestApplications(t *testing.T) {
+	visa, _ := hex.DecodeString("A0000000031010")
+	for _, tc := range []struct {
+		name string
+		card card
+		want []string
+	}{
+		{
+			name: "PPSE",
+			card: card{
+				selectCmd(emv.PPSE): ok(tlv([]byte{0x6F},
+					tlv([]byte{0x84}, emv.PPSE),
+					tlv([]byte{0xA5}, tlv([]byte{0xBF, 0x0C},
+						template("A0000000041010", "MASTERCARD", 0x02),
+						template("A0000000031010", "VISA", 0x01))))),
+			},
+			want: []string{
+				"A0000000031010 VISA (Visa)",
+				"A0000000041010 MASTERCARD (Mastercard)",
+			},
+		},
+		{
+			// Directory in SFI 1, records 1 and 2, then 6A 83
+			name: "PSE records",
+			card: card{
+				selectCmd(emv.PSE): ok(tlv([]byte{0x6F},
+					tlv([]byte{0x84}, emv.PSE),
+					tlv([]byte{0xA5}, tlv([]byte{0x88}, []byte{0x01})))),
+				"00B2010C00": ok(tlv([]byte{0x70}, template("A0000001523010", "DISCOVER", 0x00))),
+				"00B2020C00": ok(tlv([]byte{0x70}, template("A0000000043060", "MAESTRO", 0x01))),
+				"00B2030C00": {0x6A, 0x83},
+			},
+			want: []string{
+				"A0000000043060 MAESTRO (Mastercard)",
+				"A0000001523010 DISCOVER (Discover)",
+			},
+		},
+		{
+			name: "known AIDs",
+			card: card{
+				selectCmd(visa): ok(tlv([]byte{0x6F},
+					tlv([]byte{0x84}, visa),
+					tlv([]byte{0xA5}, tlv([]byte{0x50}, []byte("VISA DEBIT\x00"))))),
+			},
+			want: []string{"A0000000031010 VISA DEBIT (Visa)"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			apps, err := emv.NewEMVWithTransport(tc.card).Applications()
+			if err != nil {
+				t.Fatalf("Applications: %v", err)
+			}
+			var got []string
+			for _, app := range apps {
+				got = append(got, app.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("applications\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+
+	if _, err := emv.NewEMVWithTransport(card{}).Applications(); err == nil {
+		t.Errorf("Applications of a card without payment applications succeeded")
+	}
+}
+
+func TestApplicationInfo(t *testing.T) {
+	aid, _ := hex.DecodeString("A0000000041010")
+	c := card{
+		selectCmd(aid): ok(tlv([]byte{0x6F},
+			tlv([]byte{0x84}, aid),
+			tlv([]byte{0xA5},
+				tlv([]byte{0x50}, []byte("MASTERCARD")),
+				tlv([]byte{0x9F, 0x12}, []byte("Debit Mastercard")),
+				tlv([]byte{0x87}, []byte{0x81}),
+				tlv([]byte{0x5F, 0x2D}, []byte("deen"))))),
+	}
+	app, err := emv.NewEMVWithTransport(c).ApplicationInfo(aid)
+	if err != nil {
+		t.Fatalf("ApplicationInfo: %v", err)
+	}
+	// The priority keeps the low four bits, bit 8 only asks for confirmation
+	if app.Label != "MASTERCARD" || app.PreferredName != "Debit Mastercard" || app.Priority != 1 || app.Language != "deen" {
+		t.Errorf("application %+v", app)
+	}
+	if app.String() != "A0000000041010 Debit Mastercard (Mastercard)" {
+		t.Errorf("String() = %q", app.String())
+	}
+	if _, err := emv.NewEMVWithTransport(c).ApplicationInfo([]byte{0xA0, 0x00, 0x00, 0x00, 0x25}); err == nil {
+		t.Errorf("ApplicationInfo of a missing application succeeded")
+	}
+}
+
+func TestScheme(t *testing.T) {
+	for aid, want := range map[string]string{
+		"A0000000031010":   "Visa",
+		"a0000000041010":   "Mastercard",
+		"A000000333010101": "UnionPay",
+		"A0000000":         "Unknown",
+		"D276000085010100": "Unknown",
+	} {
+		b, _ := hex.DecodeString(aid)
+		if got := emv.Scheme(b); got != want {
+			t.Errorf("Scheme(%s) = %s, want %s", aid, got, want)
+		}
+	}
+}
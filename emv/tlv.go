@@ -0,0 +1,108 @@
+package emv
+
+import "fmt"
+
+// TLV is a BER-TLV data object. Constructed objects hold their children in
+// Children instead of Value.
+type TLV struct {
+	Tag      uint32
+	Value    []byte
+	Children []TLV
+}
+
+// constructed reports whether bit 6 of the first tag byte is set
+func constructed(tag uint32) bool {
+	first := tag
+	for first > 0xFF {
+		first >>= 8
+	}
+	return first&0x20 != 0
+}
+
+// ParseTLV decodes a sequence of BER-TLV objects. Padding bytes 00 and FF
+// between objects are skipped.
+func ParseTLV(data []byte) ([]TLV, error) {
+	var tlvs []TLV
+	pos := 0
+	for pos < len(data) {
+		if data[pos] == 0x00 || data[pos] == 0xFF {
+			pos++
+			continue
+		}
+
+		// Tag: more bytes follow if the low five bits are all set, then while
+		// bit 8 is set
+		tag := uint32(data[pos])
+		pos++
+		if tag&0x1F == 0x1F {
+			for {
+				if pos >= len(data) {
+					return nil, fmt.Errorf("tag truncated")
+				}
+				tag = tag<<8 | uint32(data[pos])
+				pos++
+				if data[pos-1]&0x80 == 0 {
+					break
+				}
+			}
+		}
+
+		// Length: short form or 81/82/83 followed by the length bytes
+		if pos >= len(data) {
+			return nil, fmt.Errorf("tag %X: length missing", tag)
+		}
+		n := int(data[pos])
+		pos++
+		if n&0x80 != 0 {
+			count := n & 0x7F
+			if count == 0 || count > 3 || pos+count > len(data) {
+				return nil, fmt.Errorf("tag %X: invalid length", tag)
+			}
+			n = 0
+			for _, b := range data[pos : pos+count] {
+				n = n<<8 | int(b)
+			}
+			pos += count
+		}
+		if pos+n > len(data) {
+			return nil, fmt.Errorf("tag %X: value exceeds data", tag)
+		}
+
+		t := TLV{Tag: tag, Value: data[pos : pos+n]}
+		if constructed(tag) {
+			children, err := ParseTLV(t.Value)
+			if err != nil {
+				return nil, fmt.Errorf("tag %X: %w", tag, err)
+			}
+			t.Children = children
+		}
+		tlvs = append(tlvs, t)
+		pos += n
+	}
+	return tlvs, nil
+}
+
+// Find returns the first object with the tag, searching depth-first
+func Find(tlvs []TLV, tag uint32) (TLV, bool) {
+	for _, t := range tlvs {
+		if t.Tag == tag {
+			return t, true
+		}
+		if found, ok := Find(t.Children, tag); ok {
+			return found, true
+		}
+	}
+	return TLV{}, false
+}
+
+// FindAll returns all objects with the tag, searching depth-first
+func FindAll(tlvs []TLV, tag uint32) []TLV {
+	var found []TLV
+	for _, t := range tlvs {
+		if t.Tag == tag {
+			found = append(found, t)
+		}
+		found = append(found, FindAll(t.Children, tag)...)
+	}
+	return found
+}
@@ -0,0 +1,149 @@
+package emv_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/emv"
+)
+
+// tlv encodes an object with a short or long form length
+func tlv(tag []byte, parts ...[]byte) []byte {
+	value := bytes.Join(parts, nil)
+	out := append([]byte{}, tag...)
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xFF:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+func TestParseTLV(t *testing.T) {
+	long := bytes.Repeat([]byte{0x42}, 0x80)
+	longer := bytes.Repeat([]byte{0x43}, 0x100)
+	for _, tc := range []struct {
+		name string
+		data []byte
+		tags []uint32 // of the top-level objects
+		tag  uint32   // to find, depth-first
+		want []byte
+	}{
+		{
+			name: "primitive",
+			data: []byte{0x5A, 0x02, 0x12, 0x34},
+			tags: []uint32{0x5A},
+			tag:  0x5A, want: []byte{0x12, 0x34},
+		},
+		{
+			name: "two byte tag",
+			data: []byte{0x9F, 0x12, 0x04, 'V', 'I', 'S', 'A'},
+			tags: []uint32{0x9F12},
+			tag:  0x9F12, want: []byte("VISA"),
+		},
+		{
+			name: "three byte tag",
+			data: []byte{0xDF, 0x81, 0x01, 0x01, 0x07},
+			tags: []uint32{0xDF8101},
+			tag:  0xDF8101, want: []byte{0x07},
+		},
+		{
+			name: "long form length 81",
+			data: tlv([]byte{0x50}, long),
+			tags: []uint32{0x50},
+			tag:  0x50, want: long,
+		},
+		{
+			name: "long form length 82",
+			data: tlv([]byte{0x9F, 0x4D}, longer),
+			tags: []uint32{0x9F4D},
+			tag:  0x9F4D, want: longer,
+		},
+		{
+			name: "nested templates",
+			data: tlv([]byte{0x6F},
+				tlv([]byte{0x84}, emv.PPSE),
+				tlv([]byte{0xA5}, tlv([]byte{0xBF, 0x0C},
+					tlv([]byte{0x61}, tlv([]byte{0x4F}, []byte{0xA0, 0x00, 0x00, 0x00, 0x04, 0x10, 0x10}))))),
+			tags: []uint32{0x6F},
+			tag:  0x4F, want: []byte{0xA0, 0x00, 0x00, 0x00, 0x04, 0x10, 0x10},
+		},
+		{
+			name: "padding between objects",
+			data: []byte{0x00, 0x5A, 0x01, 0x11, 0xFF, 0xFF, 0x57, 0x01, 0x22, 0x00},
+			tags: []uint32{0x5A, 0x57},
+			tag:  0x57, want: []byte{0x22},
+		},
+		{
+			name: "empty value",
+			data: []byte{0x50, 0x00, 0x87, 0x01, 0x01},
+			tags: []uint32{0x50, 0x87},
+			tag:  0x50, want: []byte{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tlvs, err := emv.ParseTLV(tc.data)
+			if err != nil {
+				t.Fatalf("ParseTLV: %v", err)
+			}
+			if len(tlvs) != len(tc.tags) {
+				t.Fatalf("%d objects, want %d", len(tlvs), len(tc.tags))
+			}
+			for i, tag := range tc.tags {
+				if tlvs[i].Tag != tag {
+					t.Errorf("object %d tag %X, want %X", i, tlvs[i].Tag, tag)
+				}
+			}
+			found, ok := emv.Find(tlvs, tc.tag)
+			if !ok || !bytes.Equal(found.Value, tc.want) {
+				t.Errorf("Find(%X) = % X, %t", tc.tag, found.Value, ok)
+			}
+		})
+	}
+}
+
+func TestParseTLVErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"tag truncated", []byte{0x9F}},
+		{"tag continuation truncated", []byte{0xDF, 0x81}},
+		{"length missing", []byte{0x5A}},
+		{"value exceeds data", []byte{0x5A, 0x05, 0x01, 0x02}},
+		{"long form without length bytes", []byte{0x5A, 0x80}},
+		{"long form with four length bytes", []byte{0x5A, 0x84, 0x00, 0x00, 0x00, 0x01, 0x01}},
+		{"long form truncated", []byte{0x5A, 0x82, 0x01}},
+		{"long form beyond data", []byte{0x5A, 0x81, 0x80, 0x01}},
+		{"malformed child", []byte{0x6F, 0x03, 0x84, 0x05, 0x01}},
+	} {
+		if tlvs, err := emv.ParseTLV(tc.data); err == nil {
+			t.Errorf("%s: ParseTLV(% X) = %v", tc.name, tc.data, tlvs)
+		}
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	aid := func(last byte) []byte {
+		return tlv([]byte{0x61}, tlv([]byte{0x4F}, []byte{0xA0, 0x00, 0x00, 0x00, 0x03, 0x10, last}))
+	}
+	tlvs, err := emv.ParseTLV(tlv([]byte{0x70}, aid(0x10), tlv([]byte{0xA5}, aid(0x20)), aid(0x30)))
+	if err != nil {
+		t.Fatalf("ParseTLV: %v", err)
+	}
+	found := emv.FindAll(tlvs, emv.TagAID)
+	if len(found) != 3 {
+		t.Fatalf("FindAll found %d AIDs", len(found))
+	}
+	for i, last := range []byte{0x10, 0x20, 0x30} {
+		if v := found[i].Value; v[len(v)-1] != last {
+			t.Errorf("AID %d: % X", i, v)
+		}
+	}
+	if _, ok := emv.Find(tlvs, emv.TagPDOL); ok {
+		t.Errorf("Find returned a missing tag")
+	}
+}
@@ -0,0 +1,272 @@
+package felica
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// FeliCa command codes; responses use the command code plus one
+const (
+	CmdPolling                = 0x00
+	CmdRequestService         = 0x02
+	CmdRequestResponse        = 0x04
+	CmdReadWithoutEncryption  = 0x06
+	CmdWriteWithoutEncryption = 0x08
+	CmdRequestSystemCode      = 0x0C
+)
+
+// System codes
+const (
+	SystemCodeWildcard = 0xFFFF
+	SystemCodeNDEF     = 0x12FC
+	SystemCodeLite     = 0x88B4
+)
+
+// Polling request codes
+const (
+	RequestNone       = 0x00
+	RequestSystemCode = 0x01
+)
+
+// BlockSize is the size of a FeliCa block
+const BlockSize = 16
+
+// PN532 InCommunicateThru, sent as ACR122U direct transmit
+var communicateThru = []byte{0xD4, 0x42}
+
+type FeliCa struct {
+	card       hardware.Transport
+	idm        []byte
+	pmm        []byte
+	systemCode uint16
+}
+
+// NewFeliCa initializes a FeliCa handler for the connected card, whose UID
+// is its IDm
+func NewFeliCa(reader *hardware.Reader) *FeliCa {
+	return &FeliCa{
+		card: reader.Transport(),
+		idm:  append([]byte{}, reader.CardInfo().UID...),
+	}
+}
+
+// NewFeliCaWithTransport creates a FeliCa handler on top of any transport.
+// Call Polling before other commands to learn the IDm.
+func NewFeliCaWithTransport(transport hardware.Transport) *FeliCa {
+	return &FeliCa{card: transport}
+}
+
+// IDm returns the manufacture ID of the card (or of the selected system)
+func (f *FeliCa) IDm() []byte {
+	return f.idm
+}
+
+// PMm returns the manufacture parameter from the last Polling
+func (f *FeliCa) PMm() []byte {
+	return f.pmm
+}
+
+// Transceive sends a FeliCa command frame (without the length byte) through
+// the PN532 and returns the response frame without the length byte
+func (f *FeliCa) Transceive(frame []byte) ([]byte, error) {
+	if len(frame)+1 > 0xFF {
+		return nil, fmt.Errorf("frame too long")
+	}
+	payload := append([]byte{}, communicateThru...)
+	payload = append(payload, byte(len(frame)+1))
+	payload = append(payload, frame...)
+	cmd := append([]byte{0xFF, 0x00, 0x00, 0x00, byte(len(payload))}, payload...)
+
+	rsp, err := f.card.Transmit(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("transmit failed: %w", err)
+	}
+	if len(rsp) < 2 {
+		return nil, fmt.Errorf("invalid response length: %w", hardware.ErrWrongLength)
+	}
+	if sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]; sw1 != 0x90 || sw2 != 0x00 {
+		return nil, fmt.Errorf("error status: %w", hardware.NewStatusError(cmd, rsp))
+	}
+	rsp = rsp[:len(rsp)-2]
+
+	// D5 43, PN532 status, response frame with length byte
+	if len(rsp) < 5 || rsp[0] != 0xD5 || rsp[1] != 0x43 {
+		return nil, fmt.Errorf("invalid PN532 response % X", rsp)
+	}
+	if rsp[2] != 0x00 {
+		return nil, hardware.PN532Error(rsp[2])
+	}
+	rsp = rsp[3:]
+	if int(rsp[0]) != len(rsp) {
+		return nil, fmt.Errorf("invalid FeliCa response length % X", rsp)
+	}
+	if rsp[1] != frame[0]+1 {
+		return nil, fmt.Errorf("unexpected response code %02X", rsp[1])
+	}
+	return rsp[1:], nil
+}
+
+// command sends a command addressed to the IDm and returns the response
+// data behind the echoed IDm
+func (f *FeliCa) command(code byte, body []byte) ([]byte, error) {
+	if len(f.idm) != 8 {
+		return nil, fmt.Errorf("IDm unknown, poll the card first")
+	}
+	frame := append([]byte{code}, f.idm...)
+	rsp, err := f.Transceive(append(frame, body...))
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 9 || !bytes.Equal(rsp[1:9], f.idm) {
+		return nil, fmt.Errorf("response from another card")
+	}
+	return rsp[9:], nil
+}
+
+// statusError checks the status flags of read and write responses
+func statusError(rsp []byte) error {
+	if len(rsp) < 2 {
+		return fmt.Errorf("response without status flags")
+	}
+	if rsp[0] != 0x00 {
+		return fmt.Errorf("FeliCa error: status flags %02X %02X", rsp[0], rsp[1])
+	}
+	return nil
+}
+
+// Polling searches for a card with the system code and stores its IDm and
+// PMm. With RequestSystemCode the card also returns its system code.
+func (f *FeliCa) Polling(systemCode uint16, requestCode byte) error {
+	frame := binary.BigEndian.AppendUint16([]byte{CmdPolling}, systemCode)
+	frame = append(frame, requestCode, 0x00) // one time slot
+	rsp, err := f.Transceive(frame)
+	if err != nil {
+		return err
+	}
+	if len(rsp) < 17 {
+		return fmt.Errorf("polling response too short")
+	}
+	f.idm = append([]byte{}, rsp[1:9]...)
+	f.pmm = append([]byte{}, rsp[9:17]...)
+	f.systemCode = systemCode
+	if requestCode == RequestSystemCode && len(rsp) >= 19 {
+		f.systemCode = binary.BigEndian.Uint16(rsp[17:19])
+	}
+	return nil
+}
+
+// SelectSystem polls for the system code. Cards with several systems answer
+// with a different IDm per system, which later commands address.
+func (f *FeliCa) SelectSystem(systemCode uint16) error {
+	if err := f.Polling(systemCode, RequestSystemCode); err != nil {
+		return fmt.Errorf("system %04X not found: %w", systemCode, err)
+	}
+	return nil
+}
+
+// SystemCode returns the system code from the last Polling
+func (f *FeliCa) SystemCode() uint16 {
+	return f.systemCode
+}
+
+// RequestSystemCode lists the system codes of the card
+func (f *FeliCa) RequestSystemCode() ([]uint16, error) {
+	rsp, err := f.command(CmdRequestSystemCode, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 1 || len(rsp) < 1+2*int(rsp[0]) {
+		return nil, fmt.Errorf("system code response truncated")
+	}
+	codes := make([]uint16, rsp[0])
+	for i := range codes {
+		codes[i] = binary.BigEndian.Uint16(rsp[1+2*i:])
+	}
+	return codes, nil
+}
+
+// RequestService returns the key versions of area or service codes. Nodes
+// that do not exist report 0xFFFF.
+func (f *FeliCa) RequestService(nodeCodes []uint16) ([]uint16, error) {
+	if len(nodeCodes) == 0 || len(nodeCodes) > 32 {
+		return nil, fmt.Errorf("between 1 and 32 node codes allowed")
+	}
+	body := []byte{byte(len(nodeCodes))}
+	for _, code := range nodeCodes {
+		body = binary.LittleEndian.AppendUint16(body, code)
+	}
+	rsp, err := f.command(CmdRequestService, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 1 || int(rsp[0]) != len(nodeCodes) || len(rsp) < 1+2*len(nodeCodes) {
+		return nil, fmt.Errorf("request service response truncated")
+	}
+	versions := make([]uint16, len(nodeCodes))
+	for i := range versions {
+		versions[i] = binary.LittleEndian.Uint16(rsp[1+2*i:])
+	}
+	return versions, nil
+}
+
+// appendBlockList encodes block list elements for the first service of the
+// command: 2 bytes for blocks below 256, 3 bytes otherwise
+func appendBlockList(body []byte, blocks []int) []byte {
+	body = append(body, byte(len(blocks)))
+	for _, block := range blocks {
+		if block < 0x100 {
+			body = append(body, 0x80, byte(block))
+		} else {
+			body = append(body, 0x00, byte(block), byte(block>>8))
+		}
+	}
+	return body
+}
+
+// ReadWithoutEncryption reads blocks of a service that needs no
+// authentication
+func (f *FeliCa) ReadWithoutEncryption(serviceCode uint16, blocks []int) ([]byte, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no blocks to read")
+	}
+	body := binary.LittleEndian.AppendUint16([]byte{0x01}, serviceCode)
+	rsp, err := f.command(CmdReadWithoutEncryption, appendBlockList(body, blocks))
+	if err != nil {
+		return nil, err
+	}
+	if err := statusError(rsp); err != nil {
+		return nil, err
+	}
+	rsp = rsp[2:]
+	if len(rsp) < 1 || int(rsp[0]) != len(blocks) || len(rsp)-1 != len(blocks)*BlockSize {
+		return nil, fmt.Errorf("read response length mismatch")
+	}
+	return rsp[1:], nil
+}
+
+// WriteWithoutEncryption writes blocks of a service that needs no
+// authentication. data holds 16 bytes per block.
+func (f *FeliCa) WriteWithoutEncryption(serviceCode uint16, blocks []int, data []byte) error {
+	if len(blocks) == 0 || len(data) != len(blocks)*BlockSize {
+		return fmt.Errorf("data must hold %d bytes per block", BlockSize)
+	}
+	body := binary.LittleEndian.AppendUint16([]byte{0x01}, serviceCode)
+	body = appendBlockList(body, blocks)
+	rsp, err := f.command(CmdWriteWithoutEncryption, append(body, data...))
+	if err != nil {
+		return err
+	}
+	return statusError(rsp)
+}
+
+// BlockRange returns n block numbers starting at first
+func BlockRange(first int, n int) []int {
+	blocks := make([]int, n)
+	for i := range blocks {
+		blocks[i] = first + i
+	}
+	return blocks
+}
@@ -0,0 +1,234 @@
+package felica
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// FeliCa Lite-S blocks
+const (
+	BlockRC       = 0x80 // random challenge
+	BlockMAC      = 0x81
+	BlockID       = 0x82
+	BlockDID      = 0x83
+	BlockSerC     = 0x84
+	BlockSysC     = 0x85
+	BlockCKV      = 0x86 // card key version
+	BlockCK       = 0x87 // card key
+	BlockMC       = 0x88 // memory configuration
+	BlockWCNT     = 0x90 // write counter
+	BlockMACA     = 0x91
+	BlockState    = 0x92
+	BlockCRCCheck = 0xA0
+)
+
+// FeliCa Lite-S services
+const (
+	ServiceReadOnly  = 0x000B
+	ServiceReadWrite = 0x0009
+)
+
+// ErrMACMismatch is returned when a MAC from the card does not verify
+var ErrMACMismatch = errors.New("MAC mismatch")
+
+// LiteS adds the MAC_A protected access of FeliCa Lite-S cards
+type LiteS struct {
+	*FeliCa
+	sessionKey []byte
+	rc         []byte
+	authed     bool
+}
+
+// NewLiteS wraps a FeliCa handler for a Lite-S card
+func NewLiteS(f *FeliCa) *LiteS {
+	return &LiteS{FeliCa: f}
+}
+
+// Authenticate performs the mutual authentication with the 16-byte card key:
+// a fresh random challenge yields the session key, the card proves the key
+// with a MAC_A over its ID and the reader with a MAC_A write of the STATE
+// block
+func (l *LiteS) Authenticate(cardKey []byte) error {
+	if err := l.StartSession(cardKey); err != nil {
+		return err
+	}
+	if _, err := l.ReadWithMAC([]int{BlockID, BlockCKV}); err != nil {
+		return fmt.Errorf("internal authentication failed: %w", err)
+	}
+	state := make([]byte, BlockSize)
+	state[0] = 0x01 // EXT_AUTH
+	if err := l.WriteWithMAC(BlockState, state); err != nil {
+		return fmt.Errorf("external authentication failed: %w", err)
+	}
+	l.authed = true
+	return nil
+}
+
+// StartSession writes a random challenge and derives the session key used
+// for MACs, without authenticating the reader to the card
+func (l *LiteS) StartSession(cardKey []byte) error {
+	if len(cardKey) != 16 {
+		return fmt.Errorf("card key must be 16 bytes")
+	}
+	rc := make([]byte, BlockSize)
+	if _, err := rand.Read(rc); err != nil {
+		return err
+	}
+	if err := l.WriteWithoutEncryption(ServiceReadWrite, []int{BlockRC}, rc); err != nil {
+		return fmt.Errorf("failed to write random challenge: %w", err)
+	}
+	sk, err := sessionKey(cardKey, rc)
+	if err != nil {
+		return err
+	}
+	l.sessionKey, l.rc, l.authed = sk, rc, false
+	return nil
+}
+
+// Authenticated reports whether the last Authenticate succeeded
+func (l *LiteS) Authenticated() bool {
+	return l.authed
+}
+
+// ReadWithMAC reads up to three blocks together with MAC_A and verifies it
+func (l *LiteS) ReadWithMAC(blocks []int) ([]byte, error) {
+	if l.sessionKey == nil {
+		return nil, fmt.Errorf("no session, authenticate first")
+	}
+	if len(blocks) == 0 || len(blocks) > 3 {
+		return nil, fmt.Errorf("between 1 and 3 blocks allowed")
+	}
+	list := append(append([]int{}, blocks...), BlockMACA)
+	data, err := l.ReadWithoutEncryption(ServiceReadOnly, list)
+	if err != nil {
+		return nil, err
+	}
+	n := len(blocks) * BlockSize
+
+	// Block numbers as 2-byte little-endian values padded with FF, then the
+	// block data
+	txt := make([]byte, 0, 8+n)
+	for _, block := range list {
+		txt = append(txt, byte(block), byte(block>>8))
+	}
+	for len(txt) < 8 {
+		txt = append(txt, 0xFF)
+	}
+	txt = append(txt, data[:n]...)
+	mac, err := liteMAC(txt, l.sessionKey, l.rc[:8], false)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(mac, data[n:n+8]) {
+		return nil, ErrMACMismatch
+	}
+	return data[:n], nil
+}
+
+// WriteWithMAC writes a block together with MAC_A over the write counter,
+// the block number and the data
+func (l *LiteS) WriteWithMAC(block int, data []byte) error {
+	if l.sessionKey == nil {
+		return fmt.Errorf("no session, authenticate first")
+	}
+	if len(data) != BlockSize {
+		return fmt.Errorf("data must be %d bytes", BlockSize)
+	}
+	counter, err := l.ReadWithoutEncryption(ServiceReadOnly, []int{BlockWCNT})
+	if err != nil {
+		return fmt.Errorf("failed to read write counter: %w", err)
+	}
+	wcnt := counter[:3]
+
+	txt := append(append([]byte{}, wcnt...), 0x00, byte(block), 0x00, BlockMACA, 0x00)
+	txt = append(txt, data...)
+	mac, err := liteMAC(txt, l.sessionKey, l.rc[:8], true)
+	if err != nil {
+		return err
+	}
+	macBlock := make([]byte, BlockSize)
+	copy(macBlock, mac)
+	copy(macBlock[8:], wcnt)
+	return l.WriteWithoutEncryption(ServiceReadWrite, []int{block, BlockMACA}, append(append([]byte{}, data...), macBlock...))
+}
+
+// SetCardKey writes the card key and its version. Without a session this only
+// works while the key is not yet locked by the memory configuration.
+func (l *LiteS) SetCardKey(cardKey []byte, version uint16) error {
+	if len(cardKey) != 16 {
+		return fmt.Errorf("card key must be 16 bytes")
+	}
+	ckv := make([]byte, BlockSize)
+	ckv[0], ckv[1] = byte(version), byte(version>>8)
+	write := func(block int, data []byte) error {
+		if l.authed {
+			return l.WriteWithMAC(block, data)
+		}
+		return l.WriteWithoutEncryption(ServiceReadWrite, []int{block}, data)
+	}
+	if err := write(BlockCK, cardKey); err != nil {
+		return fmt.Errorf("failed to write card key: %w", err)
+	}
+	if err := write(BlockCKV, ckv); err != nil {
+		return fmt.Errorf("failed to write card key version: %w", err)
+	}
+	return nil
+}
+
+// Lite-S computations work on 8-byte blocks in reversed byte order
+
+func reversed(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, v := range b {
+		r[len(b)-1-i] = v
+	}
+	return r
+}
+
+// liteCipher returns 2-key 3DES with both key halves byte-reversed,
+// optionally swapped
+func liteCipher(key []byte, swap bool) (cipher.Block, error) {
+	k1, k2 := reversed(key[:8]), reversed(key[8:16])
+	if swap {
+		k1, k2 = k2, k1
+	}
+	return des.NewTripleDESCipher(append(append(append([]byte{}, k1...), k2...), k1...))
+}
+
+// sessionKey derives the session key from the card key and the random
+// challenge: 3DES-CBC with IV zero over both challenge halves
+func sessionKey(cardKey []byte, rc []byte) ([]byte, error) {
+	block, err := liteCipher(cardKey, false)
+	if err != nil {
+		return nil, err
+	}
+	in := append(reversed(rc[:8]), reversed(rc[8:16])...)
+	out := make([]byte, 16)
+	cipher.NewCBCEncrypter(block, make([]byte, 8)).CryptBlocks(out, in)
+	return append(reversed(out[:8]), reversed(out[8:])...), nil
+}
+
+// liteMAC computes a MAC as the last 3DES-CBC block over the data, with the
+// first challenge half as IV. MAC_A on writes uses the swapped session key.
+func liteMAC(data []byte, key []byte, iv []byte, swap bool) ([]byte, error) {
+	if len(data)%8 != 0 {
+		return nil, fmt.Errorf("MAC data must be a multiple of 8 bytes")
+	}
+	block, err := liteCipher(key, swap)
+	if err != nil {
+		return nil, err
+	}
+	mac := reversed(iv)
+	for pos := 0; pos < len(data); pos += 8 {
+		chunk := reversed(data[pos : pos+8])
+		for i := range mac {
+			mac[i] ^= chunk[i]
+		}
+		block.Encrypt(mac, mac)
+	}
+	return reversed(mac), nil
+}
@@ -0,0 +1,209 @@
+package felica
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// unhex decodes hex, ignoring spaces
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+var (
+	testCardKey = []byte{
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF,
+		0xFE, 0xDC, 0xBA, 0x98, 0x76, 0x54, 0x32, 0x10,
+	}
+	testIDm = []byte{0x01, 0x2E, 0x4C, 0xD1, 0x83, 0x0E, 0x52, 0x28}
+)
+
+// TestMACA checks the session key and MAC_A of reads and writes against
+// values computed with OpenSSL 2-key 3DES-CBC on the byte-reversed halves
+func TestMACA(t *testing.T) {
+	rc := unhex(t, "4B6A2C8E9F0D1E37 A5C6E8F1029384B7")
+	sk, err := sessionKey(testCardKey, rc)
+	if err != nil {
+		t.Fatalf("sessionKey: %v", err)
+	}
+	if want := unhex(t, "51FEEB68AC99D87E AA80D8879C133F46"); !bytes.Equal(sk, want) {
+		t.Fatalf("session key % X, want % X", sk, want)
+	}
+
+	for _, tc := range []struct {
+		name string
+		txt  string
+		swap bool
+		want string
+	}{
+		{
+			// Block list ID, CKV and MAC_A, the ID and CKV blocks
+			name: "read",
+			txt: "8200860091 00FFFF" +
+				"0123456789ABCDEF 0000000000000000" +
+				"0100000000000000 0000000000000000",
+			want: "63C438FA8F2F18F5",
+		},
+		{
+			// Write counter 5, the STATE block and MAC_A, EXT_AUTH set
+			name: "write",
+			txt:  "050000 00 92 00 91 00 0100000000000000 0000000000000000",
+			swap: true,
+			want: "B388A3727155746D",
+		},
+	} {
+		mac, err := liteMAC(unhex(t, tc.txt), sk, rc[:8], tc.swap)
+		if err != nil {
+			t.Fatalf("%s: liteMAC: %v", tc.name, err)
+		}
+		if want := unhex(t, tc.want); !bytes.Equal(mac, want) {
+			t.Errorf("%s: MAC_A % X, want % X", tc.name, mac, want)
+		}
+	}
+	if _, err := liteMAC(make([]byte, 12), sk, rc[:8], false); err == nil {
+		t.Errorf("liteMAC accepted 12 bytes")
+	}
+}
+
+// liteS is a FeliCa Lite-S behind the PN532 of the reader
+type liteS struct {
+	t      *testing.T
+	blocks map[int][]byte
+	wcnt   int
+	sk     []byte
+}
+
+func newLiteS(t *testing.T) *liteS {
+	c := &liteS{t: t, blocks: map[int][]byte{}, wcnt: 5}
+	c.blocks[BlockID] = append(append([]byte{}, testIDm...), make([]byte, 8)...)
+	c.blocks[BlockCK] = testCardKey
+	c.blocks[BlockCKV] = append([]byte{0x01}, make([]byte, 15)...)
+	return c
+}
+
+// block returns a block, zero if never written
+func (c *liteS) block(n int) []byte {
+	if b, ok := c.blocks[n]; ok {
+		return b
+	}
+	return make([]byte, BlockSize)
+}
+
+func (c *liteS) Transmit(cmd []byte) ([]byte, error) {
+	// FF 00 00 00 Lc D4 42 length frame
+	frame := cmd[8:]
+	if !bytes.Equal(frame[1:9], testIDm) {
+		c.t.Fatalf("command for IDm % X", frame[1:9])
+	}
+	n := int(frame[12])
+	var blocks []int
+	for i := 0; i < n; i++ {
+		blocks = append(blocks, int(frame[14+2*i]))
+	}
+	data := frame[13+2*n:]
+	rsp := append([]byte{frame[0] + 1}, testIDm...)
+
+	switch frame[0] {
+	case CmdReadWithoutEncryption:
+		rsp = append(rsp, 0x00, 0x00, byte(n))
+		var read []byte
+		for _, b := range blocks {
+			switch b {
+			case BlockMACA:
+				txt := []byte{}
+				for _, b := range blocks {
+					txt = append(txt, byte(b), 0x00)
+				}
+				txt = append(txt, bytes.Repeat([]byte{0xFF}, 8-len(txt))...)
+				mac, err := liteMAC(append(txt, read...), c.sk, c.block(BlockRC)[:8], false)
+				if err != nil {
+					c.t.Fatalf("liteMAC: %v", err)
+				}
+				read = append(read, append(mac, make([]byte, 8)...)...)
+			case BlockWCNT:
+				read = append(read, byte(c.wcnt), byte(c.wcnt>>8), byte(c.wcnt>>16))
+				read = append(read, make([]byte, 13)...)
+			default:
+				read = append(read, c.block(b)...)
+			}
+		}
+		rsp = append(rsp, read...)
+	case CmdWriteWithoutEncryption:
+		if len(blocks) == 2 && blocks[1] == BlockMACA {
+			w := []byte{byte(c.wcnt), byte(c.wcnt >> 8), byte(c.wcnt >> 16)}
+			txt := append(append([]byte{}, w...), 0x00, byte(blocks[0]), 0x00, BlockMACA, 0x00)
+			mac, err := liteMAC(append(txt, data[:BlockSize]...), c.sk, c.block(BlockRC)[:8], true)
+			if err != nil {
+				c.t.Fatalf("liteMAC: %v", err)
+			}
+			if !bytes.Equal(data[BlockSize:BlockSize+8], mac) || !bytes.Equal(data[BlockSize+8:BlockSize+11], w) {
+				rsp = append(rsp, 0x01, 0xA6)
+				break
+			}
+			c.wcnt++
+			blocks, data = blocks[:1], data[:BlockSize]
+		}
+		for i, b := range blocks {
+			c.blocks[b] = append([]byte{}, data[i*BlockSize:(i+1)*BlockSize]...)
+			if b == BlockRC {
+				var err error
+				if c.sk, err = sessionKey(c.block(BlockCK), c.blocks[BlockRC]); err != nil {
+					c.t.Fatalf("sessionKey: %v", err)
+				}
+			}
+		}
+		rsp = append(rsp, 0x00, 0x00)
+	default:
+		c.t.Fatalf("unexpected command % X", frame)
+	}
+	return append(append([]byte{0xD5, 0x43, 0x00, byte(len(rsp) + 1)}, rsp...), 0x90, 0x00), nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	card := newLiteS(t)
+	f := NewFeliCaWithTransport(card)
+	f.idm = testIDm
+	l := NewLiteS(f)
+
+	if _, err := l.ReadWithMAC([]int{BlockID}); err == nil {
+		t.Fatalf("ReadWithMAC without a session succeeded")
+	}
+	wrong := bytes.Repeat([]byte{0xA5}, 16)
+	if err := l.Authenticate(wrong); !errors.Is(err, ErrMACMismatch) {
+		t.Fatalf("Authenticate with a wrong key: %v", err)
+	}
+	if l.Authenticated() || card.block(BlockState)[0] != 0x00 {
+		t.Fatalf("authenticated with a wrong key")
+	}
+
+	if err := l.Authenticate(testCardKey); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if !l.Authenticated() || card.block(BlockState)[0] != 0x01 || card.wcnt != 6 {
+		t.Fatalf("state % X, write counter %d", card.block(BlockState), card.wcnt)
+	}
+	data, err := l.ReadWithMAC([]int{BlockID, BlockCKV})
+	if err != nil || !bytes.Equal(data[:8], testIDm) || data[BlockSize] != 0x01 {
+		t.Fatalf("ReadWithMAC: % X, %v", data, err)
+	}
+
+	// With a session the new key and version are written with MAC_A
+	newKey := bytes.Repeat([]byte{0x3C}, 16)
+	if err := l.SetCardKey(newKey, 0x0102); err != nil {
+		t.Fatalf("SetCardKey: %v", err)
+	}
+	if !bytes.Equal(card.block(BlockCK), newKey) || card.block(BlockCKV)[0] != 0x02 || card.wcnt != 8 {
+		t.Fatalf("card key % X, version % X", card.block(BlockCK), card.block(BlockCKV)[:2])
+	}
+	if err := l.Authenticate(newKey); err != nil {
+		t.Fatalf("Authenticate with the new key: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package hardware
+
+import "fmt"
+
+// SetBuzzerOnDetect switches the beep of the ACR122U on detecting a card,
+// which is on by default, with the pseudo-APDU FF 00 52. The setting is
+// kept until the reader is unplugged. Like all pseudo-APDUs it goes through
+// the card connection, so a card must be connected, unless the connection
+// is direct, see Escape.
+func (m *Reader) SetBuzzerOnDetect(enabled bool) error {
+	var status byte
+	if enabled {
+		status = 0xFF
+	}
+	if _, err := m.readerCommand([]byte{0xFF, 0x00, 0x52, status, 0x00}); err != nil {
+		return fmt.Errorf("failed to set buzzer: %w", err)
+	}
+	return nil
+}
+
+// readerCommand sends a pseudo-APDU to the reader and returns the second
+// status byte, which the ACR122U uses for data
+func (m *Reader) readerCommand(cmd []byte) (byte, error) {
+	rsp, err := m.readerTransmit(cmd)
+	if err != nil {
+		return 0, err
+	}
+	if len(rsp) != 2 || rsp[0] != 0x90 {
+		return 0, NewStatusError(cmd, rsp)
+	}
+	return rsp[1], nil
+}
+
+// Capabilities describes what the reader firmware supports, as told by its
+// version
+type Capabilities struct {
+	Firmware     string // as reported, e.g. "ACR122U207"
+	Model        string // e.g. "ACR122U"
+	Major, Minor int
+
+	// Pseudo-APDUs of firmware 2.00 and later: Get Data (FF CA), the PICC
+	// operating parameter (FF 00 50/51) and the buzzer on detection (FF 00 52)
+	GetData        bool
+	PICCParameters bool
+	BuzzerOnDetect bool
+	// Direct PN532 commands with FF 00 00 00, supported by all firmware
+	PN532 bool
+	// Extended length APDUs, which no ACR122U passes
+	ExtendedLength bool
+}
+
+// FirmwareVersion returns the firmware version of the ACR122U, e.g.
+// "ACR122U207" for version 2.07, with the pseudo-APDU FF 00 48
+func (m *Reader) FirmwareVersion() (string, error) {
+	rsp, err := m.readerTransmit([]byte{0xFF, 0x00, 0x48, 0x00, 0x00})
+	if err != nil {
+		return "", fmt.Errorf("failed to get firmware version: %w", err)
+	}
+	// The version comes without status word; some firmware appends 90 00
+	if n := len(rsp); n >= 2 && rsp[n-2] == 0x90 && rsp[n-1] == 0x00 {
+		rsp = rsp[:n-2]
+	}
+	if len(rsp) == 0 {
+		return "", fmt.Errorf("failed to get firmware version: empty response")
+	}
+	for _, c := range rsp {
+		if c < 0x20 || c > 0x7E {
+			return "", fmt.Errorf("failed to get firmware version: response % X", rsp)
+		}
+	}
+	return string(rsp), nil
+}
+
+// Capabilities queries the firmware version and returns what it supports
+func (m *Reader) Capabilities() (Capabilities, error) {
+	version, err := m.FirmwareVersion()
+	if err != nil {
+		return Capabilities{}, err
+	}
+	return ParseFirmware(version)
+}
+
+// ParseFirmware returns the capabilities of a firmware version as reported
+// by FirmwareVersion: the model followed by three digits, major and minor
+// version
+func ParseFirmware(version string) (Capabilities, error) {
+	n := len(version)
+	if n < 4 {
+		return Capabilities{}, fmt.Errorf("invalid firmware version %q", version)
+	}
+	digits := version[n-3:]
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Capabilities{}, fmt.Errorf("invalid firmware version %q", version)
+		}
+	}
+	c := Capabilities{
+		Firmware: version,
+		Model:    version[:n-3],
+		Major:    int(digits[0] - '0'),
+		Minor:    int(digits[1]-'0')*10 + int(digits[2]-'0'),
+		PN532:    true,
+	}
+	if c.Major >= 2 {
+		c.GetData = true
+		c.PICCParameters = true
+		c.BuzzerOnDetect = true
+	}
+	return c, nil
+}
+
+// AtLeast reports whether the firmware is the version major.minor or later
+func (c Capabilities) AtLeast(major, minor int) bool {
+	return c.Major > major || c.Major == major && c.Minor >= minor
+}
+
+// PICCParameter is the PICC operating parameter of the ACR122U, selecting
+// the card types polled for and how. The default has all bits set.
+type PICCParameter byte
+
+const (
+	PollTypeA       PICCParameter = 1 << iota // ISO 14443 Type A
+	PollTypeB                                 // ISO 14443 Type B
+	PollTopaz                                 // Topaz/Jewel
+	PollFeliCa212                             // FeliCa 212 kbit/s
+	PollFeliCa424                             // FeliCa 424 kbit/s
+	PollInterval250                           // poll every 250 ms instead of 500 ms
+	AutoATS                                   // request the ATS of ISO 14443-4 cards
+	AutoPolling                               // poll for cards without a command
+
+	// PICCDefault is the setting of a new reader
+	PICCDefault PICCParameter = 0xFF
+)
+
+// Has reports whether all bits of flags are set
+func (p PICCParameter) Has(flags PICCParameter) bool {
+	return p&flags == flags
+}
+
+// GetPICCParameter returns the PICC operating parameter with the
+// pseudo-APDU FF 00 50
+func (m *Reader) GetPICCParameter() (PICCParameter, error) {
+	p, err := m.readerCommand([]byte{0xFF, 0x00, 0x50, 0x00, 0x00})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get PICC operating parameter: %w", err)
+	}
+	return PICCParameter(p), nil
+}
+
+// SetPICCParameter sets the PICC operating parameter with the pseudo-APDU
+// FF 00 51, e.g. to poll for Type A cards only, which detects them faster.
+// The setting is kept until the reader is unplugged.
+func (m *Reader) SetPICCParameter(p PICCParameter) error {
+	got, err := m.readerCommand([]byte{0xFF, 0x00, 0x51, byte(p), 0x00})
+	if err != nil {
+		return fmt.Errorf("failed to set PICC operating parameter: %w", err)
+	}
+	if PICCParameter(got) != p {
+		return fmt.Errorf("failed to set PICC operating parameter: reader reports %02X", got)
+	}
+	return nil
+}
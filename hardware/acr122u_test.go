@@ -0,0 +1,140 @@
+package hardware_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+)
+
+// scripted is an ACR122U connection answering pseudo-APDUs by their
+// instruction byte, 63 00 when not scripted. It records what it is sent,
+// through the card connection and as control commands.
+type scripted struct {
+	sent    [][]byte
+	codes   []uint32
+	escaped [][]byte
+	answers map[byte][]byte
+}
+
+func (s *scripted) Transmit(cmd []byte) ([]byte, error) {
+	s.sent = append(s.sent, append([]byte{}, cmd...))
+	return s.answer(cmd), nil
+}
+
+func (s *scripted) Control(code uint32, data []byte) ([]byte, error) {
+	s.codes = append(s.codes, code)
+	s.escaped = append(s.escaped, append([]byte{}, data...))
+	return s.answer(data), nil
+}
+
+func (s *scripted) answer(cmd []byte) []byte {
+	if rsp, ok := s.answers[cmd[2]]; ok {
+		return rsp
+	}
+	return []byte{0x63, 0x00}
+}
+
+func (s *scripted) Status() (hardware.ConnectionStatus, error) {
+	return hardware.ConnectionStatus{Reader: "scripted", ATR: mock.NTAGATR, Protocol: "T=1"}, nil
+}
+
+func (s *scripted) Disconnect() error {
+	return nil
+}
+
+func TestSetBuzzerOnDetect(t *testing.T) {
+	conn := &scripted{answers: map[byte][]byte{0x52: {0x90, 0x00}}}
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.SetBuzzerOnDetect(false); err != nil {
+		t.Fatalf("SetBuzzerOnDetect(false): %v", err)
+	}
+	if err := reader.SetBuzzerOnDetect(true); err != nil {
+		t.Fatalf("SetBuzzerOnDetect(true): %v", err)
+	}
+	for i, want := range [][]byte{
+		{0xFF, 0x00, 0x52, 0x00, 0x00},
+		{0xFF, 0x00, 0x52, 0xFF, 0x00},
+	} {
+		if !bytes.Equal(conn.sent[i], want) {
+			t.Errorf("command %d: % X, want % X", i, conn.sent[i], want)
+		}
+	}
+
+	conn.answers[0x52] = []byte{0x63, 0x00}
+	if err := reader.SetBuzzerOnDetect(true); err == nil {
+		t.Fatalf("SetBuzzerOnDetect refused by the reader: no error")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	conn := &scripted{answers: map[byte][]byte{0x48: []byte("ACR122U207")}}
+	reader := hardware.NewReaderWithConnection(conn)
+	c, err := reader.Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+	if c.Firmware != "ACR122U207" || c.Model != "ACR122U" || c.Major != 2 || c.Minor != 7 {
+		t.Errorf("version: %+v", c)
+	}
+	if !c.GetData || !c.PICCParameters || !c.BuzzerOnDetect || !c.PN532 || c.ExtendedLength {
+		t.Errorf("features: %+v", c)
+	}
+	if !c.AtLeast(2, 7) || !c.AtLeast(1, 99) || c.AtLeast(2, 8) || c.AtLeast(3, 0) {
+		t.Errorf("AtLeast of %d.%02d", c.Major, c.Minor)
+	}
+
+	// Some firmware appends a status word to the version
+	conn.answers[0x48] = append([]byte("ACR122U103"), 0x90, 0x00)
+	c, err = reader.Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities with status word: %v", err)
+	}
+	if c.Major != 1 || c.Minor != 3 || c.GetData || c.PICCParameters || c.BuzzerOnDetect || !c.PN532 {
+		t.Errorf("firmware 1.03: %+v", c)
+	}
+
+	conn.answers[0x48] = []byte{0x63, 0x00}
+	if _, err := reader.FirmwareVersion(); err == nil {
+		t.Fatalf("FirmwareVersion refused by the reader: no error")
+	}
+}
+
+func TestParseFirmware(t *testing.T) {
+	for _, version := range []string{"", "207", "ACR122Uxyz", "ACR122U2.7"} {
+		if _, err := hardware.ParseFirmware(version); err == nil {
+			t.Errorf("ParseFirmware(%q): no error", version)
+		}
+	}
+}
+
+func TestPICCParameter(t *testing.T) {
+	if hardware.PollTypeA != 0x01 || hardware.PollFeliCa424 != 0x10 || hardware.AutoATS != 0x40 || hardware.AutoPolling != 0x80 {
+		t.Fatalf("PICC parameter bits")
+	}
+
+	conn := &scripted{answers: map[byte][]byte{0x50: {0x90, 0xFF}, 0x51: {0x90, 0x81}}}
+	reader := hardware.NewReaderWithConnection(conn)
+	p, err := reader.GetPICCParameter()
+	if err != nil {
+		t.Fatalf("GetPICCParameter: %v", err)
+	}
+	if p != hardware.PICCDefault || !p.Has(hardware.PollTypeA|hardware.PollFeliCa424|hardware.AutoPolling) {
+		t.Errorf("PICC parameter %02X", byte(p))
+	}
+	if hardware.PollTypeA.Has(hardware.PollTypeA | hardware.PollTypeB) {
+		t.Errorf("Has reports a bit not set")
+	}
+
+	if err := reader.SetPICCParameter(hardware.AutoPolling | hardware.PollTypeA); err != nil {
+		t.Fatalf("SetPICCParameter: %v", err)
+	}
+	if want := []byte{0xFF, 0x00, 0x51, 0x81, 0x00}; !bytes.Equal(conn.sent[1], want) {
+		t.Errorf("set command % X, want % X", conn.sent[1], want)
+	}
+	// The reader echoes the parameter it took
+	if err := reader.SetPICCParameter(hardware.PICCDefault); err == nil {
+		t.Fatalf("SetPICCParameter with another echo: no error")
+	}
+}
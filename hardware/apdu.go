@@ -0,0 +1,154 @@
+package hardware
+
+import "fmt"
+
+// Command is an ISO 7816-4 command APDU. Le is the number of response bytes
+// expected, 0 for none, up to 256 in short and 65536 in extended form.
+type Command struct {
+	CLA, INS, P1, P2 byte
+	Data             []byte
+	Le               int
+}
+
+// Extended reports whether the command needs the extended length form
+func (c Command) Extended() bool {
+	return len(c.Data) > 0xFF || c.Le > 0x100
+}
+
+// Bytes encodes the command, in short form if data and Le fit, otherwise in
+// extended form
+func (c Command) Bytes() []byte {
+	out := []byte{c.CLA, c.INS, c.P1, c.P2}
+	if !c.Extended() {
+		if len(c.Data) > 0 {
+			out = append(append(out, byte(len(c.Data))), c.Data...)
+		}
+		if c.Le > 0 {
+			out = append(out, byte(c.Le))
+		}
+		return out
+	}
+	out = append(out, 0x00)
+	if len(c.Data) > 0 {
+		out = append(append(out, byte(len(c.Data)>>8), byte(len(c.Data))), c.Data...)
+	}
+	if c.Le > 0 {
+		out = append(out, byte(c.Le>>8), byte(c.Le))
+	}
+	return out
+}
+
+// ParseCommand decodes a command APDU in short or extended form
+func ParseCommand(apdu []byte) (Command, error) {
+	if len(apdu) < 4 {
+		return Command{}, fmt.Errorf("APDU too short")
+	}
+	c := Command{CLA: apdu[0], INS: apdu[1], P1: apdu[2], P2: apdu[3]}
+	body := apdu[4:]
+	switch {
+	case len(body) == 0:
+		return c, nil
+	case len(body) == 1:
+		c.Le = int(body[0])
+		if c.Le == 0 {
+			c.Le = 0x100
+		}
+		return c, nil
+	case body[0] != 0x00:
+		n := int(body[0])
+		switch len(body) {
+		case 1 + n:
+		case 2 + n:
+			c.Le = int(body[1+n])
+			if c.Le == 0 {
+				c.Le = 0x100
+			}
+		default:
+			return Command{}, fmt.Errorf("APDU length %d does not match Lc %d", len(apdu), n)
+		}
+		c.Data = body[1 : 1+n]
+		return c, nil
+	case len(body) == 3:
+		c.Le = int(body[1])<<8 | int(body[2])
+		if c.Le == 0 {
+			c.Le = 0x10000
+		}
+		return c, nil
+	case len(body) > 3:
+		n := int(body[1])<<8 | int(body[2])
+		switch len(body) {
+		case 3 + n:
+		case 5 + n:
+			c.Le = int(body[3+n])<<8 | int(body[4+n])
+			if c.Le == 0 {
+				c.Le = 0x10000
+			}
+		default:
+			return Command{}, fmt.Errorf("APDU length %d does not match Lc %d", len(apdu), n)
+		}
+		if n == 0 {
+			return Command{}, fmt.Errorf("extended APDU with Lc 0")
+		}
+		c.Data = body[3 : 3+n]
+		return c, nil
+	}
+	return Command{}, fmt.Errorf("invalid APDU length %d", len(apdu))
+}
+
+// maxChainedResponse limits the data collected with GET RESPONSE
+const maxChainedResponse = 0x10000
+
+// ChainingTransport completes responses for callers that want the whole
+// answer: data announced with 61 XX is fetched with GET RESPONSE and
+// appended, a command answered with 6C XX is sent again with that Le. Other
+// status words are passed through.
+type ChainingTransport struct {
+	transport Transport
+}
+
+// NewChainingTransport wraps a transport
+func NewChainingTransport(transport Transport) *ChainingTransport {
+	return &ChainingTransport{transport: transport}
+}
+
+func (t *ChainingTransport) Transmit(cmd []byte) ([]byte, error) {
+	rsp, err := t.transport.Transmit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	c, parseErr := ParseCommand(cmd)
+	if len(rsp) == 2 && rsp[0] == 0x6C && parseErr == nil && c.Le > 0 && !c.Extended() {
+		// Wrong Le; sent once more, as a card answering 6C XX again would
+		// loop forever
+		c.Le = int(rsp[1])
+		if c.Le == 0 {
+			c.Le = 0x100
+		}
+		if rsp, err = t.transport.Transmit(c.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	var data []byte
+	for parseErr == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x61 {
+		data = append(data, rsp[:len(rsp)-2]...)
+		if len(data) > maxChainedResponse {
+			return nil, fmt.Errorf("response exceeds %d bytes", maxChainedResponse)
+		}
+		if rsp, err = t.transport.Transmit([]byte{c.CLA & 0x03, 0xC0, 0x00, 0x00, rsp[len(rsp)-1]}); err != nil {
+			return nil, fmt.Errorf("get response failed: %w", err)
+		}
+	}
+	return append(data, rsp...), nil
+}
+
+func (t *ChainingTransport) ExtendedLength() bool {
+	return ExtendedLength(t.transport)
+}
+
+// ExtendedLength reports whether extended length APDUs pass the transport
+// and reader. Transports tell by an ExtendedLength() bool method; the reader
+// connection reports what was set with Reader.SetExtendedLength.
+func ExtendedLength(transport Transport) bool {
+	t, ok := transport.(interface{ ExtendedLength() bool })
+	return ok && t.ExtendedLength()
+}
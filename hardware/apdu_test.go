@@ -0,0 +1,166 @@
+package hardware_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+func TestCommandBytes(t *testing.T) {
+	data := bytes.Repeat([]byte{0x5A}, 300)
+	for _, tc := range []struct {
+		name string
+		cmd  hardware.Command
+		want []byte
+	}{
+		{"case 1", hardware.Command{INS: 0xA4}, []byte{0x00, 0xA4, 0x00, 0x00}},
+		{"case 2 short", hardware.Command{INS: 0xB0, Le: 0x10}, []byte{0x00, 0xB0, 0x00, 0x00, 0x10}},
+		{"case 2 short, Le 256", hardware.Command{INS: 0xB0, Le: 0x100}, []byte{0x00, 0xB0, 0x00, 0x00, 0x00}},
+		{"case 3 short", hardware.Command{INS: 0xD6, P2: 0x02, Data: []byte{0x01, 0x02}},
+			[]byte{0x00, 0xD6, 0x00, 0x02, 0x02, 0x01, 0x02}},
+		{"case 4 short", hardware.Command{CLA: 0x90, INS: 0x60, Data: []byte{0x01}, Le: 0x100},
+			[]byte{0x90, 0x60, 0x00, 0x00, 0x01, 0x01, 0x00}},
+		{"case 2 extended", hardware.Command{INS: 0xB0, Le: 0x101}, []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x01, 0x01}},
+		{"case 2 extended, Le 65536", hardware.Command{INS: 0xB0, Le: 0x10000}, []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"case 3 extended", hardware.Command{INS: 0xD6, Data: data},
+			append([]byte{0x00, 0xD6, 0x00, 0x00, 0x00, 0x01, 0x2C}, data...)},
+		{"case 4 extended", hardware.Command{INS: 0x88, Data: data, Le: 0x10},
+			append(append([]byte{0x00, 0x88, 0x00, 0x00, 0x00, 0x01, 0x2C}, data...), 0x00, 0x10)},
+		{"case 4, extended by Le", hardware.Command{INS: 0x88, Data: []byte{0x01}, Le: 0x200},
+			[]byte{0x00, 0x88, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x02, 0x00}},
+	} {
+		apdu := tc.cmd.Bytes()
+		if !bytes.Equal(apdu, tc.want) {
+			t.Errorf("%s: Bytes = % X, want % X", tc.name, apdu, tc.want)
+			continue
+		}
+		c, err := hardware.ParseCommand(apdu)
+		if err != nil {
+			t.Errorf("%s: ParseCommand: %v", tc.name, err)
+			continue
+		}
+		if c.CLA != tc.cmd.CLA || c.INS != tc.cmd.INS || c.P1 != tc.cmd.P1 || c.P2 != tc.cmd.P2 ||
+			!bytes.Equal(c.Data, tc.cmd.Data) || c.Le != tc.cmd.Le {
+			t.Errorf("%s: ParseCommand = %+v", tc.name, c)
+		}
+	}
+}
+
+func TestParseCommandErrors(t *testing.T) {
+	for _, apdu := range [][]byte{
+		{0x00, 0xB0, 0x00},
+		{0x00, 0xD6, 0x00, 0x00, 0x03, 0x01},                   // short Lc beyond the data
+		{0x00, 0xD6, 0x00, 0x00, 0x01, 0x01, 0x02, 0x03},       // two bytes behind the data
+		{0x00, 0xD6, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01},       // extended Lc beyond the data
+		{0x00, 0xD6, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // extended Lc 0
+		{0x00, 0xB0, 0x00, 0x00, 0x00, 0x01},
+	} {
+		if c, err := hardware.ParseCommand(apdu); err == nil {
+			t.Errorf("ParseCommand(% X) = %+v", apdu, c)
+		}
+	}
+}
+
+// exchange is a command expected by a script and the answer to it
+type exchange struct {
+	cmd []byte
+	rsp []byte
+	err error
+}
+
+// script is a transport that expects the commands of its exchanges in
+// order
+type script struct {
+	t         *testing.T
+	exchanges []exchange
+}
+
+func (s *script) Transmit(cmd []byte) ([]byte, error) {
+	s.t.Helper()
+	if len(s.exchanges) == 0 {
+		s.t.Fatalf("unexpected command % X", cmd)
+	}
+	e := s.exchanges[0]
+	s.exchanges = s.exchanges[1:]
+	if !bytes.Equal(cmd, e.cmd) {
+		s.t.Fatalf("command % X, want % X", cmd, e.cmd)
+	}
+	return e.rsp, e.err
+}
+
+func TestChainingTransport(t *testing.T) {
+	readBinary := []byte{0x00, 0xB0, 0x00, 0x00, 0x08}
+	for _, tc := range []struct {
+		name      string
+		cmd       []byte
+		exchanges []exchange
+		want      []byte
+	}{
+		{"complete", readBinary, []exchange{
+			{cmd: readBinary, rsp: []byte{0x01, 0x02, 0x90, 0x00}},
+		}, []byte{0x01, 0x02, 0x90, 0x00}},
+		{"61 XX", readBinary, []exchange{
+			{cmd: readBinary, rsp: []byte{0x01, 0x61, 0x02}},
+			{cmd: []byte{0x00, 0xC0, 0x00, 0x00, 0x02}, rsp: []byte{0x02, 0x03, 0x61, 0x00}},
+			{cmd: []byte{0x00, 0xC0, 0x00, 0x00, 0x00}, rsp: []byte{0x04, 0x90, 0x00}},
+		}, []byte{0x01, 0x02, 0x03, 0x04, 0x90, 0x00}},
+		{"61 XX, logical channel of the CLA", []byte{0x83, 0xCA, 0x00, 0x00, 0x00}, []exchange{
+			{cmd: []byte{0x83, 0xCA, 0x00, 0x00, 0x00}, rsp: []byte{0x61, 0x01}},
+			{cmd: []byte{0x03, 0xC0, 0x00, 0x00, 0x01}, rsp: []byte{0x01, 0x90, 0x00}},
+		}, []byte{0x01, 0x90, 0x00}},
+		{"6C XX", readBinary, []exchange{
+			{cmd: readBinary, rsp: []byte{0x6C, 0x03}},
+			{cmd: []byte{0x00, 0xB0, 0x00, 0x00, 0x03}, rsp: []byte{0x01, 0x02, 0x03, 0x90, 0x00}},
+		}, []byte{0x01, 0x02, 0x03, 0x90, 0x00}},
+		{"6C XX, then 61 XX", readBinary, []exchange{
+			{cmd: readBinary, rsp: []byte{0x6C, 0x00}},
+			{cmd: []byte{0x00, 0xB0, 0x00, 0x00, 0x00}, rsp: []byte{0x01, 0x61, 0x01}},
+			{cmd: []byte{0x00, 0xC0, 0x00, 0x00, 0x01}, rsp: []byte{0x02, 0x90, 0x00}},
+		}, []byte{0x01, 0x02, 0x90, 0x00}},
+		{"6C XX answered once", readBinary, []exchange{
+			{cmd: readBinary, rsp: []byte{0x6C, 0x03}},
+			{cmd: []byte{0x00, 0xB0, 0x00, 0x00, 0x03}, rsp: []byte{0x6C, 0x02}},
+		}, []byte{0x6C, 0x02}},
+		{"6C XX without Le", []byte{0x00, 0xD6, 0x00, 0x00, 0x01, 0x01}, []exchange{
+			{cmd: []byte{0x00, 0xD6, 0x00, 0x00, 0x01, 0x01}, rsp: []byte{0x6C, 0x03}},
+		}, []byte{0x6C, 0x03}},
+		{"error status", readBinary, []exchange{
+			{cmd: readBinary, rsp: []byte{0x6A, 0x82}},
+		}, []byte{0x6A, 0x82}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			card := &script{t: t, exchanges: tc.exchanges}
+			rsp, err := hardware.NewChainingTransport(card).Transmit(tc.cmd)
+			if err != nil {
+				t.Fatalf("Transmit: %v", err)
+			}
+			if !bytes.Equal(rsp, tc.want) {
+				t.Errorf("Transmit = % X, want % X", rsp, tc.want)
+			}
+			if len(card.exchanges) != 0 {
+				t.Errorf("%d commands not sent", len(card.exchanges))
+			}
+		})
+	}
+}
+
+func TestChainingTransportErrors(t *testing.T) {
+	readBinary := []byte{0x00, 0xB0, 0x00, 0x00, 0x08}
+	card := &script{t: t, exchanges: []exchange{
+		{cmd: readBinary, rsp: []byte{0x01, 0x61, 0x02}},
+		{cmd: []byte{0x00, 0xC0, 0x00, 0x00, 0x02}, err: hardware.ErrCardRemoved},
+	}}
+	if _, err := hardware.NewChainingTransport(card).Transmit(readBinary); !errors.Is(err, hardware.ErrCardRemoved) {
+		t.Errorf("Transmit with GET RESPONSE failing = %v", err)
+	}
+
+	// A card announcing more data forever
+	endless := hardware.TransportFunc(func(cmd []byte) ([]byte, error) {
+		return append(make([]byte, 0xFF), 0x61, 0x00), nil
+	})
+	if rsp, err := hardware.NewChainingTransport(endless).Transmit(readBinary); err == nil {
+		t.Errorf("Transmit of an endless response returned %d bytes", len(rsp))
+	}
+}
@@ -0,0 +1,227 @@
+package atr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Initial characters (TS) selecting the coding convention
+const (
+	DirectConvention  = 0x3B
+	InverseConvention = 0x3F
+)
+
+// ProtocolGlobal is the protocol value of TD bytes announcing global
+// interface bytes (T=15)
+const ProtocolGlobal = 15
+
+// fiTable maps the high nibble of TA1 to the clock rate conversion factor Fi,
+// 0 marks reserved values
+var fiTable = [16]int{372, 372, 558, 744, 1116, 1488, 1860, 0, 0, 512, 768, 1024, 1536, 2048, 0, 0}
+
+// diTable maps the low nibble of TA1 to the baud rate adjustment factor Di
+var diTable = [16]int{0, 1, 2, 4, 8, 16, 32, 64, 12, 20, 0, 0, 0, 0, 0, 0}
+
+// InterfaceBytes is one group of interface bytes TAi, TBi, TCi and TDi. The
+// first group holds global parameters, later groups belong to the protocol
+// announced by the TD byte of the previous group.
+type InterfaceBytes struct {
+	Protocol int // protocol the group applies to, -1 for the first group
+	TA, TB   byte
+	TC, TD   byte
+	HasTA    bool
+	HasTB    bool
+	HasTC    bool
+	HasTD    bool
+}
+
+// ATR is a decoded Answer to Reset as defined by ISO/IEC 7816-3
+type ATR struct {
+	Raw        []byte
+	TS         byte
+	T0         byte
+	Interface  []InterfaceBytes
+	Historical []byte
+	TCK        byte
+	HasTCK     bool
+}
+
+// Parse decodes an ATR. The TCK checksum is verified when present.
+func Parse(b []byte) (*ATR, error) {
+	if len(b) < 2 {
+		return nil, fmt.Errorf("ATR too short: %d bytes", len(b))
+	}
+	a := &ATR{
+		Raw: append([]byte{}, b...),
+		TS:  b[0],
+		T0:  b[1],
+	}
+	if a.TS != DirectConvention && a.TS != InverseConvention {
+		return nil, fmt.Errorf("invalid initial character TS=%02X", a.TS)
+	}
+
+	// Interface bytes: the high nibble of T0 and of each TD tells which of
+	// the following TA, TB, TC and TD bytes are present
+	pos := 2
+	indicator := a.T0 >> 4
+	protocol := -1
+	for {
+		group := InterfaceBytes{Protocol: protocol}
+		for i, dst := range []*byte{&group.TA, &group.TB, &group.TC, &group.TD} {
+			if indicator&(1<<i) == 0 {
+				continue
+			}
+			if pos >= len(b) {
+				return nil, fmt.Errorf("ATR truncated in interface bytes")
+			}
+			*dst = b[pos]
+			pos++
+		}
+		group.HasTA = indicator&0x1 != 0
+		group.HasTB = indicator&0x2 != 0
+		group.HasTC = indicator&0x4 != 0
+		group.HasTD = indicator&0x8 != 0
+		a.Interface = append(a.Interface, group)
+		if !group.HasTD {
+			break
+		}
+		indicator = group.TD >> 4
+		protocol = int(group.TD & 0x0F)
+	}
+
+	// Historical bytes, their number is the low nibble of T0
+	k := int(a.T0 & 0x0F)
+	if pos+k > len(b) {
+		return nil, fmt.Errorf("ATR truncated in historical bytes")
+	}
+	a.Historical = append([]byte{}, b[pos:pos+k]...)
+	pos += k
+
+	// TCK is present unless only T=0 is indicated
+	if a.needsTCK() {
+		if pos >= len(b) {
+			return nil, fmt.Errorf("ATR missing TCK")
+		}
+		a.TCK = b[pos]
+		a.HasTCK = true
+		pos++
+		var sum byte
+		for _, c := range b[1:pos] {
+			sum ^= c
+		}
+		if sum != 0 {
+			return nil, fmt.Errorf("ATR checksum mismatch: TCK=%02X", a.TCK)
+		}
+	}
+	if pos != len(b) {
+		return nil, fmt.Errorf("ATR has %d extra bytes", len(b)-pos)
+	}
+	return a, nil
+}
+
+func (a *ATR) needsTCK() bool {
+	for _, p := range a.Protocols() {
+		if p != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Protocols returns the protocols offered by the card in the order of the TD
+// bytes, without the global T=15. A card without TD bytes supports T=0.
+func (a *ATR) Protocols() []int {
+	var protocols []int
+	for _, group := range a.Interface {
+		if !group.HasTD {
+			continue
+		}
+		p := int(group.TD & 0x0F)
+		if p == ProtocolGlobal {
+			continue
+		}
+		seen := false
+		for _, q := range protocols {
+			seen = seen || q == p
+		}
+		if !seen {
+			protocols = append(protocols, p)
+		}
+	}
+	if len(protocols) == 0 {
+		protocols = []int{0}
+	}
+	return protocols
+}
+
+// InterfaceFor returns the interface bytes of the given protocol, i.e. the
+// groups following a TD byte announcing it
+func (a *ATR) InterfaceFor(protocol int) []InterfaceBytes {
+	var groups []InterfaceBytes
+	for _, group := range a.Interface {
+		if group.Protocol == protocol {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// FiDi returns the clock rate conversion factor and the baud rate adjustment
+// factor from TA1, or the defaults 372 and 1 without TA1
+func (a *ATR) FiDi() (fi int, di int) {
+	if !a.Interface[0].HasTA {
+		return 372, 1
+	}
+	ta1 := a.Interface[0].TA
+	return fiTable[ta1>>4], diTable[ta1&0x0F]
+}
+
+// ExtraGuardTime returns the extra guard time N from TC1
+func (a *ATR) ExtraGuardTime() int {
+	if !a.Interface[0].HasTC {
+		return 0
+	}
+	return int(a.Interface[0].TC)
+}
+
+// IFSC returns the information field size of the card for T=1 from the first
+// TA byte of the T=1 groups, or the default 32
+func (a *ATR) IFSC() int {
+	for _, group := range a.InterfaceFor(1) {
+		if group.HasTA {
+			return int(group.TA)
+		}
+	}
+	return 32
+}
+
+func (a *ATR) String() string {
+	var sb strings.Builder
+	convention := "direct"
+	if a.TS == InverseConvention {
+		convention = "inverse"
+	}
+	fmt.Fprintf(&sb, "TS=%02X (%s convention) T0=%02X", a.TS, convention, a.T0)
+	for i, group := range a.Interface {
+		for _, ib := range []struct {
+			name    string
+			value   byte
+			present bool
+		}{
+			{"TA", group.TA, group.HasTA},
+			{"TB", group.TB, group.HasTB},
+			{"TC", group.TC, group.HasTC},
+			{"TD", group.TD, group.HasTD},
+		} {
+			if ib.present {
+				fmt.Fprintf(&sb, " %s%d=%02X", ib.name, i+1, ib.value)
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "\nProtocols: %v\n", a.Protocols())
+	fmt.Fprintf(&sb, "Historical bytes: % X", a.Historical)
+	if a.HasTCK {
+		fmt.Fprintf(&sb, "\nTCK=%02X", a.TCK)
+	}
+	return sb.String()
+}
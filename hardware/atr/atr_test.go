@@ -0,0 +1,111 @@
+package atr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		atr        []byte
+		header     string // first line of String
+		protocols  []int
+		historical []byte
+		tck        bool
+		fi, di     int
+		ifsc, n    int
+	}{
+		{
+			name: "PC/SC contactless MIFARE Classic 1K",
+			atr: []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+				0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x6A},
+			header:    "TS=3B (direct convention) T0=8F TD1=80 TD2=01",
+			protocols: []int{0, 1},
+			historical: []byte{0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+				0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+			tck: true, fi: 372, di: 1, ifsc: 32,
+		},
+		{
+			name:       "PC/SC contactless DESFire EV1",
+			atr:        []byte{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80},
+			header:     "TS=3B (direct convention) T0=81 TD1=80 TD2=01",
+			protocols:  []int{0, 1},
+			historical: []byte{0x80},
+			tck:        true, fi: 372, di: 1, ifsc: 32,
+		},
+		{
+			name:       "JCOP41 with TA1, TC1 and T=1 parameters",
+			atr:        []byte{0x3B, 0xFA, 0x13, 0x00, 0x00, 0x81, 0x31, 0xFE, 0x45, 'J', 'C', 'O', 'P', '4', '1', 'V', '2', '2', '1', 0x96},
+			header:     "TS=3B (direct convention) T0=FA TA1=13 TB1=00 TC1=00 TD1=81 TD2=31 TA3=FE TB3=45",
+			protocols:  []int{1},
+			historical: []byte("JCOP41V221"),
+			tck:        true, fi: 372, di: 4, ifsc: 254,
+		},
+		{
+			name:       "T=0 only without TCK",
+			atr:        []byte{0x3B, 0x02, 0x14, 0x50},
+			header:     "TS=3B (direct convention) T0=02",
+			protocols:  []int{0},
+			historical: []byte{0x14, 0x50},
+			fi:         372, di: 1, ifsc: 32,
+		},
+		{
+			name:       "inverse convention with extra guard time",
+			atr:        []byte{0x3F, 0x65, 0x25, 0x08, 0x2C, 0x09, 0x69, 0x90, 0x00},
+			header:     "TS=3F (inverse convention) T0=65 TB1=25 TC1=08",
+			protocols:  []int{0},
+			historical: []byte{0x2C, 0x09, 0x69, 0x90, 0x00},
+			fi:         372, di: 1, ifsc: 32, n: 8,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := Parse(tc.atr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if header, _, _ := strings.Cut(a.String(), "\n"); header != tc.header {
+				t.Errorf("String() starts %q, want %q", header, tc.header)
+			}
+			if fmt.Sprint(a.Protocols()) != fmt.Sprint(tc.protocols) {
+				t.Errorf("protocols %v", a.Protocols())
+			}
+			if !bytes.Equal(a.Historical, tc.historical) {
+				t.Errorf("historical bytes % X", a.Historical)
+			}
+			if a.HasTCK != tc.tck || (tc.tck && a.TCK != tc.atr[len(tc.atr)-1]) {
+				t.Errorf("TCK %02X present %t", a.TCK, a.HasTCK)
+			}
+			if fi, di := a.FiDi(); fi != tc.fi || di != tc.di {
+				t.Errorf("Fi %d, Di %d", fi, di)
+			}
+			if a.IFSC() != tc.ifsc || a.ExtraGuardTime() != tc.n {
+				t.Errorf("IFSC %d, extra guard time %d", a.IFSC(), a.ExtraGuardTime())
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	classic := []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+		0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x6A}
+	badTCK := append(append([]byte{}, classic[:len(classic)-1]...), 0x6B)
+	for _, tc := range []struct {
+		name string
+		atr  []byte
+	}{
+		{"too short", []byte{0x3B}},
+		{"invalid TS", []byte{0x3A, 0x00}},
+		{"truncated interface bytes", []byte{0x3B, 0x80}},
+		{"truncated historical bytes", []byte{0x3B, 0x05, 0x01}},
+		{"missing TCK", classic[:len(classic)-1]},
+		{"checksum mismatch", badTCK},
+		{"extra bytes", []byte{0x3B, 0x02, 0x14, 0x50, 0x00}},
+	} {
+		if a, err := Parse(tc.atr); err == nil {
+			t.Errorf("%s: Parse(% X) = %s", tc.name, tc.atr, a)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package atr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fscTable maps FSCI to the frame size the card accepts, FSC. Reserved
+// values are taken as 256.
+var fscTable = [16]int{16, 24, 32, 40, 48, 64, 96, 128, 256, 512, 1024, 2048, 4096, 256, 256, 256}
+
+// ATS is a decoded Answer To Select of an ISO/IEC 14443-4 Type A card. PC/SC
+// readers put its historical bytes into the ATR.
+type ATS struct {
+	Raw        []byte
+	TL         byte // length byte, counting itself
+	T0         byte // format byte, zero if absent
+	TA, TB, TC byte
+	HasT0      bool
+	HasTA      bool
+	HasTB      bool
+	HasTC      bool
+	Historical []byte
+}
+
+// ParseATS decodes an ATS without CRC
+func ParseATS(b []byte) (*ATS, error) {
+	if len(b) < 1 {
+		return nil, fmt.Errorf("ATS empty")
+	}
+	a := &ATS{Raw: append([]byte{}, b...), TL: b[0]}
+	if int(a.TL) != len(b) {
+		return nil, fmt.Errorf("ATS length byte TL=%02X for %d bytes", a.TL, len(b))
+	}
+	if len(b) == 1 {
+		return a, nil
+	}
+	a.T0, a.HasT0 = b[1], true
+	if a.T0&0x80 != 0 {
+		return nil, fmt.Errorf("invalid ATS format byte T0=%02X", a.T0)
+	}
+	pos := 2
+	for i, dst := range []*byte{&a.TA, &a.TB, &a.TC} {
+		if a.T0&(0x10<<i) == 0 {
+			continue
+		}
+		if pos >= len(b) {
+			return nil, fmt.Errorf("ATS truncated in interface bytes")
+		}
+		*dst = b[pos]
+		pos++
+	}
+	a.HasTA = a.T0&0x10 != 0
+	a.HasTB = a.T0&0x20 != 0
+	a.HasTC = a.T0&0x40 != 0
+	a.Historical = append([]byte{}, b[pos:]...)
+	return a, nil
+}
+
+// FSC returns the largest frame the card accepts, 32 bytes by default
+func (a *ATS) FSC() int {
+	if !a.HasT0 {
+		return 32
+	}
+	return fscTable[a.T0&0x0F]
+}
+
+// FWI returns the frame waiting time integer from TB, or the default 4
+func (a *ATS) FWI() int {
+	if !a.HasTB {
+		return 4
+	}
+	return int(a.TB >> 4)
+}
+
+// SFGI returns the start-up frame guard time integer from TB, or the
+// default 0
+func (a *ATS) SFGI() int {
+	if !a.HasTB {
+		return 0
+	}
+	return int(a.TB & 0x0F)
+}
+
+// BitRates returns the divisors D the card supports from the reader to the
+// card and from the card to the reader, besides 1 (106 kbit/s)
+func (a *ATS) BitRates() (toCard []int, fromCard []int) {
+	if !a.HasTA {
+		return nil, nil
+	}
+	for i, d := range []int{2, 4, 8} {
+		if a.TA&(0x01<<i) != 0 {
+			toCard = append(toCard, d)
+		}
+		if a.TA&(0x10<<i) != 0 {
+			fromCard = append(fromCard, d)
+		}
+	}
+	return toCard, fromCard
+}
+
+// CID reports whether the card supports the card identifier, which it does
+// by default
+func (a *ATS) CID() bool {
+	return !a.HasTC || a.TC&0x02 != 0
+}
+
+// NAD reports whether the card supports the node address
+func (a *ATS) NAD() bool {
+	return a.HasTC && a.TC&0x01 != 0
+}
+
+func (a *ATS) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "TL=%02X", a.TL)
+	if a.HasT0 {
+		fmt.Fprintf(&sb, " T0=%02X", a.T0)
+	}
+	for _, ib := range []struct {
+		name    string
+		value   byte
+		present bool
+	}{
+		{"TA", a.TA, a.HasTA},
+		{"TB", a.TB, a.HasTB},
+		{"TC", a.TC, a.HasTC},
+	} {
+		if ib.present {
+			fmt.Fprintf(&sb, " %s=%02X", ib.name, ib.value)
+		}
+	}
+	toCard, fromCard := a.BitRates()
+	fmt.Fprintf(&sb, "\nFSC=%d FWI=%d SFGI=%d CID=%t NAD=%t", a.FSC(), a.FWI(), a.SFGI(), a.CID(), a.NAD())
+	fmt.Fprintf(&sb, "\nBit rate divisors: to card %v, from card %v\n", toCard, fromCard)
+	fmt.Fprintf(&sb, "Historical bytes: % X", a.Historical)
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package atr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestParseATS(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		ats              []byte
+		ta, tb, tc       bool
+		fsc, fwi, sfgi   int
+		toCard, fromCard []int
+		cid, nad         bool
+		historical       []byte
+	}{
+		{
+			name: "DESFire EV1",
+			ats:  []byte{0x06, 0x75, 0x77, 0x81, 0x02, 0x80},
+			ta:   true, tb: true, tc: true,
+			fsc: 64, fwi: 8, sfgi: 1,
+			toCard: []int{2, 4, 8}, fromCard: []int{2, 4, 8},
+			cid: true, historical: []byte{0x80},
+		},
+		{
+			name: "NTAG 424 DNA",
+			ats:  []byte{0x06, 0x77, 0x77, 0x71, 0x02, 0x80},
+			ta:   true, tb: true, tc: true,
+			fsc: 128, fwi: 7, sfgi: 1,
+			toCard: []int{2, 4, 8}, fromCard: []int{2, 4, 8},
+			cid: true, historical: []byte{0x80},
+		},
+		{
+			name: "MIFARE Plus",
+			ats:  []byte{0x0C, 0x75, 0x77, 0x80, 0x02, 0xC1, 0x05, 0x2F, 0x2F, 0x01, 0xBC, 0xD6},
+			ta:   true, tb: true, tc: true,
+			fsc: 64, fwi: 8, sfgi: 0,
+			toCard: []int{2, 4, 8}, fromCard: []int{2, 4, 8},
+			cid: true, historical: []byte{0xC1, 0x05, 0x2F, 0x2F, 0x01, 0xBC, 0xD6},
+		},
+		{
+			name: "JCOP without historical bytes",
+			ats:  []byte{0x05, 0x78, 0x80, 0x70, 0x02},
+			ta:   true, tb: true, tc: true,
+			fsc: 256, fwi: 7, sfgi: 0,
+			cid: true, historical: []byte{},
+		},
+		{
+			name: "TB only",
+			ats:  []byte{0x04, 0x28, 0x81, 0xC1},
+			tb:   true,
+			fsc:  256, fwi: 8, sfgi: 1,
+			cid: true, historical: []byte{0xC1},
+		},
+		{
+			name: "TC with NAD, no CID",
+			ats:  []byte{0x03, 0x42, 0x01},
+			tc:   true,
+			fsc:  32, fwi: 4,
+			nad: true, historical: []byte{},
+		},
+		{
+			name: "length byte only",
+			ats:  []byte{0x01},
+			fsc:  32, fwi: 4,
+			cid: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := ParseATS(tc.ats)
+			if err != nil {
+				t.Fatalf("ParseATS: %v", err)
+			}
+			if a.HasTA != tc.ta || a.HasTB != tc.tb || a.HasTC != tc.tc {
+				t.Errorf("TA %t, TB %t, TC %t present", a.HasTA, a.HasTB, a.HasTC)
+			}
+			if a.FSC() != tc.fsc || a.FWI() != tc.fwi || a.SFGI() != tc.sfgi {
+				t.Errorf("FSC %d, FWI %d, SFGI %d", a.FSC(), a.FWI(), a.SFGI())
+			}
+			toCard, fromCard := a.BitRates()
+			if fmt.Sprint(toCard) != fmt.Sprint(tc.toCard) || fmt.Sprint(fromCard) != fmt.Sprint(tc.fromCard) {
+				t.Errorf("bit rate divisors %v to card, %v from card", toCard, fromCard)
+			}
+			if a.CID() != tc.cid || a.NAD() != tc.nad {
+				t.Errorf("CID %t, NAD %t", a.CID(), a.NAD())
+			}
+			if !bytes.Equal(a.Historical, tc.historical) {
+				t.Errorf("historical bytes % X", a.Historical)
+			}
+		})
+	}
+}
+
+func TestParseATSErrors(t *testing.T) {
+	for _, b := range [][]byte{
+		{},
+		{0x05, 0x75},             // TL longer than the ATS
+		{0x02, 0x80},             // T0 with bit 8 set
+		{0x03, 0x70, 0x77},       // TA, TB and TC announced, one given
+		{0x02, 0x75, 0x77, 0x81}, // TL shorter than the ATS
+	} {
+		if a, err := ParseATS(b); err == nil {
+			t.Errorf("ParseATS(% X) = %s", b, a)
+		}
+	}
+}
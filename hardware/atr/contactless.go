@@ -0,0 +1,136 @@
+package atr
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// PCSCRID is the registered application provider identifier of the PC/SC
+// workgroup, found in the ATR of contactless storage cards
+var PCSCRID = []byte{0xA0, 0x00, 0x00, 0x03, 0x06}
+
+// Standard bytes of PC/SC Part 3 contactless ATRs
+const (
+	StandardNone           = 0x00
+	StandardISO14443APart1 = 0x01
+	StandardISO14443APart2 = 0x02
+	StandardISO14443APart3 = 0x03
+	StandardISO14443BPart1 = 0x05
+	StandardISO14443BPart2 = 0x06
+	StandardISO14443BPart3 = 0x07
+	StandardISO15693Part1  = 0x09
+	StandardISO15693Part2  = 0x0A
+	StandardISO15693Part3  = 0x0B
+	StandardISO15693Part4  = 0x0C
+	StandardFeliCa         = 0x11
+	StandardLowFrequency   = 0x40
+)
+
+// Card names of PC/SC Part 3 contactless ATRs
+const (
+	CardNameNone            = 0x0000
+	CardNameMifare1K        = 0x0001
+	CardNameMifare4K        = 0x0002
+	CardNameUltralight      = 0x0003
+	CardNameSR176           = 0x0006
+	CardNameSRIX4K          = 0x0007
+	CardNameICodeSLI        = 0x0014
+	CardNameICode1          = 0x0016
+	CardNameMifareMini      = 0x0026
+	CardNameJewel           = 0x002F
+	CardNameTopaz           = 0x0030
+	CardNameICodeSL2        = 0x0035
+	CardNameMifarePlus2K    = 0x0036
+	CardNameMifarePlus4K    = 0x0037
+	CardNameMifarePlusSL22K = 0x0038
+	CardNameMifarePlusSL24K = 0x0039
+	CardNameUltralightC     = 0x003A
+	CardNameFeliCa          = 0x003B
+	CardNameUltralightEV1   = 0x003D
+)
+
+var standardNames = map[byte]string{
+	StandardNone:           "No information",
+	StandardISO14443APart1: "ISO 14443 A part 1",
+	StandardISO14443APart2: "ISO 14443 A part 2",
+	StandardISO14443APart3: "ISO 14443 A part 3",
+	StandardISO14443BPart1: "ISO 14443 B part 1",
+	StandardISO14443BPart2: "ISO 14443 B part 2",
+	StandardISO14443BPart3: "ISO 14443 B part 3",
+	StandardISO15693Part1:  "ISO 15693 part 1",
+	StandardISO15693Part2:  "ISO 15693 part 2",
+	StandardISO15693Part3:  "ISO 15693 part 3",
+	StandardISO15693Part4:  "ISO 15693 part 4",
+	StandardFeliCa:         "FeliCa",
+	StandardLowFrequency:   "Low frequency contactless",
+}
+
+var cardNames = map[uint16]string{
+	CardNameNone:            "No information",
+	CardNameMifare1K:        "MIFARE Classic 1K",
+	CardNameMifare4K:        "MIFARE Classic 4K",
+	CardNameUltralight:      "MIFARE Ultralight",
+	CardNameSR176:           "ST SR176",
+	CardNameSRIX4K:          "ST SRI X4K",
+	CardNameICodeSLI:        "ICODE SLI",
+	CardNameICode1:          "ICODE1",
+	CardNameMifareMini:      "MIFARE Mini",
+	CardNameJewel:           "Jewel",
+	CardNameTopaz:           "Topaz",
+	CardNameICodeSL2:        "ICODE SL2",
+	CardNameMifarePlus2K:    "MIFARE Plus SL1 2K",
+	CardNameMifarePlus4K:    "MIFARE Plus SL1 4K",
+	CardNameMifarePlusSL22K: "MIFARE Plus SL2 2K",
+	CardNameMifarePlusSL24K: "MIFARE Plus SL2 4K",
+	CardNameUltralightC:     "MIFARE Ultralight C",
+	CardNameFeliCa:          "FeliCa",
+	CardNameUltralightEV1:   "MIFARE Ultralight EV1",
+}
+
+// Contactless is the card identification that PC/SC Part 3 compliant readers
+// put into the historical bytes of the ATR of contactless storage cards
+// (3B 8F 80 01 80 4F 0C A0 00 00 03 06 SS NN NN 00 00 00 00 TCK)
+type Contactless struct {
+	RID      []byte
+	Standard byte
+	CardName uint16
+}
+
+// Contactless decodes the PC/SC Part 3 structure of the historical bytes. It
+// reports false for ATRs of other cards, e.g. ISO 14443-4 cards like DESFire.
+func (a *ATR) Contactless() (*Contactless, bool) {
+	h := a.Historical
+	// Category indicator 0x80 followed by the compact-TLV application
+	// identifier (tag 4, length 12)
+	if len(h) < 15 || h[0] != 0x80 || h[1] != 0x4F || h[2] != 0x0C {
+		return nil, false
+	}
+	if !bytes.Equal(h[3:8], PCSCRID) {
+		return nil, false
+	}
+	return &Contactless{
+		RID:      append([]byte{}, h[3:8]...),
+		Standard: h[8],
+		CardName: uint16(h[9])<<8 | uint16(h[10]),
+	}, true
+}
+
+// StandardName describes the standard byte
+func (c *Contactless) StandardName() string {
+	if name, ok := standardNames[c.Standard]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown standard %02X", c.Standard)
+}
+
+// Name describes the card name bytes
+func (c *Contactless) Name() string {
+	if name, ok := cardNames[c.CardName]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown card %04X", c.CardName)
+}
+
+func (c *Contactless) String() string {
+	return fmt.Sprintf("%s, %s (RID %X)", c.Name(), c.StandardName(), c.RID)
+}
@@ -0,0 +1,59 @@
+package atr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContactless(t *testing.T) {
+	for _, tc := range []struct {
+		atr      []byte
+		standard string
+		name     string
+	}{
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x6A}, "ISO 14443 A part 3", "MIFARE Classic 1K"},
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x03, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x69}, "ISO 14443 A part 3", "MIFARE Classic 4K"},
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x03, 0x00, 0x26, 0x00, 0x00, 0x00, 0x00, 0x4D}, "ISO 14443 A part 3", "MIFARE Mini"},
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x68}, "ISO 14443 A part 3", "MIFARE Ultralight"},
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x03, 0x00, 0x3A, 0x00, 0x00, 0x00, 0x00, 0x51}, "ISO 14443 A part 3", "MIFARE Ultralight C"},
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x03, 0x00, 0x3D, 0x00, 0x00, 0x00, 0x00, 0x56}, "ISO 14443 A part 3", "MIFARE Ultralight EV1"},
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x11, 0x00, 0x3B, 0x00, 0x00, 0x00, 0x00, 0x42}, "FeliCa", "FeliCa"},
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x0B, 0x00, 0x14, 0x00, 0x00, 0x00, 0x00, 0x77}, "ISO 15693 part 3", "ICODE SLI"},
+		{[]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06,
+			0x03, 0x12, 0x34, 0x00, 0x00, 0x00, 0x00, 0x4D}, "ISO 14443 A part 3", "Unknown card 1234"},
+	} {
+		a, err := Parse(tc.atr)
+		if err != nil {
+			t.Fatalf("Parse(% X): %v", tc.atr, err)
+		}
+		c, ok := a.Contactless()
+		if !ok {
+			t.Fatalf("% X: not a contactless storage card", tc.atr)
+		}
+		if !bytes.Equal(c.RID, PCSCRID) || c.StandardName() != tc.standard || c.Name() != tc.name {
+			t.Errorf("% X: %s", tc.atr, c)
+		}
+	}
+
+	// ISO 14443-4 cards like DESFire carry other historical bytes
+	for _, b := range [][]byte{
+		{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80},
+		{0x3B, 0xFA, 0x13, 0x00, 0x00, 0x81, 0x31, 0xFE, 0x45, 'J', 'C', 'O', 'P', '4', '1', 'V', '2', '2', '1', 0x96},
+	} {
+		a, err := Parse(b)
+		if err != nil {
+			t.Fatalf("Parse(% X): %v", b, err)
+		}
+		if c, ok := a.Contactless(); ok {
+			t.Errorf("% X decoded as %s", b, c)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package atr
+
+import "testing"
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x6A})
+	f.Add([]byte{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80})
+	f.Add([]byte{0x3B, 0x02, 0x14, 0x50})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		a, err := Parse(b)
+		if err != nil {
+			return
+		}
+		_ = a.String()
+		a.FiDi()
+		a.IFSC()
+		a.ExtraGuardTime()
+		if c, ok := a.Contactless(); ok {
+			_ = c.String()
+		}
+	})
+}
+
+func FuzzParseATS(f *testing.F) {
+	f.Add([]byte{0x06, 0x75, 0x77, 0x81, 0x02, 0x80})
+	f.Add([]byte{0x01})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		a, err := ParseATS(b)
+		if err != nil {
+			return
+		}
+		_ = a.String()
+	})
+}
@@ -0,0 +1,54 @@
+package hardware
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/oo-developer/acr122u/config"
+)
+
+// NewReaderFromConfig creates a reader and selects the reader configured by
+// name pattern, with the configured timeouts. Card module settings are
+// applied by the modules, e.g. classic.UseConfig.
+func NewReaderFromConfig(cfg *config.Config) (*Reader, error) {
+	reader, err := NewReader()
+	if err != nil {
+		return nil, err
+	}
+	readers, err := reader.ListReaders()
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	name, err := SelectReader(readers, cfg.Reader.Name)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	reader.UseReader(name)
+	reader.pattern = cfg.Reader.Name
+	reader.SetTimeouts(Timeouts{
+		Wait:     cfg.Reader.WaitTimeout,
+		Connect:  cfg.Reader.ConnectTimeout,
+		Exchange: cfg.Reader.ExchangeTimeout,
+	})
+	return reader, nil
+}
+
+// SelectReader returns the first reader whose name matches the regular
+// expression, the first reader if the pattern is empty
+func SelectReader(readers []string, pattern string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid reader pattern: %v", err)
+	}
+	for _, name := range readers {
+		if re.MatchString(name) {
+			return name, nil
+		}
+	}
+	if pattern == "" {
+		return "", fmt.Errorf("no readers detected")
+	}
+	return "", fmt.Errorf("no reader matching %q", pattern)
+}
@@ -0,0 +1,38 @@
+package hardware
+
+import "github.com/ebfe/scard"
+
+// ConnectConfig sets how Connect connects to the reader
+type ConnectConfig struct {
+	// Share is scard.ShareShared if zero. scard.ShareExclusive locks the
+	// reader against other processes; scard.ShareDirect connects to the
+	// reader without a card, for Control, and skips the card detection.
+	Share scard.ShareMode
+	// Protocol is scard.ProtocolT0|scard.ProtocolT1 if zero, except for
+	// direct connections, which negotiate no protocol
+	Protocol scard.Protocol
+}
+
+// SetConnectConfig sets how Connect and Reconnect connect to the reader
+func (m *Reader) SetConnectConfig(config ConnectConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connectConfig = config
+}
+
+// shareMode returns the share mode and protocol to connect with
+func (m *Reader) shareMode() (scard.ShareMode, scard.Protocol) {
+	share, protocol := m.connectConfig.Share, m.connectConfig.Protocol
+	if share == 0 {
+		share = scard.ShareShared
+	}
+	if protocol == 0 && share != scard.ShareDirect {
+		protocol = scard.ProtocolT0 | scard.ProtocolT1
+	}
+	return share, protocol
+}
+
+// direct reports whether the reader connects without a card
+func (m *Reader) direct() bool {
+	return m.connectConfig.Share == scard.ShareDirect
+}
@@ -0,0 +1,76 @@
+package hardware
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestShareMode(t *testing.T) {
+	for _, tc := range []struct {
+		config   ConnectConfig
+		share    scard.ShareMode
+		protocol scard.Protocol
+	}{
+		{ConnectConfig{}, scard.ShareShared, scard.ProtocolT0 | scard.ProtocolT1},
+		{ConnectConfig{Share: scard.ShareExclusive}, scard.ShareExclusive, scard.ProtocolT0 | scard.ProtocolT1},
+		{ConnectConfig{Share: scard.ShareExclusive, Protocol: scard.ProtocolT1}, scard.ShareExclusive, scard.ProtocolT1},
+		{ConnectConfig{Protocol: scard.ProtocolT0}, scard.ShareShared, scard.ProtocolT0},
+		{ConnectConfig{Share: scard.ShareDirect}, scard.ShareDirect, scard.ProtocolUndefined},
+	} {
+		m := &Reader{connectConfig: tc.config}
+		share, protocol := m.shareMode()
+		if share != tc.share || protocol != tc.protocol {
+			t.Errorf("%+v: share mode %d, protocol %d, want %d, %d", tc.config, share, protocol, tc.share, tc.protocol)
+		}
+		if m.direct() != (tc.share == scard.ShareDirect) {
+			t.Errorf("%+v: direct %t", tc.config, m.direct())
+		}
+	}
+}
+
+// cardless is a direct connection to a reader without a card
+type cardless struct {
+	transmits int
+}
+
+func (c *cardless) Transmit(cmd []byte) ([]byte, error) {
+	c.transmits++
+	return nil, scard.ErrNoSmartcard
+}
+
+func (c *cardless) Control(code uint32, data []byte) ([]byte, error) {
+	return []byte{0x90, 0x00}, nil
+}
+
+func (c *cardless) Status() (ConnectionStatus, error) {
+	return ConnectionStatus{Reader: "cardless"}, nil
+}
+
+func (c *cardless) Disconnect() error {
+	return nil
+}
+
+func TestDirectConnect(t *testing.T) {
+	conn := &cardless{}
+	m := NewReaderWithConnection(conn)
+	if err := m.Connect(); !errors.Is(err, ErrCardRemoved) {
+		t.Fatalf("Connect without card: %v", err)
+	}
+
+	conn.transmits = 0
+	m.SetConnectConfig(ConnectConfig{Share: scard.ShareDirect})
+	if err := m.Connect(); err != nil {
+		t.Fatalf("direct Connect: %v", err)
+	}
+	if err := m.Reconnect(); err != nil {
+		t.Fatalf("direct Reconnect: %v", err)
+	}
+	if conn.transmits != 0 {
+		t.Errorf("%d APDUs sent to the card", conn.transmits)
+	}
+	if info := m.CardInfo(); info.UID != nil || info.Type != "" {
+		t.Errorf("card detected: %s % X", info.Type, info.UID)
+	}
+}
@@ -0,0 +1,78 @@
+package hardware_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ntag"
+	"github.com/oo-developer/acr122u/ntag/simulator"
+)
+
+// simulated is a connection to a simulated NTAG215
+type simulated struct {
+	*simulator.Simulator
+	controls     int
+	disconnected bool
+}
+
+func (c *simulated) Control(code uint32, data []byte) ([]byte, error) {
+	c.controls++
+	return []byte{0x90, 0x00}, nil
+}
+
+func (c *simulated) Status() (hardware.ConnectionStatus, error) {
+	return hardware.ConnectionStatus{Reader: "simulated", ATR: mock.NTAGATR, Protocol: "T=1"}, nil
+}
+
+func (c *simulated) Disconnect() error {
+	c.disconnected = true
+	return nil
+}
+
+func TestConnection(t *testing.T) {
+	uid := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	memory := make([]byte, ntag.NTAG215TotalPages*4)
+	copy(memory[0:3], uid[0:3])
+	memory[3] = 0x88 ^ uid[0] ^ uid[1] ^ uid[2]
+	copy(memory[4:8], uid[3:7])
+	memory[8] = uid[3] ^ uid[4] ^ uid[5] ^ uid[6]
+	memory[9] = 0x48
+	copy(memory[12:16], []byte{0xE1, 0x10, 0x3E, 0x00})
+	card, err := simulator.NewSimulator(memory)
+	if err != nil {
+		t.Fatalf("NewSimulator: %v", err)
+	}
+	conn := &simulated{Simulator: card}
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	info := reader.CardInfo()
+	if !bytes.Equal(info.UID, uid) || info.Type == "" {
+		t.Errorf("card %q % X", info.Type, info.UID)
+	}
+	if !bytes.Equal(info.ATR, mock.NTAGATR) || info.Protocol != "T=1" {
+		t.Errorf("ATR % X, protocol %q from the connection status", info.ATR, info.Protocol)
+	}
+	if reader.Connection() != conn || reader.Card() != nil {
+		t.Errorf("connection %v, PC/SC card %v", reader.Connection(), reader.Card())
+	}
+	if _, err := reader.Control(hardware.EscapeCode, []byte{0xFF, 0x00, 0x48, 0x00, 0x00}); err != nil || conn.controls != 1 {
+		t.Errorf("Control: %v, %d control commands", err, conn.controls)
+	}
+
+	// Reader selection and waiting for cards need PC/SC
+	if _, err := reader.ListReaders(); err == nil {
+		t.Errorf("ListReaders without PC/SC")
+	}
+	if err := reader.WaitForCard(); err == nil {
+		t.Errorf("WaitForCard without PC/SC")
+	}
+
+	if err := reader.Close(); err != nil || !conn.disconnected {
+		t.Errorf("Close: %v, disconnected %t", err, conn.disconnected)
+	}
+}
@@ -0,0 +1,78 @@
+package hardware_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+)
+
+func TestConnectContext(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	reader := mock.NewReader(card, mock.NTAGATR)
+	ctx, cancel := context.WithCancel(context.Background())
+	apdus := 0
+	reader.Use(func(next hardware.Transport) hardware.Transport {
+		return hardware.TransportFunc(func(cmd []byte) ([]byte, error) {
+			if apdus++; apdus == 2 {
+				cancel()
+			}
+			return next.Transmit(cmd)
+		})
+	})
+
+	if err := reader.ConnectContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ConnectContext cancelled during the detection: %v", err)
+	}
+	if apdus != 2 {
+		t.Fatalf("detection went on for %d APDUs after the cancellation", apdus-2)
+	}
+	apdus = 0
+	if err := reader.ConnectContext(ctx); !errors.Is(err, context.Canceled) || apdus != 0 {
+		t.Fatalf("ConnectContext with a cancelled context: %v after %d APDUs", err, apdus)
+	}
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+}
+
+func TestTransmitContext(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	conn := &stalling{Connection: mock.NewConnection(card, mock.NTAGATR), release: make(chan struct{})}
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	read := []byte{0xFF, 0xB0, 0x00, 0x04, 0x04}
+	if rsp, err := reader.TransmitContext(context.Background(), read); err != nil || len(rsp) != 6 {
+		t.Fatalf("TransmitContext: % X, %v", rsp, err)
+	}
+
+	conn.stalled.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := reader.TransmitContext(ctx, read); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("stalled TransmitContext: %v", err)
+	}
+	if _, err := reader.TransmitContext(context.Background(), read); !errors.Is(err, hardware.ErrNotConnected) {
+		t.Fatalf("TransmitContext after giving up: %v", err)
+	}
+
+	conn.stalled.Store(false)
+	close(conn.release)
+	if err := reader.Reconnect(); err != nil {
+		t.Fatalf("Reconnect: %v", err)
+	}
+	if rsp, err := reader.TransmitContext(context.Background(), read); err != nil || len(rsp) != 6 {
+		t.Fatalf("TransmitContext after Reconnect: % X, %v", rsp, err)
+	}
+}
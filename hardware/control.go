@@ -0,0 +1,52 @@
+package hardware
+
+import (
+	"fmt"
+
+	"github.com/ebfe/scard"
+)
+
+// EscapeCode is the control code of IOCTL_CCID_ESCAPE, which passes
+// pseudo-APDUs to the ACR122U without a card, see Escape
+var EscapeCode = scard.CtlCode(escapeFunction)
+
+// Control sends a control command to the reader, e.g. with a direct
+// connection when no card is on the reader, see SetConnectConfig. The code
+// is built with scard.CtlCode.
+func (m *Reader) Control(code uint32, data []byte) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.control(code, data)
+}
+
+// control sends a control command with the reader's lock held
+func (m *Reader) control(code uint32, data []byte) ([]byte, error) {
+	if m.card == nil {
+		return nil, ErrNotConnected
+	}
+	rsp, err := m.card.Control(code, data)
+	if err != nil {
+		return nil, fmt.Errorf("control %08X failed: %w", code, err)
+	}
+	Logger().Debug("control", "reader", m.reader, "code", fmt.Sprintf("%08X", code),
+		"data", fmt.Sprintf("%X", data), "response", fmt.Sprintf("%X", rsp))
+	return rsp, nil
+}
+
+// Escape sends a pseudo-APDU to the reader with IOCTL_CCID_ESCAPE, which
+// works without a card. On Linux and macOS the CCID driver must allow it,
+// with ifdDriverOptions 0x0001 in its Info.plist.
+func (m *Reader) Escape(cmd []byte) ([]byte, error) {
+	return m.Control(EscapeCode, cmd)
+}
+
+// readerTransmit sends a pseudo-APDU to the reader: as escape command on
+// direct connections, otherwise through the card connection
+func (m *Reader) readerTransmit(cmd []byte) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.direct() {
+		return m.control(EscapeCode, cmd)
+	}
+	return m.transmit(cmd)
+}
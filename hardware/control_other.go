@@ -0,0 +1,7 @@
+//go:build !windows
+
+package hardware
+
+// escapeFunction is the function of IOCTL_CCID_ESCAPE for the pcsc-lite
+// CCID driver
+const escapeFunction = 1
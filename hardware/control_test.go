@@ -0,0 +1,57 @@
+package hardware_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+func TestEscape(t *testing.T) {
+	conn := &scripted{answers: map[byte][]byte{
+		0x48: []byte("ACR122U215"),
+		0x50: {0x90, 0x81},
+		0x52: {0x90, 0x00},
+	}}
+	reader := hardware.NewReaderWithConnection(conn)
+	reader.SetConnectConfig(hardware.ConnectConfig{Share: scard.ShareDirect})
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	if err := reader.SetBuzzerOnDetect(false); err != nil {
+		t.Fatalf("SetBuzzerOnDetect: %v", err)
+	}
+	c, err := reader.Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+	if c.Major != 2 || c.Minor != 15 {
+		t.Errorf("firmware %d.%02d", c.Major, c.Minor)
+	}
+	p, err := reader.GetPICCParameter()
+	if err != nil {
+		t.Fatalf("GetPICCParameter: %v", err)
+	}
+	if p != hardware.AutoPolling|hardware.PollTypeA {
+		t.Errorf("PICC parameter %02X", byte(p))
+	}
+
+	if len(conn.sent) != 0 {
+		t.Errorf("sent through the card connection: % X", conn.sent)
+	}
+	for i, code := range conn.codes {
+		if code != hardware.EscapeCode {
+			t.Errorf("control %d: code %08X, want %08X", i, code, hardware.EscapeCode)
+		}
+	}
+	if want := []byte{0xFF, 0x00, 0x52, 0x00, 0x00}; len(conn.escaped) != 3 || !bytes.Equal(conn.escaped[0], want) {
+		t.Errorf("escape commands % X", conn.escaped)
+	}
+
+	rsp, err := reader.Escape([]byte{0xFF, 0x00, 0x48, 0x00, 0x00})
+	if err != nil || string(rsp) != "ACR122U215" {
+		t.Fatalf("Escape: %q, %v", rsp, err)
+	}
+}
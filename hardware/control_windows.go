@@ -0,0 +1,5 @@
+package hardware
+
+// escapeFunction is the function of IOCTL_CCID_ESCAPE for the Windows CCID
+// driver
+const escapeFunction = 3500
@@ -0,0 +1,85 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotConnected is returned when there is no connection to a card
+	ErrNotConnected = errors.New("not connected to card")
+	// ErrCardRemoved is returned when the card left the reader
+	ErrCardRemoved = errors.New("card removed")
+	// ErrAuthFailed matches status errors of refused authentications and
+	// commands needing one
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrWrongLength matches status errors of wrong command or response
+	// lengths, and is returned for responses too short to hold a status
+	ErrWrongLength = errors.New("wrong length")
+	// ErrTimeout is returned when an operation ran out of the time set with
+	// Reader.SetTimeouts
+	ErrTimeout = errors.New("timeout")
+)
+
+// errAbandoned is returned for exchanges after one was given up
+var errAbandoned = fmt.Errorf("%w: an exchange was given up, reconnect first", ErrNotConnected)
+
+// errNoPCSC is returned by the methods of a reader created with
+// NewReaderWithConnection that need PC/SC
+var errNoPCSC = errors.New("reader has no PC/SC context")
+
+// StatusError is a response whose status word is not success. It matches
+// ErrAuthFailed and ErrWrongLength with errors.Is where the status says so.
+type StatusError struct {
+	SW1, SW2 byte
+	Command  []byte // the command answered, if known
+}
+
+// NewStatusError returns the status error of a response to the command
+func NewStatusError(cmd []byte, rsp []byte) *StatusError {
+	e := &StatusError{Command: cmd}
+	if len(rsp) >= 2 {
+		e.SW1, e.SW2 = rsp[len(rsp)-2], rsp[len(rsp)-1]
+	}
+	return e
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%02X %02X", e.SW1, e.SW2)
+}
+
+// SW returns the status word
+func (e *StatusError) SW() uint16 {
+	return uint16(e.SW1)<<8 | uint16(e.SW2)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrAuthFailed:
+		switch {
+		case e.SW1 == 0x69 && (e.SW2 == 0x82 || e.SW2 == 0x88), e.SW1 == 0x63 && e.SW2&0xF0 == 0xC0:
+			return true
+		case e.SW1 == 0x91 && e.SW2 == 0xAE: // DESFire authentication error
+			return true
+		case e.SW1 == 0x63 && e.SW2 == 0x00:
+			// The ACR122U answers refused General Authenticate and
+			// Authentication commands with a plain failure
+			return len(e.Command) >= 2 && e.Command[0] == 0xFF && (e.Command[1] == 0x86 || e.Command[1] == 0x88)
+		}
+	case ErrWrongLength:
+		return e.SW1 == 0x67 || e.SW1 == 0x6C || e.SW1 == 0x91 && e.SW2 == 0x7E
+	}
+	return false
+}
+
+// CheckStatus returns the data of a response to the command without the
+// status word, or the status error if it is not 90 00
+func CheckStatus(cmd []byte, rsp []byte) ([]byte, error) {
+	if len(rsp) < 2 {
+		return nil, fmt.Errorf("invalid response length %d: %w", len(rsp), ErrWrongLength)
+	}
+	if rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+		return nil, NewStatusError(cmd, rsp)
+	}
+	return rsp[:len(rsp)-2], nil
+}
@@ -0,0 +1,100 @@
+package hardware_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+)
+
+func TestStatusErrorIs(t *testing.T) {
+	auth := []byte{0xFF, 0x86, 0x00, 0x00, 0x05}
+	read := []byte{0xFF, 0xB0, 0x00, 0x04, 0x10}
+	for _, tc := range []struct {
+		cmd         []byte
+		sw          []byte
+		auth, wrong bool
+	}{
+		{read, []byte{0x69, 0x82}, true, false},
+		{read, []byte{0x69, 0x88}, true, false},
+		{read, []byte{0x63, 0xC2}, true, false},
+		{read, []byte{0x91, 0xAE}, true, false},
+		{auth, []byte{0x63, 0x00}, true, false},
+		{read, []byte{0x63, 0x00}, false, false},
+		{read, []byte{0x67, 0x00}, false, true},
+		{read, []byte{0x6C, 0x10}, false, true},
+		{read, []byte{0x91, 0x7E}, false, true},
+		{read, []byte{0x6A, 0x82}, false, false},
+	} {
+		_, err := hardware.CheckStatus(tc.cmd, tc.sw)
+		var status *hardware.StatusError
+		if !errors.As(err, &status) || status.SW() != uint16(tc.sw[0])<<8|uint16(tc.sw[1]) {
+			t.Fatalf("CheckStatus(% X): %v", tc.sw, err)
+		}
+		if errors.Is(err, hardware.ErrAuthFailed) != tc.auth || errors.Is(err, hardware.ErrWrongLength) != tc.wrong {
+			t.Errorf("% X to % X: ErrAuthFailed %t, ErrWrongLength %t", tc.sw, tc.cmd,
+				errors.Is(err, hardware.ErrAuthFailed), errors.Is(err, hardware.ErrWrongLength))
+		}
+	}
+	if _, err := hardware.CheckStatus(read, []byte{0x90}); !errors.Is(err, hardware.ErrWrongLength) {
+		t.Errorf("CheckStatus of a short response: %v", err)
+	}
+}
+
+func TestPN532ErrorIs(t *testing.T) {
+	if !errors.Is(hardware.PN532Error(0x14), hardware.ErrAuthFailed) {
+		t.Errorf("PN532 error 14 does not match ErrAuthFailed")
+	}
+	if !errors.Is(hardware.PN532Error(0x2B), hardware.ErrCardRemoved) {
+		t.Errorf("PN532 error 2B does not match ErrCardRemoved")
+	}
+	if errors.Is(hardware.PN532Error(0x01), hardware.ErrAuthFailed) {
+		t.Errorf("PN532 error 01 matches ErrAuthFailed")
+	}
+}
+
+// failingControl is a connection whose control commands fail
+type failingControl struct {
+	*mock.Connection
+}
+
+func (failingControl) Control(code uint32, data []byte) ([]byte, error) {
+	return nil, hardware.ErrCardRemoved
+}
+
+func TestControlErrors(t *testing.T) {
+	reader := hardware.NewReaderWithConnection(nil)
+	if _, err := reader.Control(hardware.EscapeCode, []byte{0xFF, 0x00, 0x48, 0x00, 0x00}); !errors.Is(err, hardware.ErrNotConnected) {
+		t.Fatalf("Control without connection: %v", err)
+	}
+	reader = hardware.NewReaderWithConnection(failingControl{mock.NewConnection(nil, nil)})
+	if _, err := reader.Control(hardware.EscapeCode, []byte{0xFF, 0x00, 0x48, 0x00, 0x00}); !errors.Is(err, hardware.ErrCardRemoved) {
+		t.Fatalf("failing Control: %v", err)
+	}
+}
+
+func TestSentinelsThroughCardModules(t *testing.T) {
+	card, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	conn := mock.NewConnection(card, mock.Classic1KATR)
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	c := classic.NewClassic(reader)
+	if err := c.LoadKey(0, []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}); err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	if err := c.Authenticate(4, 0x60, 0); !errors.Is(err, hardware.ErrAuthFailed) {
+		t.Fatalf("Authenticate with a wrong key: %v", err)
+	}
+
+	conn.Remove()
+	if _, err := c.ReadBlock(4); !errors.Is(err, hardware.ErrCardRemoved) {
+		t.Fatalf("ReadBlock of a removed card: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package hardware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// EventType tells what happened on a reader
+type EventType string
+
+const (
+	EventInserted EventType = "inserted"
+	EventRemoved  EventType = "removed"
+	EventMute     EventType = "mute"  // a card is on the reader but does not answer
+	EventError    EventType = "error" // watching ended, see CardEvent.Err
+)
+
+// CardEvent is a change on one reader
+type CardEvent struct {
+	Type   EventType
+	Reader string
+	ATR    []byte // inserted cards only
+	Time   time.Time
+	Err    error // EventError only
+}
+
+// Events watches the selected reader, or all readers if none is selected,
+// and sends an event for each card arriving, leaving or not answering. Cards
+// already on a reader are reported as inserted first. The channel is closed
+// when ctx ends, or after an EventError.
+//
+// Watching uses its own PC/SC context, so the reader can connect to cards
+// meanwhile.
+func (m *Reader) Events(ctx context.Context) <-chan CardEvent {
+	events := make(chan CardEvent, 16)
+	m.mu.Lock()
+	name := m.reader
+	m.mu.Unlock()
+	go func() {
+		defer close(events)
+		if err := watch(ctx, name, events); err != nil && ctx.Err() == nil {
+			select {
+			case events <- CardEvent{Type: EventError, Reader: name, Time: time.Now(), Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return events
+}
+
+func watch(ctx context.Context, name string, events chan<- CardEvent) error {
+	sctx, err := scard.EstablishContext()
+	if err != nil {
+		return fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer sctx.Release()
+
+	readers := []string{name}
+	if name == "" {
+		if readers, err = sctx.ListReaders(); err != nil {
+			return fmt.Errorf("failed to list readers: %w", err)
+		}
+	}
+	states := make([]scard.ReaderState, len(readers))
+	for i, name := range readers {
+		states[i] = scard.ReaderState{Reader: name, CurrentState: scard.StateUnaware}
+	}
+
+	stop := context.AfterFunc(ctx, func() { sctx.Cancel() })
+	defer stop()
+	for {
+		err := sctx.GetStatusChange(states, -1)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, scard.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to watch readers: %w", err)
+		}
+		for _, event := range stateEvents(states, time.Now()) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// stateEvents returns the events of the readers whose card changed in a
+// GetStatusChange, and makes their event states the current ones
+func stateEvents(states []scard.ReaderState, now time.Time) []CardEvent {
+	var events []CardEvent
+	for i := range states {
+		old, state := states[i].CurrentState, states[i].EventState&^scard.StateChanged
+		states[i].CurrentState = state
+		event := CardEvent{Reader: states[i].Reader, Time: now}
+		switch {
+		case cardState(state) == cardState(old):
+			continue
+		case cardState(state) == EventInserted:
+			event.Type = EventInserted
+			event.ATR = append([]byte{}, states[i].Atr...)
+		case cardState(state) == EventMute:
+			event.Type = EventMute
+		case cardState(old) != EventRemoved && old != scard.StateUnaware:
+			event.Type = EventRemoved
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// cardState reduces a reader state to inserted, mute or removed
+func cardState(state scard.StateFlag) EventType {
+	switch {
+	case state&scard.StatePresent == 0:
+		return EventRemoved
+	case state&scard.StateMute != 0:
+		return EventMute
+	}
+	return EventInserted
+}
@@ -0,0 +1,78 @@
+package hardware
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// TestStateEvents feeds the events of two readers, a and b, as
+// GetStatusChange reports them
+func TestStateEvents(t *testing.T) {
+	atr := []byte{0x3B, 0x8F, 0x80, 0x01}
+	states := []scard.ReaderState{
+		{Reader: "a", CurrentState: scard.StateUnaware},
+		{Reader: "b", CurrentState: scard.StateUnaware},
+	}
+	for i, step := range []struct {
+		a, b scard.StateFlag
+		want []string
+	}{
+		// A card already on b counts as inserted
+		{scard.StateEmpty, scard.StatePresent, []string{"b inserted"}},
+		{scard.StatePresent | scard.StateChanged, scard.StatePresent, []string{"a inserted"}},
+		// Changes that leave the card state alone are no events
+		{scard.StatePresent | scard.StateInuse, scard.StatePresent | scard.StateExclusive, nil},
+		{scard.StatePresent | scard.StateMute, scard.StateEmpty | scard.StateChanged, []string{"a mute", "b removed"}},
+		{scard.StateEmpty, scard.StateEmpty, []string{"a removed"}},
+		{scard.StateEmpty, scard.StateEmpty, nil},
+	} {
+		states[0].EventState, states[1].EventState = step.a, step.b
+		for j := range states {
+			states[j].Atr = atr
+		}
+		now := time.Now()
+		events := stateEvents(states, now)
+		var got []string
+		for _, e := range events {
+			got = append(got, e.Reader+" "+string(e.Type))
+			if e.Time != now {
+				t.Errorf("step %d: event time %v", i, e.Time)
+			}
+			if hasATR := e.ATR != nil; hasATR != (e.Type == EventInserted) || hasATR && !bytes.Equal(e.ATR, atr) {
+				t.Errorf("step %d: %s with ATR % X", i, e.Type, e.ATR)
+			}
+		}
+		if len(got) != len(step.want) {
+			t.Fatalf("step %d: events %q, want %q", i, got, step.want)
+		}
+		for j := range got {
+			if got[j] != step.want[j] {
+				t.Fatalf("step %d: events %q, want %q", i, got, step.want)
+			}
+		}
+		if states[0].CurrentState&scard.StateChanged != 0 || states[1].CurrentState&scard.StateChanged != 0 {
+			t.Fatalf("step %d: changed flag kept in the current states", i)
+		}
+	}
+}
+
+func TestCardState(t *testing.T) {
+	for _, tc := range []struct {
+		state scard.StateFlag
+		want  EventType
+	}{
+		{scard.StateUnaware, EventRemoved},
+		{scard.StateEmpty, EventRemoved},
+		{scard.StatePresent, EventInserted},
+		{scard.StatePresent | scard.StateInuse, EventInserted},
+		{scard.StatePresent | scard.StateMute, EventMute},
+		{scard.StateEmpty | scard.StateMute, EventRemoved},
+	} {
+		if got := cardState(tc.state); got != tc.want {
+			t.Errorf("cardState(%#x) = %s, want %s", tc.state, got, tc.want)
+		}
+	}
+}
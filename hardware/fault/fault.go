@@ -0,0 +1,104 @@
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+
+	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// ErrResponseLost is returned for a dropped response. The card did execute
+// the command, which is what makes lost responses hard to recover from.
+var ErrResponseLost = errors.New("response lost")
+
+// Config selects the faults. Exchanges are counted from 1.
+type Config struct {
+	// DropResponses lists exchanges whose response is lost after the card
+	// executed the command
+	DropResponses []int
+	// FailRate is the fraction of exchanges answered with 63 00 without
+	// reaching the card, like a reader losing the card's answer
+	FailRate float64
+	// Seed makes the FailRate faults reproducible
+	Seed int64
+	// RemoveAfter takes the card off the reader after this many exchanges,
+	// never if zero. Further exchanges fail with an error matching both
+	// hardware.ErrCardRemoved and scard.ErrRemovedCard.
+	RemoveAfter int
+}
+
+// remover is implemented by the card simulators, which end their sessions
+// when the card is taken away
+type remover interface {
+	Remove()
+}
+
+// Transport injects faults into the exchanges with another transport, e.g. a
+// card simulator, to check how the code above copes with an unreliable RF
+// field:
+//
+//	sim, _ := simulator.LoadSimulator("ntag215.bin")
+//	faulty := fault.NewTransport(sim, fault.Config{RemoveAfter: 20})
+//	_, err := ntag.NewNTAGWithTransport(faulty).DumpMemory()
+//
+// It is safe for concurrent use.
+type Transport struct {
+	mu        sync.Mutex
+	transport hardware.Transport
+	config    Config
+	rand      *rand.Rand
+	count     int
+	removed   bool
+}
+
+// NewTransport wraps a transport
+func NewTransport(transport hardware.Transport, config Config) *Transport {
+	return &Transport{
+		transport: transport,
+		config:    config,
+		rand:      rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+func (t *Transport) Transmit(cmd []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.count++
+	if t.config.RemoveAfter > 0 && t.count > t.config.RemoveAfter && !t.removed {
+		t.removed = true
+		if r, ok := t.transport.(remover); ok {
+			r.Remove()
+		}
+	}
+	if t.removed {
+		return nil, fmt.Errorf("%w: %w", hardware.ErrCardRemoved, scard.ErrRemovedCard)
+	}
+	if t.config.FailRate > 0 && t.rand.Float64() < t.config.FailRate {
+		return []byte{0x63, 0x00}, nil
+	}
+	rsp, err := t.transport.Transmit(cmd)
+	if err == nil && slices.Contains(t.config.DropResponses, t.count) {
+		return nil, ErrResponseLost
+	}
+	return rsp, err
+}
+
+// Count returns the number of exchanges so far
+func (t *Transport) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.count
+}
+
+// Reinsert puts a removed card back on the reader; RemoveAfter does not
+// trigger again
+func (t *Transport) Reinsert() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.removed = false
+	t.config.RemoveAfter = 0
+}
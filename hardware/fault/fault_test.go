@@ -0,0 +1,226 @@
+package fault_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/desfire/simulator"
+	"github.com/oo-developer/acr122u/desfire/wallet"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/fault"
+	"github.com/oo-developer/acr122u/hardware/transcript"
+	"github.com/oo-developer/acr122u/ntag"
+	ntagsim "github.com/oo-developer/acr122u/ntag/simulator"
+)
+
+var ntagUID = []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+// cleanDump returns the dump of a blank NTAG215 read without faults, in
+// which the password reads as zeros
+func cleanDump(t *testing.T) []byte {
+	t.Helper()
+	sim, err := newNTAG215(ntagUID)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	dump, err := ntag.NewNTAGWithTransport(sim).DumpMemory()
+	if err != nil {
+		t.Fatalf("DumpMemory: %v", err)
+	}
+	return dump
+}
+
+// retrying sends commands answered with 63 00 again, up to 8 times
+type retrying struct {
+	transport hardware.Transport
+}
+
+func (r retrying) Transmit(cmd []byte) ([]byte, error) {
+	var rsp []byte
+	var err error
+	for attempt := 0; attempt < 8; attempt++ {
+		rsp, err = r.transport.Transmit(cmd)
+		if err != nil || len(rsp) != 2 || rsp[0] != 0x63 || rsp[1] != 0x00 {
+			break
+		}
+	}
+	return rsp, err
+}
+
+func TestRetryFailures(t *testing.T) {
+	want := cleanDump(t)
+	for seed := int64(0); seed < 10; seed++ {
+		sim, err := newNTAG215(ntagUID)
+		if err != nil {
+			t.Fatalf("NewNTAG215: %v", err)
+		}
+		faulty := fault.NewTransport(sim, fault.Config{FailRate: 0.3, Seed: seed})
+		dump, err := ntag.NewNTAGWithTransport(retrying{faulty}).DumpMemory()
+		if err != nil {
+			t.Fatalf("seed %d: DumpMemory: %v", seed, err)
+		}
+		if !bytes.Equal(dump, want) {
+			t.Fatalf("seed %d: dumped %d bytes, not the card's %d", seed, len(dump), len(want))
+		}
+	}
+}
+
+func TestFailuresNeverShortenDumps(t *testing.T) {
+	want := cleanDump(t)
+	failed := 0
+	for seed := int64(0); seed < 20; seed++ {
+		sim, err := newNTAG215(ntagUID)
+		if err != nil {
+			t.Fatalf("NewNTAG215: %v", err)
+		}
+		dump, err := ntag.NewNTAGWithTransport(fault.NewTransport(sim, fault.Config{FailRate: 0.2, Seed: seed})).DumpMemory()
+		if err != nil {
+			failed++
+			continue
+		}
+		if !bytes.Equal(dump, want) {
+			t.Fatalf("seed %d: dumped %d bytes without error, not the card's %d", seed, len(dump), len(want))
+		}
+	}
+	if failed == 0 {
+		t.Fatalf("no dump failed, the faults did not reach the tag")
+	}
+}
+
+var (
+	walletAID = []byte{0x57, 0x4C, 0x54}
+	walletKey = wallet.Key{No: 0, Type: desfire.KeyTypeAES, Key: make([]byte, 16)}
+)
+
+// newWallet returns a card with a wallet holding 100
+func newWallet(t *testing.T) *simulator.Simulator {
+	t.Helper()
+	sim := simulator.NewSimulator(ntagUID)
+	df := desfire.NewDESFireWithTransport(sim)
+	if err := df.CreateApplication(walletAID, 0x0F, 0x81); err != nil {
+		t.Fatalf("CreateApplication: %v", err)
+	}
+	if err := df.SelectApplication(walletAID); err != nil {
+		t.Fatalf("SelectApplication: %v", err)
+	}
+	if err := df.AuthenticateAES(0, walletKey.Key); err != nil {
+		t.Fatalf("AuthenticateAES: %v", err)
+	}
+	access := desfire.AccessRights{Read: 0, Write: 0, ReadWrite: 0, ChangeAccess: 0}
+	if err := df.CreateValueFile(1, desfire.CommModeFull, access, 0, 1000, 100, false); err != nil {
+		t.Fatalf("CreateValueFile: %v", err)
+	}
+	return sim
+}
+
+func walletConfig() wallet.Config {
+	return wallet.Config{AID: walletAID, FileNo: 1, DebitKey: walletKey, CreditKey: walletKey}
+}
+
+func balance(t *testing.T, card hardware.Transport) int32 {
+	t.Helper()
+	b, err := wallet.NewWallet(desfire.NewDESFireWithTransport(card), walletConfig()).Balance()
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	return b
+}
+
+// exchangeOf returns the number of the exchange of a payment that sends
+// the DESFire command
+func exchangeOf(t *testing.T, cmd byte) int {
+	t.Helper()
+	rec := transcript.NewRecorder(newWallet(t))
+	if _, err := wallet.NewWallet(desfire.NewDESFireWithTransport(rec), walletConfig()).Pay(10); err != nil {
+		t.Fatalf("Pay: %v", err)
+	}
+	for i, e := range rec.Exchanges() {
+		if e.Command[1] == cmd {
+			return i + 1
+		}
+	}
+	t.Fatalf("payment sends no command %02X", cmd)
+	return 0
+}
+
+func TestPaymentRollback(t *testing.T) {
+	debit := exchangeOf(t, desfire.CmdDebit)
+	commit := exchangeOf(t, desfire.CmdCommitTransaction)
+
+	for _, tc := range []struct {
+		name    string
+		config  fault.Config
+		err     error
+		balance int32
+	}{
+		// The card executed the debit; the wallet aborts the transaction
+		{"debit response lost", fault.Config{DropResponses: []int{debit}}, fault.ErrResponseLost, 100},
+		// Taking the card away discards the pending debit
+		{"removed before commit", fault.Config{RemoveAfter: debit}, scard.ErrRemovedCard, 100},
+		// The card committed, only the confirmation is missing
+		{"commit response lost", fault.Config{DropResponses: []int{commit}}, fault.ErrResponseLost, 90},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sim := newWallet(t)
+			faulty := fault.NewTransport(sim, tc.config)
+			rec := transcript.NewRecorder(faulty)
+			if _, err := wallet.NewWallet(desfire.NewDESFireWithTransport(rec), walletConfig()).Pay(10); !errors.Is(err, tc.err) {
+				t.Fatalf("Pay: %v", err)
+			}
+			exchanges := rec.Exchanges()
+			if last := exchanges[len(exchanges)-1].Command[1]; last != desfire.CmdAbortTransaction {
+				t.Fatalf("failed payment ended with command %02X, not an abort", last)
+			}
+			faulty.Reinsert()
+			if b := balance(t, faulty); b != tc.balance {
+				t.Fatalf("balance %d, want %d", b, tc.balance)
+			}
+		})
+	}
+}
+
+func TestSessionDropped(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config fault.Config
+		err    error
+	}{
+		// Authentication takes two exchanges
+		{"response lost", fault.Config{DropResponses: []int{3}}, fault.ErrResponseLost},
+		{"card removed", fault.Config{RemoveAfter: 2}, scard.ErrRemovedCard},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			df := desfire.NewDESFireWithTransport(fault.NewTransport(simulator.NewSimulator(ntagUID), tc.config))
+			if err := df.Authenticate(0, desfire.KeyTypeDES, make([]byte, 8)); err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if _, err := df.GetApplicationIDs(); !errors.Is(err, tc.err) {
+				t.Fatalf("GetApplicationIDs: %v", err)
+			}
+			if df.AuthState().Authenticated {
+				t.Fatalf("session kept after the fault")
+			}
+		})
+	}
+}
+
+// newNTAG215 returns a blank NTAG215 formatted for NDEF without password
+// protection
+func newNTAG215(uid []byte) (*ntagsim.Simulator, error) {
+	memory := make([]byte, ntag.NTAG215TotalPages*4)
+	copy(memory[0:3], uid[0:3])
+	memory[3] = 0x88 ^ uid[0] ^ uid[1] ^ uid[2]
+	copy(memory[4:8], uid[3:7])
+	memory[8] = uid[3] ^ uid[4] ^ uid[5] ^ uid[6]
+	memory[9] = 0x48
+	copy(memory[12:16], []byte{0xE1, 0x10, 0x3E, 0x00})
+	copy(memory[16:20], []byte{0x03, 0x00, 0xFE, 0x00})
+	config := 0x83 * 4
+	copy(memory[config-4:], []byte{0x00, 0x00, 0x00, 0xBD})
+	copy(memory[config:], []byte{0x04, 0x00, 0x00, 0xFF})
+	copy(memory[config+8:], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+	return ntagsim.NewSimulator(memory)
+}
@@ -0,0 +1,67 @@
+package hardware_test
+
+import (
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// fuzzConnection answers each command with the next length-prefixed chunk of
+// the fuzz input, and 6A 82 once the input is used up
+type fuzzConnection struct {
+	data []byte
+	atr  []byte
+}
+
+func (c *fuzzConnection) Transmit(cmd []byte) ([]byte, error) {
+	if len(c.data) == 0 {
+		return []byte{0x6A, 0x82}, nil
+	}
+	n := min(int(c.data[0]), len(c.data)-1)
+	rsp := c.data[1 : 1+n]
+	c.data = c.data[1+n:]
+	return rsp, nil
+}
+
+func (c *fuzzConnection) Control(code uint32, data []byte) ([]byte, error) {
+	return c.Transmit(data)
+}
+
+func (c *fuzzConnection) Status() (hardware.ConnectionStatus, error) {
+	return hardware.ConnectionStatus{Reader: "fuzz", ATR: c.atr, Protocol: "T=1"}, nil
+}
+
+func (c *fuzzConnection) Disconnect() error {
+	return nil
+}
+
+// FuzzConnect runs the card detection, e.g. the DESFire GetVersion parsing,
+// on arbitrary card responses
+func FuzzConnect(f *testing.F) {
+	// UID, then a DESFire EV1 answering GetVersion
+	f.Add([]byte{
+		9, 0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x90, 0x00,
+		9, 0x04, 0x01, 0x01, 0x01, 0x00, 0x1A, 0x05, 0x91, 0xAF,
+		9, 0x04, 0x01, 0x01, 0x01, 0x00, 0x1A, 0x05, 0x91, 0xAF,
+		9, 0x04, 0x01, 0x01, 0x01, 0x00, 0x1A, 0x05, 0x91, 0xAF,
+	}, []byte{0x3B, 0x81, 0x80, 0x01, 0x80, 0x80})
+	// A DESFire cutting its GetVersion answer short
+	f.Add([]byte{
+		6, 0x04, 0x01, 0x02, 0x03, 0x90, 0x00,
+		3, 0x04, 0x91, 0xAF,
+		2, 0x6A, 0x82,
+		2, 0x6A, 0x82,
+		3, 0x04, 0x91, 0xAF,
+	}, []byte{})
+	// An NTAG215
+	f.Add([]byte{
+		9, 0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x90, 0x00,
+		2, 0x6E, 0x00,
+		18, 0x04, 0x01, 0x02, 0x8B, 0x03, 0x04, 0x05, 0x06, 0x04, 0x48, 0, 0, 0xE1, 0x10, 0x3E, 0x00, 0x90, 0x00,
+	}, []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x68})
+	f.Fuzz(func(t *testing.T, data []byte, atr []byte) {
+		reader := hardware.NewReaderWithConnection(&fuzzConnection{data: data, atr: atr})
+		reader.Connect()
+		reader.CardInfo()
+	})
+}
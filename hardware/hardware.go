@@ -2,11 +2,15 @@ package hardware
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/hardware/atr"
 )
 
 const (
@@ -18,8 +22,10 @@ const (
 	MIFARE_DESFIRE    = "DESFire EV1/EV2/EV3"
 	MIFARE_PLUS_SE_2K = "MIFARE Plus SE 2K"
 	MIFARE_PLUS_SE_4K = "MIFARE Plus SE 4K"
+	MIFARE_PLUS       = "MIFARE Plus"
 	TOPAZ_JEWEL       = "Topaz/Jewel"
 	FELI_CA           = "FeliCa"
+	ISO14443_B        = "ISO 14443-4 Type B"
 )
 
 type CardInfo struct {
@@ -32,11 +38,47 @@ type CardInfo struct {
 	BlockCount  int    // Number of blocks
 	SectorCount int    // Number of sectors
 	Protocol    string // Communication protocol
+	ATRCardName string // Card name from a PC/SC Part 3 contactless ATR
+	ATRMismatch bool   // ATR card name contradicts the ATQA/SAK detection
+	ATQB        []byte // Answer to Request Type B
+	// MIFARE Plus security level ("SL0" to "SL3"), empty for other cards. SL1
+	// cards are MIFARE Classic compatible.
+	SecurityLevel string
+	ParsedATR     *atr.ATR // decoded ATR, nil if it does not decode
+	// Decoded ATS of ISO 14443-4 Type A cards, nil for other cards and
+	// readers that do not return it
+	ParsedATS *atr.ATS
 }
 
+// atrCardTypes lists the detected card types consistent with a PC/SC Part 3
+// card name. Readers report NTAG as Ultralight.
+var atrCardTypes = map[uint16][]string{
+	atr.CardNameMifare1K:     {MIFARE_CLASSIK_1K},
+	atr.CardNameMifare4K:     {MIFARE_CLASSIK_4K},
+	atr.CardNameMifareMini:   {MIFARE_MINI},
+	atr.CardNameUltralight:   {MIFARE_ULTRALIGHT, NTAG},
+	atr.CardNameUltralightC:  {MIFARE_ULTRALIGHT},
+	atr.CardNameMifarePlus2K: {MIFARE_PLUS_SE_2K, MIFARE_CLASSIK_1K},
+	atr.CardNameMifarePlus4K: {MIFARE_PLUS_SE_4K, MIFARE_CLASSIK_4K},
+	atr.CardNameJewel:        {TOPAZ_JEWEL},
+	atr.CardNameTopaz:        {TOPAZ_JEWEL},
+	atr.CardNameFeliCa:       {FELI_CA},
+}
+
+// Reader is a connection to a PC/SC reader and the card on it. Exchanges
+// with the card, connecting, reconnecting and disconnecting may come from
+// several goroutines: a lock lets one at a time through, and Transaction
+// holds it across a sequence of APDUs. Waiting for cards, with WaitForCard
+// and WaitForCardRemoval, blocks the PC/SC context and belongs to a single
+// goroutine while no other talks to the card; Events watches on its own.
+// Configure the reader before sharing it. SetLogger and Logger may be
+// called from any goroutine.
 type Reader struct {
+	// mu guards the card connection and the exchanges with the card
+	mu sync.Mutex
+
 	ctx       *scard.Context
-	card      *scard.Card
+	card      Connection
 	reader    string
 	stateFlag scard.StateFlag
 	cardInfo  *CardInfo
@@ -45,13 +87,34 @@ type Reader struct {
 	page1     []byte
 	page2     []byte
 	page3     []byte
+
+	extendedLength bool
+	middlewares    []Middleware
+
+	// opCtx bounds the operation holding the lock: the card detection of
+	// ConnectContext and ReconnectContext, or the exchange of TransmitContext
+	opCtx context.Context
+
+	// pattern selects the reader again on Rebind, see SetHotplug
+	pattern string
+	hotplug bool
+
+	reconnect    ReconnectPolicy
+	reconnecting bool
+	retry        RetryPolicy
+	timeouts     Timeouts
+	// abandoned is closed when an exchange given up on ends; the
+	// connection is not used until it is re-established
+	abandoned <-chan struct{}
+
+	connectConfig ConnectConfig
 }
 
 // NewReader initializes a new hardware
 func NewReader() (*Reader, error) {
 	ctx, err := scard.EstablishContext()
 	if err != nil {
-		return nil, fmt.Errorf("failed to establish context: %v", err)
+		return nil, fmt.Errorf("failed to establish context: %w", err)
 	}
 
 	r := &Reader{
@@ -63,21 +126,147 @@ func NewReader() (*Reader, error) {
 }
 
 func (m *Reader) Ctx() *scard.Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.ctx
 }
 
+// Card returns the PC/SC card handle, nil if not connected through PC/SC
 func (m *Reader) Card() *scard.Card {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.card.(pcscConnection); ok {
+		return c.card
+	}
+	return nil
+}
+
+// NewReaderWithConnection creates a reader on a connection of another
+// backend than PC/SC. Connect detects the card on it; waiting for cards and
+// reader selection need PC/SC and are not available.
+func NewReaderWithConnection(conn Connection) *Reader {
+	return &Reader{
+		card:      conn,
+		stateFlag: scard.StateUnaware,
+		cardInfo:  &CardInfo{},
+	}
+}
+
+// Connection returns the connection to the card, nil if not connected
+func (m *Reader) Connection() Connection {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.card
 }
 
+// Transport returns the connection to the card for card modules, wrapped in
+// the middlewares installed with Use. APDUs are logged at debug level as
+// sent to the card, see SetLogger. The transport follows the reader to the
+// card it connects to next, and reconnects as set with SetReconnectPolicy.
+// Each exchange takes the reader's lock, so it waits for transactions of
+// other goroutines.
+func (m *Reader) Transport() Transport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return readerTransport{
+		Transport:      lockedTransport{mu: &m.mu, transport: m.chain()},
+		extendedLength: m.extendedLength,
+	}
+}
+
+// chain returns the transport to the card without the reader's lock
+func (m *Reader) chain() Transport {
+	transport := Chain(loggingTransport{transport: cardTransport{reader: m}}, retryWith(m.retry, m.operation))
+	return Chain(transport, m.middlewares...)
+}
+
+// lockedTransport holds a lock for each exchange
+type lockedTransport struct {
+	mu        *sync.Mutex
+	transport Transport
+}
+
+func (t lockedTransport) Transmit(cmd []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.transport.Transmit(cmd)
+}
+
+// Use adds middlewares to the reader's transport, inside those added before,
+// i.e. closer to the card. Card modules created afterwards send their APDUs
+// through them, as does the card detection of Connect.
+func (m *Reader) Use(middlewares ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.middlewares = append(m.middlewares, middlewares...)
+}
+
+// readerTransport is the transport of a reader, reporting its settings to
+// the card modules
+type readerTransport struct {
+	Transport
+	extendedLength bool
+}
+
+func (t readerTransport) ExtendedLength() bool {
+	return t.extendedLength
+}
+
+// SetExtendedLength tells card modules whether extended length APDUs reach
+// the card. The ACR122U passes short APDUs only, other PC/SC readers chosen
+// with SelectReader may pass extended ones.
+func (m *Reader) SetExtendedLength(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.extendedLength = enabled
+}
+
+// operation returns the context of the operation holding the lock, see
+// opCtx, or context.Background if none is set
+func (m *Reader) operation() context.Context {
+	if m.opCtx == nil {
+		return context.Background()
+	}
+	return m.opCtx
+}
+
+// transmit sends an APDU with the reader's lock held
+func (m *Reader) transmit(cmd []byte) ([]byte, error) {
+	if m.opCtx != nil {
+		if m.opCtx.Err() != nil {
+			return nil, context.Cause(m.opCtx)
+		}
+	}
+	return m.chain().Transmit(cmd)
+}
+
+// TransmitContext sends an APDU like the transport of Transport and returns
+// the error of ctx once it ends. The card may still execute a command it
+// gave up on, so the connection is given up as after an exchange timeout,
+// see Timeouts.
+func (m *Reader) TransmitContext(ctx context.Context, cmd []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opCtx = ctx
+	defer func() { m.opCtx = nil }()
+	return m.transmit(cmd)
+}
+
 func (m *Reader) Reader() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.reader
 }
 
 // Close releases the hardware resources
 func (m *Reader) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.card != nil {
-		m.card.Disconnect(scard.LeaveCard)
+		m.card.Disconnect()
 	}
 	if m.ctx != nil {
 		return m.ctx.Release()
@@ -85,78 +274,238 @@ func (m *Reader) Close() error {
 	return nil
 }
 
+// WaitForCard blocks until a card is on the reader, or until the wait
+// timeout of SetTimeouts
 func (m *Reader) WaitForCard() error {
-	states := []scard.ReaderState{
-		{Reader: m.reader, CurrentState: m.stateFlag},
+	return m.WaitForCardContext(context.Background())
+}
+
+// WaitForCardContext blocks until a card is on the reader or ctx ends, in
+// which case it returns the error of ctx. The wait is cancelled in PC/SC, so
+// no goroutine is left blocked.
+func (m *Reader) WaitForCardContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	m.mu.Lock()
+	pcsc, state, hotplug, timeout := m.ctx, m.waitState(), m.hotplug, m.timeouts.Wait
+	m.mu.Unlock()
+	if pcsc == nil {
+		return errNoPCSC
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	stop := cancelWait(ctx, pcsc)
+	defer func() { stop() }()
+
+	states := []scard.ReaderState{state}
 	for {
-		err := m.ctx.GetStatusChange(states, 876000*time.Hour)
+		err := pcsc.GetStatusChange(states, 876000*time.Hour)
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if hotplug && readerLost(err, states[0].EventState) {
+			Logger().Warn("reader lost, waiting for it", "reader", states[0].Reader, "error", err)
+			if err := m.waitForReader(ctx); err != nil {
+				return err
+			}
+			// Rebind may have established a new PC/SC context
+			stop()
+			m.mu.Lock()
+			pcsc, states[0] = m.ctx, m.waitState()
+			m.mu.Unlock()
+			stop = cancelWait(ctx, pcsc)
+			continue
+		}
 		if err != nil {
 			return err
 		}
 		if states[0].EventState&scard.StatePresent != 0 {
+			m.mu.Lock()
 			m.stateFlag = states[0].EventState
+			m.mu.Unlock()
 			break
 		}
+		states[0].CurrentState = states[0].EventState &^ scard.StateChanged
 	}
 	return nil
 }
 
+// WaitForCardRemoval blocks until no card is on the reader, e.g. before
+// waiting for the next card where the same card must not count twice
+func (m *Reader) WaitForCardRemoval() error {
+	return m.WaitForCardRemovalContext(context.Background())
+}
+
+// WaitForCardRemovalContext blocks like WaitForCardRemoval until ctx ends,
+// in which case it returns the error of ctx. A reader unplugged meanwhile
+// counts as the card removed.
+func (m *Reader) WaitForCardRemovalContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	pcsc, state, timeout := m.ctx, m.waitState(), m.timeouts.Wait
+	m.mu.Unlock()
+	if pcsc == nil {
+		return errNoPCSC
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	stop := cancelWait(ctx, pcsc)
+	defer stop()
+
+	states := []scard.ReaderState{state}
+	for {
+		err := pcsc.GetStatusChange(states, 876000*time.Hour)
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		gone, state, err := cardGone(err, states[0].EventState)
+		if err != nil {
+			return err
+		}
+		if gone {
+			m.mu.Lock()
+			m.stateFlag = state
+			m.mu.Unlock()
+			return nil
+		}
+		states[0].CurrentState = state
+	}
+}
+
+// waitState returns the state of the reader to wait on for a change, with
+// the reader's lock held
+func (m *Reader) waitState() scard.ReaderState {
+	return scard.ReaderState{Reader: m.reader, CurrentState: m.stateFlag}
+}
+
+// cancelWait cancels the waits on the PC/SC context once ctx ends, until
+// stopped like context.AfterFunc
+func cancelWait(ctx context.Context, pcsc *scard.Context) (stop func() bool) {
+	return context.AfterFunc(ctx, func() { pcsc.Cancel() })
+}
+
+// cardGone reports whether a status change of the reader means that no card
+// is on it, and returns the state to wait on next. A lost reader counts as
+// the card gone, so the next wait starts from scratch.
+func cardGone(err error, event scard.StateFlag) (bool, scard.StateFlag, error) {
+	if readerLost(err, event) {
+		return true, scard.StateUnaware, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	state := event &^ scard.StateChanged
+	return state&scard.StatePresent == 0, state, nil
+}
+
 func (m *Reader) Disconnect() {
-	m.card.Disconnect(scard.LeaveCard)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.card.Disconnect()
 }
 
 // ListReaders returns available PC/SC readers
 func (m *Reader) ListReaders() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx == nil {
+		return nil, errNoPCSC
+	}
 	readers, err := m.ctx.ListReaders()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list readers: %v", err)
+		return nil, fmt.Errorf("failed to list readers: %w", err)
 	}
 	return readers, nil
 }
 
 func (m *Reader) UseReader(reader string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.reader = reader
 }
 
 // Connect connects to the first available hardware with a card
 func (m *Reader) Connect() error {
-	if m.reader == "" {
-		return fmt.Errorf("no hardware selected, use: UseReader(hardware string)")
-	}
-	card, err := m.ctx.Connect(m.reader, scard.ShareShared, scard.ProtocolT0|scard.ProtocolT1)
-	if err != nil {
-		return fmt.Errorf("failed to connect to hardware: %v", err)
+	return m.ConnectContext(context.Background())
+}
+
+// ConnectContext connects like Connect. The card detection, which takes a
+// number of APDUs, stops when ctx ends and returns its error. Direct
+// connections, see SetConnectConfig, connect to the reader only.
+func (m *Reader) ConnectContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ctx, cancel := withTimeout(ctx, m.timeouts.Connect)
+	defer cancel()
+	m.opCtx = ctx
+	defer func() { m.opCtx = nil }()
 
-	m.card = card
+	if m.ctx != nil {
+		if m.reader == "" {
+			return fmt.Errorf("no hardware selected, use: UseReader(hardware string)")
+		}
+		share, protocol := m.shareMode()
+		card, err := m.ctx.Connect(m.reader, share, protocol)
+		if err != nil {
+			return fmt.Errorf("failed to connect to hardware: %w", err)
+		}
+		m.card = pcscConnection{card: card}
+	}
+	if err := m.settle(ctx); err != nil {
+		return err
+	}
+	if m.direct() {
+		*m.cardInfo = CardInfo{}
+		return nil
+	}
 	uid, err := m.getUID()
+	if err == nil {
+		m.cardInfo.UID = uid
+		err = m.detectCardType()
+	}
 	if err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
 		return err
 	}
-	m.cardInfo.UID = uid
-	err = m.detectCardType()
-	return err
+	Logger().Debug("card detected", "reader", m.reader, "uid", fmt.Sprintf("%X", uid),
+		"type", m.cardInfo.Type, "atr", fmt.Sprintf("%X", m.cardInfo.ATR))
+	return nil
 }
 
+// CardInfo returns a copy of what Connect detected about the card
 func (m *Reader) CardInfo() *CardInfo {
-	return m.cardInfo
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info := *m.cardInfo
+	info.UID = bytes.Clone(info.UID)
+	info.ATR = bytes.Clone(info.ATR)
+	info.ATQA = bytes.Clone(info.ATQA)
+	info.ATQB = bytes.Clone(info.ATQB)
+	return &info
 }
 
 func (m *Reader) getUID() ([]byte, error) {
 	if m.card == nil {
-		return nil, fmt.Errorf("not connected to card")
+		return nil, ErrNotConnected
 	}
 	cmd := []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}
-	rsp, err := m.card.Transmit(cmd)
+	rsp, err := m.transmit(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get UID: %v", err)
+		return nil, fmt.Errorf("failed to get UID: %w", err)
 	}
 	if len(rsp) < 2 {
-		return nil, fmt.Errorf("invalid response length")
+		return nil, fmt.Errorf("invalid response length: %w", ErrWrongLength)
 	}
 	if rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
-		return nil, fmt.Errorf("error status: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+		return nil, fmt.Errorf("error status: %w", NewStatusError(cmd, rsp))
 	}
 	return rsp[:len(rsp)-2], nil
 }
@@ -190,13 +539,8 @@ func (m *Reader) detectCardType() error {
 	if err != nil {
 		return err
 	}
-	protocol := "Unknown"
-	switch status.ActiveProtocol {
-	case scard.ProtocolT0:
-		protocol = "T=0"
-	case scard.ProtocolT1:
-		protocol = "T=1"
-	default:
+	protocol := status.Protocol
+	if protocol == "" {
 		protocol = "Unknown"
 	}
 	cardType, sizeInBytes, err := m.getCardType(atqa, sak, sizeInBytes)
@@ -204,15 +548,118 @@ func (m *Reader) detectCardType() error {
 		return err
 	}
 
+	parsed, _ := atr.Parse(status.ATR)
+	m.cardInfo.SecurityLevel = ""
+	if name, level, size, ok := m.detectPlus(parsed); ok {
+		cardType, sizeInBytes = name, size
+		m.cardInfo.SecurityLevel = level
+	}
+
+	m.cardInfo.ATQB = nil
+	if strings.HasPrefix(cardType, "Unknown") {
+		// ATQA and SAK only exist for Type A, poll for a Type B card
+		if atqb, ok := m.tryTypeB(); ok {
+			cardType = fmt.Sprintf("%s (PUPI=%s)", ISO14443_B, hex.EncodeToString(atqb[1:5]))
+			m.cardInfo.ATQB = atqb
+		}
+	}
+
 	m.cardInfo.Type = cardType
-	m.cardInfo.ATR = status.Atr
+	m.cardInfo.ATR = status.ATR
 	m.cardInfo.SAK = sak
 	m.cardInfo.ATQA = atqa
 	m.cardInfo.Protocol = protocol
 	m.cardInfo.Capacity = sizeInBytes
+	m.cardInfo.ParsedATR = parsed
+	m.cardInfo.ParsedATS = m.getATS(parsed)
+	m.checkATR(parsed)
 	return nil
 }
 
+// checkATR decodes the card name of contactless storage cards from the ATR
+// and cross-checks it with the type detected from ATQA and SAK
+func (m *Reader) checkATR(parsed *atr.ATR) {
+	m.cardInfo.ATRCardName = ""
+	m.cardInfo.ATRMismatch = false
+	if parsed == nil {
+		return
+	}
+	contactless, ok := parsed.Contactless()
+	if !ok {
+		return
+	}
+	m.cardInfo.ATRCardName = contactless.Name()
+	expected, ok := atrCardTypes[contactless.CardName]
+	if !ok {
+		return
+	}
+	m.cardInfo.ATRMismatch = true
+	for _, name := range expected {
+		if strings.HasPrefix(m.cardInfo.Type, name) {
+			m.cardInfo.ATRMismatch = false
+		}
+	}
+}
+
+// plusSizes maps the low nibble of the NXP type byte to the memory size
+var plusSizes = map[byte]int{0x01: 1024, 0x02: 2048, 0x03: 4096, 0x04: 8192}
+
+// detectPlus identifies MIFARE Plus cards and their security level. At SL1
+// and SL2 the ATR carries a Plus card name. At SL0 and SL3 the card speaks
+// ISO 14443-4: the NXP type byte in the ATS historical bytes (C1 05 2x)
+// identifies it, and only SL0 answers a WritePerso to the missing block 9090
+// with "invalid block number".
+func (m *Reader) detectPlus(parsed *atr.ATR) (string, string, int, bool) {
+	if parsed == nil {
+		return "", "", 0, false
+	}
+	if contactless, ok := parsed.Contactless(); ok {
+		switch contactless.CardName {
+		case atr.CardNameMifarePlus2K:
+			return fmt.Sprintf("%s (SL1, CRYPTO1 compatible)", MIFARE_PLUS_SE_2K), "SL1", 2048, true
+		case atr.CardNameMifarePlus4K:
+			return fmt.Sprintf("%s (SL1, CRYPTO1 compatible)", MIFARE_PLUS_SE_4K), "SL1", 4096, true
+		case atr.CardNameMifarePlusSL22K:
+			return fmt.Sprintf("%s (SL2, 2048B)", MIFARE_PLUS), "SL2", 2048, true
+		case atr.CardNameMifarePlusSL24K:
+			return fmt.Sprintf("%s (SL2, 4096B)", MIFARE_PLUS), "SL2", 4096, true
+		}
+		return "", "", 0, false
+	}
+	h := parsed.Historical
+	if len(h) < 3 || h[0] != 0xC1 || h[1] < 0x01 || h[2]&0xF0 != 0x20 {
+		return "", "", 0, false
+	}
+	size := plusSizes[h[2]&0x0F]
+	level := "SL3"
+	probe := append([]byte{0xA8, 0x90, 0x90}, make([]byte, 16)...)
+	if rsp, err := m.transmit(probe); err == nil && len(rsp) > 0 && rsp[0] == 0x09 {
+		level = "SL0"
+	}
+	return fmt.Sprintf("%s (%s, AES, %dB)", MIFARE_PLUS, level, size), level, size, true
+}
+
+// getATS reads the ATS of ISO 14443-4 cards with Get Data (FF CA 01 00 00),
+// which readers answer if they keep it. Contactless storage cards, whose
+// ATR carries a PC/SC card name instead, have none.
+func (m *Reader) getATS(parsed *atr.ATR) *atr.ATS {
+	if parsed == nil {
+		return nil
+	}
+	if _, ok := parsed.Contactless(); ok {
+		return nil
+	}
+	rsp, err := m.transmit([]byte{0xFF, 0xCA, 0x01, 0x00, 0x00})
+	if err != nil || len(rsp) < 3 || !bytes.Equal(rsp[len(rsp)-2:], []byte{0x90, 0x00}) {
+		return nil
+	}
+	ats, err := atr.ParseATS(rsp[:len(rsp)-2])
+	if err != nil {
+		return nil
+	}
+	return ats
+}
+
 func (m *Reader) getCardAttributes() (sak byte, atqa []byte, sizeInBytes int, err error) {
 	if ok, size := m.tryNTAG(m.page3); ok {
 		sizeInBytes = size
@@ -242,12 +689,12 @@ func (m *Reader) getCardAttributes() (sak byte, atqa []byte, sizeInBytes int, er
 		return sak, atqa, 0, nil
 	}
 	selectAll := []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}
-	resp, err := m.card.Transmit(selectAll)
+	resp, err := m.transmit(selectAll)
 	if err != nil {
-		return sak, atqa, 0, fmt.Errorf("failed to transmit: %v", err)
+		return sak, atqa, 0, fmt.Errorf("failed to transmit: %w", err)
 	}
 	if len(resp) < 4 || !bytes.Equal(resp[len(resp)-2:], []byte{0x90, 0x00}) {
-		return sak, atqa, 0, fmt.Errorf("invalid response length")
+		return sak, atqa, 0, fmt.Errorf("invalid response length: %w", ErrWrongLength)
 	}
 	atqa, sak = resp[0:2], resp[2]
 	return sak, atqa, 0, nil
@@ -321,13 +768,13 @@ func (m *Reader) classicLoadKey(keyNumber byte, key []byte) error {
 	cmd := []byte{0xFF, 0x82, 0x00, keyNumber, 0x06}
 	cmd = append(cmd, key...)
 
-	rsp, err := m.card.Transmit(cmd)
+	rsp, err := m.transmit(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to load key: %v", err)
+		return fmt.Errorf("failed to load key: %w", err)
 	}
 
 	if len(rsp) != 2 || rsp[0] != 0x90 || rsp[1] != 0x00 {
-		return fmt.Errorf("key load failed: %v", rsp)
+		return fmt.Errorf("key load failed: %w", NewStatusError(cmd, rsp))
 	}
 
 	return nil
@@ -337,13 +784,13 @@ func (m *Reader) classicLoadKey(keyNumber byte, key []byte) error {
 func (m *Reader) classicAuthenticate(block byte, keyType byte, keyNumber byte) error {
 	cmd := []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, block, keyType, keyNumber}
 
-	rsp, err := m.card.Transmit(cmd)
+	rsp, err := m.transmit(cmd)
 	if err != nil {
-		return fmt.Errorf("authentication failed: %v", err)
+		return fmt.Errorf("authentication failed: %w", err)
 	}
 
 	if len(rsp) != 2 || rsp[0] != 0x90 || rsp[1] != 0x00 {
-		return fmt.Errorf("authentication error: %v", rsp)
+		return fmt.Errorf("authentication error: %w", NewStatusError(cmd, rsp))
 	}
 
 	return nil
@@ -352,7 +799,7 @@ func (m *Reader) classicAuthenticate(block byte, keyType byte, keyNumber byte) e
 func (m *Reader) tryUltralight() bool {
 	CmdRead := byte(0x30)
 	cmd := []byte{CmdRead, 4}
-	response, err := m.card.Transmit(cmd)
+	response, err := m.transmit(cmd)
 	if err != nil {
 		return false
 	}
@@ -383,37 +830,56 @@ func (m *Reader) tryNTAG(page3 []byte) (bool, int) {
 
 func (m *Reader) readPage(page byte) ([]byte, error) {
 	cmd := []byte{0xFF, 0xB0, 0x00, page, 0x04}
-	rsp, err := m.card.Transmit(cmd)
+	rsp, err := m.transmit(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("read failed: %v", err)
+		return nil, fmt.Errorf("read failed: %w", err)
 	}
 	if len(rsp) < 2 {
-		return nil, fmt.Errorf("invalid response length")
+		return nil, fmt.Errorf("invalid response length: %w", ErrWrongLength)
 	}
 	if rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
-		return nil, fmt.Errorf("read error: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+		return nil, fmt.Errorf("read error: %w", NewStatusError(cmd, rsp))
+	}
+	if len(rsp) < 6 {
+		return nil, fmt.Errorf("read returned %d bytes", len(rsp)-2)
 	}
 	return rsp[:4], nil
 }
 
 func (m *Reader) readBlock(block byte) ([]byte, error) {
 	cmd := []byte{0xFF, 0xB0, 0x00, block, 0x10}
-	rsp, err := m.card.Transmit(cmd)
+	rsp, err := m.transmit(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("read failed: %v", err)
+		return nil, fmt.Errorf("read failed: %w", err)
 	}
 	if len(rsp) < 2 {
-		return nil, fmt.Errorf("invalid response length")
+		return nil, fmt.Errorf("invalid response length: %w", ErrWrongLength)
 	}
 	if rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
-		return nil, fmt.Errorf("read error: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+		return nil, fmt.Errorf("read error: %w", NewStatusError(cmd, rsp))
 	}
 	return rsp[:len(rsp)-2], nil
 }
 
+// tryTypeB lists a Type B target through the PN532 (InListPassiveTarget at
+// 106 kbps) and returns its ATQB
+func (m *Reader) tryTypeB() ([]byte, bool) {
+	cmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x05, 0xD4, 0x4A, 0x01, 0x03, 0x00}
+	rsp, err := m.transmit(cmd)
+	if err != nil || len(rsp) < 2 || !bytes.Equal(rsp[len(rsp)-2:], []byte{0x90, 0x00}) {
+		return nil, false
+	}
+	// D5 4B, NbTg, Tg, ATQB
+	rsp = rsp[:len(rsp)-2]
+	if len(rsp) < 16 || rsp[0] != 0xD5 || rsp[1] != 0x4B || rsp[2] == 0 || rsp[4] != 0x50 {
+		return nil, false
+	}
+	return append([]byte{}, rsp[4:16]...), true
+}
+
 func (m *Reader) tryDESFireVersion() ([]byte, bool) {
 	cmd := []byte{0x90, 0x60, 0x00, 0x00, 0x00}
-	rsp, err := m.card.Transmit(cmd)
+	rsp, err := m.transmit(cmd)
 	if err != nil {
 		return nil, false
 	}
@@ -429,18 +895,23 @@ func (m *Reader) tryDESFireVersion() ([]byte, bool) {
 
 func (m *Reader) getDESFireInfo() (string, int, bool) {
 	cmd := []byte{0x90, 0x60, 0x00, 0x00, 0x00}
-	rsp, err := m.card.Transmit(cmd)
+	rsp, err := m.transmit(cmd)
 	if err != nil {
 		return "", 0, false
 	}
 	if len(rsp) <= 2 {
 		return "", 0, false
 	}
+	// Each frame holds vendor, type, subtype, major, minor, size and
+	// protocol, followed by the status
+	if len(rsp) < 9 {
+		return "", 0, true
+	}
 	hwMajor := rsp[3]
-	if len(rsp) > 0 && rsp[len(rsp)-1] == 0xAF {
+	if rsp[len(rsp)-1] == 0xAF {
 		cmd := []byte{0x90, 0xAF, 0x00, 0x00, 0x00}
-		rsp, err := m.card.Transmit(cmd)
-		if err != nil {
+		rsp, err := m.transmit(cmd)
+		if err != nil || len(rsp) < 9 {
 			return "", 0, false
 		}
 		size := rsp[5]
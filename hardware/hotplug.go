@@ -0,0 +1,201 @@
+package hardware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// PnPNotification is the pseudo reader whose state changes when readers are
+// plugged in or unplugged
+const PnPNotification = `\\?PnP?\Notification`
+
+// pnpPollInterval is the interval readers are listed at where PC/SC has no
+// PnP notification
+const pnpPollInterval = time.Second
+
+// ReaderChange reports readers plugged in or unplugged
+type ReaderChange struct {
+	Added   []string
+	Removed []string
+	Readers []string // all readers after the change
+	Time    time.Time
+	Err     error // watching ended
+}
+
+// WatchReaders sends a change for each reader plugged in or unplugged, with
+// the readers present at the start reported as added. The channel is closed
+// when ctx ends, or after a change with Err.
+func WatchReaders(ctx context.Context) <-chan ReaderChange {
+	changes := make(chan ReaderChange, 4)
+	go func() {
+		defer close(changes)
+		if err := watchReaders(ctx, changes); err != nil && ctx.Err() == nil {
+			select {
+			case changes <- ReaderChange{Time: time.Now(), Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return changes
+}
+
+func watchReaders(ctx context.Context, changes chan<- ReaderChange) error {
+	sctx, err := scard.EstablishContext()
+	if err != nil {
+		return fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer sctx.Release()
+	stop := context.AfterFunc(ctx, func() { sctx.Cancel() })
+	defer stop()
+
+	var readers []string
+	states := []scard.ReaderState{{Reader: PnPNotification, CurrentState: scard.StateUnaware}}
+	for {
+		current, err := listReaders(sctx)
+		if err != nil {
+			return err
+		}
+		if change := diffReaders(readers, current); change != nil {
+			select {
+			case changes <- *change:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		readers = current
+
+		err = sctx.GetStatusChange(states, -1)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && !errors.Is(err, scard.ErrTimeout) {
+			return fmt.Errorf("failed to watch readers: %w", err)
+		}
+		if states[0].EventState&scard.StateUnknown != 0 {
+			// No PnP notification, list the readers from time to time
+			select {
+			case <-time.After(pnpPollInterval):
+			case <-ctx.Done():
+				return nil
+			}
+			continue
+		}
+		states[0].CurrentState = states[0].EventState &^ scard.StateChanged
+	}
+}
+
+// listReaders lists the readers, none if PC/SC reports no readers as error
+func listReaders(sctx *scard.Context) ([]string, error) {
+	readers, err := sctx.ListReaders()
+	if errors.Is(err, scard.ErrNoReadersAvailable) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list readers: %w", err)
+	}
+	return readers, nil
+}
+
+// diffReaders returns the change between two reader lists, nil if none
+func diffReaders(before, after []string) *ReaderChange {
+	change := &ReaderChange{Readers: after, Time: time.Now()}
+	for _, name := range after {
+		if !slices.Contains(before, name) {
+			change.Added = append(change.Added, name)
+		}
+	}
+	for _, name := range before {
+		if !slices.Contains(after, name) {
+			change.Removed = append(change.Removed, name)
+		}
+	}
+	if change.Added == nil && change.Removed == nil {
+		return nil
+	}
+	return change
+}
+
+// SetHotplug makes WaitForCard and WaitForCardContext survive the reader
+// being unplugged: they wait for it to come back and Rebind to it
+func (m *Reader) SetHotplug(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hotplug = enabled
+}
+
+// Rebind selects the reader again after it was unplugged or PC/SC
+// restarted, for which it establishes a new PC/SC context if the old one is
+// no longer valid. The reader is chosen by the pattern of
+// NewReaderFromConfig; otherwise the reader used before is kept if present,
+// or the first reader taken. The card connection is dropped.
+func (m *Reader) Rebind() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx == nil {
+		return errNoPCSC
+	}
+	if m.card != nil {
+		m.card.Disconnect()
+		m.card = nil
+	}
+	if valid, err := m.ctx.IsValid(); !valid || err != nil {
+		m.ctx.Release()
+		ctx, err := scard.EstablishContext()
+		if err != nil {
+			return fmt.Errorf("failed to establish context: %w", err)
+		}
+		m.ctx = ctx
+	}
+	readers, err := listReaders(m.ctx)
+	if err != nil {
+		return err
+	}
+	name := m.reader
+	if m.pattern != "" || !slices.Contains(readers, name) {
+		if name, err = SelectReader(readers, m.pattern); err != nil {
+			return err
+		}
+	}
+	m.reader = name
+	m.stateFlag = scard.StateUnaware
+	m.cardInfo = &CardInfo{}
+	Logger().Info("reader bound", "reader", name)
+	return nil
+}
+
+// waitForReader rebinds once a reader is back, or returns the error of ctx
+func (m *Reader) waitForReader(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes := WatchReaders(ctx)
+	for {
+		if err := m.Rebind(); err == nil {
+			return nil
+		}
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return context.Cause(ctx)
+			}
+			if change.Err != nil {
+				return change.Err
+			}
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+}
+
+// readerLost reports whether a PC/SC error or state means the reader is
+// gone, for which hot-plugging rebinds
+func readerLost(err error, state scard.StateFlag) bool {
+	return errors.Is(err, scard.ErrReaderUnavailable) || errors.Is(err, scard.ErrUnknownReader) ||
+		errors.Is(err, scard.ErrNoService) || errors.Is(err, scard.ErrServiceStopped) ||
+		errors.Is(err, scard.ErrNoReadersAvailable) ||
+		(err == nil && state&(scard.StateUnavailable|scard.StateUnknown) != 0)
+}
@@ -0,0 +1,81 @@
+package hardware
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestDiffReaders(t *testing.T) {
+	for _, tc := range []struct {
+		before, after  []string
+		added, removed []string
+	}{
+		{nil, []string{"a"}, []string{"a"}, nil},
+		{[]string{"a"}, []string{"a", "b"}, []string{"b"}, nil},
+		{[]string{"a", "b"}, []string{"b"}, nil, []string{"a"}},
+		{[]string{"a"}, []string{"b"}, []string{"b"}, []string{"a"}},
+		{[]string{"a", "b"}, []string{"b", "a"}, nil, nil},
+		{nil, nil, nil, nil},
+	} {
+		change := diffReaders(tc.before, tc.after)
+		if tc.added == nil && tc.removed == nil {
+			if change != nil {
+				t.Errorf("%v to %v: change %+v", tc.before, tc.after, change)
+			}
+			continue
+		}
+		if change == nil || !slices.Equal(change.Added, tc.added) || !slices.Equal(change.Removed, tc.removed) ||
+			!slices.Equal(change.Readers, tc.after) {
+			t.Errorf("%v to %v: change %+v", tc.before, tc.after, change)
+		}
+	}
+}
+
+func TestReaderLost(t *testing.T) {
+	for _, tc := range []struct {
+		err   error
+		state scard.StateFlag
+		lost  bool
+	}{
+		{scard.ErrReaderUnavailable, 0, true},
+		{fmt.Errorf("failed to wait for card: %w", scard.ErrUnknownReader), 0, true},
+		{scard.ErrNoService, 0, true},
+		{scard.ErrServiceStopped, 0, true},
+		{scard.ErrNoReadersAvailable, 0, true},
+		{nil, scard.StateUnavailable, true},
+		{nil, scard.StateUnknown | scard.StateChanged, true},
+		{nil, scard.StateEmpty, false},
+		{nil, scard.StatePresent, false},
+		{scard.ErrTimeout, 0, false},
+		{scard.ErrRemovedCard, 0, false},
+	} {
+		if lost := readerLost(tc.err, tc.state); lost != tc.lost {
+			t.Errorf("readerLost(%v, %#x) = %t", tc.err, tc.state, lost)
+		}
+	}
+}
+
+// TestSelectReader picks the reader Rebind binds to when the old one is gone
+func TestSelectReader(t *testing.T) {
+	readers := []string{"Generic Smart Card Reader 00 00", "ACS ACR122U PICC Interface 01 00"}
+	for _, tc := range []struct {
+		pattern, want string
+	}{
+		{"", readers[0]},
+		{"ACR122", readers[1]},
+		{"^ACS .* 01", readers[1]},
+		{"Identiv", ""},
+		{"(", ""},
+	} {
+		name, err := SelectReader(readers, tc.pattern)
+		if name != tc.want || (err == nil) != (tc.want != "") {
+			t.Errorf("SelectReader %q: %q, %v", tc.pattern, name, err)
+		}
+	}
+	if _, err := SelectReader(nil, ""); err == nil {
+		t.Errorf("SelectReader without readers")
+	}
+}
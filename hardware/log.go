@@ -0,0 +1,162 @@
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+// SetLogger sets the logger of the reader and all card modules. At debug
+// level every APDU is logged as a hexdump with key material redacted. nil
+// restores the default, slog.Default().
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// Logger returns the logger set with SetLogger
+func Logger() *slog.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// loggingTransport logs the APDUs of a transport at debug level
+type loggingTransport struct {
+	transport Transport
+}
+
+func (t loggingTransport) Transmit(cmd []byte) ([]byte, error) {
+	l := Logger()
+	if !l.Enabled(context.Background(), slog.LevelDebug) {
+		return t.transport.Transmit(cmd)
+	}
+	start := time.Now()
+	rsp, err := t.transport.Transmit(cmd)
+	attrs := []slog.Attr{
+		slog.String("cmd", hexdump(cmd, commandSecret(cmd))),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		rspText := hexdump(rsp, -1)
+		if responseSecret(cmd, rsp) {
+			rspText = hexdump(rsp[:len(rsp)-2], 0) + " " + hexdump(rsp[len(rsp)-2:], -1)
+		}
+		attrs = append(attrs, slog.String("rsp", rspText))
+	}
+	l.LogAttrs(context.Background(), slog.LevelDebug, "apdu", attrs...)
+	return rsp, err
+}
+
+// hexdump formats bytes as space separated upper case hex. Bytes from
+// secret on are shown as ** unless secret is negative.
+func hexdump(b []byte, secret int) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		if secret >= 0 && i >= secret {
+			parts[i] = "**"
+		} else {
+			parts[i] = fmt.Sprintf("%02X", v)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ntagPasswordPages hold PWD and PACK of NTAG213, NTAG215 and NTAG216
+var ntagPasswordPages = map[byte]bool{0x2B: true, 0x2C: true, 0x85: true, 0x86: true, 0xE5: true, 0xE6: true}
+
+// ulcKeyPage reports whether a page holds the 3DES key of Ultralight C. The
+// key is written with the native WRITE, so pages 2Dh to 2Fh of NTAG user
+// memory written with Update Binary are still logged.
+func ulcKeyPage(page byte) bool {
+	return page >= 0x2C && page <= 0x2F
+}
+
+// isTrailer reports whether a MIFARE Classic block is a sector trailer
+func isTrailer(block byte) bool {
+	if block < 128 {
+		return block%4 == 3
+	}
+	return block%16 == 15
+}
+
+// feliCaKeyWrite returns where the block data of a FeliCa Write Without
+// Encryption starts if it writes the card key block of Lite-S, -1 otherwise.
+// frame is the command frame behind its length byte: code, IDm, services,
+// block list and data.
+func feliCaKeyWrite(frame []byte) int {
+	if len(frame) < 10 || frame[0] != 0x08 {
+		return -1
+	}
+	pos := 10 + 2*int(frame[9])
+	if len(frame) <= pos {
+		return -1
+	}
+	n := int(frame[pos])
+	pos++
+	key := false
+	for i := 0; i < n; i++ {
+		// 2-byte elements have bit 7 set, 3-byte ones a little-endian block
+		if len(frame) < pos+2 {
+			return -1
+		}
+		if frame[pos]&0x80 != 0 {
+			key = key || frame[pos+1] == 0x87
+			pos += 2
+			continue
+		}
+		if len(frame) < pos+3 {
+			return -1
+		}
+		key = key || (frame[pos+1] == 0x87 && frame[pos+2] == 0x00)
+		pos += 3
+	}
+	if !key {
+		return -1
+	}
+	return pos
+}
+
+// commandSecret returns where key material starts in a command, -1 if it has
+// none: Load Keys, writes of sector trailers and NTAG password pages, NTAG
+// PWD_AUTH, native writes of the Ultralight C key, DESFire ChangeKey and
+// writes of the FeliCa Lite-S card key
+func commandSecret(cmd []byte) int {
+	if len(cmd) <= 5 {
+		return -1
+	}
+	switch {
+	case cmd[0] == 0xFF && cmd[1] == 0x82:
+		return 5
+	case cmd[0] == 0xFF && cmd[1] == 0xD6:
+		if (cmd[4] == 16 && isTrailer(cmd[3])) || (cmd[4] == 4 && ntagPasswordPages[cmd[3]]) {
+			return 5
+		}
+	case cmd[0] == 0xFF && cmd[1] == 0x00 && cmd[2] == 0x00 && cmd[5] == 0x1B:
+		return 6
+	case cmd[0] == 0xFF && cmd[1] == 0x00 && cmd[2] == 0x00 && len(cmd) > 9 &&
+		cmd[5] == 0xD4 && cmd[6] == 0x42 && cmd[7] == 0xA2 && ulcKeyPage(cmd[8]):
+		return 9
+	case cmd[0] == 0x90 && cmd[1] == 0xC4:
+		return 5
+	case cmd[0] == 0xFF && cmd[1] == 0x00 && cmd[2] == 0x00 && len(cmd) > 8 &&
+		cmd[5] == 0xD4 && cmd[6] == 0x42:
+		if pos := feliCaKeyWrite(cmd[8:]); pos >= 0 {
+			return 8 + pos
+		}
+	}
+	return -1
+}
+
+// responseSecret reports reads of sector trailers, which may contain key B.
+// NTAG reads of the same page numbers look alike and are masked as well.
+func responseSecret(cmd []byte, rsp []byte) bool {
+	return len(cmd) >= 5 && cmd[0] == 0xFF && cmd[1] == 0xB0 && isTrailer(cmd[3]) && len(rsp) > 2
+}
@@ -0,0 +1,64 @@
+package hardware
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// feliCaWrite builds the direct transmit of a FeliCa Write Without
+// Encryption of the block list elements and data
+func feliCaWrite(elements []byte, n int, data []byte) []byte {
+	frame := append([]byte{0x08}, 0x01, 0x2E, 0x4C, 0xD1, 0x83, 0x0E, 0x52, 0x28)
+	frame = append(frame, 0x01, 0x09, 0x00, byte(n))
+	frame = append(append(frame, elements...), data...)
+	payload := append([]byte{0xD4, 0x42, byte(len(frame) + 1)}, frame...)
+	return append([]byte{0xFF, 0x00, 0x00, 0x00, byte(len(payload))}, payload...)
+}
+
+func TestCommandSecret(t *testing.T) {
+	key := bytes.Repeat([]byte{0xC5}, 16)
+	for _, tc := range []struct {
+		name   string
+		cmd    []byte
+		secret int
+	}{
+		{"Load Keys", []byte{0xFF, 0x82, 0x00, 0x00, 0x06, 0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}, 5},
+		{"sector trailer", append([]byte{0xFF, 0xD6, 0x00, 0x07, 0x10}, key...), 5},
+		{"4K sector trailer", append([]byte{0xFF, 0xD6, 0x00, 0x8F, 0x10}, key...), 5},
+		{"data block", append([]byte{0xFF, 0xD6, 0x00, 0x04, 0x10}, key...), -1},
+		{"NTAG215 PWD", []byte{0xFF, 0xD6, 0x00, 0x85, 0x04, 0x12, 0x34, 0x56, 0x78}, 5},
+		{"NTAG page", []byte{0xFF, 0xD6, 0x00, 0x04, 0x04, 0x12, 0x34, 0x56, 0x78}, -1},
+		{"PWD_AUTH", []byte{0xFF, 0x00, 0x00, 0x00, 0x05, 0x1B, 0x12, 0x34, 0x56, 0x78}, 6},
+		{"Ultralight C key", []byte{0xFF, 0x00, 0x00, 0x00, 0x07, 0xD4, 0x42, 0xA2, 0x2C, 0x01, 0x02, 0x03, 0x04}, 9},
+		{"DESFire ChangeKey", append([]byte{0x90, 0xC4, 0x00, 0x00, 0x11, 0x00}, key...), 5},
+		{"FeliCa card key", feliCaWrite([]byte{0x80, 0x87}, 1, key), 23},
+		{"FeliCa card key with MAC_A", feliCaWrite([]byte{0x80, 0x87, 0x80, 0x91}, 2, append(key, key...)), 25},
+		{"FeliCa card key, 3-byte element", feliCaWrite([]byte{0x00, 0x87, 0x00}, 1, key), 24},
+		{"FeliCa user block", feliCaWrite([]byte{0x80, 0x05}, 1, key), -1},
+		{"FeliCa card key version", feliCaWrite([]byte{0x80, 0x86}, 1, key), -1},
+		{"FeliCa block 0187h", feliCaWrite([]byte{0x00, 0x87, 0x01}, 1, key), -1},
+		{"FeliCa truncated block list", feliCaWrite([]byte{0x80}, 2, nil), -1},
+	} {
+		if got := commandSecret(tc.cmd); got != tc.secret {
+			t.Errorf("%s: secret from %d, want %d", tc.name, got, tc.secret)
+		}
+	}
+}
+
+func TestLogRedacted(t *testing.T) {
+	key := bytes.Repeat([]byte{0xC5}, 16)
+	var log bytes.Buffer
+	SetLogger(slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(nil)
+	card := TransportFunc(func([]byte) ([]byte, error) {
+		return []byte{0xD5, 0x43, 0x00, 0x0C, 0x09, 0x90, 0x00}, nil
+	})
+	if _, err := (loggingTransport{transport: card}).Transmit(feliCaWrite([]byte{0x80, 0x87}, 1, key)); err != nil {
+		t.Fatalf("Transmit: %v", err)
+	}
+	if strings.Contains(log.String(), "C5") || !strings.Contains(log.String(), "80 87 **") {
+		t.Fatalf("card key write logged as:\n%s", log.String())
+	}
+}
@@ -0,0 +1,95 @@
+package hardware
+
+import (
+	"sync"
+	"time"
+)
+
+// Middleware wraps a transport to add behavior to every exchange, like
+// logging, retries, rate limiting, metrics or tracing. Middlewares installed
+// with Reader.Use apply to all card modules created from the reader; modules
+// created with NewXWithTransport can be given a transport built with Chain.
+type Middleware func(next Transport) Transport
+
+// TransportFunc adapts a function to a Transport
+type TransportFunc func(cmd []byte) ([]byte, error)
+
+func (f TransportFunc) Transmit(cmd []byte) ([]byte, error) {
+	return f(cmd)
+}
+
+// Chain wraps the transport in the middlewares, the first one outermost: it
+// sees each command first and each response last
+func Chain(transport Transport, middlewares ...Middleware) Transport {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		transport = middlewares[i](transport)
+	}
+	return transport
+}
+
+// Logging logs every APDU at debug level like the reader does, with key
+// material redacted; see SetLogger
+func Logging(next Transport) Transport {
+	return loggingTransport{transport: next}
+}
+
+// Trace calls fn after every exchange with the command, the response or
+// error, and the time the exchange took
+func Trace(fn func(cmd []byte, rsp []byte, err error, duration time.Duration)) Middleware {
+	return func(next Transport) Transport {
+		return TransportFunc(func(cmd []byte) ([]byte, error) {
+			start := time.Now()
+			rsp, err := next.Transmit(cmd)
+			fn(cmd, rsp, err, time.Since(start))
+			return rsp, err
+		})
+	}
+}
+
+// Hook observes APDU exchanges, see Hooks
+type Hook interface {
+	// OnTransmit is called with each command before it is sent
+	OnTransmit(apdu []byte)
+	// OnResponse is called with the response or error of the command
+	OnResponse(rsp []byte, err error)
+}
+
+// Hooks calls the hook around every exchange. Installed with Reader.Use it
+// sees the APDUs of all card modules created from the reader, e.g. of the
+// classic, ntag and desfire packages; modules created with
+// NewXWithTransport can be given a transport built with Chain.
+func Hooks(hook Hook) Middleware {
+	return func(next Transport) Transport {
+		return TransportFunc(func(cmd []byte) ([]byte, error) {
+			hook.OnTransmit(cmd)
+			rsp, err := next.Transmit(cmd)
+			hook.OnResponse(rsp, err)
+			return rsp, err
+		})
+	}
+}
+
+// Retry sends a command again after a card reset or failed transaction, up
+// to attempts times in total, waiting delay in between. See RetryWith for
+// retrying on other transport errors and on status words.
+func Retry(attempts int, delay time.Duration) Middleware {
+	return RetryWith(RetryPolicy{Attempts: attempts, Delay: delay})
+}
+
+// RateLimit spaces the commands at least interval apart, for cards or readers
+// that fail under back-to-back commands. It is safe for concurrent use.
+func RateLimit(interval time.Duration) Middleware {
+	return func(next Transport) Transport {
+		var mu sync.Mutex
+		var last time.Time
+		return TransportFunc(func(cmd []byte) ([]byte, error) {
+			mu.Lock()
+			if wait := interval - time.Since(last); wait > 0 {
+				time.Sleep(wait)
+			}
+			last = time.Now()
+			mu.Unlock()
+			return next.Transmit(cmd)
+		})
+	}
+}
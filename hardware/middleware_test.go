@@ -0,0 +1,61 @@
+package hardware_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// recorder is a hook keeping the exchanges it sees
+type recorder struct {
+	log []string
+}
+
+func (r *recorder) OnTransmit(apdu []byte) {
+	r.log = append(r.log, fmt.Sprintf("> %X", apdu))
+}
+
+func (r *recorder) OnResponse(rsp []byte, err error) {
+	if err != nil {
+		r.log = append(r.log, fmt.Sprintf("< %v", err))
+		return
+	}
+	r.log = append(r.log, fmt.Sprintf("< %X", rsp))
+}
+
+func TestHooks(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	conn := mock.NewConnection(card, mock.NTAGATR)
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	hook := &recorder{}
+	reader.Use(hardware.Hooks(hook))
+
+	// Modules created from the reader go through the hook
+	if _, err := ntag.NewNTAG(reader).ReadPage(4); err != nil {
+		t.Fatalf("ReadPage: %v", err)
+	}
+	want := []string{"> FFB0000404", "< 0300FE009000"}
+	if fmt.Sprint(hook.log) != fmt.Sprint(want) {
+		t.Errorf("hook saw %q, want %q", hook.log, want)
+	}
+
+	hook.log = nil
+	conn.Remove()
+	if _, err := reader.Transport().Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x04}); !errors.Is(err, hardware.ErrCardRemoved) {
+		t.Fatalf("Transmit to a removed card: %v", err)
+	}
+	if len(hook.log) != 2 || !strings.Contains(hook.log[1], hardware.ErrCardRemoved.Error()) {
+		t.Errorf("hook saw %q", hook.log)
+	}
+}
@@ -0,0 +1,89 @@
+package mock
+
+import (
+	"fmt"
+
+	classicsim "github.com/oo-developer/acr122u/classic/simulator"
+	desfiresim "github.com/oo-developer/acr122u/desfire/simulator"
+	"github.com/oo-developer/acr122u/ntag"
+	ntagsim "github.com/oo-developer/acr122u/ntag/simulator"
+)
+
+// ATRs the ACR122U reports for the virtual cards
+var (
+	NTAGATR      = storageATR(0x0003)
+	Classic1KATR = storageATR(0x0001)
+	DESFireATR   = contactlessATR([]byte{0x80})
+)
+
+// NewNTAG215 returns a blank NTAG215 with a 7-byte UID, formatted for NDEF
+// with an empty message and without password protection
+func NewNTAG215(uid []byte) (*ntagsim.Simulator, error) {
+	if len(uid) != 7 {
+		return nil, fmt.Errorf("UID must be 7 bytes")
+	}
+	memory := make([]byte, ntag.NTAG215TotalPages*4)
+	copy(memory[0:3], uid[0:3])
+	memory[3] = 0x88 ^ uid[0] ^ uid[1] ^ uid[2]
+	copy(memory[4:8], uid[3:7])
+	memory[8] = uid[3] ^ uid[4] ^ uid[5] ^ uid[6]
+	memory[9] = 0x48
+	copy(memory[12:16], []byte{0xE1, 0x10, 0x3E, 0x00})
+	copy(memory[16:20], []byte{0x03, 0x00, 0xFE, 0x00})
+
+	config := 0x83 * 4
+	copy(memory[config-4:], []byte{0x00, 0x00, 0x00, 0xBD})
+	copy(memory[config:], []byte{0x04, 0x00, 0x00, 0xFF})
+	copy(memory[config+8:], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+	return ntagsim.NewSimulator(memory)
+}
+
+// NewClassic1K returns a blank MIFARE Classic 1K with a 4-byte UID, all
+// sectors in transport configuration: keys A and B FFFFFFFFFFFF, access
+// bits FF0780
+func NewClassic1K(uid []byte) (*classicsim.Simulator, error) {
+	if len(uid) != 4 {
+		return nil, fmt.Errorf("UID must be 4 bytes")
+	}
+	memory := make([]byte, 1024)
+	copy(memory, uid)
+	memory[4] = uid[0] ^ uid[1] ^ uid[2] ^ uid[3]
+	copy(memory[5:8], []byte{0x08, 0x04, 0x00})
+	for sector := 0; sector < 16; sector++ {
+		trailer := memory[sector*64+48 : sector*64+64]
+		copy(trailer, []byte{
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFF, 0x07, 0x80, 0x69,
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		})
+	}
+	return classicsim.NewSimulator(memory)
+}
+
+// NewDESFire returns an empty DESFire with a 7-byte UID and the default DES
+// PICC master key
+func NewDESFire(uid []byte) (*desfiresim.Simulator, error) {
+	if len(uid) != 7 {
+		return nil, fmt.Errorf("UID must be 7 bytes")
+	}
+	return desfiresim.NewSimulator(uid), nil
+}
+
+// storageATR builds the PC/SC Part 3 ATR of a contactless storage card
+func storageATR(name uint16) []byte {
+	return contactlessATR([]byte{
+		0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03,
+		byte(name >> 8), byte(name), 0x00, 0x00, 0x00, 0x00,
+	})
+}
+
+// contactlessATR builds the ATR PC/SC reports for contactless cards from
+// the historical bytes
+func contactlessATR(historical []byte) []byte {
+	atr := append([]byte{0x3B, 0x80 | byte(len(historical)), 0x80, 0x01}, historical...)
+	var tck byte
+	for _, b := range atr[1:] {
+		tck ^= b
+	}
+	return append(atr, tck)
+}
@@ -0,0 +1,152 @@
+package mock
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// Firmware is the version the mock reader reports
+const Firmware = "ACR122U207"
+
+// Connection is an ACR122U with a virtual card on it, to run code that
+// transmits to cards without hardware:
+//
+//	card, _ := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+//	reader := hardware.NewReaderWithConnection(mock.NewConnection(card, mock.NTAGATR))
+//	err := reader.Connect()
+//
+// The reader's own pseudo-APDUs (firmware version, LED and buzzer, PICC
+// operating parameter, buzzer on detection) and Get Data of the UID are
+// answered by the connection, the former also through Control; everything
+// else is passed to the card. It is safe for concurrent use.
+type Connection struct {
+	mu           sync.Mutex
+	card         hardware.Transport
+	atr          []byte
+	picc         hardware.PICCParameter
+	disconnected bool
+}
+
+// remover is implemented by the card simulators, which end their sessions
+// when the card is taken away
+type remover interface {
+	Remove()
+}
+
+// uidReader is implemented by the card simulators
+type uidReader interface {
+	UID() []byte
+}
+
+// NewConnection puts a card on the reader. The ATR is what Status reports,
+// see the ATRs of this package.
+func NewConnection(card hardware.Transport, atr []byte) *Connection {
+	return &Connection{
+		card: card,
+		atr:  append([]byte{}, atr...),
+		picc: hardware.PICCDefault,
+	}
+}
+
+// NewReader returns a reader on a new connection to the card
+func NewReader(card hardware.Transport, atr []byte) *hardware.Reader {
+	return hardware.NewReaderWithConnection(NewConnection(card, atr))
+}
+
+// Remove takes the card off the reader. Exchanges fail with
+// hardware.ErrCardRemoved until a card is put on with Insert.
+func (c *Connection) Remove() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.card.(remover); ok {
+		r.Remove()
+	}
+	c.card = nil
+	c.atr = nil
+}
+
+// Insert puts a card on the reader, replacing the one there
+func (c *Connection) Insert(card hardware.Transport, atr []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.card.(remover); ok {
+		r.Remove()
+	}
+	c.card = card
+	c.atr = append([]byte{}, atr...)
+}
+
+func (c *Connection) Transmit(cmd []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.disconnected {
+		return nil, hardware.ErrNotConnected
+	}
+	if rsp, ok := c.readerCommand(cmd); ok {
+		return rsp, nil
+	}
+	if c.card == nil {
+		return nil, hardware.ErrCardRemoved
+	}
+	if card, ok := c.card.(uidReader); ok && len(cmd) >= 4 && cmd[0] == 0xFF && cmd[1] == 0xCA && cmd[2] == 0x00 {
+		// Get Data is answered by the reader from the anticollision
+		return append(card.UID(), 0x90, 0x00), nil
+	}
+	return c.card.Transmit(cmd)
+}
+
+// Control answers the reader's pseudo-APDUs sent as escape commands
+func (c *Connection) Control(code uint32, data []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if code != hardware.EscapeCode {
+		return nil, errors.New("unsupported control code")
+	}
+	if rsp, ok := c.readerCommand(data); ok {
+		return rsp, nil
+	}
+	return []byte{0x63, 0x00}, nil
+}
+
+func (c *Connection) Status() (hardware.ConnectionStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.disconnected {
+		return hardware.ConnectionStatus{}, hardware.ErrNotConnected
+	}
+	if c.card == nil {
+		return hardware.ConnectionStatus{}, hardware.ErrCardRemoved
+	}
+	return hardware.ConnectionStatus{Reader: "ACS ACR122U PICC Interface (mock)", ATR: c.atr, Protocol: "T=1"}, nil
+}
+
+func (c *Connection) Disconnect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.disconnected = true
+	return nil
+}
+
+// readerCommand answers the pseudo-APDUs the reader executes itself
+func (c *Connection) readerCommand(cmd []byte) ([]byte, bool) {
+	if len(cmd) < 5 || cmd[0] != 0xFF || cmd[1] != 0x00 {
+		return nil, false
+	}
+	switch cmd[2] {
+	case 0x48:
+		return []byte(Firmware), true
+	case 0x40:
+		// LED and buzzer control answers the LED state
+		return []byte{0x90, cmd[3] & 0x03}, true
+	case 0x50:
+		return []byte{0x90, byte(c.picc)}, true
+	case 0x51:
+		c.picc = hardware.PICCParameter(cmd[3])
+		return []byte{0x90, cmd[3]}, true
+	case 0x52:
+		return []byte{0x90, 0x00}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,205 @@
+package mock_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+var uid7 = []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+func TestDetection(t *testing.T) {
+	ntagCard, err := mock.NewNTAG215(uid7)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	classicCard, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	desfireCard, err := mock.NewDESFire(uid7)
+	if err != nil {
+		t.Fatalf("NewDESFire: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		card hardware.Transport
+		atr  []byte
+		uid  []byte
+		typ  string
+	}{
+		{"NTAG215", ntagCard, mock.NTAGATR, uid7, hardware.NTAG},
+		{"Classic 1K", classicCard, mock.Classic1KATR, []byte{0x01, 0x02, 0x03, 0x04}, hardware.MIFARE_CLASSIK_1K},
+		{"DESFire", desfireCard, mock.DESFireATR, uid7, "DESFire"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := mock.NewReader(tc.card, tc.atr)
+			if err := reader.Connect(); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			info := reader.CardInfo()
+			if !strings.HasPrefix(info.Type, tc.typ) || !bytes.Equal(info.UID, tc.uid) || info.ATRMismatch {
+				t.Fatalf("detected %s % X, ATR mismatch %t", info.Type, info.UID, info.ATRMismatch)
+			}
+			if info.ParsedATR == nil {
+				t.Fatalf("ATR % X not parsed", info.ATR)
+			}
+		})
+	}
+}
+
+func TestATRMismatch(t *testing.T) {
+	classicCard, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	// The SAK says Classic 1K, the ATR says Ultralight
+	reader := mock.NewReader(classicCard, mock.NTAGATR)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	info := reader.CardInfo()
+	if !strings.HasPrefix(info.Type, hardware.MIFARE_CLASSIK_1K) || info.ATRCardName != "MIFARE Ultralight" || !info.ATRMismatch {
+		t.Fatalf("detected %s, ATR card name %q, ATR mismatch %t", info.Type, info.ATRCardName, info.ATRMismatch)
+	}
+}
+
+func TestNewCardErrors(t *testing.T) {
+	if _, err := mock.NewNTAG215(uid7[:4]); err == nil {
+		t.Errorf("NewNTAG215 with a 4-byte UID: no error")
+	}
+	if _, err := mock.NewClassic1K(uid7); err == nil {
+		t.Errorf("NewClassic1K with a 7-byte UID: no error")
+	}
+	if _, err := mock.NewDESFire(uid7[:4]); err == nil {
+		t.Errorf("NewDESFire with a 4-byte UID: no error")
+	}
+}
+
+func TestReaderCommands(t *testing.T) {
+	card, err := mock.NewNTAG215(uid7)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	conn := mock.NewConnection(card, mock.NTAGATR)
+	reader := hardware.NewReaderWithConnection(conn)
+	caps, err := reader.Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+	if caps.Firmware != mock.Firmware {
+		t.Errorf("firmware %q", caps.Firmware)
+	}
+	if err := reader.SetBuzzerOnDetect(false); err != nil {
+		t.Fatalf("SetBuzzerOnDetect: %v", err)
+	}
+	if err := reader.SetPICCParameter(hardware.PollTypeA); err != nil {
+		t.Fatalf("SetPICCParameter: %v", err)
+	}
+
+	// Escape commands reach the same reader
+	rsp, err := reader.Escape([]byte{0xFF, 0x00, 0x50, 0x00, 0x00})
+	if err != nil || !bytes.Equal(rsp, []byte{0x90, byte(hardware.PollTypeA)}) {
+		t.Fatalf("PICC parameter by escape: % X, %v", rsp, err)
+	}
+	rsp, err = reader.Escape([]byte{0xFF, 0xB0, 0x00, 0x04, 0x04})
+	if err != nil || !bytes.Equal(rsp, []byte{0x63, 0x00}) {
+		t.Fatalf("card command by escape: % X, %v", rsp, err)
+	}
+	if _, err := reader.Control(hardware.EscapeCode+1, nil); err == nil {
+		t.Fatalf("Control with another code: no error")
+	}
+}
+
+func TestCardModules(t *testing.T) {
+	ntagCard, err := mock.NewNTAG215(uid7)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	reader := mock.NewReader(ntagCard, mock.NTAGATR)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	page, err := ntag.NewNTAG(reader).ReadPage(3)
+	if err != nil {
+		t.Fatalf("ReadPage: %v", err)
+	}
+	if !bytes.Equal(page[:4], []byte{0xE1, 0x10, 0x3E, 0x00}) {
+		t.Errorf("capability container % X", page[:4])
+	}
+
+	classicCard, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	c := classic.NewClassicWithTransport(mock.NewConnection(classicCard, mock.Classic1KATR))
+	if err := c.LoadKey(0, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}); err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	if err := c.Authenticate(4, classic.KeyTypeA, 0); err != nil {
+		t.Fatalf("Authenticate with the transport key: %v", err)
+	}
+	if _, err := c.ReadBlock(4); err != nil {
+		t.Fatalf("ReadBlock: %v", err)
+	}
+
+	desfireCard, err := mock.NewDESFire(uid7)
+	if err != nil {
+		t.Fatalf("NewDESFire: %v", err)
+	}
+	df := desfire.NewDESFireWithTransport(mock.NewConnection(desfireCard, mock.DESFireATR))
+	if err := df.Authenticate(0, desfire.KeyTypeDES, make([]byte, 8)); err != nil {
+		t.Fatalf("Authenticate with the default key: %v", err)
+	}
+	if aids, err := df.GetApplicationIDs(); err != nil || len(aids) != 0 {
+		t.Fatalf("GetApplicationIDs of an empty card: %v, %v", aids, err)
+	}
+}
+
+func TestRemoveInsert(t *testing.T) {
+	first, err := mock.NewNTAG215(uid7)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	conn := mock.NewConnection(first, mock.NTAGATR)
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	conn.Remove()
+	if _, err := conn.Status(); !errors.Is(err, hardware.ErrCardRemoved) {
+		t.Fatalf("Status without card: %v", err)
+	}
+	if _, err := reader.Transport().Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x04}); !errors.Is(err, hardware.ErrCardRemoved) {
+		t.Fatalf("Transmit without card: %v", err)
+	}
+	// The reader answers without a card
+	if _, err := reader.FirmwareVersion(); err != nil {
+		t.Fatalf("FirmwareVersion without card: %v", err)
+	}
+
+	second, err := mock.NewClassic1K([]byte{0x0A, 0x0B, 0x0C, 0x0D})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	conn.Insert(second, mock.Classic1KATR)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect to the inserted card: %v", err)
+	}
+	if info := reader.CardInfo(); !strings.HasPrefix(info.Type, hardware.MIFARE_CLASSIK_1K) || !bytes.Equal(info.UID, []byte{0x0A, 0x0B, 0x0C, 0x0D}) {
+		t.Fatalf("inserted card: %s % X", info.Type, info.UID)
+	}
+
+	reader.Disconnect()
+	if _, err := conn.Transmit([]byte{0xFF, 0x00, 0x48, 0x00, 0x00}); !errors.Is(err, hardware.ErrNotConnected) {
+		t.Fatalf("Transmit after Disconnect: %v", err)
+	}
+}
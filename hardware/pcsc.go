@@ -0,0 +1,50 @@
+package hardware
+
+import "github.com/ebfe/scard"
+
+// pcscConnection is a connection made through PC/SC
+type pcscConnection struct {
+	card *scard.Card
+}
+
+func (c pcscConnection) Transmit(cmd []byte) ([]byte, error) {
+	return c.card.Transmit(cmd)
+}
+
+func (c pcscConnection) Control(code uint32, data []byte) ([]byte, error) {
+	return c.card.Control(code, data)
+}
+
+func (c pcscConnection) Status() (ConnectionStatus, error) {
+	status, err := c.card.Status()
+	if err != nil {
+		return ConnectionStatus{}, err
+	}
+	s := ConnectionStatus{Reader: status.Reader, ATR: status.Atr}
+	switch status.ActiveProtocol {
+	case scard.ProtocolT0:
+		s.Protocol = "T=0"
+	case scard.ProtocolT1:
+		s.Protocol = "T=1"
+	}
+	return s, nil
+}
+
+func (c pcscConnection) Disconnect() error {
+	return c.card.Disconnect(scard.LeaveCard)
+}
+
+func (c pcscConnection) BeginTransaction() error {
+	return c.card.BeginTransaction()
+}
+
+func (c pcscConnection) EndTransaction() error {
+	return c.card.EndTransaction(scard.LeaveCard)
+}
+
+// transactor is implemented by connections with transactions against other
+// processes
+type transactor interface {
+	BeginTransaction() error
+	EndTransaction() error
+}
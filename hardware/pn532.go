@@ -0,0 +1,127 @@
+package hardware
+
+import "fmt"
+
+// PN532 commands for PN532Transmit
+const (
+	PN532GetFirmwareVersion = 0x02
+	PN532ReadRegister       = 0x06
+	PN532WriteRegister      = 0x08
+	PN532RFConfiguration    = 0x32
+	PN532InDataExchange     = 0x40
+	PN532InCommunicateThru  = 0x42
+	PN532InDeselect         = 0x44
+	PN532InListPassiveTgt   = 0x4A
+	PN532InRelease          = 0x52
+	PN532InSelect           = 0x54
+)
+
+// maxPN532Frame is the longest frame the direct transmit envelope carries,
+// the D4 host byte included
+const maxPN532Frame = 0xFF
+
+// PN532Error is the error status a PN532 returns for InDataExchange and
+// InCommunicateThru
+type PN532Error byte
+
+var pn532Errors = map[PN532Error]string{
+	0x01: "timeout",
+	0x02: "CRC error",
+	0x03: "parity error",
+	0x04: "wrong bit count during anti-collision",
+	0x05: "framing error",
+	0x06: "abnormal bit collision",
+	0x07: "buffer size insufficient",
+	0x09: "RF buffer overflow",
+	0x0A: "RF field not switched on in time",
+	0x0B: "RF protocol error",
+	0x0D: "temperature error",
+	0x0E: "internal buffer overflow",
+	0x10: "invalid parameter",
+	0x14: "authentication error",
+	0x27: "command not acceptable in current context",
+	0x29: "target released by initiator",
+	0x2A: "card ID mismatch",
+	0x2B: "card disappeared",
+}
+
+// Is matches ErrAuthFailed for refused MIFARE authentications and
+// ErrCardRemoved for cards that left the field
+func (e PN532Error) Is(target error) bool {
+	return target == ErrAuthFailed && e == 0x14 || target == ErrCardRemoved && e == 0x2B
+}
+
+func (e PN532Error) Error() string {
+	if s, ok := pn532Errors[e]; ok {
+		return fmt.Sprintf("PN532 error %02X: %s", byte(e), s)
+	}
+	return fmt.Sprintf("PN532 error %02X", byte(e))
+}
+
+// PN532Transmit sends a PN532 command frame, the command code followed by
+// its parameters, in the direct transmit envelope FF 00 00 00 of the
+// ACR122U and returns the response behind the D5 reply code. This reaches
+// card commands the reader's APDU layer does not pass, e.g. FAST_READ or
+// READ_SIG through InCommunicateThru.
+func PN532Transmit(t Transport, frame []byte) ([]byte, error) {
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("empty PN532 frame")
+	}
+	if len(frame)+1 > maxPN532Frame {
+		return nil, fmt.Errorf("PN532 frame too long: %d bytes", len(frame))
+	}
+	cmd := append([]byte{0xFF, 0x00, 0x00, 0x00, byte(len(frame) + 1), 0xD4}, frame...)
+	rsp, err := t.Transmit(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("direct transmit failed: %w", err)
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+		return nil, fmt.Errorf("direct transmit failed: %w", NewStatusError(cmd, rsp))
+	}
+	rsp = rsp[:len(rsp)-2]
+	if len(rsp) < 2 || rsp[0] != 0xD5 || rsp[1] != frame[0]+1 {
+		return nil, fmt.Errorf("invalid PN532 response % X", rsp)
+	}
+	return rsp[2:], nil
+}
+
+// CommunicateThru sends a raw frame to the card with PN532
+// InCommunicateThru and returns its answer; the PN532 adds and checks the
+// CRC. A status other than success is returned as PN532Error.
+func CommunicateThru(t Transport, data []byte) ([]byte, error) {
+	return pn532Exchange(t, append([]byte{PN532InCommunicateThru}, data...))
+}
+
+// DataExchange sends data to a target activated by the PN532, 1 for the
+// card the reader selected, with InDataExchange, which handles ISO 14443-4
+// framing, and returns the answer of the card. A status other than success
+// is returned as PN532Error.
+func DataExchange(t Transport, target byte, data []byte) ([]byte, error) {
+	return pn532Exchange(t, append([]byte{PN532InDataExchange, target}, data...))
+}
+
+func pn532Exchange(t Transport, frame []byte) ([]byte, error) {
+	rsp, err := PN532Transmit(t, frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) == 0 {
+		return nil, fmt.Errorf("invalid PN532 response: no status")
+	}
+	if status := rsp[0] & 0x3F; status != 0 {
+		return nil, PN532Error(status)
+	}
+	return rsp[1:], nil
+}
+
+// PN532Transmit sends a PN532 command frame through the card connection,
+// see PN532Transmit
+func (m *Reader) PN532Transmit(frame []byte) ([]byte, error) {
+	return PN532Transmit(m.Transport(), frame)
+}
+
+// CommunicateThru sends a raw frame to the card through the card
+// connection, see CommunicateThru
+func (m *Reader) CommunicateThru(data []byte) ([]byte, error) {
+	return CommunicateThru(m.Transport(), data)
+}
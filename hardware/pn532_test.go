@@ -0,0 +1,98 @@
+package hardware_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// pn532 is a transport answering every command with rsp, keeping the last
+// command sent
+type pn532 struct {
+	sent []byte
+	rsp  []byte
+}
+
+func (p *pn532) Transmit(cmd []byte) ([]byte, error) {
+	p.sent = append([]byte{}, cmd...)
+	return p.rsp, nil
+}
+
+func TestCommunicateThru(t *testing.T) {
+	p := &pn532{rsp: []byte{0xD5, 0x43, 0x00, 0x01, 0x02, 0x03, 0x04, 0x90, 0x00}}
+	rsp, err := hardware.CommunicateThru(p, []byte{0x3A, 0x00, 0x00})
+	if err != nil {
+		t.Fatalf("CommunicateThru: %v", err)
+	}
+	if !bytes.Equal(rsp, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Errorf("response % X", rsp)
+	}
+	if want := []byte{0xFF, 0x00, 0x00, 0x00, 0x05, 0xD4, 0x42, 0x3A, 0x00, 0x00}; !bytes.Equal(p.sent, want) {
+		t.Errorf("command % X, want % X", p.sent, want)
+	}
+
+	p.rsp = []byte{0xD5, 0x43, 0x01, 0x90, 0x00}
+	_, err = hardware.CommunicateThru(p, []byte{0x3A, 0x00, 0x00})
+	var pnErr hardware.PN532Error
+	if !errors.As(err, &pnErr) || pnErr != 0x01 || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("CommunicateThru timing out: %v", err)
+	}
+}
+
+func TestDataExchange(t *testing.T) {
+	p := &pn532{rsp: []byte{0xD5, 0x41, 0x00, 0x09, 0x90, 0x00}}
+	rsp, err := hardware.DataExchange(p, 1, []byte{0x60})
+	if err != nil {
+		t.Fatalf("DataExchange: %v", err)
+	}
+	if !bytes.Equal(rsp, []byte{0x09}) {
+		t.Errorf("response % X", rsp)
+	}
+	if want := []byte{0xFF, 0x00, 0x00, 0x00, 0x04, 0xD4, 0x40, 0x01, 0x60}; !bytes.Equal(p.sent, want) {
+		t.Errorf("command % X, want % X", p.sent, want)
+	}
+
+	// The upper bits of the status flag chaining, not errors
+	p.rsp = []byte{0xD5, 0x41, 0x40, 0x09, 0x90, 0x00}
+	if _, err := hardware.DataExchange(p, 1, []byte{0x60}); err != nil {
+		t.Fatalf("DataExchange with status 40: %v", err)
+	}
+	p.rsp = []byte{0xD5, 0x41, 0x14, 0x90, 0x00}
+	var status hardware.PN532Error
+	if _, err := hardware.DataExchange(p, 1, []byte{0x60}); !errors.As(err, &status) || status != 0x14 {
+		t.Fatalf("DataExchange refused: %v", err)
+	}
+}
+
+func TestPN532Transmit(t *testing.T) {
+	p := &pn532{rsp: []byte{0xD5, 0x03, 0x32, 0x01, 0x06, 0x07, 0x90, 0x00}}
+	rsp, err := hardware.PN532Transmit(p, []byte{hardware.PN532GetFirmwareVersion})
+	if err != nil {
+		t.Fatalf("PN532Transmit: %v", err)
+	}
+	if !bytes.Equal(rsp, []byte{0x32, 0x01, 0x06, 0x07}) {
+		t.Errorf("firmware version % X", rsp)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		frame []byte
+		rsp   []byte
+	}{
+		{"empty frame", nil, p.rsp},
+		{"frame too long", make([]byte, 0xFF), p.rsp},
+		{"refused", []byte{hardware.PN532GetFirmwareVersion}, []byte{0x63, 0x00}},
+		{"wrong reply code", []byte{hardware.PN532GetFirmwareVersion}, []byte{0xD5, 0x09, 0x90, 0x00}},
+		{"no reply code", []byte{hardware.PN532GetFirmwareVersion}, []byte{0x90, 0x00}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &pn532{rsp: tc.rsp}
+			if _, err := hardware.PN532Transmit(p, tc.frame); err == nil {
+				t.Fatalf("no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package hardware
+
+import "context"
+
+// Progress receives the work done so far and the total of a long operation,
+// like a dump, key probing or personalization. The unit is documented by the
+// operation, e.g. bytes or keys tried. It is called on the goroutine running
+// the operation.
+//
+// Long operations take a context and a Progress, either of which may be
+// unused: FooContext(ctx, ..., progress) next to a plain Foo(...). Cancelling
+// the context stops the operation between two card commands with the
+// context's error.
+type Progress func(done int, total int)
+
+// Tracker follows a long operation for its progress callback and context. A
+// nil Tracker neither reports nor cancels, so helpers shared with plain
+// operations can take one.
+type Tracker struct {
+	ctx      context.Context
+	progress Progress
+	done     int
+	total    int
+}
+
+// NewTracker tracks an operation; progress may be nil
+func NewTracker(ctx context.Context, progress Progress) *Tracker {
+	return &Tracker{ctx: ctx, progress: progress}
+}
+
+// Begin sets the total of the operation and reports that nothing is done
+func (t *Tracker) Begin(total int) {
+	if t == nil {
+		return
+	}
+	t.done, t.total = 0, total
+	if t.progress != nil {
+		t.progress(0, total)
+	}
+}
+
+// Next returns the context error once the operation is cancelled. Call it
+// before each step.
+func (t *Tracker) Next() error {
+	if t == nil {
+		return nil
+	}
+	return t.ctx.Err()
+}
+
+// Advance reports n more units done
+func (t *Tracker) Advance(n int) {
+	if t == nil {
+		return
+	}
+	t.done = min(t.done+n, t.total)
+	if t.progress != nil {
+		t.progress(t.done, t.total)
+	}
+}
@@ -0,0 +1,102 @@
+package hardware_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware/mock"
+)
+
+func TestReaderConcurrent(t *testing.T) {
+	uid := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	card, err := mock.NewNTAG215(uid)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	reader := mock.NewReader(card, mock.NTAGATR)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	want := reader.CardInfo().Type
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := reader.Connect(); err != nil {
+					t.Errorf("Connect: %v", err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			transport := reader.Transport()
+			for j := 0; j < 50; j++ {
+				rsp, err := transport.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+				if err != nil || !bytes.Equal(rsp[:len(rsp)-2], uid) {
+					t.Errorf("Get Data: % X, %v", rsp, err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				info := reader.CardInfo()
+				if info.Type != want {
+					t.Errorf("card type %q", info.Type)
+				}
+				info.UID = nil
+			}
+		}()
+	}
+	wg.Wait()
+
+	if info := reader.CardInfo(); info.Type != want || !bytes.Equal(info.UID, uid) {
+		t.Fatalf("CardInfo: %s % X", info.Type, info.UID)
+	}
+}
+
+// TestReaderAccessors reads the reader fields while another goroutine
+// selects readers and connects, for the race detector
+func TestReaderAccessors(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	reader := mock.NewReader(card, mock.NTAGATR)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 20; j++ {
+			reader.UseReader("ACS ACR122U")
+			if err := reader.Connect(); err != nil {
+				t.Errorf("Connect: %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 20; j++ {
+			if name := reader.Reader(); name != "" && name != "ACS ACR122U" {
+				t.Errorf("reader %q", name)
+			}
+			if reader.Card() != nil || reader.Ctx() != nil {
+				t.Errorf("PC/SC handles on a mock connection")
+			}
+			if _, err := reader.ListReaders(); err == nil {
+				t.Errorf("ListReaders without PC/SC succeeded")
+			}
+			for range reader.Events(ctx) {
+			}
+		}
+	}()
+	wg.Wait()
+}
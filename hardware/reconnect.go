@@ -0,0 +1,153 @@
+package hardware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// ReconnectPolicy makes the reader reconnect to the card when PC/SC reports
+// that the card was reset or a transaction failed, and send the failed
+// command again. State on the card, such as an authentication, is lost with
+// the reset, so the command may still fail.
+type ReconnectPolicy struct {
+	Attempts int           // reconnect attempts, no reconnecting if zero
+	Delay    time.Duration // before the second attempt, doubling after
+	MaxDelay time.Duration // delay limit, none if zero
+}
+
+// SetReconnectPolicy sets how the reader reconnects on card resets, see
+// ReconnectPolicy. Reconnect uses the attempts and delays as well.
+func (m *Reader) SetReconnectPolicy(policy ReconnectPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconnect = policy
+}
+
+// Reconnect re-establishes the connection to the card, e.g. after it was
+// reset, and detects the card again. It tries as often as the reconnect
+// policy allows, at least once.
+func (m *Reader) Reconnect() error {
+	return m.ReconnectContext(context.Background())
+}
+
+// ReconnectContext reconnects like Reconnect. It stops when ctx ends and
+// returns its cause, see context.Cause.
+func (m *Reader) ReconnectContext(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reconnectContext(ctx)
+}
+
+// reconnectContext reconnects with the reader's lock held
+func (m *Reader) reconnectContext(ctx context.Context) error {
+	if m.card == nil {
+		return ErrNotConnected
+	}
+	delay := m.reconnect.Delay
+	for attempt := 1; ; attempt++ {
+		err := m.reconnectOnce(ctx)
+		if err == nil || ctx.Err() != nil || attempt >= m.reconnect.Attempts {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return err
+		}
+		Logger().Debug("reconnect failed", "reader", m.reader, "attempt", attempt, "error", err)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		delay *= 2
+		if m.reconnect.MaxDelay > 0 {
+			delay = min(delay, m.reconnect.MaxDelay)
+		}
+	}
+}
+
+// reconnectOnce reconnects the card handle, or connects anew if the handle
+// is gone, and replays the card detection
+func (m *Reader) reconnectOnce(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, m.timeouts.Connect)
+	defer cancel()
+	prev := m.opCtx
+	m.reconnecting = true
+	m.opCtx = ctx
+	defer func() {
+		m.reconnecting = false
+		m.opCtx = prev
+	}()
+	if err := m.settle(ctx); err != nil {
+		return err
+	}
+
+	// Other backends only detect the card again
+	if c, ok := m.card.(pcscConnection); ok {
+		share, protocol := m.shareMode()
+		if err := c.card.Reconnect(share, protocol, scard.LeaveCard); err != nil {
+			c.card.Disconnect(scard.LeaveCard)
+			card, err := m.ctx.Connect(m.reader, share, protocol)
+			if err != nil {
+				return fmt.Errorf("failed to reconnect: %w", err)
+			}
+			m.card = pcscConnection{card: card}
+		}
+	}
+	*m.cardInfo = CardInfo{}
+	if m.direct() {
+		return nil
+	}
+	uid, err := m.getUID()
+	if err != nil {
+		return err
+	}
+	m.cardInfo.UID = uid
+	if err := m.detectCardType(); err != nil {
+		return err
+	}
+	Logger().Info("card reconnected", "reader", m.reader, "uid", fmt.Sprintf("%X", uid), "type", m.cardInfo.Type)
+	return nil
+}
+
+// cardReset reports whether a PC/SC error means the connection to the card
+// must be re-established
+func cardReset(err error) bool {
+	return errors.Is(err, scard.ErrResetCard) || errors.Is(err, scard.ErrNotTransacted)
+}
+
+// cardTransport sends APDUs over the current card connection of a reader,
+// reconnecting on card resets as the reconnect policy allows. Errors of a
+// removed card match ErrCardRemoved.
+type cardTransport struct {
+	reader *Reader
+}
+
+func (t cardTransport) Transmit(cmd []byte) ([]byte, error) {
+	m := t.reader
+	if m.card == nil {
+		return nil, ErrNotConnected
+	}
+	rsp, err := m.exchange(cmd)
+	if err == nil || m.reconnect.Attempts == 0 || m.reconnecting || !cardReset(err) {
+		return rsp, cardError(err)
+	}
+	Logger().Warn("card reset, reconnecting", "reader", m.reader, "error", err)
+	// Reconnect within the operation sending the command, e.g. the
+	// deadline of TransmitContext
+	if rerr := m.reconnectContext(m.operation()); rerr != nil {
+		return nil, fmt.Errorf("%w, then %w", err, rerr)
+	}
+	rsp, err = m.exchange(cmd)
+	return rsp, cardError(err)
+}
+
+// cardError makes PC/SC errors of a card gone from the reader match
+// ErrCardRemoved
+func cardError(err error) error {
+	if errors.Is(err, scard.ErrRemovedCard) || errors.Is(err, scard.ErrNoSmartcard) {
+		return fmt.Errorf("%w: %w", ErrCardRemoved, err)
+	}
+	return err
+}
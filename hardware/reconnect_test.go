@@ -0,0 +1,152 @@
+package hardware_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+)
+
+// resetting is a connection whose card is reset as often as told: each
+// exchange fails with a reset card until resets is used up
+type resetting struct {
+	*mock.Connection
+	resets int
+	uids   int
+}
+
+func (c *resetting) Transmit(cmd []byte) ([]byte, error) {
+	if cmd[0] == 0xFF && cmd[1] == 0xCA {
+		c.uids++
+	}
+	if c.resets > 0 {
+		c.resets--
+		return nil, scard.ErrResetCard
+	}
+	return c.Connection.Transmit(cmd)
+}
+
+func TestReconnect(t *testing.T) {
+	uid := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	card, err := mock.NewNTAG215(uid)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	conn := &resetting{Connection: mock.NewConnection(card, mock.NTAGATR)}
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	transport := reader.Transport()
+	read := []byte{0xFF, 0xB0, 0x00, 0x04, 0x04}
+
+	conn.resets = 1
+	if _, err := transport.Transmit(read); !errors.Is(err, scard.ErrResetCard) {
+		t.Fatalf("reset without reconnect policy: %v", err)
+	}
+
+	reader.SetReconnectPolicy(hardware.ReconnectPolicy{Attempts: 3, Delay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+	for _, tc := range []struct {
+		name   string
+		resets int
+		uids   int
+	}{
+		{"reset once", 1, 1},
+		{"reset on detection", 3, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conn.resets, conn.uids = tc.resets, 0
+			rsp, err := transport.Transmit(read)
+			if err != nil || len(rsp) != 6 {
+				t.Fatalf("read: % X, %v", rsp, err)
+			}
+			if conn.uids != tc.uids {
+				t.Errorf("card detected %d times, want %d", conn.uids, tc.uids)
+			}
+			if info := reader.CardInfo(); !bytes.Equal(info.UID, uid) || info.Type == "" {
+				t.Errorf("card after reconnect: %s % X", info.Type, info.UID)
+			}
+		})
+	}
+
+	conn.resets = 10
+	if _, err := transport.Transmit(read); !errors.Is(err, scard.ErrResetCard) {
+		t.Fatalf("reset beyond the attempts: %v", err)
+	}
+	conn.resets = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := reader.ReconnectContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReconnectContext with ended context: %v", err)
+	}
+
+	// Cancelled with a cause while waiting for the next attempt
+	reader.SetReconnectPolicy(hardware.ReconnectPolicy{Attempts: 3, Delay: time.Minute})
+	conn.resets = 100
+	errStop := errors.New("stop")
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	time.AfterFunc(20*time.Millisecond, func() { cancelCause(errStop) })
+	start := time.Now()
+	if err := reader.ReconnectContext(ctx); !errors.Is(err, errStop) {
+		t.Fatalf("ReconnectContext cancelled with a cause: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("ReconnectContext returned after %v", elapsed)
+	}
+	conn.resets = 0
+	if err := reader.Reconnect(); err != nil {
+		t.Fatalf("Reconnect: %v", err)
+	}
+	if err := hardware.NewReaderWithConnection(nil).Reconnect(); !errors.Is(err, hardware.ErrNotConnected) {
+		t.Fatalf("Reconnect without connection: %v", err)
+	}
+}
+
+// resetStalling is a connection whose card is reset once, after which
+// reads stall until released
+type resetStalling struct {
+	*stalling
+	reset bool
+}
+
+func (c *resetStalling) Transmit(cmd []byte) ([]byte, error) {
+	if c.reset {
+		c.reset = false
+		c.stalled.Store(true)
+		return nil, scard.ErrResetCard
+	}
+	return c.stalling.Transmit(cmd)
+}
+
+func TestReconnectWithinDeadline(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	conn := &resetStalling{stalling: &stalling{Connection: mock.NewConnection(card, mock.NTAGATR), release: make(chan struct{})}}
+	defer close(conn.release)
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	reader.SetReconnectPolicy(hardware.ReconnectPolicy{Attempts: 3, Delay: time.Millisecond})
+
+	// The card is reset, and the card detection of the reconnect stalls
+	conn.reset = true
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = reader.TransmitContext(ctx, []byte{0xFF, 0xB0, 0x00, 0x04, 0x04})
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, scard.ErrResetCard) {
+		t.Fatalf("TransmitContext: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("TransmitContext returned after %v", elapsed)
+	}
+}
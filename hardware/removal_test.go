@@ -0,0 +1,65 @@
+package hardware
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestCardGone(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		err   error
+		event scard.StateFlag
+		gone  bool
+		state scard.StateFlag
+	}{
+		{"present", nil, scard.StatePresent | scard.StateChanged, false, scard.StatePresent},
+		{"in use", nil, scard.StatePresent | scard.StateInuse | scard.StateChanged, false, scard.StatePresent | scard.StateInuse},
+		{"removed", nil, scard.StateEmpty | scard.StateChanged, true, scard.StateEmpty},
+		{"reader unplugged", nil, scard.StateUnavailable | scard.StateChanged, true, scard.StateUnaware},
+		{"reader gone", scard.ErrUnknownReader, 0, true, scard.StateUnaware},
+		{"service stopped", scard.ErrServiceStopped, 0, true, scard.StateUnaware},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gone, state, err := cardGone(tc.err, tc.event)
+			if err != nil {
+				t.Fatalf("cardGone: %v", err)
+			}
+			if gone != tc.gone || state != tc.state {
+				t.Fatalf("gone %t, state %X, want %t, %X", gone, state, tc.gone, tc.state)
+			}
+		})
+	}
+	if _, _, err := cardGone(scard.ErrCommError, 0); !errors.Is(err, scard.ErrCommError) {
+		t.Fatalf("cardGone with a PC/SC error: %v", err)
+	}
+}
+
+func TestCardError(t *testing.T) {
+	for _, tc := range []struct {
+		err     error
+		removed bool
+	}{
+		{scard.ErrRemovedCard, true},
+		{scard.ErrNoSmartcard, true},
+		{scard.ErrResetCard, false},
+		{scard.ErrCommError, false},
+	} {
+		err := cardError(tc.err)
+		if errors.Is(err, ErrCardRemoved) != tc.removed || !errors.Is(err, tc.err) {
+			t.Errorf("cardError(%v) = %v", tc.err, err)
+		}
+	}
+	if cardError(nil) != nil {
+		t.Errorf("cardError(nil) is not nil")
+	}
+}
+
+func TestWaitForCardRemovalWithoutPCSC(t *testing.T) {
+	m := NewReaderWithConnection(nil)
+	if err := m.WaitForCardRemoval(); !errors.Is(err, errNoPCSC) {
+		t.Fatalf("WaitForCardRemoval: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package hardware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// RetryPolicy sends a command again after failures that are likely to pass
+// on the next try, like the 63 00 of a tag coupling poorly on a metal
+// surface. A lost response may mean the card did execute the command, and
+// a refused authentication counts against limits like AUTHLIM of NTAG, so
+// only retry what is safe to repeat.
+type RetryPolicy struct {
+	Attempts int           // attempts in total, no retrying below 2
+	Delay    time.Duration // between attempts
+	// StatusWords lists the responses to retry, e.g. 0x6300
+	StatusWords []uint16
+	// Errors lists the transport errors to retry, matched with errors.Is,
+	// e.g. scard.ErrCommError. If empty, only a reset card and a failed
+	// transaction are retried, scard.ErrResetCard and scard.ErrNotTransacted,
+	// after which the card did not execute the command.
+	Errors []error
+}
+
+// retries reports whether the policy retries the response or error
+func (p RetryPolicy) retries(rsp []byte, err error) bool {
+	if err != nil {
+		if len(p.Errors) == 0 {
+			return cardReset(err)
+		}
+		return slices.ContainsFunc(p.Errors, func(target error) bool {
+			return errors.Is(err, target)
+		})
+	}
+	if len(rsp) < 2 {
+		return false
+	}
+	return slices.Contains(p.StatusWords, uint16(rsp[len(rsp)-2])<<8|uint16(rsp[len(rsp)-1]))
+}
+
+// RetryWith retries commands as the policy says. The last response is
+// returned when all attempts fail.
+func RetryWith(policy RetryPolicy) Middleware {
+	return retryWith(policy, context.Background)
+}
+
+// retryWith retries like RetryWith. It stops waiting for the next attempt
+// once the context of the command ends and returns the error of the context.
+func retryWith(policy RetryPolicy, ctx func() context.Context) Middleware {
+	return func(next Transport) Transport {
+		return TransportFunc(func(cmd []byte) ([]byte, error) {
+			var rsp []byte
+			var err error
+			for attempt := 1; ; attempt++ {
+				rsp, err = next.Transmit(cmd)
+				if attempt >= policy.Attempts || !policy.retries(rsp, err) {
+					if err != nil && attempt > 1 {
+						return nil, fmt.Errorf("%w (after %d attempts)", err, attempt)
+					}
+					return rsp, err
+				}
+				Logger().Debug("retrying command", "attempt", attempt, "sw", statusWord(rsp), "error", err)
+				if err := sleep(ctx(), policy.Delay); err != nil {
+					return nil, err
+				}
+			}
+		})
+	}
+}
+
+// sleep waits for the delay, or returns the error of ctx once it ends
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// SetRetryPolicy makes the reader retry commands of all card modules
+// created from it afterwards, and of the card detection, as the policy says.
+// Retries happen inside the middlewares added with Use and keep the reader's
+// lock. The wait between attempts ends with the operation, e.g. the context
+// of TransmitContext.
+func (m *Reader) SetRetryPolicy(policy RetryPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retry = policy
+}
+
+// statusWord formats the status word of a response for logging
+func statusWord(rsp []byte) string {
+	if len(rsp) < 2 {
+		return ""
+	}
+	return fmt.Sprintf("%02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+}
@@ -0,0 +1,152 @@
+package hardware_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	ntagsim "github.com/oo-developer/acr122u/ntag/simulator"
+)
+
+// flaky is an NTAG answering reads with 63 00 as often as told
+type flaky struct {
+	*ntagsim.Simulator
+	fails int
+	reads int
+}
+
+func (f *flaky) Transmit(cmd []byte) ([]byte, error) {
+	if len(cmd) >= 2 && cmd[0] == 0xFF && cmd[1] == 0xB0 {
+		f.reads++
+		if f.reads <= f.fails {
+			return []byte{0x63, 0x00}, nil
+		}
+	}
+	return f.Simulator.Transmit(cmd)
+}
+
+func TestRetryStatusWords(t *testing.T) {
+	sim, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	card := &flaky{Simulator: sim}
+	reader := mock.NewReader(card, mock.NTAGATR)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	reader.SetRetryPolicy(hardware.RetryPolicy{Attempts: 3, StatusWords: []uint16{0x6300}})
+	read := []byte{0xFF, 0xB0, 0x00, 0x04, 0x04}
+
+	card.reads, card.fails = 0, 2
+	rsp, err := reader.Transport().Transmit(read)
+	if err != nil || len(rsp) != 6 || card.reads != 3 {
+		t.Fatalf("read after 2 failures: % X, %v, %d reads", rsp, err, card.reads)
+	}
+	card.reads, card.fails = 0, 5
+	rsp, err = reader.Transport().Transmit(read)
+	if err != nil || len(rsp) != 2 || rsp[0] != 0x63 || card.reads != 3 {
+		t.Fatalf("read after 5 failures: % X, %v, %d reads", rsp, err, card.reads)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	sim, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	card := &flaky{Simulator: sim}
+	reader := mock.NewReader(card, mock.NTAGATR)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	reader.SetRetryPolicy(hardware.RetryPolicy{Attempts: 3, Delay: time.Hour, StatusWords: []uint16{0x6300}})
+	read := []byte{0xFF, 0xB0, 0x00, 0x04, 0x04}
+
+	// The wait for the next attempt ends with the context
+	card.reads, card.fails = 0, 5
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if rsp, err := reader.TransmitContext(ctx, read); !errors.Is(err, context.DeadlineExceeded) || card.reads != 1 {
+		t.Fatalf("TransmitContext: % X, %v after %d reads", rsp, err, card.reads)
+	}
+	// and leaves the reader to the next command
+	card.reads, card.fails = 0, 0
+	if rsp, err := reader.Transport().Transmit(read); err != nil || len(rsp) != 6 {
+		t.Fatalf("read after the cancelled retry: % X, %v", rsp, err)
+	}
+}
+
+func TestRetryErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   hardware.RetryPolicy
+		err      error
+		attempts int
+	}{
+		{"default reset", hardware.RetryPolicy{Attempts: 4}, scard.ErrResetCard, 4},
+		{"default transaction", hardware.RetryPolicy{Attempts: 4}, scard.ErrNotTransacted, 4},
+		{"default other", hardware.RetryPolicy{Attempts: 4}, scard.ErrCommError, 1},
+		{"listed", hardware.RetryPolicy{Attempts: 4, Errors: []error{scard.ErrCommError}}, scard.ErrCommError, 4},
+		{"not listed", hardware.RetryPolicy{Attempts: 4, Errors: []error{scard.ErrCommError}}, scard.ErrResetCard, 1},
+		{"single attempt", hardware.RetryPolicy{Attempts: 1}, scard.ErrResetCard, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n := 0
+			transport := hardware.Chain(hardware.TransportFunc(func([]byte) ([]byte, error) {
+				n++
+				return nil, tc.err
+			}), hardware.RetryWith(tc.policy))
+			if _, err := transport.Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x04}); !errors.Is(err, tc.err) {
+				t.Fatalf("Transmit: %v", err)
+			}
+			if n != tc.attempts {
+				t.Fatalf("%d attempts, want %d", n, tc.attempts)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	n := 0
+	transport := hardware.Chain(hardware.TransportFunc(func([]byte) ([]byte, error) {
+		n++
+		if n < 3 {
+			return nil, scard.ErrResetCard
+		}
+		return []byte{0x90, 0x00}, nil
+	}), hardware.Retry(3, 0))
+	if rsp, err := transport.Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x04}); err != nil || n != 3 {
+		t.Fatalf("Transmit: % X, %v after %d attempts", rsp, err, n)
+	}
+}
+
+func TestRetryDefaultErrors(t *testing.T) {
+	// Without listed errors only those after which the card did not execute
+	// the command are retried
+	for _, err := range []error{
+		scard.ErrResetCard,
+		scard.ErrNotTransacted,
+		scard.ErrCommError,
+		scard.ErrRemovedCard,
+		scard.ErrNoSmartcard,
+		scard.ErrTimeout,
+		scard.ErrUnpoweredCard,
+		errors.New("backend failure"),
+	} {
+		n := 0
+		transport := hardware.Chain(hardware.TransportFunc(func([]byte) ([]byte, error) {
+			n++
+			return nil, err
+		}), hardware.RetryWith(hardware.RetryPolicy{Attempts: 3}))
+		transport.Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x04})
+		retried := errors.Is(err, scard.ErrResetCard) || errors.Is(err, scard.ErrNotTransacted)
+		if retried && n != 3 || !retried && n != 1 {
+			t.Errorf("%v: %d attempts", err, n)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Timeouts bound the reader's operations so that a stuck card or reader
+// cannot hang them. Zero means no timeout. Operations that ran out of time
+// return an error matching ErrTimeout. Long operations of the card modules,
+// such as dumps, are bounded as a whole with their Context variants.
+type Timeouts struct {
+	// Wait bounds WaitForCard and WaitForCardRemoval
+	Wait time.Duration
+	// Connect bounds Connect and each reconnect attempt, including the card
+	// detection
+	Connect time.Duration
+	// Exchange bounds each exchange with the card. The card may still
+	// execute a command that timed out, so the connection is given up:
+	// further exchanges fail with an error matching ErrNotConnected until
+	// Reconnect or Connect.
+	Exchange time.Duration
+}
+
+// SetTimeouts sets the timeouts of the reader's operations
+func (m *Reader) SetTimeouts(timeouts Timeouts) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeouts = timeouts
+}
+
+// Timeouts returns the timeouts of the reader's operations
+func (m *Reader) Timeouts() Timeouts {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.timeouts
+}
+
+// withTimeout bounds ctx by the timeout if it is set. context.Cause reports
+// the timeout as ErrTimeout.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %v", ErrTimeout, timeout))
+}
+
+// exchange sends an APDU over the card connection within the exchange
+// timeout and the context of the operation. An exchange given up on is left
+// running and the connection given up until it is re-established, see
+// settle.
+func (m *Reader) exchange(cmd []byte) ([]byte, error) {
+	if m.abandoned != nil {
+		return nil, errAbandoned
+	}
+	ctx := m.operation()
+	if timeout := m.timeouts.Exchange; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w: no response after %v", ErrTimeout, timeout))
+		defer cancel()
+	}
+	if ctx.Done() == nil {
+		return m.card.Transmit(cmd)
+	}
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	type result struct {
+		rsp []byte
+		err error
+	}
+	done := make(chan result, 1)
+	finished := make(chan struct{})
+	card := m.card
+	go func() {
+		defer close(finished)
+		rsp, err := card.Transmit(cmd)
+		done <- result{rsp, err}
+	}()
+	select {
+	case r := <-done:
+		return r.rsp, r.err
+	case <-ctx.Done():
+		err := context.Cause(ctx)
+		Logger().Warn("exchange given up, reconnect to go on", "reader", m.reader, "error", err)
+		m.abandoned = finished
+		return nil, err
+	}
+}
+
+// settle prepares the connection for use after an exchange was given up.
+// Other backends than PC/SC cannot take a command while one is running, so
+// it waits for that exchange to end, or returns the error of ctx.
+func (m *Reader) settle(ctx context.Context) error {
+	if m.abandoned == nil {
+		return nil
+	}
+	if _, ok := m.card.(pcscConnection); !ok {
+		select {
+		case <-m.abandoned:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+	m.abandoned = nil
+	return nil
+}
@@ -0,0 +1,65 @@
+package hardware_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+)
+
+// stalling is a connection on which reads hang until released once stalled
+type stalling struct {
+	*mock.Connection
+	stalled atomic.Bool
+	release chan struct{}
+	reads   atomic.Int32
+}
+
+func (c *stalling) Transmit(cmd []byte) ([]byte, error) {
+	if c.stalled.Load() && len(cmd) >= 2 && cmd[0] == 0xFF && cmd[1] == 0xB0 {
+		c.reads.Add(1)
+		<-c.release
+	}
+	return c.Connection.Transmit(cmd)
+}
+
+func TestExchangeTimeout(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	conn := &stalling{Connection: mock.NewConnection(card, mock.NTAGATR), release: make(chan struct{})}
+	reader := hardware.NewReaderWithConnection(conn)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	reader.SetTimeouts(hardware.Timeouts{Connect: 50 * time.Millisecond, Exchange: 20 * time.Millisecond})
+	read := []byte{0xFF, 0xB0, 0x00, 0x04, 0x04}
+
+	conn.stalled.Store(true)
+	if _, err := reader.Transport().Transmit(read); !errors.Is(err, hardware.ErrTimeout) {
+		t.Fatalf("stalled read: %v", err)
+	}
+	if _, err := reader.Transport().Transmit(read); !errors.Is(err, hardware.ErrNotConnected) {
+		t.Fatalf("read after a timeout: %v", err)
+	}
+	if n := conn.reads.Load(); n != 1 {
+		t.Fatalf("%d reads reached the connection, want 1", n)
+	}
+
+	// The connection is not used while the timed out read runs
+	if err := reader.Reconnect(); !errors.Is(err, hardware.ErrTimeout) {
+		t.Fatalf("Reconnect during the stalled read: %v", err)
+	}
+	conn.stalled.Store(false)
+	close(conn.release)
+	if err := reader.Reconnect(); err != nil {
+		t.Fatalf("Reconnect: %v", err)
+	}
+	if rsp, err := reader.Transport().Transmit(read); err != nil || len(rsp) != 6 {
+		t.Fatalf("read after Reconnect: % X, %v", rsp, err)
+	}
+}
@@ -0,0 +1,48 @@
+package transcript_test
+
+import (
+	"testing"
+
+	"github.com/oo-developer/acr122u/classic"
+	classicsim "github.com/oo-developer/acr122u/classic/simulator"
+	"github.com/oo-developer/acr122u/hardware/transcript"
+)
+
+func TestReplayTryStandardKeys(t *testing.T) {
+	card, err := newClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	rec := transcript.NewRecorder(card)
+	if name := classic.NewClassicWithTransport(rec).TryStandardKeys(4, classic.KeyTypeA); name != "factory" {
+		t.Fatalf("TryStandardKeys: %q", name)
+	}
+
+	// The keys are probed in the same order every time
+	for i := 0; i < 20; i++ {
+		rep := transcript.NewReplayer(rec.Exchanges())
+		if name := classic.NewClassicWithTransport(rep).TryStandardKeys(4, classic.KeyTypeA); name != "factory" {
+			t.Fatalf("replay %d: TryStandardKeys: %q", i, name)
+		}
+		if err := rep.Done(); err != nil {
+			t.Fatalf("replay %d: Done: %v", i, err)
+		}
+	}
+}
+
+// newClassic1K returns a blank MIFARE Classic 1K with all sectors in
+// transport configuration
+func newClassic1K(uid []byte) (*classicsim.Simulator, error) {
+	memory := make([]byte, 1024)
+	copy(memory, uid)
+	memory[4] = uid[0] ^ uid[1] ^ uid[2] ^ uid[3]
+	copy(memory[5:8], []byte{0x08, 0x04, 0x00})
+	for sector := 0; sector < 16; sector++ {
+		copy(memory[sector*64+48:], []byte{
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFF, 0x07, 0x80, 0x69,
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		})
+	}
+	return classicsim.NewSimulator(memory)
+}
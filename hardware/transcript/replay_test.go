@@ -0,0 +1,144 @@
+package transcript_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/hardware/transcript"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// replay runs a flow against a transcript of testdata, which it must
+// replay completely
+func replay(t *testing.T, name string, flow func(t *testing.T, card hardware.Transport)) {
+	t.Helper()
+	rep, err := transcript.LoadReplayer("testdata/" + name + ".jsonl")
+	if err != nil {
+		t.Fatalf("LoadReplayer: %v", err)
+	}
+	flow(t, rep)
+	if err := rep.Done(); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+}
+
+func TestReplayClassic(t *testing.T) {
+	replay(t, "classic1k_read", func(t *testing.T, card hardware.Transport) {
+		c := classic.NewClassicWithTransport(card)
+		if err := c.LoadKey(0, []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}); err != nil {
+			t.Fatalf("LoadKey: %v", err)
+		}
+		if err := c.Authenticate(0, 0x60, 0); !errors.Is(err, hardware.ErrAuthFailed) {
+			t.Fatalf("Authenticate with a wrong key: %v", err)
+		}
+		if err := c.LoadKey(0, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}); err != nil {
+			t.Fatalf("LoadKey: %v", err)
+		}
+		var data []byte
+		for sector := byte(0); sector < 2; sector++ {
+			if err := c.Authenticate(sector*4, 0x60, 0); err != nil {
+				t.Fatalf("Authenticate sector %d: %v", sector, err)
+			}
+			for block := sector * 4; block < sector*4+4; block++ {
+				b, err := c.ReadBlock(block)
+				if err != nil {
+					t.Fatalf("ReadBlock %d: %v", block, err)
+				}
+				data = append(data, b...)
+			}
+		}
+		if !bytes.Equal(data[:4], []byte{0x5A, 0x3C, 0x12, 0x8B}) || !bytes.HasPrefix(data[64:], []byte("ACCESS-0001")) {
+			t.Fatalf("read % X", data)
+		}
+	})
+}
+
+func TestReplayNTAG(t *testing.T) {
+	replay(t, "ntag215_dump", func(t *testing.T, card hardware.Transport) {
+		dump, err := ntag.NewNTAGWithTransport(card).DumpMemory()
+		if err != nil {
+			t.Fatalf("DumpMemory: %v", err)
+		}
+		if len(dump) != ntag.NTAG215TotalPages*4 || !bytes.Equal(dump[12:16], []byte{0xE1, 0x10, 0x3E, 0x00}) {
+			t.Fatalf("dumped %d bytes, capability container % X", len(dump), dump[12:16])
+		}
+	})
+}
+
+func TestReplayUltralight(t *testing.T) {
+	replay(t, "ultralight_ev1_read", func(t *testing.T, card hardware.Transport) {
+		tag := ntag.NewNTAGWithTransport(card)
+		version, err := tag.GetVersion()
+		if err != nil {
+			t.Fatalf("GetVersion: %v", err)
+		}
+		if version[2] != 0x03 || version[6] != 0x0B {
+			t.Fatalf("version % X is not an MF0UL11", version)
+		}
+		var data []byte
+		for page := byte(0); page < 20; page += 4 {
+			b, err := tag.ReadPages(page)
+			if err != nil {
+				t.Fatalf("ReadPages %d: %v", page, err)
+			}
+			data = append(data, b...)
+		}
+		if len(data) != 80 || data[0] != 0x04 {
+			t.Fatalf("read % X", data)
+		}
+	})
+}
+
+func TestReplayDESFire(t *testing.T) {
+	replay(t, "desfire_ev1_info", func(t *testing.T, card hardware.Transport) {
+		df := desfire.NewDESFireWithTransport(card)
+		version, err := df.GetVersion()
+		if err != nil {
+			t.Fatalf("GetVersion: %v", err)
+		}
+		if len(version) != 28 || version[0] != 0x04 || version[1] != 0x01 {
+			t.Fatalf("version % X", version)
+		}
+		aids, err := df.GetApplicationIDs()
+		if err != nil || len(aids) != 0 {
+			t.Fatalf("GetApplicationIDs: %X, %v", aids, err)
+		}
+		if v, err := df.GetKeyVersion(0); err != nil || v != 0 {
+			t.Fatalf("GetKeyVersion: %d, %v", v, err)
+		}
+	})
+}
+
+func TestFileRecorderReplay(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	rec, err := transcript.NewFileRecorder(card, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRecorder: %v", err)
+	}
+	want, err := ntag.NewNTAGWithTransport(rec).DumpMemory()
+	if err != nil {
+		t.Fatalf("DumpMemory: %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rep, err := transcript.LoadReplayer(rec.Path())
+	if err != nil {
+		t.Fatalf("LoadReplayer: %v", err)
+	}
+	if dump, err := ntag.NewNTAGWithTransport(rep).DumpMemory(); err != nil || !bytes.Equal(dump, want) {
+		t.Fatalf("replayed DumpMemory: % X, %v", dump, err)
+	}
+	if err := rep.Done(); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+}
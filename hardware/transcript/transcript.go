@@ -0,0 +1,300 @@
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// Exchange is one command and the card's response. Error holds the transport
+// error instead of a response, e.g. a removed card. Time and Duration are
+// zero unless recorded.
+type Exchange struct {
+	Command  []byte
+	Response []byte
+	Error    string
+	Time     time.Time
+	Duration time.Duration
+}
+
+// Directions of transcript records
+const (
+	dirCommand  = "cmd"
+	dirResponse = "rsp"
+)
+
+// record is the file representation of one direction of an exchange, one per
+// line. Responses carry the data and status word separately and the time the
+// card took to answer:
+//
+//	{"time":"2026-10-16T08:58:02.873Z","dir":"cmd","data":"FFCA000000"}
+//	{"dir":"rsp","data":"04A1B2C3D4E5F6","sw":"9000","duration_us":412}
+type record struct {
+	Time       *time.Time `json:"time,omitempty"`
+	Dir        string     `json:"dir"`
+	Data       string     `json:"data,omitempty"`
+	SW         string     `json:"sw,omitempty"`
+	Error      string     `json:"err,omitempty"`
+	DurationUs int64      `json:"duration_us,omitempty"`
+}
+
+// Read parses a transcript. Empty lines and lines starting with # are
+// skipped, so transcripts can carry comments.
+func Read(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	pending := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		data, err := hex.DecodeString(rec.Data + rec.SW)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid data: %v", line, err)
+		}
+		switch {
+		case rec.Dir == dirCommand && !pending:
+			e := Exchange{Command: data}
+			if rec.Time != nil {
+				e.Time = *rec.Time
+			}
+			exchanges = append(exchanges, e)
+			pending = true
+		case rec.Dir == dirResponse && pending:
+			e := &exchanges[len(exchanges)-1]
+			e.Response, e.Error = data, rec.Error
+			e.Duration = time.Duration(rec.DurationUs) * time.Microsecond
+			pending = false
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q record", line, rec.Dir)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %v", err)
+	}
+	if pending {
+		return nil, fmt.Errorf("line %d: command without response", line)
+	}
+	return exchanges, nil
+}
+
+// Write writes exchanges in the format Read parses
+func Write(w io.Writer, exchanges []Exchange) error {
+	encoder := json.NewEncoder(w)
+	for _, e := range exchanges {
+		for _, rec := range records(e) {
+			if err := encoder.Encode(rec); err != nil {
+				return fmt.Errorf("failed to write transcript: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// records returns the command and response records of an exchange
+func records(e Exchange) []record {
+	cmd := record{Dir: dirCommand, Data: strings.ToUpper(hex.EncodeToString(e.Command))}
+	if !e.Time.IsZero() {
+		t := e.Time.UTC()
+		cmd.Time = &t
+	}
+	rsp := record{Dir: dirResponse, Error: e.Error, DurationUs: e.Duration.Microseconds()}
+	data := e.Response
+	if len(data) >= 2 {
+		rsp.SW = strings.ToUpper(hex.EncodeToString(data[len(data)-2:]))
+		data = data[:len(data)-2]
+	}
+	rsp.Data = strings.ToUpper(hex.EncodeToString(data))
+	return []record{cmd, rsp}
+}
+
+// Load reads a transcript file
+func Load(path string) ([]Exchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %v", err)
+	}
+	defer f.Close()
+	exchanges, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return exchanges, nil
+}
+
+// Save writes a transcript file
+func Save(path string, exchanges []Exchange) error {
+	var buf bytes.Buffer
+	if err := Write(&buf, exchanges); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to save transcript: %v", err)
+	}
+	return nil
+}
+
+// Recorder passes APDUs to a transport and records the exchanges, e.g. to
+// capture a transcript from a real card:
+//
+//	rec := transcript.NewRecorder(reader.Transport())
+//	tag := ntag.NewNTAGWithTransport(rec)
+//	tag.DumpMemory()
+//	transcript.Save("ntag215_dump.jsonl", rec.Exchanges())
+//
+// Recorder and Replayer are safe for concurrent use, though exchanges of
+// concurrent callers interleave in no defined order.
+type Recorder struct {
+	mu        sync.Mutex
+	transport hardware.Transport
+	exchanges []Exchange
+	path      string
+	file      *os.File
+	encoder   *json.Encoder
+	err       error
+}
+
+// NewRecorder records the exchanges with the transport
+func NewRecorder(transport hardware.Transport) *Recorder {
+	return &Recorder{transport: transport}
+}
+
+// NewFileRecorder records the exchanges with the transport and writes each
+// one as it happens to a new file in dir named after the current time, e.g.
+// transcript-20261016-085802.873.jsonl, so the transcript of a session that
+// crashes or hangs is kept. Close the recorder when the session ends.
+func NewFileRecorder(transport hardware.Transport, dir string) (*Recorder, error) {
+	now := time.Now()
+	path := filepath.Join(dir, "transcript-"+now.Format("20060102-150405.000")+".jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcript: %v", err)
+	}
+	if _, err := fmt.Fprintf(f, "# transcript started %s\n", now.UTC().Format(time.RFC3339)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write transcript: %v", err)
+	}
+	return &Recorder{transport: transport, path: path, file: f, encoder: json.NewEncoder(f)}, nil
+}
+
+// Path returns the file of a recorder created with NewFileRecorder
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Close closes the file of a recorder created with NewFileRecorder and
+// returns the first error writing it
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to write transcript: %v", err)
+	}
+	r.file, r.encoder = nil, nil
+	return r.err
+}
+
+func (r *Recorder) Transmit(cmd []byte) ([]byte, error) {
+	start := time.Now()
+	rsp, err := r.transport.Transmit(cmd)
+	e := Exchange{
+		Command:  append([]byte{}, cmd...),
+		Response: append([]byte{}, rsp...),
+		Time:     start,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.mu.Lock()
+	r.exchanges = append(r.exchanges, e)
+	if r.encoder != nil && r.err == nil {
+		for _, rec := range records(e) {
+			if werr := r.encoder.Encode(rec); werr != nil {
+				r.err = fmt.Errorf("failed to write transcript: %v", werr)
+				break
+			}
+		}
+	}
+	r.mu.Unlock()
+	return rsp, err
+}
+
+// Exchanges returns the exchanges recorded so far
+func (r *Recorder) Exchanges() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Exchange{}, r.exchanges...)
+}
+
+// Replayer is a transport answering with the responses of a transcript. Each
+// command must match the next recorded command, so a replay fails as soon as
+// the code under test sends something different than when it was recorded.
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+	next      int
+}
+
+// NewReplayer replays the exchanges in order
+func NewReplayer(exchanges []Exchange) *Replayer {
+	return &Replayer{exchanges: exchanges}
+}
+
+// LoadReplayer replays a transcript file
+func LoadReplayer(path string) (*Replayer, error) {
+	exchanges, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(exchanges), nil
+}
+
+func (r *Replayer) Transmit(cmd []byte) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next >= len(r.exchanges) {
+		return nil, fmt.Errorf("transcript exhausted: unexpected command %X", cmd)
+	}
+	e := r.exchanges[r.next]
+	if !bytes.Equal(e.Command, cmd) {
+		return nil, fmt.Errorf("exchange %d: expected command %X, got %X", r.next+1, e.Command, cmd)
+	}
+	r.next++
+	if e.Error != "" {
+		return nil, fmt.Errorf("%s", e.Error)
+	}
+	return append([]byte{}, e.Response...), nil
+}
+
+// Done returns an error unless every exchange has been replayed
+func (r *Replayer) Done() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next < len(r.exchanges) {
+		return fmt.Errorf("%d of %d exchanges not replayed, next command %X",
+			len(r.exchanges)-r.next, len(r.exchanges), r.exchanges[r.next].Command)
+	}
+	return nil
+}
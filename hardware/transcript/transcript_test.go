@@ -0,0 +1,203 @@
+package transcript
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// echo answers each command with its data and 9000
+type echo struct{}
+
+func (echo) Transmit(cmd []byte) ([]byte, error) {
+	return append(append([]byte{}, cmd...), 0x90, 0x00), nil
+}
+
+func TestRecorderReplayerConcurrent(t *testing.T) {
+	rec := NewRecorder(echo{})
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if _, err := rec.Transmit([]byte{0xFF, 0xB0, 0x00, byte(i), byte(j)}); err != nil {
+					t.Errorf("Transmit: %v", err)
+				}
+				rec.Exchanges()
+			}
+		}()
+	}
+	wg.Wait()
+	exchanges := rec.Exchanges()
+	if len(exchanges) != 400 {
+		t.Fatalf("recorded %d exchanges, want 400", len(exchanges))
+	}
+	for _, e := range exchanges {
+		if !bytes.Equal(e.Response[:len(e.Command)], e.Command) {
+			t.Fatalf("command %X recorded with response %X", e.Command, e.Response)
+		}
+	}
+
+	// Concurrent callers replay in no defined order, so all send the same
+	// command
+	same := make([]Exchange, 400)
+	for i := range same {
+		same[i] = Exchange{Command: []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}, Response: []byte{0x04, 0xA1, 0x90, 0x00}}
+	}
+	rep := NewReplayer(same)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if _, err := rep.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err != nil {
+					t.Errorf("Transmit: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := rep.Done(); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+}
+
+// failing answers every command with an error
+type failing struct{}
+
+func (failing) Transmit(cmd []byte) ([]byte, error) {
+	return nil, errors.New("card removed")
+}
+
+func TestWriteRead(t *testing.T) {
+	at := time.Date(2026, 10, 16, 8, 58, 2, 873000000, time.UTC)
+	exchanges := []Exchange{
+		{Command: []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}, Response: []byte{0x04, 0xA1, 0xB2, 0x90, 0x00}, Time: at, Duration: 412 * time.Microsecond},
+		{Command: []byte{0xFF, 0xB0, 0x00, 0x04, 0x10}, Response: []byte{0x63, 0x00}},
+		{Command: []byte{0xFF, 0xB0, 0x00, 0x08, 0x10}, Error: "card removed", Time: at.Add(time.Second)},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, exchanges); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("wrote %d records, want 6:\n%s", len(lines), buf.String())
+	}
+	for i, want := range []string{
+		`{"time":"2026-10-16T08:58:02.873Z","dir":"cmd","data":"FFCA000000"}`,
+		`{"dir":"rsp","data":"04A1B2","sw":"9000","duration_us":412}`,
+		`{"dir":"cmd","data":"FFB0000410"}`,
+		`{"dir":"rsp","sw":"6300"}`,
+	} {
+		if lines[i] != want {
+			t.Errorf("record %d: %s, want %s", i, lines[i], want)
+		}
+	}
+
+	read, err := Read(strings.NewReader("# comment\n\n" + buf.String()))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(read) != len(exchanges) {
+		t.Fatalf("read %d exchanges, want %d", len(read), len(exchanges))
+	}
+	for i, e := range exchanges {
+		got := read[i]
+		if !bytes.Equal(got.Command, e.Command) || !bytes.Equal(got.Response, e.Response) ||
+			got.Error != e.Error || !got.Time.Equal(e.Time) || got.Duration != e.Duration {
+			t.Errorf("exchange %d: %+v, want %+v", i, got, e)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, text string
+	}{
+		{"response first", `{"dir":"rsp","sw":"9000"}`},
+		{"two commands", `{"dir":"cmd","data":"FFCA000000"}` + "\n" + `{"dir":"cmd","data":"FFCA000000"}`},
+		{"command without response", `{"dir":"cmd","data":"FFCA000000"}`},
+		{"invalid hex", `{"dir":"cmd","data":"FFCA00000"}`},
+		{"invalid JSON", `{"dir":"cmd"`},
+		{"unknown direction", `{"dir":"req","data":"FFCA000000"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Read(strings.NewReader(tc.text)); err == nil {
+				t.Fatalf("Read accepted %s", tc.text)
+			}
+		})
+	}
+}
+
+func TestFileRecorder(t *testing.T) {
+	rec, err := NewFileRecorder(echo{}, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileRecorder: %v", err)
+	}
+	start := time.Now()
+	if _, err := rec.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err != nil {
+		t.Fatalf("Transmit: %v", err)
+	}
+	if _, err := rec.Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x10}); err != nil {
+		t.Fatalf("Transmit: %v", err)
+	}
+
+	// Exchanges are on disk before the recorder is closed
+	exchanges, err := Load(rec.Path())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(exchanges) != 2 {
+		t.Fatalf("loaded %d exchanges before Close, want 2", len(exchanges))
+	}
+	if e := exchanges[1]; !bytes.Equal(e.Response, []byte{0xFF, 0xB0, 0x00, 0x04, 0x10, 0x90, 0x00}) ||
+		e.Time.Before(start.Truncate(time.Millisecond)) || e.Duration < 0 {
+		t.Fatalf("loaded %+v", e)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if _, err := rec.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err != nil {
+		t.Fatalf("Transmit after Close: %v", err)
+	}
+	if exchanges, _ := Load(rec.Path()); len(exchanges) != 2 {
+		t.Fatalf("loaded %d exchanges after Close, want 2", len(exchanges))
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	rec := NewRecorder(echo{})
+	rec.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+	exchanges := rec.Exchanges()
+	removed := NewRecorder(failing{})
+	removed.Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x10})
+	exchanges = append(exchanges, removed.Exchanges()...)
+
+	rep := NewReplayer(exchanges)
+	if _, err := rep.Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x10}); err == nil {
+		t.Fatalf("Transmit accepted a command out of order")
+	}
+	if rsp, err := rep.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err != nil || !bytes.Equal(rsp, []byte{0xFF, 0xCA, 0x00, 0x00, 0x00, 0x90, 0x00}) {
+		t.Fatalf("Transmit: %X, %v", rsp, err)
+	}
+	if err := rep.Done(); err == nil {
+		t.Fatalf("Done with an exchange left")
+	}
+	if _, err := rep.Transmit([]byte{0xFF, 0xB0, 0x00, 0x04, 0x10}); err == nil || err.Error() != "card removed" {
+		t.Fatalf("Transmit of a failed exchange: %v", err)
+	}
+	if _, err := rep.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err == nil {
+		t.Fatalf("Transmit past the end of the transcript")
+	}
+	if err := rep.Done(); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+}
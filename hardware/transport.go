@@ -0,0 +1,28 @@
+package hardware
+
+// Transport exchanges APDUs with a card. *scard.Card implements it; card
+// modules accept any Transport so they can also run against simulators.
+type Transport interface {
+	Transmit(cmd []byte) ([]byte, error)
+}
+
+// Connection is a connection to a card through a reader backend. Reader
+// talks to the card through it: PC/SC connections are made by Connect,
+// other backends such as libnfc, a serial PN532 or test doubles are given
+// to NewReaderWithConnection.
+type Connection interface {
+	Transport
+	// Control sends a control command to the reader, see Reader.Control
+	Control(code uint32, data []byte) ([]byte, error)
+	// Status returns the state of the connection
+	Status() (ConnectionStatus, error)
+	// Disconnect ends the connection, leaving the card as it is
+	Disconnect() error
+}
+
+// ConnectionStatus is the state of a connection
+type ConnectionStatus struct {
+	Reader   string
+	ATR      []byte
+	Protocol string // "T=0" or "T=1", empty if unknown
+}
@@ -0,0 +1,80 @@
+package hardware
+
+import "fmt"
+
+// Tx is exclusive use of the card from BeginTransaction to End: the
+// reader's lock keeps other goroutines out and, on PC/SC connections, a
+// PC/SC transaction other processes. Tx is the transport for the card
+// modules meanwhile, e.g. classic.NewClassicWithTransport; the reader's own
+// transport would wait for the transaction to end.
+type Tx struct {
+	reader    *Reader
+	transport Transport
+	ended     bool
+}
+
+// BeginTransaction starts exclusive use of the card, so that a sequence of
+// APDUs such as an authentication and a read is not interleaved by other
+// goroutines or processes. End must be called to release the reader.
+func (m *Reader) BeginTransaction() (*Tx, error) {
+	m.mu.Lock()
+	if m.card == nil {
+		m.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	if t, ok := m.card.(transactor); ok {
+		if err := t.BeginTransaction(); err != nil {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("failed to begin transaction: %w", cardError(err))
+		}
+	}
+	return &Tx{
+		reader:    m,
+		transport: readerTransport{Transport: m.chain(), extendedLength: m.extendedLength},
+	}, nil
+}
+
+func (tx *Tx) Transmit(cmd []byte) ([]byte, error) {
+	if tx.ended {
+		return nil, fmt.Errorf("transaction ended")
+	}
+	return tx.transport.Transmit(cmd)
+}
+
+// ExtendedLength reports whether the reader passes extended length APDUs
+func (tx *Tx) ExtendedLength() bool {
+	return ExtendedLength(tx.transport)
+}
+
+// End ends the transaction and releases the reader. Further calls do
+// nothing.
+func (tx *Tx) End() error {
+	if tx.ended {
+		return nil
+	}
+	tx.ended = true
+	m := tx.reader
+	defer m.mu.Unlock()
+	t, ok := m.card.(transactor)
+	if !ok {
+		return nil
+	}
+	if err := t.EndTransaction(); err != nil {
+		return fmt.Errorf("failed to end transaction: %w", cardError(err))
+	}
+	return nil
+}
+
+// Transaction runs fn in a transaction on the card, see BeginTransaction,
+// and returns its error, or else that of ending the transaction
+func (m *Reader) Transaction(fn func(t Transport) error) error {
+	tx, err := m.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	if endErr := tx.End(); err == nil {
+		err = endErr
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+// Package cmac implements CMAC (NIST SP 800-38B) for the AES and DES based
+// MACs of the card packages
+package cmac
+
+import "crypto/cipher"
+
+// Subkeys derives the subkeys K1 and K2 of a block cipher with 8 or 16
+// byte blocks
+func Subkeys(block cipher.Block) ([]byte, []byte) {
+	l := make([]byte, block.BlockSize())
+	block.Encrypt(l, l)
+	k1 := double(l)
+	return k1, double(k1)
+}
+
+// double multiplies a value by x in GF(2^64) or GF(2^128)
+func double(in []byte) []byte {
+	rb := byte(0x87)
+	if len(in) == 8 {
+		rb = 0x1B
+	}
+	out := make([]byte, len(in))
+	var carry byte
+	for i := len(in) - 1; i >= 0; i-- {
+		out[i] = in[i]<<1 | carry
+		carry = in[i] >> 7
+	}
+	if carry != 0 {
+		out[len(out)-1] ^= rb
+	}
+	return out
+}
+
+// Sum returns the CMAC of data
+func Sum(block cipher.Block, data []byte) []byte {
+	return Chained(block, nil, data)
+}
+
+// Chained returns the CMAC of data with iv as the initial chaining value
+// instead of zero, as DESFire chains the MACs of a session. A nil iv is
+// zero.
+func Chained(block cipher.Block, iv []byte, data []byte) []byte {
+	bs := block.BlockSize()
+	k1, k2 := Subkeys(block)
+
+	msg := append([]byte{}, data...)
+	last := k1
+	if len(msg) == 0 || len(msg)%bs != 0 {
+		msg = append(msg, 0x80)
+		msg = append(msg, make([]byte, (bs-len(msg)%bs)%bs)...)
+		last = k2
+	}
+	tail := msg[len(msg)-bs:]
+	for i := range tail {
+		tail[i] ^= last[i]
+	}
+
+	mac := make([]byte, bs)
+	copy(mac, iv)
+	for i := 0; i < len(msg); i += bs {
+		for j := range mac {
+			mac[j] ^= msg[i+j]
+		}
+		block.Encrypt(mac, mac)
+	}
+	return mac
+}
@@ -0,0 +1,95 @@
+package cmac
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/des"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// unhex decodes hex, ignoring spaces
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+// TestAES runs the AES-128 examples of NIST SP 800-38B, Appendix D.1
+func TestAES(t *testing.T) {
+	block, err := aes.NewCipher(unhex(t, "2B7E1516 28AED2A6 ABF71588 09CF4F3C"))
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	k1, k2 := Subkeys(block)
+	if want := unhex(t, "FBEED618 35713366 7C85E08F 7236A8DE"); !bytes.Equal(k1, want) {
+		t.Errorf("K1 % X, want % X", k1, want)
+	}
+	if want := unhex(t, "F7DDAC30 6AE266CC F90BC11E E46D513B"); !bytes.Equal(k2, want) {
+		t.Errorf("K2 % X, want % X", k2, want)
+	}
+
+	msg := unhex(t, "6BC1BEE2 2E409F96 E93D7E11 7393172A AE2D8A57 1E03AC9C 9EB76FAC 45AF8E51"+
+		"30C81C46 A35CE411 E5FBC119 1A0A52EF F69F2445 DF4F9B17 AD2B417B E66C3710")
+	for _, tc := range []struct {
+		len  int
+		want string
+	}{
+		{0, "BB1D6929 E9593728 7FA37D12 9B756746"},
+		{16, "070A16B4 6B4D4144 F79BDD9D D04A287C"},
+		{40, "DFA66747 DE9AE630 30CA3261 1497C827"},
+		{64, "51F0BEBF 7E3B9D92 FC497417 79363CFE"},
+	} {
+		if mac := Sum(block, msg[:tc.len]); !bytes.Equal(mac, unhex(t, tc.want)) {
+			t.Errorf("CMAC of %d bytes % X, want %s", tc.len, mac, tc.want)
+		}
+	}
+}
+
+// TestTDES runs the three-key TDEA examples of NIST SP 800-38B, Appendix D.4
+func TestTDES(t *testing.T) {
+	block, err := des.NewTripleDESCipher(unhex(t, "8AA83BF8CBDA1062 0BC1BF19FBB6CD58 BC313D4A371CA8B5"))
+	if err != nil {
+		t.Fatalf("NewTripleDESCipher: %v", err)
+	}
+	msg := unhex(t, "6BC1BEE22E409F96")
+	for _, tc := range []struct {
+		len  int
+		want string
+	}{
+		{0, "B7A688E122FFAF95"},
+		{8, "8E8F293136283797"},
+	} {
+		if mac := Sum(block, msg[:tc.len]); !bytes.Equal(mac, unhex(t, tc.want)) {
+			t.Errorf("CMAC of %d bytes % X, want %s", tc.len, mac, tc.want)
+		}
+	}
+}
+
+func TestChained(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	data := []byte{0x6F, 0x01}
+	if mac := Chained(block, make([]byte, 16), data); !bytes.Equal(mac, Sum(block, data)) {
+		t.Errorf("CMAC chained on zero % X, want % X", mac, Sum(block, data))
+	}
+
+	// The chaining value enters the last block like a CBC IV
+	iv := bytes.Repeat([]byte{0xA5}, 16)
+	_, k2 := Subkeys(block)
+	want := append(append([]byte{}, data...), 0x80)
+	want = append(want, make([]byte, 16-len(want))...)
+	for i := range want {
+		want[i] ^= k2[i] ^ iv[i]
+	}
+	block.Encrypt(want, want)
+	if mac := Chained(block, iv, data); !bytes.Equal(mac, want) {
+		t.Errorf("CMAC chained on % X: % X, want % X", iv, mac, want)
+	}
+}
@@ -0,0 +1,90 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// AgeFile is a provider of the secrets in an age encrypted file. The
+// decrypted file is a JSON object of names and hex secrets:
+//
+//	{"office-a": "A0A1A2A3A4A5", "master": "00112233445566778899AABBCCDDEEFF"}
+type AgeFile struct {
+	secrets Map
+}
+
+// LoadAgeFile decrypts a file with the identities of an identity file, as
+// written by age-keygen
+func LoadAgeFile(path string, identityPath string) (*AgeFile, error) {
+	data, err := os.ReadFile(identityPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age identity: %v", err)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identity: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open age file: %v", err)
+	}
+	defer f.Close()
+	r, err := age.Decrypt(f, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %v", path, err)
+	}
+	var text map[string]string
+	if err := json.NewDecoder(r).Decode(&text); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	secrets := make(Map)
+	for name, value := range text {
+		if secrets[name], err = hex.DecodeString(value); err != nil {
+			return nil, fmt.Errorf("secret %s in %s is not hex", name, path)
+		}
+	}
+	return &AgeFile{secrets: secrets}, nil
+}
+
+func (a *AgeFile) Secret(name string) ([]byte, error) {
+	return a.secrets.Secret(name)
+}
+
+// SaveAgeFile encrypts secrets to the recipients, given as "age1..." or SSH
+// public keys, and writes them to a file readable only by the owner
+func SaveAgeFile(path string, secrets Map, recipients ...string) error {
+	parsed, err := age.ParseRecipients(strings.NewReader(strings.Join(recipients, "\n")))
+	if err != nil {
+		return fmt.Errorf("failed to parse age recipients: %v", err)
+	}
+	text := make(map[string]string)
+	for name, secret := range secrets {
+		text[name] = fmt.Sprintf("%X", secret)
+	}
+	plain, err := json.MarshalIndent(text, "", "  ")
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, parsed...)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %v", err)
+	}
+	if _, err := io.Copy(w, bytes.NewReader(plain)); err != nil {
+		return fmt.Errorf("failed to encrypt: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to save age file: %v", err)
+	}
+	return nil
+}
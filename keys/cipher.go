@@ -0,0 +1,46 @@
+package keys
+
+import (
+	"crypto/cipher"
+	"fmt"
+)
+
+// Cipher performs CBC encryption with a key that is not handed out, such as
+// a key kept in an HSM. Data must be a multiple of the block size.
+type Cipher interface {
+	BlockSize() int
+	EncryptCBC(iv []byte, data []byte) ([]byte, error)
+	DecryptCBC(iv []byte, data []byte) ([]byte, error)
+}
+
+// CipherProvider supplies the ciphers of keys by name
+type CipherProvider interface {
+	Cipher(name string) (Cipher, error)
+}
+
+// BlockCipher adapts a block cipher of a key in memory
+func BlockCipher(block cipher.Block) Cipher {
+	return blockCipher{block}
+}
+
+type blockCipher struct {
+	cipher.Block
+}
+
+func (b blockCipher) EncryptCBC(iv []byte, data []byte) ([]byte, error) {
+	if len(data)%b.BlockSize() != 0 || len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("data is not aligned to the block size")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(b.Block, iv).CryptBlocks(out, data)
+	return out, nil
+}
+
+func (b blockCipher) DecryptCBC(iv []byte, data []byte) ([]byte, error) {
+	if len(data)%b.BlockSize() != 0 || len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("data is not aligned to the block size")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(b.Block, iv).CryptBlocks(out, data)
+	return out, nil
+}
@@ -0,0 +1,56 @@
+package keys
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Keyring is a provider of secrets in the OS keyring: the Secret Service on
+// Linux, the Keychain on macOS and the Credential Manager on Windows.
+// Secrets are stored as hex under the service, with the name as user.
+type Keyring struct {
+	service string
+}
+
+// NewKeyring returns the secrets of a keyring service
+func NewKeyring(service string) *Keyring {
+	return &Keyring{service: service}
+}
+
+func (k *Keyring) Secret(name string) ([]byte, error) {
+	text, err := keyring.Get(k.service, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s in keyring %s", ErrNotFound, name, k.service)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %v", err)
+	}
+	secret, err := hex.DecodeString(text)
+	if err != nil {
+		return nil, fmt.Errorf("keyring secret %s is not hex", name)
+	}
+	return secret, nil
+}
+
+// Store saves a secret in the keyring, replacing one of the same name
+func (k *Keyring) Store(name string, secret []byte) error {
+	if err := keyring.Set(k.service, name, hex.EncodeToString(secret)); err != nil {
+		return fmt.Errorf("failed to write keyring: %v", err)
+	}
+	return nil
+}
+
+// Delete removes a secret from the keyring
+func (k *Keyring) Delete(name string) error {
+	err := keyring.Delete(k.service, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("%w: %s in keyring %s", ErrNotFound, name, k.service)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write keyring: %v", err)
+	}
+	return nil
+}
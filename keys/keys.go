@@ -0,0 +1,129 @@
+package keys
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/oo-developer/acr122u/config"
+)
+
+// Provider supplies keys and passwords by name
+type Provider interface {
+	Secret(name string) ([]byte, error)
+}
+
+// ErrNotFound is returned by providers for names they do not hold
+var ErrNotFound = errors.New("secret not found")
+
+// Map is a provider of secrets held in memory
+type Map map[string][]byte
+
+func (m Map) Secret(name string) ([]byte, error) {
+	secret, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return secret, nil
+}
+
+// DefaultKeyringService is the keyring service of references when the config
+// names none
+const DefaultKeyringService = "acr122u"
+
+// Resolver resolves the key references of a config, see config.DESFireKey.
+// The age file is opened on first use.
+type Resolver struct {
+	keys config.Keys
+
+	mutex sync.Mutex
+	age   *AgeFile
+}
+
+// NewResolver creates a resolver for the key stores of the config
+func NewResolver(cfg *config.Config) *Resolver {
+	return &Resolver{keys: cfg.Keys}
+}
+
+// Resolve returns the key of size bytes a value stands for: the key itself
+// in hex, or a reference
+func (r *Resolver) Resolve(value string, size int) ([]byte, error) {
+	if !config.IsReference(value) {
+		return parseHex(value, size)
+	}
+	scheme, name, _ := strings.Cut(value, ":")
+	var text string
+	switch scheme {
+	case "env":
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s not set", name)
+		}
+		text = v
+	case "file":
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	case "hex":
+		text = name
+	case "keyring":
+		service := r.keys.KeyringService
+		if service == "" {
+			service = DefaultKeyringService
+		}
+		return secret(NewKeyring(service), name, size)
+	case "age":
+		age, err := r.ageFile()
+		if err != nil {
+			return nil, err
+		}
+		return secret(age, name, size)
+	}
+	return parseHex(strings.TrimSpace(text), size)
+}
+
+func (r *Resolver) ageFile() (*AgeFile, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.age != nil {
+		return r.age, nil
+	}
+	if r.keys.AgeFile == "" {
+		return nil, fmt.Errorf("no age file configured")
+	}
+	age, err := LoadAgeFile(r.keys.AgeFile, r.keys.AgeIdentity)
+	if err != nil {
+		return nil, err
+	}
+	r.age = age
+	return age, nil
+}
+
+// secret reads a secret of the size from a provider
+func secret(p Provider, name string, size int) ([]byte, error) {
+	s, err := p.Secret(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) != size {
+		return nil, fmt.Errorf("secret %s has %d bytes, not %d", name, len(s), size)
+	}
+	return s, nil
+}
+
+// parseHex decodes hex with optional spaces or colons, as in configs
+func parseHex(s string, size int) ([]byte, error) {
+	b, err := hex.DecodeString(strings.NewReplacer(" ", "", ":", "").Replace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex %q", s)
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("key must be %d bytes, not %d", size, len(b))
+	}
+	return b, nil
+}
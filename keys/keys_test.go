@@ -0,0 +1,143 @@
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/oo-developer/acr122u/config"
+	"github.com/zalando/go-keyring"
+)
+
+// stores puts secrets into the mocked keyring and an age file, and returns
+// a config naming the age file
+func stores(t *testing.T) *config.Config {
+	t.Helper()
+	keyring.MockInit()
+	if err := NewKeyring(DefaultKeyringService).Store("office-b", []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	dir := t.TempDir()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := filepath.Join(dir, "identity.txt")
+	if err := os.WriteFile(identity, []byte("# created for the test\n"+id.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "keys.age")
+	secrets := Map{"master": bytes.Repeat([]byte{0x11}, 16), "aged-b": {0x01, 0x02, 0x03, 0x04, 0x05, 0x06}}
+	if err := SaveAgeFile(file, secrets, id.Recipient().String()); err != nil {
+		t.Fatalf("SaveAgeFile: %v", err)
+	}
+	if fi, err := os.Stat(file); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("Stat: %v, %v", fi.Mode(), err)
+	}
+
+	cfg, err := config.ParseYAML([]byte(`
+classic:
+  keys:
+    - name: office
+      key_a: A0A1A2A3A4A5
+      key_b: keyring:office-b
+    - name: aged
+      key_b: age:aged-b
+desfire:
+  keys:
+    - name: master
+      type: aes
+      ref: age:master
+keys:
+  age_file: ` + file + `
+  age_identity: ` + identity + `
+`))
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	return cfg
+}
+
+func TestResolve(t *testing.T) {
+	r := NewResolver(stores(t))
+	t.Setenv("ACR122U_TEST_KEY", "FFFFFFFFFFFF")
+	for _, tc := range []struct {
+		value string
+		size  int
+		want  []byte
+	}{
+		{"A0:A1:A2:A3:A4:A5", 6, []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}},
+		{"hex:000102030405", 6, []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}},
+		{"env:ACR122U_TEST_KEY", 6, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{"keyring:office-b", 6, []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5}},
+		{"age:aged-b", 6, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}},
+		{"age:master", 16, bytes.Repeat([]byte{0x11}, 16)},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			key, err := r.Resolve(tc.value, tc.size)
+			if err != nil || !bytes.Equal(key, tc.want) {
+				t.Fatalf("Resolve: % X, %v", key, err)
+			}
+		})
+	}
+
+	for _, value := range []string{"keyring:office-c", "age:office-c"} {
+		if _, err := r.Resolve(value, 6); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Resolve %s: %v", value, err)
+		}
+	}
+	if _, err := r.Resolve("age:master", 6); err == nil {
+		t.Errorf("Resolve accepted a secret of the wrong size")
+	}
+	if _, err := NewResolver(&config.Config{}).Resolve("age:master", 16); err == nil {
+		t.Errorf("Resolve without an age file")
+	}
+	if _, err := config.ParseYAML([]byte("classic:\n  keys:\n    - name: x\n      key_a: age:x\n")); err == nil {
+		t.Errorf("ParseYAML accepted an age reference without an age file")
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	keyring.MockInit()
+	k := NewKeyring("acr122u-test")
+	if _, err := k.Secret("gate"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Secret before Store: %v", err)
+	}
+	if err := k.Store("gate", []byte{0x01, 0x02}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if err := k.Store("gate", []byte{0x03, 0x04}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if secret, err := k.Secret("gate"); err != nil || !bytes.Equal(secret, []byte{0x03, 0x04}) {
+		t.Fatalf("Secret: % X, %v", secret, err)
+	}
+	if _, err := NewKeyring("acr122u-other").Secret("gate"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Secret of another service: %v", err)
+	}
+	if err := k.Delete("gate"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := k.Delete("gate"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Delete: %v", err)
+	}
+}
+
+func TestAgeFileWrongIdentity(t *testing.T) {
+	cfg := stores(t)
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := filepath.Join(t.TempDir(), "other.txt")
+	if err := os.WriteFile(identity, []byte(other.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAgeFile(cfg.Keys.AgeFile, identity); err == nil {
+		t.Fatalf("LoadAgeFile decrypted with another identity")
+	}
+}
@@ -0,0 +1,191 @@
+package pkcs11
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/oo-developer/acr122u/keys"
+)
+
+// Config selects the PKCS#11 module and token
+type Config struct {
+	// Module is the path of the PKCS#11 library, e.g.
+	// /usr/lib/softhsm/libsofthsm2.so
+	Module string
+	// Token is the label of the token, the first token if empty
+	Token string
+	// PIN is the user PIN of the token
+	PIN string
+}
+
+// HSM is a provider of ciphers of the secret keys on a PKCS#11 token. The
+// keys are used on the token and never read, so AES, 2-key and 3-key 3DES
+// keys may be created non-extractable.
+type HSM struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+
+	// A session runs one operation at a time
+	mutex sync.Mutex
+}
+
+// Open loads the module and logs in to the token
+func Open(cfg Config) (*HSM, error) {
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %v", err)
+	}
+	h := &HSM{ctx: ctx}
+	slot, err := h.findSlot(cfg.Token)
+	if err != nil {
+		h.finalize()
+		return nil, err
+	}
+	h.session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		h.finalize()
+		return nil, fmt.Errorf("failed to open PKCS#11 session: %v", err)
+	}
+	if err := ctx.Login(h.session, pkcs11.CKU_USER, cfg.PIN); err != nil {
+		ctx.CloseSession(h.session)
+		h.finalize()
+		return nil, fmt.Errorf("failed to log in to token: %v", err)
+	}
+	return h, nil
+}
+
+func (h *HSM) findSlot(token string) (uint, error) {
+	slots, err := h.ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots: %v", err)
+	}
+	for _, slot := range slots {
+		info, err := h.ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if token == "" || strings.TrimSpace(info.Label) == token {
+			return slot, nil
+		}
+	}
+	if token == "" {
+		return 0, fmt.Errorf("no PKCS#11 token present")
+	}
+	return 0, fmt.Errorf("PKCS#11 token %q not found", token)
+}
+
+func (h *HSM) finalize() {
+	h.ctx.Finalize()
+	h.ctx.Destroy()
+}
+
+// Close logs out and unloads the module
+func (h *HSM) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.ctx.Logout(h.session)
+	err := h.ctx.CloseSession(h.session)
+	h.finalize()
+	if err != nil {
+		return fmt.Errorf("failed to close PKCS#11 session: %v", err)
+	}
+	return nil
+}
+
+// Cipher returns the cipher of the secret key with the label
+func (h *HSM) Cipher(label string) (keys.Cipher, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := h.ctx.FindObjectsInit(h.session, template); err != nil {
+		return nil, fmt.Errorf("failed to search keys: %v", err)
+	}
+	objects, _, err := h.ctx.FindObjects(h.session, 2)
+	h.ctx.FindObjectsFinal(h.session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search keys: %v", err)
+	}
+	switch len(objects) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s on token", keys.ErrNotFound, label)
+	case 1:
+	default:
+		return nil, fmt.Errorf("more than one key labeled %s", label)
+	}
+
+	attrs, err := h.ctx.GetAttributeValue(h.session, objects[0], []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil || len(attrs) != 1 {
+		return nil, fmt.Errorf("failed to read type of key %s: %v", label, err)
+	}
+	c := &hsmCipher{hsm: h, key: objects[0]}
+	switch {
+	case isKeyType(attrs[0], pkcs11.CKK_AES):
+		c.mechanism, c.blockSize = pkcs11.CKM_AES_CBC, 16
+	case isKeyType(attrs[0], pkcs11.CKK_DES2), isKeyType(attrs[0], pkcs11.CKK_DES3):
+		c.mechanism, c.blockSize = pkcs11.CKM_DES3_CBC, 8
+	default:
+		return nil, fmt.Errorf("key %s is neither AES nor 3DES", label)
+	}
+	return c, nil
+}
+
+func isKeyType(attr *pkcs11.Attribute, keyType uint) bool {
+	return bytes.Equal(attr.Value, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType).Value)
+}
+
+type hsmCipher struct {
+	hsm       *HSM
+	key       pkcs11.ObjectHandle
+	mechanism uint
+	blockSize int
+}
+
+func (c *hsmCipher) BlockSize() int {
+	return c.blockSize
+}
+
+func (c *hsmCipher) EncryptCBC(iv []byte, data []byte) ([]byte, error) {
+	if len(data)%c.blockSize != 0 || len(iv) != c.blockSize {
+		return nil, fmt.Errorf("data is not aligned to the block size")
+	}
+	c.hsm.mutex.Lock()
+	defer c.hsm.mutex.Unlock()
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(c.mechanism, iv)}
+	if err := c.hsm.ctx.EncryptInit(c.hsm.session, mechanism, c.key); err != nil {
+		return nil, fmt.Errorf("failed to encrypt on token: %v", err)
+	}
+	out, err := c.hsm.ctx.Encrypt(c.hsm.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt on token: %v", err)
+	}
+	return out, nil
+}
+
+func (c *hsmCipher) DecryptCBC(iv []byte, data []byte) ([]byte, error) {
+	if len(data)%c.blockSize != 0 || len(iv) != c.blockSize {
+		return nil, fmt.Errorf("data is not aligned to the block size")
+	}
+	c.hsm.mutex.Lock()
+	defer c.hsm.mutex.Unlock()
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(c.mechanism, iv)}
+	if err := c.hsm.ctx.DecryptInit(c.hsm.session, mechanism, c.key); err != nil {
+		return nil, fmt.Errorf("failed to decrypt on token: %v", err)
+	}
+	out, err := c.hsm.ctx.Decrypt(c.hsm.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt on token: %v", err)
+	}
+	return out, nil
+}
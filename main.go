@@ -1,18 +1,40 @@
 package main
 
 import (
-	"encoding/hex"
-	"fmt"
-	"log"
+	"context"
+	"flag"
+	"log/slog"
 	"os"
+	"os/signal"
 
 	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/personalize"
+	"github.com/oo-developer/acr122u/uid"
 )
 
 func main() {
+	debug := flag.Bool("debug", false, "log every APDU")
+	templatePath := flag.String("template", "", "personalize every card with this template (YAML)")
+	flag.Parse()
+	level := slog.LevelInfo
+	if *debug {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	var engine *personalize.Engine
+	if *templatePath != "" {
+		template, err := personalize.Load(*templatePath)
+		if err != nil {
+			slog.Error("failed to load template", "error", err)
+			os.Exit(1)
+		}
+		engine = personalize.NewEngine(template, nil)
+	}
+
 	reader, err := hardware.NewReader()
 	if err != nil {
-		fmt.Printf("[ERROR] Failed to create hardware: %v\n", err)
+		slog.Error("failed to create reader", "error", err)
 		os.Exit(1)
 	}
 	defer reader.Close()
@@ -20,37 +42,44 @@ func main() {
 	// List available readers
 	readers, err := reader.ListReaders()
 	if err != nil {
-		log.Printf("[ERROR] Failed to list readers: %v\n", err)
+		slog.Error("failed to list readers", "error", err)
 		os.Exit(1)
 	}
-
-	fmt.Println("[OK] Available readers:")
 	if len(readers) == 0 {
-		fmt.Println("[ERROR] No readers detected")
+		slog.Error("no readers detected")
 		os.Exit(1)
 	}
-	for i, r := range readers {
-		fmt.Printf("     %d: %s\n", i, r)
-	}
+	slog.Info("available readers", "readers", readers)
 	reader.UseReader(readers[0])
+	reader.SetHotplug(true)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 	for {
-		fmt.Println("[OK] Waiting for card ...")
-		err = reader.WaitForCard()
+		slog.Info("waiting for card", "reader", readers[0])
+		err = reader.WaitForCardContext(ctx)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
-			fmt.Printf("[ERROR] Failed to wait for card: %v\n", err)
+			slog.Error("failed to wait for card", "error", err)
 			os.Exit(1)
 		}
-		fmt.Println("[OK] Card ready")
 
 		// Connect to card
-		fmt.Println("[OK] Connecting to card...")
-		if err := reader.Connect(); err != nil {
-			reader.Disconnect()
+		if err := reader.ConnectContext(ctx); err != nil {
+			slog.Warn("failed to connect", "error", err)
+			continue
+		}
+		info := reader.CardInfo()
+		slog.Info("card connected", "uid", uid.Hex(info.UID), "type", info.Type)
+		if engine != nil {
+			if err := engine.Personalize(ctx, reader, 0); err != nil {
+				slog.Warn("failed to personalize card", "error", err)
+			} else {
+				slog.Info("card personalized", "template", engine.Name())
+			}
 		}
-		fmt.Println("[OK] Connected!")
-		fmt.Printf("[OK] Card UID : %s\n", hex.EncodeToString(reader.CardInfo().UID))
-		fmt.Printf("[OK] Card type: %s\n", reader.CardInfo().Type)
 
 		reader.Disconnect()
 	}
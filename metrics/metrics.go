@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/oo-developer/acr122u/daemon"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the metrics of this module and the Go runtime and process
+// collectors. Register own metrics here to serve them on the same endpoint.
+var Registry = prometheus.NewRegistry()
+
+var (
+	scans = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acr122u_scans_total",
+		Help: "Cards scanned by card type.",
+	}, []string{"type"})
+	apdus = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "acr122u_apdus_total",
+		Help: "APDUs sent through instrumented transports.",
+	})
+	apduErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acr122u_apdu_errors_total",
+		Help: "Failed APDUs by status word, \"transmit\" if no response was received.",
+	}, []string{"sw"})
+	apduDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "acr122u_apdu_duration_seconds",
+		Help:    "APDU round trip time.",
+		Buckets: []float64{.002, .005, .01, .025, .05, .1, .25, .5, 1},
+	})
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acr122u_auth_failures_total",
+		Help: "Failed card authentications by card family.",
+	}, []string{"card"})
+	dumpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "acr122u_dump_duration_seconds",
+		Help:    "Duration of full memory dumps by card family.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"card"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		scans, apdus, apduErrors, apduDuration, authFailures, dumpDuration,
+	)
+}
+
+// ObserveScan counts a scanned card
+func ObserveScan(info *hardware.CardInfo) {
+	scans.WithLabelValues(info.Type).Inc()
+}
+
+// ObserveAuthFailure counts a failed authentication, e.g. "classic"
+func ObserveAuthFailure(card string) {
+	authFailures.WithLabelValues(card).Inc()
+}
+
+// ObserveDump records the duration of a dump
+func ObserveDump(card string, d time.Duration) {
+	dumpDuration.WithLabelValues(card).Observe(d.Seconds())
+}
+
+// TimeDump starts timing a dump; call the returned function when it ends:
+//
+//	defer metrics.TimeDump("ntag")()
+func TimeDump(card string) func() {
+	start := time.Now()
+	return func() { ObserveDump(card, time.Since(start)) }
+}
+
+// RegisterDaemon exports the reconnect count and state of the daemon
+func RegisterDaemon(d *daemon.Daemon) error {
+	reconnects := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "acr122u_reader_reconnects_total",
+		Help: "Reconnects to pcscd and the reader.",
+	}, func() float64 { return float64(d.Status().Reconnects) })
+	up := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "acr122u_reader_up",
+		Help: "1 if the daemon is connected to the reader.",
+	}, func() float64 {
+		if d.Status().State == "running" {
+			return 1
+		}
+		return 0
+	})
+	for _, c := range []prometheus.Collector{reconnects, up} {
+		if err := Registry.Register(c); err != nil {
+			return fmt.Errorf("failed to register daemon metrics: %v", err)
+		}
+	}
+	return nil
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ListenAndServe serves /metrics on the address, e.g. ":9122"
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
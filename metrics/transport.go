@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// Transport counts the APDUs of a transport, their failures by status word
+// and failed authentications it recognizes. It is as safe for concurrent use
+// as the transport it wraps.
+type Transport struct {
+	transport hardware.Transport
+}
+
+// NewTransport instruments a transport, e.g. reader.Transport(). Card modules
+// accept it through their NewXWithTransport constructors.
+func NewTransport(transport hardware.Transport) *Transport {
+	return &Transport{transport: transport}
+}
+
+// Middleware instruments the transports of a reader:
+//
+//	reader.Use(metrics.Middleware)
+func Middleware(next hardware.Transport) hardware.Transport {
+	return NewTransport(next)
+}
+
+func (t *Transport) Transmit(cmd []byte) ([]byte, error) {
+	start := time.Now()
+	rsp, err := t.transport.Transmit(cmd)
+	apduDuration.Observe(time.Since(start).Seconds())
+	apdus.Inc()
+	if err != nil {
+		apduErrors.WithLabelValues("transmit").Inc()
+		return rsp, err
+	}
+	if len(rsp) < 2 {
+		return rsp, nil
+	}
+	sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]
+	if failed(sw1, sw2) {
+		apduErrors.WithLabelValues(fmt.Sprintf("%02X%02X", sw1, sw2)).Inc()
+		if card := authCommand(cmd); card != "" {
+			authFailures.WithLabelValues(card).Inc()
+		}
+	}
+	return rsp, nil
+}
+
+// failed reports error status words. 61xx announces more data; DESFire
+// answers 9100 for success and 91AF for additional frames.
+func failed(sw1, sw2 byte) bool {
+	switch {
+	case sw1 == 0x90 && sw2 == 0x00, sw1 == 0x61:
+		return false
+	case sw1 == 0x91 && (sw2 == 0x00 || sw2 == 0xAF):
+		return false
+	}
+	return true
+}
+
+// authCommand returns the card family of authentication commands
+func authCommand(cmd []byte) string {
+	switch {
+	case len(cmd) >= 2 && cmd[0] == 0xFF && cmd[1] == 0x86:
+		return "classic"
+	case len(cmd) >= 2 && cmd[0] == 0x90 && (cmd[1] == 0x0A || cmd[1] == 0x1A || cmd[1] == 0xAA || cmd[1] == 0x71 || cmd[1] == 0xAF):
+		return "desfire"
+	case len(cmd) >= 2 && cmd[0] == 0x00 && cmd[1] == 0x82:
+		return "iso7816"
+	}
+	return ""
+}
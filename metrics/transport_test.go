@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+)
+
+// counter returns the value of a counter of the registry, summed over its
+// series with the label value, or over all series if label is empty
+func counter(t *testing.T, name string, label string) float64 {
+	t.Helper()
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var sum float64
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			match := label == ""
+			for _, l := range m.GetLabel() {
+				match = match || l.GetValue() == label
+			}
+			if match {
+				sum += m.GetCounter().GetValue()
+			}
+		}
+	}
+	return sum
+}
+
+// card answers every command with a fixed response
+type card []byte
+
+func (c card) Transmit(cmd []byte) ([]byte, error) {
+	return c, nil
+}
+
+func TestTransportConcurrent(t *testing.T) {
+	apdusBefore := counter(t, "acr122u_apdus_total", "")
+	failuresBefore := counter(t, "acr122u_auth_failures_total", "classic")
+	ok := NewTransport(card{0x90, 0x00})
+	refused := NewTransport(card{0x63, 0x00})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				ok.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+				refused.Transmit([]byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, 0x04, 0x60, 0x00})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := counter(t, "acr122u_apdus_total", "") - apdusBefore; n != 1600 {
+		t.Fatalf("counted %v APDUs, want 1600", n)
+	}
+	if n := counter(t, "acr122u_auth_failures_total", "classic") - failuresBefore; n != 800 {
+		t.Fatalf("counted %v failed authentications, want 800", n)
+	}
+}
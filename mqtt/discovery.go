@@ -0,0 +1,60 @@
+package mqtt
+
+// Home Assistant MQTT discovery, see
+// https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery
+
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// tagConfig makes Home Assistant fire a tag scanned event per scan, which
+// automations trigger on
+type tagConfig struct {
+	Topic         string `json:"topic"`
+	ValueTemplate string `json:"value_template"`
+	Device        device `json:"device"`
+}
+
+// sensorConfig shows the last scan with type and reader as attributes
+type sensorConfig struct {
+	Name                string `json:"name"`
+	UniqueID            string `json:"unique_id"`
+	StateTopic          string `json:"state_topic"`
+	ValueTemplate       string `json:"value_template"`
+	JSONAttributesTopic string `json:"json_attributes_topic"`
+	AvailabilityTopic   string `json:"availability_topic"`
+	Icon                string `json:"icon"`
+	Device              device `json:"device"`
+}
+
+// discoveryConfigs returns the configs by topic
+func (p *Publisher) discoveryConfigs() map[string]any {
+	id := p.config.DeviceID
+	dev := device{
+		Identifiers:  []string{"acr122u_" + id},
+		Name:         p.config.DeviceName,
+		Manufacturer: "ACS",
+		Model:        "ACR122U",
+	}
+	prefix := p.config.DiscoveryPrefix
+	return map[string]any{
+		prefix + "/tag/" + id + "/config": tagConfig{
+			Topic:         p.config.Topic,
+			ValueTemplate: "{{ value_json.uid }}",
+			Device:        dev,
+		},
+		prefix + "/sensor/" + id + "/last_scan/config": sensorConfig{
+			Name:                "Last scan",
+			UniqueID:            "acr122u_" + id + "_last_scan",
+			StateTopic:          p.config.Topic,
+			ValueTemplate:       "{{ value_json.uid }}",
+			JSONAttributesTopic: p.config.Topic,
+			AvailabilityTopic:   p.AvailabilityTopic(),
+			Icon:                "mdi:nfc-variant",
+			Device:              dev,
+		},
+	}
+}
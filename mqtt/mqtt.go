@@ -0,0 +1,176 @@
+package mqtt
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// Config configures the broker connection and topics
+type Config struct {
+	Broker   string // e.g. tcp://localhost:1883
+	ClientID string // acr122u-<DeviceID> if empty
+	Username string
+	Password string
+
+	// Topic receives the scan events, acr122u/<DeviceID>/scan if empty
+	Topic string
+	QoS   byte
+
+	// DeviceID identifies the reader in topics and Home Assistant, acr122u if
+	// empty
+	DeviceID   string
+	DeviceName string
+
+	// Discovery publishes Home Assistant discovery configs under
+	// DiscoveryPrefix (homeassistant if empty) on every connect
+	Discovery       bool
+	DiscoveryPrefix string
+
+	ConnectTimeout time.Duration // 10s if zero
+}
+
+// ScanEvent is the JSON payload of a card scan
+type ScanEvent struct {
+	UID       string    `json:"uid"`
+	Type      string    `json:"type"`
+	Reader    string    `json:"reader"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewScanEvent creates the event of the connected card with the UID in upper
+// case hex
+func NewScanEvent(reader *hardware.Reader) ScanEvent {
+	info := reader.CardInfo()
+	return ScanEvent{
+		UID:       strings.ToUpper(hex.EncodeToString(info.UID)),
+		Type:      info.Type,
+		Reader:    reader.Reader(),
+		Timestamp: time.Now(),
+	}
+}
+
+// Publisher sends scan events to an MQTT broker. The availability topic is
+// set to online while connected and to offline by the broker's last will.
+type Publisher struct {
+	client paho.Client
+	config Config
+}
+
+// NewPublisher connects to the broker. The client reconnects on its own after
+// connection losses.
+func NewPublisher(config Config) (*Publisher, error) {
+	if config.Broker == "" {
+		return nil, fmt.Errorf("no broker configured")
+	}
+	if config.QoS > 2 {
+		return nil, fmt.Errorf("invalid QoS %d", config.QoS)
+	}
+	if config.DeviceID == "" {
+		config.DeviceID = "acr122u"
+	}
+	if config.DeviceName == "" {
+		config.DeviceName = "ACR122U " + config.DeviceID
+	}
+	if config.ClientID == "" {
+		config.ClientID = "acr122u-" + config.DeviceID
+	}
+	if config.Topic == "" {
+		config.Topic = "acr122u/" + config.DeviceID + "/scan"
+	}
+	if config.DiscoveryPrefix == "" {
+		config.DiscoveryPrefix = "homeassistant"
+	}
+	if config.ConnectTimeout == 0 {
+		config.ConnectTimeout = 10 * time.Second
+	}
+
+	p := &Publisher{config: config}
+	opts := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(config.ConnectTimeout).
+		SetWill(p.AvailabilityTopic(), "offline", 1, true).
+		SetOnConnectHandler(func(paho.Client) {
+			// Errors are seen again on the next connect
+			_ = p.announce()
+		})
+	p.client = paho.NewClient(opts)
+	token := p.client.Connect()
+	if !token.WaitTimeout(config.ConnectTimeout) {
+		return nil, fmt.Errorf("failed to connect to %s: timeout", config.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", config.Broker, err)
+	}
+	return p, nil
+}
+
+// Topic returns the topic of the scan events
+func (p *Publisher) Topic() string {
+	return p.config.Topic
+}
+
+// AvailabilityTopic returns the topic with the online/offline state
+func (p *Publisher) AvailabilityTopic() string {
+	return "acr122u/" + p.config.DeviceID + "/availability"
+}
+
+// Publish sends the scan of the connected card
+func (p *Publisher) Publish(reader *hardware.Reader) error {
+	return p.PublishEvent(NewScanEvent(reader))
+}
+
+// PublishEvent sends a scan event
+func (p *Publisher) PublishEvent(event ScanEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	return p.publish(p.config.Topic, payload, false)
+}
+
+// Close marks the reader offline and disconnects
+func (p *Publisher) Close() {
+	_ = p.publish(p.AvailabilityTopic(), []byte("offline"), true)
+	p.client.Disconnect(250)
+}
+
+func (p *Publisher) publish(topic string, payload []byte, retained bool) error {
+	token := p.client.Publish(topic, p.config.QoS, retained, payload)
+	if !token.WaitTimeout(p.config.ConnectTimeout) {
+		return fmt.Errorf("failed to publish to %s: timeout", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %v", topic, err)
+	}
+	return nil
+}
+
+// announce publishes availability and, if enabled, the discovery configs
+func (p *Publisher) announce() error {
+	if err := p.publish(p.AvailabilityTopic(), []byte("online"), true); err != nil {
+		return err
+	}
+	if !p.config.Discovery {
+		return nil
+	}
+	for topic, config := range p.discoveryConfigs() {
+		payload, err := json.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("failed to encode discovery config: %v", err)
+		}
+		if err := p.publish(topic, payload, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package ndef
+
+import "fmt"
+
+// TypeAAR is the external type of Android Application Records
+var TypeAAR = []byte("android.com:pkg")
+
+// NewAARRecord creates an Android Application Record that launches (or
+// offers to install) the app with the given package name
+func NewAARRecord(pkg string) (Record, error) {
+	if pkg == "" {
+		return Record{}, fmt.Errorf("empty package name")
+	}
+	return Record{TNF: TNFExternal, Type: TypeAAR, Payload: []byte(pkg)}, nil
+}
+
+// AppendAAR adds an Android Application Record after the records of the
+// message. Android dispatches the first record as usual and starts the app of
+// the AAR, so the AAR goes last.
+func (m *Message) AppendAAR(pkg string) error {
+	r, err := NewAARRecord(pkg)
+	if err != nil {
+		return err
+	}
+	m.Records = append(m.Records, r)
+	return nil
+}
+
+// AndroidPackages returns the package names of all Android Application
+// Records in the message
+func (m *Message) AndroidPackages() []string {
+	var pkgs []string
+	for _, r := range m.Records {
+		if r.IsExternal(string(TypeAAR)) {
+			pkgs = append(pkgs, string(r.Payload))
+		}
+	}
+	return pkgs
+}
@@ -0,0 +1,213 @@
+package ndef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// MIME types of Bluetooth secure simple pairing OOB records
+const (
+	MIMETypeBluetooth   = "application/vnd.bluetooth.ep.oob"
+	MIMETypeBluetoothLE = "application/vnd.bluetooth.le.oob"
+)
+
+// EIR and AD data types
+const (
+	eirShortName     = 0x08
+	eirCompleteName  = 0x09
+	eirClassOfDevice = 0x0D
+	eirHashC         = 0x0E
+	eirRandomizerR   = 0x0F
+	adAppearance     = 0x19
+	adLEAddress      = 0x1B
+	adLERole         = 0x1C
+)
+
+// LE roles
+const (
+	BluetoothLERolePeripheral          = 0x00
+	BluetoothLERoleCentral             = 0x01
+	BluetoothLERolePeripheralPreferred = 0x02
+	BluetoothLERoleCentralPreferred    = 0x03
+)
+
+// BluetoothOOB is the out-of-band pairing data of a BR/EDR device such as a
+// speaker or headset
+type BluetoothOOB struct {
+	Address     []byte // 6 bytes, most significant first as displayed
+	Name        string
+	Class       uint32 // Class of Device, 0 omits it
+	HashC       []byte // Simple Pairing Hash C, optional
+	RandomizerR []byte // Simple Pairing Randomizer R, optional
+}
+
+// BluetoothLEOOB is the out-of-band pairing data of a Bluetooth LE device
+type BluetoothLEOOB struct {
+	Address       []byte // 6 bytes, most significant first as displayed
+	RandomAddress bool
+	Role          byte // BluetoothLERole*
+	Name          string
+	Appearance    uint16 // 0 omits it
+}
+
+// NewBluetoothRecord creates a BR/EDR OOB record
+func NewBluetoothRecord(oob BluetoothOOB) (Record, error) {
+	if len(oob.Address) != 6 {
+		return Record{}, fmt.Errorf("Bluetooth address must be 6 bytes")
+	}
+	var eir []byte
+	if oob.Class != 0 {
+		eir = appendEIR(eir, eirClassOfDevice, []byte{byte(oob.Class), byte(oob.Class >> 8), byte(oob.Class >> 16)})
+	}
+	if len(oob.HashC) > 0 {
+		eir = appendEIR(eir, eirHashC, oob.HashC)
+	}
+	if len(oob.RandomizerR) > 0 {
+		eir = appendEIR(eir, eirRandomizerR, oob.RandomizerR)
+	}
+	if oob.Name != "" {
+		eir = appendEIR(eir, eirCompleteName, []byte(oob.Name))
+	}
+
+	// OOB data length covers itself and the address
+	payload := binary.LittleEndian.AppendUint16(nil, uint16(2+6+len(eir)))
+	payload = append(payload, reverse(oob.Address)...)
+	payload = append(payload, eir...)
+	return NewMIMERecord(MIMETypeBluetooth, payload), nil
+}
+
+// Bluetooth decodes a BR/EDR OOB record
+func (r Record) Bluetooth() (*BluetoothOOB, error) {
+	if r.TNF != TNFMedia || !bytes.EqualFold(r.Type, []byte(MIMETypeBluetooth)) {
+		return nil, fmt.Errorf("not a Bluetooth OOB record")
+	}
+	if len(r.Payload) < 8 {
+		return nil, fmt.Errorf("Bluetooth OOB record truncated")
+	}
+	n := int(binary.LittleEndian.Uint16(r.Payload))
+	if n < 8 || n > len(r.Payload) {
+		return nil, fmt.Errorf("invalid Bluetooth OOB data length %d", n)
+	}
+	oob := &BluetoothOOB{Address: reverse(r.Payload[2:8])}
+	fields, err := parseEIR(r.Payload[8:n])
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		switch f.typ {
+		case eirShortName, eirCompleteName:
+			oob.Name = string(f.value)
+		case eirClassOfDevice:
+			if len(f.value) != 3 {
+				return nil, fmt.Errorf("invalid class of device")
+			}
+			oob.Class = uint32(f.value[0]) | uint32(f.value[1])<<8 | uint32(f.value[2])<<16
+		case eirHashC:
+			oob.HashC = f.value
+		case eirRandomizerR:
+			oob.RandomizerR = f.value
+		}
+	}
+	return oob, nil
+}
+
+// NewBluetoothLERecord creates a Bluetooth LE OOB record
+func NewBluetoothLERecord(oob BluetoothLEOOB) (Record, error) {
+	if len(oob.Address) != 6 {
+		return Record{}, fmt.Errorf("Bluetooth address must be 6 bytes")
+	}
+	address := reverse(oob.Address)
+	if oob.RandomAddress {
+		address = append(address, 0x01)
+	} else {
+		address = append(address, 0x00)
+	}
+	var payload []byte
+	payload = appendEIR(payload, adLEAddress, address)
+	payload = appendEIR(payload, adLERole, []byte{oob.Role})
+	if oob.Appearance != 0 {
+		payload = appendEIR(payload, adAppearance, binary.LittleEndian.AppendUint16(nil, oob.Appearance))
+	}
+	if oob.Name != "" {
+		payload = appendEIR(payload, eirCompleteName, []byte(oob.Name))
+	}
+	return NewMIMERecord(MIMETypeBluetoothLE, payload), nil
+}
+
+// BluetoothLE decodes a Bluetooth LE OOB record
+func (r Record) BluetoothLE() (*BluetoothLEOOB, error) {
+	if r.TNF != TNFMedia || !bytes.EqualFold(r.Type, []byte(MIMETypeBluetoothLE)) {
+		return nil, fmt.Errorf("not a Bluetooth LE OOB record")
+	}
+	fields, err := parseEIR(r.Payload)
+	if err != nil {
+		return nil, err
+	}
+	oob := &BluetoothLEOOB{}
+	for _, f := range fields {
+		switch f.typ {
+		case adLEAddress:
+			if len(f.value) != 7 {
+				return nil, fmt.Errorf("invalid LE device address")
+			}
+			oob.Address = reverse(f.value[:6])
+			oob.RandomAddress = f.value[6]&0x01 != 0
+		case adLERole:
+			if len(f.value) != 1 {
+				return nil, fmt.Errorf("invalid LE role")
+			}
+			oob.Role = f.value[0]
+		case adAppearance:
+			if len(f.value) != 2 {
+				return nil, fmt.Errorf("invalid appearance")
+			}
+			oob.Appearance = binary.LittleEndian.Uint16(f.value)
+		case eirShortName, eirCompleteName:
+			oob.Name = string(f.value)
+		}
+	}
+	if oob.Address == nil {
+		return nil, fmt.Errorf("Bluetooth LE OOB record without address")
+	}
+	return oob, nil
+}
+
+type eirField struct {
+	typ   byte
+	value []byte
+}
+
+// appendEIR appends an EIR/AD structure: length (of type and data), type and
+// data
+func appendEIR(b []byte, typ byte, value []byte) []byte {
+	b = append(b, byte(1+len(value)), typ)
+	return append(b, value...)
+}
+
+func parseEIR(b []byte) ([]eirField, error) {
+	var fields []eirField
+	for len(b) > 0 {
+		n := int(b[0])
+		if n == 0 {
+			// Zero length ends the significant part
+			break
+		}
+		if len(b) < 1+n {
+			return nil, fmt.Errorf("EIR structure truncated")
+		}
+		fields = append(fields, eirField{typ: b[1], value: append([]byte{}, b[2:1+n]...)})
+		b = b[1+n:]
+	}
+	return fields, nil
+}
+
+// reverse returns a reversed copy, Bluetooth addresses are sent least
+// significant byte first
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package ndef_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/ndef"
+)
+
+func TestBluetooth(t *testing.T) {
+	oob := ndef.BluetoothOOB{
+		Address: []byte{0x00, 0x0C, 0x78, 0x12, 0x34, 0x56},
+		Name:    "Spk",
+		Class:   0x240404,
+	}
+	r, err := ndef.NewBluetoothRecord(oob)
+	if err != nil {
+		t.Fatalf("NewBluetoothRecord: %v", err)
+	}
+	// OOB data length, address least significant byte first, class of
+	// device and complete local name
+	want := unhex(t, "1200 563412780C00 04 0D 040424 04 09 53706B")
+	if string(r.Type) != ndef.MIMETypeBluetooth || !bytes.Equal(r.Payload, want) {
+		t.Fatalf("record %s, want payload % X", r, want)
+	}
+	got, err := r.Bluetooth()
+	if err != nil {
+		t.Fatalf("Bluetooth: %v", err)
+	}
+	if !bytes.Equal(got.Address, oob.Address) || got.Name != oob.Name || got.Class != oob.Class {
+		t.Errorf("Bluetooth = %+v", got)
+	}
+
+	oob.HashC, oob.RandomizerR = bytes.Repeat([]byte{0x11}, 16), bytes.Repeat([]byte{0x22}, 16)
+	if r, err = ndef.NewBluetoothRecord(oob); err != nil {
+		t.Fatalf("NewBluetoothRecord: %v", err)
+	}
+	if got, err = r.Bluetooth(); err != nil || !bytes.Equal(got.HashC, oob.HashC) || !bytes.Equal(got.RandomizerR, oob.RandomizerR) {
+		t.Errorf("Bluetooth with pairing data = %+v, %v", got, err)
+	}
+
+	if _, err := ndef.NewBluetoothRecord(ndef.BluetoothOOB{Address: []byte{0x00}}); err == nil {
+		t.Errorf("NewBluetoothRecord accepted a 1-byte address")
+	}
+	for _, payload := range []string{
+		"0800 563412780C",                // truncated address
+		"0700 563412780C00",              // length below the address
+		"1000 563412780C00 04 09 53",     // length beyond the payload
+		"0C00 563412780C00 02 0D 0404",   // 1-byte class of device
+		"0C00 563412780C00 05 09 53706B", // truncated name
+	} {
+		r := ndef.NewMIMERecord(ndef.MIMETypeBluetooth, unhex(t, payload))
+		if oob, err := r.Bluetooth(); err == nil {
+			t.Errorf("Bluetooth of % X = %+v", r.Payload, oob)
+		}
+	}
+}
+
+func TestBluetoothLE(t *testing.T) {
+	oob := ndef.BluetoothLEOOB{
+		Address:       []byte{0xC0, 0x11, 0x22, 0x33, 0x44, 0x55},
+		RandomAddress: true,
+		Role:          ndef.BluetoothLERolePeripheral,
+		Name:          "K",
+		Appearance:    0x03C1,
+	}
+	r, err := ndef.NewBluetoothLERecord(oob)
+	if err != nil {
+		t.Fatalf("NewBluetoothLERecord: %v", err)
+	}
+	// LE device address with the random flag, role, appearance and name
+	want := unhex(t, "08 1B 554433 2211C0 01  02 1C 00  03 19 C103  02 09 4B")
+	if string(r.Type) != ndef.MIMETypeBluetoothLE || !bytes.Equal(r.Payload, want) {
+		t.Fatalf("record %s, want payload % X", r, want)
+	}
+	got, err := r.BluetoothLE()
+	if err != nil {
+		t.Fatalf("BluetoothLE: %v", err)
+	}
+	if !bytes.Equal(got.Address, oob.Address) || !got.RandomAddress || got.Role != oob.Role ||
+		got.Name != oob.Name || got.Appearance != oob.Appearance {
+		t.Errorf("BluetoothLE = %+v", got)
+	}
+
+	// A zero length ends the significant part
+	r = ndef.NewMIMERecord(ndef.MIMETypeBluetoothLE, unhex(t, "08 1B 554433 2211C0 00 02 1C 01 00 FFFF"))
+	if got, err = r.BluetoothLE(); err != nil || got.RandomAddress || got.Role != ndef.BluetoothLERoleCentral {
+		t.Errorf("BluetoothLE with padding = %+v, %v", got, err)
+	}
+	for _, payload := range []string{
+		"02 1C 00",                          // no address
+		"07 1B 554433 2211C0",               // 6-byte address
+		"08 1B 554433 2211C0 00 03 1C 0000", // 2-byte role
+		"08 1B 554433",                      // truncated
+	} {
+		r := ndef.NewMIMERecord(ndef.MIMETypeBluetoothLE, unhex(t, payload))
+		if oob, err := r.BluetoothLE(); err == nil {
+			t.Errorf("BluetoothLE of % X = %+v", r.Payload, oob)
+		}
+	}
+}
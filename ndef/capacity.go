@@ -0,0 +1,100 @@
+package ndef
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// Data areas of the tags whose size the card detection does not report
+const (
+	ultralightDataArea = 48  // pages 4-15
+	feliCaLiteDataArea = 208 // 13 blocks of FeliCa Lite-S
+	type4MaxFileSize   = 0x7FFF
+	type4Reserved      = 256 // application, CC file and allocation overhead
+)
+
+// CapacityFor returns the largest NDEF message in bytes that fits on the
+// detected card once formatted by this package. The capability container,
+// TLV overhead and MAD sectors are accounted for; lock control TLVs of
+// Ultralight C tags take another 5 bytes.
+func CapacityFor(info *hardware.CardInfo) (int, error) {
+	switch DetectTagType(info) {
+	case TagType2:
+		area := info.Capacity
+		if area == 0 {
+			area = ultralightDataArea
+		}
+		return tlvCapacity(area), nil
+	case TagClassic:
+		area := 0
+		for _, sector := range classicNDEFSectors(info) {
+			area += 16 * sectorDataBlocks(sector)
+		}
+		return tlvCapacity(area), nil
+	case TagType4:
+		return type4FileSize(info.Capacity) - 2, nil
+	case TagType3:
+		return feliCaLiteDataArea, nil
+	default:
+		return 0, fmt.Errorf("no NDEF mapping for card type %q", info.Type)
+	}
+}
+
+// FitsOn returns a CapacityError if the message is too large for the card
+func (m *Message) FitsOn(info *hardware.CardInfo) error {
+	capacity, err := CapacityFor(info)
+	if err != nil {
+		return err
+	}
+	if size := m.Len(); size > capacity {
+		return &CapacityError{Size: size, Capacity: capacity}
+	}
+	return nil
+}
+
+// tlvCapacity returns the largest message an NDEF TLV in an area of the
+// given size holds. The terminator TLV is optional when the NDEF TLV fills
+// the area.
+func tlvCapacity(area int) int {
+	switch {
+	case area-2 < 0xFF:
+		return max(area-2, 0)
+	case area-4 < 0xFF:
+		return 0xFE
+	default:
+		return min(area-4, 0xFFFE)
+	}
+}
+
+// classicNDEFSectors returns the sectors a formatted Classic card assigns to
+// NDEF: all but the MAD sectors 0 and 16
+func classicNDEFSectors(info *hardware.CardInfo) []int {
+	count := 16
+	switch {
+	case strings.HasPrefix(info.Type, hardware.MIFARE_MINI):
+		count = 5
+	case strings.HasPrefix(info.Type, hardware.MIFARE_PLUS_SE_2K):
+		count = 32
+	case is4K(info):
+		count = 40
+	}
+	var sectors []int
+	for sector := 1; sector < count; sector++ {
+		if sector != 16 {
+			sectors = append(sectors, sector)
+		}
+	}
+	return sectors
+}
+
+// type4FileSize returns the size of the NDEF file created on a DESFire card
+// with the given storage size
+func type4FileSize(storage int) int {
+	if storage <= type4Reserved {
+		storage = 2048
+	}
+	// DESFire allocates memory in blocks of 32 bytes
+	return min((storage-type4Reserved)/32*32, type4MaxFileSize)
+}
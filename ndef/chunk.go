@@ -0,0 +1,76 @@
+package ndef
+
+import "fmt"
+
+// ChunkRecord splits a record into a chunk sequence with payloads of at most
+// size bytes. The initial chunk carries type and ID, the following chunks use
+// TNFUnchanged. Records that fit are returned unchanged.
+func ChunkRecord(r Record, size int) ([]Record, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", size)
+	}
+	if r.Chunked || r.TNF == TNFUnchanged {
+		return nil, fmt.Errorf("record is already chunked")
+	}
+	if len(r.Payload) <= size {
+		return []Record{r}, nil
+	}
+
+	var chunks []Record
+	for pos := 0; pos < len(r.Payload); pos += size {
+		chunk := Record{
+			TNF:     TNFUnchanged,
+			Payload: r.Payload[pos:min(pos+size, len(r.Payload))],
+			Chunked: pos+size < len(r.Payload),
+		}
+		if pos == 0 {
+			chunk.TNF, chunk.Type, chunk.ID = r.TNF, r.Type, r.ID
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// Chunk returns a copy of the message with every record payload larger than
+// size split into chunks
+func (m *Message) Chunk(size int) (*Message, error) {
+	out := &Message{}
+	for i, r := range m.Records {
+		if r.Chunked || r.TNF == TNFUnchanged {
+			out.Records = append(out.Records, r)
+			continue
+		}
+		chunks, err := ChunkRecord(r, size)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		out.Records = append(out.Records, chunks...)
+	}
+	return out, nil
+}
+
+// Dechunk returns a copy of the message with each chunk sequence joined into
+// a single record, so the record helpers see the whole payload
+func (m *Message) Dechunk() (*Message, error) {
+	out := &Message{}
+	inChunk := false
+	for i, r := range m.Records {
+		if err := checkChunk(r, inChunk); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		chunked := r.Chunked
+		if inChunk {
+			last := &out.Records[len(out.Records)-1]
+			last.Payload = append(last.Payload, r.Payload...)
+		} else {
+			r.Payload = append([]byte{}, r.Payload...)
+			r.Chunked = false
+			out.Records = append(out.Records, r)
+		}
+		inChunk = chunked
+	}
+	if inChunk {
+		return nil, fmt.Errorf("message ends within a chunked record")
+	}
+	return out, nil
+}
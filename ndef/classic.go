@@ -0,0 +1,209 @@
+package ndef
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// Public keys of the MIFARE Classic NDEF mapping
+var (
+	MADKeyA  = []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+	NDEFKeyA = []byte{0xD3, 0xF7, 0xD3, 0xF7, 0xD3, 0xF7}
+)
+
+// madNDEFAID is the MAD application ID of NDEF sectors (function cluster
+// E1, application code 03)
+var madNDEFAID = [2]byte{0x03, 0xE1}
+
+// sectorFirstBlock returns the first block of a sector. Sectors 32-39 of
+// 4K cards have 16 blocks.
+func sectorFirstBlock(sector int) int {
+	if sector < 32 {
+		return sector * 4
+	}
+	return 128 + (sector-32)*16
+}
+
+// sectorDataBlocks returns the number of data blocks (without trailer)
+func sectorDataBlocks(sector int) int {
+	if sector < 32 {
+		return 3
+	}
+	return 15
+}
+
+// readClassic returns the NDEF message of a MIFARE Classic tag from the
+// sectors the MAD assigns to NDEF
+func readClassic(reader *hardware.Reader) ([]byte, error) {
+	card := classic.NewClassic(reader)
+	sectors, err := ndefSectors(card, hasMAD2(reader.CardInfo()))
+	if err != nil {
+		return nil, err
+	}
+	if len(sectors) == 0 {
+		return nil, ErrNoNDEF
+	}
+
+	var data []byte
+	for _, sector := range sectors {
+		blocks, err := readSector(card, sector, NDEFKeyA)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, blocks...)
+	}
+	return findNDEFTLV(data)
+}
+
+func is4K(info *hardware.CardInfo) bool {
+	return strings.HasPrefix(info.Type, hardware.MIFARE_CLASSIK_4K) ||
+		strings.HasPrefix(info.Type, hardware.MIFARE_PLUS_SE_4K)
+}
+
+// hasMAD2 reports whether the card has more sectors than MAD version 1
+// covers
+func hasMAD2(info *hardware.CardInfo) bool {
+	return is4K(info) || strings.HasPrefix(info.Type, hardware.MIFARE_PLUS_SE_2K)
+}
+
+// ndefSectors reads the MAD and returns the NDEF sectors in order. Cards
+// with MAD version 2 have a second directory in sector 16.
+func ndefSectors(card *classic.Classic, mad2 bool) ([]int, error) {
+	mad, err := readSector(card, 0, MADKeyA)
+	if err != nil {
+		return nil, fmt.Errorf("no MAD: %w", err)
+	}
+	// Block 0 is the manufacturer block
+	sectors := madSectors(mad[16:], 1, 16)
+
+	if !mad2 {
+		return sectors, nil
+	}
+	trailer, err := card.ReadBlock(3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MAD sector trailer: %w", err)
+	}
+	// General purpose byte: MAD version in bits 0-1
+	if trailer[9]&0x03 != 0x02 {
+		return sectors, nil
+	}
+	dir, err := readSector(card, 16, MADKeyA)
+	if err != nil {
+		return nil, fmt.Errorf("no MAD2: %w", err)
+	}
+	return append(sectors, madSectors(dir, 17, 40)...), nil
+}
+
+// madSectors returns the NDEF sectors of a MAD directory (CRC, info byte and
+// AIDs) covering the sectors from first to end
+func madSectors(dir []byte, first int, end int) []int {
+	var sectors []int
+	for sector := first; sector < end; sector++ {
+		i := 2 * (sector - first + 1)
+		if i+1 < len(dir) && dir[i] == madNDEFAID[0] && dir[i+1] == madNDEFAID[1] {
+			sectors = append(sectors, sector)
+		}
+	}
+	return sectors
+}
+
+// readSector authenticates a sector with key A and returns its data blocks
+func readSector(card *classic.Classic, sector int, keyA []byte) ([]byte, error) {
+	first := sectorFirstBlock(sector)
+	if err := card.LoadKey(0x00, keyA); err != nil {
+		return nil, err
+	}
+	if err := card.Authenticate(byte(first), classic.KeyTypeA, 0x00); err != nil {
+		return nil, fmt.Errorf("sector %d: %w", sector, err)
+	}
+	var data []byte
+	for i := 0; i < sectorDataBlocks(sector); i++ {
+		block, err := card.ReadBlock(byte(first + i))
+		if err != nil {
+			return nil, fmt.Errorf("sector %d: %w", sector, err)
+		}
+		data = append(data, block...)
+	}
+	return data, nil
+}
+
+// writeClassic stores the message in the NDEF sectors listed in the MAD.
+// The first sector, holding the start of the NDEF TLV, is written last.
+func writeClassic(reader *hardware.Reader, raw []byte, s *hardware.Tracker) error {
+	card := classic.NewClassic(reader)
+	sectors, err := ndefSectors(card, hasMAD2(reader.CardInfo()))
+	if err != nil {
+		return err
+	}
+	if len(sectors) == 0 {
+		return ErrNoNDEF
+	}
+
+	capacity := 0
+	for _, sector := range sectors {
+		capacity += 16 * sectorDataBlocks(sector)
+	}
+	tlv, err := ndefTLV(raw, capacity)
+	if err != nil {
+		return err
+	}
+	area := make([]byte, capacity)
+	copy(area, tlv)
+
+	// Split the area into sectors, skipping those beyond the TLV
+	type sectorData struct {
+		sector int
+		data   []byte
+	}
+	var writes []sectorData
+	for _, sector := range sectors {
+		n := 16 * sectorDataBlocks(sector)
+		writes = append(writes, sectorData{sector, area[:n]})
+		area = area[n:]
+		if capacity-len(area) >= len(tlv) {
+			break
+		}
+	}
+	writes = append(writes[1:], writes[0])
+	s.Begin(capacity - len(area))
+	for _, w := range writes {
+		if err := s.Next(); err != nil {
+			return err
+		}
+		if err := writeSector(card, w.sector, w.data); err != nil {
+			return err
+		}
+		s.Advance(len(w.data))
+	}
+	return nil
+}
+
+// writeSector authenticates an NDEF sector with key A and writes its data
+// blocks. Sectors whose general purpose byte denies write access are
+// read-only.
+func writeSector(card *classic.Classic, sector int, data []byte) error {
+	first := sectorFirstBlock(sector)
+	trailerBlock := first + sectorDataBlocks(sector)
+	if err := card.LoadKey(0x00, NDEFKeyA); err != nil {
+		return err
+	}
+	if err := card.Authenticate(byte(first), classic.KeyTypeA, 0x00); err != nil {
+		return fmt.Errorf("sector %d: %w", sector, err)
+	}
+	trailer, err := card.ReadBlock(byte(trailerBlock))
+	if err != nil {
+		return fmt.Errorf("sector %d: %w", sector, err)
+	}
+	if trailer[9]&0x03 != 0 {
+		return ErrReadOnly
+	}
+	for i := 0; i < sectorDataBlocks(sector); i++ {
+		if err := card.WriteBlock(byte(first+i), data[16*i:16*i+16]); err != nil {
+			return fmt.Errorf("sector %d: %w", sector, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package ndef
+
+import "fmt"
+
+// Sizes of full MIFARE Classic memory dumps
+var classicDumpSectors = map[int]int{
+	320:  5,  // Mini
+	1024: 16, // 1K
+	2048: 32, // Plus 2K
+	4096: 40, // 4K
+}
+
+// FromDump extracts the NDEF message from a memory image captured earlier,
+// without a card present. Dumps of Classic cards (all blocks including
+// trailers, e.g. 1024 bytes for a 1K card) are read through their MAD,
+// dumps of Type 2 tags (all pages from page 0, e.g. from NTAG.DumpMemory)
+// through their capability container.
+func FromDump(dump []byte) (*Message, error) {
+	raw, err := dumpNDEF(dump)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return &Message{}, nil
+	}
+	return Parse(raw)
+}
+
+func dumpNDEF(dump []byte) ([]byte, error) {
+	if count, ok := classicDumpSectors[len(dump)]; ok {
+		return classicDumpNDEF(dump, count)
+	}
+	if len(dump) >= 4*dataAreaStart && dump[4*ccPage] == ccMagic {
+		return type2DumpNDEF(dump)
+	}
+	return nil, fmt.Errorf("unknown dump layout of %d bytes: %w", len(dump), ErrNoNDEF)
+}
+
+// classicDumpNDEF collects the NDEF sectors listed in the MAD of a Classic
+// dump
+func classicDumpNDEF(dump []byte, count int) ([]byte, error) {
+	// General purpose byte of sector 0: DA bit and MAD version
+	gpb := dump[3*16+9]
+	if gpb&0x80 == 0 {
+		return nil, ErrNoNDEF
+	}
+	sectors := madSectors(dump[16:48], 1, min(count, 16))
+	if gpb&0x03 == 0x02 && count > 16 {
+		first := sectorFirstBlock(16) * 16
+		sectors = append(sectors, madSectors(dump[first:first+48], 17, count)...)
+	}
+	if len(sectors) == 0 {
+		return nil, ErrNoNDEF
+	}
+
+	var data []byte
+	for _, sector := range sectors {
+		first := sectorFirstBlock(sector) * 16
+		data = append(data, dump[first:first+16*sectorDataBlocks(sector)]...)
+	}
+	return findNDEFTLV(data)
+}
+
+// type2DumpNDEF reads the data area given by the capability container of a
+// Type 2 dump. Dumps cut short by unreadable pages are accepted as long as
+// the NDEF TLV is complete.
+func type2DumpNDEF(dump []byte) ([]byte, error) {
+	size := int(dump[4*ccPage+2]) * 8
+	data := dump[4*dataAreaStart:]
+	if len(data) > size {
+		data = data[:size]
+	}
+	return findNDEFTLV(data)
+}
@@ -0,0 +1,111 @@
+package ndef
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ExternalURNPrefix is the URN form of NFC Forum external type names
+const ExternalURNPrefix = "urn:nfc:ext:"
+
+// ExternalDecoder decodes the payload of a registered external type
+type ExternalDecoder func(payload []byte) (any, error)
+
+var (
+	externalMu       sync.RWMutex
+	externalDecoders = map[string]ExternalDecoder{
+		string(TypeAAR): func(payload []byte) (any, error) { return string(payload), nil },
+	}
+)
+
+// ExternalType returns the normalized type name "domain:type" of an external
+// type. The URN prefix is optional; names compare case-insensitively and are
+// stored in lower case.
+func ExternalType(name string) (string, error) {
+	if len(name) >= len(ExternalURNPrefix) && strings.EqualFold(name[:len(ExternalURNPrefix)], ExternalURNPrefix) {
+		name = name[len(ExternalURNPrefix):]
+	}
+	domain, typ, ok := strings.Cut(name, ":")
+	if !ok || domain == "" || typ == "" {
+		return "", fmt.Errorf("external type %q must have the form domain:type", name)
+	}
+	for _, c := range name {
+		if c < 0x21 || c > 0x7E {
+			return "", fmt.Errorf("external type %q contains invalid characters", name)
+		}
+	}
+	if len(name) > 0xFF {
+		return "", fmt.Errorf("external type %q too long", name)
+	}
+	return strings.ToLower(name), nil
+}
+
+// NewExternalRecord creates a record of an external type, given as
+// "domain:type" or "urn:nfc:ext:domain:type"
+func NewExternalRecord(name string, payload []byte) (Record, error) {
+	typ, err := ExternalType(name)
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{TNF: TNFExternal, Type: []byte(typ), Payload: payload}, nil
+}
+
+// ExternalType returns the normalized external type of the record
+func (r Record) ExternalType() (string, error) {
+	if r.TNF != TNFExternal {
+		return "", fmt.Errorf("not an external type record")
+	}
+	return ExternalType(string(r.Type))
+}
+
+// IsExternal reports whether the record has the given external type
+func (r Record) IsExternal(name string) bool {
+	typ, err := ExternalType(name)
+	if err != nil {
+		return false
+	}
+	rt, err := r.ExternalType()
+	return err == nil && rt == typ
+}
+
+// RegisterExternalType registers the decoder of an external type. A later
+// registration replaces an earlier one.
+func RegisterExternalType(name string, decode ExternalDecoder) error {
+	typ, err := ExternalType(name)
+	if err != nil {
+		return err
+	}
+	externalMu.Lock()
+	defer externalMu.Unlock()
+	externalDecoders[typ] = decode
+	return nil
+}
+
+// DecodeExternal decodes the payload with the decoder registered for the
+// record's external type
+func (r Record) DecodeExternal() (any, error) {
+	typ, err := r.ExternalType()
+	if err != nil {
+		return nil, err
+	}
+	externalMu.RLock()
+	decode, ok := externalDecoders[typ]
+	externalMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no decoder registered for %s%s", ExternalURNPrefix, typ)
+	}
+	return decode(r.Payload)
+}
+
+// FindExternal returns the records of the message with the given external
+// type
+func (m *Message) FindExternal(name string) []Record {
+	var records []Record
+	for _, r := range m.Records {
+		if r.IsExternal(name) {
+			records = append(records, r)
+		}
+	}
+	return records
+}
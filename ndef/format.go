@@ -0,0 +1,249 @@
+package ndef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// Access bits of a Classic card formatted for NDEF: MAD sectors are
+// read-only with key A, NDEF sectors readable and writable with it
+var (
+	madAccess  = []byte{0x78, 0x77, 0x88}
+	ndefAccess = []byte{0x7F, 0x07, 0x88}
+	factoryKey = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+// ndefAID is the DESFire application holding the Type 4 NDEF files
+var ndefAID = []byte{0x01, 0x00, 0x00}
+
+var emptyNDEFTLV = []byte{TLVNDEF, 0x00, TLVTerminator}
+
+const (
+	ndefGPB       = 0x40 // NDEF mapping version 1.0, read/write access
+	type4AppFID   = 0xE110
+	type4CCFID    = 0xE103
+	type4NDEFFID  = 0xE104
+	feliCaMCBlock = 0x88
+)
+
+// Format initializes the NDEF structure of the connected tag and leaves an
+// empty message: capability container and TLVs on Type 2 tags, MAD and NDEF
+// sectors on Classic cards (which must still use the factory keys), the NDEF
+// application on DESFire and the attribute block on FeliCa Lite-S.
+func Format(reader *hardware.Reader) error {
+	switch tagType := DetectTagType(reader.CardInfo()); tagType {
+	case TagType2:
+		return formatType2(reader)
+	case TagClassic:
+		return formatClassic(reader)
+	case TagType4:
+		return formatType4(reader)
+	case TagType3:
+		return formatType3(reader)
+	default:
+		return fmt.Errorf("no NDEF mapping for card type %q", reader.CardInfo().Type)
+	}
+}
+
+// Erase replaces the message of a formatted tag with an empty one
+func Erase(reader *hardware.Reader) error {
+	return writeRaw(reader, nil, nil)
+}
+
+// formatType2 writes the capability container if the tag has none and an
+// empty NDEF TLV. The capability container is one-time programmable.
+func formatType2(reader *hardware.Reader) error {
+	tag := ntag.NewNTAG(reader)
+	cc, err := tag.ReadPage(ccPage)
+	if err != nil {
+		return fmt.Errorf("failed to read capability container: %w", err)
+	}
+	if cc[0] != ccMagic {
+		if !bytes.Equal(cc, make([]byte, 4)) {
+			return fmt.Errorf("capability container % X cannot be changed", cc)
+		}
+		area := reader.CardInfo().Capacity
+		if area == 0 {
+			area = ultralightDataArea
+		}
+		cc = []byte{ccMagic, 0x10, byte(area / 8), 0x00}
+		if err := tag.WritePage(ccPage, cc); err != nil {
+			return fmt.Errorf("failed to write capability container: %w", err)
+		}
+	}
+	return writeType2(reader, nil, nil)
+}
+
+// formatClassic assigns all sectors besides the MAD to NDEF. The NDEF
+// sectors are written first, the MAD last.
+func formatClassic(reader *hardware.Reader) error {
+	card := classic.NewClassic(reader)
+	info := reader.CardInfo()
+	sectors := classicNDEFSectors(info)
+
+	for i, sector := range sectors {
+		data := make([]byte, 16*sectorDataBlocks(sector))
+		if i == 0 {
+			copy(data, emptyNDEFTLV)
+		}
+		trailer := sectorTrailer(NDEFKeyA, ndefAccess, ndefGPB)
+		if err := formatSector(card, sector, data, trailer); err != nil {
+			return err
+		}
+	}
+
+	aids := make([]byte, 2*40)
+	for _, sector := range sectors {
+		copy(aids[2*sector:], madNDEFAID[:])
+	}
+	// MAD version 2 on cards with more than 16 sectors, with the second
+	// directory in sector 16
+	version := byte(1)
+	if hasMAD2(info) {
+		version = 2
+		mad2 := append([]byte{0x00, 0x00}, aids[2*17:]...)
+		mad2[0] = madCRC(mad2[1:])
+		if err := formatSector(card, 16, mad2, sectorTrailer(MADKeyA, madAccess, 0xC0|version)); err != nil {
+			return err
+		}
+	}
+	mad := make([]byte, 48)
+	copy(mad[18:48], aids[2:32])
+	mad[16] = madCRC(mad[17:48])
+	if err := formatSector(card, 0, mad[16:], sectorTrailer(MADKeyA, madAccess, 0xC0|version)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sectorTrailer builds a trailer with key B left at the factory value
+func sectorTrailer(keyA []byte, access []byte, gpb byte) []byte {
+	trailer := append([]byte{}, keyA...)
+	trailer = append(trailer, access...)
+	trailer = append(trailer, gpb)
+	return append(trailer, factoryKey...)
+}
+
+// formatSector authenticates with the factory key A and writes the data
+// blocks of a sector, starting at block 1 in sector 0, and then the trailer
+func formatSector(card *classic.Classic, sector int, data []byte, trailer []byte) error {
+	first := sectorFirstBlock(sector)
+	if err := card.LoadKey(0x00, factoryKey); err != nil {
+		return err
+	}
+	if err := card.Authenticate(byte(first), classic.KeyTypeA, 0x00); err != nil {
+		return fmt.Errorf("sector %d: %w", sector, err)
+	}
+	block := first
+	if sector == 0 {
+		block++ // manufacturer block
+	}
+	for ; len(data) > 0; block++ {
+		if err := card.WriteBlock(byte(block), data[:16]); err != nil {
+			return fmt.Errorf("sector %d: %w", sector, err)
+		}
+		data = data[16:]
+	}
+	if err := card.WriteBlock(byte(first+sectorDataBlocks(sector)), trailer); err != nil {
+		return fmt.Errorf("sector %d trailer: %w", sector, err)
+	}
+	return nil
+}
+
+// madCRC computes the CRC-8 (polynomial 0x1D, preset 0xC7) over the info byte
+// and the AIDs of a MAD
+func madCRC(data []byte) byte {
+	crc := byte(0xC7)
+	for _, b := range data {
+		crc ^= b
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x1D
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// formatType4 creates the NDEF application with capability container and
+// NDEF file on a DESFire card whose PICC master key allows free application
+// creation. An existing NDEF application is only erased.
+func formatType4(reader *hardware.Reader) error {
+	df := desfire.NewDESFire(reader)
+	if err := df.SelectApplication([]byte{0x00, 0x00, 0x00}); err != nil {
+		return err
+	}
+	aids, err := df.GetApplicationIDs()
+	if err != nil {
+		return err
+	}
+	for _, aid := range aids {
+		if bytes.Equal(aid, ndefAID) {
+			return writeType4(reader.Transport(), nil, nil)
+		}
+	}
+
+	if err := df.CreateISOApplication(ndefAID, 0x0F, 0x01, type4AppFID, NDEFApplicationName); err != nil {
+		return fmt.Errorf("failed to create NDEF application: %w", err)
+	}
+	if err := df.SelectApplication(ndefAID); err != nil {
+		return err
+	}
+	size := type4FileSize(reader.CardInfo().Capacity)
+	if err := df.CreateISOStdDataFile(1, type4CCFID, desfire.CommModePlain, desfire.FreeAccess, 15); err != nil {
+		return fmt.Errorf("failed to create capability container: %w", err)
+	}
+	if err := df.CreateISOStdDataFile(2, type4NDEFFID, desfire.CommModePlain, desfire.FreeAccess, size); err != nil {
+		return fmt.Errorf("failed to create NDEF file: %w", err)
+	}
+
+	// Mapping version 2.0, MLe 003B, MLc 0034, NDEF file control TLV
+	cc := []byte{0x00, 0x0F, 0x20, 0x00, 0x3B, 0x00, 0x34, 0x04, 0x06}
+	cc = binary.BigEndian.AppendUint16(cc, type4NDEFFID)
+	cc = binary.BigEndian.AppendUint16(cc, uint16(size))
+	cc = append(cc, 0x00, 0x00)
+	if err := df.WriteData(1, 0, cc); err != nil {
+		return fmt.Errorf("failed to write capability container: %w", err)
+	}
+	if err := df.WriteData(2, 0, []byte{0x00, 0x00}); err != nil {
+		return fmt.Errorf("failed to write NDEF length: %w", err)
+	}
+	return nil
+}
+
+// formatType3 enables NDEF in the memory configuration block of a FeliCa
+// Lite-S tag and writes an attribute block for an empty message
+func formatType3(reader *hardware.Reader) error {
+	tag, err := newType3Tag(reader)
+	if err != nil {
+		return err
+	}
+	attr := &Type3Attributes{
+		Version:   type3MappingVersion,
+		MaxRead:   4,
+		MaxWrite:  1,
+		MaxBlocks: feliCaLiteDataArea / type3BlockSize,
+		ReadWrite: true,
+	}
+	if err := tag.update(0, attr.Bytes()); err != nil {
+		return err
+	}
+	mc, err := tag.check(feliCaMCBlock, 1)
+	if err != nil {
+		return err
+	}
+	// SYS_OP: system code 12FC for NDEF
+	if mc[3] != 0x01 {
+		mc[3] = 0x01
+		return tag.update(feliCaMCBlock, mc)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package ndef
+
+import "testing"
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte{0xD1, 0x01, 0x0C, 0x55, 0x04, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm'})
+	f.Add([]byte{0xD1, 0x01, 0x05, 0x54, 0x02, 'e', 'n', 'h', 'i'})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m, err := Parse(b)
+		if err != nil {
+			return
+		}
+		_ = m.String()
+		m.Encode()
+		m.Dechunk()
+		m.AndroidPackages()
+		for _, r := range m.Records {
+			r.URI()
+			r.Text()
+			r.Bluetooth()
+			r.BluetoothLE()
+			r.ExternalType()
+			r.DecodeExternal()
+			r.Signature()
+			r.SmartPoster()
+			r.VCard()
+			r.WiFiCredentials()
+		}
+	})
+}
+
+func FuzzTLVs(f *testing.F) {
+	f.Add([]byte{0x03, 0x03, 0xD0, 0x00, 0x00, 0xFE})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ParseTLVs(b)
+	})
+}
+
+func FuzzFromDump(f *testing.F) {
+	f.Add(make([]byte, 540))
+	f.Add(make([]byte, 1024))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		FromDump(b)
+	})
+}
+
+func FuzzType3(f *testing.F) {
+	f.Add(make([]byte, 16))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ParseType3Attributes(b)
+	})
+}
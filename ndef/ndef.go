@@ -0,0 +1,201 @@
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Type name formats (TNF)
+const (
+	TNFEmpty       = 0x00
+	TNFWellKnown   = 0x01 // NFC Forum well-known type, e.g. "U" or "T"
+	TNFMedia       = 0x02 // MIME media type
+	TNFAbsoluteURI = 0x03
+	TNFExternal    = 0x04 // NFC Forum external type
+	TNFUnknown     = 0x05
+	TNFUnchanged   = 0x06 // middle and terminating chunks
+	TNFReserved    = 0x07
+)
+
+// Record header flags
+const (
+	FlagMB = 0x80 // message begin
+	FlagME = 0x40 // message end
+	FlagCF = 0x20 // chunk flag
+	FlagSR = 0x10 // short record, 1-byte payload length
+	FlagIL = 0x08 // ID length present
+)
+
+// Record is a single NDEF record. Chunked marks a record whose payload is
+// continued in the following record.
+type Record struct {
+	TNF     byte
+	Type    []byte
+	ID      []byte
+	Payload []byte
+	Chunked bool
+}
+
+// Message is a sequence of NDEF records
+type Message struct {
+	Records []Record
+}
+
+// Parse decodes raw NDEF bytes into a message and checks that it is well
+// formed: MB on the first and ME on the last record only, field lengths
+// matching the TNF and valid chunk sequences.
+func Parse(data []byte) (*Message, error) {
+	msg := &Message{}
+	pos := 0
+	inChunk := false
+	for {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("NDEF message truncated: no record with ME flag")
+		}
+		record, flags, n, err := parseRecord(data[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(msg.Records), err)
+		}
+		first := len(msg.Records) == 0
+		if first != (flags&FlagMB != 0) {
+			if first {
+				return nil, fmt.Errorf("record 0: MB flag not set")
+			}
+			return nil, fmt.Errorf("record %d: unexpected MB flag", len(msg.Records))
+		}
+		if err := checkChunk(record, inChunk); err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(msg.Records), err)
+		}
+		inChunk = record.Chunked
+		msg.Records = append(msg.Records, record)
+		pos += n
+
+		if flags&FlagME != 0 {
+			break
+		}
+	}
+	if inChunk {
+		return nil, fmt.Errorf("NDEF message ends within a chunked record")
+	}
+	if pos != len(data) {
+		return nil, fmt.Errorf("NDEF message followed by %d extra bytes", len(data)-pos)
+	}
+	return msg, nil
+}
+
+// parseRecord decodes the record at the start of data and returns it with
+// its header flags and encoded length
+func parseRecord(data []byte) (Record, byte, int, error) {
+	if len(data) < 3 {
+		return Record{}, 0, 0, fmt.Errorf("record header truncated")
+	}
+	flags := data[0]
+	record := Record{
+		TNF:     flags & 0x07,
+		Chunked: flags&FlagCF != 0,
+	}
+	typeLen := int(data[1])
+	pos := 2
+
+	var payloadLen int
+	if flags&FlagSR != 0 {
+		payloadLen = int(data[pos])
+		pos++
+	} else {
+		if len(data) < pos+4 {
+			return Record{}, 0, 0, fmt.Errorf("record header truncated")
+		}
+		length := binary.BigEndian.Uint32(data[pos:])
+		if uint64(length) > uint64(len(data)) {
+			return Record{}, 0, 0, fmt.Errorf("payload length %d exceeds the message", length)
+		}
+		payloadLen = int(length)
+		pos += 4
+	}
+
+	idLen := 0
+	if flags&FlagIL != 0 {
+		if len(data) < pos+1 {
+			return Record{}, 0, 0, fmt.Errorf("record header truncated")
+		}
+		idLen = int(data[pos])
+		pos++
+	}
+
+	if len(data) < pos+typeLen+idLen+payloadLen {
+		return Record{}, 0, 0, fmt.Errorf("record truncated")
+	}
+	record.Type = append([]byte{}, data[pos:pos+typeLen]...)
+	pos += typeLen
+	if flags&FlagIL != 0 {
+		record.ID = append([]byte{}, data[pos:pos+idLen]...)
+		pos += idLen
+	}
+	record.Payload = append([]byte{}, data[pos:pos+payloadLen]...)
+	pos += payloadLen
+
+	if err := record.check(); err != nil {
+		return Record{}, 0, 0, err
+	}
+	return record, flags, pos, nil
+}
+
+// check verifies the field lengths required by the TNF
+func (r *Record) check() error {
+	switch r.TNF {
+	case TNFEmpty:
+		if len(r.Type) != 0 || len(r.ID) != 0 || len(r.Payload) != 0 {
+			return fmt.Errorf("empty record with type, ID or payload")
+		}
+	case TNFWellKnown, TNFMedia, TNFAbsoluteURI, TNFExternal:
+		if len(r.Type) == 0 {
+			return fmt.Errorf("record without type")
+		}
+	case TNFUnknown, TNFUnchanged:
+		if len(r.Type) != 0 {
+			return fmt.Errorf("type not allowed for TNF %d", r.TNF)
+		}
+	case TNFReserved:
+		return fmt.Errorf("reserved TNF")
+	}
+	return nil
+}
+
+// checkChunk verifies a record against the chunk sequence it may be part of:
+// only the initial chunk carries type and ID, the following chunks use
+// TNFUnchanged
+func checkChunk(r Record, inChunk bool) error {
+	if !inChunk {
+		if r.TNF == TNFUnchanged {
+			return fmt.Errorf("TNF unchanged outside a chunked record")
+		}
+		return nil
+	}
+	if r.TNF != TNFUnchanged {
+		return fmt.Errorf("chunk without TNF unchanged")
+	}
+	if len(r.ID) != 0 {
+		return fmt.Errorf("chunk with ID")
+	}
+	return nil
+}
+
+func (r Record) String() string {
+	s := fmt.Sprintf("TNF=%d type=%q", r.TNF, r.Type)
+	if len(r.ID) > 0 {
+		s += fmt.Sprintf(" id=%q", r.ID)
+	}
+	if r.Chunked {
+		s += " chunked"
+	}
+	return s + fmt.Sprintf(" payload=% X", r.Payload)
+}
+
+func (m *Message) String() string {
+	lines := make([]string, len(m.Records))
+	for i, r := range m.Records {
+		lines[i] = fmt.Sprintf("[%d] %s", i, r)
+	}
+	return strings.Join(lines, "\n")
+}
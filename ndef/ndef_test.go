@@ -0,0 +1,184 @@
+package ndef_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/ndef"
+)
+
+// unhex decodes hex, ignoring spaces
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+func TestEncodeParse(t *testing.T) {
+	long := bytes.Repeat([]byte{0xAB}, 256)
+	for _, tc := range []struct {
+		name    string
+		records []ndef.Record
+		raw     string
+	}{
+		{
+			// URI RTD example: "http://www." abbreviated to 01
+			name:    "URI",
+			records: []ndef.Record{ndef.NewURIRecord("http://www.nfc.com")},
+			raw:     "D1 01 08 55 01 6E 66 63 2E 63 6F 6D",
+		},
+		{
+			name: "text",
+			records: []ndef.Record{{TNF: ndef.TNFWellKnown, Type: []byte("T"),
+				Payload: []byte("\x02enHello, world!")}},
+			raw: "D1 01 10 54 02 65 6E 48 65 6C 6C 6F 2C 20 77 6F 72 6C 64 21",
+		},
+		{
+			// Without SR the payload length takes 4 bytes
+			name:    "long record",
+			records: []ndef.Record{{TNF: ndef.TNFMedia, Type: []byte("a/b"), Payload: long}},
+			raw:     "C2 03 00000100 612F62" + strings.Repeat("AB", 256),
+		},
+		{
+			name:    "record with ID",
+			records: []ndef.Record{{TNF: ndef.TNFAbsoluteURI, Type: []byte("u:x"), ID: []byte("id"), Payload: []byte{0x01}}},
+			raw:     "DB 03 01 02 753A78 6964 01",
+		},
+		{
+			name: "three records",
+			records: []ndef.Record{
+				ndef.NewURIRecord("tel:+1"),
+				{TNF: ndef.TNFEmpty},
+				ndef.NewMIMERecord("text/plain", []byte("hi")),
+			},
+			raw: "91 01 03 55 05 2B 31" + " 10 00 00" + " 52 0A 02 746578742F706C61696E 6869",
+		},
+		{
+			// Initial chunk with type, middle and terminating chunks with TNF
+			// unchanged, CF on all but the last
+			name: "chunks",
+			records: []ndef.Record{
+				{TNF: ndef.TNFMedia, Type: []byte("a/b"), Payload: []byte{0x01, 0x02}, Chunked: true},
+				{TNF: ndef.TNFUnchanged, Payload: []byte{0x03}, Chunked: true},
+				{TNF: ndef.TNFUnchanged, Payload: []byte{0x04}},
+			},
+			raw: "B2 03 02 612F62 0102" + " 36 00 01 03" + " 56 00 01 04",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := ndef.NewMessage(tc.records...)
+			raw, err := m.Encode()
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if want := unhex(t, tc.raw); !bytes.Equal(raw, want) {
+				t.Fatalf("Encode = % X, want % X", raw, want)
+			}
+			if m.Len() != len(raw) {
+				t.Errorf("Len %d, encoded %d bytes", m.Len(), len(raw))
+			}
+			parsed, err := ndef.Parse(raw)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(parsed.Records) != len(tc.records) {
+				t.Fatalf("parsed %d records", len(parsed.Records))
+			}
+			for i, r := range parsed.Records {
+				want := tc.records[i]
+				if r.TNF != want.TNF || !bytes.Equal(r.Type, want.Type) || !bytes.Equal(r.ID, want.ID) ||
+					!bytes.Equal(r.Payload, want.Payload) || r.Chunked != want.Chunked {
+					t.Errorf("record %d: %s, want %s", i, r, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"no MB", "51 01 01 55 00"},
+		{"no ME", "91 01 01 55 00"},
+		{"MB on the second record", "91 01 01 55 00 D1 01 01 55 00"},
+		{"header truncated", "D1 01"},
+		{"payload truncated", "D1 01 05 55 00"},
+		{"long payload length beyond the message", "C1 01 00010000 55"},
+		{"well-known without type", "D1 00 00"},
+		{"empty with payload", "D0 00 01 00"},
+		{"unknown with type", "D5 01 00 55"},
+		{"reserved TNF", "D7 00 00"},
+		{"unchanged outside a chunk", "D6 00 00"},
+		{"chunk without unchanged", "B2 03 00 612F62 52 03 00 612F62"},
+		{"ends within a chunk", "B2 03 00 612F62 56 00 00 FF"},
+		{"unterminated chunk", "B2 03 00 612F62 36 00 00"},
+		{"extra bytes", "D1 01 01 55 00 00"},
+	} {
+		if m, err := ndef.Parse(unhex(t, tc.raw)); err == nil {
+			t.Errorf("%s: Parse = %s", tc.name, m)
+		}
+	}
+	if _, err := ndef.NewMessage().Encode(); err == nil {
+		t.Errorf("Encode of an empty message succeeded")
+	}
+	unterminated := ndef.NewMessage(ndef.Record{TNF: ndef.TNFMedia, Type: []byte("a/b"), Chunked: true})
+	if _, err := unterminated.Encode(); err == nil {
+		t.Errorf("Encode of an unterminated chunk succeeded")
+	}
+}
+
+func TestChunk(t *testing.T) {
+	payload := []byte("0123456789")
+	m := ndef.NewMessage(ndef.NewMIMERecord("text/plain", payload), ndef.NewURIRecord("https://a.b"))
+	chunked, err := m.Chunk(4)
+	if err != nil {
+		t.Fatalf("Chunk: %v", err)
+	}
+	// 4 + 4 + 2 payload bytes, the URI record fits
+	if len(chunked.Records) != 4 {
+		t.Fatalf("chunked:\n%s", chunked)
+	}
+	for i, want := range []string{"0123", "4567", "89"} {
+		r := chunked.Records[i]
+		if string(r.Payload) != want || r.Chunked != (i < 2) || (i > 0) != (r.TNF == ndef.TNFUnchanged) {
+			t.Errorf("chunk %d: %s", i, r)
+		}
+	}
+	raw, err := chunked.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	parsed, err := ndef.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	joined, err := parsed.Dechunk()
+	if err != nil {
+		t.Fatalf("Dechunk: %v", err)
+	}
+	if len(joined.Records) != 2 || string(joined.Records[0].Type) != "text/plain" ||
+		!bytes.Equal(joined.Records[0].Payload, payload) || joined.Records[0].Chunked {
+		t.Fatalf("reassembled:\n%s", joined)
+	}
+	if uri, err := joined.Records[1].URI(); err != nil || uri != "https://a.b" {
+		t.Errorf("URI after reassembly: %q, %v", uri, err)
+	}
+
+	if _, err := ndef.ChunkRecord(ndef.NewURIRecord("https://a.b"), 0); err == nil {
+		t.Errorf("ChunkRecord with size 0 succeeded")
+	}
+	if _, err := ndef.ChunkRecord(chunked.Records[0], 2); err == nil {
+		t.Errorf("ChunkRecord of a chunk succeeded")
+	}
+	if _, err := ndef.NewMessage(chunked.Records[:2]...).Dechunk(); err == nil {
+		t.Errorf("Dechunk of an unterminated chunk succeeded")
+	}
+}
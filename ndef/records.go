@@ -0,0 +1,237 @@
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Well-known record types
+var (
+	TypeURI  = []byte("U")
+	TypeText = []byte("T")
+)
+
+// uriPrefixes is the URI identifier code table of the URI record type. Index
+// 0 means no abbreviation.
+var uriPrefixes = []string{
+	"",
+	"http://www.",
+	"https://www.",
+	"http://",
+	"https://",
+	"tel:",
+	"mailto:",
+	"ftp://anonymous:anonymous@",
+	"ftp://ftp.",
+	"ftps://",
+	"sftp://",
+	"smb://",
+	"nfs://",
+	"ftp://",
+	"dav://",
+	"news:",
+	"telnet://",
+	"imap:",
+	"rtsp://",
+	"urn:",
+	"pop:",
+	"sip:",
+	"sips:",
+	"tftp:",
+	"btspp://",
+	"btl2cap://",
+	"btgoep://",
+	"tcpobex://",
+	"irdaobex://",
+	"file://",
+	"urn:epc:id:",
+	"urn:epc:tag:",
+	"urn:epc:pat:",
+	"urn:epc:raw:",
+	"urn:epc:",
+	"urn:nfc:",
+}
+
+// AbbreviateURI splits a URI into the identifier code of its longest known
+// prefix and the remainder. Code 0 means no abbreviation.
+func AbbreviateURI(uri string) (byte, string) {
+	code := 0
+	for i, prefix := range uriPrefixes {
+		if strings.HasPrefix(uri, prefix) && len(prefix) > len(uriPrefixes[code]) {
+			code = i
+		}
+	}
+	return byte(code), uri[len(uriPrefixes[code]):]
+}
+
+// ExpandURI prepends the prefix of an identifier code to the remainder
+func ExpandURI(code byte, rest string) (string, error) {
+	if int(code) >= len(uriPrefixes) {
+		return "", fmt.Errorf("reserved URI identifier code %02X", code)
+	}
+	return uriPrefixes[code] + rest, nil
+}
+
+// NewURIRecord creates a URI record, abbreviating the longest known prefix
+func NewURIRecord(uri string) Record {
+	code, rest := AbbreviateURI(uri)
+	payload := append([]byte{code}, rest...)
+	return Record{TNF: TNFWellKnown, Type: TypeURI, Payload: payload}
+}
+
+// URI returns the URI of a URI record with the prefix expanded
+func (r Record) URI() (string, error) {
+	if r.TNF != TNFWellKnown || string(r.Type) != string(TypeURI) {
+		return "", fmt.Errorf("not a URI record")
+	}
+	if len(r.Payload) == 0 {
+		return "", fmt.Errorf("empty URI record")
+	}
+	return ExpandURI(r.Payload[0], string(r.Payload[1:]))
+}
+
+// NewTextRecord creates a UTF-8 text record with an IANA language code such
+// as "en" or "de-CH"
+func NewTextRecord(lang string, text string) (Record, error) {
+	if len(lang) > 0x3F {
+		return Record{}, fmt.Errorf("language code too long: %d bytes", len(lang))
+	}
+	payload := append([]byte{byte(len(lang))}, lang...)
+	payload = append(payload, text...)
+	return Record{TNF: TNFWellKnown, Type: TypeText, Payload: payload}, nil
+}
+
+// Text returns the language code and text of a text record, UTF-8 or UTF-16
+// encoded
+func (r Record) Text() (lang string, text string, err error) {
+	if r.TNF != TNFWellKnown || string(r.Type) != string(TypeText) {
+		return "", "", fmt.Errorf("not a text record")
+	}
+	if len(r.Payload) == 0 {
+		return "", "", fmt.Errorf("empty text record")
+	}
+	status := r.Payload[0]
+	langLen := int(status & 0x3F)
+	if len(r.Payload) < 1+langLen {
+		return "", "", fmt.Errorf("text record truncated")
+	}
+	lang = string(r.Payload[1 : 1+langLen])
+	raw := r.Payload[1+langLen:]
+	if status&0x80 == 0 {
+		return lang, string(raw), nil
+	}
+
+	// UTF-16, big endian unless a byte order mark says otherwise
+	if len(raw)%2 != 0 {
+		return "", "", fmt.Errorf("odd length of UTF-16 text")
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if len(raw) >= 2 && raw[0] == 0xFF && raw[1] == 0xFE {
+		order = binary.LittleEndian
+		raw = raw[2:]
+	} else if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		raw = raw[2:]
+	}
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = order.Uint16(raw[2*i:])
+	}
+	return lang, string(utf16.Decode(units)), nil
+}
+
+// NewMIMERecord creates a record of a MIME media type such as "text/plain"
+func NewMIMERecord(mimeType string, data []byte) Record {
+	return Record{
+		TNF:     TNFMedia,
+		Type:    []byte(mimeType),
+		Payload: append([]byte{}, data...),
+	}
+}
+
+// NewMessage assembles records into a message
+func NewMessage(records ...Record) *Message {
+	return &Message{Records: records}
+}
+
+// Len returns the encoded size of the record
+func (r Record) Len() int {
+	n := 2 + len(r.Type) + len(r.ID) + len(r.Payload)
+	if len(r.Payload) < 256 {
+		n++
+	} else {
+		n += 4
+	}
+	if len(r.ID) > 0 {
+		n++
+	}
+	return n
+}
+
+// Len returns the encoded size of the message
+func (m *Message) Len() int {
+	n := 0
+	for _, r := range m.Records {
+		n += r.Len()
+	}
+	return n
+}
+
+// Encode serializes the message, setting the MB, ME, SR and IL flags
+func (m *Message) Encode() ([]byte, error) {
+	if len(m.Records) == 0 {
+		return nil, fmt.Errorf("NDEF message without records")
+	}
+	out := make([]byte, 0, m.Len())
+	inChunk := false
+	for i, r := range m.Records {
+		if len(r.Type) > 255 || len(r.ID) > 255 {
+			return nil, fmt.Errorf("record %d: type or ID longer than 255 bytes", i)
+		}
+		if err := r.check(); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		if err := checkChunk(r, inChunk); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		inChunk = r.Chunked
+		out = r.appendTo(out, i == 0, i == len(m.Records)-1)
+	}
+	if inChunk {
+		return nil, fmt.Errorf("NDEF message ends within a chunked record")
+	}
+	return out, nil
+}
+
+func (r Record) appendTo(out []byte, first bool, last bool) []byte {
+	flags := r.TNF & 0x07
+	if first {
+		flags |= FlagMB
+	}
+	if last {
+		flags |= FlagME
+	}
+	if r.Chunked {
+		flags |= FlagCF
+	}
+	if len(r.Payload) < 256 {
+		flags |= FlagSR
+	}
+	if len(r.ID) > 0 {
+		flags |= FlagIL
+	}
+
+	out = append(out, flags, byte(len(r.Type)))
+	if len(r.Payload) < 256 {
+		out = append(out, byte(len(r.Payload)))
+	} else {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(r.Payload)))
+	}
+	if len(r.ID) > 0 {
+		out = append(out, byte(len(r.ID)))
+	}
+	out = append(out, r.Type...)
+	out = append(out, r.ID...)
+	return append(out, r.Payload...)
+}
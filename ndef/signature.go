@@ -0,0 +1,329 @@
+package ndef
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TypeSignature is the well-known type of Signature records (Signature RTD
+// 2.0)
+const TypeSignature = "Sig"
+
+// ErrNoSignature is returned when a message carries no signature record
+var ErrNoSignature = errors.New("message is not signed")
+
+// ErrUnsigned is returned when a signed message has records no signature
+// covers, e.g. records appended after the last signature
+var ErrUnsigned = errors.New("record not covered by a signature")
+
+const signatureVersion = 0x20
+
+// SignatureType identifies the signature algorithm and key size
+type SignatureType byte
+
+const (
+	SignatureNone        SignatureType = 0x00 // marks the start of a signed section
+	SignatureRSAPSS1024  SignatureType = 0x01
+	SignatureRSAPKCS1024 SignatureType = 0x02
+	SignatureDSA1024     SignatureType = 0x03
+	SignatureECDSAP192   SignatureType = 0x04
+	SignatureRSAPSS2048  SignatureType = 0x05
+	SignatureRSAPKCS2048 SignatureType = 0x06
+	SignatureDSA2048     SignatureType = 0x07
+	SignatureECDSAP224   SignatureType = 0x08
+	SignatureECDSAK233   SignatureType = 0x09
+	SignatureECDSAB233   SignatureType = 0x0A
+	SignatureECDSAP256   SignatureType = 0x0B
+)
+
+// Certificate formats and the supported hash
+const (
+	CertificateX509 = 0x00
+	CertificateM2M  = 0x01
+	hashSHA256      = 0x02
+)
+
+// Signature is the content of a Signature record. Either Value or URI
+// locates the signature; the certificate chain starts with the signer.
+type Signature struct {
+	Type         SignatureType
+	Hash         byte // 0x02: SHA-256
+	Value        []byte
+	URI          string
+	CertFormat   byte
+	Certificates [][]byte
+	CertURI      string
+}
+
+// NewSignatureRecord builds a Signature record
+func NewSignatureRecord(s *Signature) (Record, error) {
+	if len(s.Certificates) > 0x0F {
+		return Record{}, fmt.Errorf("at most 15 certificates allowed")
+	}
+	value := s.Value
+	typ := byte(s.Type) & 0x7F
+	if s.URI != "" {
+		value = []byte(s.URI)
+		typ |= 0x80
+	}
+	if len(value) > 0xFFFF {
+		return Record{}, fmt.Errorf("signature too long")
+	}
+	payload := []byte{signatureVersion, typ, s.Hash}
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(value)))
+	payload = append(payload, value...)
+
+	certs := (s.CertFormat&0x07)<<4 | byte(len(s.Certificates))
+	if s.CertURI != "" {
+		certs |= 0x80
+	}
+	payload = append(payload, certs)
+	for _, cert := range s.Certificates {
+		if len(cert) > 0xFFFF {
+			return Record{}, fmt.Errorf("certificate too long")
+		}
+		payload = binary.BigEndian.AppendUint16(payload, uint16(len(cert)))
+		payload = append(payload, cert...)
+	}
+	if s.CertURI != "" {
+		payload = binary.BigEndian.AppendUint16(payload, uint16(len(s.CertURI)))
+		payload = append(payload, s.CertURI...)
+	}
+	return Record{TNF: TNFWellKnown, Type: []byte(TypeSignature), Payload: payload}, nil
+}
+
+// Signature decodes a Signature record
+func (r Record) Signature() (*Signature, error) {
+	if r.TNF != TNFWellKnown || string(r.Type) != TypeSignature {
+		return nil, fmt.Errorf("not a signature record")
+	}
+	p := r.Payload
+	if len(p) < 6 {
+		return nil, fmt.Errorf("signature record truncated")
+	}
+	if p[0]>>4 != signatureVersion>>4 {
+		return nil, fmt.Errorf("unsupported signature version %02X", p[0])
+	}
+	s := &Signature{Type: SignatureType(p[1] & 0x7F), Hash: p[2]}
+	n := int(binary.BigEndian.Uint16(p[3:5]))
+	if len(p) < 5+n+1 {
+		return nil, fmt.Errorf("signature record truncated")
+	}
+	if p[1]&0x80 != 0 {
+		s.URI = string(p[5 : 5+n])
+	} else {
+		s.Value = append([]byte{}, p[5:5+n]...)
+	}
+
+	p = p[5+n:]
+	s.CertFormat = p[0] >> 4 & 0x07
+	uriPresent := p[0]&0x80 != 0
+	count := int(p[0] & 0x0F)
+	p = p[1:]
+	for i := 0; i < count+btoi(uriPresent); i++ {
+		if len(p) < 2 || len(p) < 2+int(binary.BigEndian.Uint16(p)) {
+			return nil, fmt.Errorf("certificate chain truncated")
+		}
+		n := int(binary.BigEndian.Uint16(p))
+		if i < count {
+			s.Certificates = append(s.Certificates, append([]byte{}, p[2:2+n]...))
+		} else {
+			s.CertURI = string(p[2 : 2+n])
+		}
+		p = p[2+n:]
+	}
+	return s, nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// signedData returns the data a signature covers: type length, payload
+// length, ID length, type, ID and payload of each record. Header flags are
+// left out since MB and ME change when records are appended.
+func signedData(records []Record) []byte {
+	var data []byte
+	for _, r := range records {
+		data = append(data, byte(len(r.Type)))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(r.Payload)))
+		data = append(data, byte(len(r.ID)))
+		data = append(data, r.Type...)
+		data = append(data, r.ID...)
+		data = append(data, r.Payload...)
+	}
+	return data
+}
+
+// signedSection returns the records from the previous signature record (or
+// the start of the message) up to the record at end
+func (m *Message) signedSection(end int) []Record {
+	start := end
+	for start > 0 && !isSignature(m.Records[start-1]) {
+		start--
+	}
+	return m.Records[start:end]
+}
+
+func isSignature(r Record) bool {
+	return r.TNF == TNFWellKnown && string(r.Type) == TypeSignature
+}
+
+// signatureType maps a public key to the signature type of the RTD
+func signatureType(pub crypto.PublicKey) (SignatureType, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P224():
+			return SignatureECDSAP224, nil
+		case elliptic.P256():
+			return SignatureECDSAP256, nil
+		}
+	case *rsa.PublicKey:
+		switch k.Size() {
+		case 128:
+			return SignatureRSAPKCS1024, nil
+		case 256:
+			return SignatureRSAPKCS2048, nil
+		}
+	}
+	return SignatureNone, fmt.Errorf("unsupported signing key %T", pub)
+}
+
+// Sign appends a signature record over the records following the previous
+// signature record. The certificates, signer first, are embedded in X.509
+// format.
+func (m *Message) Sign(signer crypto.Signer, certs ...*x509.Certificate) error {
+	typ, err := signatureType(signer.Public())
+	if err != nil {
+		return err
+	}
+	section := m.signedSection(len(m.Records))
+	if len(section) == 0 {
+		return fmt.Errorf("no records to sign")
+	}
+	digest := sha256.Sum256(signedData(section))
+	value, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+
+	s := &Signature{Type: typ, Hash: hashSHA256, Value: value, CertFormat: CertificateX509}
+	for _, cert := range certs {
+		s.Certificates = append(s.Certificates, cert.Raw)
+	}
+	record, err := NewSignatureRecord(s)
+	if err != nil {
+		return err
+	}
+	m.Records = append(m.Records, record)
+	return nil
+}
+
+// Verify checks every signature record of the message against the public
+// key. Signature markers without a signature are skipped. Records that no
+// signature covers fail with ErrUnsigned.
+func (m *Message) Verify(pub crypto.PublicKey) error {
+	return m.verify(func(*Signature) (crypto.PublicKey, error) { return pub, nil })
+}
+
+// VerifyChain checks every signature record against the embedded X.509
+// certificate chain, which must verify with the given options
+func (m *Message) VerifyChain(opts x509.VerifyOptions) error {
+	return m.verify(func(s *Signature) (crypto.PublicKey, error) {
+		if s.CertFormat != CertificateX509 || len(s.Certificates) == 0 {
+			return nil, fmt.Errorf("no X.509 certificate chain")
+		}
+		var chain []*x509.Certificate
+		for _, raw := range s.Certificates {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate: %w", err)
+			}
+			chain = append(chain, cert)
+		}
+		o := opts
+		o.Intermediates = x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			o.Intermediates.AddCert(cert)
+		}
+		if _, err := chain[0].Verify(o); err != nil {
+			return nil, err
+		}
+		return chain[0].PublicKey, nil
+	})
+}
+
+func (m *Message) verify(key func(*Signature) (crypto.PublicKey, error)) error {
+	signed := false
+	covered := 0 // records up to here are signed or signature records
+	for i, r := range m.Records {
+		if !isSignature(r) {
+			continue
+		}
+		s, err := r.Signature()
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if s.Type == SignatureNone {
+			continue
+		}
+		if s.URI != "" {
+			return fmt.Errorf("record %d: signature by URI not supported", i)
+		}
+		if s.Hash != hashSHA256 {
+			return fmt.Errorf("record %d: unsupported hash %02X", i, s.Hash)
+		}
+		pub, err := key(s)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if typ, err := signatureType(pub); err != nil || typ != s.Type {
+			return fmt.Errorf("record %d: key does not match signature type %02X", i, s.Type)
+		}
+
+		section := m.signedSection(i)
+		if err := m.checkCovered(covered, i-len(section)); err != nil {
+			return err
+		}
+		covered = i + 1
+		digest := sha256.Sum256(signedData(section))
+		switch k := pub.(type) {
+		case *ecdsa.PublicKey:
+			if !ecdsa.VerifyASN1(k, digest[:], s.Value) {
+				return fmt.Errorf("record %d: invalid signature", i)
+			}
+		case *rsa.PublicKey:
+			if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], s.Value); err != nil {
+				return fmt.Errorf("record %d: invalid signature", i)
+			}
+		}
+		signed = true
+	}
+	if !signed {
+		return ErrNoSignature
+	}
+	return m.checkCovered(covered, len(m.Records))
+}
+
+// checkCovered fails with ErrUnsigned if a record other than a signature
+// record lies between start and end
+func (m *Message) checkCovered(start, end int) error {
+	for i := start; i < end; i++ {
+		if !isSignature(m.Records[i]) {
+			return fmt.Errorf("record %d: %w", i, ErrUnsigned)
+		}
+	}
+	return nil
+}
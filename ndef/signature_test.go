@@ -0,0 +1,201 @@
+package ndef_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/oo-developer/acr122u/ndef"
+)
+
+// signedMessage returns a URI and a text record signed with the key
+func signedMessage(t *testing.T, key crypto.Signer, certs ...*x509.Certificate) *ndef.Message {
+	t.Helper()
+	text, err := ndef.NewTextRecord("en", "hello")
+	if err != nil {
+		t.Fatalf("NewTextRecord: %v", err)
+	}
+	m := ndef.NewMessage(ndef.NewURIRecord("https://example.com"), text)
+	if err := m.Sign(key, certs...); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return m
+}
+
+// reparse encodes and parses the message, as written to and read from a tag
+func reparse(t *testing.T, m *ndef.Message) *ndef.Message {
+	t.Helper()
+	raw, err := m.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	m, err = ndef.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return m
+}
+
+func TestSignVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		key  crypto.Signer
+		typ  ndef.SignatureType
+	}{
+		{"ECDSA P-256", ecKey, ndef.SignatureECDSAP256},
+		{"RSA 2048", rsaKey, ndef.SignatureRSAPKCS2048},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := reparse(t, signedMessage(t, tc.key))
+			s, err := m.Records[2].Signature()
+			if err != nil || s.Type != tc.typ || s.Hash != 0x02 {
+				t.Fatalf("Signature: %+v, %v", s, err)
+			}
+			if err := m.Verify(tc.key.Public()); err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+
+			// A changed payload byte breaks the signature
+			changed := reparse(t, m)
+			changed.Records[1].Payload[len(changed.Records[1].Payload)-1] ^= 0x01
+			if err := changed.Verify(tc.key.Public()); err == nil {
+				t.Errorf("Verify of a changed payload succeeded")
+			}
+
+			// Records appended after the signature are not covered
+			appended := reparse(t, m)
+			appended.Records = append(appended.Records, ndef.NewURIRecord("https://example.org"))
+			if err := appended.Verify(tc.key.Public()); !errors.Is(err, ndef.ErrUnsigned) {
+				t.Errorf("Verify with an appended record: %v", err)
+			}
+		})
+	}
+
+	m := signedMessage(t, ecKey)
+	if err := m.Verify(otherKey.Public()); err == nil {
+		t.Errorf("Verify with another key succeeded")
+	}
+	if err := m.Verify(rsaKey.Public()); err == nil {
+		t.Errorf("Verify with a key of another type succeeded")
+	}
+	if err := ndef.NewMessage(ndef.NewURIRecord("https://example.com")).Verify(ecKey.Public()); !errors.Is(err, ndef.ErrNoSignature) {
+		t.Errorf("Verify of an unsigned message: %v", err)
+	}
+}
+
+func TestSignSections(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	// Two sections, each signed on its own
+	m := signedMessage(t, key)
+	m.Records = append(m.Records, ndef.NewURIRecord("https://example.org"))
+	if err := m.Sign(key); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := reparse(t, m).Verify(key.Public()); err != nil {
+		t.Fatalf("Verify of two sections: %v", err)
+	}
+
+	// A record put before a signature marker is not covered
+	marker, err := ndef.NewSignatureRecord(&ndef.Signature{Type: ndef.SignatureNone})
+	if err != nil {
+		t.Fatalf("NewSignatureRecord: %v", err)
+	}
+	m = ndef.NewMessage(marker, ndef.NewURIRecord("https://example.com"))
+	if err := m.Sign(key); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := m.Verify(key.Public()); err != nil {
+		t.Fatalf("Verify after a marker: %v", err)
+	}
+	m.Records = append([]ndef.Record{ndef.NewURIRecord("https://example.org")}, m.Records...)
+	if err := m.Verify(key.Public()); !errors.Is(err, ndef.ErrUnsigned) {
+		t.Errorf("Verify with a record before the marker: %v", err)
+	}
+}
+
+// newCertificate returns a certificate for the key, signed by the parent or
+// self-signed if parent is nil
+func newCertificate(t *testing.T, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, ca bool) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "tag signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		template.Subject.CommonName = "tag CA"
+		parent, parentKey = template, key
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert
+}
+
+func TestVerifyChain(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	ca := newCertificate(t, caKey, nil, nil, true)
+	leaf := newCertificate(t, key, ca, caKey, false)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	opts := x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+
+	m := reparse(t, signedMessage(t, key, leaf))
+	if err := m.VerifyChain(opts); err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if err := m.VerifyChain(x509.VerifyOptions{Roots: x509.NewCertPool()}); err == nil {
+		t.Errorf("VerifyChain against another root succeeded")
+	}
+
+	// The embedded certificate is not the signer's
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if err := signedMessage(t, otherKey, leaf).VerifyChain(opts); err == nil {
+		t.Errorf("VerifyChain with the certificate of another key succeeded")
+	}
+	if err := signedMessage(t, key).VerifyChain(opts); err == nil {
+		t.Errorf("VerifyChain without certificates succeeded")
+	}
+}
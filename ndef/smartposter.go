@@ -0,0 +1,126 @@
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Smart Poster record type and the local types of its nested records
+var (
+	TypeSmartPoster = []byte("Sp")
+	typeAction      = []byte("act")
+	typeSize        = []byte("s")
+	typeType        = []byte("t")
+)
+
+// Action is the recommended action of a Smart Poster. The zero value omits
+// the action record.
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionDo          // open the URI
+	ActionSave        // save it for later
+	ActionEdit        // open it for editing
+)
+
+// Title is a Smart Poster title in one language
+type Title struct {
+	Lang string
+	Text string
+}
+
+// SmartPoster is the content of a Smart Poster record: a URI with optional
+// titles, recommended action, and size and MIME type of the referenced
+// content
+type SmartPoster struct {
+	URI    string
+	Titles []Title
+	Action Action
+	Size   int    // 0 omits the size record
+	Type   string // empty omits the type record
+}
+
+// NewSmartPosterRecord creates a Smart Poster record with its content as
+// nested NDEF message
+func NewSmartPosterRecord(sp SmartPoster) (Record, error) {
+	if sp.URI == "" {
+		return Record{}, fmt.Errorf("smart poster without URI")
+	}
+	records := []Record{NewURIRecord(sp.URI)}
+	for _, title := range sp.Titles {
+		r, err := NewTextRecord(title.Lang, title.Text)
+		if err != nil {
+			return Record{}, fmt.Errorf("title %q: %w", title.Lang, err)
+		}
+		records = append(records, r)
+	}
+	if sp.Action != ActionNone {
+		if sp.Action < ActionDo || sp.Action > ActionEdit {
+			return Record{}, fmt.Errorf("invalid action %d", sp.Action)
+		}
+		records = append(records, Record{TNF: TNFWellKnown, Type: typeAction, Payload: []byte{byte(sp.Action - ActionDo)}})
+	}
+	if sp.Size > 0 {
+		records = append(records, Record{TNF: TNFWellKnown, Type: typeSize, Payload: binary.BigEndian.AppendUint32(nil, uint32(sp.Size))})
+	}
+	if sp.Type != "" {
+		records = append(records, Record{TNF: TNFWellKnown, Type: typeType, Payload: []byte(sp.Type)})
+	}
+
+	payload, err := NewMessage(records...).Encode()
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{TNF: TNFWellKnown, Type: TypeSmartPoster, Payload: payload}, nil
+}
+
+// SmartPoster decodes the nested message of a Smart Poster record. Unknown
+// nested records, e.g. icons, are skipped.
+func (r Record) SmartPoster() (*SmartPoster, error) {
+	if r.TNF != TNFWellKnown || string(r.Type) != string(TypeSmartPoster) {
+		return nil, fmt.Errorf("not a smart poster record")
+	}
+	msg, err := Parse(r.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("smart poster content: %w", err)
+	}
+
+	sp := &SmartPoster{}
+	for _, nested := range msg.Records {
+		if nested.TNF != TNFWellKnown {
+			continue
+		}
+		switch string(nested.Type) {
+		case string(TypeURI):
+			if sp.URI != "" {
+				return nil, fmt.Errorf("smart poster with more than one URI")
+			}
+			if sp.URI, err = nested.URI(); err != nil {
+				return nil, err
+			}
+		case string(TypeText):
+			lang, text, err := nested.Text()
+			if err != nil {
+				return nil, err
+			}
+			sp.Titles = append(sp.Titles, Title{Lang: lang, Text: text})
+		case string(typeAction):
+			if len(nested.Payload) != 1 || nested.Payload[0] > 2 {
+				return nil, fmt.Errorf("invalid smart poster action")
+			}
+			sp.Action = ActionDo + Action(nested.Payload[0])
+		case string(typeSize):
+			if len(nested.Payload) != 4 {
+				return nil, fmt.Errorf("invalid smart poster size")
+			}
+			sp.Size = int(binary.BigEndian.Uint32(nested.Payload))
+		case string(typeType):
+			sp.Type = string(nested.Payload)
+		}
+	}
+	if sp.URI == "" {
+		return nil, fmt.Errorf("smart poster without URI")
+	}
+	return sp, nil
+}
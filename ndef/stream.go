@@ -0,0 +1,7 @@
+package ndef
+
+import "github.com/oo-developer/acr122u/hardware"
+
+// Progress receives the bytes written to the tag so far and the total of the
+// running write
+type Progress = hardware.Progress
@@ -0,0 +1,85 @@
+package ndef
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// ErrNoNDEF is returned when the tag is not formatted for NDEF
+var ErrNoNDEF = errors.New("tag holds no NDEF data")
+
+// TagType is the NFC Forum mapping used to store NDEF on a tag
+type TagType int
+
+const (
+	TagUnknown TagType = iota
+	TagType2           // NTAG and MIFARE Ultralight
+	TagClassic         // MIFARE Classic with MAD
+	TagType4           // ISO-DEP, e.g. DESFire
+	TagType3           // FeliCa Lite and Lite-S
+)
+
+func (t TagType) String() string {
+	switch t {
+	case TagType2:
+		return "Type 2"
+	case TagClassic:
+		return "MIFARE Classic"
+	case TagType4:
+		return "Type 4"
+	case TagType3:
+		return "Type 3"
+	default:
+		return "Unknown"
+	}
+}
+
+// DetectTagType picks the NDEF mapping from the detected card type
+func DetectTagType(info *hardware.CardInfo) TagType {
+	switch {
+	case strings.HasPrefix(info.Type, hardware.NTAG),
+		strings.HasPrefix(info.Type, hardware.MIFARE_ULTRALIGHT):
+		return TagType2
+	case strings.HasPrefix(info.Type, hardware.MIFARE_CLASSIK_1K),
+		strings.HasPrefix(info.Type, hardware.MIFARE_CLASSIK_4K),
+		strings.HasPrefix(info.Type, hardware.MIFARE_MINI),
+		strings.HasPrefix(info.Type, hardware.MIFARE_PLUS_SE_2K),
+		strings.HasPrefix(info.Type, hardware.MIFARE_PLUS_SE_4K):
+		return TagClassic
+	case strings.Contains(info.Type, "DESFire"):
+		return TagType4
+	case strings.HasPrefix(info.Type, hardware.FELI_CA):
+		return TagType3
+	default:
+		return TagUnknown
+	}
+}
+
+// ReadFromTag reads and parses the NDEF message of the connected tag. An
+// initialized but empty tag yields a message without records.
+func ReadFromTag(reader *hardware.Reader) (*Message, error) {
+	var raw []byte
+	var err error
+	switch tagType := DetectTagType(reader.CardInfo()); tagType {
+	case TagType2:
+		raw, err = readType2(reader)
+	case TagClassic:
+		raw, err = readClassic(reader)
+	case TagType4:
+		raw, err = readType4(reader.Transport())
+	case TagType3:
+		raw, err = readType3(reader)
+	default:
+		return nil, fmt.Errorf("no NDEF mapping for card type %q", reader.CardInfo().Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return &Message{}, nil
+	}
+	return Parse(raw)
+}
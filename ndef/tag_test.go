@@ -0,0 +1,328 @@
+package ndef_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ndef"
+)
+
+// present connects a mock reader to a card
+func present(t *testing.T, card hardware.Transport, atr []byte) *hardware.Reader {
+	t.Helper()
+	reader := mock.NewReader(card, atr)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return reader
+}
+
+// testMessage returns a message of a URI and a text record
+func testMessage(t *testing.T) *ndef.Message {
+	t.Helper()
+	text, err := ndef.NewTextRecord("en", "Hello")
+	if err != nil {
+		t.Fatalf("NewTextRecord: %v", err)
+	}
+	return ndef.NewMessage(ndef.NewURIRecord("https://example.com"), text)
+}
+
+// checkMessage fails unless the tag holds the encoding of want
+func checkMessage(t *testing.T, reader *hardware.Reader, want *ndef.Message) {
+	t.Helper()
+	got, err := ndef.ReadFromTag(reader)
+	if err != nil {
+		t.Fatalf("ReadFromTag: %v", err)
+	}
+	raw, err := got.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if wantRaw, _ := want.Encode(); !bytes.Equal(raw, wantRaw) {
+		t.Fatalf("ReadFromTag = % X, want % X", raw, wantRaw)
+	}
+}
+
+func TestType2(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	reader := present(t, card, mock.NTAGATR)
+	if m, err := ndef.ReadFromTag(reader); err != nil || len(m.Records) != 0 {
+		t.Fatalf("ReadFromTag of a blank tag = %v, %v", m, err)
+	}
+
+	msg := testMessage(t)
+	if err := ndef.WriteToTag(reader, msg, true); err != nil {
+		t.Fatalf("WriteToTag: %v", err)
+	}
+	// NDEF TLV from page 4 with the URI record first, then the terminator
+	raw, _ := msg.Encode()
+	want := append([]byte{ndef.TLVNDEF, byte(len(raw))}, raw...)
+	want = append(want, ndef.TLVTerminator)
+	if memory := card.Dump(); !bytes.Equal(memory[16:16+len(want)], want) {
+		t.Fatalf("data area % X, want % X", memory[16:16+len(want)], want)
+	}
+	checkMessage(t, reader, msg)
+	dumped, err := ndef.FromDump(card.Dump())
+	if err != nil {
+		t.Fatalf("FromDump: %v", err)
+	}
+	if got, _ := dumped.Encode(); !bytes.Equal(got, raw) {
+		t.Errorf("FromDump = % X", got)
+	}
+
+	if err := ndef.Erase(reader); err != nil {
+		t.Fatalf("Erase: %v", err)
+	}
+	if m, err := ndef.ReadFromTag(reader); err != nil || len(m.Records) != 0 {
+		t.Errorf("ReadFromTag after Erase = %v, %v", m, err)
+	}
+	if err := ndef.Format(reader); err != nil {
+		t.Errorf("Format of a formatted tag: %v", err)
+	}
+}
+
+func TestType2Capacity(t *testing.T) {
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	reader := present(t, card, mock.NTAGATR)
+	capacity, err := ndef.CapacityFor(reader.CardInfo())
+	if err != nil {
+		t.Fatalf("CapacityFor: %v", err)
+	}
+	// 496 bytes of user memory less the 4-byte TLV header
+	if capacity != 492 {
+		t.Fatalf("capacity %d", capacity)
+	}
+
+	// A media record with a 1-byte type and a 4-byte payload length has a
+	// 7-byte header
+	full := ndef.NewMessage(ndef.NewMIMERecord("a", make([]byte, capacity-7)))
+	if err := full.FitsOn(reader.CardInfo()); err != nil {
+		t.Fatalf("FitsOn: %v", err)
+	}
+	if err := ndef.WriteToTag(reader, full, true); err != nil {
+		t.Fatalf("WriteToTag of %d bytes: %v", full.Len(), err)
+	}
+	checkMessage(t, reader, full)
+
+	over := ndef.NewMessage(ndef.NewMIMERecord("a", make([]byte, capacity-6)))
+	var capErr *ndef.CapacityError
+	if err := over.FitsOn(reader.CardInfo()); !errors.As(err, &capErr) || capErr.Size != capacity+1 {
+		t.Errorf("FitsOn = %v", err)
+	}
+	if err := ndef.WriteToTag(reader, over, false); !errors.As(err, &capErr) {
+		t.Errorf("WriteToTag of %d bytes = %v", over.Len(), err)
+	}
+	checkMessage(t, reader, full)
+
+	// Read-only access condition in the capability container
+	if _, err := reader.Transport().Transmit([]byte{0xFF, 0xD6, 0x00, 0x03, 0x04, 0xE1, 0x10, 0x3E, 0x0F}); err != nil {
+		t.Fatalf("Transmit: %v", err)
+	}
+	if err := ndef.WriteToTag(reader, testMessage(t), false); !errors.Is(err, ndef.ErrReadOnly) {
+		t.Errorf("WriteToTag of a read-only tag = %v", err)
+	}
+}
+
+func TestClassic(t *testing.T) {
+	card, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	reader := present(t, card, mock.Classic1KATR)
+	if m, err := ndef.ReadFromTag(reader); err == nil {
+		t.Fatalf("ReadFromTag of a card without MAD = %v", m)
+	}
+	if err := ndef.Format(reader); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	// MAD: info byte, then sectors 1-15 assigned to the NFC Forum AID E103
+	if mad := card.Dump()[16:48]; !bytes.Equal(mad[2:6], []byte{0x03, 0xE1, 0x03, 0xE1}) || mad[30] != 0x03 || mad[31] != 0xE1 {
+		t.Fatalf("MAD % X", mad)
+	}
+
+	msg := testMessage(t)
+	if err := ndef.WriteToTag(reader, msg, true); err != nil {
+		t.Fatalf("WriteToTag: %v", err)
+	}
+	checkMessage(t, reader, msg)
+	dumped, err := ndef.FromDump(card.Dump())
+	if err != nil {
+		t.Fatalf("FromDump: %v", err)
+	}
+	if got, want := dumped.String(), msg.String(); got != want {
+		t.Errorf("FromDump:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := ndef.FromDump(make([]byte, 100)); !errors.Is(err, ndef.ErrNoNDEF) {
+		t.Errorf("FromDump of 100 bytes = %v", err)
+	}
+}
+
+// type4Tag answers the ISO 7816-4 commands of the Type 4 mapping from a
+// capability container and an NDEF file, and GetVersion for the card
+// detection
+type type4Tag struct {
+	files    map[uint16][]byte
+	selected []byte
+	app      bool
+}
+
+func newType4Tag(size int, access byte) *type4Tag {
+	// Mapping version 2.0, MLe 0010 and MLc 0008 to force several commands,
+	// NDEF file E104
+	cc := []byte{0x00, 0x0F, 0x20, 0x00, 0x10, 0x00, 0x08, 0x04, 0x06, 0xE1, 0x04}
+	cc = binary.BigEndian.AppendUint16(cc, uint16(size))
+	cc = append(cc, 0x00, access)
+	return &type4Tag{files: map[uint16][]byte{0xE103: cc, 0xE104: make([]byte, size)}}
+}
+
+// UID lets the reader answer Get Data
+func (c *type4Tag) UID() []byte {
+	return []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+}
+
+func (c *type4Tag) Transmit(apdu []byte) ([]byte, error) {
+	ok := []byte{0x90, 0x00}
+	if len(apdu) >= 2 && apdu[0] == 0x90 {
+		// GetVersion: hardware and software frames of a DESFire EV1 4K
+		switch apdu[1] {
+		case 0x60:
+			return []byte{0x04, 0x01, 0x01, 0x01, 0x00, 0x18, 0x05, 0x91, 0xAF}, nil
+		case 0xAF:
+			return []byte{0x04, 0x01, 0x01, 0x01, 0x00, 0x18, 0x05, 0x91, 0x00}, nil
+		}
+	}
+	if len(apdu) < 5 || apdu[0] != 0x00 {
+		return []byte{0x6E, 0x00}, nil
+	}
+	offset := int(apdu[2])<<8 | int(apdu[3])
+	switch apdu[1] {
+	case 0xA4:
+		id := apdu[5 : 5+int(apdu[4])]
+		if apdu[2] == 0x04 {
+			c.app = bytes.Equal(id, ndef.NDEFApplicationName)
+			if !c.app {
+				return []byte{0x6A, 0x82}, nil
+			}
+			return ok, nil
+		}
+		file, found := c.files[binary.BigEndian.Uint16(id)]
+		if !c.app || !found {
+			return []byte{0x6A, 0x82}, nil
+		}
+		c.selected = file
+		return ok, nil
+	case 0xB0:
+		n := int(apdu[4])
+		if c.selected == nil || offset+n > len(c.selected) {
+			return []byte{0x6B, 0x00}, nil
+		}
+		return append(append([]byte{}, c.selected[offset:offset+n]...), ok...), nil
+	case 0xD6:
+		data := apdu[5 : 5+int(apdu[4])]
+		if c.selected == nil || offset+len(data) > len(c.selected) {
+			return []byte{0x6B, 0x00}, nil
+		}
+		copy(c.selected[offset:], data)
+		return ok, nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestType4(t *testing.T) {
+	tag := newType4Tag(64, 0x00)
+	reader := present(t, tag, mock.DESFireATR)
+	if m, err := ndef.ReadFromTag(reader); err != nil || len(m.Records) != 0 {
+		t.Fatalf("ReadFromTag of an empty file = %v, %v", m, err)
+	}
+
+	msg := testMessage(t)
+	if err := ndef.WriteToTag(reader, msg, true); err != nil {
+		t.Fatalf("WriteToTag: %v", err)
+	}
+	// NLEN followed by the message
+	raw, _ := msg.Encode()
+	want := binary.BigEndian.AppendUint16(nil, uint16(len(raw)))
+	if file := tag.files[0xE104]; !bytes.Equal(file[:len(want)+len(raw)], append(want, raw...)) {
+		t.Fatalf("NDEF file % X", file)
+	}
+	checkMessage(t, reader, msg)
+
+	var capErr *ndef.CapacityError
+	large := ndef.NewMessage(ndef.NewMIMERecord("a", make([]byte, 60)))
+	if err := ndef.WriteToTag(reader, large, false); !errors.As(err, &capErr) || capErr.Capacity != 64 {
+		t.Errorf("WriteToTag of %d bytes = %v", large.Len(), err)
+	}
+
+	readOnly := present(t, newType4Tag(64, 0xFF), mock.DESFireATR)
+	if err := ndef.WriteToTag(readOnly, msg, false); !errors.Is(err, ndef.ErrReadOnly) {
+		t.Errorf("WriteToTag of a read-only file = %v", err)
+	}
+}
+
+func TestType3Attributes(t *testing.T) {
+	// FeliCa Lite-S: version 1.0, Nbr 4, Nbw 1, 13 blocks, read/write,
+	// 20-byte message
+	block := unhex(t, "10 04 01 000D 00000000 00 01 000014 0037")
+	attr, err := ndef.ParseType3Attributes(block)
+	if err != nil {
+		t.Fatalf("ParseType3Attributes: %v", err)
+	}
+	want := ndef.Type3Attributes{Version: 0x10, MaxRead: 4, MaxWrite: 1, MaxBlocks: 13, ReadWrite: true, Length: 20}
+	if *attr != want {
+		t.Errorf("attributes %+v, want %+v", *attr, want)
+	}
+	if got := attr.Bytes(); !bytes.Equal(got, block) {
+		t.Errorf("Bytes = % X, want % X", got, block)
+	}
+
+	attr.Writing, attr.Length = true, 0x010203
+	if got, err := ndef.ParseType3Attributes(attr.Bytes()); err != nil || *got != *attr {
+		t.Errorf("round trip = %+v, %v", got, err)
+	}
+	block[15]++
+	if _, err := ndef.ParseType3Attributes(block); err == nil {
+		t.Errorf("ParseType3Attributes accepted a wrong checksum")
+	}
+	if _, err := ndef.ParseType3Attributes(block[:15]); err == nil {
+		t.Errorf("ParseType3Attributes accepted 15 bytes")
+	}
+}
+
+func TestCapacityFor(t *testing.T) {
+	for _, tc := range []struct {
+		info     hardware.CardInfo
+		tagType  ndef.TagType
+		capacity int
+	}{
+		{hardware.CardInfo{Type: hardware.MIFARE_ULTRALIGHT}, ndef.TagType2, 46},
+		{hardware.CardInfo{Type: hardware.NTAG, Capacity: 872}, ndef.TagType2, 868},
+		{hardware.CardInfo{Type: hardware.MIFARE_CLASSIK_1K}, ndef.TagClassic, 716},
+		{hardware.CardInfo{Type: hardware.MIFARE_MINI}, ndef.TagClassic, 190},
+		{hardware.CardInfo{Type: hardware.MIFARE_DESFIRE, Capacity: 4096}, ndef.TagType4, 3838},
+		{hardware.CardInfo{Type: hardware.FELI_CA}, ndef.TagType3, 208},
+	} {
+		if got := ndef.DetectTagType(&tc.info); got != tc.tagType {
+			t.Errorf("%s: DetectTagType = %s, want %s", tc.info.Type, got, tc.tagType)
+		}
+		if got, err := ndef.CapacityFor(&tc.info); err != nil || got != tc.capacity {
+			t.Errorf("%s: CapacityFor = %d, %v, want %d", tc.info.Type, got, err, tc.capacity)
+		}
+	}
+	unknown := &hardware.CardInfo{Type: "ISO 15693"}
+	if got := ndef.DetectTagType(unknown); got != ndef.TagUnknown {
+		t.Errorf("DetectTagType = %s", got)
+	}
+	if _, err := ndef.CapacityFor(unknown); err == nil {
+		t.Errorf("CapacityFor of an unknown card succeeded")
+	}
+}
@@ -0,0 +1,175 @@
+package ndef
+
+import "fmt"
+
+// TLV types of Type 2 tags and MIFARE Classic NDEF sectors
+const (
+	TLVNull          = 0x00
+	TLVLockControl   = 0x01
+	TLVMemoryControl = 0x02
+	TLVNDEF          = 0x03
+	TLVProprietary   = 0xFD
+	TLVTerminator    = 0xFE
+)
+
+// TLV is a block of the TLV area of a Type 2 tag. NULL and terminator TLVs
+// have neither length nor value.
+type TLV struct {
+	Type   byte
+	Value  []byte
+	Offset int // position in the parsed area
+}
+
+// ControlArea is the memory described by a lock or memory control TLV
+type ControlArea struct {
+	Offset int // byte address relative to the start of the tag memory
+	Size   int // lock bits for lock control, bytes for memory control TLVs
+	// Bytes locked by each dynamic lock bit, lock control TLVs only
+	BytesLockedPerBit int
+}
+
+// ParseTLVs splits a TLV area into its TLVs, up to and including the
+// terminator TLV. Lengths of 1 byte and of 3 bytes (FF and two bytes) are
+// accepted. On error the TLVs parsed so far are returned as well.
+func ParseTLVs(data []byte) ([]TLV, error) {
+	var tlvs []TLV
+	pos := 0
+	for pos < len(data) {
+		t := TLV{Type: data[pos], Offset: pos}
+		pos++
+		if t.Type == TLVNull || t.Type == TLVTerminator {
+			tlvs = append(tlvs, t)
+			if t.Type == TLVTerminator {
+				break
+			}
+			continue
+		}
+
+		if pos >= len(data) {
+			return tlvs, fmt.Errorf("TLV %02X truncated", t.Type)
+		}
+		n := int(data[pos])
+		pos++
+		if n == 0xFF {
+			if pos+2 > len(data) {
+				return tlvs, fmt.Errorf("TLV %02X truncated", t.Type)
+			}
+			n = int(data[pos])<<8 | int(data[pos+1])
+			pos += 2
+		}
+		if pos+n > len(data) {
+			return tlvs, fmt.Errorf("TLV %02X exceeds the data area", t.Type)
+		}
+		t.Value = append([]byte{}, data[pos:pos+n]...)
+		tlvs = append(tlvs, t)
+		pos += n
+	}
+	return tlvs, nil
+}
+
+// Len returns the encoded size of the TLV
+func (t TLV) Len() int {
+	switch {
+	case t.Type == TLVNull || t.Type == TLVTerminator:
+		return 1
+	case len(t.Value) < 0xFF:
+		return 2 + len(t.Value)
+	default:
+		return 4 + len(t.Value)
+	}
+}
+
+// tlvSize returns the memory a message of n bytes occupies in an NDEF TLV
+// followed by a terminator TLV
+func tlvSize(n int) int {
+	return TLV{Type: TLVNDEF, Value: make([]byte, n)}.Len() + 1
+}
+
+// EncodeTLVs serializes TLVs, using the 3-byte length format for values of
+// 255 bytes and more
+func EncodeTLVs(tlvs []TLV) []byte {
+	var out []byte
+	for _, t := range tlvs {
+		out = append(out, t.Type)
+		if t.Type == TLVNull || t.Type == TLVTerminator {
+			continue
+		}
+		if len(t.Value) < 0xFF {
+			out = append(out, byte(len(t.Value)))
+		} else {
+			out = append(out, 0xFF, byte(len(t.Value)>>8), byte(len(t.Value)))
+		}
+		out = append(out, t.Value...)
+	}
+	return out
+}
+
+// ControlArea decodes the value of a lock or memory control TLV
+func (t TLV) ControlArea() (ControlArea, error) {
+	if t.Type != TLVLockControl && t.Type != TLVMemoryControl {
+		return ControlArea{}, fmt.Errorf("TLV %02X is no control TLV", t.Type)
+	}
+	if len(t.Value) != 3 {
+		return ControlArea{}, fmt.Errorf("control TLV must have 3 bytes")
+	}
+	// Position: page address and byte offset, page size from the page
+	// control byte
+	pageAddr := int(t.Value[0] >> 4)
+	byteOffset := int(t.Value[0] & 0x0F)
+	bytesPerPage := 1 << (t.Value[2] & 0x0F)
+	area := ControlArea{
+		Offset: pageAddr*bytesPerPage + byteOffset,
+		Size:   int(t.Value[1]),
+	}
+	if area.Size == 0 {
+		area.Size = 256
+	}
+	if t.Type == TLVLockControl {
+		area.BytesLockedPerBit = 1 << (t.Value[2] >> 4)
+	}
+	return area, nil
+}
+
+// findNDEFTLV returns the value of the first NDEF TLV in a TLV area. Broken
+// TLVs behind the NDEF TLV are ignored.
+func findNDEFTLV(data []byte) ([]byte, error) {
+	tlvs, err := ParseTLVs(data)
+	for _, t := range tlvs {
+		if t.Type == TLVNDEF {
+			return t.Value, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, ErrNoNDEF
+}
+
+// controlTLVsEnd returns the offset behind the leading lock and memory
+// control TLVs, where the NDEF TLV belongs
+func controlTLVsEnd(data []byte) int {
+	tlvs, _ := ParseTLVs(data)
+	end := 0
+	for _, t := range tlvs {
+		if t.Type == TLVLockControl || t.Type == TLVMemoryControl {
+			end = t.Offset + t.Len()
+		} else if t.Type != TLVNull {
+			break
+		}
+	}
+	return end
+}
+
+// ndefTLV wraps a message in an NDEF TLV followed by a terminator TLV. The
+// terminator is left out if the NDEF TLV fills the area exactly.
+func ndefTLV(raw []byte, capacity int) ([]byte, error) {
+	tlvs := []TLV{{Type: TLVNDEF, Value: raw}}
+	size := tlvs[0].Len()
+	if size > capacity {
+		return nil, &CapacityError{Size: size + 1, Capacity: capacity}
+	}
+	if size < capacity {
+		tlvs = append(tlvs, TLV{Type: TLVTerminator})
+	}
+	return EncodeTLVs(tlvs), nil
+}
@@ -0,0 +1,87 @@
+package ndef_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/ndef"
+)
+
+func TestTLVs(t *testing.T) {
+	// NTAG203 data area: lock control TLV for the dynamic lock bits at
+	// 0xA0, an empty NDEF TLV and the terminator, with a NULL TLV between
+	raw := unhex(t, "01 03 A0 10 44 00 03 00 FE")
+	tlvs, err := ndef.ParseTLVs(append(raw, 0xAA))
+	if err != nil {
+		t.Fatalf("ParseTLVs: %v", err)
+	}
+	want := []ndef.TLV{
+		{Type: ndef.TLVLockControl, Value: []byte{0xA0, 0x10, 0x44}, Offset: 0},
+		{Type: ndef.TLVNull, Offset: 5},
+		{Type: ndef.TLVNDEF, Value: []byte{}, Offset: 6},
+		{Type: ndef.TLVTerminator, Offset: 8},
+	}
+	if len(tlvs) != len(want) {
+		t.Fatalf("ParseTLVs = %+v", tlvs)
+	}
+	for i, tlv := range tlvs {
+		if tlv.Type != want[i].Type || !bytes.Equal(tlv.Value, want[i].Value) || tlv.Offset != want[i].Offset {
+			t.Errorf("TLV %d: %+v, want %+v", i, tlv, want[i])
+		}
+	}
+	if got := ndef.EncodeTLVs(tlvs); !bytes.Equal(got, raw) {
+		t.Errorf("EncodeTLVs = % X, want % X", got, raw)
+	}
+
+	area, err := tlvs[0].ControlArea()
+	if err != nil {
+		t.Fatalf("ControlArea: %v", err)
+	}
+	if area != (ndef.ControlArea{Offset: 0xA0, Size: 16, BytesLockedPerBit: 16}) {
+		t.Errorf("lock control area %+v", area)
+	}
+	// Memory control TLV of 256 reserved bytes at page 3, byte 2
+	memory := ndef.TLV{Type: ndef.TLVMemoryControl, Value: []byte{0x32, 0x00, 0x02}}
+	if area, err := memory.ControlArea(); err != nil || area != (ndef.ControlArea{Offset: 14, Size: 256}) {
+		t.Errorf("memory control area %+v, %v", area, err)
+	}
+	for _, tlv := range []ndef.TLV{tlvs[2], {Type: ndef.TLVLockControl, Value: []byte{0xA0, 0x10}}} {
+		if area, err := tlv.ControlArea(); err == nil {
+			t.Errorf("ControlArea of %+v = %+v", tlv, area)
+		}
+	}
+}
+
+func TestTLVLength(t *testing.T) {
+	for _, tc := range []struct {
+		n      int
+		header string
+	}{
+		{0, "03 00"},
+		{254, "03 FE"},
+		{255, "03 FF 00FF"},
+		{256, "03 FF 0100"},
+	} {
+		tlv := ndef.TLV{Type: ndef.TLVNDEF, Value: bytes.Repeat([]byte{0x5A}, tc.n)}
+		raw := ndef.EncodeTLVs([]ndef.TLV{tlv})
+		header := unhex(t, tc.header)
+		if !bytes.HasPrefix(raw, header) || len(raw) != len(header)+tc.n || tlv.Len() != len(raw) {
+			t.Errorf("%d bytes: encoded % X, Len %d", tc.n, raw[:len(header)], tlv.Len())
+			continue
+		}
+		tlvs, err := ndef.ParseTLVs(raw)
+		if err != nil || len(tlvs) != 1 || len(tlvs[0].Value) != tc.n {
+			t.Errorf("%d bytes: ParseTLVs = %d TLVs, %v", tc.n, len(tlvs), err)
+		}
+	}
+	if n := (ndef.TLV{Type: ndef.TLVTerminator}).Len(); n != 1 {
+		t.Errorf("terminator Len %d", n)
+	}
+
+	for _, raw := range []string{"03", "03 FF 01", "03 05 0000", "00 03 FF 0100" + strings.Repeat("00", 255)} {
+		if tlvs, err := ndef.ParseTLVs(unhex(t, raw)); err == nil {
+			t.Errorf("ParseTLVs(%s) = %+v", raw, tlvs)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package ndef
+
+import (
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// Capability container of Type 2 tags
+const (
+	ccMagic       = 0xE1
+	ccPage        = 3
+	dataAreaStart = 4
+)
+
+// readType2 returns the NDEF message of an NTAG or Ultralight tag
+func readType2(reader *hardware.Reader) ([]byte, error) {
+	_, data, err := readType2Area(ntag.NewNTAG(reader))
+	if err != nil {
+		return nil, err
+	}
+	return findNDEFTLV(data)
+}
+
+// writeType2 stores the message behind the lock and memory control TLVs of
+// the data area. The page holding the start of the NDEF TLV is written last,
+// so an interrupted write leaves the old length or an unparsable message.
+func writeType2(reader *hardware.Reader, raw []byte, s *hardware.Tracker) error {
+	tag := ntag.NewNTAG(reader)
+	cc, data, err := readType2Area(tag)
+	if err != nil {
+		return err
+	}
+	// Write access in the low nibble of the access byte, 0 grants it
+	if cc[3]&0x0F != 0 {
+		return ErrReadOnly
+	}
+
+	start := controlTLVsEnd(data)
+	tlv, err := ndefTLV(raw, len(data)-start)
+	if err != nil {
+		return err
+	}
+
+	// Merge into the pages, keeping the control TLVs before start
+	area := append([]byte{}, data...)
+	copy(area[start:], tlv)
+	first := start / 4
+	last := (start + len(tlv) - 1) / 4
+	s.Begin(4 * (last - first + 1))
+	for _, page := range append(pageRange(first+1, last), first) {
+		if err := s.Next(); err != nil {
+			return err
+		}
+		if err := tag.WritePage(byte(dataAreaStart+page), area[4*page:4*page+4]); err != nil {
+			return fmt.Errorf("failed to write page %d: %w", dataAreaStart+page, err)
+		}
+		s.Advance(4)
+	}
+	return nil
+}
+
+// pageRange returns the pages from first to last inclusive
+func pageRange(first int, last int) []int {
+	var pages []int
+	for page := first; page <= last; page++ {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// readType2Area reads the capability container and the data area
+func readType2Area(tag *ntag.NTAG) (cc []byte, data []byte, err error) {
+	cc, err = tag.ReadPage(ccPage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read capability container: %w", err)
+	}
+	if cc[0] != ccMagic {
+		return nil, nil, ErrNoNDEF
+	}
+
+	// The data area size is given in units of 8 bytes
+	size := int(cc[2]) * 8
+	data = make([]byte, 0, size)
+	for page := dataAreaStart; len(data) < size; page += 4 {
+		pages, err := tag.ReadPages(byte(page))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read page %d: %w", page, err)
+		}
+		data = append(data, pages...)
+	}
+	return cc, data[:size], nil
+}
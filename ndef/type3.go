@@ -0,0 +1,193 @@
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/felica"
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// NDEF service codes and mapping version of Type 3 tags
+const (
+	ndefServiceRead     = 0x000B
+	ndefServiceWrite    = 0x0009
+	type3MappingVersion = 0x10
+	type3BlockSize      = felica.BlockSize
+)
+
+// Type3Attributes is the attribute information block (block 0) of a Type 3
+// tag
+type Type3Attributes struct {
+	Version   byte
+	MaxRead   int  // Nbr: blocks per Check command
+	MaxWrite  int  // Nbw: blocks per Update command
+	MaxBlocks int  // Nmaxb: blocks of the NDEF area
+	Writing   bool // WriteF: a write is in progress
+	ReadWrite bool // RWFlag
+	Length    int  // Ln: NDEF message length
+}
+
+// ParseType3Attributes decodes an attribute information block and checks its
+// checksum
+func ParseType3Attributes(block []byte) (*Type3Attributes, error) {
+	if len(block) != type3BlockSize {
+		return nil, fmt.Errorf("attribute block must have %d bytes", type3BlockSize)
+	}
+	if checksum(block[:14]) != binary.BigEndian.Uint16(block[14:16]) {
+		return nil, fmt.Errorf("attribute block checksum mismatch")
+	}
+	return &Type3Attributes{
+		Version:   block[0],
+		MaxRead:   int(block[1]),
+		MaxWrite:  int(block[2]),
+		MaxBlocks: int(binary.BigEndian.Uint16(block[3:5])),
+		Writing:   block[9] == 0x0F,
+		ReadWrite: block[10] == 0x01,
+		Length:    int(block[11])<<16 | int(block[12])<<8 | int(block[13]),
+	}, nil
+}
+
+// Bytes encodes the attribute information block including its checksum
+func (a *Type3Attributes) Bytes() []byte {
+	block := make([]byte, type3BlockSize)
+	block[0] = a.Version
+	block[1] = byte(a.MaxRead)
+	block[2] = byte(a.MaxWrite)
+	binary.BigEndian.PutUint16(block[3:5], uint16(a.MaxBlocks))
+	if a.Writing {
+		block[9] = 0x0F
+	}
+	if a.ReadWrite {
+		block[10] = 0x01
+	}
+	block[11], block[12], block[13] = byte(a.Length>>16), byte(a.Length>>8), byte(a.Length)
+	binary.BigEndian.PutUint16(block[14:16], checksum(block[:14]))
+	return block
+}
+
+func checksum(data []byte) uint16 {
+	var sum uint16
+	for _, b := range data {
+		sum += uint16(b)
+	}
+	return sum
+}
+
+// type3Tag accesses the NDEF service of a Type 3 tag
+type type3Tag struct {
+	card *felica.FeliCa
+}
+
+func newType3Tag(reader *hardware.Reader) (*type3Tag, error) {
+	card := felica.NewFeliCa(reader)
+	if len(card.IDm()) != 8 {
+		return nil, fmt.Errorf("invalid IDm length %d", len(card.IDm()))
+	}
+	return &type3Tag{card: card}, nil
+}
+
+// check reads n blocks starting at first
+func (t *type3Tag) check(first int, n int) ([]byte, error) {
+	data, err := t.card.ReadWithoutEncryption(ndefServiceRead, felica.BlockRange(first, n))
+	if err != nil {
+		return nil, fmt.Errorf("check block %d: %w", first, err)
+	}
+	return data, nil
+}
+
+// update writes whole blocks starting at first
+func (t *type3Tag) update(first int, data []byte) error {
+	blocks := felica.BlockRange(first, len(data)/type3BlockSize)
+	if err := t.card.WriteWithoutEncryption(ndefServiceWrite, blocks, data); err != nil {
+		return fmt.Errorf("update block %d: %w", first, err)
+	}
+	return nil
+}
+
+func (t *type3Tag) attributes() (*Type3Attributes, error) {
+	block, err := t.check(0, 1)
+	if err != nil {
+		return nil, err
+	}
+	attr, err := ParseType3Attributes(block)
+	if err != nil {
+		return nil, err
+	}
+	if attr.Version>>4 != type3MappingVersion>>4 {
+		return nil, ErrNoNDEF
+	}
+	attr.MaxRead = max(attr.MaxRead, 1)
+	attr.MaxWrite = max(attr.MaxWrite, 1)
+	return attr, nil
+}
+
+// readType3 returns the NDEF message of a FeliCa Lite(-S) tag
+func readType3(reader *hardware.Reader) ([]byte, error) {
+	tag, err := newType3Tag(reader)
+	if err != nil {
+		return nil, err
+	}
+	attr, err := tag.attributes()
+	if err != nil {
+		return nil, err
+	}
+	if attr.Writing {
+		return nil, fmt.Errorf("NDEF message is incomplete, a write was interrupted")
+	}
+	if attr.Length > attr.MaxBlocks*type3BlockSize {
+		return nil, fmt.Errorf("NDEF length %d exceeds the NDEF area", attr.Length)
+	}
+
+	blocks := (attr.Length + type3BlockSize - 1) / type3BlockSize
+	var data []byte
+	for block := 0; block < blocks; block += attr.MaxRead {
+		part, err := tag.check(1+block, min(attr.MaxRead, blocks-block))
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, part...)
+	}
+	return data[:attr.Length], nil
+}
+
+// writeType3 stores the message in the NDEF area. WriteF is set in the
+// attribute block for the duration of the write.
+func writeType3(reader *hardware.Reader, raw []byte, s *hardware.Tracker) error {
+	tag, err := newType3Tag(reader)
+	if err != nil {
+		return err
+	}
+	attr, err := tag.attributes()
+	if err != nil {
+		return err
+	}
+	if !attr.ReadWrite {
+		return ErrReadOnly
+	}
+	if capacity := attr.MaxBlocks * type3BlockSize; len(raw) > capacity {
+		return &CapacityError{Size: len(raw), Capacity: capacity}
+	}
+
+	attr.Writing = true
+	if err := tag.update(0, attr.Bytes()); err != nil {
+		return err
+	}
+	blocks := (len(raw) + type3BlockSize - 1) / type3BlockSize
+	data := make([]byte, blocks*type3BlockSize)
+	copy(data, raw)
+	s.Begin(len(data))
+	for block := 0; block < blocks; block += attr.MaxWrite {
+		if err := s.Next(); err != nil {
+			return err
+		}
+		n := min(attr.MaxWrite, blocks-block)
+		if err := tag.update(1+block, data[block*type3BlockSize:(block+n)*type3BlockSize]); err != nil {
+			return err
+		}
+		s.Advance(n * type3BlockSize)
+	}
+	attr.Writing = false
+	attr.Length = len(raw)
+	return tag.update(0, attr.Bytes())
+}
@@ -0,0 +1,164 @@
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// NDEFApplicationName is the ISO DF name of the Type 4 NDEF application
+var NDEFApplicationName = []byte{0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01}
+
+// ccFileID is the ISO file ID of the Type 4 capability container
+var ccFileID = []byte{0xE1, 0x03}
+
+// type4CC is the part of the Type 4 capability container needed to access
+// the NDEF file
+type type4CC struct {
+	maxLe   int
+	maxLc   int
+	fileID  []byte
+	maxSize int
+	read    byte
+	write   byte
+}
+
+// readType4 returns the NDEF message of an ISO-DEP tag
+func readType4(card hardware.Transport) ([]byte, error) {
+	card = hardware.NewChainingTransport(card)
+	cc, err := selectType4(card)
+	if err != nil {
+		return nil, err
+	}
+	if cc.read != 0x00 {
+		return nil, fmt.Errorf("NDEF file is not readable (access %02X)", cc.read)
+	}
+	nlen, err := readBinary(card, 0, 2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NDEF length: %w", err)
+	}
+	n := int(binary.BigEndian.Uint16(nlen))
+	if n > cc.maxSize-2 {
+		return nil, fmt.Errorf("NDEF length %d exceeds the file size", n)
+	}
+
+	data := make([]byte, 0, n)
+	for len(data) < n {
+		chunk := min(n-len(data), cc.maxLe)
+		part, err := readBinary(card, 2+len(data), chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NDEF file: %w", err)
+		}
+		if len(part) == 0 {
+			return nil, fmt.Errorf("NDEF file read returned no data")
+		}
+		data = append(data, part...)
+	}
+	return data[:n], nil
+}
+
+// selectType4 selects the NDEF application, reads the capability container
+// and leaves the NDEF file selected
+func selectType4(card hardware.Transport) (*type4CC, error) {
+	if err := isoSelect(card, 0x04, 0x00, NDEFApplicationName); err != nil {
+		return nil, ErrNoNDEF
+	}
+	if err := isoSelect(card, 0x00, 0x0C, ccFileID); err != nil {
+		return nil, fmt.Errorf("failed to select capability container: %w", err)
+	}
+	raw, err := readBinary(card, 0, 15)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capability container: %w", err)
+	}
+	// CCLEN, mapping version, MLe, MLc, NDEF file control TLV (T=04, L=06)
+	if len(raw) < 15 || raw[7] != 0x04 || raw[8] != 0x06 {
+		return nil, fmt.Errorf("invalid capability container % X", raw)
+	}
+	cc := &type4CC{
+		maxLe:   int(binary.BigEndian.Uint16(raw[3:5])),
+		maxLc:   int(binary.BigEndian.Uint16(raw[5:7])),
+		fileID:  raw[9:11],
+		maxSize: int(binary.BigEndian.Uint16(raw[11:13])),
+		read:    raw[13],
+		write:   raw[14],
+	}
+	// Short APDUs carry at most 255 bytes
+	limit := 0xFF
+	if hardware.ExtendedLength(card) {
+		limit = 0xFFFF
+	}
+	cc.maxLe = min(max(cc.maxLe, 1), limit)
+	cc.maxLc = min(max(cc.maxLc, 1), limit)
+	if err := isoSelect(card, 0x00, 0x0C, cc.fileID); err != nil {
+		return nil, fmt.Errorf("failed to select NDEF file: %w", err)
+	}
+	return cc, nil
+}
+
+func isoSelect(card hardware.Transport, p1 byte, p2 byte, id []byte) error {
+	cmd := append([]byte{0x00, 0xA4, p1, p2, byte(len(id))}, id...)
+	if p2 != 0x0C {
+		cmd = append(cmd, 0x00)
+	}
+	_, err := isoTransmit(card, cmd)
+	return err
+}
+
+func readBinary(card hardware.Transport, offset int, length int) ([]byte, error) {
+	return isoTransmit(card, hardware.Command{INS: 0xB0, P1: byte(offset >> 8), P2: byte(offset), Le: length}.Bytes())
+}
+
+// isoTransmit sends an ISO 7816-4 APDU and checks for status 90 00
+func isoTransmit(card hardware.Transport, cmd []byte) ([]byte, error) {
+	rsp, err := card.Transmit(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("transmit failed: %w", err)
+	}
+	data, err := hardware.CheckStatus(cmd, rsp)
+	if err != nil {
+		return nil, fmt.Errorf("error status: %w", err)
+	}
+	return data, nil
+}
+
+// writeType4 stores the message in the NDEF file. NLEN is cleared first and
+// set last, so an interrupted write leaves an empty message.
+func writeType4(card hardware.Transport, raw []byte, s *hardware.Tracker) error {
+	card = hardware.NewChainingTransport(card)
+	cc, err := selectType4(card)
+	if err != nil {
+		return err
+	}
+	if cc.write != 0x00 {
+		return ErrReadOnly
+	}
+	if len(raw)+2 > cc.maxSize {
+		return &CapacityError{Size: len(raw) + 2, Capacity: cc.maxSize}
+	}
+
+	if err := updateBinary(card, 0, []byte{0x00, 0x00}); err != nil {
+		return fmt.Errorf("failed to clear NDEF length: %w", err)
+	}
+	s.Begin(len(raw))
+	for pos := 0; pos < len(raw); pos += cc.maxLc {
+		if err := s.Next(); err != nil {
+			return err
+		}
+		chunk := raw[pos:min(pos+cc.maxLc, len(raw))]
+		if err := updateBinary(card, 2+pos, chunk); err != nil {
+			return fmt.Errorf("failed to write NDEF file: %w", err)
+		}
+		s.Advance(len(chunk))
+	}
+	if err := updateBinary(card, 0, binary.BigEndian.AppendUint16(nil, uint16(len(raw)))); err != nil {
+		return fmt.Errorf("failed to write NDEF length: %w", err)
+	}
+	return nil
+}
+
+func updateBinary(card hardware.Transport, offset int, data []byte) error {
+	cmd := hardware.Command{INS: 0xD6, P1: byte(offset >> 8), P2: byte(offset), Data: data}
+	_, err := isoTransmit(card, cmd.Bytes())
+	return err
+}
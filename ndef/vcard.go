@@ -0,0 +1,41 @@
+package ndef
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MIME types of vCard records, the second one is still written by older
+// phones
+const (
+	MIMETypeVCard  = "text/vcard"
+	MIMETypeXVCard = "text/x-vcard"
+)
+
+// NewVCardRecord creates a vCard record. A capacity other than 0 is the
+// usable NDEF memory of the target chip in bytes (e.g. ntag.NTAG213Spec
+// .UserBytes); the record is rejected if a message holding only it would not
+// fit.
+func NewVCardRecord(vcard []byte, capacity int) (Record, error) {
+	trimmed := bytes.TrimSpace(vcard)
+	if !bytes.HasPrefix(bytes.ToUpper(trimmed), []byte("BEGIN:VCARD")) ||
+		!bytes.HasSuffix(bytes.ToUpper(trimmed), []byte("END:VCARD")) {
+		return Record{}, fmt.Errorf("not a vCard: missing BEGIN:VCARD or END:VCARD")
+	}
+	r := NewMIMERecord(MIMETypeVCard, vcard)
+	if capacity > 0 {
+		if size := tlvSize(r.Len()); size > capacity {
+			return Record{}, fmt.Errorf("vCard needs %d bytes, tag holds %d", size, capacity)
+		}
+	}
+	return r, nil
+}
+
+// VCard returns the vCard of a record
+func (r Record) VCard() ([]byte, error) {
+	if r.TNF != TNFMedia ||
+		!bytes.EqualFold(r.Type, []byte(MIMETypeVCard)) && !bytes.EqualFold(r.Type, []byte(MIMETypeXVCard)) {
+		return nil, fmt.Errorf("not a vCard record")
+	}
+	return r.Payload, nil
+}
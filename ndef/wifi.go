@@ -0,0 +1,170 @@
+package ndef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// MIMETypeWSC is the MIME type of Wi-Fi Simple Config records
+const MIMETypeWSC = "application/vnd.wfa.wsc"
+
+// Wi-Fi authentication types
+const (
+	WiFiAuthOpen           = 0x0001
+	WiFiAuthWPAPersonal    = 0x0002
+	WiFiAuthShared         = 0x0004
+	WiFiAuthWPAEnterprise  = 0x0008
+	WiFiAuthWPA2Enterprise = 0x0010
+	WiFiAuthWPA2Personal   = 0x0020
+)
+
+// Wi-Fi encryption types
+const (
+	WiFiEncryptionNone = 0x0001
+	WiFiEncryptionWEP  = 0x0002
+	WiFiEncryptionTKIP = 0x0004
+	WiFiEncryptionAES  = 0x0008
+)
+
+// WSC attribute types
+const (
+	wscAuthType       = 0x1003
+	wscCredential     = 0x100E
+	wscEncryptionType = 0x100F
+	wscMACAddress     = 0x1020
+	wscNetworkIndex   = 0x1026
+	wscNetworkKey     = 0x1027
+	wscSSID           = 0x1045
+	wscVendorExt      = 0x1049
+	wscVersion        = 0x104A
+)
+
+// wfaVendorExt is the WFA vendor extension announcing WSC version 2.0
+var wfaVendorExt = []byte{0x00, 0x37, 0x2A, 0x00, 0x01, 0x20}
+
+// broadcastMAC is used when the credential is not bound to an access point
+var broadcastMAC = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// WiFiCredential is a network credential of a WSC configuration token
+type WiFiCredential struct {
+	SSID           string
+	NetworkKey     string
+	AuthType       uint16 // WiFiAuth*, WiFiAuthWPA2Personal if 0
+	EncryptionType uint16 // WiFiEncryption*, WiFiEncryptionAES if 0
+	MAC            []byte // access point MAC, broadcast if nil
+}
+
+// NewWiFiRecord creates a WSC configuration token record that lets phones join
+// the network with a tap
+func NewWiFiRecord(c WiFiCredential) (Record, error) {
+	if c.SSID == "" || len(c.SSID) > 32 {
+		return Record{}, fmt.Errorf("SSID must be 1 to 32 bytes")
+	}
+	if len(c.NetworkKey) > 64 {
+		return Record{}, fmt.Errorf("network key longer than 64 bytes")
+	}
+	if c.AuthType == 0 {
+		c.AuthType = WiFiAuthWPA2Personal
+	}
+	if c.EncryptionType == 0 {
+		c.EncryptionType = WiFiEncryptionAES
+	}
+	mac := c.MAC
+	if mac == nil {
+		mac = broadcastMAC
+	}
+	if len(mac) != 6 {
+		return Record{}, fmt.Errorf("MAC address must be 6 bytes")
+	}
+
+	var credential []byte
+	credential = appendWSC(credential, wscNetworkIndex, []byte{0x01})
+	credential = appendWSC(credential, wscSSID, []byte(c.SSID))
+	credential = appendWSC(credential, wscAuthType, binary.BigEndian.AppendUint16(nil, c.AuthType))
+	credential = appendWSC(credential, wscEncryptionType, binary.BigEndian.AppendUint16(nil, c.EncryptionType))
+	credential = appendWSC(credential, wscNetworkKey, []byte(c.NetworkKey))
+	credential = appendWSC(credential, wscMACAddress, mac)
+
+	var payload []byte
+	payload = appendWSC(payload, wscVersion, []byte{0x10})
+	payload = appendWSC(payload, wscCredential, credential)
+	payload = appendWSC(payload, wscVendorExt, wfaVendorExt)
+	return NewMIMERecord(MIMETypeWSC, payload), nil
+}
+
+// WiFiCredentials returns the credentials of a WSC record
+func (r Record) WiFiCredentials() ([]WiFiCredential, error) {
+	if r.TNF != TNFMedia || !bytes.EqualFold(r.Type, []byte(MIMETypeWSC)) {
+		return nil, fmt.Errorf("not a Wi-Fi Simple Config record")
+	}
+	attrs, err := parseWSC(r.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials []WiFiCredential
+	for _, attr := range attrs {
+		if attr.typ != wscCredential {
+			continue
+		}
+		fields, err := parseWSC(attr.value)
+		if err != nil {
+			return nil, fmt.Errorf("credential: %w", err)
+		}
+		var c WiFiCredential
+		for _, field := range fields {
+			switch field.typ {
+			case wscSSID:
+				c.SSID = string(field.value)
+			case wscNetworkKey:
+				c.NetworkKey = string(field.value)
+			case wscAuthType:
+				if len(field.value) != 2 {
+					return nil, fmt.Errorf("invalid authentication type")
+				}
+				c.AuthType = binary.BigEndian.Uint16(field.value)
+			case wscEncryptionType:
+				if len(field.value) != 2 {
+					return nil, fmt.Errorf("invalid encryption type")
+				}
+				c.EncryptionType = binary.BigEndian.Uint16(field.value)
+			case wscMACAddress:
+				c.MAC = field.value
+			}
+		}
+		credentials = append(credentials, c)
+	}
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("WSC record without credential")
+	}
+	return credentials, nil
+}
+
+type wscAttribute struct {
+	typ   uint16
+	value []byte
+}
+
+func appendWSC(b []byte, typ uint16, value []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	return append(b, value...)
+}
+
+func parseWSC(b []byte) ([]wscAttribute, error) {
+	var attrs []wscAttribute
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("WSC attribute header truncated")
+		}
+		typ := binary.BigEndian.Uint16(b)
+		n := int(binary.BigEndian.Uint16(b[2:]))
+		if len(b) < 4+n {
+			return nil, fmt.Errorf("WSC attribute %04X truncated", typ)
+		}
+		attrs = append(attrs, wscAttribute{typ: typ, value: append([]byte{}, b[4:4+n]...)})
+		b = b[4+n:]
+	}
+	return attrs, nil
+}
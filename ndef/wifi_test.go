@@ -0,0 +1,71 @@
+package ndef_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/ndef"
+)
+
+func TestWiFi(t *testing.T) {
+	r, err := ndef.NewWiFiRecord(ndef.WiFiCredential{SSID: "Home", NetworkKey: "secret123"})
+	if err != nil {
+		t.Fatalf("NewWiFiRecord: %v", err)
+	}
+	// Version 1.0, the credential with network index, SSID, WPA2-Personal,
+	// AES, key and broadcast MAC, and the WFA extension for version 2.0
+	want := unhex(t, "104A 0001 10"+
+		"100E 0030"+
+		"1026 0001 01"+
+		"1045 0004 486F6D65"+
+		"1003 0002 0020"+
+		"100F 0002 0008"+
+		"1027 0009 736563726574313233"+
+		"1020 0006 FFFFFFFFFFFF"+
+		"1049 0006 00372A000120")
+	if r.TNF != ndef.TNFMedia || string(r.Type) != ndef.MIMETypeWSC || !bytes.Equal(r.Payload, want) {
+		t.Fatalf("record %s, want payload % X", r, want)
+	}
+	credentials, err := r.WiFiCredentials()
+	if err != nil {
+		t.Fatalf("WiFiCredentials: %v", err)
+	}
+	c := credentials[0]
+	if len(credentials) != 1 || c.SSID != "Home" || c.NetworkKey != "secret123" ||
+		c.AuthType != ndef.WiFiAuthWPA2Personal || c.EncryptionType != ndef.WiFiEncryptionAES ||
+		!bytes.Equal(c.MAC, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Errorf("credential %+v", c)
+	}
+
+	open := ndef.WiFiCredential{SSID: "Cafe", AuthType: ndef.WiFiAuthOpen, EncryptionType: ndef.WiFiEncryptionNone,
+		MAC: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}
+	if r, err = ndef.NewWiFiRecord(open); err != nil {
+		t.Fatalf("NewWiFiRecord: %v", err)
+	}
+	if credentials, err = r.WiFiCredentials(); err != nil || credentials[0].SSID != "Cafe" ||
+		credentials[0].AuthType != ndef.WiFiAuthOpen || !bytes.Equal(credentials[0].MAC, open.MAC) {
+		t.Errorf("WiFiCredentials = %+v, %v", credentials, err)
+	}
+
+	for _, c := range []ndef.WiFiCredential{
+		{},
+		{SSID: string(make([]byte, 33))},
+		{SSID: "x", NetworkKey: string(make([]byte, 65))},
+		{SSID: "x", MAC: []byte{0x00}},
+	} {
+		if _, err := ndef.NewWiFiRecord(c); err == nil {
+			t.Errorf("NewWiFiRecord(%+v) succeeded", c)
+		}
+	}
+	for _, payload := range []string{
+		"104A 0001 10",               // no credential
+		"104A 0001",                  // truncated attribute
+		"100E 0006 1003 0001 20 00",  // 1-byte authentication type
+		"100E 0005 1045 0004 486F6D", // truncated SSID
+	} {
+		r := ndef.NewMIMERecord(ndef.MIMETypeWSC, unhex(t, payload))
+		if c, err := r.WiFiCredentials(); err == nil {
+			t.Errorf("WiFiCredentials of % X = %+v", r.Payload, c)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ndef
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// ErrReadOnly is returned when the tag's NDEF area is write protected
+var ErrReadOnly = errors.New("tag is read-only")
+
+// ErrVerifyFailed is returned when the message read back after writing
+// differs from the one written
+var ErrVerifyFailed = errors.New("NDEF message read back differs")
+
+// CapacityError is returned when a message does not fit on the tag
+type CapacityError struct {
+	Size     int // bytes needed including the container overhead
+	Capacity int // bytes available on the tag
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("NDEF message needs %d bytes, tag holds %d", e.Size, e.Capacity)
+}
+
+// WriteToTag writes the message to the connected tag using the mapping of
+// its type. With verify set the message is read back and compared.
+func WriteToTag(reader *hardware.Reader, msg *Message, verify bool) error {
+	return WriteToTagContext(context.Background(), reader, msg, verify, nil)
+}
+
+// WriteToTagContext writes the message like WriteToTag, page by page or
+// block by block, reporting each step to progress (which may be nil).
+// Cancelling the context stops the write between two steps and leaves an
+// empty message on the tag.
+func WriteToTagContext(ctx context.Context, reader *hardware.Reader, msg *Message, verify bool, progress Progress) error {
+	raw, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s := hardware.NewTracker(ctx, progress)
+	if err := writeRaw(reader, raw, s); err != nil {
+		if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			if cleanup := writeRaw(reader, nil, nil); cleanup != nil {
+				return fmt.Errorf("%w, failed to clear the tag: %v", err, cleanup)
+			}
+		}
+		return err
+	}
+	if !verify {
+		return nil
+	}
+
+	written, err := ReadFromTag(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read back: %w", err)
+	}
+	back, err := written.Encode()
+	if err != nil || !bytes.Equal(back, raw) {
+		return ErrVerifyFailed
+	}
+	return nil
+}
+
+// writeRaw stores an encoded message using the mapping of the tag type. An
+// empty message leaves an initialized tag without records.
+func writeRaw(reader *hardware.Reader, raw []byte, s *hardware.Tracker) error {
+	switch tagType := DetectTagType(reader.CardInfo()); tagType {
+	case TagType2:
+		return writeType2(reader, raw, s)
+	case TagClassic:
+		return writeClassic(reader, raw, s)
+	case TagType4:
+		return writeType4(reader.Transport(), raw, s)
+	case TagType3:
+		return writeType3(reader, raw, s)
+	default:
+		return fmt.Errorf("no NDEF mapping for card type %q", reader.CardInfo().Type)
+	}
+}
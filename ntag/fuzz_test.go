@@ -0,0 +1,42 @@
+package ntag_test
+
+import (
+	"testing"
+
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// fuzzTransport answers each command with the next length-prefixed chunk of
+// the fuzz input, and 90 00 once the input is used up
+type fuzzTransport struct {
+	data []byte
+}
+
+func (f *fuzzTransport) Transmit(cmd []byte) ([]byte, error) {
+	if len(f.data) == 0 {
+		return []byte{0x90, 0x00}, nil
+	}
+	n := min(int(f.data[0]), len(f.data)-1)
+	rsp := f.data[1 : 1+n]
+	f.data = f.data[1+n:]
+	return rsp, nil
+}
+
+// FuzzResponses runs the chip detection and the decoding of the
+// configuration pages on arbitrary tag responses
+func FuzzResponses(f *testing.F) {
+	// GET_VERSION of an NTAG215, then its configuration pages
+	f.Add([]byte{
+		10, 0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x11, 0x03, 0x90, 0x00,
+		18, 0x04, 0x00, 0x00, 0xFF, 0x00, 0x05, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0x90, 0x00,
+	})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		n := ntag.NewNTAGWithTransport(&fuzzTransport{data: b})
+		n.DetectChipType()
+		n.PasswordProtected()
+		n.GetUserMemoryRange()
+		n.ReadPage(4)
+		n.ReadPages(4)
+		n.Authenticate([]byte{0x01, 0x02, 0x03, 0x04})
+	})
+}
@@ -1,10 +1,16 @@
 package ntag
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
-	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/config"
+	"github.com/oo-developer/acr122u/dump"
 	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
 )
 
 const (
@@ -40,10 +46,15 @@ const (
 	CMD_WRITE       = 0xA2
 	CMD_COMP_WRITE  = 0xA0
 	CMD_PWD_AUTH    = 0x1B
+	CMD_READ_SIG    = 0x3C
 
 	// Status Words
 	SW1_SUCCESS = 0x90
 	SW2_SUCCESS = 0x00
+
+	// MaxFastReadPages is the number of pages one FAST_READ may return
+	// through the PN532
+	MaxFastReadPages = 60
 )
 
 // NTAGType represents the detected NTAG chip type
@@ -82,6 +93,33 @@ var (
 	}
 )
 
+// UseConfig adds the configured passwords to DefaultPasswords, so
+// TryStandardPasswords tries them as well. Password references are resolved
+// with the keys package.
+func UseConfig(cfg *config.Config) error {
+	resolver := keys.NewResolver(cfg)
+	for _, p := range cfg.NTAG.Passwords {
+		var pwd, pack []byte
+		var err error
+		if p.PWD != "" {
+			if pwd, err = resolver.Resolve(p.PWD, 4); err != nil {
+				return fmt.Errorf("ntag password %s: %v", p.Name, err)
+			}
+		}
+		if p.PACK != "" {
+			if pack, err = resolver.Resolve(p.PACK, 2); err != nil {
+				return fmt.Errorf("ntag password %s: %v", p.Name, err)
+			}
+		}
+		DefaultPasswords[p.Name] = struct {
+			PWD   []byte
+			PACK  []byte
+			Usage string
+		}{PWD: pwd, PACK: pack, Usage: "Configured"}
+	}
+	return nil
+}
+
 // DefaultPasswords contains common NTAG password configurations
 var DefaultPasswords = map[string]struct {
 	PWD   []byte
@@ -111,8 +149,7 @@ var DefaultPasswords = map[string]struct {
 }
 
 type NTAG struct {
-	ctx      *scard.Context
-	card     *scard.Card
+	card     hardware.Transport
 	reader   string
 	chipType *NTAGType
 }
@@ -120,12 +157,19 @@ type NTAG struct {
 // NewNTAG initializes a new NTAG handler
 func NewNTAG(reader *hardware.Reader) *NTAG {
 	return &NTAG{
-		ctx:    reader.Ctx(),
-		card:   reader.Card(),
+		card:   reader.Transport(),
 		reader: reader.Reader(),
 	}
 }
 
+// NewNTAGWithTransport creates an NTAG handler on top of any transport, e.g.
+// a transcript replayer
+func NewNTAGWithTransport(transport hardware.Transport) *NTAG {
+	return &NTAG{
+		card: transport,
+	}
+}
+
 // GetVersion retrieves the version information from the NTAG chip
 // Note: This may not work on all ACR122U firmware versions
 func (n *NTAG) GetVersion() ([]byte, error) {
@@ -133,7 +177,7 @@ func (n *NTAG) GetVersion() ([]byte, error) {
 	cmd := []byte{CLA_DIRECT_TRANSMIT, 0x00, 0x00, 0x00, 0x02, CMD_GET_VERSION, 0x00}
 	rsp, err := n.card.Transmit(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get version: %v", err)
+		return nil, fmt.Errorf("failed to get version: %w", err)
 	}
 
 	if len(rsp) < 2 {
@@ -145,7 +189,30 @@ func (n *NTAG) GetVersion() ([]byte, error) {
 		return rsp[:len(rsp)-2], nil
 	}
 
-	return nil, fmt.Errorf("get version failed: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+	return nil, fmt.Errorf("get version failed: %w", hardware.NewStatusError(cmd, rsp))
+}
+
+// ReadSignature reads the 32 byte originality signature of NTAG21x and
+// Ultralight EV1 tags, the ECDSA signature of the UID by NXP
+func (n *NTAG) ReadSignature() ([]byte, error) {
+	cmd := []byte{CLA_DIRECT_TRANSMIT, 0x00, 0x00, 0x00, 0x02, CMD_READ_SIG, 0x00}
+	rsp, err := n.card.Transmit(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	if len(rsp) < 2 {
+		return nil, fmt.Errorf("invalid response length: %w", hardware.ErrWrongLength)
+	}
+
+	if rsp[len(rsp)-2] != SW1_SUCCESS || rsp[len(rsp)-1] != SW2_SUCCESS {
+		return nil, fmt.Errorf("read signature failed: %w", hardware.NewStatusError(cmd, rsp))
+	}
+	if len(rsp)-2 != 32 {
+		return nil, fmt.Errorf("signature has %d bytes, expected 32", len(rsp)-2)
+	}
+
+	return rsp[:32], nil
 }
 
 // DetectChipTypeByMemory detects chip type by probing memory boundaries
@@ -157,10 +224,10 @@ func (n *NTAG) DetectChipTypeByMemory() (*NTAGType, error) {
 	// NTAG216: 231 pages (0-230), user memory ends at page 225
 
 	// Try reading page 130 (only exists on NTAG215/216)
-	if _, err := n.ReadPage(130); err == nil {
+	if n.probePage(130) {
 		// Can read page 130, must be NTAG215 or NTAG216
 		// Try reading page 226 (only exists on NTAG216)
-		if _, err := n.ReadPage(226); err == nil {
+		if n.probePage(226) {
 			n.chipType = &NTAG216Spec
 			return &NTAG216Spec, nil
 		}
@@ -173,6 +240,18 @@ func (n *NTAG) DetectChipTypeByMemory() (*NTAGType, error) {
 	return &NTAG213Spec, nil
 }
 
+// probePage reports whether a page exists. The tag answers a missing page
+// like a lost frame, so a failed read is tried again before the page counts
+// as missing; otherwise one bad read shrinks the detected chip.
+func (n *NTAG) probePage(page byte) bool {
+	for range 2 {
+		if _, err := n.ReadPage(page); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // DetectChipType detects the NTAG chip type (213/215/216)
 // Tries GET_VERSION first, falls back to memory probing if that fails
 func (n *NTAG) DetectChipType() (*NTAGType, error) {
@@ -217,15 +296,19 @@ func (n *NTAG) ReadPage(page byte) ([]byte, error) {
 
 	rsp, err := n.card.Transmit(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("read failed: %v", err)
+		return nil, fmt.Errorf("read failed: %w", err)
 	}
 
 	if len(rsp) < 2 {
-		return nil, fmt.Errorf("invalid response length")
+		return nil, fmt.Errorf("invalid response length: %w", hardware.ErrWrongLength)
 	}
 
 	if rsp[len(rsp)-2] != SW1_SUCCESS || rsp[len(rsp)-1] != SW2_SUCCESS {
-		return nil, fmt.Errorf("read error: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+		return nil, fmt.Errorf("read error: %w", hardware.NewStatusError(cmd, rsp))
+	}
+
+	if len(rsp) < 6 {
+		return nil, fmt.Errorf("read returned %d bytes", len(rsp)-2)
 	}
 
 	// Return only the first 4 bytes (the requested page)
@@ -239,15 +322,15 @@ func (n *NTAG) ReadPages(startPage byte) ([]byte, error) {
 
 	rsp, err := n.card.Transmit(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("read failed: %v", err)
+		return nil, fmt.Errorf("read failed: %w", err)
 	}
 
 	if len(rsp) < 2 {
-		return nil, fmt.Errorf("invalid response length")
+		return nil, fmt.Errorf("invalid response length: %w", hardware.ErrWrongLength)
 	}
 
 	if rsp[len(rsp)-2] != SW1_SUCCESS || rsp[len(rsp)-1] != SW2_SUCCESS {
-		return nil, fmt.Errorf("read error: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+		return nil, fmt.Errorf("read error: %w", hardware.NewStatusError(cmd, rsp))
 	}
 
 	return rsp[:len(rsp)-2], nil
@@ -265,11 +348,11 @@ func (n *NTAG) WritePage(page byte, data []byte) error {
 
 	rsp, err := n.card.Transmit(cmd)
 	if err != nil {
-		return fmt.Errorf("write failed: %v", err)
+		return fmt.Errorf("write failed: %w", err)
 	}
 
 	if len(rsp) != 2 || rsp[0] != SW1_SUCCESS || rsp[1] != SW2_SUCCESS {
-		return fmt.Errorf("write error: %v", rsp)
+		return fmt.Errorf("write error: %w", hardware.NewStatusError(cmd, rsp))
 	}
 
 	return nil
@@ -287,15 +370,15 @@ func (n *NTAG) Authenticate(password []byte) ([]byte, error) {
 
 	rsp, err := n.card.Transmit(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("authentication failed: %v", err)
+		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
 
 	if len(rsp) < 2 {
-		return nil, fmt.Errorf("invalid response length")
+		return nil, fmt.Errorf("invalid response length: %w", hardware.ErrWrongLength)
 	}
 
 	if rsp[len(rsp)-2] != SW1_SUCCESS || rsp[len(rsp)-1] != SW2_SUCCESS {
-		return nil, fmt.Errorf("authentication error: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+		return nil, fmt.Errorf("authentication error: %w", hardware.NewStatusError(cmd, rsp))
 	}
 
 	// Return PACK (2 bytes)
@@ -306,6 +389,34 @@ func (n *NTAG) Authenticate(password []byte) ([]byte, error) {
 	return nil, nil
 }
 
+// PasswordProtected tells whether AUTH0 protects a page of the tag. Tags
+// without password support are never protected.
+func (n *NTAG) PasswordProtected() (bool, error) {
+	if n.chipType == nil {
+		if _, err := n.DetectChipType(); err != nil {
+			return false, fmt.Errorf("failed to detect chip type: %w", err)
+		}
+	}
+
+	var auth0Page byte
+	switch n.chipType.Name {
+	case NTAG213:
+		auth0Page = 0x29
+	case NTAG215:
+		auth0Page = 0x83
+	case NTAG216:
+		auth0Page = 0xE3
+	default:
+		return false, nil
+	}
+
+	configData, err := n.ReadPage(auth0Page)
+	if err != nil {
+		return false, fmt.Errorf("failed to read config page: %w", err)
+	}
+	return int(configData[3]) < n.chipType.TotalPages, nil
+}
+
 // SetPassword configures password protection
 // pwd: 4-byte password
 // pack: 2-byte password acknowledge
@@ -321,7 +432,7 @@ func (n *NTAG) SetPassword(pwd []byte, pack []byte, auth0 byte, authLim byte) er
 
 	if n.chipType == nil {
 		if _, err := n.DetectChipType(); err != nil {
-			return fmt.Errorf("failed to detect chip type: %v", err)
+			return fmt.Errorf("failed to detect chip type: %w", err)
 		}
 	}
 
@@ -343,7 +454,7 @@ func (n *NTAG) SetPassword(pwd []byte, pack []byte, auth0 byte, authLim byte) er
 
 	// Write PWD (4 bytes)
 	if err := n.WritePage(pwdPage, pwd); err != nil {
-		return fmt.Errorf("failed to write password: %v", err)
+		return fmt.Errorf("failed to write password: %w", err)
 	}
 
 	// Write PACK (2 bytes) + RFU (2 bytes, set to 0x00)
@@ -353,55 +464,55 @@ func (n *NTAG) SetPassword(pwd []byte, pack []byte, auth0 byte, authLim byte) er
 	packData[3] = 0x00 // RFU
 
 	if err := n.WritePage(packPage, packData); err != nil {
-		return fmt.Errorf("failed to write PACK: %v", err)
+		return fmt.Errorf("failed to write PACK: %w", err)
 	}
 
-	// Configure AUTH0 (starting page for authentication)
-	// This is in the dynamic lock/reserved area
-	var auth0Page byte
+	// Set AUTHLIM in ACCESS page first, AUTH0 may protect it
+	var accessPage byte
 	switch n.chipType.Name {
 	case NTAG213:
-		auth0Page = 0x29 // Page 41
+		accessPage = 0x2A // Page 42
 	case NTAG215:
-		auth0Page = 0x83 // Page 131
+		accessPage = 0x84 // Page 132
 	case NTAG216:
-		auth0Page = 0xE3 // Page 227
+		accessPage = 0xE4 // Page 228
 	}
 
-	// Read current configuration page
-	configData, err := n.ReadPage(auth0Page)
+	accessData, err := n.ReadPage(accessPage)
 	if err != nil {
-		return fmt.Errorf("failed to read config page: %v", err)
+		return fmt.Errorf("failed to read access page: %w", err)
 	}
 
-	// Modify AUTH0
-	configData[3] = auth0
+	// Set AUTHLIM (bits 0-2 of byte 0)
+	accessData[0] = (accessData[0] & 0xF8) | (authLim & 0x07)
 
-	if err := n.WritePage(auth0Page, configData); err != nil {
-		return fmt.Errorf("failed to write AUTH0: %v", err)
+	if err := n.WritePage(accessPage, accessData); err != nil {
+		return fmt.Errorf("failed to write AUTHLIM: %w", err)
 	}
 
-	// Set AUTHLIM in ACCESS page
-	var accessPage byte
+	// Configure AUTH0 (starting page for authentication)
+	// This is in the dynamic lock/reserved area
+	var auth0Page byte
 	switch n.chipType.Name {
 	case NTAG213:
-		accessPage = 0x2A // Page 42
+		auth0Page = 0x29 // Page 41
 	case NTAG215:
-		accessPage = 0x84 // Page 132
+		auth0Page = 0x83 // Page 131
 	case NTAG216:
-		accessPage = 0xE4 // Page 228
+		auth0Page = 0xE3 // Page 227
 	}
 
-	accessData, err := n.ReadPage(accessPage)
+	// Read current configuration page
+	configData, err := n.ReadPage(auth0Page)
 	if err != nil {
-		return fmt.Errorf("failed to read access page: %v", err)
+		return fmt.Errorf("failed to read config page: %w", err)
 	}
 
-	// Set AUTHLIM (bits 0-2 of byte 0)
-	accessData[0] = (accessData[0] & 0xF8) | (authLim & 0x07)
+	// Modify AUTH0
+	configData[3] = auth0
 
-	if err := n.WritePage(accessPage, accessData); err != nil {
-		return fmt.Errorf("failed to write AUTHLIM: %v", err)
+	if err := n.WritePage(auth0Page, configData); err != nil {
+		return fmt.Errorf("failed to write AUTH0: %w", err)
 	}
 
 	return nil
@@ -411,7 +522,7 @@ func (n *NTAG) SetPassword(pwd []byte, pack []byte, auth0 byte, authLim byte) er
 func (n *NTAG) RemovePassword() error {
 	if n.chipType == nil {
 		if _, err := n.DetectChipType(); err != nil {
-			return fmt.Errorf("failed to detect chip type: %v", err)
+			return fmt.Errorf("failed to detect chip type: %w", err)
 		}
 	}
 
@@ -428,49 +539,178 @@ func (n *NTAG) RemovePassword() error {
 	// Read current configuration
 	configData, err := n.ReadPage(auth0Page)
 	if err != nil {
-		return fmt.Errorf("failed to read config page: %v", err)
+		return fmt.Errorf("failed to read config page: %w", err)
 	}
 
 	// Set AUTH0 to 0xFF (disables password protection)
 	configData[3] = 0xFF
 
 	if err := n.WritePage(auth0Page, configData); err != nil {
-		return fmt.Errorf("failed to disable password: %v", err)
+		return fmt.Errorf("failed to disable password: %w", err)
 	}
 
 	return nil
 }
 
-// DumpMemory reads all user-accessible pages
+// FastRead reads the pages from start to end, inclusive, with a single
+// FAST_READ sent through PN532 InCommunicateThru. At most MaxFastReadPages
+// pages fit into one response.
+func (n *NTAG) FastRead(start byte, end byte) ([]byte, error) {
+	if end < start || int(end-start) >= MaxFastReadPages {
+		return nil, fmt.Errorf("invalid page range %d-%d", start, end)
+	}
+	cmd := []byte{CLA_DIRECT_TRANSMIT, 0x00, 0x00, 0x00, 0x05, 0xD4, 0x42, CMD_FAST_READ, start, end}
+	rsp, err := n.card.Transmit(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("fast read failed: %w", err)
+	}
+
+	if len(rsp) < 2 {
+		return nil, fmt.Errorf("invalid response length: %w", hardware.ErrWrongLength)
+	}
+
+	if rsp[len(rsp)-2] != SW1_SUCCESS || rsp[len(rsp)-1] != SW2_SUCCESS {
+		return nil, fmt.Errorf("fast read error: %w", hardware.NewStatusError(cmd, rsp))
+	}
+
+	// D5 43, PN532 status, page data
+	rsp = rsp[:len(rsp)-2]
+	if len(rsp) < 3 || rsp[0] != 0xD5 || rsp[1] != 0x43 {
+		return nil, fmt.Errorf("invalid PN532 response % X", rsp)
+	}
+	if rsp[2] != 0x00 {
+		return nil, hardware.PN532Error(rsp[2])
+	}
+	if want := (int(end-start) + 1) * 4; len(rsp)-3 != want {
+		return nil, fmt.Errorf("fast read returned %d bytes, expected %d", len(rsp)-3, want)
+	}
+
+	return rsp[3:], nil
+}
+
+// DumpMemory reads all pages. It uses FAST_READ where the tag supports it
+// and otherwise reads four pages per command, falling back to single pages
+// where that fails, e.g. at the start of a password protected area.
 func (n *NTAG) DumpMemory() ([]byte, error) {
+	return n.DumpMemoryContext(context.Background(), nil)
+}
+
+// DumpMemoryContext reads all pages like DumpMemory, reporting the bytes
+// read to progress (which may be nil). Cancelling the context stops the dump
+// between two reads.
+func (n *NTAG) DumpMemoryContext(ctx context.Context, progress hardware.Progress) ([]byte, error) {
 	if n.chipType == nil {
 		if _, err := n.DetectChipType(); err != nil {
-			return nil, fmt.Errorf("failed to detect chip type: %v", err)
+			return nil, fmt.Errorf("failed to detect chip type: %w", err)
 		}
 	}
 
+	total := n.chipType.TotalPages
 	data := make([]byte, 0, n.chipType.TotalBytes)
+	t := hardware.NewTracker(ctx, progress)
+	t.Begin(n.chipType.TotalBytes)
 
-	// Read all pages
-	for page := byte(0); page < byte(n.chipType.TotalPages); page++ {
-		pageData, err := n.ReadPage(page)
+	// FAST_READ in chunks; any failure restarts with plain reads
+	for page := 0; page < total; page += MaxFastReadPages {
+		if err := t.Next(); err != nil {
+			return nil, err
+		}
+		end := min(page+MaxFastReadPages, total) - 1
+		chunk, err := n.FastRead(byte(page), byte(end))
+		if err != nil {
+			data = data[:0]
+			t.Begin(n.chipType.TotalBytes)
+			break
+		}
+		data = append(data, chunk...)
+		t.Advance(len(chunk))
+	}
+	if len(data) == n.chipType.TotalBytes {
+		return data, nil
+	}
+
+	page := 0
+	for ; page+4 <= total; page += 4 {
+		if err := t.Next(); err != nil {
+			return nil, err
+		}
+		chunk, err := n.ReadPages(byte(page))
+		if err != nil || len(chunk) != 16 {
+			break
+		}
+		data = append(data, chunk...)
+		t.Advance(len(chunk))
+	}
+	for ; page < total; page++ {
+		if err := t.Next(); err != nil {
+			return nil, err
+		}
+		pageData, err := n.ReadPage(byte(page))
 		if err != nil {
 			// Some pages may not be readable
 			return data, fmt.Errorf("failed to read page %d: %v", page, err)
 		}
 		data = append(data, pageData...)
+		t.Advance(len(pageData))
 	}
 
 	return data, nil
 }
 
-// TryStandardPasswords attempts authentication with common passwords
+// DumpImage reads all pages into an image of the dump package, e.g. to store
+// it with dump.Save
+func (n *NTAG) DumpImage() (*dump.Image, error) {
+	return n.DumpImageContext(context.Background(), nil)
+}
+
+// DumpImageContext reads the image like DumpMemoryContext. Pages that cannot
+// be read, e.g. behind a password, are zeros in a region marked unreadable
+// rather than an error; PWD and PACK always read as zeros and are marked
+// protected.
+func (n *NTAG) DumpImageContext(ctx context.Context, progress hardware.Progress) (*dump.Image, error) {
+	data, err := n.DumpMemoryContext(ctx, progress)
+	if n.chipType == nil || ctx.Err() != nil || len(data) < 8 {
+		return nil, err
+	}
+	spec := n.chipType
+	read := len(data)
+	data = append(data, make([]byte, spec.TotalBytes-read)...)
+	img := &dump.Image{
+		Type:     spec.Name,
+		UID:      append(append([]byte{}, data[0:3]...), data[4:8]...),
+		Data:     data,
+		Captured: time.Now(),
+	}
+	secret := spec.TotalBytes - 8
+	img.Regions = append(img.Regions, dump.Region{Name: "memory", Length: min(read, secret), Status: dump.StatusOK})
+	if read < secret {
+		img.Regions = append(img.Regions, dump.Region{Name: "unreadable pages", Offset: read, Length: secret - read, Status: dump.StatusUnreadable})
+	}
+	img.Regions = append(img.Regions, dump.Region{Name: "PWD and PACK", Offset: secret, Length: 8, Status: dump.StatusProtected})
+	return img, nil
+}
+
+// TryStandardPasswords attempts authentication with common passwords, in
+// name order
 func (n *NTAG) TryStandardPasswords() (string, []byte, error) {
-	for name, cred := range DefaultPasswords {
+	return n.TryStandardPasswordsContext(context.Background(), nil)
+}
+
+// TryStandardPasswordsContext tries the passwords like TryStandardPasswords,
+// reporting the passwords tried to progress (which may be nil)
+func (n *NTAG) TryStandardPasswordsContext(ctx context.Context, progress hardware.Progress) (string, []byte, error) {
+	t := hardware.NewTracker(ctx, progress)
+	t.Begin(len(DefaultPasswords))
+	for _, name := range slices.Sorted(maps.Keys(DefaultPasswords)) {
+		if err := t.Next(); err != nil {
+			return "", nil, err
+		}
+		cred := DefaultPasswords[name]
 		pack, err := n.Authenticate(cred.PWD)
 		if err == nil {
 			return name, pack, nil
 		}
+		t.Advance(1)
 	}
 	return "", nil, fmt.Errorf("no standard password matched")
 }
@@ -479,7 +719,7 @@ func (n *NTAG) TryStandardPasswords() (string, []byte, error) {
 func (n *NTAG) GetUserMemoryRange() (start byte, end byte, err error) {
 	if n.chipType == nil {
 		if _, err := n.DetectChipType(); err != nil {
-			return 0, 0, fmt.Errorf("failed to detect chip type: %v", err)
+			return 0, 0, fmt.Errorf("failed to detect chip type: %w", err)
 		}
 	}
 
@@ -0,0 +1,196 @@
+package ntag_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/dump"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ntag"
+	"github.com/oo-developer/acr122u/ntag/simulator"
+)
+
+// counter counts the APDUs sent to a transport
+type counter struct {
+	transport hardware.Transport
+	apdus     int
+}
+
+func (c *counter) Transmit(cmd []byte) ([]byte, error) {
+	c.apdus++
+	return c.transport.Transmit(cmd)
+}
+
+// newNTAG215 returns a blank NTAG215 formatted for NDEF without password
+// protection
+func newNTAG215(uid []byte) (*simulator.Simulator, error) {
+	memory := make([]byte, ntag.NTAG215TotalPages*4)
+	copy(memory[0:3], uid[0:3])
+	memory[3] = 0x88 ^ uid[0] ^ uid[1] ^ uid[2]
+	copy(memory[4:8], uid[3:7])
+	memory[8] = uid[3] ^ uid[4] ^ uid[5] ^ uid[6]
+	memory[9] = 0x48
+	copy(memory[12:16], []byte{0xE1, 0x10, 0x3E, 0x00})
+	copy(memory[16:20], []byte{0x03, 0x00, 0xFE, 0x00})
+	config := 0x83 * 4
+	copy(memory[config-4:], []byte{0x00, 0x00, 0x00, 0xBD})
+	copy(memory[config:], []byte{0x04, 0x00, 0x00, 0xFF})
+	copy(memory[config+8:], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+	return simulator.NewSimulator(memory)
+}
+
+func BenchmarkDumpMemory(b *testing.B) {
+	sim, err := newNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		b.Fatalf("NewNTAG215: %v", err)
+	}
+	card := &counter{transport: sim}
+	tag := ntag.NewNTAGWithTransport(card)
+	if _, err := tag.DetectChipType(); err != nil {
+		b.Fatalf("DetectChipType: %v", err)
+	}
+	card.apdus = 0
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := tag.DumpMemory(); err != nil {
+			b.Fatalf("DumpMemory: %v", err)
+		}
+	}
+	b.ReportMetric(float64(card.apdus)/float64(b.N), "apdus/op")
+}
+
+func TestDumpImage(t *testing.T) {
+	uid := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	blank, err := newNTAG215(uid)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	secret := len(blank.Dump()) - 8
+	for _, tc := range []struct {
+		name    string
+		auth0   byte // first page protected against reading
+		regions []dump.Region
+	}{
+		{"open", 0xFF, []dump.Region{
+			{Name: "memory", Length: secret, Status: dump.StatusOK},
+			{Name: "PWD and PACK", Offset: secret, Length: 8, Status: dump.StatusProtected},
+		}},
+		{"read protected", 0x10, []dump.Region{
+			{Name: "memory", Length: 0x10 * 4, Status: dump.StatusOK},
+			{Name: "unreadable pages", Offset: 0x10 * 4, Length: secret - 0x10*4, Status: dump.StatusUnreadable},
+			{Name: "PWD and PACK", Offset: secret, Length: 8, Status: dump.StatusProtected},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			memory := blank.Dump()
+			config := 0x83 * 4
+			memory[config+3] = tc.auth0
+			memory[config+4] = 0x80 // PROT: reading needs the password too
+			sim, err := simulator.NewSimulator(memory)
+			if err != nil {
+				t.Fatalf("NewSimulator: %v", err)
+			}
+			img, err := ntag.NewNTAGWithTransport(sim).DumpImage()
+			if err != nil {
+				t.Fatalf("DumpImage: %v", err)
+			}
+			if img.Type != "NTAG215" || !bytes.Equal(img.UID, uid) || len(img.Data) != len(memory) {
+				t.Fatalf("dumped %s with UID % X, %d bytes", img.Type, img.UID, len(img.Data))
+			}
+			if len(img.Regions) != len(tc.regions) {
+				t.Fatalf("regions %v", img.Regions)
+			}
+			for i, r := range tc.regions {
+				if img.Regions[i] != r {
+					t.Errorf("region %d: %+v, want %+v", i, img.Regions[i], r)
+				}
+			}
+			if img.Complete() != (len(tc.regions) == 2) {
+				t.Errorf("Complete: %t", img.Complete())
+			}
+			readable := img.Regions[0].Length
+			if !bytes.Equal(img.Data[:readable], memory[:readable]) {
+				t.Fatalf("readable pages differ")
+			}
+			if len(bytes.Trim(img.Data[readable:], "\x00")) != 0 {
+				t.Fatalf("unread pages are not zeros: % X", img.Data[readable:])
+			}
+		})
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	sim, err := newNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	tag := ntag.NewNTAGWithTransport(sim)
+	if protected, err := tag.PasswordProtected(); err != nil || protected {
+		t.Fatalf("blank tag: protected %v, %v", protected, err)
+	}
+	pwd, pack := []byte{0x11, 0x22, 0x33, 0x44}, []byte{0xAA, 0xBB}
+	// AUTH0 4 protects the ACCESS page too, so AUTHLIM must be written first
+	if err := tag.SetPassword(pwd, pack, 0x04, 3); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if protected, err := tag.PasswordProtected(); err != nil || !protected {
+		t.Fatalf("after SetPassword: protected %v, %v", protected, err)
+	}
+	got, err := tag.Authenticate(pwd)
+	if err != nil || len(got) != 2 || got[0] != pack[0] || got[1] != pack[1] {
+		t.Fatalf("Authenticate: PACK % X, %v", got, err)
+	}
+	access, err := tag.ReadPage(0x84)
+	if err != nil {
+		t.Fatalf("ReadPage: %v", err)
+	}
+	if limit := access[0] & 0x07; limit != 3 {
+		t.Fatalf("AUTHLIM %d, want 3", limit)
+	}
+}
+
+func TestWriteULCKeyLog(t *testing.T) {
+	key := []byte("0123456789ABCDEF")
+	memory := make([]byte, 192)
+	copy(memory, []byte{0x04, 0x01, 0x02, 0x88 ^ 0x04 ^ 0x01 ^ 0x02, 0x03, 0x04, 0x05, 0x06, 0x03 ^ 0x04 ^ 0x05 ^ 0x06, 0x48, 0x00, 0x00, 0xE1, 0x10, 0x06, 0x00})
+	for i, page := range ntag.ULCKeyPages(key) {
+		copy(memory[(ntag.ULCKeyPage+i)*4:], page)
+	}
+	card, err := simulator.NewSimulator(memory)
+	if err != nil {
+		t.Fatalf("NewSimulator: %v", err)
+	}
+	reader := mock.NewReader(card, mock.NTAGATR)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	tag := ntag.NewNTAG(reader)
+	if err := tag.AuthenticateULC(key); err != nil {
+		t.Fatalf("AuthenticateULC: %v", err)
+	}
+
+	// Bytes that appear in no other part of the logged exchanges
+	other := []byte{0xC0, 0xC1, 0xC2, 0xC3, 0xC4, 0xC5, 0xC6, 0xC7, 0xC8, 0xC9, 0xCA, 0xCB, 0xCC, 0xCD, 0xCE, 0xCF}
+	var log bytes.Buffer
+	hardware.SetLogger(slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer hardware.SetLogger(nil)
+	if err := tag.WriteULCKey(other); err != nil {
+		t.Fatalf("WriteULCKey: %v", err)
+	}
+	hardware.SetLogger(nil)
+	if n := strings.Count(log.String(), "msg=apdu"); n != 4 {
+		t.Fatalf("%d APDUs logged:\n%s", n, log.String())
+	}
+	for _, b := range other {
+		if strings.Contains(log.String(), fmt.Sprintf("%02X", b)) {
+			t.Fatalf("key byte %02X logged:\n%s", b, log.String())
+		}
+	}
+	if err := tag.AuthenticateULC(other); err != nil {
+		t.Fatalf("AuthenticateULC with the new key: %v", err)
+	}
+}
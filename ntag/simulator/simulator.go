@@ -0,0 +1,332 @@
+package simulator
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/dump"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// chip describes a simulated NTAG or Ultralight by its memory size
+type chip struct {
+	name       string
+	pages      int
+	configPage int    // CFG0 page, 0 if the chip has no password
+	version    []byte // GET_VERSION response, nil if not supported
+	ulc        bool   // 3DES authentication of Ultralight C
+}
+
+var chips = []chip{
+	{name: "MIFARE Ultralight", pages: 16},
+	{name: "MIFARE Ultralight C", pages: 48, ulc: true},
+	{name: "MIFARE Ultralight EV1 (MF0UL11)", pages: 20, configPage: 0x10,
+		version: []byte{0x00, 0x04, 0x03, 0x01, 0x01, 0x00, 0x0B, 0x03}},
+	{name: "MIFARE Ultralight EV1 (MF0UL21)", pages: 41, configPage: 0x25,
+		version: []byte{0x00, 0x04, 0x03, 0x01, 0x01, 0x00, 0x0E, 0x03}},
+	{name: ntag.NTAG213, pages: ntag.NTAG213TotalPages, configPage: 0x29,
+		version: []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x0F, 0x03}},
+	{name: ntag.NTAG215, pages: ntag.NTAG215TotalPages, configPage: 0x83,
+		version: []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x11, 0x03}},
+	{name: ntag.NTAG216, pages: ntag.NTAG216TotalPages, configPage: 0xE3,
+		version: []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x13, 0x03}},
+}
+
+var (
+	swSuccess = []byte{0x90, 0x00}
+	swFailed  = []byte{0x63, 0x00}
+)
+
+// Simulator is an NTAG21x or MIFARE Ultralight card loaded from a dump. It
+// answers the pseudo-APDUs of the ACR122U (READ BINARY, UPDATE BINARY and
+// direct transmit of GET_VERSION, READ, FAST_READ, WRITE, PWD_AUTH,
+// READ_SIG and the Ultralight C AUTHENTICATE, also wrapped in PN532
+// InCommunicateThru) and can be used as transport for
+// ntag.NewNTAGWithTransport.
+//
+// The chip is chosen by the dump size. Password protection follows AUTH0,
+// PROT and AUTHLIM of the configuration pages; PWD and PACK are taken from
+// the dump, which holds zeros if it was read from a card. Ultralight C tags
+// authenticate with the key of pages 2Ch to 2Fh, but their AUTH0 and AUTH1
+// are not enforced. Static lock bits are enforced, dynamic lock bits are
+// not. The originality signature is zeros unless set with SetSignature.
+type Simulator struct {
+	chip          chip
+	memory        []byte
+	authenticated bool
+	failedAuths   int
+	signature     []byte
+	rndB          []byte // challenge of a running Ultralight C authentication
+	iv            []byte
+}
+
+// NewSimulator creates a card from a raw dump of all pages
+func NewSimulator(dump []byte) (*Simulator, error) {
+	for _, c := range chips {
+		if len(dump) == c.pages*4 {
+			return &Simulator{chip: c, memory: append([]byte{}, dump...)}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported dump size %d", len(dump))
+}
+
+// LoadSimulator creates a card from a dump file in any format of the dump
+// package, chosen by extension
+func LoadSimulator(path string) (*Simulator, error) {
+	img, err := dump.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSimulator(img.Data)
+}
+
+// Name returns the simulated chip, e.g. NTAG215
+func (s *Simulator) Name() string {
+	return s.chip.name
+}
+
+// UID returns the 7-byte UID of pages 0 and 1
+func (s *Simulator) UID() []byte {
+	return append(append([]byte{}, s.memory[0:3]...), s.memory[4:8]...)
+}
+
+// Dump returns the current memory, including writes
+func (s *Simulator) Dump() []byte {
+	return append([]byte{}, s.memory...)
+}
+
+// SetSignature sets the originality signature READ_SIG returns
+func (s *Simulator) SetSignature(signature []byte) {
+	s.signature = append([]byte{}, signature...)
+}
+
+// Remove simulates taking the card off the reader, which ends the
+// authentication
+func (s *Simulator) Remove() {
+	s.authenticated = false
+}
+
+func (s *Simulator) Transmit(apdu []byte) ([]byte, error) {
+	if len(apdu) < 5 || apdu[0] != ntag.CLA_DIRECT_TRANSMIT {
+		return []byte{0x6E, 0x00}, nil
+	}
+	switch {
+	case apdu[1] == 0xCA && apdu[2] == 0x00:
+		return append(s.UID(), swSuccess...), nil
+	case apdu[1] == ntag.INS_READ_BINARY:
+		le := int(apdu[4])
+		if le == 0 {
+			le = 16
+		}
+		return s.read(int(apdu[3]), le), nil
+	case apdu[1] == ntag.INS_UPDATE_BINARY:
+		if len(apdu) != 9 || apdu[4] != 4 {
+			return []byte{0x67, 0x00}, nil
+		}
+		return s.write(int(apdu[3]), apdu[5:9]), nil
+	case apdu[1] == 0x00 && apdu[2] == 0x00 && apdu[3] == 0x00:
+		lc := int(apdu[4])
+		if lc == 0 || len(apdu) < 5+lc {
+			return []byte{0x67, 0x00}, nil
+		}
+		payload := apdu[5 : 5+lc]
+		if len(payload) > 2 && payload[0] == 0xD4 && payload[1] == 0x42 {
+			return s.communicateThru(payload[2:]), nil
+		}
+		return s.native(payload), nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+// communicateThru answers a command wrapped in PN532 InCommunicateThru; a
+// NAK of the tag is reported as PN532 timeout
+func (s *Simulator) communicateThru(cmd []byte) []byte {
+	rsp := s.native(cmd)
+	if !bytes.Equal(rsp[len(rsp)-2:], swSuccess) {
+		return []byte{0xD5, 0x43, 0x01, 0x90, 0x00}
+	}
+	return append([]byte{0xD5, 0x43, 0x00}, rsp...)
+}
+
+// native executes a command sent with direct transmit
+func (s *Simulator) native(cmd []byte) []byte {
+	switch {
+	case cmd[0] == ntag.CMD_GET_VERSION:
+		if s.chip.version == nil {
+			return swFailed
+		}
+		return append(append([]byte{}, s.chip.version...), swSuccess...)
+	case cmd[0] == ntag.CMD_READ && len(cmd) == 2:
+		return s.read(int(cmd[1]), 16)
+	case cmd[0] == ntag.CMD_FAST_READ && len(cmd) == 3:
+		if s.chip.version == nil || cmd[2] < cmd[1] {
+			return swFailed
+		}
+		return s.read(int(cmd[1]), (int(cmd[2])-int(cmd[1])+1)*4)
+	case cmd[0] == ntag.CMD_WRITE && len(cmd) == 6:
+		return s.write(int(cmd[1]), cmd[2:6])
+	case cmd[0] == ntag.CMD_PWD_AUTH && len(cmd) == 5:
+		return s.passwordAuth(cmd[1:5])
+	case cmd[0] == ntag.CMD_READ_SIG && len(cmd) == 2:
+		if s.chip.version == nil {
+			return swFailed
+		}
+		signature := make([]byte, 32)
+		copy(signature, s.signature)
+		return append(signature, swSuccess...)
+	case cmd[0] == ntag.CMD_ULC_AUTH && len(cmd) == 2:
+		return s.ulcAuth()
+	case cmd[0] == ntag.CMD_ULC_CONTINUE && len(cmd) == 17:
+		return s.ulcContinue(cmd[1:])
+	}
+	return swFailed
+}
+
+// read returns length bytes from page on, wrapping around at the end of the
+// memory like READ does. Without authentication reads starting at a
+// protected page fail; protected pages further on read as zeros, as do PWD
+// and PACK.
+func (s *Simulator) read(page int, length int) []byte {
+	if page >= s.chip.pages {
+		return swFailed
+	}
+	if s.readProtected(page) {
+		return swFailed
+	}
+	out := make([]byte, 0, length+2)
+	for i := 0; i < length; i++ {
+		p := (page + i/4) % s.chip.pages
+		if s.readProtected(p) || s.isSecret(p) {
+			out = append(out, 0x00)
+			continue
+		}
+		out = append(out, s.memory[p*4+i%4])
+	}
+	return append(out, swSuccess...)
+}
+
+func (s *Simulator) write(page int, data []byte) []byte {
+	if page < 2 || page >= s.chip.pages || s.writeProtected(page) || s.locked(page) {
+		return swFailed
+	}
+	offset := page * 4
+	switch page {
+	case 2:
+		// Only the lock bytes are writable, and bits can only be set
+		s.memory[offset+2] |= data[2]
+		s.memory[offset+3] |= data[3]
+	case 3:
+		// Capability container is one-time programmable
+		for i := range data {
+			s.memory[offset+i] |= data[i]
+		}
+	default:
+		copy(s.memory[offset:offset+4], data)
+	}
+	return swSuccess
+}
+
+func (s *Simulator) passwordAuth(pwd []byte) []byte {
+	if s.chip.configPage == 0 {
+		return swFailed
+	}
+	cfg := s.chip.configPage * 4
+	authLim := int(s.memory[cfg+4] & 0x07)
+	if authLim != 0 && s.failedAuths >= authLim {
+		return swFailed
+	}
+	if !bytes.Equal(pwd, s.memory[cfg+8:cfg+12]) {
+		s.failedAuths++
+		return swFailed
+	}
+	s.failedAuths = 0
+	s.authenticated = true
+	return append(append([]byte{}, s.memory[cfg+12:cfg+14]...), swSuccess...)
+}
+
+// auth0 returns the first protected page, past the end if none
+func (s *Simulator) auth0() int {
+	if s.chip.configPage == 0 {
+		return s.chip.pages
+	}
+	return int(s.memory[s.chip.configPage*4+3])
+}
+
+func (s *Simulator) writeProtected(page int) bool {
+	return !s.authenticated && page >= s.auth0()
+}
+
+func (s *Simulator) readProtected(page int) bool {
+	if s.authenticated || page < s.auth0() {
+		return false
+	}
+	return s.memory[(s.chip.configPage+1)*4]&0x80 != 0
+}
+
+// isSecret reports the PWD and PACK pages, and the key of Ultralight C,
+// which always read as zeros
+func (s *Simulator) isSecret(page int) bool {
+	if s.chip.ulc {
+		return page >= ntag.ULCKeyPage
+	}
+	return s.chip.configPage != 0 && (page == s.chip.configPage+2 || page == s.chip.configPage+3)
+}
+
+// ulcCipher returns the 3DES cipher of the key in pages 2Ch to 2Fh
+func (s *Simulator) ulcCipher() cipher.Block {
+	key := make([]byte, 16)
+	for i := 0; i < 4; i++ {
+		page := s.memory[(ntag.ULCKeyPage+i)*4:]
+		half := key[(i/2)*8:]
+		start := 7 - (i%2)*4
+		for j := 0; j < 4; j++ {
+			half[start-j] = page[j]
+		}
+	}
+	block, _ := des.NewTripleDESCipher(append(key, key[:8]...))
+	return block
+}
+
+// ulcAuth answers the first step of the Ultralight C authentication with
+// the enciphered challenge
+func (s *Simulator) ulcAuth() []byte {
+	if !s.chip.ulc {
+		return swFailed
+	}
+	s.rndB = make([]byte, 8)
+	rand.Read(s.rndB)
+	s.iv = make([]byte, 8)
+	cipher.NewCBCEncrypter(s.ulcCipher(), make([]byte, 8)).CryptBlocks(s.iv, s.rndB)
+	return append(append([]byte{0xAF}, s.iv...), swSuccess...)
+}
+
+// ulcContinue checks the answer of the reader and proves the key in turn
+func (s *Simulator) ulcContinue(token []byte) []byte {
+	if s.rndB == nil {
+		return swFailed
+	}
+	rndB := s.rndB
+	s.rndB = nil
+	block := s.ulcCipher()
+	plain := make([]byte, 16)
+	cipher.NewCBCDecrypter(block, s.iv).CryptBlocks(plain, token)
+	if !bytes.Equal(plain[8:], append(append([]byte{}, rndB[1:]...), rndB[0])) {
+		return swFailed
+	}
+	s.authenticated = true
+	rsp := make([]byte, 8)
+	cipher.NewCBCEncrypter(block, token[8:]).CryptBlocks(rsp, append(append([]byte{}, plain[1:8]...), plain[0]))
+	return append(append([]byte{0x00}, rsp...), swSuccess...)
+}
+
+// locked reports pages 3 to 15 locked by the static lock bytes
+func (s *Simulator) locked(page int) bool {
+	if page < 3 || page > 15 {
+		return false
+	}
+	lock := uint16(s.memory[10]) | uint16(s.memory[11])<<8
+	return lock&(1<<page) != 0
+}
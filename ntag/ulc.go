@@ -0,0 +1,121 @@
+package ntag
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+const (
+	CMD_ULC_AUTH     = 0x1A
+	CMD_ULC_CONTINUE = 0xAF
+
+	// ULCKeyPage is the first of the four pages holding the 3DES key of
+	// Ultralight C tags
+	ULCKeyPage = 0x2C
+)
+
+// AuthenticateULC runs the 3DES mutual authentication of MIFARE Ultralight C
+// with a 16 byte key. Both sides prove the key; an error tells which did not.
+func (n *NTAG) AuthenticateULC(key []byte) error {
+	block, err := newULCCipher(key)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := n.communicateThru([]byte{CMD_ULC_AUTH, 0x00})
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	if len(rsp) != 9 || rsp[0] != CMD_ULC_CONTINUE {
+		return fmt.Errorf("invalid authentication response % X", rsp)
+	}
+	encRndB := rsp[1:]
+	rndB := make([]byte, 8)
+	cipher.NewCBCDecrypter(block, make([]byte, 8)).CryptBlocks(rndB, encRndB)
+
+	rndA := make([]byte, 8)
+	if _, err := rand.Read(rndA); err != nil {
+		return fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	token := make([]byte, 16)
+	cipher.NewCBCEncrypter(block, encRndB).CryptBlocks(token, append(rndA, rotateLeft(rndB)...))
+
+	rsp, err = n.communicateThru(append([]byte{CMD_ULC_CONTINUE}, token...))
+	if err != nil {
+		return fmt.Errorf("key not accepted: %w", err)
+	}
+	if len(rsp) != 9 || rsp[0] != 0x00 {
+		return fmt.Errorf("key not accepted: % X: %w", rsp, hardware.ErrAuthFailed)
+	}
+	rndA2 := make([]byte, 8)
+	cipher.NewCBCDecrypter(block, token[8:]).CryptBlocks(rndA2, rsp[1:])
+	if !bytes.Equal(rndA2, rotateLeft(rndA)) {
+		return fmt.Errorf("card does not hold the key")
+	}
+	return nil
+}
+
+// WriteULCKey writes the 3DES key of an Ultralight C tag. The tag takes the
+// halves of the key byte-reversed. The pages are written with the native
+// WRITE, which the reader's log redacts for the key pages.
+func (n *NTAG) WriteULCKey(key []byte) error {
+	if len(key) != 16 {
+		return fmt.Errorf("key must be 16 bytes")
+	}
+	for i, data := range ULCKeyPages(key) {
+		if _, err := n.communicateThru(append([]byte{CMD_WRITE, byte(ULCKeyPage + i)}, data...)); err != nil {
+			return fmt.Errorf("failed to write key: %w", err)
+		}
+	}
+	return nil
+}
+
+// ULCKeyPages returns the four pages from ULCKeyPage on for a 16 byte key
+func ULCKeyPages(key []byte) [][]byte {
+	pages := make([][]byte, 4)
+	for i := range pages {
+		// Page 0 holds bytes 7 to 4, page 1 bytes 3 to 0, then the second half
+		half := key[(i/2)*8 : (i/2)*8+8]
+		start := 7 - (i%2)*4
+		pages[i] = []byte{half[start], half[start-1], half[start-2], half[start-3]}
+	}
+	return pages
+}
+
+// newULCCipher creates the 2-key 3DES cipher of a 16 byte key
+func newULCCipher(key []byte) (cipher.Block, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("key must be 16 bytes")
+	}
+	return des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+}
+
+// communicateThru sends a native command in PN532 InCommunicateThru and
+// returns the answer of the tag
+func (n *NTAG) communicateThru(cmd []byte) ([]byte, error) {
+	apdu := append([]byte{CLA_DIRECT_TRANSMIT, 0x00, 0x00, 0x00, byte(len(cmd) + 2), 0xD4, 0x42}, cmd...)
+	rsp, err := n.card.Transmit(apdu)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != SW1_SUCCESS || rsp[len(rsp)-1] != SW2_SUCCESS {
+		return nil, fmt.Errorf("direct transmit failed: %w", hardware.NewStatusError(apdu, rsp))
+	}
+	rsp = rsp[:len(rsp)-2]
+	if len(rsp) < 3 || rsp[0] != 0xD5 || rsp[1] != 0x43 {
+		return nil, fmt.Errorf("invalid PN532 response % X", rsp)
+	}
+	if rsp[2] != 0x00 {
+		return nil, hardware.PN532Error(rsp[2])
+	}
+	return rsp[3:], nil
+}
+
+func rotateLeft(data []byte) []byte {
+	return append(append([]byte{}, data[1:]...), data[0])
+}
@@ -0,0 +1,295 @@
+package passport
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// ErrSecureMessaging is returned when a protected response does not verify
+var ErrSecureMessaging = errors.New("secure messaging error")
+
+// Key derivation counters
+const (
+	kdfEnc = 1
+	kdfMac = 2
+)
+
+// deriveKey derives a 2-key 3DES key from the seed with odd parity bits
+func deriveKey(seed []byte, counter uint32) []byte {
+	h := sha1.Sum(binary.BigEndian.AppendUint32(append([]byte{}, seed...), counter))
+	key := h[:16]
+	for i, b := range key {
+		ones := 0
+		for v := b >> 1; v != 0; v >>= 1 {
+			ones += int(v & 1)
+		}
+		key[i] = b&0xFE | byte(1-ones%2)
+	}
+	return key
+}
+
+// tripleDES returns 2-key 3DES for a 16-byte key
+func tripleDES(key []byte) (cipher.Block, error) {
+	return des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+}
+
+// pad applies ISO 9797-1 padding method 2
+func pad(data []byte) []byte {
+	out := append(append([]byte{}, data...), 0x80)
+	for len(out)%8 != 0 {
+		out = append(out, 0x00)
+	}
+	return out
+}
+
+// unpad removes ISO 9797-1 padding method 2
+func unpad(data []byte) ([]byte, error) {
+	i := bytes.LastIndexByte(data, 0x80)
+	if i < 0 || len(bytes.Trim(data[i+1:], "\x00")) != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return data[:i], nil
+}
+
+// encrypt encrypts padded data with 3DES-CBC and IV zero
+func encrypt(key []byte, data []byte) ([]byte, error) {
+	block, err := tripleDES(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, make([]byte, 8)).CryptBlocks(out, data)
+	return out, nil
+}
+
+// decrypt decrypts data with 3DES-CBC and IV zero
+func decrypt(key []byte, data []byte) ([]byte, error) {
+	if len(data)%8 != 0 {
+		return nil, fmt.Errorf("ciphertext must be a multiple of 8 bytes")
+	}
+	block, err := tripleDES(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, make([]byte, 8)).CryptBlocks(out, data)
+	return out, nil
+}
+
+// retailMAC computes the ISO 9797-1 MAC algorithm 3 over padded data: DES-CBC
+// with the first key half, then 3DES on the last block
+func retailMAC(key []byte, data []byte) ([]byte, error) {
+	k1, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, err
+	}
+	k2, err := des.NewCipher(key[8:16])
+	if err != nil {
+		return nil, err
+	}
+	mac := make([]byte, 8)
+	for pos := 0; pos < len(data); pos += 8 {
+		for i := range mac {
+			mac[i] ^= data[pos+i]
+		}
+		k1.Encrypt(mac, mac)
+	}
+	k2.Decrypt(mac, mac)
+	k1.Encrypt(mac, mac)
+	return mac, nil
+}
+
+// AuthenticateBAC runs Basic Access Control with keys derived from the MRZ
+// and switches the connection to secure messaging
+func (p *Passport) AuthenticateBAC(key BACKey) error {
+	info, err := key.information()
+	if err != nil {
+		return err
+	}
+	h := sha1.Sum([]byte(info))
+	seed := h[:16]
+	kEnc, kMac := deriveKey(seed, kdfEnc), deriveKey(seed, kdfMac)
+
+	p.sm = nil
+	rndIC, err := p.command([]byte{0x00, 0x84, 0x00, 0x00, 0x08})
+	if err != nil {
+		return fmt.Errorf("get challenge failed: %w", err)
+	}
+	if len(rndIC) != 8 {
+		return fmt.Errorf("invalid challenge length %d", len(rndIC))
+	}
+	rndIFD := make([]byte, 8)
+	kIFD := make([]byte, 16)
+	if _, err := rand.Read(rndIFD); err != nil {
+		return err
+	}
+	if _, err := rand.Read(kIFD); err != nil {
+		return err
+	}
+	sm, err := p.mutualAuthenticate(kEnc, kMac, rndIC, rndIFD, kIFD)
+	if err != nil {
+		return err
+	}
+	p.sm = sm
+	return nil
+}
+
+// mutualAuthenticate sends EXTERNAL AUTHENTICATE and derives the session
+// keys from both key halves
+func (p *Passport) mutualAuthenticate(kEnc, kMac, rndIC, rndIFD, kIFD []byte) (*secureMessaging, error) {
+	s := append(append(append([]byte{}, rndIFD...), rndIC...), kIFD...)
+	eIFD, err := encrypt(kEnc, s)
+	if err != nil {
+		return nil, err
+	}
+	mIFD, err := retailMAC(kMac, pad(eIFD))
+	if err != nil {
+		return nil, err
+	}
+	apdu := append([]byte{0x00, 0x82, 0x00, 0x00, 0x28}, eIFD...)
+	apdu = append(append(apdu, mIFD...), 0x28)
+	rsp, err := p.command(apdu)
+	if err != nil {
+		return nil, fmt.Errorf("external authenticate failed: %w", err)
+	}
+	if len(rsp) != 40 {
+		return nil, fmt.Errorf("invalid authentication response length %d", len(rsp))
+	}
+	mac, err := retailMAC(kMac, pad(rsp[:32]))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(mac, rsp[32:]) {
+		return nil, fmt.Errorf("authentication response: %w", ErrSecureMessaging)
+	}
+	r, err := decrypt(kEnc, rsp[:32])
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(r[:8], rndIC) || !bytes.Equal(r[8:16], rndIFD) {
+		return nil, fmt.Errorf("challenge mismatch in authentication response")
+	}
+	seed := make([]byte, 16)
+	for i := range seed {
+		seed[i] = kIFD[i] ^ r[16+i]
+	}
+	return &secureMessaging{
+		kEnc: deriveKey(seed, kdfEnc),
+		kMac: deriveKey(seed, kdfMac),
+		ssc:  binary.BigEndian.Uint64(append(append([]byte{}, rndIC[4:]...), rndIFD[4:]...)),
+	}, nil
+}
+
+// secureMessaging protects APDUs with the BAC session keys
+type secureMessaging struct {
+	kEnc []byte
+	kMac []byte
+	ssc  uint64
+}
+
+// mac increments the send sequence counter and computes the MAC over it and
+// the data
+func (sm *secureMessaging) mac(data []byte) ([]byte, error) {
+	sm.ssc++
+	return retailMAC(sm.kMac, pad(append(binary.BigEndian.AppendUint64(nil, sm.ssc), data...)))
+}
+
+// protect wraps an APDU in short or extended form
+func (sm *secureMessaging) protect(apdu []byte) ([]byte, error) {
+	cmd, err := hardware.ParseCommand(apdu)
+	if err != nil {
+		return nil, err
+	}
+	header := []byte{cmd.CLA | 0x0C, cmd.INS, cmd.P1, cmd.P2}
+	data := cmd.Data
+	var le []byte
+	switch {
+	case cmd.Le > 0x100:
+		le = []byte{byte(cmd.Le >> 8), byte(cmd.Le)}
+	case cmd.Le > 0:
+		le = []byte{byte(cmd.Le)}
+	}
+
+	var body []byte
+	if len(data) > 0 {
+		enc, err := encrypt(sm.kEnc, pad(data))
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, 0x87)
+		body = appendLength(body, len(enc)+1)
+		body = append(append(body, 0x01), enc...)
+	}
+	if le != nil {
+		body = append(append(body, 0x97, byte(len(le))), le...)
+	}
+	mac, err := sm.mac(append(pad(header), body...))
+	if err != nil {
+		return nil, err
+	}
+	body = append(append(body, 0x8E, 0x08), mac...)
+	out := hardware.Command{CLA: header[0], INS: header[1], P1: header[2], P2: header[3], Data: body, Le: 0x100}
+	if cmd.Extended() || out.Extended() {
+		out.Le = 0x10000
+	}
+	return out.Bytes(), nil
+}
+
+// unprotect verifies a protected response (without status word) and
+// returns the plain data and status word
+func (sm *secureMessaging) unprotect(rsp []byte) ([]byte, uint16, error) {
+	var do87, do99, do8e []byte
+	var macInput []byte
+	rest := rsp
+	for len(rest) > 0 {
+		tag, value, next, raw, err := berObject(rest)
+		if err != nil {
+			return nil, 0, fmt.Errorf("protected response: %w", err)
+		}
+		switch tag {
+		case 0x87:
+			do87 = value
+			macInput = append(macInput, raw...)
+		case 0x99:
+			do99 = value
+			macInput = append(macInput, raw...)
+		case 0x8E:
+			do8e = value
+		}
+		rest = next
+	}
+	if len(do99) != 2 || len(do8e) != 8 {
+		return nil, 0, fmt.Errorf("protected response incomplete: %w", ErrSecureMessaging)
+	}
+	mac, err := sm.mac(macInput)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(mac, do8e) {
+		return nil, 0, fmt.Errorf("response MAC: %w", ErrSecureMessaging)
+	}
+	sw := uint16(do99[0])<<8 | uint16(do99[1])
+	if len(do87) == 0 {
+		return nil, sw, nil
+	}
+	if do87[0] != 0x01 {
+		return nil, 0, fmt.Errorf("unknown padding indicator %02X", do87[0])
+	}
+	plain, err := decrypt(sm.kEnc, do87[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := unpad(plain)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, sw, nil
+}
@@ -0,0 +1,132 @@
+package passport
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// unhex decodes hex, ignoring spaces
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+// scripted is a chip answering the expected commands in order
+type scripted struct {
+	t         *testing.T
+	exchanges [][2][]byte
+}
+
+func (s *scripted) Transmit(cmd []byte) ([]byte, error) {
+	s.t.Helper()
+	if len(s.exchanges) == 0 {
+		s.t.Fatalf("unexpected command % X", cmd)
+	}
+	want, rsp := s.exchanges[0][0], s.exchanges[0][1]
+	s.exchanges = s.exchanges[1:]
+	if !bytes.Equal(cmd, want) {
+		s.t.Fatalf("command % X, want % X", cmd, want)
+	}
+	return rsp, nil
+}
+
+// TestBACWorkedExample follows the worked example of ICAO Doc 9303 Part 11,
+// Appendix D: key derivation from the MRZ, the mutual authentication and
+// secure messaging for SELECT and READ BINARY of EF.COM
+func TestBACWorkedExample(t *testing.T) {
+	key := BACKey{DocumentNumber: "L898902C", DateOfBirth: "690806", DateOfExpiry: "940623"}
+	info, err := key.information()
+	if err != nil {
+		t.Fatalf("information: %v", err)
+	}
+	if info != "L898902C<369080619406236" {
+		t.Fatalf("MRZ information %q", info)
+	}
+	h := sha1.Sum([]byte(info))
+	seed := h[:16]
+	kEnc, kMac := deriveKey(seed, kdfEnc), deriveKey(seed, kdfMac)
+	for _, tc := range []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"Kseed", seed, "239AB9CB282DAF66231DC5A4DF6BFBAE"},
+		{"Kenc", kEnc, "AB94FDECF2674FDFB9B391F85D7F76F2"},
+		{"Kmac", kMac, "7962D9ECE03D1ACD4C76089DCE131543"},
+	} {
+		if want := unhex(t, tc.want); !bytes.Equal(tc.got, want) {
+			t.Errorf("%s % X, want % X", tc.name, tc.got, want)
+		}
+	}
+
+	rndIC := unhex(t, "4608F91988702212")
+	rndIFD := unhex(t, "781723860C06C226")
+	kIFD := unhex(t, "0B795240CB7049B01C19B33E32804F0B")
+	chip := &scripted{t: t, exchanges: [][2][]byte{{
+		unhex(t, "0082000028 72C29C2371CC9BDB65B779B8E8D37B29ECC154AA56A8799FAE2F498F76ED92F2 5F1448EEA8AD90A7 28"),
+		unhex(t, "46B9342A41396CD7386BF5803104D7CEDC122B9132139BAF2EEDC94EE178534F 2F2D235D074D7449 9000"),
+	}}}
+	p := NewPassportWithTransport(chip)
+	sm, err := p.mutualAuthenticate(kEnc, kMac, rndIC, rndIFD, kIFD)
+	if err != nil {
+		t.Fatalf("mutualAuthenticate: %v", err)
+	}
+	if want := unhex(t, "979EC13B1CBFE9DCD01AB0FED307EAE5"); !bytes.Equal(sm.kEnc, want) {
+		t.Errorf("KSenc % X, want % X", sm.kEnc, want)
+	}
+	if want := unhex(t, "F1CB1F1FB5ADF208806B89DC579DC1F8"); !bytes.Equal(sm.kMac, want) {
+		t.Errorf("KSmac % X, want % X", sm.kMac, want)
+	}
+	if sm.ssc != 0x887022120C06C226 {
+		t.Errorf("SSC %016X, want 887022120C06C226", sm.ssc)
+	}
+
+	p.sm = sm
+	for _, tc := range []struct {
+		name      string
+		command   string
+		protected string
+		response  string
+		data      string
+	}{
+		{
+			name:      "select EF.COM",
+			command:   "00A4020C02011E",
+			protected: "0CA4020C15 8709016375432908C044F6 8E08BF8B92D635FF24F8 00",
+			response:  "990290008E08FA855A5D4C50A8ED 9000",
+		},
+		{
+			name:      "read binary of 4 bytes",
+			command:   "00B0000004",
+			protected: "0CB000000D 970104 8E08ED6705417E96BA55 00",
+			response:  "8709019FF0EC34F99226519902 90008E08AD55CC17140B2DED 9000",
+			data:      "60145F01",
+		},
+		{
+			name:      "read binary of the rest",
+			command:   "00B0000412",
+			protected: "0CB000040D 970112 8E082EA28A70F3C7B535 00",
+			response:  "871901FB9235F4E4037F2327DCC8964F1F9B8C30F42C8E2FFF224A 99029000 8E08C8B2787EAEA07D74 9000",
+			data:      "04303130365F36063034303030305C026175",
+		},
+	} {
+		chip.exchanges = [][2][]byte{{unhex(t, tc.protected), unhex(t, tc.response)}}
+		data, err := p.command(unhex(t, tc.command))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if want := unhex(t, tc.data); !bytes.Equal(data, want) {
+			t.Errorf("%s: data % X, want % X", tc.name, data, want)
+		}
+	}
+	if sm.ssc != 0x887022120C06C22C {
+		t.Errorf("SSC %016X after the exchanges, want 887022120C06C22C", sm.ssc)
+	}
+}
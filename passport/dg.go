@@ -0,0 +1,208 @@
+package passport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Data group tags
+const (
+	tagDG1            = 0x61
+	tagMRZ            = 0x5F1F
+	tagDG2            = 0x75
+	tagBiometricData  = 0x5F2E
+	tagBiometricDataE = 0x7F2E
+)
+
+// berHeader decodes the tag and length of a BER-TLV object and returns the
+// size of the header
+func berHeader(data []byte) (uint32, int, int, error) {
+	if len(data) < 2 {
+		return 0, 0, 0, fmt.Errorf("TLV header truncated")
+	}
+	pos := 1
+	tag := uint32(data[0])
+	if data[0]&0x1F == 0x1F {
+		for {
+			if pos >= len(data) {
+				return 0, 0, 0, fmt.Errorf("tag truncated")
+			}
+			tag = tag<<8 | uint32(data[pos])
+			pos++
+			if data[pos-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	if pos >= len(data) {
+		return 0, 0, 0, fmt.Errorf("tag %X: length missing", tag)
+	}
+	n := int(data[pos])
+	pos++
+	if n&0x80 != 0 {
+		count := n & 0x7F
+		if count == 0 || count > 3 || pos+count > len(data) {
+			return 0, 0, 0, fmt.Errorf("tag %X: invalid length", tag)
+		}
+		n = 0
+		for _, b := range data[pos : pos+count] {
+			n = n<<8 | int(b)
+		}
+		pos += count
+	}
+	return tag, n, pos, nil
+}
+
+// berObject splits the first BER-TLV object off data and returns its tag,
+// value, the remaining data and the complete encoded object
+func berObject(data []byte) (uint32, []byte, []byte, []byte, error) {
+	tag, n, header, err := berHeader(data)
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+	if header+n > len(data) {
+		return 0, nil, nil, nil, fmt.Errorf("tag %X: value exceeds data", tag)
+	}
+	return tag, data[header : header+n], data[header+n:], data[:header+n], nil
+}
+
+// appendLength appends a BER length
+func appendLength(b []byte, n int) []byte {
+	switch {
+	case n < 0x80:
+		return append(b, byte(n))
+	case n <= 0xFF:
+		return append(b, 0x81, byte(n))
+	default:
+		return append(b, 0x82, byte(n>>8), byte(n))
+	}
+}
+
+// findValues collects the values of all objects with the tag, descending
+// into constructed objects
+func findValues(data []byte, tag uint32) ([][]byte, error) {
+	var found [][]byte
+	for len(data) > 0 {
+		t, value, rest, _, err := berObject(data)
+		if err != nil {
+			return nil, err
+		}
+		first := t
+		for first > 0xFF {
+			first >>= 8
+		}
+		switch {
+		case t == tag:
+			found = append(found, value)
+		case first&0x20 != 0:
+			inner, err := findValues(value, tag)
+			if err != nil {
+				return nil, fmt.Errorf("tag %X: %w", t, err)
+			}
+			found = append(found, inner...)
+		}
+		data = rest
+	}
+	return found, nil
+}
+
+// ParseDG1 parses the content of data group 1
+func ParseDG1(data []byte) (*MRZ, error) {
+	tag, value, _, _, err := berObject(data)
+	if err != nil {
+		return nil, err
+	}
+	if tag != tagDG1 {
+		return nil, fmt.Errorf("not a DG1 (tag %X)", tag)
+	}
+	mrz, err := findValues(value, tagMRZ)
+	if err != nil {
+		return nil, err
+	}
+	if len(mrz) == 0 {
+		return nil, fmt.Errorf("DG1 without MRZ")
+	}
+	return ParseMRZ(string(mrz[0]))
+}
+
+// Image formats of facial records
+const (
+	ImageJPEG     = "jpeg"
+	ImageJPEG2000 = "jpeg2000"
+)
+
+// FaceImage is a facial image from data group 2
+type FaceImage struct {
+	Format string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// ParseDG2 extracts the facial images of data group 2
+func ParseDG2(data []byte) ([]FaceImage, error) {
+	tag, value, _, _, err := berObject(data)
+	if err != nil {
+		return nil, err
+	}
+	if tag != tagDG2 {
+		return nil, fmt.Errorf("not a DG2 (tag %X)", tag)
+	}
+	blocks, err := findValues(value, tagBiometricData)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := findValues(value, tagBiometricDataE)
+	if err != nil {
+		return nil, err
+	}
+	var images []FaceImage
+	for _, block := range append(blocks, encrypted...) {
+		faces, err := parseFacialRecord(block)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, faces...)
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("DG2 without facial image")
+	}
+	return images, nil
+}
+
+// parseFacialRecord decodes an ISO/IEC 19794-5 facial record: a 14-byte
+// header, then per image a 20-byte information block, 8 bytes per feature
+// point, 12 bytes of image information and the image
+func parseFacialRecord(b []byte) ([]FaceImage, error) {
+	if len(b) < 14 || !bytes.Equal(b[:4], []byte("FAC\x00")) {
+		return nil, fmt.Errorf("not a facial record")
+	}
+	count := int(binary.BigEndian.Uint16(b[12:14]))
+	var images []FaceImage
+	pos := 14
+	for i := 0; i < count; i++ {
+		if pos+20 > len(b) {
+			return nil, fmt.Errorf("facial record %d truncated", i)
+		}
+		length := int(binary.BigEndian.Uint32(b[pos:]))
+		points := int(binary.BigEndian.Uint16(b[pos+4:]))
+		end := pos + length
+		info := pos + 20 + 8*points
+		if length < 20 || end > len(b) || info+12 > end {
+			return nil, fmt.Errorf("facial record %d: invalid length", i)
+		}
+		img := FaceImage{
+			Format: ImageJPEG,
+			Width:  int(binary.BigEndian.Uint16(b[info+2:])),
+			Height: int(binary.BigEndian.Uint16(b[info+4:])),
+			Data:   append([]byte{}, b[info+12:end]...),
+		}
+		if b[info+1] == 0x01 {
+			img.Format = ImageJPEG2000
+		}
+		images = append(images, img)
+		pos = end
+	}
+	return images, nil
+}
@@ -0,0 +1,155 @@
+package passport
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MRZ holds the fields of a machine readable zone
+type MRZ struct {
+	DocumentCode   string
+	IssuingState   string
+	DocumentNumber string
+	Surname        string
+	GivenNames     string
+	Nationality    string
+	DateOfBirth    string // YYMMDD
+	Sex            string
+	DateOfExpiry   string // YYMMDD
+	OptionalData   string
+}
+
+// checkDigit computes the ICAO 9303 check digit with weights 7, 3, 1
+func checkDigit(s string) byte {
+	weights := []int{7, 3, 1}
+	sum := 0
+	for i, c := range s {
+		v := 0
+		switch {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			v = int(c-'A') + 10
+		}
+		sum += v * weights[i%3]
+	}
+	return byte('0' + sum%10)
+}
+
+// field returns an MRZ field with filler characters trimmed
+func field(s string) string {
+	return strings.TrimRight(s, "<")
+}
+
+// names splits the name field into surname and given names
+func names(s string) (string, string) {
+	surname, given, _ := strings.Cut(field(s), "<<")
+	return strings.ReplaceAll(surname, "<", " "), strings.ReplaceAll(given, "<", " ")
+}
+
+// verify checks the check digit of an MRZ field
+func verify(name string, value string, digit byte) error {
+	if checkDigit(value) != digit {
+		return fmt.Errorf("%s check digit mismatch", name)
+	}
+	return nil
+}
+
+// ParseMRZ parses a TD1 (3x30), TD2 (2x36) or TD3 (2x44) machine readable
+// zone. Line breaks and spaces are ignored.
+func ParseMRZ(s string) (*MRZ, error) {
+	s = strings.Join(strings.Fields(strings.ToUpper(s)), "")
+	m := &MRZ{}
+	var docNumber, dob, expiry string
+	var docDigit, dobDigit, expiryDigit byte
+
+	switch len(s) {
+	case 88, 72: // TD3 and TD2 share the layout of the second line
+		width := len(s) / 2
+		l1, l2 := s[:width], s[width:]
+		m.DocumentCode = field(l1[0:2])
+		m.IssuingState = field(l1[2:5])
+		m.Surname, m.GivenNames = names(l1[5:])
+		docNumber, docDigit = l2[0:9], l2[9]
+		m.Nationality = field(l2[10:13])
+		dob, dobDigit = l2[13:19], l2[19]
+		m.Sex = field(l2[20:21])
+		expiry, expiryDigit = l2[21:27], l2[27]
+		if width == 44 {
+			m.OptionalData = field(l2[28:42])
+		} else {
+			m.OptionalData = field(l2[28:35])
+		}
+	case 90:
+		l1, l2, l3 := s[:30], s[30:60], s[60:]
+		m.DocumentCode = field(l1[0:2])
+		m.IssuingState = field(l1[2:5])
+		docNumber, docDigit = l1[5:14], l1[14]
+		optional := l1[15:30]
+		if docDigit == '<' {
+			// Long document numbers continue in the optional data, followed
+			// by their check digit
+			rest := field(optional)
+			if rest == "" {
+				return nil, fmt.Errorf("document number truncated")
+			}
+			docNumber += rest[:len(rest)-1]
+			docDigit = rest[len(rest)-1]
+			optional = optional[len(rest):]
+		}
+		m.OptionalData = field(optional)
+		dob, dobDigit = l2[0:6], l2[6]
+		m.Sex = field(l2[7:8])
+		expiry, expiryDigit = l2[8:14], l2[14]
+		m.Nationality = field(l2[15:18])
+		m.Surname, m.GivenNames = names(l3)
+	default:
+		return nil, fmt.Errorf("invalid MRZ length %d", len(s))
+	}
+
+	if err := verify("document number", docNumber, docDigit); err != nil {
+		return nil, err
+	}
+	if err := verify("date of birth", dob, dobDigit); err != nil {
+		return nil, err
+	}
+	if err := verify("date of expiry", expiry, expiryDigit); err != nil {
+		return nil, err
+	}
+	m.DocumentNumber = field(docNumber)
+	m.DateOfBirth = dob
+	m.DateOfExpiry = expiry
+	return m, nil
+}
+
+// BACKey returns the key for Basic Access Control from the MRZ
+func (m *MRZ) BACKey() BACKey {
+	return BACKey{
+		DocumentNumber: m.DocumentNumber,
+		DateOfBirth:    m.DateOfBirth,
+		DateOfExpiry:   m.DateOfExpiry,
+	}
+}
+
+// BACKey is the part of the MRZ that Basic Access Control keys derive from
+type BACKey struct {
+	DocumentNumber string
+	DateOfBirth    string // YYMMDD
+	DateOfExpiry   string // YYMMDD
+}
+
+// information returns the MRZ information: document number, date of birth
+// and date of expiry, each followed by its check digit
+func (k BACKey) information() (string, error) {
+	if len(k.DateOfBirth) != 6 || len(k.DateOfExpiry) != 6 {
+		return "", fmt.Errorf("dates must be YYMMDD")
+	}
+	doc := strings.ToUpper(k.DocumentNumber)
+	for len(doc) < 9 {
+		doc += "<"
+	}
+	info := doc + string(checkDigit(doc))
+	info += k.DateOfBirth + string(checkDigit(k.DateOfBirth))
+	info += k.DateOfExpiry + string(checkDigit(k.DateOfExpiry))
+	return info, nil
+}
@@ -0,0 +1,170 @@
+package passport
+
+import (
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// ApplicationID is the eMRTD LDS1 application
+var ApplicationID = []byte{0xA0, 0x00, 0x00, 0x02, 0x47, 0x10, 0x01}
+
+// Elementary files of the LDS1 application
+const (
+	FileCOM = 0x011E
+	FileSOD = 0x011D
+	FileDG1 = 0x0101
+	FileDG2 = 0x0102
+)
+
+// maxRead is the READ BINARY size that keeps protected responses within a
+// single reader frame
+const maxRead = 0x80
+
+// maxReadExtended is the READ BINARY size where the transport passes
+// extended length APDUs
+const maxReadExtended = 0x400
+
+// maxOffset is the highest offset READ BINARY with an even INS can address
+const maxOffset = 0x7FFF
+
+// Passport reads an electronic machine readable travel document over ISO-DEP
+type Passport struct {
+	card hardware.Transport
+	sm   *secureMessaging
+}
+
+// NewPassport creates a passport handler on the reader's connection
+func NewPassport(reader *hardware.Reader) *Passport {
+	return NewPassportWithTransport(reader.Transport())
+}
+
+// NewPassportWithTransport creates a passport handler on top of any transport.
+// Responses announced with 61 XX are completed with GET RESPONSE.
+func NewPassportWithTransport(transport hardware.Transport) *Passport {
+	return &Passport{card: hardware.NewChainingTransport(transport)}
+}
+
+// SecureMessaging reports whether BAC established secure messaging
+func (p *Passport) SecureMessaging() bool {
+	return p.sm != nil
+}
+
+// command sends an APDU, protected once BAC succeeded, and returns the
+// response data if the status word is 90 00
+func (p *Passport) command(apdu []byte) ([]byte, error) {
+	out := apdu
+	if p.sm != nil {
+		var err error
+		if out, err = p.sm.protect(apdu); err != nil {
+			return nil, err
+		}
+	}
+	rsp, err := p.card.Transmit(out)
+	if err != nil {
+		// The send sequence counter is lost along with the response
+		p.sm = nil
+		return nil, fmt.Errorf("transmit failed: %w", err)
+	}
+	if len(rsp) < 2 {
+		p.sm = nil
+		return nil, fmt.Errorf("invalid response length")
+	}
+	sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]
+	data := rsp[:len(rsp)-2]
+	if p.sm != nil && len(data) == 0 {
+		// An unprotected status means the chip ended secure messaging
+		p.sm = nil
+	}
+	if p.sm != nil {
+		plain, sw, err := p.sm.unprotect(data)
+		if err != nil {
+			p.sm = nil
+			return nil, err
+		}
+		data, sw1, sw2 = plain, byte(sw>>8), byte(sw)
+	}
+	if sw1 != 0x90 || sw2 != 0x00 {
+		return nil, fmt.Errorf("error status: %w", &hardware.StatusError{SW1: sw1, SW2: sw2, Command: apdu})
+	}
+	return data, nil
+}
+
+// SelectApplication selects the LDS1 application
+func (p *Passport) SelectApplication() error {
+	apdu := append([]byte{0x00, 0xA4, 0x04, 0x0C, byte(len(ApplicationID))}, ApplicationID...)
+	if _, err := p.command(apdu); err != nil {
+		return fmt.Errorf("failed to select eMRTD application: %w", err)
+	}
+	return nil
+}
+
+// selectFile selects an elementary file by its ID
+func (p *Passport) selectFile(fid uint16) error {
+	if _, err := p.command([]byte{0x00, 0xA4, 0x02, 0x0C, 0x02, byte(fid >> 8), byte(fid)}); err != nil {
+		return fmt.Errorf("failed to select file %04X: %w", fid, err)
+	}
+	return nil
+}
+
+// readBinary reads n bytes of the selected file at the offset
+func (p *Passport) readBinary(offset int, n int) ([]byte, error) {
+	data, err := p.command(hardware.Command{INS: 0xB0, P1: byte(offset >> 8), P2: byte(offset), Le: n}.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("read binary at %d failed: %w", offset, err)
+	}
+	return data, nil
+}
+
+// ReadFile selects an elementary file and reads it completely. The file
+// length comes from the TLV header of its content.
+func (p *Passport) ReadFile(fid uint16) ([]byte, error) {
+	if err := p.selectFile(fid); err != nil {
+		return nil, err
+	}
+	data, err := p.readBinary(0, 4)
+	if err != nil {
+		return nil, err
+	}
+	_, length, header, err := berHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("file %04X: %w", fid, err)
+	}
+	total := header + length
+	if total > maxOffset {
+		return nil, fmt.Errorf("file %04X too large (%d bytes)", fid, total)
+	}
+	for len(data) < total {
+		n := min(total-len(data), maxRead)
+		if hardware.ExtendedLength(p.card) {
+			n = min(total-len(data), maxReadExtended)
+		}
+		chunk, err := p.readBinary(len(data), n)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) == 0 {
+			return nil, fmt.Errorf("file %04X truncated at %d bytes", fid, len(data))
+		}
+		data = append(data, chunk...)
+	}
+	return data[:total], nil
+}
+
+// ReadDG1 reads and parses the MRZ stored in data group 1
+func (p *Passport) ReadDG1() (*MRZ, error) {
+	data, err := p.ReadFile(FileDG1)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDG1(data)
+}
+
+// ReadDG2 reads the facial images stored in data group 2
+func (p *Passport) ReadDG2() ([]FaceImage, error) {
+	data, err := p.ReadFile(FileDG2)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDG2(data)
+}
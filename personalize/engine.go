@@ -0,0 +1,318 @@
+package personalize
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/oo-developer/acr122u/batch"
+	"github.com/oo-developer/acr122u/config"
+	"github.com/oo-developer/acr122u/desfire/provision"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
+	"github.com/oo-developer/acr122u/ndef"
+	"github.com/oo-developer/acr122u/uid"
+	"gopkg.in/yaml.v3"
+)
+
+// Engine applies a template to cards. It renders the template for each card
+// into the profile of the card family and serves as batch.Profile itself,
+// so the CLI, the daemon and batch stations share it.
+type Engine struct {
+	template *Template
+	resolver *keys.Resolver
+}
+
+// NewEngine creates an engine resolving key references with the resolver,
+// which may be nil for references to the environment and files only
+func NewEngine(template *Template, resolver *keys.Resolver) *Engine {
+	if resolver == nil {
+		resolver = keys.NewResolver(&config.Config{})
+	}
+	return &Engine{template: template, resolver: resolver}
+}
+
+func (e *Engine) Name() string {
+	if e.template.Name == "" {
+		return "template"
+	}
+	return e.template.Name
+}
+
+func (e *Engine) Supports(info *hardware.CardInfo) bool {
+	return e.family(info) != ""
+}
+
+// family returns the section of the template for a card, empty if none
+func (e *Engine) family(info *hardware.CardInfo) string {
+	switch {
+	case e.template.Classic != nil && (&batch.ClassicProfile{}).Supports(info):
+		return "classic"
+	case e.template.NTAG != nil && (&batch.NTAGProfile{}).Supports(info):
+		return "ntag"
+	case e.template.DESFire.Kind != 0 && (&batch.DESFireProfile{}).Supports(info):
+		return "desfire"
+	}
+	return ""
+}
+
+func (e *Engine) Apply(ctx context.Context, reader *hardware.Reader, card *batch.Card) error {
+	profile, err := e.Render(card)
+	if err != nil {
+		return err
+	}
+	return profile.Apply(ctx, reader, card)
+}
+
+func (e *Engine) Verify(ctx context.Context, reader *hardware.Reader, card *batch.Card) error {
+	profile, err := e.Render(card)
+	if err != nil {
+		return err
+	}
+	return profile.Verify(ctx, reader, card)
+}
+
+// Personalize applies the template to the connected card and verifies it,
+// outside of a batch station
+func (e *Engine) Personalize(ctx context.Context, reader *hardware.Reader, serial int) error {
+	card := &batch.Card{Info: reader.CardInfo(), Serial: serial}
+	if !e.Supports(card.Info) {
+		return fmt.Errorf("template %s does not support %s", e.Name(), card.Info.Type)
+	}
+	if err := e.Apply(ctx, reader, card); err != nil {
+		return fmt.Errorf("failed to apply template: %w", err)
+	}
+	if err := e.Verify(ctx, reader, card); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	return nil
+}
+
+// Variables returns the builtin variables and the fields for a card
+func (e *Engine) Variables(card *batch.Card) (map[string]string, error) {
+	vars := map[string]string{
+		"uid":        uid.Hex(card.Info.UID),
+		"serial":     strconv.Itoa(card.Serial),
+		"serial_hex": fmt.Sprintf("%08X", uint32(card.Serial)),
+		"type":       card.Info.Type,
+	}
+	for _, f := range e.template.Fields {
+		value, err := e.field(f, vars)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.Name, err)
+		}
+		vars[f.Name] = value
+	}
+	return vars, nil
+}
+
+func (e *Engine) field(f Field, vars map[string]string) (string, error) {
+	switch {
+	case f.HMAC != "":
+		key, err := expandHex(f.Key, vars)
+		if err != nil {
+			return "", fmt.Errorf("key: %v", err)
+		}
+		data, err := expandHex(f.HMAC, vars)
+		if err != nil {
+			return "", fmt.Errorf("hmac: %v", err)
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		if f.Size > 0 {
+			sum = sum[:f.Size]
+		}
+		return fmt.Sprintf("%X", sum), nil
+	case f.Key != "":
+		key, err := e.key(f.Key, f.Size, vars)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%X", key), nil
+	}
+	return expand(f.Value, vars)
+}
+
+// Render returns the profile of the card family for a card, with the
+// variables expanded and the keys resolved
+func (e *Engine) Render(card *batch.Card) (batch.Profile, error) {
+	family := e.family(card.Info)
+	if family == "" {
+		return nil, fmt.Errorf("template %s does not support %s", e.Name(), card.Info.Type)
+	}
+	vars, err := e.Variables(card)
+	if err != nil {
+		return nil, err
+	}
+	var profile batch.Profile
+	switch family {
+	case "classic":
+		profile, err = e.classic(vars)
+	case "ntag":
+		profile, err = e.ntag(vars)
+	case "desfire":
+		profile, err = e.desfire(vars)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", family, err)
+	}
+	return profile, nil
+}
+
+func (e *Engine) classic(vars map[string]string) (batch.Profile, error) {
+	t := e.template.Classic
+	p := &batch.ClassicProfile{
+		Label:   e.Name(),
+		Blocks:  make(map[int][]byte),
+		Sectors: make(map[int]batch.ClassicSector),
+	}
+	var err error
+	if t.TransportKey != "" {
+		if p.TransportKey, err = e.key(t.TransportKey, 6, vars); err != nil {
+			return nil, fmt.Errorf("transport key: %v", err)
+		}
+	}
+	for block, value := range t.Blocks {
+		data, err := expandHex(value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", block, err)
+		}
+		if len(data) > 16 {
+			return nil, fmt.Errorf("block %d: %d bytes exceed the block", block, len(data))
+		}
+		p.Blocks[block] = append(data, make([]byte, 16-len(data))...)
+	}
+	for sector, s := range t.Sectors {
+		var target batch.ClassicSector
+		if s.KeyA != "" {
+			if target.KeyA, err = e.key(s.KeyA, 6, vars); err != nil {
+				return nil, fmt.Errorf("sector %d key A: %v", sector, err)
+			}
+		}
+		if s.KeyB != "" {
+			if target.KeyB, err = e.key(s.KeyB, 6, vars); err != nil {
+				return nil, fmt.Errorf("sector %d key B: %v", sector, err)
+			}
+		}
+		if s.Access != "" {
+			if target.Access, err = expandHex(s.Access, vars); err != nil || len(target.Access) != 4 {
+				return nil, fmt.Errorf("sector %d: access must be 4 bytes of hex", sector)
+			}
+		}
+		p.Sectors[sector] = target
+	}
+	return p, nil
+}
+
+func (e *Engine) ntag(vars map[string]string) (batch.Profile, error) {
+	t := e.template.NTAG
+	p := &batch.NTAGProfile{Label: e.Name(), Auth0: t.Auth0, AuthLimit: t.AuthLimit}
+	if p.Auth0 == 0 {
+		p.Auth0 = 4
+	}
+	if len(t.Records) > 0 {
+		p.Message = ndef.NewMessage()
+		for i, r := range t.Records {
+			record, err := renderRecord(r, vars)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %v", i+1, err)
+			}
+			p.Message.Records = append(p.Message.Records, record)
+		}
+	}
+	if t.Password != "" {
+		var err error
+		if p.Password, err = e.key(t.Password, 4, vars); err != nil {
+			return nil, fmt.Errorf("password: %v", err)
+		}
+		if p.PACK, err = e.key(t.PACK, 2, vars); err != nil {
+			return nil, fmt.Errorf("pack: %v", err)
+		}
+	}
+	return p, nil
+}
+
+func renderRecord(r Record, vars map[string]string) (ndef.Record, error) {
+	switch {
+	case r.URI != "":
+		uri, err := expand(r.URI, vars)
+		if err != nil {
+			return ndef.Record{}, err
+		}
+		return ndef.NewURIRecord(uri), nil
+	case r.Text != "":
+		text, err := expand(r.Text, vars)
+		if err != nil {
+			return ndef.Record{}, err
+		}
+		lang := r.Lang
+		if lang == "" {
+			lang = "en"
+		}
+		return ndef.NewTextRecord(lang, text)
+	}
+	data, err := expandHex(r.Data, vars)
+	if err != nil {
+		return ndef.Record{}, err
+	}
+	if r.MIME != "" {
+		return ndef.NewMIMERecord(r.MIME, data), nil
+	}
+	return ndef.NewExternalRecord(r.External, data)
+}
+
+func (e *Engine) desfire(vars map[string]string) (batch.Profile, error) {
+	node := copyNode(&e.template.DESFire)
+	var err error
+	walkScalars(node, func(n *yaml.Node) {
+		if err == nil {
+			n.Value, err = expand(n.Value, vars)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var profile provision.Profile
+	if err := node.Decode(&profile); err != nil {
+		return nil, fmt.Errorf("failed to parse profile: %v", err)
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	return &batch.DESFireProfile{Label: e.Name(), Profile: &profile}, nil
+}
+
+// key expands a value and resolves it as hex or key reference
+func (e *Engine) key(value string, size int, vars map[string]string) ([]byte, error) {
+	value, err := expand(value, vars)
+	if err != nil {
+		return nil, err
+	}
+	return e.resolver.Resolve(value, size)
+}
+
+func expandHex(value string, vars map[string]string) ([]byte, error) {
+	value, err := expand(value, vars)
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(cleanHex(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex %q", value)
+	}
+	return data, nil
+}
+
+// copyNode returns a deep copy of a YAML node, which rendering modifies
+func copyNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
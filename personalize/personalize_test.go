@@ -0,0 +1,210 @@
+package personalize_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/oo-developer/acr122u/batch"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ndef"
+	"github.com/oo-developer/acr122u/personalize"
+)
+
+const member = `
+name: member
+fields:
+  - name: master
+    key: env:ACR122U_TEST_MASTER
+    size: 16
+  - name: sector_key
+    hmac: ${uid}
+    key: ${master}
+    size: 6
+  - name: label
+    value: "M-${serial}"
+classic:
+  blocks:
+    4: ${uid}
+    5: ${serial_hex}
+  sectors:
+    1: {key_a: "${sector_key}", access: FF078069}
+ntag:
+  records:
+    - uri: https://example.com/m/${uid}
+    - text: ${label}
+  password: "01020304"
+  pack: "8080"
+desfire:
+  picc_key_type: des
+  picc_key: "0000000000000000"
+  applications:
+    - aid: "010203"
+      key_settings: 0x0F
+      key_type: aes
+      keys:
+        - value: ${master}
+`
+
+var master = []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}
+
+// newEngine returns an engine of the member template
+func newEngine(t *testing.T) *personalize.Engine {
+	t.Helper()
+	t.Setenv("ACR122U_TEST_MASTER", "00112233445566778899AABBCCDDEEFF")
+	template, err := personalize.Parse([]byte(member))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return personalize.NewEngine(template, nil)
+}
+
+// present connects a mock reader to a card
+func present(t *testing.T, card hardware.Transport, atr []byte) *hardware.Reader {
+	t.Helper()
+	reader := mock.NewReader(card, atr)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return reader
+}
+
+func TestClassic(t *testing.T) {
+	e := newEngine(t)
+	uid := []byte{0x01, 0x02, 0x03, 0x04}
+	card, err := mock.NewClassic1K(uid)
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	reader := present(t, card, mock.Classic1KATR)
+	if err := e.Personalize(context.Background(), reader, 7); err != nil {
+		t.Fatalf("Personalize: %v", err)
+	}
+	memory := card.Dump()
+	if !bytes.HasPrefix(memory[4*16:], uid) || !bytes.HasPrefix(memory[5*16:], []byte{0x00, 0x00, 0x00, 0x07}) {
+		t.Fatalf("blocks 4 and 5: % X", memory[4*16:6*16])
+	}
+	mac := hmac.New(sha256.New, master)
+	mac.Write(uid)
+	if key := memory[7*16 : 7*16+6]; !bytes.Equal(key, mac.Sum(nil)[:6]) {
+		t.Fatalf("sector 1 key A % X, want the HMAC of the UID % X", key, mac.Sum(nil)[:6])
+	}
+
+	// The engine is a station profile, and applying it again is harmless
+	station, err := batch.NewStation(batch.Config{Profile: e})
+	if err != nil {
+		t.Fatalf("NewStation: %v", err)
+	}
+	if err := station.Handle(reader); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+}
+
+func TestNTAG(t *testing.T) {
+	e := newEngine(t)
+	card, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	if err := e.Personalize(context.Background(), present(t, card, mock.NTAGATR), 8); err != nil {
+		t.Fatalf("Personalize: %v", err)
+	}
+	msg, err := ndef.FromDump(card.Dump())
+	if err != nil {
+		t.Fatalf("FromDump: %v", err)
+	}
+	if len(msg.Records) != 2 {
+		t.Fatalf("records %v", msg.Records)
+	}
+	if uri, err := msg.Records[0].URI(); err != nil || uri != "https://example.com/m/04010203040506" {
+		t.Errorf("URI %q, %v", uri, err)
+	}
+	if _, text, err := msg.Records[1].Text(); err != nil || text != "M-8" {
+		t.Errorf("text %q, %v", text, err)
+	}
+	if pwd := card.Dump()[0x85*4 : 0x86*4]; !bytes.Equal(pwd, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Errorf("password % X", pwd)
+	}
+}
+
+func TestDESFire(t *testing.T) {
+	e := newEngine(t)
+	card, err := mock.NewDESFire([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	if err != nil {
+		t.Fatalf("NewDESFire: %v", err)
+	}
+	if err := e.Personalize(context.Background(), present(t, card, mock.DESFireATR), 9); err != nil {
+		t.Fatalf("Personalize: %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, template string
+	}{
+		{"no family", "name: x"},
+		{"unknown variable", "fields: [{name: a, value: '${nope}'}]\nntag: {}"},
+		{"field shadowing a variable", "fields: [{name: uid, value: x}]\nntag: {}"},
+		{"record of two kinds", "ntag: {records: [{uri: a, text: b}]}"},
+		{"password without PACK", "ntag: {password: '01020304'}"},
+		{"unknown variable in DESFire", "desfire: {picc_key: '${zz}'}"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := personalize.Parse([]byte(tc.template)); err == nil {
+				t.Fatalf("Parse accepted %q", tc.template)
+			}
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	uid := []byte{0x01, 0x02, 0x03, 0x04}
+	vars, err := newEngine(t).Variables(&batch.Card{Info: &hardware.CardInfo{Type: hardware.MIFARE_CLASSIK_1K, UID: uid}, Serial: 7})
+	if err != nil {
+		t.Fatalf("Variables: %v", err)
+	}
+	mac := hmac.New(sha256.New, master)
+	mac.Write(uid)
+	for name, want := range map[string]string{
+		"uid":        "01020304",
+		"serial":     "7",
+		"serial_hex": "00000007",
+		"type":       hardware.MIFARE_CLASSIK_1K,
+		"master":     "00112233445566778899AABBCCDDEEFF",
+		"sector_key": fmt.Sprintf("%X", mac.Sum(nil)[:6]),
+		"label":      "M-7",
+	} {
+		if vars[name] != want {
+			t.Errorf("%s = %q, want %q", name, vars[name], want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	e := newEngine(t)
+	uid := []byte{0x01, 0x02, 0x03, 0x04}
+	profile, err := e.Render(&batch.Card{Info: &hardware.CardInfo{Type: hardware.MIFARE_CLASSIK_1K, UID: uid}, Serial: 7})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	p, ok := profile.(*batch.ClassicProfile)
+	if !ok {
+		t.Fatalf("rendered a %T", profile)
+	}
+	if !bytes.HasPrefix(p.Blocks[4], uid) || !bytes.Equal(p.Blocks[5][:4], []byte{0x00, 0x00, 0x00, 0x07}) {
+		t.Fatalf("blocks 4 and 5: % X, % X", p.Blocks[4], p.Blocks[5])
+	}
+	mac := hmac.New(sha256.New, master)
+	mac.Write(uid)
+	if key := p.Sectors[1].KeyA; !bytes.Equal(key, mac.Sum(nil)[:6]) {
+		t.Fatalf("sector 1 key A % X, want the HMAC of the UID % X", key, mac.Sum(nil)[:6])
+	}
+
+	if _, err := e.Render(&batch.Card{Info: &hardware.CardInfo{Type: hardware.FELI_CA}}); err == nil {
+		t.Fatal("rendered a template without a FeliCa section for a FeliCa card")
+	}
+}
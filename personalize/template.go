@@ -0,0 +1,280 @@
+package personalize
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template describes the target content of cards, with a section for each
+// card family it supports. It is loaded from YAML:
+//
+//	name: member card
+//	fields:
+//	  - name: master
+//	    key: keyring:member-master
+//	    size: 16
+//	  - name: sector_key
+//	    hmac: ${uid}
+//	    key: ${master}
+//	    size: 6
+//	classic:
+//	  blocks:
+//	    4: ${uid}
+//	    5: ${serial_hex}
+//	  sectors:
+//	    1: {key_a: "${sector_key}", access: FF078069}
+//	ntag:
+//	  records:
+//	    - uri: https://example.com/m/${uid}
+//	  password: keyring:member-pwd
+//	  pack: "8080"
+//
+// String values may refer to the variables uid (hex), serial (decimal),
+// serial_hex (4 bytes as hex) and type, and to the fields, as ${name}.
+type Template struct {
+	Name    string   `yaml:"name"`
+	Fields  []Field  `yaml:"fields"`
+	Classic *Classic `yaml:"classic"`
+	NTAG    *NTAG    `yaml:"ntag"`
+	// DESFire is a provision profile, absent if its kind is zero. Its values
+	// are expanded per card before it is parsed, so keys are given in hex or
+	// as fields.
+	DESFire yaml.Node `yaml:"desfire"`
+}
+
+// Field is a value computed for each card, in the order of the fields: Value
+// with the references expanded, the key a reference like keyring:name stands
+// for, or the HMAC-SHA256 of hex data keyed with the hex Key. Keys and HMACs
+// are hex; Size is the key size, or the HMAC bytes kept (32 if zero).
+type Field struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+	Key   string `yaml:"key"`
+	HMAC  string `yaml:"hmac"`
+	Size  int    `yaml:"size"`
+}
+
+// Classic is the content of MIFARE Classic cards, see batch.ClassicProfile.
+// Block data is hex, padded with zeros to 16 bytes; keys are hex or
+// references.
+type Classic struct {
+	TransportKey string                `yaml:"transport_key"`
+	Blocks       map[int]string        `yaml:"blocks"`
+	Sectors      map[int]ClassicSector `yaml:"sectors"`
+}
+
+// ClassicSector is the target trailer of a sector; empty keys are kept
+type ClassicSector struct {
+	KeyA   string `yaml:"key_a"`
+	KeyB   string `yaml:"key_b"`
+	Access string `yaml:"access"`
+}
+
+// NTAG is the content of NTAG and Ultralight cards, see batch.NTAGProfile.
+// Password and PACK are hex or references.
+type NTAG struct {
+	Records  []Record `yaml:"records"`
+	Password string   `yaml:"password"`
+	PACK     string   `yaml:"pack"`
+	// Auth0 is the first protected page, 4 (all user memory) if zero
+	Auth0     byte `yaml:"auth0"`
+	AuthLimit byte `yaml:"auth_limit"`
+}
+
+// Record is one NDEF record: a URI, a text, or Data in hex with a MIME or
+// external type
+type Record struct {
+	URI      string `yaml:"uri"`
+	Text     string `yaml:"text"`
+	Lang     string `yaml:"lang"` // of texts, en if empty
+	MIME     string `yaml:"mime"`
+	External string `yaml:"external"`
+	Data     string `yaml:"data"`
+}
+
+// variablePattern matches ${name} references, as in scripts
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var fieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// builtins are the variables of every card
+var builtins = []string{"uid", "serial", "serial_hex", "type"}
+
+// Load reads a template from a YAML file
+func Load(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %v", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates a YAML template
+func Parse(data []byte) (*Template, error) {
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validate checks the fields, records and references before a card is
+// touched. The values themselves are checked when they are rendered for a
+// card.
+func (t *Template) Validate() error {
+	if t.Classic == nil && t.NTAG == nil && t.DESFire.Kind == 0 {
+		return fmt.Errorf("template has no card family")
+	}
+	known := make(map[string]bool)
+	for _, name := range builtins {
+		known[name] = true
+	}
+	for i, f := range t.Fields {
+		if err := f.validate(known); err != nil {
+			return fmt.Errorf("field %d (%s): %v", i+1, f.Name, err)
+		}
+		known[f.Name] = true
+	}
+
+	if t.Classic != nil {
+		values := []string{t.Classic.TransportKey}
+		for _, data := range t.Classic.Blocks {
+			values = append(values, data)
+		}
+		for _, s := range t.Classic.Sectors {
+			values = append(values, s.KeyA, s.KeyB, s.Access)
+		}
+		if err := checkReferences(known, values...); err != nil {
+			return fmt.Errorf("classic: %v", err)
+		}
+	}
+	if t.NTAG != nil {
+		for i, r := range t.NTAG.Records {
+			if err := r.validate(); err != nil {
+				return fmt.Errorf("ntag record %d: %v", i+1, err)
+			}
+			if err := checkReferences(known, r.URI, r.Text, r.Data); err != nil {
+				return fmt.Errorf("ntag record %d: %v", i+1, err)
+			}
+		}
+		if (t.NTAG.Password == "") != (t.NTAG.PACK == "") {
+			return fmt.Errorf("ntag: password and pack go together")
+		}
+		if err := checkReferences(known, t.NTAG.Password, t.NTAG.PACK); err != nil {
+			return fmt.Errorf("ntag: %v", err)
+		}
+	}
+	if t.DESFire.Kind != 0 {
+		var err error
+		walkScalars(&t.DESFire, func(n *yaml.Node) {
+			if err == nil {
+				err = checkReferences(known, n.Value)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("desfire: %v", err)
+		}
+	}
+	return nil
+}
+
+func (f Field) validate(known map[string]bool) error {
+	if !fieldName.MatchString(f.Name) {
+		return fmt.Errorf("invalid name")
+	}
+	if known[f.Name] {
+		return fmt.Errorf("defined twice or a builtin")
+	}
+	switch {
+	case f.HMAC != "":
+		if f.Key == "" || f.Value != "" {
+			return fmt.Errorf("hmac needs a key and no value")
+		}
+		if f.Size < 0 || f.Size > 32 {
+			return fmt.Errorf("hmac size must be at most 32")
+		}
+	case f.Key != "":
+		if f.Value != "" {
+			return fmt.Errorf("needs either value or key")
+		}
+		if f.Size <= 0 {
+			return fmt.Errorf("key needs a size")
+		}
+	case f.Value == "":
+		return fmt.Errorf("needs value, key or hmac")
+	}
+	return checkReferences(known, f.Value, f.Key, f.HMAC)
+}
+
+func (r Record) validate() error {
+	kinds := 0
+	for _, v := range []string{r.URI, r.Text, r.MIME, r.External} {
+		if v != "" {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("needs one of uri, text, mime or external")
+	}
+	if r.Data != "" && r.MIME == "" && r.External == "" {
+		return fmt.Errorf("data is only used with mime or external")
+	}
+	return nil
+}
+
+// checkReferences checks that the values refer to known variables only
+func checkReferences(known map[string]bool, values ...string) error {
+	for _, v := range values {
+		for _, m := range variablePattern.FindAllStringSubmatch(v, -1) {
+			if !known[m[1]] {
+				return fmt.Errorf("undefined variable %q", m[1])
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces variable references
+func expand(s string, vars map[string]string) (string, error) {
+	var missing string
+	out := variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := variablePattern.FindStringSubmatch(ref)[1]
+		value, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable %q", missing)
+	}
+	return out, nil
+}
+
+// walkScalars calls f for the scalar values of a node, not the mapping keys
+func walkScalars(n *yaml.Node, f func(*yaml.Node)) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		f(n)
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			walkScalars(n.Content[i], f)
+		}
+	default:
+		for _, c := range n.Content {
+			walkScalars(c, f)
+		}
+	}
+}
+
+// cleanHex removes separators from hex strings, e.g. "04:A2 1B"
+func cleanHex(s string) string {
+	return strings.NewReplacer(" ", "", ":", "", "\t", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,87 @@
+package plus
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+
+	"github.com/oo-developer/acr122u/internal/cmac"
+)
+
+// aesCMAC computes the AES CMAC of data
+func aesCMAC(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cmac.Sum(block, data), nil
+}
+
+// cmac8 truncates the CMAC to its odd-indexed bytes as MIFARE Plus does
+func cmac8(key []byte, data []byte) ([]byte, error) {
+	full, err := aesCMAC(key, data)
+	if err != nil {
+		return nil, err
+	}
+	mac := make([]byte, 8)
+	for i := range mac {
+		mac[i] = full[2*i+1]
+	}
+	return mac, nil
+}
+
+// aesCBC encrypts or decrypts whole blocks with AES-CBC
+func aesCBC(key []byte, iv []byte, data []byte, encrypt bool) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if iv == nil {
+		iv = make([]byte, aes.BlockSize)
+	}
+	out := make([]byte, len(data))
+	if encrypt {
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	} else {
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	}
+	return out, nil
+}
+
+// rotateLeft rotates a random number by one byte
+func rotateLeft(b []byte) []byte {
+	return append(append([]byte{}, b[1:]...), b[0])
+}
+
+// sessionKeys derives the encryption and MAC session keys from both random
+// numbers
+func sessionKeys(key []byte, rndA []byte, rndB []byte) ([]byte, []byte, error) {
+	sv1 := make([]byte, 0, 16)
+	sv1 = append(append(sv1, rndA[11:16]...), rndB[11:16]...)
+	for i := 4; i < 9; i++ {
+		sv1 = append(sv1, rndA[i]^rndB[i])
+	}
+	sv1 = append(sv1, 0x11)
+
+	sv2 := make([]byte, 0, 16)
+	sv2 = append(append(sv2, rndA[7:12]...), rndB[7:12]...)
+	for i := 0; i < 5; i++ {
+		sv2 = append(sv2, rndA[i]^rndB[i])
+	}
+	sv2 = append(sv2, 0x22)
+
+	kEnc, err := aesCBC(key, nil, sv1, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	kMac, err := aesCBC(key, nil, sv2, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kEnc, kMac, nil
+}
+
+// counters encodes the read and write counters little-endian
+func counters(rCtr, wCtr uint16) []byte {
+	return binary.LittleEndian.AppendUint16(binary.LittleEndian.AppendUint16(nil, rCtr), wCtr)
+}
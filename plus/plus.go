@@ -0,0 +1,384 @@
+package plus
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// MIFARE Plus commands
+const (
+	CmdFirstAuthenticate     = 0x70
+	CmdAuthenticatePart2     = 0x72
+	CmdFollowingAuthenticate = 0x76
+	CmdResetAuth             = 0x78
+	CmdReadEncryptedMACed    = 0x31 // MAC on command and response
+	CmdReadPlainMACed        = 0x33
+	CmdWriteEncryptedMACed   = 0xA1 // MAC on response
+	CmdWritePlainMACed       = 0xA3
+	CmdWritePerso            = 0xA8
+	CmdCommitPerso           = 0xAA
+)
+
+// MIFARE Plus status codes
+const (
+	StatusOK                 = 0x90
+	StatusAuthentication     = 0x06
+	StatusCommandOverflow    = 0x07
+	StatusInvalidMAC         = 0x08
+	StatusInvalidBlockNumber = 0x09
+	StatusNonExistingBlock   = 0x0A
+	StatusConditionsOfUse    = 0x0B
+	StatusLength             = 0x0C
+	StatusGeneralFailure     = 0x0F
+)
+
+var statusNames = map[byte]string{
+	StatusAuthentication:     "authentication error",
+	StatusCommandOverflow:    "command overflow",
+	StatusInvalidMAC:         "invalid MAC",
+	StatusInvalidBlockNumber: "invalid block number",
+	StatusNonExistingBlock:   "non-existing block",
+	StatusConditionsOfUse:    "conditions of use not satisfied",
+	StatusLength:             "length error",
+	StatusGeneralFailure:     "general manipulation error",
+}
+
+// Key block numbers
+const (
+	KeyCardMaster        = 0x9000
+	KeyCardConfiguration = 0x9001
+	KeyLevel2Switch      = 0x9002
+	KeyLevel3Switch      = 0x9003
+	KeyLevel1CardAuth    = 0x9004
+	keySectorBase        = 0x4000
+)
+
+// BlockSize is the size of a MIFARE Plus block
+const BlockSize = 16
+
+// maxReadBlocks keeps the data, status and MAC of a read in one frame
+const maxReadBlocks = 13
+
+// SectorKey returns the AES key block number of a sector's key A or B
+func SectorKey(sector int, keyB bool) uint16 {
+	n := uint16(keySectorBase + 2*sector)
+	if keyB {
+		n++
+	}
+	return n
+}
+
+// SecurityLevel is the security level a MIFARE Plus card runs at
+type SecurityLevel int
+
+const (
+	SecurityLevelUnknown SecurityLevel = -1
+	SL0                  SecurityLevel = 0 // personalization, ISO 14443-4
+	SL1                  SecurityLevel = 1 // MIFARE Classic compatible
+	SL2                  SecurityLevel = 2
+	SL3                  SecurityLevel = 3 // AES, ISO 14443-4
+)
+
+func (l SecurityLevel) String() string {
+	if l < SL0 || l > SL3 {
+		return "Unknown"
+	}
+	return fmt.Sprintf("SL%d", int(l))
+}
+
+// CardSecurityLevel returns the security level the reader detected while
+// connecting, SecurityLevelUnknown for other cards
+func CardSecurityLevel(info *hardware.CardInfo) SecurityLevel {
+	for l := SL0; l <= SL3; l++ {
+		if info.SecurityLevel == l.String() {
+			return l
+		}
+	}
+	return SecurityLevelUnknown
+}
+
+// Plus handles MIFARE Plus cards at security level 3
+type Plus struct {
+	card hardware.Transport
+	kEnc []byte
+	kMac []byte
+	ti   []byte // transaction identifier
+	rCtr uint16
+	wCtr uint16
+}
+
+// NewPlus creates a MIFARE Plus handler on the reader's connection
+func NewPlus(reader *hardware.Reader) *Plus {
+	return &Plus{card: reader.Transport()}
+}
+
+// NewPlusWithTransport creates a MIFARE Plus handler on top of any transport
+func NewPlusWithTransport(transport hardware.Transport) *Plus {
+	return &Plus{card: transport}
+}
+
+// Transceive sends a native command and returns the response behind the
+// status byte
+func (p *Plus) Transceive(cmd []byte) ([]byte, error) {
+	rsp, err := p.card.Transmit(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("transmit failed: %w", err)
+	}
+	if len(rsp) < 1 {
+		return nil, fmt.Errorf("empty response")
+	}
+	if rsp[0] != StatusOK {
+		if name, ok := statusNames[rsp[0]]; ok {
+			return nil, fmt.Errorf("MIFARE Plus error %02X: %s", rsp[0], name)
+		}
+		return nil, fmt.Errorf("MIFARE Plus error %02X", rsp[0])
+	}
+	return rsp[1:], nil
+}
+
+// DetectSecurityLevel tells SL0 from SL3 on a card that answers at ISO
+// 14443-4: only SL0 accepts WritePerso and rejects the block number 9090
+// that does not exist
+func (p *Plus) DetectSecurityLevel() (SecurityLevel, error) {
+	cmd := append([]byte{CmdWritePerso, 0x90, 0x90}, make([]byte, BlockSize)...)
+	rsp, err := p.card.Transmit(cmd)
+	if err != nil {
+		return SecurityLevelUnknown, fmt.Errorf("transmit failed: %v", err)
+	}
+	if len(rsp) < 1 {
+		return SecurityLevelUnknown, fmt.Errorf("empty response")
+	}
+	if rsp[0] == StatusInvalidBlockNumber {
+		return SL0, nil
+	}
+	return SL3, nil
+}
+
+// Authenticated reports whether a session with a transaction identifier is
+// established
+func (p *Plus) Authenticated() bool {
+	return p.ti != nil
+}
+
+// FirstAuthenticate starts a new transaction with the AES key stored at the
+// key block number, deriving fresh session keys and resetting the counters
+func (p *Plus) FirstAuthenticate(keyNo uint16, key []byte) error {
+	p.ti = nil
+	cmd := binary.LittleEndian.AppendUint16([]byte{CmdFirstAuthenticate}, keyNo)
+	rsp, rndA, rndB, err := p.authenticate(append(cmd, 0x00), key)
+	if err != nil {
+		return err
+	}
+	// TI, RndA', PICC and PCD capabilities
+	if len(rsp) < 20 || !bytes.Equal(rsp[4:20], rotateLeft(rndA)) {
+		return fmt.Errorf("authentication failed: RndA mismatch")
+	}
+	kEnc, kMac, err := sessionKeys(key, rndA, rndB)
+	if err != nil {
+		return err
+	}
+	p.kEnc, p.kMac = kEnc, kMac
+	p.ti = append([]byte{}, rsp[:4]...)
+	p.rCtr, p.wCtr = 0, 0
+	return nil
+}
+
+// FollowingAuthenticate switches to another key within the transaction,
+// keeping the transaction identifier and counters
+func (p *Plus) FollowingAuthenticate(keyNo uint16, key []byte) error {
+	if p.ti == nil {
+		return fmt.Errorf("no transaction, use FirstAuthenticate")
+	}
+	cmd := binary.LittleEndian.AppendUint16([]byte{CmdFollowingAuthenticate}, keyNo)
+	// A failed authentication ends the transaction on the card
+	rsp, rndA, rndB, err := p.authenticate(cmd, key)
+	if err != nil {
+		p.ti = nil
+		return err
+	}
+	if len(rsp) < 16 || !bytes.Equal(rsp[:16], rotateLeft(rndA)) {
+		p.ti = nil
+		return fmt.Errorf("authentication failed: RndA mismatch")
+	}
+	kEnc, kMac, err := sessionKeys(key, rndA, rndB)
+	if err != nil {
+		return err
+	}
+	p.kEnc, p.kMac = kEnc, kMac
+	return nil
+}
+
+// authenticate runs both authentication steps and returns the decrypted
+// answer to the second step
+func (p *Plus) authenticate(cmd []byte, key []byte) ([]byte, []byte, []byte, error) {
+	if len(key) != 16 {
+		return nil, nil, nil, fmt.Errorf("AES key must be 16 bytes")
+	}
+	rsp, err := p.Transceive(cmd)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	if len(rsp) != 16 {
+		return nil, nil, nil, fmt.Errorf("invalid RndB length %d", len(rsp))
+	}
+	rndB, err := aesCBC(key, nil, rsp, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rndA := make([]byte, 16)
+	if _, err := rand.Read(rndA); err != nil {
+		return nil, nil, nil, err
+	}
+	token, err := aesCBC(key, nil, append(append([]byte{}, rndA...), rotateLeft(rndB)...), true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rsp, err = p.Transceive(append([]byte{CmdAuthenticatePart2}, token...))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	if len(rsp) == 0 || len(rsp)%16 != 0 {
+		return nil, nil, nil, fmt.Errorf("invalid authentication response length %d", len(rsp))
+	}
+	plain, err := aesCBC(key, nil, rsp, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return plain, rndA, rndB, nil
+}
+
+// abort forgets the transaction after a failed command. The card ends it on
+// errors, and after a lost response the counters can no longer be trusted.
+func (p *Plus) abort() {
+	p.ti, p.kEnc, p.kMac = nil, nil, nil
+}
+
+// ResetAuth ends the transaction
+func (p *Plus) ResetAuth() error {
+	p.abort()
+	_, err := p.Transceive([]byte{CmdResetAuth})
+	return err
+}
+
+// mac computes the MAC over a command or response code, a counter, the
+// transaction identifier and the data
+func (p *Plus) mac(code byte, ctr uint16, data []byte) ([]byte, error) {
+	in := binary.LittleEndian.AppendUint16([]byte{code}, ctr)
+	in = append(append(in, p.ti...), data...)
+	return cmac8(p.kMac, in)
+}
+
+// iv returns the IV for data from the reader (transaction identifier first)
+// or from the card (counters first)
+func (p *Plus) iv(fromCard bool) []byte {
+	ctr := counters(p.rCtr, p.wCtr)
+	ctrs := append(append(append([]byte{}, ctr...), ctr...), ctr...)
+	if fromCard {
+		return append(ctrs, p.ti...)
+	}
+	return append(append([]byte{}, p.ti...), ctrs...)
+}
+
+// ReadBlocks reads count blocks starting at the block number, encrypted and
+// with MACs on command and response
+func (p *Plus) ReadBlocks(block uint16, count int) ([]byte, error) {
+	return p.read(CmdReadEncryptedMACed, block, count)
+}
+
+// ReadBlocksPlain reads blocks in plain with MACs on command and response
+func (p *Plus) ReadBlocksPlain(block uint16, count int) ([]byte, error) {
+	return p.read(CmdReadPlainMACed, block, count)
+}
+
+func (p *Plus) read(code byte, block uint16, count int) ([]byte, error) {
+	if p.ti == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	if count < 1 || count > maxReadBlocks {
+		return nil, fmt.Errorf("between 1 and %d blocks allowed", maxReadBlocks)
+	}
+	params := binary.LittleEndian.AppendUint16(nil, block)
+	params = append(params, byte(count))
+	mac, err := p.mac(code, p.rCtr, params)
+	if err != nil {
+		return nil, err
+	}
+	cmd := append(append([]byte{code}, params...), mac...)
+	rsp, err := p.Transceive(cmd)
+	if err != nil {
+		p.abort()
+		return nil, fmt.Errorf("read of block %d failed: %w", block, err)
+	}
+	p.rCtr++
+	if len(rsp) != count*BlockSize+8 {
+		p.abort()
+		return nil, fmt.Errorf("read response length mismatch")
+	}
+	data, rmac := rsp[:count*BlockSize], rsp[count*BlockSize:]
+	expected, err := p.mac(StatusOK, p.rCtr, append(append([]byte{}, params...), data...))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(expected, rmac) {
+		p.abort()
+		return nil, fmt.Errorf("read of block %d: response MAC mismatch", block)
+	}
+	if code == CmdReadPlainMACed {
+		return data, nil
+	}
+	return aesCBC(p.kEnc, p.iv(true), data, false)
+}
+
+// WriteBlock writes a block encrypted with a MAC on command and response
+func (p *Plus) WriteBlock(block uint16, data []byte) error {
+	return p.write(CmdWriteEncryptedMACed, block, data)
+}
+
+// WriteBlockPlain writes a block in plain with a MAC on command and response
+func (p *Plus) WriteBlockPlain(block uint16, data []byte) error {
+	return p.write(CmdWritePlainMACed, block, data)
+}
+
+func (p *Plus) write(code byte, block uint16, data []byte) error {
+	if p.ti == nil {
+		return fmt.Errorf("not authenticated")
+	}
+	if len(data) != BlockSize {
+		return fmt.Errorf("data must be %d bytes", BlockSize)
+	}
+	payload := data
+	if code == CmdWriteEncryptedMACed {
+		var err error
+		if payload, err = aesCBC(p.kEnc, p.iv(false), data, true); err != nil {
+			return err
+		}
+	}
+	params := append(binary.LittleEndian.AppendUint16(nil, block), payload...)
+	mac, err := p.mac(code, p.wCtr, params)
+	if err != nil {
+		return err
+	}
+	rsp, err := p.Transceive(append(append([]byte{code}, params...), mac...))
+	if err != nil {
+		p.abort()
+		return fmt.Errorf("write of block %d failed: %w", block, err)
+	}
+	p.wCtr++
+	if len(rsp) != 8 {
+		p.abort()
+		return fmt.Errorf("write response length mismatch")
+	}
+	expected, err := p.mac(StatusOK, p.wCtr, nil)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, rsp) {
+		p.abort()
+		return fmt.Errorf("write of block %d: response MAC mismatch", block)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package plus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// unhex decodes hex, ignoring spaces
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+// TestCMAC8 truncates the AES-128 CMAC of NIST SP 800-38B, Example 2
+func TestCMAC8(t *testing.T) {
+	key := unhex(t, "2B7E1516 28AED2A6 ABF71588 09CF4F3C")
+	mac, err := cmac8(key, unhex(t, "6BC1BEE2 2E409F96 E93D7E11 7393172A"))
+	if err != nil {
+		t.Fatalf("cmac8: %v", err)
+	}
+	// Odd bytes of 070A16B4 6B4D4144 F79BDD9D D04A287C
+	if want := unhex(t, "0AB44D44 9B9D4A7C"); !bytes.Equal(mac, want) {
+		t.Errorf("cmac8 % X, want % X", mac, want)
+	}
+}
+
+func TestSessionKeys(t *testing.T) {
+	key := unhex(t, "00010203 04050607 08090A0B 0C0D0E0F")
+	rndA := unhex(t, "A0A1A2A3 A4A5A6A7 A8A9AAAB ACADAEAF")
+	rndB := unhex(t, "B0B1B2B3 B4B5B6B7 B8B9BABB BCBDBEBF")
+	kEnc, kMac, err := sessionKeys(key, rndA, rndB)
+	if err != nil {
+		t.Fatalf("sessionKeys: %v", err)
+	}
+	// SV1 ABACADAEAF BBBCBDBEBF 1010101010 11 and
+	// SV2 A7A8A9AAAB B7B8B9BABB 1010101010 22 encrypted with the key
+	if want := unhex(t, "95363CE7 413DB1E2 A1F65729 3EAC7D7A"); !bytes.Equal(kEnc, want) {
+		t.Errorf("Kenc % X, want % X", kEnc, want)
+	}
+	if want := unhex(t, "1E3C186B 9E5E603E 5CFD81D1 47EDC695"); !bytes.Equal(kMac, want) {
+		t.Errorf("Kmac % X, want % X", kMac, want)
+	}
+}
+
+// sl3Card is a MIFARE Plus at security level 3 with a single AES key
+type sl3Card struct {
+	t          *testing.T
+	key        []byte
+	rndB       []byte
+	ti         []byte
+	kEnc, kMac []byte
+	rCtr, wCtr uint16
+	blocks     map[uint16][]byte
+	corrupt    bool // flip a bit of the next read response MAC
+}
+
+func newSL3Card(t *testing.T, key []byte) *sl3Card {
+	return &sl3Card{
+		t:      t,
+		key:    key,
+		rndB:   unhex(t, "B0B1B2B3 B4B5B6B7 B8B9BABB BCBDBEBF"),
+		ti:     []byte{0x11, 0x22, 0x33, 0x44},
+		blocks: map[uint16][]byte{},
+	}
+}
+
+// iv returns the IV of data from the card or from the reader
+func (c *sl3Card) iv(fromCard bool) []byte {
+	ctr := counters(c.rCtr, c.wCtr)
+	ctrs := bytes.Repeat(ctr, 3)
+	if fromCard {
+		return append(ctrs, c.ti...)
+	}
+	return append(append([]byte{}, c.ti...), ctrs...)
+}
+
+// mac computes the MAC over a code, a counter, the transaction identifier
+// and data
+func (c *sl3Card) mac(code byte, ctr uint16, data []byte) []byte {
+	in := binary.LittleEndian.AppendUint16([]byte{code}, ctr)
+	mac, err := cmac8(c.kMac, append(append(in, c.ti...), data...))
+	if err != nil {
+		c.t.Fatalf("cmac8: %v", err)
+	}
+	return mac
+}
+
+// encrypt runs AES-CBC on behalf of the card
+func (c *sl3Card) encrypt(key []byte, iv []byte, data []byte, encrypt bool) []byte {
+	out, err := aesCBC(key, iv, data, encrypt)
+	if err != nil {
+		c.t.Fatalf("aesCBC: %v", err)
+	}
+	return out
+}
+
+func (c *sl3Card) Transmit(cmd []byte) ([]byte, error) {
+	switch cmd[0] {
+	case CmdFirstAuthenticate:
+		return append([]byte{StatusOK}, c.encrypt(c.key, nil, c.rndB, true)...), nil
+	case CmdAuthenticatePart2:
+		plain := c.encrypt(c.key, nil, cmd[1:], false)
+		if !bytes.Equal(plain[16:], rotateLeft(c.rndB)) {
+			return []byte{StatusAuthentication}, nil
+		}
+		rndA := plain[:16]
+		// TI, RndA', PICC and PCD capabilities
+		rsp := append(append(append([]byte{}, c.ti...), rotateLeft(rndA)...), make([]byte, 12)...)
+		var err error
+		if c.kEnc, c.kMac, err = sessionKeys(c.key, rndA, c.rndB); err != nil {
+			c.t.Fatalf("sessionKeys: %v", err)
+		}
+		c.rCtr, c.wCtr = 0, 0
+		return append([]byte{StatusOK}, c.encrypt(c.key, nil, rsp, true)...), nil
+	case CmdReadEncryptedMACed, CmdReadPlainMACed:
+		params, mac := cmd[1:4], cmd[4:]
+		if !bytes.Equal(mac, c.mac(cmd[0], c.rCtr, params)) {
+			return []byte{StatusInvalidMAC}, nil
+		}
+		c.rCtr++
+		block := binary.LittleEndian.Uint16(params)
+		var data []byte
+		for i := range uint16(params[2]) {
+			data = append(data, c.blocks[block+i]...)
+			data = append(data, make([]byte, BlockSize-len(c.blocks[block+i]))...)
+		}
+		if cmd[0] == CmdReadEncryptedMACed {
+			data = c.encrypt(c.kEnc, c.iv(true), data, true)
+		}
+		rmac := c.mac(StatusOK, c.rCtr, append(append([]byte{}, params...), data...))
+		if c.corrupt {
+			rmac[0] ^= 0x01
+			c.corrupt = false
+		}
+		return append(append([]byte{StatusOK}, data...), rmac...), nil
+	case CmdWriteEncryptedMACed, CmdWritePlainMACed:
+		params, mac := cmd[1:3+BlockSize], cmd[3+BlockSize:]
+		if !bytes.Equal(mac, c.mac(cmd[0], c.wCtr, params)) {
+			return []byte{StatusInvalidMAC}, nil
+		}
+		data := params[2:]
+		if cmd[0] == CmdWriteEncryptedMACed {
+			data = c.encrypt(c.kEnc, c.iv(false), data, false)
+		}
+		c.blocks[binary.LittleEndian.Uint16(params)] = append([]byte{}, data...)
+		c.wCtr++
+		return append([]byte{StatusOK}, c.mac(StatusOK, c.wCtr, nil)...), nil
+	}
+	c.t.Fatalf("unexpected command % X", cmd)
+	return nil, nil
+}
+
+func TestSL3(t *testing.T) {
+	key := unhex(t, "00010203 04050607 08090A0B 0C0D0E0F")
+	card := newSL3Card(t, key)
+	p := NewPlusWithTransport(card)
+
+	if err := p.FirstAuthenticate(SectorKey(1, false), bytes.Repeat([]byte{0xFF}, 16)); err == nil {
+		t.Fatalf("FirstAuthenticate with a wrong key succeeded")
+	}
+	if p.Authenticated() {
+		t.Fatalf("authenticated after a failed FirstAuthenticate")
+	}
+	if err := p.FirstAuthenticate(SectorKey(1, false), key); err != nil {
+		t.Fatalf("FirstAuthenticate: %v", err)
+	}
+	if !bytes.Equal(p.ti, card.ti) || !bytes.Equal(p.kEnc, card.kEnc) || !bytes.Equal(p.kMac, card.kMac) {
+		t.Fatalf("TI % X and session keys differ from the card's", p.ti)
+	}
+
+	data := []byte("MIFARE Plus SL3!")
+	if err := p.WriteBlock(4, data); err != nil {
+		t.Fatalf("WriteBlock: %v", err)
+	}
+	if !bytes.Equal(card.blocks[4], data) {
+		t.Fatalf("block 4 on the card % X", card.blocks[4])
+	}
+	plain := bytes.Repeat([]byte{0x5A}, BlockSize)
+	if err := p.WriteBlockPlain(5, plain); err != nil {
+		t.Fatalf("WriteBlockPlain: %v", err)
+	}
+	read, err := p.ReadBlocks(4, 2)
+	if err != nil || !bytes.Equal(read, append(append([]byte{}, data...), plain...)) {
+		t.Fatalf("ReadBlocks: % X, %v", read, err)
+	}
+	if read, err = p.ReadBlocksPlain(5, 1); err != nil || !bytes.Equal(read, plain) {
+		t.Fatalf("ReadBlocksPlain: % X, %v", read, err)
+	}
+	if p.rCtr != 2 || p.wCtr != 2 {
+		t.Errorf("read counter %d, write counter %d", p.rCtr, p.wCtr)
+	}
+
+	// A wrong response MAC ends the transaction
+	card.corrupt = true
+	if _, err := p.ReadBlocks(4, 1); err == nil || !strings.Contains(err.Error(), "response MAC mismatch") {
+		t.Fatalf("ReadBlocks with a wrong response MAC: %v", err)
+	}
+	if p.Authenticated() {
+		t.Fatalf("authenticated after a wrong response MAC")
+	}
+	if err := p.WriteBlock(4, data); err == nil {
+		t.Fatalf("WriteBlock without a transaction succeeded")
+	}
+}
@@ -0,0 +1,5 @@
+// Package pb holds the protocol buffer messages and gRPC stubs of the reader
+// service, generated from acr122u.proto.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative acr122u.proto
@@ -0,0 +1,476 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebfe/scard"
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/ndef"
+	"github.com/oo-developer/acr122u/ntag"
+	"github.com/oo-developer/acr122u/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultChunkSize is the dump chunk size when the request leaves it open
+const defaultChunkSize = 1024
+
+// pollInterval bounds how long Events waits for a reader state change before
+// it looks at new requests
+const pollInterval = 500 * time.Millisecond
+
+var factoryKey = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// Server implements the ReaderService on top of one reader. Calls are
+// serialized since a reader talks to one card at a time; Events connects to
+// arriving cards, so later card operations act on them.
+type Server struct {
+	pb.UnimplementedReaderServiceServer
+	mu        sync.Mutex
+	reader    *hardware.Reader
+	connected bool
+}
+
+// NewServer creates the service for the reader
+func NewServer(reader *hardware.Reader) *Server {
+	return &Server{reader: reader}
+}
+
+// Register adds the service to a gRPC server
+func (s *Server) Register(g *grpc.Server) {
+	pb.RegisterReaderServiceServer(g, s)
+}
+
+func (s *Server) ListReaders(ctx context.Context, req *pb.ListReadersRequest) (*pb.ListReadersResponse, error) {
+	readers, err := s.reader.ListReaders()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.ListReadersResponse{Readers: readers}, nil
+}
+
+func (s *Server) Connect(ctx context.Context, req *pb.ConnectRequest) (*pb.CardInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name, err := s.readerName(req.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.connect(name); err != nil {
+		return nil, err
+	}
+	return s.cardInfo(), nil
+}
+
+// readerName returns the requested reader or the first one
+func (s *Server) readerName(name string) (string, error) {
+	if name != "" {
+		return name, nil
+	}
+	readers, err := s.reader.ListReaders()
+	if err != nil {
+		return "", status.Error(codes.Unavailable, err.Error())
+	}
+	if len(readers) == 0 {
+		return "", status.Error(codes.NotFound, "no readers detected")
+	}
+	return readers[0], nil
+}
+
+// connect connects to the card on the reader; the caller holds the lock
+func (s *Server) connect(name string) error {
+	if s.connected {
+		s.reader.Disconnect()
+		s.connected = false
+	}
+	s.reader.UseReader(name)
+	if err := s.reader.Connect(); err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	s.connected = true
+	return nil
+}
+
+func (s *Server) Disconnect(ctx context.Context, req *pb.DisconnectRequest) (*pb.DisconnectResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connected {
+		s.reader.Disconnect()
+		s.connected = false
+	}
+	return &pb.DisconnectResponse{}, nil
+}
+
+func (s *Server) GetCardInfo(ctx context.Context, req *pb.GetCardInfoRequest) (*pb.CardInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	return s.cardInfo(), nil
+}
+
+// requireCard fails unless a card is connected; the caller holds the lock
+func (s *Server) requireCard() error {
+	if !s.connected {
+		return status.Error(codes.FailedPrecondition, "no card connected, call Connect first")
+	}
+	return nil
+}
+
+func (s *Server) cardInfo() *pb.CardInfo {
+	info := s.reader.CardInfo()
+	return &pb.CardInfo{
+		Reader:        s.reader.Reader(),
+		Type:          info.Type,
+		Uid:           info.UID,
+		Atr:           info.ATR,
+		Sak:           uint32(info.SAK),
+		Atqa:          info.ATQA,
+		Capacity:      int32(info.Capacity),
+		Protocol:      info.Protocol,
+		AtrCardName:   info.ATRCardName,
+		Atqb:          info.ATQB,
+		SecurityLevel: info.SecurityLevel,
+	}
+}
+
+func (s *Server) Transmit(ctx context.Context, req *pb.TransmitRequest) (*pb.TransmitResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	if len(req.Apdu) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty APDU")
+	}
+	rsp, err := s.reader.Transport().Transmit(req.Apdu)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.TransmitResponse{Response: rsp}, nil
+}
+
+// classicKey returns the key (the factory key if empty) and the key type
+func classicKey(key []byte, keyType pb.KeyType) ([]byte, byte, error) {
+	if len(key) == 0 {
+		key = factoryKey
+	}
+	if len(key) != 6 {
+		return nil, 0, status.Error(codes.InvalidArgument, "key must be 6 bytes")
+	}
+	if keyType == pb.KeyType_KEY_TYPE_B {
+		return key, classic.KeyTypeB, nil
+	}
+	return key, classic.KeyTypeA, nil
+}
+
+// authenticateBlock loads the key and authenticates the sector of the block
+func authenticateBlock(c *classic.Classic, block uint32, key []byte, keyType pb.KeyType) error {
+	if block > 0xFF {
+		return status.Error(codes.InvalidArgument, "block out of range")
+	}
+	key, kt, err := classicKey(key, keyType)
+	if err != nil {
+		return err
+	}
+	if err := c.LoadKey(0x00, key); err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if err := c.Authenticate(byte(block), kt, 0x00); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+func (s *Server) ReadBlock(ctx context.Context, req *pb.ReadBlockRequest) (*pb.ReadBlockResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	c := classic.NewClassic(s.reader)
+	if err := authenticateBlock(c, req.Block, req.Key, req.KeyType); err != nil {
+		return nil, err
+	}
+	data, err := c.ReadBlock(byte(req.Block))
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.ReadBlockResponse{Data: data}, nil
+}
+
+func (s *Server) WriteBlock(ctx context.Context, req *pb.WriteBlockRequest) (*pb.WriteBlockResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	if len(req.Data) != 16 {
+		return nil, status.Error(codes.InvalidArgument, "data must be 16 bytes")
+	}
+	c := classic.NewClassic(s.reader)
+	if err := authenticateBlock(c, req.Block, req.Key, req.KeyType); err != nil {
+		return nil, err
+	}
+	if err := c.WriteBlock(byte(req.Block), req.Data); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.WriteBlockResponse{}, nil
+}
+
+func (s *Server) ReadPage(ctx context.Context, req *pb.ReadPageRequest) (*pb.ReadPageResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	if req.Page > 0xFF {
+		return nil, status.Error(codes.InvalidArgument, "page out of range")
+	}
+	data, err := ntag.NewNTAG(s.reader).ReadPage(byte(req.Page))
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.ReadPageResponse{Data: data}, nil
+}
+
+func (s *Server) WritePage(ctx context.Context, req *pb.WritePageRequest) (*pb.WritePageResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	if req.Page > 0xFF {
+		return nil, status.Error(codes.InvalidArgument, "page out of range")
+	}
+	if len(req.Data) != 4 {
+		return nil, status.Error(codes.InvalidArgument, "data must be 4 bytes")
+	}
+	if err := ntag.NewNTAG(s.reader).WritePage(byte(req.Page), req.Data); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.WritePageResponse{}, nil
+}
+
+func (s *Server) ReadNDEF(ctx context.Context, req *pb.ReadNDEFRequest) (*pb.NDEFMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	msg, err := ndef.ReadFromTag(s.reader)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	raw, err := msg.Encode()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	out := &pb.NDEFMessage{Message: raw}
+	for _, r := range msg.Records {
+		out.Records = append(out.Records, &pb.NDEFRecord{Tnf: uint32(r.TNF), Type: r.Type, Id: r.ID, Payload: r.Payload})
+	}
+	return out, nil
+}
+
+func (s *Server) WriteNDEF(ctx context.Context, req *pb.WriteNDEFRequest) (*pb.WriteNDEFResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	msg, err := ndef.Parse(req.Message)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := ndef.WriteToTagContext(ctx, s.reader, msg, req.Verify, nil); err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.WriteNDEFResponse{}, nil
+}
+
+func (s *Server) ReadDump(req *pb.ReadDumpRequest, stream pb.ReaderService_ReadDumpServer) error {
+	dump, err := s.dump(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	size := int(req.ChunkSize)
+	if size == 0 {
+		size = defaultChunkSize
+	}
+	for offset := 0; offset < len(dump); offset += size {
+		end := min(offset+size, len(dump))
+		chunk := &pb.DumpChunk{Offset: uint32(offset), Data: dump[offset:end], TotalSize: uint32(len(dump))}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dump reads the memory of the connected card. Cancelling ctx stops the
+// dump between two reads.
+func (s *Server) dump(ctx context.Context, req *pb.ReadDumpRequest) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireCard(); err != nil {
+		return nil, err
+	}
+	info := s.reader.CardInfo()
+	switch {
+	case strings.HasPrefix(info.Type, hardware.NTAG), strings.HasPrefix(info.Type, hardware.MIFARE_ULTRALIGHT):
+		dump, err := ntag.NewNTAG(s.reader).DumpMemoryContext(ctx, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return dump, nil
+	case classic.Supported(info):
+		return s.classicDump(ctx, info, req.Key, req.KeyType)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "no dump for card type %q", info.Type)
+}
+
+// classicDump reads all blocks, authenticating each sector with the key
+func (s *Server) classicDump(ctx context.Context, info *hardware.CardInfo, key []byte, keyType pb.KeyType) ([]byte, error) {
+	blocks := info.Capacity / 16
+	if blocks == 0 {
+		blocks = 64
+	}
+	c := classic.NewClassic(s.reader)
+	dump := make([]byte, 0, blocks*16)
+	for block := 0; block < blocks; block++ {
+		// Sectors hold 4 blocks, from sector 32 on 16 blocks
+		if (block < 128 && block%4 == 0) || (block >= 128 && block%16 == 0) {
+			if err := ctx.Err(); err != nil {
+				return nil, status.FromContextError(err).Err()
+			}
+			if err := authenticateBlock(c, uint32(block), key, keyType); err != nil {
+				return nil, err
+			}
+		}
+		data, err := c.ReadBlock(byte(block))
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		dump = append(dump, data...)
+	}
+	return dump, nil
+}
+
+func (s *Server) Events(stream pb.ReaderService_EventsServer) error {
+	sctx, err := scard.EstablishContext()
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer sctx.Release()
+
+	ctx := stream.Context()
+	requests := make(chan *pb.EventsRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var reader string
+	state := scard.StateUnaware
+	for {
+		// Block until the first request, then only look for new ones
+		var wait <-chan time.Time
+		if reader != "" {
+			wait = time.After(0)
+		}
+		select {
+		case req := <-requests:
+			if req.Stop {
+				return nil
+			}
+			name, err := s.lockedReaderName(req.Reader)
+			if err != nil {
+				return err
+			}
+			reader, state = name, scard.StateUnaware
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-wait:
+		}
+
+		states := []scard.ReaderState{{Reader: reader, CurrentState: state}}
+		if err := sctx.GetStatusChange(states, pollInterval); err != nil {
+			if errors.Is(err, scard.ErrTimeout) {
+				continue
+			}
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		newState := states[0].EventState &^ scard.StateChanged
+		present := newState&scard.StatePresent != 0
+		wasPresent := state&scard.StatePresent != 0
+		state = newState
+
+		var event *pb.Event
+		switch {
+		case present && !wasPresent:
+			card, err := s.connectEvent(reader)
+			if err != nil {
+				// A card pulled away while connecting shows up as removed next
+				continue
+			}
+			event = &pb.Event{Type: pb.Event_TYPE_CARD_PRESENT, Card: card}
+		case !present && wasPresent:
+			event = &pb.Event{Type: pb.Event_TYPE_CARD_REMOVED}
+		default:
+			continue
+		}
+		event.Reader = reader
+		event.Time = timestamppb.Now()
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) lockedReaderName(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readerName(name)
+}
+
+// connectEvent connects to a card that arrived on the reader
+func (s *Server) connectEvent(reader string) (*pb.CardInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.connect(reader); err != nil {
+		return nil, err
+	}
+	return s.cardInfo(), nil
+}
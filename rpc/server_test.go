@@ -0,0 +1,316 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ndef"
+	"github.com/oo-developer/acr122u/rpc"
+	"github.com/oo-developer/acr122u/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var ntagUID = []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+// newClient serves a mock reader with the card over an in-memory listener
+// and returns a client of it
+func newClient(t *testing.T, card hardware.Transport, atr []byte) pb.ReaderServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 16)
+	g := grpc.NewServer()
+	rpc.NewServer(mock.NewReader(card, atr)).Register(g)
+	go g.Serve(listener)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewReaderServiceClient(conn)
+}
+
+// connect connects the server to the card
+func connect(t *testing.T, client pb.ReaderServiceClient) *pb.CardInfo {
+	t.Helper()
+	info, err := client.Connect(context.Background(), &pb.ConnectRequest{Reader: "mock"})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return info
+}
+
+// checkCode fails unless err is a status with the code
+func checkCode(t *testing.T, what string, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Errorf("%s: %v, want code %s", what, err, code)
+	}
+}
+
+func TestCardInfo(t *testing.T) {
+	card, err := mock.NewNTAG215(ntagUID)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	client := newClient(t, card, mock.NTAGATR)
+	ctx := context.Background()
+	_, err = client.GetCardInfo(ctx, &pb.GetCardInfoRequest{})
+	checkCode(t, "GetCardInfo before Connect", err, codes.FailedPrecondition)
+	// The mock reader has no PC/SC context to list readers with
+	_, err = client.ListReaders(ctx, &pb.ListReadersRequest{})
+	checkCode(t, "ListReaders", err, codes.Unavailable)
+	_, err = client.Connect(ctx, &pb.ConnectRequest{})
+	checkCode(t, "Connect to the first reader", err, codes.Unavailable)
+
+	info := connect(t, client)
+	if info.Reader != "mock" || !strings.HasPrefix(info.Type, hardware.NTAG) || !bytes.Equal(info.Uid, ntagUID) ||
+		!bytes.Equal(info.Atr, mock.NTAGATR) {
+		t.Errorf("Connect = %v", info)
+	}
+	got, err := client.GetCardInfo(ctx, &pb.GetCardInfoRequest{})
+	if err != nil || got.Type != info.Type || !bytes.Equal(got.Uid, ntagUID) {
+		t.Errorf("GetCardInfo = %v, %v", got, err)
+	}
+
+	if _, err := client.Disconnect(ctx, &pb.DisconnectRequest{}); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	_, err = client.Transmit(ctx, &pb.TransmitRequest{Apdu: []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}})
+	checkCode(t, "Transmit after Disconnect", err, codes.FailedPrecondition)
+}
+
+func TestPages(t *testing.T) {
+	card, err := mock.NewNTAG215(ntagUID)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	client := newClient(t, card, mock.NTAGATR)
+	connect(t, client)
+	ctx := context.Background()
+
+	data := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+	if _, err := client.WritePage(ctx, &pb.WritePageRequest{Page: 8, Data: data}); err != nil {
+		t.Fatalf("WritePage: %v", err)
+	}
+	rsp, err := client.ReadPage(ctx, &pb.ReadPageRequest{Page: 8})
+	if err != nil {
+		t.Fatalf("ReadPage: %v", err)
+	}
+	if !bytes.HasPrefix(rsp.Data, data) || !bytes.Equal(card.Dump()[32:36], data) {
+		t.Errorf("ReadPage = % X", rsp.Data)
+	}
+	tx, err := client.Transmit(ctx, &pb.TransmitRequest{Apdu: []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}})
+	if err != nil || !bytes.Equal(tx.Response, append(append([]byte{}, ntagUID...), 0x90, 0x00)) {
+		t.Errorf("Transmit Get Data = %v, %v", tx, err)
+	}
+
+	_, err = client.WritePage(ctx, &pb.WritePageRequest{Page: 8, Data: data[:3]})
+	checkCode(t, "WritePage of 3 bytes", err, codes.InvalidArgument)
+	_, err = client.ReadPage(ctx, &pb.ReadPageRequest{Page: 0x100})
+	checkCode(t, "ReadPage 100h", err, codes.InvalidArgument)
+	_, err = client.Transmit(ctx, &pb.TransmitRequest{})
+	checkCode(t, "Transmit of no APDU", err, codes.InvalidArgument)
+	// Page 0 holds the UID and is read-only
+	_, err = client.WritePage(ctx, &pb.WritePageRequest{Page: 0, Data: data})
+	checkCode(t, "WritePage 0", err, codes.Unavailable)
+}
+
+func TestBlocks(t *testing.T) {
+	card, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	client := newClient(t, card, mock.Classic1KATR)
+	connect(t, client)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte{0x5A}, 16)
+	if _, err := client.WriteBlock(ctx, &pb.WriteBlockRequest{Block: 5, Data: data}); err != nil {
+		t.Fatalf("WriteBlock: %v", err)
+	}
+	rsp, err := client.ReadBlock(ctx, &pb.ReadBlockRequest{Block: 5})
+	if err != nil || !bytes.Equal(rsp.Data, data) {
+		t.Fatalf("ReadBlock = %v, %v", rsp, err)
+	}
+
+	_, err = client.ReadBlock(ctx, &pb.ReadBlockRequest{Block: 5, Key: make([]byte, 6)})
+	checkCode(t, "ReadBlock with a wrong key", err, codes.PermissionDenied)
+	_, err = client.ReadBlock(ctx, &pb.ReadBlockRequest{Block: 5, Key: make([]byte, 5)})
+	checkCode(t, "ReadBlock with a 5-byte key", err, codes.InvalidArgument)
+	_, err = client.WriteBlock(ctx, &pb.WriteBlockRequest{Block: 5, Data: data[:15]})
+	checkCode(t, "WriteBlock of 15 bytes", err, codes.InvalidArgument)
+	_, err = client.ReadBlock(ctx, &pb.ReadBlockRequest{Block: 0x100})
+	checkCode(t, "ReadBlock 100h", err, codes.InvalidArgument)
+}
+
+func TestNDEF(t *testing.T) {
+	card, err := mock.NewNTAG215(ntagUID)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	client := newClient(t, card, mock.NTAGATR)
+	connect(t, client)
+	ctx := context.Background()
+
+	raw, err := ndef.NewMessage(ndef.NewURIRecord("https://example.com")).Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if _, err := client.WriteNDEF(ctx, &pb.WriteNDEFRequest{Message: raw, Verify: true}); err != nil {
+		t.Fatalf("WriteNDEF: %v", err)
+	}
+	msg, err := client.ReadNDEF(ctx, &pb.ReadNDEFRequest{})
+	if err != nil {
+		t.Fatalf("ReadNDEF: %v", err)
+	}
+	if !bytes.Equal(msg.Message, raw) || len(msg.Records) != 1 || string(msg.Records[0].Type) != "U" ||
+		msg.Records[0].Tnf != uint32(ndef.TNFWellKnown) {
+		t.Errorf("ReadNDEF = %v", msg)
+	}
+	_, err = client.WriteNDEF(ctx, &pb.WriteNDEFRequest{Message: []byte{0xD1, 0x01}})
+	checkCode(t, "WriteNDEF of a truncated message", err, codes.InvalidArgument)
+}
+
+// readDump collects the chunks of a dump, checking their offsets and total
+// size, and returns the dump and the number of chunks
+func readDump(t *testing.T, client pb.ReaderServiceClient, req *pb.ReadDumpRequest) ([]byte, int) {
+	t.Helper()
+	stream, err := client.ReadDump(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ReadDump: %v", err)
+	}
+	var dump []byte
+	chunks, total := 0, 0
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if chunks == 0 {
+			total = int(chunk.TotalSize)
+		}
+		if int(chunk.Offset) != len(dump) || int(chunk.TotalSize) != total {
+			t.Fatalf("chunk at %d of %d after %d bytes", chunk.Offset, chunk.TotalSize, len(dump))
+		}
+		dump = append(dump, chunk.Data...)
+		chunks++
+	}
+	if len(dump) != total {
+		t.Fatalf("dump of %d bytes, total size %d", len(dump), total)
+	}
+	return dump, chunks
+}
+
+func TestReadDump(t *testing.T) {
+	tag, err := mock.NewNTAG215(ntagUID)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	client := newClient(t, tag, mock.NTAGATR)
+	connect(t, client)
+	// 135 pages of 4 bytes in chunks of 100, PWD and PACK read as zeros
+	dump, chunks := readDump(t, client, &pb.ReadDumpRequest{ChunkSize: 100})
+	if len(dump) != 540 || chunks != 6 || !bytes.Equal(dump[:0x85*4], tag.Dump()[:0x85*4]) {
+		t.Errorf("dump of %d bytes in %d chunks", len(dump), chunks)
+	}
+
+	classic, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	client = newClient(t, classic, mock.Classic1KATR)
+	connect(t, client)
+	dump, chunks = readDump(t, client, &pb.ReadDumpRequest{})
+	if len(dump) != 1024 || chunks != 1 || !bytes.Equal(dump[:16], classic.Dump()[:16]) {
+		t.Errorf("dump of %d bytes in %d chunks", len(dump), chunks)
+	}
+	stream, err := client.ReadDump(context.Background(), &pb.ReadDumpRequest{Key: make([]byte, 6)})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	checkCode(t, "ReadDump with a wrong key", err, codes.PermissionDenied)
+}
+
+// dumpStream is the server side of a ReadDump call that only counts chunks
+type dumpStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	chunks int
+}
+
+func (s *dumpStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *dumpStream) Send(*pb.DumpChunk) error {
+	s.chunks++
+	return nil
+}
+
+// cancelling cancels the call after a number of exchanges with the card
+type cancelling struct {
+	card   hardware.Transport
+	after  int
+	cancel context.CancelFunc
+}
+
+func (c *cancelling) Transmit(cmd []byte) ([]byte, error) {
+	if c.after--; c.after == 0 {
+		c.cancel()
+	}
+	return c.card.Transmit(cmd)
+}
+
+func TestReadDumpCancel(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		atr  []byte
+		card func() (hardware.Transport, error)
+	}{
+		{"NTAG", mock.NTAGATR, func() (hardware.Transport, error) { return mock.NewNTAG215(ntagUID) }},
+		{"Classic", mock.Classic1KATR, func() (hardware.Transport, error) {
+			return mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			card, err := tc.card()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			wrapped := &cancelling{card: card, cancel: cancel}
+			server := rpc.NewServer(mock.NewReader(wrapped, tc.atr))
+			if _, err := server.Connect(context.Background(), &pb.ConnectRequest{Reader: "mock"}); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			// Cancelled by the client after the first read of the dump
+			wrapped.after = 2
+			stream := &dumpStream{ctx: ctx}
+			err = server.ReadDump(&pb.ReadDumpRequest{}, stream)
+			checkCode(t, "ReadDump", err, codes.Canceled)
+			if stream.chunks != 0 {
+				t.Errorf("%d chunks sent", stream.chunks)
+			}
+		})
+	}
+}
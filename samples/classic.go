@@ -2,12 +2,12 @@ package samples
 
 import (
 	"encoding/hex"
-	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/oo-developer/acr122u/classic"
 	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/keys"
 )
 
 func ClassicSample(reader *hardware.Reader) {
@@ -15,63 +15,59 @@ func ClassicSample(reader *hardware.Reader) {
 
 	blockNum := byte(4)
 	key := classicReader.TryStandardKeys(blockNum, classic.KeyTypeA)
-	fmt.Printf("[OK] Default key found: %s\n", key)
-	fmt.Printf("[OK] Key: %s\n", hex.EncodeToString(classic.DefaultKeys[key].KeyA))
+	slog.Info("default key found", "name", key)
 
-	// Default MIFARE Classic key (all 0xFF)
-	defaultKey := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
-	//defaultKey := []byte{0x12, 0x34, 0xAB, 0xCD, 0xEF, 0x12}
+	// The key comes from the OS keyring, stored beforehand with
+	// keys.NewKeyring("acr122u").Store("sample-key-a", key)
+	sampleKey, err := keys.NewKeyring(keys.DefaultKeyringService).Secret("sample-key-a")
+	if err != nil {
+		slog.Error("failed to read key", "error", err)
+		os.Exit(1)
+	}
 
-	fmt.Println("[OK] Loading authentication key...")
-	if err := classicReader.LoadKey(0x00, defaultKey); err != nil {
-		log.Printf("[ERROR] Failed to load key: %v\n", err)
+	if err := classicReader.LoadKey(0x00, sampleKey); err != nil {
+		slog.Error("failed to load key", "error", err)
 		os.Exit(1)
 	}
 
 	//blockNum := byte(4)
-	fmt.Printf("[OK] Authenticating block %d...\n", blockNum)
 	if err := classicReader.Authenticate(blockNum, classic.KeyTypeA, 0x00); err != nil {
-		fmt.Printf("[ERROR] Authentication failed: %v\n", err)
+		slog.Error("authentication failed", "block", blockNum, "error", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("[OK] Reading block %d...\n", blockNum)
 	data, err := classicReader.ReadBlock(blockNum)
 	if err != nil {
-		fmt.Printf("[ERROR] Read failed: %v\n", err)
+		slog.Error("read failed", "block", blockNum, "error", err)
 		os.Exit(1)
 	}
-	fmt.Printf("[OK] Block %d data: %s\n", blockNum, hex.EncodeToString(data))
-	fmt.Printf("[OK] Block %d ASCII: %q\n", blockNum, data)
+	slog.Info("block read", "block", blockNum, "data", hex.EncodeToString(data), "ascii", string(data))
 
 	newData := []byte("1c00901100b0020A") // Must be exactly 16 bytes
 	if len(newData) != 16 {
-		fmt.Println("Data must be 16 bytes")
+		slog.Error("data must be 16 bytes")
 		os.Exit(1)
 	}
 
-	fmt.Printf("[OK] Writing to block %d...\n", blockNum)
 	if err := classicReader.WriteBlock(blockNum, newData); err != nil {
-		fmt.Printf("[ERROR] Write failed: %v\n", err)
+		slog.Error("write failed", "block", blockNum, "error", err)
 		os.Exit(1)
 	}
-	fmt.Println("[OK] Write successful!")
+	slog.Info("block written", "block", blockNum)
 
-	fmt.Printf("[OK] Reading block %d again to verify...\n", blockNum)
 	verifyData, err := classicReader.ReadBlock(blockNum)
 	if err != nil {
-		fmt.Printf("[ERROR] Verify read failed: %v\n", err)
+		slog.Error("verify read failed", "block", blockNum, "error", err)
 		os.Exit(1)
 	}
-	fmt.Printf("[OK] Verified data: %s\n", hex.EncodeToString(verifyData))
-	fmt.Printf("[OK] Verified ASCII: %q\n", verifyData)
+	slog.Info("block verified", "block", blockNum, "data", hex.EncodeToString(verifyData), "ascii", string(verifyData))
 
-	fmt.Println("[OK] Dumping card ... (64 blocks)")
+	slog.Info("dumping card", "blocks", 64)
 	for ii := 0; ii < 64; ii++ {
 		classicReader.Authenticate(byte(ii), classic.KeyTypeA, 0x00)
 		data, err := classicReader.ReadBlock(byte(ii))
 		if err == nil {
-			fmt.Printf("    [%02d] %s\n", ii, hex.EncodeToString(data))
+			slog.Info("block", "block", ii, "data", hex.EncodeToString(data))
 		}
 	}
 }
@@ -2,8 +2,7 @@ package samples
 
 import (
 	"encoding/hex"
-	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/oo-developer/acr122u/hardware"
 	"github.com/oo-developer/acr122u/ntag"
@@ -13,20 +12,24 @@ func NtagSample(reader *hardware.Reader) {
 	ntagReader := ntag.NewNTAG(reader)
 
 	page0, err := ntagReader.ReadPages(0)
-	fmt.Printf("[OK] Pages 0: %s\n", hex.EncodeToString(page0))
-	page0s, err := ntagReader.ReadPage(0)
-	page1s, err := ntagReader.ReadPage(1)
-	page2s, err := ntagReader.ReadPage(2)
-	page3s, err := ntagReader.ReadPage(3)
-	fmt.Printf("[OK] Page 0: %s\n", hex.EncodeToString(page0s))
-	fmt.Printf("[OK] Page 1: %s\n", hex.EncodeToString(page1s))
-	fmt.Printf("[OK] Page 2: %s\n", hex.EncodeToString(page2s))
-	fmt.Printf("[OK] Page 3: %s\n", hex.EncodeToString(page3s))
+	if err != nil {
+		slog.Error("failed to read pages", "start", 0, "error", err)
+		return
+	}
+	slog.Info("read pages", "start", 0, "data", hex.EncodeToString(page0))
+	for page := byte(0); page < 4; page++ {
+		data, err := ntagReader.ReadPage(page)
+		if err != nil {
+			slog.Error("failed to read page", "page", page, "error", err)
+			continue
+		}
+		slog.Info("read page", "page", page, "data", hex.EncodeToString(data))
+	}
 
 	ntagType, err := ntagReader.DetectChipType()
 	if err != nil {
-		log.Printf("[ERROR] Failed to detect chip type: %v\n", err)
+		slog.Error("failed to detect chip type", "error", err)
 		return
 	}
-	fmt.Printf("[OK] Chip type: %s\n", ntagType.Name)
+	slog.Info("chip detected", "type", ntagType.Name)
 }
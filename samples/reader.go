@@ -1,18 +1,17 @@
 package samples
 
 import (
-	"encoding/hex"
-	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/uid"
 )
 
 func main() {
 	reader, err := hardware.NewReader()
 	if err != nil {
-		fmt.Printf("[ERROR] Failed to create hardware: %v\n", err)
+		slog.Error("failed to create reader", "error", err)
 		os.Exit(1)
 	}
 	defer reader.Close()
@@ -20,35 +19,28 @@ func main() {
 	// List available readers
 	readers, err := reader.ListReaders()
 	if err != nil {
-		log.Printf("[ERROR] Failed to list readers: %v\n", err)
+		slog.Error("failed to list readers", "error", err)
 		os.Exit(1)
 	}
-
-	fmt.Println("[OK] Available readers:")
 	if len(readers) == 0 {
-		fmt.Println("[ERROR] No readers detected")
+		slog.Error("no readers detected")
 		os.Exit(1)
 	}
-	for i, r := range readers {
-		fmt.Printf("     %d: %s\n", i, r)
-	}
+	slog.Info("available readers", "readers", readers)
 	reader.UseReader(readers[0])
 
-	fmt.Println("[OK] Waiting for card ...")
+	slog.Info("waiting for card")
 	err = reader.WaitForCard()
 	if err != nil {
-		fmt.Printf("[ERROR] Failed to wait for card: %v\n", err)
+		slog.Error("failed to wait for card", "error", err)
 		os.Exit(1)
 	}
-	fmt.Println("[OK] Card ready")
 
 	// Connect to card
-	fmt.Println("[OK] Connecting to card ...")
 	if err := reader.Connect(); err != nil {
-		log.Printf("[ERROR] Failed to connect: %v\n", err)
+		slog.Error("failed to connect", "error", err)
 		os.Exit(1)
 	}
-	fmt.Println("[OK] Connected!")
-	fmt.Printf("[OK] Card UID : %s\n", hex.EncodeToString(reader.CardInfo().UID))
-	fmt.Printf("[OK] Card type: %s\n", reader.CardInfo().Type)
+	info := reader.CardInfo()
+	slog.Info("card connected", "uid", uid.Hex(info.UID), "type", info.Type)
 }
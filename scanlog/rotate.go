@@ -0,0 +1,130 @@
+package scanlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rotatingFile appends to a file and moves it aside when it gets too large or
+// the day changes. New files start with the header.
+type rotatingFile struct {
+	config Config
+	header []byte
+	file   *os.File
+	size   int64
+	opened time.Time // day of the current file
+}
+
+func openRotatingFile(config Config, header []byte) (*rotatingFile, error) {
+	f := &rotatingFile{config: config, header: header}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open scan log: %v", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open scan log: %v", err)
+	}
+	f.file, f.size = file, stat.Size()
+	// An existing file belongs to the day it was last written
+	f.opened = stat.ModTime()
+	if f.size == 0 {
+		f.opened = time.Now()
+		if len(f.header) > 0 {
+			if _, err := file.Write(f.header); err != nil {
+				return fmt.Errorf("failed to write scan log: %v", err)
+			}
+			f.size = int64(len(f.header))
+		}
+	}
+	return nil
+}
+
+func (f *rotatingFile) write(t time.Time, data []byte) error {
+	if f.due(t, len(data)) {
+		if err := f.rotate(t); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write scan log: %v", err)
+	}
+	return nil
+}
+
+func (f *rotatingFile) due(t time.Time, n int) bool {
+	if f.size <= int64(len(f.header)) {
+		return false
+	}
+	if f.config.MaxSize > 0 && f.size+int64(n) > f.config.MaxSize {
+		return true
+	}
+	if f.config.Daily {
+		y1, m1, d1 := f.opened.Date()
+		y2, m2, d2 := t.Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+	return false
+}
+
+// backupName inserts the time before the extension
+func (f *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.config.Path)
+	base := strings.TrimSuffix(f.config.Path, ext)
+	name := base + "-" + t.Format("20060102-150405") + ext
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%s.%d%s", base, t.Format("20060102-150405"), i, ext)
+	}
+}
+
+func (f *rotatingFile) rotate(t time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close scan log: %v", err)
+	}
+	if err := os.Rename(f.config.Path, f.backupName(t)); err != nil {
+		return fmt.Errorf("failed to rotate scan log: %v", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// prune removes the oldest backups beyond MaxBackups. Names sort by time.
+func (f *rotatingFile) prune() {
+	if f.config.MaxBackups <= 0 {
+		return
+	}
+	ext := filepath.Ext(f.config.Path)
+	base := strings.TrimSuffix(f.config.Path, ext)
+	backups, err := filepath.Glob(base + "-????????-??????*" + ext)
+	if err != nil || len(backups) <= f.config.MaxBackups {
+		return
+	}
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-f.config.MaxBackups] {
+		os.Remove(name)
+	}
+}
+
+func (f *rotatingFile) close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,200 @@
+package scanlog
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+// Format is the storage format of the log
+type Format int
+
+const (
+	FormatCSV Format = iota
+	FormatJSONL
+	FormatSQLite
+)
+
+// ParseFormat parses "csv", "jsonl" or "sqlite"
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return FormatCSV, nil
+	case "jsonl", "json":
+		return FormatJSONL, nil
+	case "sqlite", "sqlite3":
+		return FormatSQLite, nil
+	}
+	return 0, fmt.Errorf("unknown log format %q", s)
+}
+
+// Record is one logged scan
+type Record struct {
+	Time     time.Time         `json:"time"`
+	UID      string            `json:"uid"`
+	Type     string            `json:"type"`
+	Reader   string            `json:"reader"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// csvHeader are the CSV columns. Metadata is URL query encoded, e.g.
+// operator=jane&shift=early.
+var csvHeader = []string{"time", "uid", "type", "reader", "metadata"}
+
+// Config configures the log. Rotation applies to CSV and JSONL files; a
+// rotated file gets the time of rotation appended to its name, e.g.
+// scans-20261016-081900.csv.
+type Config struct {
+	Path     string
+	Format   Format
+	Metadata map[string]string // added to every record, e.g. the location
+
+	MaxSize    int64 // rotate before a file exceeds this size, never if zero
+	Daily      bool  // rotate when the day changes
+	MaxBackups int   // rotated files to keep, all if zero
+}
+
+// Logger writes scan records. It is safe for concurrent use.
+type Logger struct {
+	mu     sync.Mutex
+	config Config
+	file   *rotatingFile
+	db     *sql.DB
+}
+
+// Open opens or creates the log
+func Open(config Config) (*Logger, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("no log path configured")
+	}
+	l := &Logger{config: config}
+	switch config.Format {
+	case FormatCSV, FormatJSONL:
+		var header []byte
+		if config.Format == FormatCSV {
+			header = []byte(strings.Join(csvHeader, ",") + "\n")
+		}
+		f, err := openRotatingFile(config, header)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f
+	case FormatSQLite:
+		db, err := openDB(config.Path)
+		if err != nil {
+			return nil, err
+		}
+		l.db = db
+	default:
+		return nil, fmt.Errorf("unknown log format %d", config.Format)
+	}
+	return l, nil
+}
+
+func openDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scan database: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS scans (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		time     TEXT NOT NULL,
+		uid      TEXT NOT NULL,
+		type     TEXT NOT NULL,
+		reader   TEXT NOT NULL,
+		metadata TEXT NOT NULL DEFAULT '{}'
+	);
+	CREATE INDEX IF NOT EXISTS scans_uid_time ON scans (uid, time)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create scan table: %v", err)
+	}
+	return db, nil
+}
+
+// Log records the connected card with optional metadata for this scan
+func (l *Logger) Log(reader *hardware.Reader, metadata map[string]string) error {
+	info := reader.CardInfo()
+	return l.Write(Record{
+		Time:     time.Now(),
+		UID:      uid.Hex(info.UID),
+		Type:     info.Type,
+		Reader:   reader.Reader(),
+		Metadata: metadata,
+	})
+}
+
+// Write appends a record. Configured metadata is added unless the record has
+// the same key.
+func (l *Logger) Write(r Record) error {
+	if len(l.config.Metadata) > 0 {
+		merged := maps.Clone(l.config.Metadata)
+		maps.Copy(merged, r.Metadata)
+		r.Metadata = merged
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch l.config.Format {
+	case FormatCSV:
+		return l.writeCSV(r)
+	case FormatJSONL:
+		line, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode scan: %v", err)
+		}
+		return l.file.write(r.Time, append(line, '\n'))
+	default:
+		return l.insert(r)
+	}
+}
+
+func (l *Logger) writeCSV(r Record) error {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	meta := url.Values{}
+	for k, v := range r.Metadata {
+		meta.Set(k, v)
+	}
+	w.Write([]string{r.Time.Format(time.RFC3339Nano), r.UID, r.Type, r.Reader, meta.Encode()})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to encode scan: %v", err)
+	}
+	return l.file.write(r.Time, []byte(sb.String()))
+}
+
+func (l *Logger) insert(r Record) error {
+	meta, err := json.Marshal(r.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %v", err)
+	}
+	if r.Metadata == nil {
+		meta = []byte("{}")
+	}
+	_, err = l.db.Exec(`INSERT INTO scans (time, uid, type, reader, metadata) VALUES (?, ?, ?, ?, ?)`,
+		r.Time.UTC().Format(time.RFC3339Nano), r.UID, r.Type, r.Reader, string(meta))
+	if err != nil {
+		return fmt.Errorf("failed to store scan: %v", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the log
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.db != nil {
+		return l.db.Close()
+	}
+	return l.file.close()
+}
@@ -0,0 +1,58 @@
+package scanlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scans.jsonl")
+	l, err := Open(Config{Path: path, Format: FormatJSONL, Metadata: map[string]string{"site": "lobby"}})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				r := Record{Time: time.Now(), UID: fmt.Sprintf("%02X%02X", i, j), Type: "NTAG215"}
+				if err := l.Write(r); err != nil {
+					t.Errorf("Write: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	uids := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %d: %v", len(uids)+1, err)
+		}
+		if r.Metadata["site"] != "lobby" {
+			t.Fatalf("record %s: metadata %v", r.UID, r.Metadata)
+		}
+		uids[r.UID] = true
+	}
+	if len(uids) != 400 {
+		t.Fatalf("%d records, want 400", len(uids))
+	}
+}
@@ -0,0 +1,125 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// operation builds the APDU of a named step from its arguments
+type operation struct {
+	required []string
+	build    func(args map[string]string) ([]byte, error)
+}
+
+// operations are the high-level steps, expanded to ACR122U pseudo-APDUs and
+// ISO 7816-4 commands
+var operations = map[string]operation{
+	"uid": {build: func(map[string]string) ([]byte, error) {
+		return []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}, nil
+	}},
+	"ats": {build: func(map[string]string) ([]byte, error) {
+		return []byte{0xFF, 0xCA, 0x01, 0x00, 0x00}, nil
+	}},
+	"load_key": {required: []string{"key"}, build: func(args map[string]string) ([]byte, error) {
+		key, err := hexArg(args, "key", 6)
+		if err != nil {
+			return nil, err
+		}
+		slot, err := byteArg(args, "slot", 0)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{0xFF, 0x82, 0x00, slot, 0x06}, key...), nil
+	}},
+	"auth": {required: []string{"block"}, build: func(args map[string]string) ([]byte, error) {
+		block, err := byteArg(args, "block", 0)
+		if err != nil {
+			return nil, err
+		}
+		slot, err := byteArg(args, "slot", 0)
+		if err != nil {
+			return nil, err
+		}
+		keyType := byte(0x60)
+		switch strings.ToUpper(args["key_type"]) {
+		case "", "A":
+		case "B":
+			keyType = 0x61
+		default:
+			return nil, fmt.Errorf("key_type must be A or B")
+		}
+		return []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, block, keyType, slot}, nil
+	}},
+	"read": {required: []string{"block"}, build: func(args map[string]string) ([]byte, error) {
+		block, err := byteArg(args, "block", 0)
+		if err != nil {
+			return nil, err
+		}
+		length, err := byteArg(args, "length", 16)
+		if err != nil {
+			return nil, err
+		}
+		return []byte{0xFF, 0xB0, 0x00, block, length}, nil
+	}},
+	"write": {required: []string{"block", "data"}, build: func(args map[string]string) ([]byte, error) {
+		block, err := byteArg(args, "block", 0)
+		if err != nil {
+			return nil, err
+		}
+		data, err := hexArg(args, "data", 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != 4 && len(data) != 16 {
+			return nil, fmt.Errorf("data must be 4 or 16 bytes")
+		}
+		return append([]byte{0xFF, 0xD6, 0x00, block, byte(len(data))}, data...), nil
+	}},
+	"select": {required: []string{"aid"}, build: func(args map[string]string) ([]byte, error) {
+		aid, err := hexArg(args, "aid", 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(aid) == 0 || len(aid) > 16 {
+			return nil, fmt.Errorf("aid must be 1 to 16 bytes")
+		}
+		apdu := append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(aid))}, aid...)
+		return append(apdu, 0x00), nil
+	}},
+	"pn532": {required: []string{"data"}, build: func(args map[string]string) ([]byte, error) {
+		data, err := hexArg(args, "data", 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 || len(data) > 0xFF {
+			return nil, fmt.Errorf("data must be 1 to 255 bytes")
+		}
+		return append([]byte{0xFF, 0x00, 0x00, 0x00, byte(len(data))}, data...), nil
+	}},
+}
+
+// hexArg parses a hex argument, checking the length if size is not zero
+func hexArg(args map[string]string, name string, size int) ([]byte, error) {
+	b, err := parseHex(args[name])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if size > 0 && len(b) != size {
+		return nil, fmt.Errorf("%s must be %d bytes", name, size)
+	}
+	return b, nil
+}
+
+// byteArg parses a decimal or 0x-prefixed byte argument
+func byteArg(args map[string]string, name string, def byte) (byte, error) {
+	s, ok := args[name]
+	if !ok || s == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid byte %q", name, s)
+	}
+	return byte(v), nil
+}
@@ -0,0 +1,171 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// StepResult records the exchange and outcome of one step
+type StepResult struct {
+	Name     string
+	Command  []byte
+	Response []byte // response data without status word
+	SW       uint16
+	Err      error
+}
+
+// Result is the outcome of a script run
+type Result struct {
+	Name      string
+	Steps     []StepResult
+	Variables map[string]string // values at the end of the run
+}
+
+// Passed reports whether all steps met their expectations
+func (r *Result) Passed() bool {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Result) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Script %s\n", r.Name)
+	for i, step := range r.Steps {
+		status := "OK"
+		if step.Err != nil {
+			status = "FAIL: " + step.Err.Error()
+		}
+		fmt.Fprintf(&sb, "  %2d %-20s > % X\n", i+1, step.Name, step.Command)
+		fmt.Fprintf(&sb, "     %-20s < % X %04X %s\n", "", step.Response, step.SW, status)
+	}
+	return sb.String()
+}
+
+// Runner executes scripts against a card
+type Runner struct {
+	card hardware.Transport
+	vars map[string]string
+}
+
+// NewRunner creates a runner on the reader's connection
+func NewRunner(reader *hardware.Reader) *Runner {
+	return NewRunnerWithTransport(reader.Transport())
+}
+
+// NewRunnerWithTransport creates a runner on top of any transport
+func NewRunnerWithTransport(transport hardware.Transport) *Runner {
+	return &Runner{card: transport, vars: make(map[string]string)}
+}
+
+// Set defines a variable for all runs. It overrides the variable of the same
+// name in scripts, e.g. for per-card keys.
+func (r *Runner) Set(name string, value string) {
+	r.vars[name] = value
+}
+
+// Run executes the steps in order and stops at the first failed step unless
+// it continues on error. The error is that of the step that stopped the run.
+func (r *Runner) Run(s *Script) (*Result, error) {
+	vars := make(map[string]string, len(s.Variables)+len(r.vars))
+	for name, value := range s.Variables {
+		vars[name] = value
+	}
+	for name, value := range r.vars {
+		vars[name] = value
+	}
+	result := &Result{Name: s.Name, Variables: vars}
+	for i, step := range s.Steps {
+		res := r.runStep(step, vars)
+		result.Steps = append(result.Steps, res)
+		if res.Err != nil && !step.ContinueOnError {
+			return result, fmt.Errorf("step %d (%s): %w", i+1, res.Name, res.Err)
+		}
+	}
+	return result, nil
+}
+
+func (r *Runner) runStep(step Step, vars map[string]string) StepResult {
+	res := StepResult{Name: step.label()}
+	cmd, err := command(step, vars)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Command = cmd
+
+	rsp, err := r.card.Transmit(cmd)
+	if err != nil {
+		res.Err = fmt.Errorf("transmit failed: %v", err)
+		return res
+	}
+	if len(rsp) < 2 {
+		res.Err = fmt.Errorf("invalid response length")
+		return res
+	}
+	res.Response = rsp[:len(rsp)-2]
+	res.SW = uint16(rsp[len(rsp)-2])<<8 | uint16(rsp[len(rsp)-1])
+	res.Err = check(step, rsp, vars)
+	if res.Err == nil && step.Save != "" {
+		vars[step.Save] = strings.ToUpper(hex.EncodeToString(res.Response))
+	}
+	return res
+}
+
+// command builds the APDU of a step with variables expanded
+func command(step Step, vars map[string]string) ([]byte, error) {
+	if step.APDU != "" {
+		apdu, err := expand(step.APDU, vars)
+		if err != nil {
+			return nil, err
+		}
+		return parseHex(apdu)
+	}
+	args := make(map[string]string, len(step.Args))
+	for name, value := range step.Args {
+		v, err := expand(value, vars)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+	return operations[step.Op].build(args)
+}
+
+// check compares the response with the expected status word and data
+func check(step Step, rsp []byte, vars map[string]string) error {
+	data, sw := rsp[:len(rsp)-2], rsp[len(rsp)-2:]
+	if !strings.EqualFold(step.SW, "any") {
+		want := step.SW
+		if want == "" {
+			want = "9000"
+		}
+		p, err := parsePattern(want)
+		if err != nil {
+			return err
+		}
+		if !p.match(sw) {
+			return fmt.Errorf("status %X, expected %s", sw, want)
+		}
+	}
+	if step.Expect != "" {
+		expect, err := expand(step.Expect, vars)
+		if err != nil {
+			return err
+		}
+		p, err := parsePattern(expect)
+		if err != nil {
+			return err
+		}
+		if !p.match(data) {
+			return fmt.Errorf("response % X does not match %s", data, expect)
+		}
+	}
+	return nil
+}
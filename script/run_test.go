@@ -0,0 +1,152 @@
+package script_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/script"
+)
+
+// card answers APDUs from a table keyed by the command in hex, everything
+// else with 6A 81
+type card struct {
+	responses map[string]string
+	sent      []string
+}
+
+func (c *card) Transmit(cmd []byte) ([]byte, error) {
+	key := strings.ToUpper(hex.EncodeToString(cmd))
+	c.sent = append(c.sent, key)
+	rsp, ok := c.responses[key]
+	if !ok {
+		return []byte{0x6A, 0x81}, nil
+	}
+	return hex.DecodeString(rsp)
+}
+
+// parse parses a script, failing the test on errors
+func parse(t *testing.T, s string) *script.Script {
+	t.Helper()
+	sc, err := script.Parse([]byte(s))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return sc
+}
+
+func TestOperations(t *testing.T) {
+	for _, tc := range []struct {
+		step string
+		apdu string
+	}{
+		{`{op: uid}`, "FFCA000000"},
+		{`{op: ats}`, "FFCA010000"},
+		{`{op: load_key, args: {key: A0A1A2A3A4A5, slot: 1}}`, "FF82000106A0A1A2A3A4A5"},
+		{`{op: auth, args: {block: 4}}`, "FF860000050100046000"},
+		{`{op: auth, args: {block: "0x3C", key_type: b, slot: 1}}`, "FF8600000501003C6101"},
+		{`{op: read, args: {block: 4}}`, "FFB0000410"},
+		{`{op: read, args: {block: 4, length: 4}}`, "FFB0000404"},
+		{`{op: write, args: {block: 5, data: "01:02:03:04"}}`, "FFD600050401020304"},
+		{`{op: select, args: {aid: D2760000850101}}`, "00A4040007D276000085010100"},
+		{`{op: pn532, args: {data: D44A0100}}`, "FF00000004D44A0100"},
+	} {
+		c := &card{}
+		// 6A 81 is expected, so only the command matters
+		s := parse(t, "steps: ["+strings.TrimSuffix(tc.step, "}")+", sw: any}]")
+		if _, err := script.NewRunnerWithTransport(c).Run(s); err != nil {
+			t.Errorf("%s: Run: %v", tc.step, err)
+			continue
+		}
+		if len(c.sent) != 1 || c.sent[0] != tc.apdu {
+			t.Errorf("%s: sent %v, want %s", tc.step, c.sent, tc.apdu)
+		}
+	}
+
+	for _, step := range []string{
+		`{op: load_key, args: {key: A0A1A2}}`,
+		`{op: auth, args: {block: 4, key_type: C}}`,
+		`{op: read, args: {block: 256}}`,
+		`{op: write, args: {block: 5, data: 010203}}`,
+		`{op: select, args: {aid: ""}}`,
+	} {
+		_, err := script.NewRunnerWithTransport(&card{}).Run(parse(t, "steps: ["+step+"]"))
+		if err == nil {
+			t.Errorf("%s: Run succeeded", step)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	c := &card{responses: map[string]string{
+		"FFCA000000":             "04A1B2C3D4E5F69000",
+		"FF82000006FFFFFFFFFFFF": "9000",
+		"FF860000050100046000":   "9000",
+		"FFB0000410":             "D27600008501010004A1B2C3D4E5F6009000",
+		"FFB0000510":             "6300",
+		"FFD6000604A1B2C3D4":     "9000",
+	}}
+	s := parse(t, `
+name: check tag
+variables:
+  key: "000000000000"
+steps:
+  - op: uid
+    save: uid
+  - op: load_key
+    args: {key: "${key}"}
+  - apdu: FF 86 00 00 05 01 00 04 60 00
+  - name: NDEF header and UID
+    op: read
+    args: {block: 4}
+    expect: "D2 76 ?? ?? 85 01 01 00 ${uid} *"
+  - op: read
+    args: {block: 5}
+    sw: "63 ??"
+  - op: read
+    args: {block: 8}
+    continue_on_error: true
+  - apdu: "FF D6 00 06 04 ${prefix}"
+`)
+	r := script.NewRunnerWithTransport(c)
+	// Overrides the key of the script
+	r.Set("key", "FFFFFFFFFFFF")
+	r.Set("prefix", "A1B2C3D4")
+	result, err := r.Run(s)
+	if err != nil {
+		t.Fatalf("Run: %v\n%s", err, result)
+	}
+	if result.Passed() || len(result.Steps) != 7 {
+		t.Fatalf("result\n%s", result)
+	}
+	for i, step := range result.Steps {
+		if (step.Err != nil) != (i == 5) {
+			t.Errorf("step %d (%s): %v", i+1, step.Name, step.Err)
+		}
+	}
+	if result.Variables["uid"] != "04A1B2C3D4E5F6" {
+		t.Errorf("uid saved as %q", result.Variables["uid"])
+	}
+	if step := result.Steps[3]; step.Name != "NDEF header and UID" || step.SW != 0x9000 || len(step.Response) != 16 {
+		t.Errorf("step 4 %+v", step)
+	}
+	if out := result.String(); !strings.Contains(out, "Script check tag") || !strings.Contains(out, "FAIL: status 6A81, expected 9000") {
+		t.Errorf("String()\n%s", out)
+	}
+
+	// A failed step stops the run
+	c.responses["FFB0000410"] = "D27600008501010004000000000000009000"
+	result, err = r.Run(s)
+	if err == nil || !strings.Contains(err.Error(), "step 4 (NDEF header and UID)") || len(result.Steps) != 4 {
+		t.Fatalf("Run with a wrong UID: %v\n%s", err, result)
+	}
+
+	// Undefined variables fail the step using them
+	result, err = script.NewRunnerWithTransport(c).Run(parse(t, `steps: [{apdu: "FF CA ${p1} 00 00"}]`))
+	if err == nil || !strings.Contains(err.Error(), `undefined variable "p1"`) {
+		t.Fatalf("Run with an undefined variable: %v", err)
+	}
+	if result.Steps[0].Command != nil {
+		t.Errorf("command % X sent with an undefined variable", result.Steps[0].Command)
+	}
+}
@@ -0,0 +1,217 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Script is a sequence of card commands with expected responses. It is
+// loaded from YAML; JSON files work as well since JSON is valid YAML.
+//
+//	name: check tag
+//	variables:
+//	  key: FFFFFFFFFFFF
+//	steps:
+//	  - op: uid
+//	    save: uid
+//	  - op: load_key
+//	    args: {key: "${key}"}
+//	  - apdu: FF 86 00 00 05 01 00 04 60 00
+//	  - op: read
+//	    args: {block: 4}
+//	    expect: "D2 76 ?? *"
+type Script struct {
+	Name      string            `yaml:"name"`
+	Variables map[string]string `yaml:"variables"`
+	Steps     []Step            `yaml:"steps"`
+}
+
+// Step sends one command: a raw APDU or a named operation with arguments.
+// Expect matches the response data as hex, "??" matches any byte and a
+// trailing "*" any remaining bytes. SW is the expected status word, 9000 if
+// empty and not checked if "any". Save stores the response data as hex in a
+// variable for later steps.
+type Step struct {
+	Name            string            `yaml:"name"`
+	APDU            string            `yaml:"apdu"`
+	Op              string            `yaml:"op"`
+	Args            map[string]string `yaml:"args"`
+	Expect          string            `yaml:"expect"`
+	SW              string            `yaml:"sw"`
+	Save            string            `yaml:"save"`
+	ContinueOnError bool              `yaml:"continue_on_error"`
+}
+
+// variablePattern matches ${name} references
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// variableName matches names that steps may save to
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Load reads a script from a YAML or JSON file
+func Load(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %v", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates a YAML or JSON script
+func Parse(data []byte) (*Script, error) {
+	var s Script
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse script: %v", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks the steps before a card is touched. Values with variable
+// references are checked when the step runs.
+func (s *Script) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("script has no steps")
+	}
+	for i, step := range s.Steps {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("step %d (%s): %v", i+1, step.label(), err)
+		}
+	}
+	return nil
+}
+
+func (s Step) validate() error {
+	if (s.APDU == "") == (s.Op == "") {
+		return fmt.Errorf("needs either apdu or op")
+	}
+	if s.Op != "" {
+		op, ok := operations[s.Op]
+		if !ok {
+			return fmt.Errorf("unknown op %q", s.Op)
+		}
+		for _, name := range op.required {
+			if _, ok := s.Args[name]; !ok {
+				return fmt.Errorf("op %s needs argument %q", s.Op, name)
+			}
+		}
+	}
+	if s.APDU != "" && !hasVariables(s.APDU) {
+		if _, err := parseHex(s.APDU); err != nil {
+			return err
+		}
+	}
+	if s.Expect != "" && !hasVariables(s.Expect) {
+		if _, err := parsePattern(s.Expect); err != nil {
+			return err
+		}
+	}
+	if s.SW != "" && !strings.EqualFold(s.SW, "any") {
+		if _, err := parsePattern(s.SW); err != nil {
+			return fmt.Errorf("sw: %v", err)
+		}
+	}
+	if s.Save != "" && !variableName.MatchString(s.Save) {
+		return fmt.Errorf("invalid variable name %q", s.Save)
+	}
+	return nil
+}
+
+// label names a step in messages
+func (s Step) label() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.Op != "":
+		return s.Op
+	default:
+		return s.APDU
+	}
+}
+
+func hasVariables(s string) bool {
+	return variablePattern.MatchString(s)
+}
+
+// expand replaces variable references
+func expand(s string, vars map[string]string) (string, error) {
+	var missing string
+	out := variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := variablePattern.FindStringSubmatch(ref)[1]
+		value, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable %q", missing)
+	}
+	return out, nil
+}
+
+// cleanHex removes separators from hex strings, e.g. "FF:CA 00"
+func cleanHex(s string) string {
+	return strings.NewReplacer(" ", "", ":", "", "\t", "", "\n", "").Replace(s)
+}
+
+func parseHex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(cleanHex(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex %q", s)
+	}
+	return b, nil
+}
+
+// pattern is a parsed expected response. Wildcard bytes are marked in any;
+// rest allows trailing bytes.
+type pattern struct {
+	bytes []byte
+	any   []bool
+	rest  bool
+}
+
+func parsePattern(s string) (*pattern, error) {
+	p := &pattern{}
+	h := cleanHex(s)
+	if strings.HasSuffix(h, "*") {
+		p.rest = true
+		h = h[:len(h)-1]
+	}
+	if len(h)%2 != 0 {
+		return nil, fmt.Errorf("invalid pattern %q", s)
+	}
+	for i := 0; i < len(h); i += 2 {
+		if h[i:i+2] == "??" {
+			p.bytes = append(p.bytes, 0)
+			p.any = append(p.any, true)
+			continue
+		}
+		b, err := hex.DecodeString(h[i : i+2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", s)
+		}
+		p.bytes = append(p.bytes, b[0])
+		p.any = append(p.any, false)
+	}
+	return p, nil
+}
+
+func (p *pattern) match(b []byte) bool {
+	if len(b) < len(p.bytes) || (!p.rest && len(b) != len(p.bytes)) {
+		return false
+	}
+	for i, v := range p.bytes {
+		if !p.any[i] && b[i] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package script_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oo-developer/acr122u/script"
+)
+
+func TestParse(t *testing.T) {
+	s, err := script.Parse([]byte(`
+name: check tag
+variables:
+  key: FFFFFFFFFFFF
+steps:
+  - op: uid
+    save: uid
+  - op: load_key
+    args: {key: "${key}"}
+  - apdu: FF 86 00 00 05 01 00 04 60 00
+  - op: read
+    args: {block: 4}
+    expect: "D2 76 ?? *"
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if s.Name != "check tag" || s.Variables["key"] != "FFFFFFFFFFFF" || len(s.Steps) != 4 {
+		t.Fatalf("script %+v", s)
+	}
+	if s.Steps[3].Op != "read" || s.Steps[3].Args["block"] != "4" || s.Steps[3].Expect != "D2 76 ?? *" {
+		t.Errorf("step 4 %+v", s.Steps[3])
+	}
+
+	// JSON is YAML as well
+	s, err = script.Parse([]byte(`{"name": "json", "steps": [{"apdu": "FFCA000000", "sw": "any"}]}`))
+	if err != nil || s.Steps[0].APDU != "FFCA000000" || s.Steps[0].SW != "any" {
+		t.Fatalf("Parse of JSON: %+v, %v", s, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		steps string
+		err   string
+	}{
+		{"no steps", `[]`, "no steps"},
+		{"apdu and op", `[{apdu: FFCA000000, op: uid}]`, "either apdu or op"},
+		{"neither apdu nor op", `[{name: empty}]`, "either apdu or op"},
+		{"unknown op", `[{op: format}]`, `unknown op "format"`},
+		{"missing argument", `[{op: write, args: {block: 4}}]`, `needs argument "data"`},
+		{"bad hex", `[{apdu: FFCA00000}]`, "invalid hex"},
+		{"bad expect", `[{op: uid, expect: "04 ?"}]`, "invalid pattern"},
+		{"bad sw", `[{op: uid, sw: "9G00"}]`, "sw: invalid pattern"},
+		{"bad variable name", `[{op: uid, save: 1uid}]`, "invalid variable name"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := script.Parse([]byte("steps: " + tc.steps))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Parse: %v, want %q", err, tc.err)
+			}
+		})
+	}
+
+	// References are only checked when the step runs
+	if _, err := script.Parse([]byte(`steps: [{apdu: "FF CA ${p1} 00 00", expect: "${uid}"}]`)); err != nil {
+		t.Errorf("Parse with variable references: %v", err)
+	}
+	if _, err := script.Parse([]byte("steps: [{op: uid\n")); err == nil {
+		t.Errorf("Parse accepted invalid YAML")
+	}
+}
@@ -0,0 +1,266 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oo-developer/acr122u/anticlone"
+	"github.com/oo-developer/acr122u/desfire"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/ndef"
+	"github.com/oo-developer/acr122u/ntag"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+// Methods of the challenge a token is issued after
+const (
+	MethodULC     = "ultralight-c"
+	MethodDESFire = "desfire-aes"
+	MethodNTAG424 = "ntag424"
+)
+
+// ErrMismatch is returned by Validate for tokens that were not derived from
+// the card key, the UID and the nonce
+var ErrMismatch = errors.New("token does not match")
+
+// ErrExpired is returned by Validate for tokens of another time step
+var ErrExpired = errors.New("token expired")
+
+// Token proves that a card holding its key was on the reader. Its value is
+// an HMAC-SHA256 under the card key over the method, the UID, the time step
+// and the nonce of the server, see Derive.
+type Token struct {
+	UID    string `json:"uid"`
+	Method string `json:"method"`
+	Nonce  string `json:"nonce"`
+	Step   int64  `json:"step"`
+	Value  string `json:"value"`
+	// Code is the value truncated to digits as in HOTP (RFC 4226), for
+	// entering by hand
+	Code string `json:"code,omitempty"`
+}
+
+// NTAG424Challenge authenticates with an AES key of the NDEF application of
+// NTAG 424 DNA
+type NTAG424Challenge struct {
+	KeyNo byte
+	Key   anticlone.KeyFunc
+}
+
+// Config holds the keys of the card families tokens are issued for. Readers
+// and backends share it: the reader needs the keys to authenticate the card,
+// the backend to validate the token.
+type Config struct {
+	// ULCKey authenticates Ultralight C cards, if set
+	ULCKey anticlone.KeyFunc
+	// DESFire authenticates DESFire cards, if set
+	DESFire *anticlone.DESFireChallenge
+	// NTAG424 authenticates NTAG 424 DNA, if set
+	NTAG424 *NTAG424Challenge
+	// Period binds tokens to a time step like TOTP; zero binds them to the
+	// nonce only
+	Period time.Duration
+	// Skew is the number of steps Validate accepts around the current one
+	Skew int
+	// Digits is the length of Token.Code, 6 to 9, or 0 for no code
+	Digits int
+}
+
+// Issuer authenticates cards and issues tokens, and validates them
+type Issuer struct {
+	config Config
+}
+
+// NewIssuer creates an issuer for the configured card families
+func NewIssuer(config Config) (*Issuer, error) {
+	if config.ULCKey == nil && config.DESFire == nil && config.NTAG424 == nil {
+		return nil, fmt.Errorf("no card family configured")
+	}
+	if config.Digits != 0 && (config.Digits < 6 || config.Digits > 9) {
+		return nil, fmt.Errorf("digits must be 6 to 9")
+	}
+	if config.Period != 0 && config.Period%time.Second != 0 || config.Period < 0 || config.Skew < 0 {
+		return nil, fmt.Errorf("period must be whole seconds and skew must not be negative")
+	}
+	return &Issuer{config: config}, nil
+}
+
+// Issue authenticates the connected card and issues a token for the nonce
+func (i *Issuer) Issue(reader *hardware.Reader, nonce []byte) (*Token, error) {
+	return i.IssueAt(reader, nonce, time.Now())
+}
+
+// IssueAt is Issue at a given time
+func (i *Issuer) IssueAt(reader *hardware.Reader, nonce []byte, now time.Time) (*Token, error) {
+	info := reader.CardInfo()
+	if info == nil {
+		return nil, fmt.Errorf("no card connected")
+	}
+	if len(nonce) == 0 {
+		return nil, fmt.Errorf("nonce must not be empty")
+	}
+	method, err := i.authenticate(reader, info)
+	if err != nil {
+		return nil, err
+	}
+	key, err := i.key(method, info.UID)
+	if err != nil {
+		return nil, err
+	}
+	return i.token(key, method, info.UID, nonce, i.step(now)), nil
+}
+
+// authenticate runs the challenge of the card family and returns its method
+func (i *Issuer) authenticate(reader *hardware.Reader, info *hardware.CardInfo) (string, error) {
+	switch {
+	case strings.Contains(info.Type, "DESFire"):
+		df := desfire.NewDESFire(reader)
+		// NTAG 424 DNA answers like DESFire, but reports its own hardware type
+		if version, err := df.GetVersion(); err == nil && len(version) > 1 && version[1] == 0x04 {
+			return MethodNTAG424, i.authenticateNTAG424(df, info.UID)
+		}
+		return MethodDESFire, i.authenticateDESFire(df, info.UID)
+	case ndef.DetectTagType(info) == ndef.TagType2:
+		return MethodULC, i.authenticateULC(ntag.NewNTAG(reader), info.UID)
+	}
+	return "", fmt.Errorf("no challenge for %s", info.Type)
+}
+
+func (i *Issuer) authenticateDESFire(df *desfire.DESFire, id []byte) error {
+	key, err := i.key(MethodDESFire, id)
+	if err != nil {
+		return err
+	}
+	if err := df.SelectApplication(i.config.DESFire.AID); err != nil {
+		return fmt.Errorf("failed to select application: %v", err)
+	}
+	if err := df.AuthenticateAES(i.config.DESFire.KeyNo, key); err != nil {
+		return fmt.Errorf("card failed the challenge: %v", err)
+	}
+	return nil
+}
+
+func (i *Issuer) authenticateNTAG424(df *desfire.DESFire, id []byte) error {
+	key, err := i.key(MethodNTAG424, id)
+	if err != nil {
+		return err
+	}
+	if err := df.SelectDFName(desfire.NTAG424DFName); err != nil {
+		return err
+	}
+	if err := df.AuthenticateEV2First(i.config.NTAG424.KeyNo, key); err != nil {
+		return fmt.Errorf("card failed the challenge: %v", err)
+	}
+	return nil
+}
+
+func (i *Issuer) authenticateULC(n *ntag.NTAG, id []byte) error {
+	key, err := i.key(MethodULC, id)
+	if err != nil {
+		return err
+	}
+	if err := n.AuthenticateULC(key); err != nil {
+		return fmt.Errorf("card failed the challenge: %v", err)
+	}
+	return nil
+}
+
+// key returns the card key of a method
+func (i *Issuer) key(method string, id []byte) ([]byte, error) {
+	var keyFunc anticlone.KeyFunc
+	switch {
+	case method == MethodULC && i.config.ULCKey != nil:
+		keyFunc = i.config.ULCKey
+	case method == MethodDESFire && i.config.DESFire != nil:
+		keyFunc = i.config.DESFire.Key
+	case method == MethodNTAG424 && i.config.NTAG424 != nil:
+		keyFunc = i.config.NTAG424.Key
+	default:
+		return nil, fmt.Errorf("no key for %s", method)
+	}
+	key, err := keyFunc(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s key: %v", method, err)
+	}
+	return key, nil
+}
+
+func (i *Issuer) step(now time.Time) int64 {
+	if i.config.Period == 0 {
+		return 0
+	}
+	return now.Unix() / int64(i.config.Period/time.Second)
+}
+
+func (i *Issuer) token(key []byte, method string, id []byte, nonce []byte, step int64) *Token {
+	value := Derive(key, method, id, nonce, step)
+	t := &Token{
+		UID:    uid.Hex(id),
+		Method: method,
+		Nonce:  hex.EncodeToString(nonce),
+		Step:   step,
+		Value:  hex.EncodeToString(value),
+	}
+	if i.config.Digits > 0 {
+		t.Code = Code(value, i.config.Digits)
+	}
+	return t
+}
+
+// Validate checks a token against the nonce the server handed out, the
+// card key and the time step
+func (i *Issuer) Validate(t *Token, nonce []byte, now time.Time) error {
+	if t.Nonce != hex.EncodeToString(nonce) {
+		return ErrMismatch
+	}
+	step := i.step(now)
+	if t.Step < step-int64(i.config.Skew) || t.Step > step+int64(i.config.Skew) {
+		return ErrExpired
+	}
+	id, err := uid.Parse(t.UID)
+	if err != nil {
+		return fmt.Errorf("invalid UID: %v", err)
+	}
+	key, err := i.key(t.Method, id)
+	if err != nil {
+		return err
+	}
+	want := Derive(key, t.Method, id, nonce, t.Step)
+	value, err := hex.DecodeString(t.Value)
+	if err != nil || !hmac.Equal(value, want) {
+		return ErrMismatch
+	}
+	if i.config.Digits > 0 && t.Code != Code(want, i.config.Digits) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// Derive computes the value of a token. The fields are length-prefixed so
+// they cannot run into each other.
+func Derive(key []byte, method string, id []byte, nonce []byte, step int64) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, field := range [][]byte{[]byte(method), id, nonce} {
+		mac.Write([]byte{byte(len(field))})
+		mac.Write(field)
+	}
+	binary.Write(mac, binary.BigEndian, step)
+	return mac.Sum(nil)
+}
+
+// Code truncates a value to digits as in HOTP (RFC 4226)
+func Code(value []byte, digits int) string {
+	offset := value[len(value)-1] & 0x0F
+	n := binary.BigEndian.Uint32(value[offset:offset+4]) & 0x7FFFFFFF
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, n%mod)
+}
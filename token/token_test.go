@@ -0,0 +1,257 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oo-developer/acr122u/anticlone"
+	"github.com/oo-developer/acr122u/desfire"
+	desfiresim "github.com/oo-developer/acr122u/desfire/simulator"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ntag"
+	ntagsim "github.com/oo-developer/acr122u/ntag/simulator"
+)
+
+// present connects a mock reader to a card
+func present(t *testing.T, card hardware.Transport, atr []byte) *hardware.Reader {
+	t.Helper()
+	reader := mock.NewReader(card, atr)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return reader
+}
+
+// ntag424 makes the DESFire simulator report the hardware type of an NTAG
+// 424 DNA in GetVersion
+type ntag424 struct {
+	*desfiresim.Simulator
+}
+
+func (c ntag424) Transmit(apdu []byte) ([]byte, error) {
+	rsp, err := c.Simulator.Transmit(apdu)
+	if len(apdu) > 1 && apdu[0] == 0x90 && apdu[1] == desfire.CmdGetVersion && len(rsp) > 2 {
+		rsp[1] = 0x04
+	}
+	return rsp, err
+}
+
+func TestUltralightC(t *testing.T) {
+	key := []byte("0123456789ABCDEF")
+	newCard := func() *ntagsim.Simulator {
+		memory := make([]byte, 192)
+		copy(memory, []byte{0x04, 0x01, 0x02, 0x88 ^ 0x04 ^ 0x01 ^ 0x02, 0x03, 0x04, 0x05, 0x06, 0x03 ^ 0x04 ^ 0x05 ^ 0x06, 0x48, 0x00, 0x00, 0xE1, 0x10, 0x06, 0x00})
+		for i, page := range ntag.ULCKeyPages(key) {
+			copy(memory[(ntag.ULCKeyPage+i)*4:], page)
+		}
+		card, err := ntagsim.NewSimulator(memory)
+		if err != nil {
+			t.Fatalf("NewSimulator: %v", err)
+		}
+		return card
+	}
+
+	issuer, err := NewIssuer(Config{ULCKey: anticlone.StaticKey(key), Period: 30 * time.Second, Skew: 1, Digits: 6})
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	nonce := []byte("nonce")
+	tok, err := issuer.IssueAt(present(t, newCard(), mock.NTAGATR), nonce, now)
+	if err != nil {
+		t.Fatalf("IssueAt: %v", err)
+	}
+	if tok.Method != MethodULC || len(tok.Code) != 6 {
+		t.Fatalf("token %+v", tok)
+	}
+	for _, tc := range []struct {
+		name  string
+		nonce []byte
+		at    time.Time
+		uid   string
+		want  error
+	}{
+		{"same step", nonce, now, tok.UID, nil},
+		{"within the skew", nonce, now.Add(20 * time.Second), tok.UID, nil},
+		{"expired", nonce, now.Add(90 * time.Second), tok.UID, ErrExpired},
+		{"other nonce", []byte("other"), now, tok.UID, ErrMismatch},
+		{"other card", nonce, now, "04010203040507", ErrMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			presented := *tok
+			presented.UID = tc.uid
+			if err := issuer.Validate(&presented, tc.nonce, tc.at); !errors.Is(err, tc.want) {
+				t.Fatalf("Validate: %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	other, err := NewIssuer(Config{ULCKey: anticlone.StaticKey([]byte("FEDCBA9876543210"))})
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	if _, err := other.Issue(present(t, newCard(), mock.NTAGATR), nonce); err == nil {
+		t.Fatalf("Issue with a wrong key")
+	}
+}
+
+// TestCode truncates the HMAC of RFC 4226 appendix D for count 0
+func TestCode(t *testing.T) {
+	mac := []byte{0xcc, 0x93, 0xcf, 0x18, 0x50, 0x8d, 0x94, 0x93, 0x4c, 0x64, 0xb6, 0x5d, 0x8b, 0xa7, 0x66, 0x7f, 0xb7, 0xcd, 0xe4, 0xb0}
+	if code := Code(mac, 6); code != "755224" {
+		t.Fatalf("Code %s, want 755224", code)
+	}
+}
+
+// newApplication creates application 010203 with the key, an ISO
+// application of the NTAG 424 DF name if iso is set
+func newApplication(t *testing.T, df *desfire.DESFire, iso bool, key []byte) {
+	t.Helper()
+	aid := []byte{0x01, 0x02, 0x03}
+	if err := df.Authenticate(0, desfire.KeyTypeDES, make([]byte, 8)); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if iso {
+		if err := df.CreateISOApplication(aid, 0x0F, 0x81, 0xE110, desfire.NTAG424DFName); err != nil {
+			t.Fatalf("CreateISOApplication: %v", err)
+		}
+	} else if err := df.CreateApplication(aid, 0x0F, 0x81); err != nil {
+		t.Fatalf("CreateApplication: %v", err)
+	}
+	if err := df.SelectApplication(aid); err != nil {
+		t.Fatalf("SelectApplication: %v", err)
+	}
+	if err := df.AuthenticateAES(0, make([]byte, 16)); err != nil {
+		t.Fatalf("AuthenticateAES: %v", err)
+	}
+	if err := df.ChangeKey(0, desfire.KeyTypeAES, key, 0, nil); err != nil {
+		t.Fatalf("ChangeKey: %v", err)
+	}
+}
+
+func TestDESFire(t *testing.T) {
+	id := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	keyOf := anticlone.DiversifiedAES(make([]byte, 16), []byte{0x01, 0x02, 0x03}, nil)
+	key, err := keyOf(id)
+	if err != nil {
+		t.Fatalf("DiversifiedAES: %v", err)
+	}
+	issuer, err := NewIssuer(Config{
+		DESFire: &anticlone.DESFireChallenge{AID: []byte{0x01, 0x02, 0x03}, Key: keyOf},
+		NTAG424: &NTAG424Challenge{Key: keyOf},
+	})
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	nonce := []byte{0x09, 0x09}
+
+	card, err := mock.NewDESFire(id)
+	if err != nil {
+		t.Fatalf("NewDESFire: %v", err)
+	}
+	reader := present(t, card, mock.DESFireATR)
+	newApplication(t, desfire.NewDESFire(reader), false, key)
+	tok, err := issuer.Issue(reader, nonce)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if tok.Method != MethodDESFire {
+		t.Fatalf("token %+v", tok)
+	}
+	if err := issuer.Validate(tok, nonce, time.Now()); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	sim, err := mock.NewDESFire(id)
+	if err != nil {
+		t.Fatalf("NewDESFire: %v", err)
+	}
+	reader = present(t, ntag424{sim}, mock.DESFireATR)
+	newApplication(t, desfire.NewDESFire(reader), true, key)
+	if tok, err = issuer.Issue(reader, nonce); err != nil {
+		t.Fatalf("Issue on NTAG 424: %v", err)
+	}
+	if tok.Method != MethodNTAG424 {
+		t.Fatalf("token %+v", tok)
+	}
+	if err := issuer.Validate(tok, nonce, time.Now()); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestAuthenticateEV2First(t *testing.T) {
+	key := make([]byte, 16)
+	key[0] = 0x42
+	card, err := mock.NewDESFire([]byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66})
+	if err != nil {
+		t.Fatalf("NewDESFire: %v", err)
+	}
+	df := desfire.NewDESFire(present(t, card, mock.DESFireATR))
+	newApplication(t, df, true, key)
+
+	if err := df.SelectDFName([]byte{0x01, 0x02, 0x03}); err == nil {
+		t.Fatalf("SelectDFName of an unknown name")
+	}
+	if err := df.SelectDFName(desfire.NTAG424DFName); err != nil {
+		t.Fatalf("SelectDFName: %v", err)
+	}
+	if err := df.AuthenticateEV2First(0, make([]byte, 16)); err == nil {
+		t.Fatalf("AuthenticateEV2First with a wrong key")
+	}
+	if err := df.SelectDFName(desfire.NTAG424DFName); err != nil {
+		t.Fatalf("SelectDFName: %v", err)
+	}
+	if err := df.AuthenticateEV2First(0, key); err != nil {
+		t.Fatalf("AuthenticateEV2First: %v", err)
+	}
+	// Without EV2 secure messaging, the commands that follow are plain
+	if _, err := df.GetKeyVersion(0); err != nil {
+		t.Fatalf("GetKeyVersion: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	key := []byte("0123456789ABCDEF")
+	issuer, err := NewIssuer(Config{ULCKey: anticlone.StaticKey(key), Period: 30 * time.Second, Skew: 1, Digits: 6})
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	nonce := []byte("nonce")
+	id := []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	tok := issuer.token(key, MethodULC, id, nonce, issuer.step(now))
+	if len(tok.Code) != 6 {
+		t.Fatalf("token %+v", tok)
+	}
+	for _, tc := range []struct {
+		name  string
+		nonce []byte
+		at    time.Time
+		uid   string
+		want  error
+	}{
+		{"same step", nonce, now, tok.UID, nil},
+		{"within the skew", nonce, now.Add(20 * time.Second), tok.UID, nil},
+		{"expired", nonce, now.Add(90 * time.Second), tok.UID, ErrExpired},
+		{"other nonce", []byte("other"), now, tok.UID, ErrMismatch},
+		{"other card", nonce, now, "04010203040507", ErrMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			presented := *tok
+			presented.UID = tc.uid
+			if err := issuer.Validate(&presented, tc.nonce, tc.at); !errors.Is(err, tc.want) {
+				t.Fatalf("Validate: %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	other, err := NewIssuer(Config{ULCKey: anticlone.StaticKey([]byte("FEDCBA9876543210")), Period: 30 * time.Second, Skew: 1})
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	if err := other.Validate(tok, nonce, now); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Validate with another key: %v", err)
+	}
+}
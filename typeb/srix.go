@@ -0,0 +1,172 @@
+package typeb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// ST SRI512/SRIX4K/ST25TB commands. These memory tags use Type B modulation
+// but not the ISO 14443-3B anticollision, so they are addressed by chip ID.
+const (
+	SRIXInitiate         = 0x06
+	SRIXReadBlock        = 0x08
+	SRIXWriteBlock       = 0x09
+	SRIXGetUID           = 0x0B
+	SRIXResetToInventory = 0x0C
+	SRIXSelect           = 0x0E
+	SRIXCompletion       = 0x0F
+	SRIXSystemBlock      = 0xFF
+	SRIXBlockSize        = 4
+	pn532StatusTimeout   = 0x01
+)
+
+// Block counts of the ST memory tags
+const (
+	SRI512Blocks   = 16
+	SRIX4KBlocks   = 128
+	ST25TB04Blocks = 128
+)
+
+// SRIX accesses an ST memory tag through PN532 InCommunicateThru
+type SRIX struct {
+	card   hardware.Transport
+	chipID byte
+}
+
+// NewSRIX creates an SRIX handler on the reader's connection
+func NewSRIX(reader *hardware.Reader) *SRIX {
+	return &SRIX{card: reader.Transport()}
+}
+
+// NewSRIXWithTransport creates an SRIX handler on top of any transport
+func NewSRIXWithTransport(transport hardware.Transport) *SRIX {
+	return &SRIX{card: transport}
+}
+
+// thru sends a raw frame; the PN532 adds and checks the CRC. A timeout is
+// returned as a nil response, since WRITE_BLOCK and COMPLETION are not
+// answered.
+func (s *SRIX) thru(frame []byte) ([]byte, error) {
+	rsp, err := pn532(s.card, cmdInCommunicateThru, frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 1 {
+		return nil, fmt.Errorf("empty InCommunicateThru response")
+	}
+	switch status := rsp[0] & pn532StatusMask; status {
+	case 0x00:
+		return rsp[1:], nil
+	case pn532StatusTimeout:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("PN532 error %02X", status)
+	}
+}
+
+// Initiate switches the reader to Type B modulation, wakes the tag and
+// selects it by the chip ID it answers with
+func (s *SRIX) Initiate() (byte, error) {
+	// A Type B poll configures the modulation; SRIX tags do not answer it
+	pn532(s.card, cmdInListPassiveTarget, []byte{0x01, brTyTypeB106, 0x00})
+
+	rsp, err := s.thru([]byte{SRIXInitiate, 0x00})
+	if err != nil {
+		return 0, err
+	}
+	if len(rsp) != 1 {
+		return 0, fmt.Errorf("no ST memory tag found")
+	}
+	if err := s.Select(rsp[0]); err != nil {
+		return 0, err
+	}
+	return rsp[0], nil
+}
+
+// Select moves the tag with the chip ID into the selected state
+func (s *SRIX) Select(chipID byte) error {
+	rsp, err := s.thru([]byte{SRIXSelect, chipID})
+	if err != nil {
+		return err
+	}
+	if len(rsp) != 1 || rsp[0] != chipID {
+		return fmt.Errorf("tag %02X not selected", chipID)
+	}
+	s.chipID = chipID
+	return nil
+}
+
+// GetUID returns the 8-byte UID, most significant byte first
+func (s *SRIX) GetUID() ([]byte, error) {
+	rsp, err := s.thru([]byte{SRIXGetUID})
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) != 8 {
+		return nil, fmt.Errorf("invalid UID response % X", rsp)
+	}
+	// The tag sends the UID least significant byte first
+	uid := make([]byte, 8)
+	for i, b := range rsp {
+		uid[7-i] = b
+	}
+	return uid, nil
+}
+
+// ReadBlock reads a 4-byte block
+func (s *SRIX) ReadBlock(block byte) ([]byte, error) {
+	rsp, err := s.thru([]byte{SRIXReadBlock, block})
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) != SRIXBlockSize {
+		return nil, fmt.Errorf("block %d: invalid response % X", block, rsp)
+	}
+	return rsp, nil
+}
+
+// WriteBlock writes a 4-byte block and reads it back, since the tag does not
+// acknowledge writes. Counter blocks only accept decreasing values.
+func (s *SRIX) WriteBlock(block byte, data []byte) error {
+	if len(data) != SRIXBlockSize {
+		return fmt.Errorf("block data must be %d bytes", SRIXBlockSize)
+	}
+	if _, err := s.thru(append([]byte{SRIXWriteBlock, block}, data...)); err != nil {
+		return fmt.Errorf("block %d: %w", block, err)
+	}
+	back, err := s.ReadBlock(block)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(back, data) {
+		return fmt.Errorf("block %d: write not applied, read % X", block, back)
+	}
+	return nil
+}
+
+// Completion deactivates the selected tag until it leaves the field
+func (s *SRIX) Completion() error {
+	_, err := s.thru([]byte{SRIXCompletion})
+	return err
+}
+
+// ResetToInventory returns the selected tag to the inventory state
+func (s *SRIX) ResetToInventory() error {
+	_, err := s.thru([]byte{SRIXResetToInventory})
+	return err
+}
+
+// ReadAll reads the given number of blocks from block 0
+func (s *SRIX) ReadAll(blocks int) ([]byte, error) {
+	data := make([]byte, 0, blocks*SRIXBlockSize)
+	for block := 0; block < blocks; block++ {
+		b, err := s.ReadBlock(byte(block))
+		if err != nil {
+			return data, err
+		}
+		data = append(data, b...)
+	}
+	return data, nil
+}
@@ -0,0 +1,108 @@
+package typeb_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/typeb"
+)
+
+// srix is an SRIX4K tag with chip ID 0x5A, answering raw frames sent with
+// InCommunicateThru
+type srix struct {
+	blocks   [typeb.SRIX4KBlocks][]byte
+	selected bool
+	ignore   map[byte]bool // blocks that do not take writes
+}
+
+func (s *srix) frame(f []byte) []byte {
+	const chipID = 0x5A
+	timeout := []byte{0x01}
+	switch f[0] {
+	case typeb.SRIXInitiate:
+		return []byte{0x00, chipID}
+	case typeb.SRIXSelect:
+		if f[1] != chipID {
+			return timeout
+		}
+		s.selected = true
+		return []byte{0x00, chipID}
+	}
+	if !s.selected {
+		return timeout
+	}
+	switch f[0] {
+	case typeb.SRIXGetUID:
+		// Least significant byte first
+		return []byte{0x00, 0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x02, 0xD0}
+	case typeb.SRIXReadBlock:
+		if s.blocks[f[1]] == nil {
+			return []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF}
+		}
+		return append([]byte{0x00}, s.blocks[f[1]]...)
+	case typeb.SRIXWriteBlock:
+		if !s.ignore[f[1]] {
+			s.blocks[f[1]] = append([]byte{}, f[2:6]...)
+		}
+	case typeb.SRIXCompletion, typeb.SRIXResetToInventory:
+		s.selected = false
+	}
+	return timeout
+}
+
+func TestSRIX(t *testing.T) {
+	tag := &srix{ignore: map[byte]bool{0x05: true}}
+	reader := &pn532{t: t}
+	reader.handle = func(code byte, data []byte) []byte {
+		switch code {
+		case 0x4A: // no Type B target answers
+			return []byte{0x00}
+		case 0x42:
+			return tag.frame(data)
+		}
+		t.Fatalf("unexpected PN532 command %02X", code)
+		return nil
+	}
+	s := typeb.NewSRIXWithTransport(reader)
+
+	if _, err := s.GetUID(); err == nil {
+		t.Fatalf("GetUID of an unselected tag succeeded")
+	}
+	chipID, err := s.Initiate()
+	if err != nil || chipID != 0x5A {
+		t.Fatalf("Initiate: %02X, %v", chipID, err)
+	}
+	uid, err := s.GetUID()
+	if err != nil || !bytes.Equal(uid, []byte{0xD0, 0x02, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}) {
+		t.Fatalf("GetUID: % X, %v", uid, err)
+	}
+
+	if err := s.WriteBlock(0x07, []byte{0x01, 0x02, 0x03, 0x04}); err != nil {
+		t.Fatalf("WriteBlock: %v", err)
+	}
+	b, err := s.ReadBlock(0x07)
+	if err != nil || !bytes.Equal(b, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Fatalf("ReadBlock: % X, %v", b, err)
+	}
+	// Writes are not acknowledged, so an ignored write shows on reading back
+	if err := s.WriteBlock(0x05, []byte{0x01, 0x02, 0x03, 0x04}); err == nil {
+		t.Fatalf("WriteBlock of a block not taking writes succeeded")
+	}
+	if err := s.WriteBlock(0x07, []byte{0x01}); err == nil {
+		t.Fatalf("WriteBlock of 1 byte succeeded")
+	}
+	data, err := s.ReadAll(typeb.SRI512Blocks)
+	if err != nil || len(data) != typeb.SRI512Blocks*typeb.SRIXBlockSize || !bytes.Equal(data[28:32], []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Fatalf("ReadAll: % X, %v", data, err)
+	}
+
+	if err := s.Completion(); err != nil {
+		t.Fatalf("Completion: %v", err)
+	}
+	if _, err := s.ReadBlock(0x07); err == nil {
+		t.Fatalf("ReadBlock after Completion succeeded")
+	}
+	if err := s.Select(0x11); err == nil {
+		t.Fatalf("Select of another chip ID succeeded")
+	}
+}
@@ -0,0 +1,174 @@
+package typeb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// PN532 commands, sent as ACR122U direct transmit
+const (
+	cmdInDataExchange        = 0x40
+	cmdInCommunicateThru     = 0x42
+	cmdInDeselect            = 0x44
+	cmdInListPassiveTarget   = 0x4A
+	brTyTypeB106             = 0x03
+	pn532Host                = 0xD4
+	pn532Reply               = 0xD5
+	pn532StatusMoreData      = 0x40
+	pn532StatusMask          = 0x3F
+	maxDirectTransmitPayload = 0xFF
+)
+
+// ATQB is the answer of a Type B card to REQB
+type ATQB struct {
+	PUPI         []byte // pseudo-unique PICC identifier
+	AppData      []byte
+	ProtocolInfo []byte
+	MaxFrameSize int  // FSCI decoded to bytes
+	ISO14443_4   bool // protocol type bit: card supports ISO 14443-4
+	FWI          byte // frame waiting time integer
+}
+
+// frameSizes maps FSCI to the maximum frame size in bytes
+var frameSizes = []int{16, 24, 32, 40, 48, 64, 96, 128, 256}
+
+// ParseATQB decodes an ATQB (50, PUPI, application data, protocol info)
+func ParseATQB(b []byte) (*ATQB, error) {
+	if len(b) < 12 || b[0] != 0x50 {
+		return nil, fmt.Errorf("invalid ATQB % X", b)
+	}
+	a := &ATQB{
+		PUPI:         append([]byte{}, b[1:5]...),
+		AppData:      append([]byte{}, b[5:9]...),
+		ProtocolInfo: append([]byte{}, b[9:12]...),
+		ISO14443_4:   b[10]&0x01 != 0,
+		FWI:          b[11] >> 4,
+	}
+	fsci := int(b[10] >> 4)
+	a.MaxFrameSize = 256
+	if fsci < len(frameSizes) {
+		a.MaxFrameSize = frameSizes[fsci]
+	}
+	return a, nil
+}
+
+// TypeB exchanges APDUs with an ISO 14443-4 Type B card activated through
+// the PN532
+type TypeB struct {
+	card   hardware.Transport
+	target byte
+	atqb   *ATQB
+}
+
+// NewTypeB creates a Type B handler on the reader's connection
+func NewTypeB(reader *hardware.Reader) *TypeB {
+	return &TypeB{card: reader.Transport()}
+}
+
+// NewTypeBWithTransport creates a Type B handler on top of any transport
+func NewTypeBWithTransport(transport hardware.Transport) *TypeB {
+	return &TypeB{card: transport}
+}
+
+// pn532 sends a PN532 command through ACR122U direct transmit and returns the
+// response behind the reply code
+func pn532(card hardware.Transport, cmd byte, data []byte) ([]byte, error) {
+	payload := append([]byte{pn532Host, cmd}, data...)
+	if len(payload) > maxDirectTransmitPayload {
+		return nil, fmt.Errorf("command too long")
+	}
+	apdu := append([]byte{0xFF, 0x00, 0x00, 0x00, byte(len(payload))}, payload...)
+	rsp, err := card.Transmit(apdu)
+	if err != nil {
+		return nil, fmt.Errorf("transmit failed: %w", err)
+	}
+	if len(rsp) < 2 {
+		return nil, fmt.Errorf("invalid response length: %w", hardware.ErrWrongLength)
+	}
+	if sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]; sw1 != 0x90 || sw2 != 0x00 {
+		return nil, fmt.Errorf("error status: %w", hardware.NewStatusError(apdu, rsp))
+	}
+	rsp = rsp[:len(rsp)-2]
+	if len(rsp) < 2 || rsp[0] != pn532Reply || rsp[1] != cmd+1 {
+		return nil, fmt.Errorf("invalid PN532 response % X", rsp)
+	}
+	return rsp[2:], nil
+}
+
+// Activate polls for a Type B card with the application family identifier
+// (0x00 for all) and activates it with ATTRIB
+func (t *TypeB) Activate(afi byte) (*ATQB, error) {
+	rsp, err := pn532(t.card, cmdInListPassiveTarget, []byte{0x01, brTyTypeB106, afi})
+	if err != nil {
+		return nil, err
+	}
+	// NbTg, Tg, ATQB (12 bytes), ATTRIB_RES length and ATTRIB_RES
+	if len(rsp) < 1 || rsp[0] == 0 {
+		return nil, fmt.Errorf("no Type B card found")
+	}
+	if len(rsp) < 14 {
+		return nil, fmt.Errorf("target data too short")
+	}
+	atqb, err := ParseATQB(rsp[2:14])
+	if err != nil {
+		return nil, err
+	}
+	t.target = rsp[1]
+	t.atqb = atqb
+	return atqb, nil
+}
+
+// ATQB returns the answer of the activated card
+func (t *TypeB) ATQB() *ATQB {
+	return t.atqb
+}
+
+// Transmit sends an APDU to the activated card (InDataExchange) and returns
+// the response including the status word, so TypeB is a hardware.Transport
+func (t *TypeB) Transmit(apdu []byte) ([]byte, error) {
+	if t.atqb == nil {
+		return nil, fmt.Errorf("no activated Type B card")
+	}
+	var out []byte
+	rsp, err := pn532(t.card, cmdInDataExchange, append([]byte{t.target}, apdu...))
+	for {
+		if err != nil {
+			return nil, err
+		}
+		if len(rsp) < 1 {
+			return nil, fmt.Errorf("empty InDataExchange response")
+		}
+		if status := rsp[0] & pn532StatusMask; status != 0 {
+			return nil, fmt.Errorf("PN532 error %02X", status)
+		}
+		out = append(out, rsp[1:]...)
+		if rsp[0]&pn532StatusMoreData == 0 {
+			return out, nil
+		}
+		// Fetch the rest of a chained response
+		rsp, err = pn532(t.card, cmdInDataExchange, []byte{t.target})
+	}
+}
+
+// Deselect sends S(DESELECT) to the activated card
+func (t *TypeB) Deselect() error {
+	if t.atqb == nil {
+		return nil
+	}
+	rsp, err := pn532(t.card, cmdInDeselect, []byte{t.target})
+	if err != nil {
+		return err
+	}
+	if len(rsp) < 1 || rsp[0]&pn532StatusMask != 0 {
+		return fmt.Errorf("deselect failed % X", rsp)
+	}
+	t.atqb = nil
+	return nil
+}
+
+// PUPIValue returns the PUPI as a number for logging and lookups
+func (a *ATQB) PUPIValue() uint32 {
+	return binary.BigEndian.Uint32(a.PUPI)
+}
@@ -0,0 +1,143 @@
+package typeb_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/typeb"
+)
+
+// ATQB of a Type B card: PUPI, application data, protocol info with FSCI 8,
+// ISO 14443-4 support and FWI 8
+var atqb = []byte{
+	0x50, 0x1D, 0x2C, 0x3B, 0x4A, 0x00, 0x00, 0x00, 0x00, 0x00, 0x81, 0x81,
+}
+
+func TestParseATQB(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		atqb    []byte
+		frame   int
+		iso4    bool
+		fwi     byte
+		invalid bool
+	}{
+		{name: "FSCI 8", atqb: atqb, frame: 256, iso4: true, fwi: 8},
+		{name: "FSCI 5", atqb: []byte{0x50, 0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x51, 0x41}, frame: 64, iso4: true, fwi: 4},
+		{name: "FSCI 0 without ISO 14443-4", atqb: []byte{0x50, 0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, frame: 16},
+		{name: "FSCI above 8", atqb: []byte{0x50, 0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC1, 0x71}, frame: 256, iso4: true, fwi: 7},
+		{name: "too short", atqb: atqb[:11], invalid: true},
+		{name: "not an ATQB", atqb: append([]byte{0x51}, atqb[1:]...), invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := typeb.ParseATQB(tc.atqb)
+			if tc.invalid {
+				if err == nil {
+					t.Fatalf("ParseATQB accepted % X", tc.atqb)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseATQB: %v", err)
+			}
+			if a.MaxFrameSize != tc.frame || a.ISO14443_4 != tc.iso4 || a.FWI != tc.fwi {
+				t.Errorf("frame size %d, ISO 14443-4 %t, FWI %d", a.MaxFrameSize, a.ISO14443_4, a.FWI)
+			}
+			if !bytes.Equal(a.PUPI, tc.atqb[1:5]) || !bytes.Equal(a.ProtocolInfo, tc.atqb[9:12]) {
+				t.Errorf("PUPI % X, protocol info % X", a.PUPI, a.ProtocolInfo)
+			}
+		})
+	}
+	a, _ := typeb.ParseATQB(atqb)
+	if a.PUPIValue() != 0x1D2C3B4A {
+		t.Errorf("PUPIValue() = %08X", a.PUPIValue())
+	}
+}
+
+// pn532 answers the PN532 commands of direct transmits with its handler
+type pn532 struct {
+	t       *testing.T
+	handle  func(code byte, data []byte) []byte
+	history [][]byte
+}
+
+func (p *pn532) Transmit(cmd []byte) ([]byte, error) {
+	// FF 00 00 00 Lc D4 code data
+	if len(cmd) < 7 || cmd[5] != 0xD4 || int(cmd[4]) != len(cmd)-5 {
+		p.t.Fatalf("invalid direct transmit % X", cmd)
+	}
+	p.history = append(p.history, cmd[6:])
+	rsp := append([]byte{0xD5, cmd[6] + 1}, p.handle(cmd[6], cmd[7:])...)
+	return append(rsp, 0x90, 0x00), nil
+}
+
+func TestTypeB(t *testing.T) {
+	chained := false
+	reader := &pn532{t: t}
+	reader.handle = func(code byte, data []byte) []byte {
+		switch code {
+		case 0x4A: // InListPassiveTarget: one target, ATQB, ATTRIB_RES
+			return append(append([]byte{0x01, 0x01}, atqb...), 0x01, 0x00)
+		case 0x40: // InDataExchange
+			if data[0] != 0x01 {
+				t.Fatalf("exchange with target %02X", data[0])
+			}
+			if len(data) == 1 {
+				// The rest of a chained response
+				chained = true
+				return []byte{0x00, 0x33, 0x44, 0x90, 0x00}
+			}
+			if bytes.Equal(data[1:], []byte{0x00, 0xB0, 0x00, 0x00, 0x00}) {
+				return []byte{0x40, 0x11, 0x22}
+			}
+			if bytes.Equal(data[1:], []byte{0x00, 0x84, 0x00, 0x00, 0x08}) {
+				return []byte{0x01} // timeout
+			}
+			return []byte{0x00, 0x90, 0x00}
+		case 0x44: // InDeselect
+			return []byte{0x00}
+		}
+		t.Fatalf("unexpected PN532 command %02X", code)
+		return nil
+	}
+	b := typeb.NewTypeBWithTransport(reader)
+
+	if _, err := b.Transmit([]byte{0x00, 0xA4, 0x04, 0x00, 0x00}); err == nil {
+		t.Fatalf("Transmit before Activate succeeded")
+	}
+	a, err := b.Activate(0x00)
+	if err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+	if a.PUPIValue() != 0x1D2C3B4A || b.ATQB() != a {
+		t.Fatalf("ATQB %+v", a)
+	}
+	if !bytes.Equal(reader.history[0], []byte{0x4A, 0x01, 0x03, 0x00}) {
+		t.Errorf("InListPassiveTarget % X", reader.history[0])
+	}
+
+	rsp, err := b.Transmit([]byte{0x00, 0xA4, 0x04, 0x00, 0x00})
+	if err != nil || !bytes.Equal(rsp, []byte{0x90, 0x00}) {
+		t.Fatalf("Transmit: % X, %v", rsp, err)
+	}
+	rsp, err = b.Transmit([]byte{0x00, 0xB0, 0x00, 0x00, 0x00})
+	if err != nil || !chained || !bytes.Equal(rsp, []byte{0x11, 0x22, 0x33, 0x44, 0x90, 0x00}) {
+		t.Fatalf("Transmit of a chained response: % X, %v", rsp, err)
+	}
+	if _, err := b.Transmit([]byte{0x00, 0x84, 0x00, 0x00, 0x08}); err == nil {
+		t.Fatalf("Transmit with a PN532 timeout succeeded")
+	}
+
+	if err := b.Deselect(); err != nil {
+		t.Fatalf("Deselect: %v", err)
+	}
+	if b.ATQB() != nil {
+		t.Errorf("ATQB kept after Deselect")
+	}
+
+	// No card in the field
+	reader.handle = func(code byte, data []byte) []byte { return []byte{0x00} }
+	if _, err := typeb.NewTypeBWithTransport(reader).Activate(0x00); err == nil {
+		t.Errorf("Activate without a card succeeded")
+	}
+}
@@ -0,0 +1,96 @@
+package uid
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Reverse returns the UID in reversed byte order. Many readers and panels
+// treat the UID as a little-endian number.
+func Reverse(uid []byte) []byte {
+	r := make([]byte, len(uid))
+	for i, b := range uid {
+		r[len(uid)-1-i] = b
+	}
+	return r
+}
+
+// Hex formats the UID as upper case hex, e.g. 04A1B2C3
+func Hex(uid []byte) string {
+	return strings.ToUpper(hex.EncodeToString(uid))
+}
+
+// ColonHex formats the UID as colon separated upper case hex, e.g.
+// 04:A1:B2:C3
+func ColonHex(uid []byte) string {
+	parts := make([]string, len(uid))
+	for i, b := range uid {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// Decimal formats the UID as a big-endian decimal number
+func Decimal(uid []byte) string {
+	return new(big.Int).SetBytes(uid).String()
+}
+
+// DecimalReversed formats the UID as a little-endian decimal number, the
+// "10 digit" format of most USB badge readers for 4-byte UIDs
+func DecimalReversed(uid []byte) string {
+	return Decimal(Reverse(uid))
+}
+
+// Pad left-pads a number with zeros to width digits, e.g. Pad(Decimal(uid),
+// 10). Longer numbers are returned unchanged.
+func Pad(number string, width int) string {
+	if len(number) >= width {
+		return number
+	}
+	return strings.Repeat("0", width-len(number)) + number
+}
+
+// Parse reads a UID in hex with optional ':', '-' or ' ' separators
+func Parse(s string) ([]byte, error) {
+	clean := strings.NewReplacer(":", "", "-", "", " ", "").Replace(s)
+	uid, err := hex.DecodeString(clean)
+	if err != nil || len(uid) == 0 {
+		return nil, fmt.Errorf("invalid UID %q", s)
+	}
+	return uid, nil
+}
+
+// Formatter returns the format of the name: hex, hex-reversed, colon,
+// decimal, decimal-reversed, decimal10 (reversed, padded to 10 digits),
+// wiegand26 or wiegand34 (facility:card)
+func Formatter(name string) (func(uid []byte) string, error) {
+	switch strings.ToLower(name) {
+	case "hex", "":
+		return Hex, nil
+	case "hex-reversed":
+		return func(uid []byte) string { return Hex(Reverse(uid)) }, nil
+	case "colon":
+		return ColonHex, nil
+	case "decimal":
+		return Decimal, nil
+	case "decimal-reversed":
+		return DecimalReversed, nil
+	case "decimal10":
+		return func(uid []byte) string { return Pad(DecimalReversed(uid), 10) }, nil
+	case "wiegand26", "wiegand34":
+		build := Wiegand26
+		if strings.HasSuffix(name, "34") {
+			build = Wiegand34
+		}
+		return func(uid []byte) string {
+			w, err := build(uid)
+			if err != nil {
+				return Hex(uid)
+			}
+			return w.String()
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown UID format %q", name)
+}
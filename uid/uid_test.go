@@ -0,0 +1,73 @@
+package uid_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oo-developer/acr122u/uid"
+)
+
+func TestFormatter(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		uid    []byte
+		want   string
+	}{
+		{"hex", []byte{0x04, 0xA1, 0xB2, 0xC3}, "04A1B2C3"},
+		{"", []byte{0x04, 0xA1, 0xB2, 0xC3}, "04A1B2C3"},
+		{"HEX-reversed", []byte{0x04, 0xA1, 0xB2, 0xC3}, "C3B2A104"},
+		{"colon", []byte{0x04, 0xA1, 0xB2, 0xC3}, "04:A1:B2:C3"},
+		{"colon", []byte{0x0A}, "0A"},
+		{"decimal", []byte{0x04, 0xA1, 0xB2, 0xC3}, "77705923"},
+		{"decimal", []byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0xF6}, "1303689068602870"},
+		{"decimal-reversed", []byte{0x04, 0xA1, 0xB2, 0xC3}, "3283263748"},
+		{"decimal10", []byte{0x01, 0x02, 0x03, 0x04}, "0067305985"},
+		{"decimal10", []byte{0x04, 0xA1, 0xB2, 0xC3}, "3283263748"},
+		{"wiegand26", []byte{0x04, 0x7B, 0x11, 0xD7}, "123:04567"},
+		{"wiegand34", []byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5}, "45763:54501"},
+		// Too short for the frame, so printed as hex
+		{"wiegand34", []byte{0xB2, 0xC3, 0xD4}, "B2C3D4"},
+	} {
+		format, err := uid.Formatter(tc.format)
+		if err != nil {
+			t.Fatalf("Formatter(%q): %v", tc.format, err)
+		}
+		if got := format(tc.uid); got != tc.want {
+			t.Errorf("%s of % X = %s, want %s", tc.format, tc.uid, got, tc.want)
+		}
+	}
+	if _, err := uid.Formatter("base64"); err == nil {
+		t.Errorf("Formatter accepted an unknown format")
+	}
+}
+
+func TestPad(t *testing.T) {
+	for _, tc := range []struct {
+		number string
+		width  int
+		want   string
+	}{
+		{"123", 5, "00123"},
+		{"12345", 5, "12345"},
+		{"123456", 5, "123456"},
+		{"", 2, "00"},
+	} {
+		if got := uid.Pad(tc.number, tc.width); got != tc.want {
+			t.Errorf("Pad(%q, %d) = %q, want %q", tc.number, tc.width, got, tc.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	want := []byte{0x04, 0xA1, 0xB2, 0xC3}
+	for _, s := range []string{"04A1B2C3", "04a1b2c3", "04:A1:B2:C3", "04-A1-B2-C3", "04 A1 B2 C3"} {
+		if got, err := uid.Parse(s); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("Parse(%q) = % X, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"", "04A1B2C", "04A1B2CG", "::"} {
+		if got, err := uid.Parse(s); err == nil {
+			t.Errorf("Parse(%q) = % X", s, got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package uid
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Wiegand is a Wiegand frame: a leading even parity bit over the first half
+// of the data, the facility code, the card number and a trailing odd parity
+// bit over the second half
+type Wiegand struct {
+	Bits     int    // 26 or 34
+	Facility uint32 // 8 bits for 26-bit, 16 bits for 34-bit frames
+	Card     uint32 // 16 bits
+	Value    uint64 // the frame including parity bits
+}
+
+// Wiegand26 builds the standard 26-bit frame (H10301) from the last three
+// UID bytes: the first is the facility code, the other two the card number.
+// Panels that read the UID little-endian expect Wiegand26(Reverse(uid)).
+func Wiegand26(uid []byte) (*Wiegand, error) {
+	if len(uid) < 3 {
+		return nil, fmt.Errorf("UID too short for 26-bit Wiegand")
+	}
+	b := uid[len(uid)-3:]
+	return NewWiegand(26, uint32(b[0]), uint32(b[1])<<8|uint32(b[2]))
+}
+
+// Wiegand34 builds the 34-bit frame from the last four UID bytes: the first
+// two are the facility code, the other two the card number
+func Wiegand34(uid []byte) (*Wiegand, error) {
+	if len(uid) < 4 {
+		return nil, fmt.Errorf("UID too short for 34-bit Wiegand")
+	}
+	b := uid[len(uid)-4:]
+	return NewWiegand(34, uint32(b[0])<<8|uint32(b[1]), uint32(b[2])<<8|uint32(b[3]))
+}
+
+// NewWiegand builds a frame from a facility code and card number
+func NewWiegand(size int, facility uint32, card uint32) (*Wiegand, error) {
+	facilityBits, err := facilityBits(size)
+	if err != nil {
+		return nil, err
+	}
+	if facility >= 1<<facilityBits {
+		return nil, fmt.Errorf("facility code %d exceeds %d bits", facility, facilityBits)
+	}
+	if card > 0xFFFF {
+		return nil, fmt.Errorf("card number %d exceeds 16 bits", card)
+	}
+	data := uint64(facility)<<16 | uint64(card)
+	half := (size - 2) / 2
+	first := data >> half
+	second := data & (1<<half - 1)
+	value := data << 1
+	if bits.OnesCount64(first)%2 == 1 {
+		value |= 1 << (size - 1)
+	}
+	if bits.OnesCount64(second)%2 == 0 {
+		value |= 1
+	}
+	return &Wiegand{Bits: size, Facility: facility, Card: card, Value: value}, nil
+}
+
+// ParseWiegand extracts facility code and card number from a received frame
+// and checks both parity bits
+func ParseWiegand(size int, value uint64) (*Wiegand, error) {
+	facilityBits, err := facilityBits(size)
+	if err != nil {
+		return nil, err
+	}
+	if value >= 1<<size {
+		return nil, fmt.Errorf("value exceeds %d bits", size)
+	}
+	data := value >> 1 & (1<<(size-2) - 1)
+	w, err := NewWiegand(size, uint32(data>>16)&(1<<facilityBits-1), uint32(data&0xFFFF))
+	if err != nil {
+		return nil, err
+	}
+	if w.Value != value {
+		return nil, fmt.Errorf("parity error in %d-bit Wiegand frame", size)
+	}
+	return w, nil
+}
+
+func facilityBits(size int) (int, error) {
+	switch size {
+	case 26:
+		return 8, nil
+	case 34:
+		return 16, nil
+	}
+	return 0, fmt.Errorf("unsupported Wiegand size %d", size)
+}
+
+// Binary returns the frame as a string of 0 and 1
+func (w *Wiegand) Binary() string {
+	return fmt.Sprintf("%0*b", w.Bits, w.Value)
+}
+
+// Hex returns the frame as zero-padded upper case hex
+func (w *Wiegand) Hex() string {
+	return fmt.Sprintf("%0*X", (w.Bits+3)/4, w.Value)
+}
+
+// String formats facility code and card number as panels print them, e.g.
+// 123:04567 (the card number is zero-padded to 5 digits)
+func (w *Wiegand) String() string {
+	return fmt.Sprintf("%03d:%05d", w.Facility, w.Card)
+}
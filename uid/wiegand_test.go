@@ -0,0 +1,101 @@
+package uid_test
+
+import (
+	"testing"
+
+	"github.com/oo-developer/acr122u/uid"
+)
+
+func TestWiegand(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		build    func() (*uid.Wiegand, error)
+		facility uint32
+		card     uint32
+		binary   string
+		hex      string
+	}{
+		{
+			// Both halves have odd parity: leading bit set, trailing bit clear
+			name:     "26-bit",
+			build:    func() (*uid.Wiegand, error) { return uid.NewWiegand(26, 123, 4567) },
+			facility: 123, card: 4567,
+			binary: "10111101100010001110101110",
+			hex:    "2F623AE",
+		},
+		{
+			name:     "26-bit from the last three UID bytes",
+			build:    func() (*uid.Wiegand, error) { return uid.Wiegand26([]byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4}) },
+			facility: 0xB2, card: 0xC3D4,
+			binary: "01011001011000011110101001",
+			hex:    "16587A9",
+		},
+		{
+			name:     "34-bit from the last four UID bytes",
+			build:    func() (*uid.Wiegand, error) { return uid.Wiegand34([]byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5}) },
+			facility: 0xB2C3, card: 0xD4E5,
+			binary: "0101100101100001111010100111001010",
+			hex:    "16587A9CA",
+		},
+		{
+			// All zero data: only the odd parity bit is set
+			name:   "26-bit zero",
+			build:  func() (*uid.Wiegand, error) { return uid.NewWiegand(26, 0, 0) },
+			binary: "00000000000000000000000001",
+			hex:    "0000001",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := tc.build()
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			if w.Facility != tc.facility || w.Card != tc.card {
+				t.Errorf("facility %d, card %d", w.Facility, w.Card)
+			}
+			if w.Binary() != tc.binary || w.Hex() != tc.hex {
+				t.Errorf("frame %s (%s), want %s (%s)", w.Binary(), w.Hex(), tc.binary, tc.hex)
+			}
+			parsed, err := uid.ParseWiegand(w.Bits, w.Value)
+			if err != nil || *parsed != *w {
+				t.Errorf("ParseWiegand: %+v, %v", parsed, err)
+			}
+		})
+	}
+}
+
+func TestWiegandErrors(t *testing.T) {
+	if _, err := uid.NewWiegand(26, 256, 1); err == nil {
+		t.Errorf("NewWiegand accepted a 9-bit facility code for 26 bits")
+	}
+	if _, err := uid.NewWiegand(34, 1, 0x10000); err == nil {
+		t.Errorf("NewWiegand accepted a 17-bit card number")
+	}
+	if _, err := uid.NewWiegand(37, 1, 1); err == nil {
+		t.Errorf("NewWiegand accepted 37 bits")
+	}
+	if _, err := uid.Wiegand26([]byte{0x01, 0x02}); err == nil {
+		t.Errorf("Wiegand26 accepted a 2-byte UID")
+	}
+	if _, err := uid.Wiegand34([]byte{0x01, 0x02, 0x03}); err == nil {
+		t.Errorf("Wiegand34 accepted a 3-byte UID")
+	}
+
+	w, err := uid.NewWiegand(26, 123, 4567)
+	if err != nil {
+		t.Fatalf("NewWiegand: %v", err)
+	}
+	for _, tc := range []struct {
+		name  string
+		value uint64
+	}{
+		{"leading parity flipped", w.Value ^ 1<<25},
+		{"trailing parity flipped", w.Value ^ 1},
+		{"data bit flipped", w.Value ^ 1<<10},
+		{"more than 26 bits", w.Value | 1<<26},
+	} {
+		if _, err := uid.ParseWiegand(26, tc.value); err == nil {
+			t.Errorf("ParseWiegand accepted a frame with the %s", tc.name)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/ntag"
+)
+
+// ClassicArea is a run of MIFARE Classic sectors holding sealed data in
+// their data blocks. The manufacturer block and the trailers are skipped.
+type ClassicArea struct {
+	Sectors []int
+	KeyType byte // classic.KeyTypeA or classic.KeyTypeB
+	Key     []byte
+}
+
+// NTAGArea is a run of pages of NTAG or Ultralight user memory holding
+// sealed data. End zero means the end of the user memory of NTAG21x. Tags
+// with a password must be authenticated before.
+type NTAGArea struct {
+	Start byte
+	End   byte // last page, inclusive
+}
+
+// WriteClassic seals data for the connected card and writes it to the area
+func (v *Vault) WriteClassic(reader *hardware.Reader, area ClassicArea, data []byte) error {
+	sealed, err := v.Seal(reader.CardInfo().UID, data)
+	if err != nil {
+		return err
+	}
+	if capacity := 16 * area.dataBlocks(); len(sealed) > capacity {
+		return fmt.Errorf("%d bytes sealed exceed the %d bytes of the area", len(sealed), capacity)
+	}
+	c := classic.NewClassic(reader)
+	return area.each(c, func(block int) (bool, error) {
+		data := make([]byte, 16)
+		copy(data, sealed)
+		if err := c.WriteBlock(byte(block), data); err != nil {
+			return false, fmt.Errorf("failed to write block %d: %w", block, err)
+		}
+		sealed = sealed[min(16, len(sealed)):]
+		return len(sealed) == 0, nil
+	})
+}
+
+// ReadClassic reads sealed data from the area and opens it for the
+// connected card
+func (v *Vault) ReadClassic(reader *hardware.Reader, area ClassicArea) ([]byte, error) {
+	var sealed []byte
+	size := headerSize
+	c := classic.NewClassic(reader)
+	err := area.each(c, func(block int) (bool, error) {
+		data, err := c.ReadBlock(byte(block))
+		if err != nil {
+			return false, fmt.Errorf("failed to read block %d: %w", block, err)
+		}
+		if sealed = append(sealed, data...); len(sealed) == 16 {
+			if size, err = sealedSize(sealed); err != nil {
+				return false, err
+			}
+		}
+		return len(sealed) >= size, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < size {
+		return nil, fmt.Errorf("sealed data of %d bytes exceeds the area", size)
+	}
+	return v.Open(reader.CardInfo().UID, sealed)
+}
+
+// each authenticates the sectors of the area in turn and calls fn with their
+// data blocks until it is done
+func (a ClassicArea) each(c *classic.Classic, fn func(block int) (bool, error)) error {
+	if err := c.LoadKey(0x00, a.Key); err != nil {
+		return err
+	}
+	for _, sector := range a.Sectors {
+		first, count := sectorBlocks(sector)
+		if err := c.Authenticate(byte(first), a.KeyType, 0x00); err != nil {
+			return fmt.Errorf("failed to authenticate sector %d: %w", sector, err)
+		}
+		for block := first; block < first+count; block++ {
+			if block == 0 {
+				continue
+			}
+			done, err := fn(block)
+			if err != nil || done {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dataBlocks returns the number of data blocks of the area
+func (a ClassicArea) dataBlocks() int {
+	n := 0
+	for _, sector := range a.Sectors {
+		_, count := sectorBlocks(sector)
+		n += count
+		if sector == 0 {
+			n--
+		}
+	}
+	return n
+}
+
+// sectorBlocks returns the first block of a sector and the number of its
+// data blocks. Sectors 32 to 39 of 4K cards have 16 blocks.
+func sectorBlocks(sector int) (int, int) {
+	if sector < 32 {
+		return sector * 4, 3
+	}
+	return 128 + (sector-32)*16, 15
+}
+
+// WriteNTAG seals data for the connected tag and writes it to the area
+func (v *Vault) WriteNTAG(reader *hardware.Reader, area NTAGArea, data []byte) error {
+	n := ntag.NewNTAG(reader)
+	start, end, err := area.pages(n)
+	if err != nil {
+		return err
+	}
+	sealed, err := v.Seal(reader.CardInfo().UID, data)
+	if err != nil {
+		return err
+	}
+	if capacity := 4 * (int(end) - int(start) + 1); len(sealed) > capacity {
+		return fmt.Errorf("%d bytes sealed exceed the %d bytes of the area", len(sealed), capacity)
+	}
+	for i := 0; i*4 < len(sealed); i++ {
+		page := make([]byte, 4)
+		copy(page, sealed[i*4:])
+		if err := n.WritePage(start+byte(i), page); err != nil {
+			return fmt.Errorf("failed to write page %d: %w", int(start)+i, err)
+		}
+	}
+	return nil
+}
+
+// ReadNTAG reads sealed data from the area and opens it for the connected
+// tag
+func (v *Vault) ReadNTAG(reader *hardware.Reader, area NTAGArea) ([]byte, error) {
+	n := ntag.NewNTAG(reader)
+	start, end, err := area.pages(n)
+	if err != nil {
+		return nil, err
+	}
+	var sealed []byte
+	size := headerSize
+	for page := int(start); len(sealed) < size; {
+		if page > int(end) {
+			return nil, fmt.Errorf("sealed data of %d bytes exceeds the area", size)
+		}
+		// A read returns 4 pages, the last pages of the area are read one
+		// by one so that nothing past it is read
+		read, count := n.ReadPages, 4
+		if page+3 > int(end) {
+			read, count = n.ReadPage, 1
+		}
+		data, err := read(byte(page))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d: %w", page, err)
+		}
+		header := len(sealed) < headerSize
+		sealed = append(sealed, data...)
+		page += count
+		if header && len(sealed) >= headerSize {
+			if size, err = sealedSize(sealed); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v.Open(reader.CardInfo().UID, sealed)
+}
+
+func (a NTAGArea) pages(n *ntag.NTAG) (byte, byte, error) {
+	start, end := a.Start, a.End
+	if end == 0 {
+		var err error
+		if _, end, err = n.GetUserMemoryRange(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if start < 4 || end < start {
+		return 0, 0, fmt.Errorf("invalid page range %d to %d", start, end)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,180 @@
+package vault_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/oo-developer/acr122u/classic"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/hardware/mock"
+	"github.com/oo-developer/acr122u/ntag"
+	ntagsim "github.com/oo-developer/acr122u/ntag/simulator"
+	"github.com/oo-developer/acr122u/vault"
+)
+
+// present connects a mock reader to a card
+func present(t *testing.T, card hardware.Transport, atr []byte) *hardware.Reader {
+	t.Helper()
+	reader := mock.NewReader(card, atr)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return reader
+}
+
+// newVault returns a vault with a fixed master key
+func newVault(t *testing.T) *vault.Vault {
+	t.Helper()
+	v, err := vault.NewVault(masterKey)
+	if err != nil {
+		t.Fatalf("NewVault: %v", err)
+	}
+	return v
+}
+
+func TestClassic(t *testing.T) {
+	v := newVault(t)
+	card, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x04})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	reader := present(t, card, mock.Classic1KATR)
+	key := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	// Block 0 is skipped, sector 0 and 1 give 5 data blocks
+	area := vault.ClassicArea{Sectors: []int{0, 1}, KeyType: classic.KeyTypeA, Key: key}
+	data := bytes.Repeat([]byte("classic "), 5)
+
+	if err := v.WriteClassic(reader, area, data); err != nil {
+		t.Fatalf("WriteClassic: %v", err)
+	}
+	opened, err := v.ReadClassic(reader, area)
+	if err != nil || !bytes.Equal(opened, data) {
+		t.Fatalf("ReadClassic: %q, %v", opened, err)
+	}
+	c := classic.NewClassic(reader)
+	if err := c.LoadKey(0x00, key); err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	if err := c.Authenticate(0, classic.KeyTypeA, 0x00); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	block0, err := c.ReadBlock(0)
+	if err != nil || !bytes.Equal(block0[:4], []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Fatalf("block 0 overwritten: % X, %v", block0, err)
+	}
+
+	if err := v.WriteClassic(reader, area, make([]byte, 5*16-vault.Overhead+1)); err == nil {
+		t.Fatalf("WriteClassic beyond the area succeeded")
+	}
+	if _, err := v.ReadClassic(reader, vault.ClassicArea{Sectors: []int{2}, KeyType: classic.KeyTypeA, Key: key}); !errors.Is(err, vault.ErrNoData) {
+		t.Fatalf("ReadClassic of a blank area: %v", err)
+	}
+
+	// Data copied block by block to another card does not open there
+	copied, err := mock.NewClassic1K([]byte{0x01, 0x02, 0x03, 0x05})
+	if err != nil {
+		t.Fatalf("NewClassic1K: %v", err)
+	}
+	other := present(t, copied, mock.Classic1KATR)
+	to := classic.NewClassic(other)
+	if err := to.LoadKey(0x00, key); err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	for _, block := range []byte{1, 2, 4, 5, 6} {
+		if err := c.Authenticate(block, classic.KeyTypeA, 0x00); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		b, err := c.ReadBlock(block)
+		if err != nil {
+			t.Fatalf("ReadBlock: %v", err)
+		}
+		if err := to.Authenticate(block, classic.KeyTypeA, 0x00); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if err := to.WriteBlock(block, b); err != nil {
+			t.Fatalf("WriteBlock: %v", err)
+		}
+	}
+	if _, err := v.ReadClassic(other, area); !errors.Is(err, vault.ErrTampered) {
+		t.Fatalf("ReadClassic of copied data: %v", err)
+	}
+}
+
+// bounded is an NTAG keeping the highest page read
+type bounded struct {
+	*ntagsim.Simulator
+	last int
+}
+
+func (b *bounded) Transmit(cmd []byte) ([]byte, error) {
+	if len(cmd) == 5 && cmd[0] == 0xFF && cmd[1] == 0xB0 {
+		b.last = max(b.last, int(cmd[3])+int(cmd[4])/4-1)
+	}
+	return b.Simulator.Transmit(cmd)
+}
+
+func TestNTAG(t *testing.T) {
+	v := newVault(t)
+	sim, err := mock.NewNTAG215(uid)
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	card := &bounded{Simulator: sim}
+	reader := present(t, card, mock.NTAGATR)
+
+	// The user memory up to its end
+	data := []byte("ntag user memory")
+	area := vault.NTAGArea{Start: 4}
+	if err := v.WriteNTAG(reader, area, data); err != nil {
+		t.Fatalf("WriteNTAG: %v", err)
+	}
+	opened, err := v.ReadNTAG(reader, area)
+	if err != nil || !bytes.Equal(opened, data) {
+		t.Fatalf("ReadNTAG: %q, %v", opened, err)
+	}
+
+	// 11 pages filled exactly: reads of 4 pages would reach page 15
+	area = vault.NTAGArea{Start: 0x10, End: 0x1A}
+	data = bytes.Repeat([]byte{0xA5}, 11*4-vault.Overhead)
+	if err := v.WriteNTAG(reader, area, data); err != nil {
+		t.Fatalf("WriteNTAG: %v", err)
+	}
+	card.last = 0
+	opened, err = v.ReadNTAG(reader, area)
+	if err != nil || !bytes.Equal(opened, data) {
+		t.Fatalf("ReadNTAG: % X, %v", opened, err)
+	}
+	if card.last != int(area.End) {
+		t.Errorf("read up to page %d of an area ending at %d", card.last, area.End)
+	}
+	if err := v.WriteNTAG(reader, area, append(data, 0x00)); err == nil {
+		t.Fatalf("WriteNTAG beyond the area succeeded")
+	}
+	if _, err := v.ReadNTAG(reader, vault.NTAGArea{Start: 0x30, End: 0x40}); !errors.Is(err, vault.ErrNoData) {
+		t.Fatalf("ReadNTAG of a blank area: %v", err)
+	}
+	if _, err := v.ReadNTAG(reader, vault.NTAGArea{Start: 0x10, End: 0x18}); err == nil {
+		t.Fatalf("ReadNTAG of a cut area succeeded")
+	}
+
+	// Data copied page by page to another tag does not open there
+	copied, err := mock.NewNTAG215([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x07})
+	if err != nil {
+		t.Fatalf("NewNTAG215: %v", err)
+	}
+	other := present(t, copied, mock.NTAGATR)
+	from, to := ntag.NewNTAG(reader), ntag.NewNTAG(other)
+	for page := area.Start; page <= area.End; page++ {
+		b, err := from.ReadPage(page)
+		if err != nil {
+			t.Fatalf("ReadPage: %v", err)
+		}
+		if err := to.WritePage(page, b); err != nil {
+			t.Fatalf("WritePage: %v", err)
+		}
+	}
+	if _, err := v.ReadNTAG(other, area); !errors.Is(err, vault.ErrTampered) {
+		t.Fatalf("ReadNTAG of copied data: %v", err)
+	}
+}
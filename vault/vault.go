@@ -0,0 +1,131 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/oo-developer/acr122u/anticlone"
+)
+
+// magic starts sealed data on a card, followed by the format version
+var magic = []byte{'A', 'V'}
+
+const (
+	version    = 1
+	headerSize = 5 // magic, version, length of the sealed payload
+	nonceSize  = 12
+	tagSize    = 16
+
+	// Overhead is the number of bytes sealing adds to the data
+	Overhead = headerSize + nonceSize + tagSize
+)
+
+// ErrNoData is returned when a card holds no sealed data
+var ErrNoData = errors.New("no sealed data")
+
+// ErrTampered is returned when sealed data does not open with the card key,
+// because it was modified or copied from another card
+var ErrTampered = errors.New("sealed data was modified or belongs to another card")
+
+// Vault encrypts and authenticates data with AES-256-GCM under a key of each
+// card before it is written to the card, so a dump of the card reveals
+// nothing. The UID is authenticated with the data.
+type Vault struct {
+	key anticlone.KeyFunc
+}
+
+// NewVault creates a vault deriving the key of each card from a master key
+// of at least 16 bytes with HKDF-SHA256 over the UID
+func NewVault(masterKey []byte) (*Vault, error) {
+	if len(masterKey) < 16 {
+		return nil, fmt.Errorf("master key must be at least 16 bytes")
+	}
+	master := append([]byte{}, masterKey...)
+	return &Vault{key: func(id []byte) ([]byte, error) {
+		return hkdf.Key(sha256.New, master, nil, "acr122u vault "+string(id), 32)
+	}}, nil
+}
+
+// NewVaultWithKeys creates a vault taking the key of each card from a
+// function, e.g. backed by an HSM. Keys must be 16, 24 or 32 bytes.
+func NewVaultWithKeys(key anticlone.KeyFunc) *Vault {
+	return &Vault{key: key}
+}
+
+func (v *Vault) aead(id []byte) (cipher.AEAD, error) {
+	key, err := v.key(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid card key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal returns the data sealed for the card with the UID, ready to be
+// written: a header, the nonce, the ciphertext and the tag
+func (v *Vault) Seal(id []byte, data []byte) ([]byte, error) {
+	aead, err := v.aead(id)
+	if err != nil {
+		return nil, err
+	}
+	size := nonceSize + len(data) + tagSize
+	if size > 0xFFFF {
+		return nil, fmt.Errorf("data too large: %d bytes", len(data))
+	}
+	header := binary.BigEndian.AppendUint16(append(append([]byte{}, magic...), version), uint16(size))
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := append(header, nonce...)
+	return aead.Seal(sealed, nonce, data, append(header, id...)), nil
+}
+
+// Open returns the data of sealed data as read from the card with the UID.
+// Bytes after the sealed data, such as the rest of the area, are ignored.
+func (v *Vault) Open(id []byte, sealed []byte) ([]byte, error) {
+	size, err := sealedSize(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < size {
+		return nil, fmt.Errorf("sealed data truncated: %d of %d bytes", len(sealed), size)
+	}
+	aead, err := v.aead(id)
+	if err != nil {
+		return nil, err
+	}
+	header := sealed[:headerSize]
+	nonce := sealed[headerSize : headerSize+nonceSize]
+	data, err := aead.Open(nil, nonce, sealed[headerSize+nonceSize:size], append(append([]byte{}, header...), id...))
+	if err != nil {
+		return nil, ErrTampered
+	}
+	return data, nil
+}
+
+// sealedSize returns the size of sealed data, header included, from its
+// header
+func sealedSize(header []byte) (int, error) {
+	if len(header) < headerSize || !bytes.Equal(header[:2], magic) {
+		return 0, ErrNoData
+	}
+	if header[2] != version {
+		return 0, fmt.Errorf("unsupported sealed data version %d", header[2])
+	}
+	size := int(binary.BigEndian.Uint16(header[3:5]))
+	if size < nonceSize+tagSize {
+		return 0, ErrNoData
+	}
+	return headerSize + size, nil
+}
@@ -0,0 +1,111 @@
+package vault_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/oo-developer/acr122u/vault"
+)
+
+var (
+	masterKey = []byte("0123456789abcdef0123456789abcdef")
+	uid       = []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+)
+
+func TestSealOpen(t *testing.T) {
+	v, err := vault.NewVault(masterKey)
+	if err != nil {
+		t.Fatalf("NewVault: %v", err)
+	}
+	data := []byte("member 1234, valid until 2027")
+	sealed, err := v.Seal(uid, data)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if len(sealed) != len(data)+vault.Overhead || bytes.Contains(sealed, data) {
+		t.Fatalf("sealed % X", sealed)
+	}
+
+	// Bytes after the sealed data, e.g. the rest of the area, are ignored
+	opened, err := v.Open(uid, append(sealed, 0x00, 0x00, 0x00))
+	if err != nil || !bytes.Equal(opened, data) {
+		t.Fatalf("Open: %q, %v", opened, err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		id     []byte
+		sealed func() []byte
+		err    error
+	}{
+		{"tampered ciphertext", uid, func() []byte {
+			b := bytes.Clone(sealed)
+			b[len(b)-17] ^= 0x01 // last byte before the 16 byte tag
+			return b
+		}, vault.ErrTampered},
+		{"tampered tag", uid, func() []byte {
+			b := bytes.Clone(sealed)
+			b[len(b)-1] ^= 0x80
+			return b
+		}, vault.ErrTampered},
+		{"other UID", []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x07}, func() []byte {
+			return sealed
+		}, vault.ErrTampered},
+		{"blank", uid, func() []byte {
+			return make([]byte, len(sealed))
+		}, vault.ErrNoData},
+		{"header only", uid, func() []byte {
+			return sealed[:3]
+		}, vault.ErrNoData},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := v.Open(tc.id, tc.sealed()); !errors.Is(err, tc.err) {
+				t.Fatalf("Open: %v, want %v", err, tc.err)
+			}
+		})
+	}
+
+	if _, err := v.Open(uid, sealed[:len(sealed)-1]); err == nil {
+		t.Fatalf("Open of truncated data succeeded")
+	}
+	other, err := vault.NewVault([]byte("fedcba9876543210fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("NewVault: %v", err)
+	}
+	if _, err := other.Open(uid, sealed); !errors.Is(err, vault.ErrTampered) {
+		t.Fatalf("Open with another master key: %v", err)
+	}
+}
+
+func TestNewVault(t *testing.T) {
+	if _, err := vault.NewVault(make([]byte, 15)); err == nil {
+		t.Fatalf("NewVault accepted a 15 byte master key")
+	}
+
+	// Keys from a function are used as they are
+	v := vault.NewVaultWithKeys(func(id []byte) ([]byte, error) {
+		return make([]byte, 16), nil
+	})
+	sealed, err := v.Seal(uid, []byte{0x01})
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if data, err := v.Open(uid, sealed); err != nil || !bytes.Equal(data, []byte{0x01}) {
+		t.Fatalf("Open: % X, %v", data, err)
+	}
+
+	failing := errors.New("HSM unavailable")
+	v = vault.NewVaultWithKeys(func(id []byte) ([]byte, error) {
+		return nil, failing
+	})
+	if _, err := v.Seal(uid, []byte{0x01}); !errors.Is(err, failing) {
+		t.Fatalf("Seal without key: %v", err)
+	}
+	v = vault.NewVaultWithKeys(func(id []byte) ([]byte, error) {
+		return make([]byte, 10), nil
+	})
+	if _, err := v.Seal(uid, []byte{0x01}); err == nil {
+		t.Fatalf("Seal accepted a 10 byte key")
+	}
+}
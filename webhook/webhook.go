@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oo-developer/acr122u/hardware"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the body as "sha256=<hex>"
+const SignatureHeader = "X-Signature-256"
+
+// Config configures the endpoint and the retries
+type Config struct {
+	URL    string
+	Secret []byte // signing key, requests are unsigned if empty
+
+	MaxRetries int           // retries after the first attempt
+	Backoff    time.Duration // delay before the first retry, doubled per retry; 1s if zero
+	MaxBackoff time.Duration // delay limit, 30s if zero
+	Timeout    time.Duration // per request, 10s if zero
+
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// Event is the JSON payload of a card scan
+type Event struct {
+	UID       string    `json:"uid"`
+	Type      string    `json:"type"`
+	Reader    string    `json:"reader"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewEvent creates the event of the connected card with the UID in upper case
+// hex
+func NewEvent(reader *hardware.Reader) Event {
+	info := reader.CardInfo()
+	return Event{
+		UID:       strings.ToUpper(hex.EncodeToString(info.UID)),
+		Type:      info.Type,
+		Reader:    reader.Reader(),
+		Timestamp: time.Now(),
+	}
+}
+
+// Notifier posts scan events to a webhook. It is safe for concurrent use.
+type Notifier struct {
+	config Config
+}
+
+// NewNotifier creates a notifier for the endpoint
+func NewNotifier(config Config) (*Notifier, error) {
+	if !strings.HasPrefix(config.URL, "http://") && !strings.HasPrefix(config.URL, "https://") {
+		return nil, fmt.Errorf("invalid webhook URL %q", config.URL)
+	}
+	if config.MaxRetries < 0 {
+		return nil, fmt.Errorf("invalid retry count %d", config.MaxRetries)
+	}
+	if config.Backoff == 0 {
+		config.Backoff = time.Second
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &Notifier{config: config}, nil
+}
+
+// Notify posts the scan of the connected card
+func (n *Notifier) Notify(reader *hardware.Reader) error {
+	return n.NotifyContext(context.Background(), NewEvent(reader))
+}
+
+// NotifyContext posts the event, retrying on network errors, 429 and 5xx
+// responses. A Retry-After header in seconds replaces the backoff delay.
+func (n *Notifier) NotifyContext(ctx context.Context, event Event) error {
+	return n.Send(ctx, event)
+}
+
+// Send posts any JSON payload like NotifyContext, for events of other
+// packages
+func (n *Notifier) Send(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	delay := n.config.Backoff
+	for attempt := 0; ; attempt++ {
+		wait, err := n.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if wait < 0 || attempt == n.config.MaxRetries {
+			return err
+		}
+		if wait == 0 {
+			wait = delay
+		}
+		select {
+		case <-time.After(min(wait, n.config.MaxBackoff)):
+		case <-ctx.Done():
+			return fmt.Errorf("%v (gave up: %v)", err, ctx.Err())
+		}
+		delay = min(delay*2, n.config.MaxBackoff)
+	}
+}
+
+// post sends one request. On failure wait is negative if retrying is
+// pointless, otherwise the delay the server asked for or zero.
+func (n *Notifier) post(ctx context.Context, body []byte) (wait time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(ctx, n.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return -1, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "acr122u-webhook")
+	if len(n.config.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(n.config.Secret, body))
+	}
+	rsp, err := n.config.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to post event: %v", err)
+	}
+	defer rsp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(rsp.Body, 64*1024))
+
+	switch {
+	case rsp.StatusCode >= 200 && rsp.StatusCode < 300:
+		return 0, nil
+	case rsp.StatusCode == http.StatusTooManyRequests || rsp.StatusCode >= 500:
+		if s, err := strconv.Atoi(rsp.Header.Get("Retry-After")); err == nil && s > 0 {
+			wait = time.Duration(s) * time.Second
+		}
+		return wait, fmt.Errorf("webhook returned %s", rsp.Status)
+	default:
+		return -1, fmt.Errorf("webhook returned %s", rsp.Status)
+	}
+}
+
+// Sign returns the signature header value of the body
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value in constant time, for receivers
+// written in Go
+func Verify(secret []byte, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
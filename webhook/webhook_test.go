@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNotifyRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify([]byte("secret"), body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("request %d: invalid signature", calls.Load()+1)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(Config{URL: srv.URL, Secret: []byte("secret"), MaxRetries: 3, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	if err := n.NotifyContext(t.Context(), Event{UID: "04A1B2C3"}); err != nil {
+		t.Fatalf("NotifyContext: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("%d requests, want 3", calls.Load())
+	}
+}
+
+func TestNotifyConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("Decode: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		seen[e.UID]++
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(Config{URL: srv.URL, Secret: []byte("secret")})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := n.NotifyContext(t.Context(), Event{UID: fmt.Sprintf("%02X%02X", i, j)}); err != nil {
+					t.Errorf("NotifyContext: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 160 {
+		t.Fatalf("received %d distinct events, want 160", len(seen))
+	}
+}
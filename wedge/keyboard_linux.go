@@ -0,0 +1,157 @@
+package wedge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// uinput ioctls and input event codes from linux/uinput.h and
+// linux/input-event-codes.h
+const (
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+
+	evSyn     = 0x00
+	evKey     = 0x01
+	synReport = 0
+
+	keyLeftShift = 42
+)
+
+// key is a key code with the shift state of a US layout
+type key struct {
+	code  uint16
+	shift bool
+}
+
+var keys = map[rune]key{
+	'1': {2, false}, '2': {3, false}, '3': {4, false}, '4': {5, false}, '5': {6, false},
+	'6': {7, false}, '7': {8, false}, '8': {9, false}, '9': {10, false}, '0': {11, false},
+	'-': {12, false}, '\t': {15, false}, '\n': {28, false}, ' ': {57, false},
+	':': {39, true}, ',': {51, false}, '.': {52, false},
+	'q': {16, false}, 'w': {17, false}, 'e': {18, false}, 'r': {19, false}, 't': {20, false},
+	'y': {21, false}, 'u': {22, false}, 'i': {23, false}, 'o': {24, false}, 'p': {25, false},
+	'a': {30, false}, 's': {31, false}, 'd': {32, false}, 'f': {33, false}, 'g': {34, false},
+	'h': {35, false}, 'j': {36, false}, 'k': {37, false}, 'l': {38, false},
+	'z': {44, false}, 'x': {45, false}, 'c': {46, false}, 'v': {47, false}, 'b': {48, false},
+	'n': {49, false}, 'm': {50, false},
+}
+
+func init() {
+	for r, k := range keys {
+		if r >= 'a' && r <= 'z' {
+			keys[r-'a'+'A'] = key{k.code, true}
+		}
+	}
+}
+
+// uinputKeyboard is a virtual keyboard device created through /dev/uinput
+type uinputKeyboard struct {
+	file *os.File
+}
+
+func newKeyboard() (keyboard, error) {
+	file, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uinput: %v", err)
+	}
+	kb := &uinputKeyboard{file: file}
+	if err := kb.setup(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	// Give the desktop time to pick up the new device before typing
+	time.Sleep(200 * time.Millisecond)
+	return kb, nil
+}
+
+func (kb *uinputKeyboard) ioctl(request uintptr, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, kb.file.Fd(), request, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (kb *uinputKeyboard) setup() error {
+	if err := kb.ioctl(uiSetEvBit, evKey); err != nil {
+		return fmt.Errorf("failed to enable key events: %v", err)
+	}
+	codes := map[uint16]bool{keyLeftShift: true}
+	for _, k := range keys {
+		codes[k.code] = true
+	}
+	for code := range codes {
+		if err := kb.ioctl(uiSetKeyBit, uintptr(code)); err != nil {
+			return fmt.Errorf("failed to enable key %d: %v", code, err)
+		}
+	}
+
+	// struct uinput_user_dev: name, input_id, ff_effects_max and the abs arrays
+	dev := make([]byte, 80+8+4+4*64*4)
+	copy(dev, "ACR122U keyboard wedge")
+	binary.LittleEndian.PutUint16(dev[80:], 0x03)   // BUS_USB
+	binary.LittleEndian.PutUint16(dev[82:], 0x072F) // ACS
+	binary.LittleEndian.PutUint16(dev[84:], 0x2200) // ACR122U
+	binary.LittleEndian.PutUint16(dev[86:], 1)
+	if _, err := kb.file.Write(dev); err != nil {
+		return fmt.Errorf("failed to set up device: %v", err)
+	}
+	if err := kb.ioctl(uiDevCreate, 0); err != nil {
+		return fmt.Errorf("failed to create device: %v", err)
+	}
+	return nil
+}
+
+// emit writes a struct input_event with a zero time, which the kernel fills
+func (kb *uinputKeyboard) emit(typ uint16, code uint16, value int32) error {
+	var ev [24]byte
+	n := int(unsafe.Sizeof(syscall.Timeval{}))
+	binary.NativeEndian.PutUint16(ev[n:], typ)
+	binary.NativeEndian.PutUint16(ev[n+2:], code)
+	binary.NativeEndian.PutUint32(ev[n+4:], uint32(value))
+	if _, err := kb.file.Write(ev[:n+8]); err != nil {
+		return fmt.Errorf("failed to send key event: %v", err)
+	}
+	return nil
+}
+
+func (kb *uinputKeyboard) press(code uint16, value int32) error {
+	if err := kb.emit(evKey, code, value); err != nil {
+		return err
+	}
+	return kb.emit(evSyn, synReport, 0)
+}
+
+func (kb *uinputKeyboard) typeRune(r rune) error {
+	k, ok := keys[r]
+	if !ok {
+		return fmt.Errorf("cannot type %q", r)
+	}
+	if k.shift {
+		if err := kb.press(keyLeftShift, 1); err != nil {
+			return err
+		}
+	}
+	if err := kb.press(k.code, 1); err != nil {
+		return err
+	}
+	if err := kb.press(k.code, 0); err != nil {
+		return err
+	}
+	if k.shift {
+		return kb.press(keyLeftShift, 0)
+	}
+	return nil
+}
+
+func (kb *uinputKeyboard) close() error {
+	kb.ioctl(uiDevDestroy, 0)
+	return kb.file.Close()
+}
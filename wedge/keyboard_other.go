@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package wedge
+
+import "fmt"
+
+func newKeyboard() (keyboard, error) {
+	return nil, fmt.Errorf("keyboard wedge is not supported on this platform")
+}
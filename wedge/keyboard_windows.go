@@ -0,0 +1,73 @@
+package wedge
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	inputKeyboard    = 1
+	keyEventKeyUp    = 0x0002
+	keyEventUnicode  = 0x0004
+	virtualKeyTab    = 0x09
+	virtualKeyReturn = 0x0D
+)
+
+var procSendInput = syscall.NewLazyDLL("user32.dll").NewProc("SendInput")
+
+// keyboardInput is an INPUT structure holding a KEYBDINPUT, padded to the
+// size of the union
+type keyboardInput struct {
+	typ     uint32
+	ki      keybdInput
+	padding [8]byte
+}
+
+type keybdInput struct {
+	virtual   uint16
+	scan      uint16
+	flags     uint32
+	time      uint32
+	extraInfo uintptr
+}
+
+// sendInputKeyboard types through SendInput, sending characters as Unicode
+// so the keyboard layout does not matter
+type sendInputKeyboard struct{}
+
+func newKeyboard() (keyboard, error) {
+	if err := procSendInput.Find(); err != nil {
+		return nil, fmt.Errorf("failed to load SendInput: %v", err)
+	}
+	return sendInputKeyboard{}, nil
+}
+
+func (sendInputKeyboard) typeRune(r rune) error {
+	var down keyboardInput
+	down.typ = inputKeyboard
+	switch r {
+	case '\n':
+		down.ki.virtual = virtualKeyReturn
+	case '\t':
+		down.ki.virtual = virtualKeyTab
+	default:
+		if r > 0xFFFF {
+			return fmt.Errorf("cannot type %q", r)
+		}
+		down.ki.scan = uint16(r)
+		down.ki.flags = keyEventUnicode
+	}
+	up := down
+	up.ki.flags |= keyEventKeyUp
+	inputs := []keyboardInput{down, up}
+	n, _, err := procSendInput.Call(uintptr(len(inputs)), uintptr(unsafe.Pointer(&inputs[0])), unsafe.Sizeof(inputs[0]))
+	if int(n) != len(inputs) {
+		return fmt.Errorf("failed to send input: %v", err)
+	}
+	return nil
+}
+
+func (sendInputKeyboard) close() error {
+	return nil
+}
@@ -0,0 +1,134 @@
+package wedge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oo-developer/acr122u/debounce"
+	"github.com/oo-developer/acr122u/hardware"
+	"github.com/oo-developer/acr122u/uid"
+)
+
+// Format turns a UID into the text to type
+type Format func(id []byte) string
+
+// FormatHex types the UID as upper case hex, e.g. 04A1B2C3
+func FormatHex(id []byte) string {
+	return uid.Hex(id)
+}
+
+// FormatHexReversed types the UID as upper case hex in reversed byte order,
+// as many commercial readers do
+func FormatHexReversed(id []byte) string {
+	return uid.Hex(uid.Reverse(id))
+}
+
+// FormatDecimal types the UID as a big-endian decimal number
+func FormatDecimal(id []byte) string {
+	return uid.Decimal(id)
+}
+
+// FormatDecimalReversed types the UID as a little-endian decimal number
+// padded to 10 digits, the format of most USB badge readers for 4-byte UIDs
+func FormatDecimalReversed(id []byte) string {
+	return uid.Pad(uid.DecimalReversed(id), 10)
+}
+
+// Terminators typed after the UID
+const (
+	TerminatorNone  = ""
+	TerminatorEnter = "\n"
+	TerminatorTab   = "\t"
+)
+
+// Config configures what is typed
+type Config struct {
+	Format     Format        // FormatHex if nil
+	Terminator string        // typed after the UID
+	KeyDelay   time.Duration // pause between keystrokes, for slow applications
+	Cooldown   time.Duration // Run skips re-taps of a card within this window
+}
+
+// keyboard is the platform's virtual keyboard: uinput on Linux, SendInput on
+// Windows
+type keyboard interface {
+	// typeRune presses and releases the key of a character. Only digits,
+	// letters, ' ', '-', ':', '.', ',', '\n' and '\t' are needed.
+	typeRune(r rune) error
+	close() error
+}
+
+// Wedge types scanned UIDs as keystrokes like a USB keyboard badge reader
+type Wedge struct {
+	keyboard keyboard
+	config   Config
+	debounce *debounce.Debouncer
+}
+
+// NewWedge creates the virtual keyboard. On Linux this needs write access to
+// /dev/uinput.
+func NewWedge(config Config) (*Wedge, error) {
+	if config.Format == nil {
+		config.Format = FormatHex
+	}
+	kb, err := newKeyboard()
+	if err != nil {
+		return nil, err
+	}
+	return &Wedge{keyboard: kb, config: config, debounce: debounce.NewDebouncer(config.Cooldown)}, nil
+}
+
+// Close removes the virtual keyboard
+func (w *Wedge) Close() error {
+	return w.keyboard.close()
+}
+
+// Type types the formatted UID followed by the terminator
+func (w *Wedge) Type(id []byte) error {
+	return w.TypeString(w.config.Format(id) + w.config.Terminator)
+}
+
+// TypeString types text made of the supported characters
+func (w *Wedge) TypeString(s string) error {
+	for _, r := range s {
+		if err := w.keyboard.typeRune(r); err != nil {
+			return err
+		}
+		if w.config.KeyDelay > 0 {
+			time.Sleep(w.config.KeyDelay)
+		}
+	}
+	return nil
+}
+
+// Run types the UID of every card presented to the reader until waiting for
+// a card fails
+func (w *Wedge) Run(reader *hardware.Reader) error {
+	return w.RunContext(context.Background(), reader)
+}
+
+// RunContext is Run until ctx ends, which returns nil
+func (w *Wedge) RunContext(ctx context.Context, reader *hardware.Reader) error {
+	for {
+		if err := reader.WaitForCardContext(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to wait for card: %v", err)
+		}
+		if err := reader.Connect(); err != nil {
+			// Card removed too early, wait for the next one
+			continue
+		}
+		id := reader.CardInfo().UID
+		var err error
+		if w.debounce.Accept(id) {
+			err = w.Type(id)
+		}
+		reader.Disconnect()
+		if err != nil {
+			return err
+		}
+	}
+}